@@ -12,6 +12,7 @@ package main
 import (
 	"context"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
@@ -23,6 +24,7 @@ import (
 )
 
 func main() {
+	walcli.SetupLogging(false)
 	services, err := walcli.NewServices()
 	if err != nil {
 		log.Fatalf("failed to initialize services: %v", err)
@@ -36,7 +38,7 @@ func main() {
 
 	srv := &http.Server{Addr: ":" + port, Handler: router}
 	go func() {
-		log.Printf("Argon API listening on :%s", port)
+		slog.Info("Argon API listening", slog.String("port", port))
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("server error: %v", err)
 		}
@@ -46,7 +48,7 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
-	log.Println("shutting down...")
+	slog.Info("shutting down")
 	router.Shutdown() // stop supervised ingesters first
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 	"time"
 
@@ -14,8 +16,10 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/net/websocket"
 )
 
 // do drives one JSON request through the router.
@@ -269,6 +273,29 @@ func TestAPI_ConsoleReadSurface(t *testing.T) {
 	code, resp = do(t, router, "GET", "/api/v1/projects/console-api/branches/main/entries?collection=orders&order=asc", nil)
 	require.Equal(t, http.StatusOK, code)
 	require.Len(t, resp["entries"], 1)
+	assert.NotContains(t, resp["entries"].([]interface{})[0], "document", "bodies are opt-in")
+	code, resp = do(t, router, "GET", "/api/v1/projects/console-api/branches/main/entries?collection=orders&include_documents=true", nil)
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, resp["entries"], 1)
+	withDoc := resp["entries"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "orders", withDoc["collection"])
+	assert.Equal(t, "o1", withDoc["document"].(map[string]interface{})["_id"])
+
+	// The project-wide actor audit spans branches, oldest first, and
+	// pages by limit.
+	code, resp = do(t, router, "GET", "/api/v1/projects/console-api/entries?actor=agent:a&limit=2", nil)
+	require.Equal(t, http.StatusOK, code)
+	entries = resp["entries"].([]interface{})
+	require.Len(t, entries, 2)
+	assert.Equal(t, true, resp["has_more"])
+	assert.Less(t, entries[0].(map[string]interface{})["lsn"].(float64), entries[1].(map[string]interface{})["lsn"].(float64))
+	code, resp = do(t, router, "GET", "/api/v1/projects/console-api/entries?actor=agent:b", nil)
+	require.Equal(t, http.StatusOK, code)
+	assert.EqualValues(t, 2, resp["count"])
+	assert.Equal(t, false, resp["has_more"])
+	code, resp = do(t, router, "GET", "/api/v1/projects/console-api/entries", nil)
+	require.Equal(t, http.StatusBadRequest, code)
+	assert.Contains(t, resp["error"], "actor")
 
 	// Time-travel summary at head, then a collection at an earlier LSN.
 	code, resp = do(t, router, "GET", "/api/v1/projects/console-api/branches/main/time-travel/query", nil)
@@ -285,6 +312,40 @@ func TestAPI_ConsoleReadSurface(t *testing.T) {
 	require.Equal(t, http.StatusOK, code)
 	require.Len(t, resp["documents"], 1)
 
+	// Documents come back in _id order, identically on every read, unless
+	// a sort is given.
+	docIDs := func(resp map[string]interface{}) []interface{} {
+		var ids []interface{}
+		for _, d := range resp["documents"].([]interface{}) {
+			ids = append(ids, d.(map[string]interface{})["_id"])
+		}
+		return ids
+	}
+	headPath := "/api/v1/projects/console-api/branches/main/time-travel/query?collection=notes"
+	_, resp = do(t, router, "GET", headPath, nil)
+	ordered := docIDs(resp)
+	assert.Equal(t, []interface{}{"n0", "n1", "n2", "n3"}, ordered)
+	_, resp = do(t, router, "GET", headPath, nil)
+	assert.Equal(t, ordered, docIDs(resp))
+	_, resp = do(t, router, "GET", headPath+"&sort=-_id", nil)
+	assert.Equal(t, []interface{}{"n3", "n2", "n1", "n0"}, docIDs(resp))
+
+	// ?filter= narrows the documents, and the total, to those matching.
+	code, resp = do(t, router, "GET", headPath+"&filter="+url.QueryEscape(`{"_id":{"$in":["n1","n3"]}}`), nil)
+	require.Equal(t, http.StatusOK, code)
+	assert.EqualValues(t, 2, resp["total"])
+	assert.Equal(t, []interface{}{"n1", "n3"}, docIDs(resp))
+	ordersPath := "/api/v1/projects/console-api/branches/main/time-travel/query?collection=orders"
+	code, resp = do(t, router, "GET", ordersPath+"&filter="+url.QueryEscape(`{"customer.city":"Bergen"}`), nil)
+	require.Equal(t, http.StatusOK, code)
+	assert.EqualValues(t, 0, resp["total"])
+	code, resp = do(t, router, "GET", headPath+"&filter=", nil)
+	require.Equal(t, http.StatusOK, code)
+	assert.EqualValues(t, 4, resp["total"])
+	code, resp = do(t, router, "GET", headPath+"&filter="+url.QueryEscape(`{"_id":`), nil)
+	require.Equal(t, http.StatusBadRequest, code)
+	assert.Contains(t, resp["error"], "filter")
+
 	// Beyond the head is an error, stated plainly.
 	code, resp = do(t, router, "GET", "/api/v1/projects/console-api/branches/main/time-travel/query?lsn=99999", nil)
 	require.Equal(t, http.StatusBadRequest, code)
@@ -354,6 +415,199 @@ func TestAPI_ConsoleReadSurface(t *testing.T) {
 	require.Equal(t, http.StatusNotFound, code)
 }
 
+func TestAPI_QueryTimeout(t *testing.T) {
+	dbName := fmt.Sprintf("argon_api_timeout_test_%d", time.Now().UnixNano())
+	services, err := walcli.NewServicesAt("mongodb://localhost:27017", dbName)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = services.Client.Database(dbName).Drop(context.Background())
+	})
+
+	_, err = services.Projects.CreateProject("timeout-api")
+	require.NoError(t, err)
+	writer, err := services.WriterFor("timeout-api", "main")
+	require.NoError(t, err)
+	for i := 0; i < 20; i++ {
+		_, err := writer.Put(context.Background(), "notes", bson.M{"_id": fmt.Sprintf("n%d", i)})
+		require.NoError(t, err)
+	}
+
+	// A nanosecond budget has always run out by the time the replay
+	// starts, so every time-travel read takes the timeout path.
+	router := NewRouterWith(services, Options{QueryTimeout: time.Nanosecond})
+	t.Cleanup(router.Shutdown)
+
+	code, resp := do(t, router, "GET", "/api/v1/projects/timeout-api/branches/main/time-travel/query?collection=notes", nil)
+	require.Equal(t, http.StatusGatewayTimeout, code, "%v", resp)
+	assert.Contains(t, resp["error"], "query exceeded the 1ns timeout")
+
+	code, _ = do(t, router, "GET", "/api/v1/projects/timeout-api/branches/main/time-travel/query", nil)
+	assert.Equal(t, http.StatusGatewayTimeout, code)
+
+	// Write routes never inherit the read timeout: a snapshot cut off
+	// midway would leave partial state behind.
+	code, resp = do(t, router, "POST", "/api/v1/projects/timeout-api/branches/main/snapshots", nil)
+	require.Equal(t, http.StatusCreated, code, "%v", resp)
+
+	// The same read succeeds with a realistic budget.
+	relaxed := NewRouterWith(services, Options{QueryTimeout: time.Minute})
+	t.Cleanup(relaxed.Shutdown)
+	code, resp = do(t, relaxed, "GET", "/api/v1/projects/timeout-api/branches/main/time-travel/query?collection=notes", nil)
+	require.Equal(t, http.StatusOK, code, "%v", resp)
+}
+
+func TestAPI_SlowQueries(t *testing.T) {
+	dbName := fmt.Sprintf("argon_api_slow_test_%d", time.Now().UnixNano())
+	services, err := walcli.NewServicesAt("mongodb://localhost:27017", dbName)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = services.Client.Database(dbName).Drop(context.Background())
+	})
+	other, err := walcli.NewServicesAt("mongodb://localhost:27017", dbName)
+	require.NoError(t, err)
+
+	_, err = services.Projects.CreateProject("slow-api")
+	require.NoError(t, err)
+	writer, err := services.WriterFor("slow-api", "main")
+	require.NoError(t, err)
+	for i := 0; i < 20; i++ {
+		_, err := writer.Put(context.Background(), "notes", bson.M{"_id": fmt.Sprintf("n%d", i)})
+		require.NoError(t, err)
+	}
+
+	router := NewRouterWith(services, Options{SlowQueryThreshold: time.Nanosecond})
+	t.Cleanup(router.Shutdown)
+
+	code, resp := do(t, router, "GET", "/api/v1/status/slow-queries", nil)
+	require.Equal(t, http.StatusOK, code, "%v", resp)
+	assert.EqualValues(t, 0, resp["total"])
+	assert.Empty(t, resp["queries"])
+
+	code, resp = do(t, router, "GET", "/api/v1/projects/slow-api/branches/main/time-travel/query?collection=notes&lsn=5", nil)
+	require.Equal(t, http.StatusOK, code, "%v", resp)
+
+	code, resp = do(t, router, "GET", "/api/v1/status/slow-queries", nil)
+	require.Equal(t, http.StatusOK, code, "%v", resp)
+	assert.EqualValues(t, 1, resp["total"])
+	queries := resp["queries"].([]interface{})
+	require.Len(t, queries, 1)
+	assert.Equal(t, "notes", queries[0].(map[string]interface{})["collection"])
+
+	// The threshold belongs to this router's services; another set of
+	// services in the same process keeps its own.
+	assert.Equal(t, time.Nanosecond, services.Materializer.SlowQueries().Threshold())
+	assert.NotEqual(t, time.Nanosecond, other.Materializer.SlowQueries().Threshold())
+	assert.Empty(t, other.Materializer.SlowQueries().Entries())
+}
+
+func TestAPI_EntryPageTokens(t *testing.T) {
+	dbName := fmt.Sprintf("argon_api_pages_test_%d", time.Now().UnixNano())
+	services, err := walcli.NewServicesAt("mongodb://localhost:27017", dbName)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = services.Client.Database(dbName).Drop(context.Background())
+	})
+	router := NewRouter(services)
+	t.Cleanup(router.Shutdown)
+
+	_, err = services.Projects.CreateProject("pages-api")
+	require.NoError(t, err)
+	writer, err := services.WriterFor("pages-api", "main")
+	require.NoError(t, err)
+	put := func(id string) {
+		_, err := writer.Put(context.Background(), "notes", bson.M{"_id": id})
+		require.NoError(t, err)
+	}
+	for i := 0; i < 25; i++ {
+		put(fmt.Sprintf("n%02d", i))
+	}
+
+	// pageThrough follows next_token to the end, writing a new entry
+	// between pages the way a live branch would.
+	pageThrough := func(order string) []float64 {
+		var lsns []float64
+		path := "/api/v1/projects/pages-api/branches/main/entries?limit=10&order=" + order
+		token := ""
+		for page := 0; ; page++ {
+			url := path
+			if token != "" {
+				url += "&page_token=" + token
+			}
+			code, resp := do(t, router, "GET", url, nil)
+			require.Equal(t, http.StatusOK, code, "%v", resp)
+			for _, e := range resp["entries"].([]interface{}) {
+				lsns = append(lsns, e.(map[string]interface{})["lsn"].(float64))
+			}
+			if resp["has_more"] != true {
+				assert.Nil(t, resp["next_token"])
+				return lsns
+			}
+			token = resp["next_token"].(string)
+			require.NotEmpty(t, token)
+			put(fmt.Sprintf("%s-%d", order, page))
+		}
+	}
+
+	// Oldest first: every entry exactly once, with no gaps, including the
+	// ones appended while paging.
+	asc := pageThrough("asc")
+	require.GreaterOrEqual(t, len(asc), 25)
+	for i := 1; i < len(asc); i++ {
+		assert.Equal(t, asc[i-1]+1, asc[i], "gap or duplicate at %d", i)
+	}
+
+	// Newest first: appends land above the cursor and never shift the
+	// pages still to come, so the pass sees exactly what existed at its
+	// first page.
+	desc := pageThrough("desc")
+	require.Len(t, desc, len(asc))
+	assert.Equal(t, asc[len(asc)-1], desc[0])
+	for i := 1; i < len(desc); i++ {
+		assert.Equal(t, desc[i-1]-1, desc[i], "gap or duplicate at %d", i)
+	}
+
+	code, _ := do(t, router, "GET", "/api/v1/projects/pages-api/branches/main/entries?page_token=garbage!", nil)
+	assert.Equal(t, http.StatusBadRequest, code)
+	_, resp := do(t, router, "GET", "/api/v1/projects/pages-api/branches/main/entries?limit=1&order=asc", nil)
+	code, _ = do(t, router, "GET", "/api/v1/projects/pages-api/branches/main/entries?page_token="+resp["next_token"].(string), nil)
+	assert.Equal(t, http.StatusBadRequest, code, "a token only resumes the order it was issued for")
+}
+
+func TestAPI_LookupStatusCodes(t *testing.T) {
+	dbName := fmt.Sprintf("argon_api_lookup_test_%d", time.Now().UnixNano())
+	services, err := walcli.NewServicesAt("mongodb://localhost:27017", dbName)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = services.Client.Database(dbName).Drop(context.Background())
+	})
+	router := NewRouter(services)
+	t.Cleanup(router.Shutdown)
+
+	_, err = services.Projects.CreateProject("lookup-api")
+	require.NoError(t, err)
+
+	// Missing things are 404s, whichever lookup misses.
+	code, resp := do(t, router, "GET", "/api/v1/projects/nope/branches", nil)
+	require.Equal(t, http.StatusNotFound, code)
+	assert.Equal(t, `project "nope" not found`, resp["error"])
+	code, resp = do(t, router, "GET", "/api/v1/projects/lookup-api/branches/nope", nil)
+	require.Equal(t, http.StatusNotFound, code)
+	assert.Equal(t, `branch "nope" not found`, resp["error"])
+	code, _ = do(t, router, "POST", "/api/v1/projects/lookup-api/branches", map[string]string{"name": "x", "from": "nope"})
+	assert.Equal(t, http.StatusNotFound, code)
+
+	// A lookup that fails for any other reason is a server error, not a
+	// claim that the project does not exist.
+	broken, err := walcli.NewServicesAt("mongodb://localhost:27017", dbName)
+	require.NoError(t, err)
+	require.NoError(t, broken.Client.Disconnect(context.Background()))
+	brokenRouter := NewRouter(broken)
+	t.Cleanup(brokenRouter.Shutdown)
+	code, resp = do(t, brokenRouter, "GET", "/api/v1/projects/lookup-api/branches", nil)
+	require.Equal(t, http.StatusInternalServerError, code, "%v", resp)
+	assert.NotContains(t, resp["error"], "not found")
+}
+
 func TestAPI_TokenReadOnlyAndCORS(t *testing.T) {
 	dbName := fmt.Sprintf("argon_api_guard_test_%d", time.Now().UnixNano())
 	services, err := walcli.NewServicesAt("mongodb://localhost:27017", dbName)
@@ -418,6 +672,102 @@ func TestAPI_TokenReadOnlyAndCORS(t *testing.T) {
 	assert.Contains(t, rec.Header().Get("Content-Type"), "application/json")
 }
 
+func TestAPI_APIKeys(t *testing.T) {
+	dbName := fmt.Sprintf("argon_api_keys_test_%d", time.Now().UnixNano())
+	services, err := walcli.NewServicesAt("mongodb://localhost:27017", dbName)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = services.Client.Database(dbName).Drop(context.Background())
+	})
+	alpha, err := services.Projects.CreateProject("alpha")
+	require.NoError(t, err)
+	_, err = services.Projects.CreateProject("beta")
+	require.NoError(t, err)
+	key, stored, err := services.APIKeys.Create(alpha.ID, "ci")
+	require.NoError(t, err)
+	assert.NotContains(t, stored.Hash, key, "only the hash is stored")
+
+	router := NewRouterWith(services, Options{RequireAPIKey: true, Token: "admin", Version: "test"})
+	t.Cleanup(router.Shutdown)
+
+	call := func(method, path, bearer string) (int, map[string]interface{}) {
+		req := httptest.NewRequest(method, path, nil)
+		if bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		var decoded map[string]interface{}
+		if rec.Body.Len() > 0 {
+			_ = json.Unmarshal(rec.Body.Bytes(), &decoded)
+		}
+		return rec.Code, decoded
+	}
+
+	// Probes and discovery stay open.
+	code, _ := call("GET", "/health", "")
+	assert.Equal(t, http.StatusOK, code)
+	code, _ = call("GET", "/api/v1/meta", "")
+	assert.Equal(t, http.StatusOK, code)
+
+	// Missing and invalid keys.
+	code, _ = call("GET", "/api/v1/projects/alpha/branches", "")
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, _ = call("GET", "/api/v1/projects/alpha/branches", "argon_0000")
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, _ = call("GET", "/api/v1/projects/alpha/branches", "not-a-key")
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	// A valid key reaches its own project.
+	code, resp := call("GET", "/api/v1/projects/alpha/branches", key)
+	assert.Equal(t, http.StatusOK, code, "%v", resp)
+	code, resp = call("GET", "/api/v1/projects", key)
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, resp["projects"], 1)
+	assert.Equal(t, "alpha", resp["projects"].([]interface{})[0].(map[string]interface{})["name"])
+	assert.EqualValues(t, 1, resp["total"], "the page total is scoped too")
+
+	// ...and nothing else.
+	code, _ = call("GET", "/api/v1/projects/beta/branches", key)
+	assert.Equal(t, http.StatusForbidden, code)
+	code, _ = call("GET", "/api/v1/projects/nope/branches", key)
+	assert.Equal(t, http.StatusForbidden, code)
+	code, _ = call("GET", "/api/v1/merge-plans?project=beta", key)
+	assert.Equal(t, http.StatusForbidden, code)
+	code, _ = call("POST", "/api/v1/projects", key)
+	assert.Equal(t, http.StatusForbidden, code)
+
+	// The admin token stays unscoped.
+	code, resp = call("GET", "/api/v1/projects", "admin")
+	require.Equal(t, http.StatusOK, code)
+	assert.Len(t, resp["projects"], 2)
+	code, _ = call("GET", "/api/v1/projects/beta/branches", "admin")
+	assert.Equal(t, http.StatusOK, code)
+}
+
+func TestAPI_AlertHistory(t *testing.T) {
+	dbName := fmt.Sprintf("argon_api_alerts_test_%d", time.Now().UnixNano())
+	services, err := walcli.NewServicesAt("mongodb://localhost:27017", dbName)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = services.Client.Database(dbName).Drop(context.Background())
+	})
+	router := NewRouter(services)
+	t.Cleanup(router.Shutdown)
+
+	services.Monitor.TriggerAlert("warning", "api_test_alert", "raised by a test", nil)
+	require.Eventually(t, func() bool {
+		code, resp := do(t, router, "GET", "/api/v1/status/alerts?level=warning", nil)
+		return code == http.StatusOK && len(resp["alerts"].([]interface{})) == 1
+	}, 10*time.Second, 50*time.Millisecond)
+
+	code, resp := do(t, router, "GET", "/api/v1/status/alerts?level=critical", nil)
+	require.Equal(t, http.StatusOK, code)
+	assert.Empty(t, resp["alerts"])
+	code, _ = do(t, router, "GET", "/api/v1/status/alerts?since=yesterday", nil)
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
 func TestAPI_RestartReattachesIngesters(t *testing.T) {
 	dbName := fmt.Sprintf("argon_api_restart_test_%d", time.Now().UnixNano())
 	services, err := walcli.NewServicesAt("mongodb://localhost:27017", dbName)
@@ -512,3 +862,685 @@ func dbFromURI(uri string) string {
 	}
 	return db
 }
+
+func TestAPI_ValidateRestoreTarget(t *testing.T) {
+	dbName := fmt.Sprintf("argon_api_validate_test_%d", time.Now().UnixNano())
+	services, err := walcli.NewServicesAt("mongodb://localhost:27017", dbName)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = services.Client.Database(dbName).Drop(context.Background())
+	})
+	router := NewRouter(services)
+	t.Cleanup(router.Shutdown)
+
+	_, err = services.Projects.CreateProject("validate-api")
+	require.NoError(t, err)
+	writer, err := services.WriterFor("validate-api", "main")
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		_, err := writer.Put(context.Background(), "notes", bson.M{"_id": fmt.Sprintf("n%d", i)})
+		require.NoError(t, err)
+	}
+	code, _ := do(t, router, "POST", "/api/v1/projects/validate-api/branches", map[string]string{"name": "feature", "from": "main"})
+	require.Equal(t, http.StatusCreated, code)
+	featureWriter, err := services.WriterFor("validate-api", "feature")
+	require.NoError(t, err)
+	_, err = featureWriter.Put(context.Background(), "notes", bson.M{"_id": "f1"})
+	require.NoError(t, err)
+	project, err := services.Projects.GetProjectByName("validate-api")
+	require.NoError(t, err)
+	feature, err := services.Branches.GetBranch(project.ID, "feature")
+	require.NoError(t, err)
+	require.Greater(t, feature.BaseLSN, int64(0))
+
+	path := "/api/v1/projects/validate-api/branches/feature/time-travel/validate"
+	validate := func(body interface{}) map[string]interface{} {
+		code, resp := do(t, router, "POST", path, body)
+		require.Equal(t, http.StatusOK, code, "%v", resp)
+		return resp
+	}
+
+	// In range, by LSN and by time.
+	resp := validate(map[string]int64{"lsn": feature.BaseLSN})
+	assert.Equal(t, true, resp["valid"])
+	assert.EqualValues(t, feature.BaseLSN, resp["target_lsn"])
+	assert.EqualValues(t, feature.HeadLSN, resp["head_lsn"])
+	resp = validate(map[string]string{"time": time.Now().Add(-time.Millisecond).UTC().Format(time.RFC3339Nano)})
+	assert.Equal(t, true, resp["valid"], "%v", resp)
+
+	// Before the base.
+	resp = validate(map[string]int64{"lsn": feature.BaseLSN - 1})
+	assert.Equal(t, false, resp["valid"])
+	assert.Contains(t, resp["reason"], "before branch creation")
+
+	// Beyond the head.
+	resp = validate(map[string]int64{"lsn": feature.HeadLSN + 1})
+	assert.Equal(t, false, resp["valid"])
+	assert.Contains(t, resp["reason"], "future LSN")
+
+	// A time before any history.
+	resp = validate(map[string]string{"time": "2000-01-01T00:00:00Z"})
+	assert.Equal(t, false, resp["valid"])
+	assert.Contains(t, resp["reason"], "no entries found before")
+
+	// Exactly one of lsn or time.
+	code, _ = do(t, router, "POST", path, map[string]interface{}{})
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = do(t, router, "POST", path, map[string]interface{}{"lsn": 1, "time": "2026-01-01T00:00:00Z"})
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = do(t, router, "POST", "/api/v1/projects/validate-api/branches/missing/time-travel/validate", map[string]int64{"lsn": 1})
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func TestAPI_RestoreEndpoints(t *testing.T) {
+	dbName := fmt.Sprintf("argon_api_restore_test_%d", time.Now().UnixNano())
+	services, err := walcli.NewServicesAt("mongodb://localhost:27017", dbName)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = services.Client.Database(dbName).Drop(context.Background())
+	})
+	router := NewRouter(services)
+	t.Cleanup(router.Shutdown)
+
+	project, err := services.Projects.CreateProject("restore-api")
+	require.NoError(t, err)
+	writer, err := services.WriterFor("restore-api", "main")
+	require.NoError(t, err)
+	var lsns []int64
+	for i := 0; i < 3; i++ {
+		lsn, err := writer.Put(context.Background(), "notes", bson.M{"_id": fmt.Sprintf("n%d", i)})
+		require.NoError(t, err)
+		lsns = append(lsns, lsn)
+	}
+	base := "/api/v1/projects/restore-api/branches/main"
+
+	// Branch at a historical LSN: the new branch holds the first write only.
+	code, resp := do(t, router, "POST", base+"/branch-at", map[string]interface{}{"name": "at-first", "lsn": lsns[0]})
+	require.Equal(t, http.StatusCreated, code, "%v", resp)
+	assert.EqualValues(t, lsns[0], resp["branch"].(map[string]interface{})["head_lsn"])
+	assert.EqualValues(t, 2, resp["preview"].(map[string]interface{})["operations_to_discard"])
+	atFirst, err := services.Branches.GetBranch(project.ID, "at-first")
+	require.NoError(t, err)
+	state, err := services.Materializer.MaterializeCollection(atFirst, "notes")
+	require.NoError(t, err)
+	assert.Len(t, state, 1)
+
+	// ...and at a time (now: the whole history).
+	code, resp = do(t, router, "POST", base+"/branch-at",
+		map[string]interface{}{"name": "at-now", "time": time.Now().UTC().Format(time.RFC3339Nano)})
+	require.Equal(t, http.StatusCreated, code, "%v", resp)
+	assert.EqualValues(t, 0, resp["preview"].(map[string]interface{})["operations_to_discard"])
+	code, _ = do(t, router, "POST", base+"/branch-at", map[string]interface{}{"name": "at-first", "lsn": lsns[0]})
+	assert.Equal(t, http.StatusConflict, code, "names stay unique")
+
+	// ...and at a tag, which time-travel queries accept too.
+	main, err := services.Branches.GetBranch(project.ID, "main")
+	require.NoError(t, err)
+	_, err = services.TimeTravel.CreateTag(main.ID, "second", lsns[1])
+	require.NoError(t, err)
+	code, resp = do(t, router, "POST", base+"/branch-at", map[string]interface{}{"name": "at-second", "tag": "second"})
+	require.Equal(t, http.StatusCreated, code, "%v", resp)
+	assert.EqualValues(t, lsns[1], resp["branch"].(map[string]interface{})["head_lsn"])
+	code, resp = do(t, router, "GET", base+"/time-travel/query?collection=notes&tag=second", nil)
+	require.Equal(t, http.StatusOK, code, "%v", resp)
+	assert.EqualValues(t, 2, resp["total"])
+	code, _ = do(t, router, "GET", base+"/time-travel/query?collection=notes&tag=missing", nil)
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = do(t, router, "POST", base+"/branch-at", map[string]interface{}{"name": "both", "tag": "second", "lsn": lsns[0]})
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	// A reset without confirmation only previews.
+	code, resp = do(t, router, "POST", base+"/restore", map[string]interface{}{"lsn": lsns[0]})
+	require.Equal(t, http.StatusBadRequest, code)
+	assert.Contains(t, resp["error"], "confirm=true")
+	assert.EqualValues(t, 2, resp["preview"].(map[string]interface{})["operations_to_discard"])
+	main, err = services.Branches.GetBranch(project.ID, "main")
+	require.NoError(t, err)
+	assert.Equal(t, lsns[2], main.HeadLSN, "unconfirmed reset must not move the head")
+
+	// Confirmed, with a backup of the discarded operations.
+	code, resp = do(t, router, "POST", base+"/restore?confirm=true", map[string]interface{}{"lsn": lsns[0], "backup": "pre-reset"})
+	require.Equal(t, http.StatusOK, code, "%v", resp)
+	assert.EqualValues(t, lsns[0], resp["branch"].(map[string]interface{})["head_lsn"])
+	assert.EqualValues(t, lsns[2], resp["backup"].(map[string]interface{})["head_lsn"])
+	main, err = services.Branches.GetBranch(project.ID, "main")
+	require.NoError(t, err)
+	state, err = services.Materializer.MaterializeCollection(main, "notes")
+	require.NoError(t, err)
+	assert.Len(t, state, 1)
+
+	// Bad targets.
+	code, _ = do(t, router, "POST", base+"/restore?confirm=true", map[string]interface{}{"lsn": lsns[2] + 100})
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = do(t, router, "POST", base+"/restore?confirm=true", map[string]interface{}{})
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = do(t, router, "POST", "/api/v1/projects/restore-api/branches/missing/restore?confirm=true", map[string]int64{"lsn": 1})
+	assert.Equal(t, http.StatusNotFound, code)
+	code, _ = do(t, router, "POST", base+"/restore?confirm=true", map[string]interface{}{"tag": "missing"})
+	assert.Equal(t, http.StatusNotFound, code)
+	code, _ = do(t, router, "POST", base+"/branch-at", map[string]interface{}{"name": "too-far", "lsn": lsns[2] + 100})
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = do(t, router, "POST", base+"/branch-at", map[string]interface{}{"name": "no-tag", "tag": "missing"})
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func TestAPI_RestoreProtectedBranch(t *testing.T) {
+	dbName := fmt.Sprintf("argon_api_protect_test_%d", time.Now().UnixNano())
+	services, err := walcli.NewServicesAt("mongodb://localhost:27017", dbName)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = services.Client.Database(dbName).Drop(context.Background())
+	})
+	router := NewRouter(services)
+	t.Cleanup(router.Shutdown)
+
+	project, err := services.Projects.CreateProject("protect-api")
+	require.NoError(t, err)
+	writer, err := services.WriterFor("protect-api", "main")
+	require.NoError(t, err)
+	target, err := writer.Put(context.Background(), "notes", bson.M{"_id": "n1"})
+	require.NoError(t, err)
+	_, err = writer.Put(context.Background(), "notes", bson.M{"_id": "n2"})
+	require.NoError(t, err)
+	main, err := services.Branches.GetBranch(project.ID, "main")
+	require.NoError(t, err)
+	require.NoError(t, services.Branches.SetProtected(main.ID, true))
+	path := "/api/v1/projects/protect-api/branches/main/restore?confirm=true"
+
+	code, resp := do(t, router, "POST", path, map[string]interface{}{"lsn": target})
+	assert.Equal(t, http.StatusConflict, code)
+	assert.Contains(t, resp["error"], "read-only")
+	code, resp = do(t, router, "POST", path, map[string]interface{}{"lsn": target, "force": true})
+	require.Equal(t, http.StatusOK, code, "%v", resp)
+	assert.EqualValues(t, target, resp["branch"].(map[string]interface{})["head_lsn"])
+	assert.Equal(t, true, resp["branch"].(map[string]interface{})["protected"])
+}
+
+func TestAPI_TimeTravelExtendedJSON(t *testing.T) {
+	dbName := fmt.Sprintf("argon_api_extjson_test_%d", time.Now().UnixNano())
+	services, err := walcli.NewServicesAt("mongodb://localhost:27017", dbName)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = services.Client.Database(dbName).Drop(context.Background())
+	})
+	router := NewRouter(services)
+	t.Cleanup(router.Shutdown)
+
+	_, err = services.Projects.CreateProject("extjson-api")
+	require.NoError(t, err)
+	writer, err := services.WriterFor("extjson-api", "main")
+	require.NoError(t, err)
+	id := primitive.NewObjectID()
+	placed := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	price, err := primitive.ParseDecimal128("19.99")
+	require.NoError(t, err)
+	_, err = writer.Put(context.Background(), "orders", bson.M{
+		"_id": id, "placed_at": primitive.NewDateTimeFromTime(placed), "price": price, "qty": int64(3),
+	})
+	require.NoError(t, err)
+
+	path := "/api/v1/projects/extjson-api/branches/main/time-travel/query?collection=orders"
+
+	// Plain JSON flattens the types.
+	code, resp := do(t, router, "GET", path, nil)
+	require.Equal(t, http.StatusOK, code)
+	doc := resp["documents"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, id.Hex(), doc["_id"])
+
+	// Extended JSON keeps them, and round-trips back to the same BSON.
+	code, resp = do(t, router, "GET", path+"&format=extjson", nil)
+	require.Equal(t, http.StatusOK, code)
+	assert.EqualValues(t, 1, resp["total"])
+	doc = resp["documents"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"$oid": id.Hex()}, doc["_id"])
+	assert.Equal(t, map[string]interface{}{"$date": map[string]interface{}{"$numberLong": fmt.Sprint(placed.UnixMilli())}}, doc["placed_at"])
+	assert.Equal(t, map[string]interface{}{"$numberDecimal": "19.99"}, doc["price"])
+	assert.Equal(t, map[string]interface{}{"$numberLong": "3"}, doc["qty"])
+
+	raw, err := json.Marshal(doc)
+	require.NoError(t, err)
+	var decoded bson.M
+	require.NoError(t, bson.UnmarshalExtJSON(raw, true, &decoded))
+	assert.Equal(t, id, decoded["_id"])
+	assert.Equal(t, price, decoded["price"])
+	assert.Equal(t, int64(3), decoded["qty"])
+
+	// Projection still applies; unknown formats are refused.
+	code, resp = do(t, router, "GET", path+"&format=extjson&fields=qty", nil)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []interface{}{map[string]interface{}{
+		"_id": map[string]interface{}{"$oid": id.Hex()}, "qty": map[string]interface{}{"$numberLong": "3"},
+	}}, resp["documents"])
+	code, resp = do(t, router, "GET", path+"&format=xml", nil)
+	require.Equal(t, http.StatusBadRequest, code)
+	assert.Contains(t, resp["error"], "format")
+}
+
+func TestAPI_TimeTravelProjection(t *testing.T) {
+	dbName := fmt.Sprintf("argon_api_projection_test_%d", time.Now().UnixNano())
+	services, err := walcli.NewServicesAt("mongodb://localhost:27017", dbName)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = services.Client.Database(dbName).Drop(context.Background())
+	})
+	router := NewRouter(services)
+	t.Cleanup(router.Shutdown)
+
+	_, err = services.Projects.CreateProject("projection-api")
+	require.NoError(t, err)
+	writer, err := services.WriterFor("projection-api", "main")
+	require.NoError(t, err)
+	_, err = writer.Put(context.Background(), "orders", bson.M{"_id": "o1", "total": 12, "customer": bson.M{"name": "ada", "city": "Oslo"}})
+	require.NoError(t, err)
+
+	// ?fields= projects each returned document.
+	path := "/api/v1/projects/projection-api/branches/main/time-travel/query?collection=orders"
+	code, resp := do(t, router, "GET", path+"&fields=customer.city", nil)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []interface{}{map[string]interface{}{
+		"_id": "o1", "customer": map[string]interface{}{"city": "Oslo"},
+	}}, resp["documents"])
+	code, resp = do(t, router, "GET", path+"&fields=-customer,-_id", nil)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []interface{}{map[string]interface{}{"total": float64(12)}}, resp["documents"])
+	code, resp = do(t, router, "GET", path+"&fields=total,-customer", nil)
+	require.Equal(t, http.StatusBadRequest, code)
+	assert.Contains(t, resp["error"], "mix")
+}
+
+func TestAPI_BranchCreation(t *testing.T) {
+	dbName := fmt.Sprintf("argon_api_creation_test_%d", time.Now().UnixNano())
+	services, err := walcli.NewServicesAt("mongodb://localhost:27017", dbName)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = services.Client.Database(dbName).Drop(context.Background())
+	})
+	router := NewRouter(services)
+	t.Cleanup(router.Shutdown)
+
+	project, err := services.Projects.CreateProject("creation-api")
+	require.NoError(t, err)
+	writer, err := services.WriterFor("creation-api", "main")
+	require.NoError(t, err)
+	forkLSN, err := writer.Put(context.Background(), "notes", bson.M{"_id": "n1"})
+	require.NoError(t, err)
+	code, _ := do(t, router, "POST", "/api/v1/projects/creation-api/branches", map[string]string{"name": "feature", "from": "main"})
+	require.Equal(t, http.StatusCreated, code)
+	feature, err := services.Branches.GetBranch(project.ID, "feature")
+	require.NoError(t, err)
+
+	code, resp := do(t, router, "GET", "/api/v1/projects/creation-api/branches/feature/creation", nil)
+	require.Equal(t, http.StatusOK, code, "%v", resp)
+	assert.Equal(t, feature.ID, resp["branch_id"])
+	assert.Equal(t, "main", resp["parent_name"])
+	assert.EqualValues(t, forkLSN, resp["fork_lsn"])
+	assert.EqualValues(t, feature.CreatedLSN, resp["created_lsn"])
+	entry, ok := resp["entry"].(map[string]interface{})
+	require.True(t, ok, "%v", resp)
+	assert.Equal(t, "create_branch", entry["operation"])
+	assert.EqualValues(t, feature.CreatedLSN, entry["lsn"])
+	assert.Equal(t, "feature", entry["metadata"].(map[string]interface{})["branch_name"])
+
+	// Restore forks record their creation LSN too.
+	restored, err := services.Restore.CreateBranchAtLSN(project.ID, feature.ID, "restored", forkLSN)
+	require.NoError(t, err)
+	creation, err := services.Branches.GetBranchCreationEntry(restored.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "feature", creation.ParentName)
+	require.NotNil(t, creation.Entry)
+	assert.Equal(t, restored.CreatedLSN, creation.Entry.LSN)
+
+	code, _ = do(t, router, "GET", "/api/v1/projects/creation-api/branches/missing/creation", nil)
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func TestAPI_ListPagination(t *testing.T) {
+	dbName := fmt.Sprintf("argon_api_list_pages_test_%d", time.Now().UnixNano())
+	services, err := walcli.NewServicesAt("mongodb://localhost:27017", dbName)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = services.Client.Database(dbName).Drop(context.Background())
+	})
+	router := NewRouter(services)
+	t.Cleanup(router.Shutdown)
+
+	for i := 0; i < 120; i++ {
+		_, err := services.Projects.CreateProject(fmt.Sprintf("proj-%03d", i))
+		require.NoError(t, err)
+	}
+
+	// Three pages at the default limit, in name order, without overlap.
+	var names []string
+	offset := ""
+	for page := 0; page < 3; page++ {
+		code, resp := do(t, router, "GET", "/api/v1/projects?offset="+offset, nil)
+		require.Equal(t, http.StatusOK, code)
+		assert.EqualValues(t, 120, resp["total"])
+		projects := resp["projects"].([]interface{})
+		for _, p := range projects {
+			names = append(names, p.(map[string]interface{})["name"].(string))
+		}
+		if page < 2 {
+			require.Len(t, projects, 50)
+			offset = fmt.Sprint(resp["next_offset"])
+		} else {
+			require.Len(t, projects, 20)
+			assert.NotContains(t, resp, "next_offset", "last page")
+		}
+	}
+	require.Len(t, names, 120)
+	for i, name := range names {
+		assert.Equal(t, fmt.Sprintf("proj-%03d", i), name)
+	}
+
+	code, resp := do(t, router, "GET", "/api/v1/projects?limit=5&offset=118", nil)
+	require.Equal(t, http.StatusOK, code)
+	assert.Len(t, resp["projects"], 2)
+	code, resp = do(t, router, "GET", "/api/v1/projects?limit=1000", nil)
+	require.Equal(t, http.StatusOK, code)
+	assert.Len(t, resp["projects"], 120, "limit is capped at 500")
+	code, _ = do(t, router, "GET", "/api/v1/projects?offset=abc", nil)
+	assert.Equal(t, http.StatusBadRequest, code)
+	for _, limit := range []string{"0", "-1"} {
+		code, _ = do(t, router, "GET", "/api/v1/projects?limit="+limit, nil)
+		assert.Equal(t, http.StatusBadRequest, code, "limit=%s", limit)
+	}
+
+	// Branches page the same way.
+	for _, name := range []string{"b1", "b2", "b3"} {
+		code, _ := do(t, router, "POST", "/api/v1/projects/proj-000/branches", map[string]string{"name": name, "from": "main"})
+		require.Equal(t, http.StatusCreated, code)
+	}
+	code, resp = do(t, router, "GET", "/api/v1/projects/proj-000/branches?limit=2", nil)
+	require.Equal(t, http.StatusOK, code)
+	assert.EqualValues(t, 4, resp["total"])
+	assert.Len(t, resp["branches"], 2)
+	assert.EqualValues(t, 2, resp["next_offset"])
+	code, resp = do(t, router, "GET", "/api/v1/projects/proj-000/branches?limit=2&offset=2", nil)
+	require.Equal(t, http.StatusOK, code)
+	branches := resp["branches"].([]interface{})
+	require.Len(t, branches, 2)
+	assert.Equal(t, "main", branches[1].(map[string]interface{})["name"])
+	assert.NotContains(t, resp, "next_offset")
+}
+
+func TestAPI_WALStream(t *testing.T) {
+	dbName := fmt.Sprintf("argon_api_stream_test_%d", time.Now().UnixNano())
+	services, err := walcli.NewServicesAt("mongodb://localhost:27017", dbName)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = services.Client.Database(dbName).Drop(context.Background())
+	})
+	router := NewRouter(services)
+	t.Cleanup(router.Shutdown)
+	srv := httptest.NewServer(router)
+	t.Cleanup(srv.Close)
+
+	_, err = services.Projects.CreateProject("stream-api")
+	require.NoError(t, err)
+	code, _ := do(t, router, "POST", "/api/v1/projects/stream-api/branches", map[string]string{"name": "exp", "from": "main"})
+	require.Equal(t, http.StatusCreated, code)
+
+	code, _ = do(t, router, "GET", "/api/v1/wal/stream", nil)
+	assert.Equal(t, http.StatusBadRequest, code, "project is required")
+	code, _ = do(t, router, "GET", "/api/v1/wal/stream?project=nope", nil)
+	assert.Equal(t, http.StatusNotFound, code)
+	code, _ = do(t, router, "GET", "/api/v1/wal/stream?project=stream-api&branch=nope", nil)
+	assert.Equal(t, http.StatusNotFound, code)
+
+	dial := func(query string) *websocket.Conn {
+		t.Helper()
+		wsURL := "ws" + strings.TrimPrefix(srv.URL, "http") + "/api/v1/wal/stream?" + query
+		ws, err := websocket.Dial(wsURL, "", srv.URL)
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = ws.Close() })
+		return ws
+	}
+	receive := func(ws *websocket.Conn) map[string]interface{} {
+		t.Helper()
+		require.NoError(t, ws.SetReadDeadline(time.Now().Add(5*time.Second)))
+		var msg map[string]interface{}
+		require.NoError(t, websocket.JSON.Receive(ws, &msg))
+		return msg
+	}
+
+	// Browsers on other sites may not open the stream.
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http") + "/api/v1/wal/stream?project=stream-api"
+	_, err = websocket.Dial(wsURL, "", "http://evil.example")
+	assert.Error(t, err)
+	listedRouter := NewRouterWith(services, Options{CORSOrigins: "http://console.example"})
+	t.Cleanup(listedRouter.Shutdown)
+	listed := httptest.NewServer(listedRouter)
+	t.Cleanup(listed.Close)
+	listedURL := "ws" + strings.TrimPrefix(listed.URL, "http") + "/api/v1/wal/stream?project=stream-api"
+	_, err = websocket.Dial(listedURL, "", "http://evil.example")
+	assert.Error(t, err)
+	allowed, err := websocket.Dial(listedURL, "", "http://console.example")
+	require.NoError(t, err)
+	_ = allowed.Close()
+
+	mainStream := dial("project=stream-api&branch=main&include_documents=true")
+	itemsStream := dial("project=stream-api&collection=items")
+
+	ctx := context.Background()
+	mainWriter, err := services.WriterFor("stream-api", "main")
+	require.NoError(t, err)
+	expWriter, err := services.WriterFor("stream-api", "exp")
+	require.NoError(t, err)
+	lsn1, err := mainWriter.Put(ctx, "items", bson.M{"_id": "i1", "n": 1})
+	require.NoError(t, err)
+	_, err = expWriter.Put(ctx, "items", bson.M{"_id": "x1"})
+	require.NoError(t, err)
+	_, err = mainWriter.Put(ctx, "users", bson.M{"_id": "u1"})
+	require.NoError(t, err)
+	lsn4, _, err := mainWriter.Delete(ctx, "items", "i1")
+	require.NoError(t, err)
+
+	// The branch stream sees main's entries only, in order, with bodies.
+	msg := receive(mainStream)
+	assert.EqualValues(t, lsn1, msg["lsn"])
+	assert.Equal(t, "put", msg["operation"])
+	assert.Equal(t, map[string]interface{}{"_id": "i1", "n": float64(1)}, msg["document"])
+	msg = receive(mainStream)
+	assert.Equal(t, "users", msg["collection"])
+	msg = receive(mainStream)
+	assert.EqualValues(t, lsn4, msg["lsn"])
+	assert.Equal(t, "delete", msg["operation"])
+	assert.Equal(t, "i1", msg["previous"].(map[string]interface{})["_id"])
+
+	// The collection stream sees items on every branch, without bodies.
+	var docIDs []string
+	for i := 0; i < 3; i++ {
+		msg := receive(itemsStream)
+		assert.Equal(t, "items", msg["collection"])
+		assert.NotContains(t, msg, "document")
+		docIDs = append(docIDs, msg["document_id"].(string))
+	}
+	assert.Equal(t, []string{"i1", "x1", "i1"}, docIDs)
+}
+
+func TestAPI_ImportProgress(t *testing.T) {
+	dbName := fmt.Sprintf("argon_api_import_test_%d", time.Now().UnixNano())
+	sourceName := dbName + "_source"
+	services, err := walcli.NewServicesAt("mongodb://localhost:27017", dbName)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = services.Client.Database(dbName).Drop(context.Background())
+		_ = services.Client.Database(sourceName).Drop(context.Background())
+	})
+	router := NewRouter(services)
+	t.Cleanup(router.Shutdown)
+
+	_, err = services.Client.Database(sourceName).Collection("users").InsertMany(context.Background(),
+		[]interface{}{bson.M{"_id": "u1"}, bson.M{"_id": "u2"}, bson.M{"_id": "u3"}})
+	require.NoError(t, err)
+	imported, err := services.ImportDatabase(context.Background(), "mongodb://localhost:27017", sourceName,
+		"import-progress-api", false, 0, "", true, 1, nil, nil, nil)
+	require.NoError(t, err)
+	raw, err := json.Marshal(imported)
+	require.NoError(t, err)
+	var result struct {
+		JobID string `json:"job_id"`
+	}
+	require.NoError(t, json.Unmarshal(raw, &result))
+	require.NotEmpty(t, result.JobID)
+
+	// A finished job streams its final state once and ends the stream.
+	req := httptest.NewRequest("GET", "/api/v1/import/progress?job="+result.JobID, nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/event-stream", rec.Header().Get("Content-Type"))
+	body := rec.Body.String()
+	assert.Contains(t, body, "event:progress")
+	assert.Contains(t, body, `"status":"completed"`)
+	assert.Contains(t, body, `"documents":3`)
+
+	code, _ := do(t, router, "GET", "/api/v1/import/progress", nil)
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = do(t, router, "GET", "/api/v1/import/progress?job=missing", nil)
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func TestAPI_Aggregate(t *testing.T) {
+	dbName := fmt.Sprintf("argon_api_aggregate_test_%d", time.Now().UnixNano())
+	services, err := walcli.NewServicesAt("mongodb://localhost:27017", dbName)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = services.Client.Database(dbName).Drop(context.Background())
+	})
+	router := NewRouter(services)
+	t.Cleanup(router.Shutdown)
+
+	_, err = services.Projects.CreateProject("aggregate-api")
+	require.NoError(t, err)
+	writer, err := services.WriterFor("aggregate-api", "main")
+	require.NoError(t, err)
+	for i, order := range []struct {
+		status string
+		total  int
+	}{{"paid", 30}, {"open", 5}, {"paid", 12}, {"open", 7}, {"refunded", 20}} {
+		_, err := writer.Put(context.Background(), "orders", bson.M{"_id": fmt.Sprintf("o%d", i), "status": order.status, "total": order.total})
+		require.NoError(t, err)
+	}
+
+	path := "/api/v1/projects/aggregate-api/branches/main/aggregate"
+	code, resp := do(t, router, "POST", path, map[string]interface{}{
+		"collection": "orders",
+		"pipeline": []interface{}{
+			map[string]interface{}{"$match": map[string]interface{}{"status": map[string]interface{}{"$ne": "refunded"}}},
+			map[string]interface{}{"$group": map[string]interface{}{
+				"_id": "$status", "revenue": map[string]interface{}{"$sum": "$total"}, "orders": map[string]interface{}{"$count": map[string]interface{}{}},
+			}},
+			map[string]interface{}{"$sort": map[string]interface{}{"revenue": -1}},
+		},
+	})
+	require.Equal(t, http.StatusOK, code, "%v", resp)
+	assert.Equal(t, "orders", resp["collection"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"_id": "paid", "revenue": float64(42), "orders": float64(2)},
+		map[string]interface{}{"_id": "open", "revenue": float64(12), "orders": float64(2)},
+	}, resp["results"])
+
+	// Unsupported stages are named; malformed bodies are refused.
+	code, resp = do(t, router, "POST", path, map[string]interface{}{
+		"collection": "orders",
+		"pipeline":   []interface{}{map[string]interface{}{"$lookup": map[string]interface{}{"from": "users"}}},
+	})
+	require.Equal(t, http.StatusBadRequest, code)
+	assert.Contains(t, resp["error"], `unsupported aggregation stage "$lookup"`)
+	code, _ = do(t, router, "POST", path, map[string]interface{}{"collection": "orders", "pipeline": "nope"})
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = do(t, router, "POST", path, map[string]interface{}{"pipeline": []interface{}{}})
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestAPI_DocumentHistory(t *testing.T) {
+	dbName := fmt.Sprintf("argon_api_history_test_%d", time.Now().UnixNano())
+	services, err := walcli.NewServicesAt("mongodb://localhost:27017", dbName)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = services.Client.Database(dbName).Drop(context.Background())
+	})
+	router := NewRouter(services)
+	t.Cleanup(router.Shutdown)
+
+	_, err = services.Projects.CreateProject("history-api")
+	require.NoError(t, err)
+	writer, err := services.WriterFor("history-api", "main")
+	require.NoError(t, err)
+	ctx := context.Background()
+	id := primitive.NewObjectID()
+	_, err = writer.Put(ctx, "users", bson.M{"_id": id, "name": "ada"})
+	require.NoError(t, err)
+	_, _, err = writer.Delete(ctx, "users", id)
+	require.NoError(t, err)
+	_, err = writer.Put(ctx, "users", bson.M{"_id": id, "name": "ada again"})
+	require.NoError(t, err)
+
+	path := "/api/v1/projects/history-api/branches/main/documents/" + id.Hex() + "/history?collection=users"
+	code, resp := do(t, router, "GET", path, nil)
+	require.Equal(t, http.StatusOK, code, "%v", resp)
+	versions := resp["versions"].([]interface{})
+	require.Len(t, versions, 3)
+	var ops []interface{}
+	var lifecycles []interface{}
+	for _, v := range versions {
+		ops = append(ops, v.(map[string]interface{})["operation"])
+		lifecycles = append(lifecycles, v.(map[string]interface{})["lifecycle"])
+	}
+	assert.Equal(t, []interface{}{"put", "delete", "put"}, ops)
+	assert.Equal(t, []interface{}{float64(1), float64(1), float64(2)}, lifecycles)
+	assert.Nil(t, versions[1].(map[string]interface{})["state"])
+	assert.Equal(t, "ada again", versions[2].(map[string]interface{})["state"].(map[string]interface{})["name"])
+
+	code, resp = do(t, router, "GET", path+"&format=extjson", nil)
+	require.Equal(t, http.StatusOK, code)
+	first := resp["versions"].([]interface{})[0].(map[string]interface{})["state"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"$oid": id.Hex()}, first["_id"])
+
+	code, resp = do(t, router, "GET", "/api/v1/projects/history-api/branches/main/documents/"+id.Hex()+"/history", nil)
+	require.Equal(t, http.StatusBadRequest, code)
+	assert.Contains(t, resp["error"], "collection")
+}
+
+func TestAPI_RenameBranch(t *testing.T) {
+	dbName := fmt.Sprintf("argon_api_rename_test_%d", time.Now().UnixNano())
+	services, err := walcli.NewServicesAt("mongodb://localhost:27017", dbName)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = services.Client.Database(dbName).Drop(context.Background())
+	})
+	router := NewRouter(services)
+	t.Cleanup(router.Shutdown)
+
+	project, err := services.Projects.CreateProject("rename-api")
+	require.NoError(t, err)
+	for _, name := range []string{"feature", "taken"} {
+		code, _ := do(t, router, "POST", "/api/v1/projects/rename-api/branches", map[string]string{"name": name, "from": "main"})
+		require.Equal(t, http.StatusCreated, code)
+	}
+	feature, err := services.Branches.GetBranch(project.ID, "feature")
+	require.NoError(t, err)
+
+	code, resp := do(t, router, "PATCH", "/api/v1/projects/rename-api/branches/feature", map[string]string{"name": "renamed"})
+	require.Equal(t, http.StatusOK, code, "%v", resp)
+	assert.Equal(t, feature.ID, resp["id"])
+	assert.Equal(t, "renamed", resp["name"])
+	code, _ = do(t, router, "GET", "/api/v1/projects/rename-api/branches/renamed", nil)
+	assert.Equal(t, http.StatusOK, code)
+	code, _ = do(t, router, "GET", "/api/v1/projects/rename-api/branches/feature", nil)
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, resp = do(t, router, "PATCH", "/api/v1/projects/rename-api/branches/renamed", map[string]string{"name": "taken"})
+	assert.Equal(t, http.StatusConflict, code)
+	assert.Equal(t, `branch "taken" already exists`, resp["error"])
+	code, _ = do(t, router, "PATCH", "/api/v1/projects/rename-api/branches/main", map[string]string{"name": "trunk"})
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = do(t, router, "PATCH", "/api/v1/projects/rename-api/branches/renamed", map[string]string{"name": "main"})
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = do(t, router, "PATCH", "/api/v1/projects/rename-api/branches/renamed", map[string]string{})
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = do(t, router, "PATCH", "/api/v1/projects/rename-api/branches/missing", map[string]string{"name": "other"})
+	assert.Equal(t, http.StatusNotFound, code)
+}
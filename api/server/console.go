@@ -6,8 +6,13 @@
 package server
 
 import (
+	"context"
 	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"sort"
@@ -15,6 +20,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/argon-lab/argon/pkg/walcli"
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -30,11 +36,17 @@ var Version = "dev"
 // local control plane: any origin, no token, writes allowed.
 type Options struct {
 	// CORSOrigins is a comma-separated allowlist of browser origins;
-	// empty or "*" allows any origin.
+	// empty or "*" allows any origin, except that the WAL stream accepts
+	// only its own origin unless the list is "*".
 	CORSOrigins string
 	// Token, when set, requires "Authorization: Bearer <token>" on every
 	// endpoint except /health and /api/v1/meta.
 	Token string
+	// RequireAPIKey requires a project-scoped API key (see `argon apikey
+	// create`) as the bearer token on the same endpoints. A key reaches
+	// only its own project; Token, if also set, remains an unscoped admin
+	// credential.
+	RequireAPIKey bool
 	// ReadOnly rejects every non-GET request. The web console uses it to
 	// serve a look-but-don't-touch instance.
 	ReadOnly bool
@@ -48,11 +60,21 @@ type Options struct {
 	DemoTTL         time.Duration
 	DemoWriteLimit  int // writes per session per minute
 	DemoMaxProjects int // concurrent demo projects
+
+	// SlowQueryThreshold, when positive, overrides the duration above
+	// which a materialization or time-travel read is logged as slow.
+	SlowQueryThreshold time.Duration
+
+	// QueryTimeout, when positive, bounds time-travel and materialization
+	// reads: they are cancelled mid-replay and answered with 504 instead
+	// of holding the connection open. Write routes never inherit it.
+	QueryTimeout time.Duration
 }
 
 // OptionsFromEnv reads the server options from the environment:
-// ARGON_CORS_ORIGINS, ARGON_API_TOKEN, ARGON_READ_ONLY, ARGON_DEMO_MODE,
-// ARGON_DEMO_TTL_MINUTES.
+// ARGON_CORS_ORIGINS, ARGON_API_TOKEN, ARGON_REQUIRE_API_KEY,
+// ARGON_READ_ONLY, ARGON_DEMO_MODE, ARGON_DEMO_TTL_MINUTES,
+// ARGON_SLOW_QUERY_MS, ARGON_QUERY_TIMEOUT_MS.
 func OptionsFromEnv() Options {
 	envBool := func(name string) bool {
 		switch strings.ToLower(os.Getenv(name)) {
@@ -67,31 +89,74 @@ func OptionsFromEnv() Options {
 			ttl = time.Duration(n) * time.Minute
 		}
 	}
+	var slow time.Duration
+	if v := os.Getenv("ARGON_SLOW_QUERY_MS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			slow = time.Duration(n) * time.Millisecond
+		}
+	}
+	var queryTimeout time.Duration
+	if v := os.Getenv("ARGON_QUERY_TIMEOUT_MS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			queryTimeout = time.Duration(n) * time.Millisecond
+		}
+	}
 	return Options{
-		CORSOrigins: os.Getenv("ARGON_CORS_ORIGINS"),
-		Token:       os.Getenv("ARGON_API_TOKEN"),
-		ReadOnly:    envBool("ARGON_READ_ONLY"),
-		Version:     Version,
-		DemoMode:    envBool("ARGON_DEMO_MODE"),
-		DemoTTL:     ttl,
+		CORSOrigins:        os.Getenv("ARGON_CORS_ORIGINS"),
+		Token:              os.Getenv("ARGON_API_TOKEN"),
+		RequireAPIKey:      envBool("ARGON_REQUIRE_API_KEY"),
+		ReadOnly:           envBool("ARGON_READ_ONLY"),
+		Version:            Version,
+		DemoMode:           envBool("ARGON_DEMO_MODE"),
+		DemoTTL:            ttl,
+		SlowQueryThreshold: slow,
+		QueryTimeout:       queryTimeout,
 	}
 }
 
 // --- middleware ---
 
-func corsMiddleware(origins string) gin.HandlerFunc {
-	allowAll := origins == "" || origins == "*"
+// queryContext bounds a read handler's context by the configured query
+// timeout. Only reads take it: writes (checkout, merge, undo, snapshots)
+// cut off midway would leave partial state behind.
+func (r *Router) queryContext(c *gin.Context) (context.Context, context.CancelFunc) {
+	if r.opts.QueryTimeout <= 0 {
+		return context.WithCancel(c.Request.Context())
+	}
+	return context.WithTimeout(c.Request.Context(), r.opts.QueryTimeout)
+}
+
+// abortQueryErr reports a failed read: 504 when the query timeout on ctx
+// cut it short, otherwise the given status.
+func (r *Router) abortQueryErr(c *gin.Context, ctx context.Context, status int, err error) {
+	// Driver errors do not always wrap the context's; the context itself
+	// is the authority on whether the deadline passed.
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		abortErr(c, http.StatusGatewayTimeout, fmt.Errorf("query exceeded the %s timeout; narrow it (a collection, an LSN nearer a snapshot) or raise ARGON_QUERY_TIMEOUT_MS", r.opts.QueryTimeout))
+		return
+	}
+	abortErr(c, status, err)
+}
+
+// originAllowlist parses Options.CORSOrigins into its listed origins.
+func originAllowlist(origins string) map[string]bool {
 	allowed := make(map[string]bool)
 	for _, o := range strings.Split(origins, ",") {
 		if o = strings.TrimSpace(o); o != "" {
 			allowed[o] = true
 		}
 	}
+	return allowed
+}
+
+func corsMiddleware(origins string) gin.HandlerFunc {
+	allowAll := origins == "" || origins == "*"
+	allowed := originAllowlist(origins)
 	return func(c *gin.Context) {
 		if origin := c.GetHeader("Origin"); origin != "" && (allowAll || allowed[origin]) {
 			c.Header("Access-Control-Allow-Origin", origin)
 			c.Header("Vary", "Origin")
-			c.Header("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+			c.Header("Access-Control-Allow-Methods", "GET, POST, PATCH, DELETE, OPTIONS")
 			c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
 			c.Header("Access-Control-Max-Age", "600")
 		}
@@ -122,6 +187,98 @@ func authMiddleware(token string) gin.HandlerFunc {
 	}
 }
 
+// apiKeyProject is the gin context key holding the project ID an API key
+// is scoped to; it is absent for the unscoped admin token.
+const apiKeyProject = "argon.apikey.project"
+
+// apiKeyMiddleware authenticates API keys and confines each to its
+// project. A route addresses a project by its :project parameter, its
+// ?project= query, or (merge plans) the plan's own project; routes that
+// address none are refused to scoped keys, except the project list, which
+// listProjects filters.
+func (r *Router) apiKeyMiddleware() gin.HandlerFunc {
+	admin := []byte("Bearer " + r.opts.Token)
+	return func(c *gin.Context) {
+		p := c.Request.URL.Path
+		if !strings.HasPrefix(p, "/api/") || p == "/api/v1/meta" {
+			c.Next()
+			return
+		}
+		header := c.GetHeader("Authorization")
+		if r.opts.Token != "" && subtle.ConstantTimeCompare([]byte(header), admin) == 1 {
+			c.Next()
+			return
+		}
+		token, found := strings.CutPrefix(header, "Bearer ")
+		if !found || token == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing API key"})
+			return
+		}
+		key, err := r.services.APIKeys.Authenticate(c.Request.Context(), token)
+		if err != nil {
+			if walcli.IsInvalidAPIKey(err) {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid API key"})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Set(apiKeyProject, key.ProjectID)
+
+		// Unknown routes fall through to their 404.
+		if c.FullPath() == "" {
+			c.Next()
+			return
+		}
+		projectID, err := r.addressedProject(c)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if projectID == "" && !(c.Request.Method == http.MethodGet && c.FullPath() == "/api/v1/projects") {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "this API key is scoped to a single project"})
+			return
+		}
+		if projectID != "" && projectID != key.ProjectID {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "this API key does not grant access to that project"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// addressedProject returns the ID of the project a request addresses, ""
+// when it addresses none. A name that does not resolve yields a
+// placeholder, so a scoped key learns nothing about other projects.
+func (r *Router) addressedProject(c *gin.Context) (string, error) {
+	name := c.Param("project")
+	if name == "" {
+		name = c.Query("project")
+	}
+	if name != "" {
+		project, err := r.services.Projects.GetProjectByName(name)
+		if err != nil {
+			if walcli.IsNotFound(err) {
+				return "?", nil
+			}
+			return "", err
+		}
+		return project.ID, nil
+	}
+	if strings.HasPrefix(c.FullPath(), "/api/v1/merge-plans/:id") {
+		planID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			return "?", nil
+		}
+		plan, err := r.services.Merge.GetPlan(c.Request.Context(), planID)
+		if err != nil {
+			return "?", nil
+		}
+		return plan.ProjectID, nil
+	}
+	return "", nil
+}
+
 func readOnlyMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		switch c.Request.Method {
@@ -147,6 +304,30 @@ func intQuery(c *gin.Context, name string, def int64) (int64, error) {
 	return n, nil
 }
 
+// pageToken is the cursor behind an opaque page token: the last LSN a
+// page returned and the direction it was read in. Paging by LSN rather
+// than by offset stays stable while new entries append.
+type pageToken struct {
+	LSN   int64
+	Order int
+}
+
+func (t pageToken) encode() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("v1:%d:%d", t.Order, t.LSN)))
+}
+
+func decodePageToken(token string) (pageToken, error) {
+	var t pageToken
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return t, fmt.Errorf("invalid page_token")
+	}
+	if _, err := fmt.Sscanf(string(raw), "v1:%d:%d", &t.Order, &t.LSN); err != nil || (t.Order != 1 && t.Order != -1) {
+		return pageToken{}, fmt.Errorf("invalid page_token")
+	}
+	return t, nil
+}
+
 // --- meta / status ---
 
 func (r *Router) meta(c *gin.Context) {
@@ -172,6 +353,82 @@ func (r *Router) ingesterStatus(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"ingesters": ids, "count": len(ids)})
 }
 
+func (r *Router) slowQueries(c *gin.Context) {
+	slow := r.services.Materializer.SlowQueries()
+	c.JSON(http.StatusOK, gin.H{
+		"threshold_ms": slow.Threshold().Milliseconds(),
+		"total":        slow.Total(),
+		"queries":      slow.Entries(),
+	})
+}
+
+// alertHistory lists monitor alerts, newest first, from ?since= (RFC3339;
+// default: all) and optionally of one ?level=.
+func (r *Router) alertHistory(c *gin.Context) {
+	var since time.Time
+	if v := c.Query("since"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			abortErr(c, http.StatusBadRequest, fmt.Errorf("invalid since (want RFC3339): %w", err))
+			return
+		}
+		since = t
+	}
+	alerts, err := r.services.AlertHistory(since, c.Query("level"))
+	if err != nil {
+		abortErr(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"alerts": alerts})
+}
+
+// importProgressInterval is how often importProgress polls a running job.
+var importProgressInterval = time.Second
+
+// importProgress streams an import job's progress as server-sent events.
+// Imports run in the CLI, not here; the job store is what the two share,
+// so the stream polls it: a "progress" event carries the job (status,
+// progress, checkpoint) whenever it changed, until the job stops running
+// or the client goes away.
+func (r *Router) importProgress(c *gin.Context) {
+	jobID := c.Query("job")
+	if jobID == "" {
+		abortErr(c, http.StatusBadRequest, fmt.Errorf("job is required"))
+		return
+	}
+	job, err := r.services.Importer.GetJob(jobID)
+	if err != nil {
+		abortLookup(c, err, "import job not found")
+		return
+	}
+
+	ctx := c.Request.Context()
+	ticker := time.NewTicker(importProgressInterval)
+	defer ticker.Stop()
+	var sent time.Time
+	c.Stream(func(w io.Writer) bool {
+		if !job.UpdatedAt.Equal(sent) {
+			c.SSEvent("progress", job)
+			sent = job.UpdatedAt
+		}
+		if !job.Running() {
+			return false
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+		next, err := r.services.Importer.GetJob(jobID)
+		if err != nil {
+			c.SSEvent("error", gin.H{"error": err.Error()})
+			return false
+		}
+		job = next
+		return true
+	})
+}
+
 // --- history ---
 
 func (r *Router) listEntries(c *gin.Context) {
@@ -219,6 +476,25 @@ func (r *Router) listEntries(c *gin.Context) {
 	if c.Query("order") == "asc" {
 		order = 1
 	}
+	// A page token resumes after the last entry of the previous page, in
+	// the direction that page was read.
+	if raw := c.Query("page_token"); raw != "" {
+		token, err := decodePageToken(raw)
+		if err != nil {
+			abortErr(c, http.StatusBadRequest, err)
+			return
+		}
+		if token.Order != order {
+			abortErr(c, http.StatusBadRequest, fmt.Errorf("page_token was issued for the other order"))
+			return
+		}
+		if order == 1 {
+			lsnRange["$gt"] = token.LSN
+		} else {
+			lsnRange["$lt"] = token.LSN
+		}
+		filter["lsn"] = lsnRange
+	}
 
 	// One extra row answers "is there another page" without a count.
 	opts := options.Find().
@@ -229,12 +505,68 @@ func (r *Router) listEntries(c *gin.Context) {
 		abortErr(c, http.StatusInternalServerError, err)
 		return
 	}
+	resp := gin.H{"entries": entries, "has_more": false}
+	if int64(len(entries)) > limit {
+		entries = entries[:limit]
+		resp["entries"] = entries
+		resp["has_more"] = true
+		resp["next_token"] = pageToken{LSN: entries[len(entries)-1].LSN, Order: order}.encode()
+	}
+	// Document bodies are opt-in: they dwarf the entry metadata.
+	if c.Query("include_documents") == "true" {
+		withDocs, err := walcli.WithDocuments(entries)
+		if err != nil {
+			abortErr(c, http.StatusInternalServerError, err)
+			return
+		}
+		resp["entries"] = withDocs
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// listActorEntries is the project-wide audit view: every entry one actor
+// wrote, on any branch, oldest first.
+func (r *Router) listActorEntries(c *gin.Context) {
+	projectID, _, ok := r.resolve(c)
+	if !ok {
+		return
+	}
+	actor := c.Query("actor")
+	if actor == "" {
+		abortErr(c, http.StatusBadRequest, fmt.Errorf("actor query parameter is required"))
+		return
+	}
+	fromLSN, err := intQuery(c, "from_lsn", 0)
+	if err != nil {
+		abortErr(c, http.StatusBadRequest, err)
+		return
+	}
+	toLSN, err := intQuery(c, "to_lsn", 0)
+	if err != nil {
+		abortErr(c, http.StatusBadRequest, err)
+		return
+	}
+	limit, err := intQuery(c, "limit", 50)
+	if err != nil {
+		abortErr(c, http.StatusBadRequest, err)
+		return
+	}
+	if limit < 1 || limit > 500 {
+		limit = 500
+	}
+	// One extra row answers "is there another page"; the next page starts
+	// at the last returned LSN + 1.
+	entries, err := r.services.WAL.GetEntriesByUser(projectID, actor, fromLSN, toLSN, limit+1)
+	if err != nil {
+		abortErr(c, http.StatusInternalServerError, err)
+		return
+	}
 	hasMore := false
 	if int64(len(entries)) > limit {
 		hasMore = true
 		entries = entries[:limit]
 	}
-	c.JSON(http.StatusOK, gin.H{"entries": entries, "has_more": hasMore})
+	c.JSON(http.StatusOK, gin.H{"actor": actor, "entries": entries, "count": len(entries), "has_more": hasMore})
 }
 
 // --- time travel reads ---
@@ -246,7 +578,7 @@ func (r *Router) timeTravelQuery(c *gin.Context) {
 	}
 	branch, err := r.services.Branches.GetBranchByID(branchID)
 	if err != nil {
-		abortErr(c, http.StatusNotFound, err)
+		abortLookup(c, err, err.Error())
 		return
 	}
 	lsn, err := intQuery(c, "lsn", branch.HeadLSN)
@@ -254,13 +586,45 @@ func (r *Router) timeTravelQuery(c *gin.Context) {
 		abortErr(c, http.StatusBadRequest, err)
 		return
 	}
+	if tag := c.Query("tag"); tag != "" {
+		if c.Query("lsn") != "" {
+			abortErr(c, http.StatusBadRequest, fmt.Errorf("lsn and tag are mutually exclusive"))
+			return
+		}
+		if lsn, err = r.services.TagLSN(branch, tag); err != nil {
+			abortErr(c, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	ctx, cancel := r.queryContext(c)
+	defer cancel()
 
 	collection := c.Query("collection")
+	projection, err := walcli.FieldProjection(c.Query("fields"))
+	if err != nil {
+		abortErr(c, http.StatusBadRequest, err)
+		return
+	}
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "extjson" {
+		abortErr(c, http.StatusBadRequest, fmt.Errorf("invalid format %q (want json or extjson)", format))
+		return
+	}
+	filter, err := walcli.ParseFilter(c.Query("filter"))
+	if err != nil {
+		abortErr(c, http.StatusBadRequest, err)
+		return
+	}
 	if collection == "" {
+		if len(filter) > 0 {
+			abortErr(c, http.StatusBadRequest, fmt.Errorf("filter requires a collection"))
+			return
+		}
 		// No collection: a summary of the branch at that LSN.
-		state, err := r.services.TimeTravel.GetBranchStateAtLSN(branch, lsn)
+		state, err := r.services.ReadTimeTravel.GetBranchStateAtLSNContext(ctx, branch, lsn)
 		if err != nil {
-			abortErr(c, http.StatusBadRequest, err)
+			r.abortQueryErr(c, ctx, http.StatusBadRequest, err)
 			return
 		}
 		counts := make(map[string]int, len(state))
@@ -271,9 +635,9 @@ func (r *Router) timeTravelQuery(c *gin.Context) {
 		return
 	}
 
-	docsByID, err := r.services.TimeTravel.MaterializeAtLSN(branch, collection, lsn)
+	docsByID, err := r.services.ReadTimeTravel.QueryAtLSNContext(ctx, branch, collection, filter, lsn)
 	if err != nil {
-		abortErr(c, http.StatusBadRequest, err)
+		r.abortQueryErr(c, ctx, http.StatusBadRequest, err)
 		return
 	}
 	skip, err := intQuery(c, "skip", 0)
@@ -290,33 +654,153 @@ func (r *Router) timeTravelQuery(c *gin.Context) {
 		limit = 500
 	}
 
-	ids := make([]string, 0, len(docsByID))
-	for id := range docsByID {
-		ids = append(ids, id)
-	}
-	sort.Strings(ids)
+	ordered := walcli.OrderDocuments(docsByID, c.Query("sort"))
 	if skip < 0 {
 		skip = 0
 	}
-	if skip > int64(len(ids)) {
-		skip = int64(len(ids))
+	if skip > int64(len(ordered)) {
+		skip = int64(len(ordered))
 	}
 	end := skip + limit
-	if end > int64(len(ids)) {
-		end = int64(len(ids))
+	if end > int64(len(ordered)) {
+		end = int64(len(ordered))
+	}
+	documents := ordered[skip:end]
+	if projection != nil {
+		// Projected after sorting and paging, so sort may name a field
+		// the projection leaves out.
+		projected := make([]bson.M, len(documents))
+		for i, doc := range documents {
+			projected[i] = projection.Apply(doc)
+		}
+		documents = projected
 	}
-	documents := make([]bson.M, 0, end-skip)
-	for _, id := range ids[skip:end] {
-		documents = append(documents, docsByID[id])
+	var encoded interface{} = documents
+	if format == "extjson" {
+		if encoded, err = extJSONDocuments(documents); err != nil {
+			abortErr(c, http.StatusInternalServerError, err)
+			return
+		}
 	}
 	c.JSON(http.StatusOK, gin.H{
 		"lsn":        lsn,
 		"collection": collection,
 		"total":      len(docsByID),
-		"documents":  documents,
+		"documents":  encoded,
 	})
 }
 
+// documentHistory returns one document's timeline on a branch: its state
+// after each change, oldest first, with nil states for deletes.
+func (r *Router) documentHistory(c *gin.Context) {
+	_, branchID, ok := r.resolve(c)
+	if !ok {
+		return
+	}
+	collection := c.Query("collection")
+	if collection == "" {
+		abortErr(c, http.StatusBadRequest, fmt.Errorf("collection query parameter is required"))
+		return
+	}
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "extjson" {
+		abortErr(c, http.StatusBadRequest, fmt.Errorf("invalid format %q (want json or extjson)", format))
+		return
+	}
+	branch, err := r.services.Branches.GetBranchByID(branchID)
+	if err != nil {
+		abortLookup(c, err, err.Error())
+		return
+	}
+	timeline, err := r.services.ReadTimeTravel.GetDocumentTimeline(branch, collection, c.Param("document"))
+	if err != nil {
+		abortErr(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	versions := make([]gin.H, len(timeline))
+	for i, v := range timeline {
+		var state interface{} = v.State
+		if format == "extjson" && v.State != nil {
+			encoded, err := extJSONDocuments([]bson.M{v.State})
+			if err != nil {
+				abortErr(c, http.StatusInternalServerError, err)
+				return
+			}
+			state = encoded[0]
+		}
+		versions[i] = gin.H{
+			"lsn":       v.LSN,
+			"timestamp": v.Timestamp,
+			"operation": v.Operation,
+			"state":     state,
+			"lifecycle": v.Lifecycle,
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"collection": collection,
+		"document":   c.Param("document"),
+		"versions":   versions,
+	})
+}
+
+// aggregate runs a minimal pipeline ($match, $group with $sum/$avg/$count,
+// $sort) over a collection at the branch head.
+func (r *Router) aggregate(c *gin.Context) {
+	_, branchID, ok := r.resolve(c)
+	if !ok {
+		return
+	}
+	var body struct {
+		Collection string          `json:"collection" binding:"required"`
+		Pipeline   json.RawMessage `json:"pipeline" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		abortErr(c, http.StatusBadRequest, err)
+		return
+	}
+	pipeline, err := walcli.ParsePipeline(string(body.Pipeline))
+	if err != nil {
+		abortErr(c, http.StatusBadRequest, err)
+		return
+	}
+	branch, err := r.services.Branches.GetBranchByID(branchID)
+	if err != nil {
+		abortLookup(c, err, err.Error())
+		return
+	}
+
+	ctx, cancel := r.queryContext(c)
+	defer cancel()
+	results, err := r.services.ReadMaterializer.AggregateContext(ctx, branch, body.Collection, pipeline)
+	if err != nil {
+		// ParsePipeline already refused a pipeline that does not compile.
+		r.abortQueryErr(c, ctx, http.StatusInternalServerError, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"lsn":        branch.HeadLSN,
+		"collection": body.Collection,
+		"results":    results,
+	})
+}
+
+// extJSONDocuments encodes documents as canonical MongoDB extended JSON,
+// which keeps ObjectIDs, dates, Decimal128 and 64-bit integers apart from
+// the strings and floats plain JSON flattens them into. The response
+// envelope stays plain JSON; only the documents change form.
+func extJSONDocuments(docs []bson.M) ([]json.RawMessage, error) {
+	encoded := make([]json.RawMessage, len(docs))
+	for i, doc := range docs {
+		raw, err := bson.MarshalExtJSON(doc, true, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode document as extended JSON: %w", err)
+		}
+		encoded[i] = raw
+	}
+	return encoded, nil
+}
+
 // --- merge plans (read side) ---
 
 func (r *Router) listMergePlans(c *gin.Context) {
@@ -327,7 +811,7 @@ func (r *Router) listMergePlans(c *gin.Context) {
 	}
 	project, err := r.services.Projects.GetProjectByName(name)
 	if err != nil {
-		abortErr(c, http.StatusNotFound, fmt.Errorf("project %q not found", name))
+		abortLookup(c, err, fmt.Sprintf("project %q not found", name))
 		return
 	}
 	plans, err := r.services.Merge.ListPlans(c.Request.Context(), project.ID)
@@ -127,7 +127,7 @@ func (r *Router) demoGuard() gin.HandlerFunc {
 				abortErr(c, http.StatusInternalServerError, err)
 				return
 			}
-			c.AbortWithStatusJSON(http.StatusOK, gin.H{"projects": []interface{}{proj}})
+			c.AbortWithStatusJSON(http.StatusOK, gin.H{"projects": []interface{}{proj}, "total": 1})
 			return
 		case p == "/api/v1/projects" && c.Request.Method == http.MethodPost:
 			c.AbortWithStatusJSON(http.StatusForbidden,
@@ -307,7 +307,7 @@ func (r *Router) demoScenario(c *gin.Context) {
 	}
 	proj, err := r.services.Projects.GetProjectByName(project)
 	if err != nil {
-		abortErr(c, http.StatusNotFound, err)
+		abortLookup(c, err, err.Error())
 		return
 	}
 	branches, err := r.services.Branches.ListBranches(proj.ID)
@@ -10,6 +10,7 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -65,9 +66,17 @@ func NewRouterWith(services *walcli.Services, opts Options) *Router {
 		opts:     opts,
 		ingest:   make(map[string]context.CancelFunc),
 	}
+	// The slow-query log belongs to these services' materializer, so the
+	// override never reaches services built for another router.
+	if opts.SlowQueryThreshold > 0 {
+		services.Materializer.SlowQueries().SetThreshold(opts.SlowQueryThreshold)
+	}
 	r.Use(gin.Recovery())
 	r.Use(corsMiddleware(opts.CORSOrigins))
-	if opts.Token != "" {
+	switch {
+	case opts.RequireAPIKey:
+		r.Use(r.apiKeyMiddleware())
+	case opts.Token != "":
 		r.Use(authMiddleware(opts.Token))
 	}
 	if opts.ReadOnly {
@@ -86,6 +95,9 @@ func NewRouterWith(services *walcli.Services, opts Options) *Router {
 	{
 		v1.GET("/meta", r.meta)
 		v1.GET("/status/ingesters", r.ingesterStatus)
+		v1.GET("/status/slow-queries", r.slowQueries)
+		v1.GET("/status/alerts", r.alertHistory)
+		v1.GET("/import/progress", r.importProgress)
 
 		if opts.DemoMode {
 			v1.POST("/demo/session", r.demoSession)
@@ -96,8 +108,12 @@ func NewRouterWith(services *walcli.Services, opts Options) *Router {
 		v1.POST("/projects", r.createProject)
 
 		v1.GET("/projects/:project/branches", r.listBranches)
+		v1.GET("/projects/:project/branch-tree", r.branchTree)
+		v1.GET("/projects/:project/entries", r.listActorEntries)
 		v1.POST("/projects/:project/branches", r.createBranch)
 		v1.GET("/projects/:project/branches/:branch", r.getBranch)
+		v1.GET("/projects/:project/branches/:branch/creation", r.branchCreation)
+		v1.PATCH("/projects/:project/branches/:branch", r.updateBranch)
 		v1.DELETE("/projects/:project/branches/:branch", r.deleteBranch)
 
 		v1.POST("/projects/:project/branches/:branch/checkout", r.checkoutBranch)
@@ -123,8 +139,14 @@ func NewRouterWith(services *walcli.Services, opts Options) *Router {
 
 		v1.POST("/projects/:project/branches/:branch/undo", r.undoRange)
 		v1.GET("/projects/:project/branches/:branch/entries", r.listEntries)
+		v1.GET("/wal/stream", r.streamEntries)
 		v1.GET("/projects/:project/branches/:branch/time-travel", r.timeTravelInfo)
 		v1.GET("/projects/:project/branches/:branch/time-travel/query", r.timeTravelQuery)
+		v1.POST("/projects/:project/branches/:branch/aggregate", r.aggregate)
+		v1.GET("/projects/:project/branches/:branch/documents/:document/history", r.documentHistory)
+		v1.POST("/projects/:project/branches/:branch/time-travel/validate", r.validateRestoreTarget)
+		v1.POST("/projects/:project/branches/:branch/restore", r.restoreBranch)
+		v1.POST("/projects/:project/branches/:branch/branch-at", r.branchAt)
 		v1.POST("/projects/:project/branches/:branch/snapshots", r.createSnapshot)
 	}
 	r.mountUI()
@@ -203,10 +225,21 @@ func abortErr(c *gin.Context, status int, err error) {
 	c.JSON(status, gin.H{"error": err.Error()})
 }
 
+// abortLookup answers a failed project or branch lookup: 404 when the
+// thing does not exist (with notFound as the message), 500 when the lookup
+// itself failed — a database outage is not a missing project.
+func abortLookup(c *gin.Context, err error, notFound string) {
+	if walcli.IsNotFound(err) {
+		abortErr(c, http.StatusNotFound, errors.New(notFound))
+		return
+	}
+	abortErr(c, http.StatusInternalServerError, err)
+}
+
 func (r *Router) resolve(c *gin.Context) (projectID, branchID string, ok bool) {
 	project, err := r.services.Projects.GetProjectByName(c.Param("project"))
 	if err != nil {
-		abortErr(c, http.StatusNotFound, fmt.Errorf("project %q not found", c.Param("project")))
+		abortLookup(c, err, fmt.Sprintf("project %q not found", c.Param("project")))
 		return "", "", false
 	}
 	branchName := c.Param("branch")
@@ -215,7 +248,7 @@ func (r *Router) resolve(c *gin.Context) (projectID, branchID string, ok bool) {
 	}
 	branch, err := r.services.Branches.GetBranch(project.ID, branchName)
 	if err != nil {
-		abortErr(c, http.StatusNotFound, fmt.Errorf("branch %q not found", branchName))
+		abortLookup(c, err, fmt.Sprintf("branch %q not found", branchName))
 		return "", "", false
 	}
 	return project.ID, branch.ID, true
@@ -224,12 +257,30 @@ func (r *Router) resolve(c *gin.Context) (projectID, branchID string, ok bool) {
 // --- projects ---
 
 func (r *Router) listProjects(c *gin.Context) {
-	projects, err := r.services.Projects.ListProjects()
+	limit, offset, ok := pageParams(c)
+	if !ok {
+		return
+	}
+	// A project-scoped API key sees only its own project.
+	if scope, ok := c.Get(apiKeyProject); ok {
+		project, err := r.services.Projects.GetProject(scope.(string))
+		if err != nil {
+			abortLookup(c, err, "project not found")
+			return
+		}
+		page := []interface{}{}
+		if offset == 0 {
+			page = append(page, project)
+		}
+		c.JSON(http.StatusOK, pageResponse("projects", page, len(page), offset, 1))
+		return
+	}
+	projects, total, err := r.services.Projects.ListProjectsPaged(limit, offset)
 	if err != nil {
 		abortErr(c, http.StatusInternalServerError, err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"projects": projects})
+	c.JSON(http.StatusOK, pageResponse("projects", projects, len(projects), offset, total))
 }
 
 func (r *Router) createProject(c *gin.Context) {
@@ -255,12 +306,65 @@ func (r *Router) listBranches(c *gin.Context) {
 	if !ok {
 		return
 	}
-	branches, err := r.services.Branches.ListBranches(projectID)
+	limit, offset, ok := pageParams(c)
+	if !ok {
+		return
+	}
+	branches, total, err := r.services.Branches.ListBranchesPaged(projectID, limit, offset)
+	if err != nil {
+		abortErr(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.JSON(http.StatusOK, pageResponse("branches", branches, len(branches), offset, total))
+}
+
+// pageParams reads ?limit (default 50, capped at 500; it must be positive)
+// and ?offset for a listing.
+func pageParams(c *gin.Context) (limit, offset int64, ok bool) {
+	limit, err := intQuery(c, "limit", 50)
+	if err != nil {
+		abortErr(c, http.StatusBadRequest, err)
+		return 0, 0, false
+	}
+	if limit < 1 {
+		abortErr(c, http.StatusBadRequest, fmt.Errorf("limit must be positive, got %d", limit))
+		return 0, 0, false
+	}
+	if limit > 500 {
+		limit = 500
+	}
+	offset, err = intQuery(c, "offset", 0)
+	if err != nil {
+		abortErr(c, http.StatusBadRequest, err)
+		return 0, 0, false
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	return limit, offset, true
+}
+
+// pageResponse wraps one page of a listing with the total and, while more
+// remain, the offset of the next page.
+func pageResponse(key string, items interface{}, count int, offset, total int64) gin.H {
+	resp := gin.H{key: items, "total": total}
+	if next := offset + int64(count); next < total {
+		resp["next_offset"] = next
+	}
+	return resp
+}
+
+func (r *Router) branchTree(c *gin.Context) {
+	projectID, _, ok := r.resolve(c)
+	if !ok {
+		return
+	}
+	tree, err := r.services.Branches.GetBranchTree(projectID)
 	if err != nil {
 		abortErr(c, http.StatusInternalServerError, err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"branches": branches})
+	c.JSON(http.StatusOK, gin.H{"branches": tree})
 }
 
 func (r *Router) createBranch(c *gin.Context) {
@@ -280,7 +384,7 @@ func (r *Router) createBranch(c *gin.Context) {
 	if body.From != "" {
 		parent, err := r.services.Branches.GetBranch(projectID, body.From)
 		if err != nil {
-			abortErr(c, http.StatusNotFound, fmt.Errorf("parent branch %q not found", body.From))
+			abortLookup(c, err, fmt.Sprintf("parent branch %q not found", body.From))
 			return
 		}
 		parentID = parent.ID
@@ -300,7 +404,7 @@ func (r *Router) getBranch(c *gin.Context) {
 	}
 	branch, err := r.services.Branches.GetBranchByID(branchID)
 	if err != nil {
-		abortErr(c, http.StatusNotFound, err)
+		abortLookup(c, err, err.Error())
 		return
 	}
 	resp := gin.H{"branch": branch}
@@ -310,6 +414,49 @@ func (r *Router) getBranch(c *gin.Context) {
 	c.JSON(http.StatusOK, resp)
 }
 
+// branchCreation reports where a branch was forked from and the WAL entry
+// that recorded its creation, for audit.
+func (r *Router) branchCreation(c *gin.Context) {
+	_, branchID, ok := r.resolve(c)
+	if !ok {
+		return
+	}
+	creation, err := r.services.Branches.GetBranchCreationEntry(branchID)
+	if err != nil {
+		abortLookup(c, err, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, creation)
+}
+
+// updateBranch renames a branch: {"name": "<new name>"}.
+func (r *Router) updateBranch(c *gin.Context) {
+	projectID, _, ok := r.resolve(c)
+	if !ok {
+		return
+	}
+	var body struct {
+		Name string `json:"name" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		abortErr(c, http.StatusBadRequest, err)
+		return
+	}
+	branch, err := r.services.Branches.RenameBranch(projectID, c.Param("branch"), body.Name)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, branch)
+	case walcli.IsBranchExists(err):
+		abortErr(c, http.StatusConflict, fmt.Errorf("branch %q already exists", body.Name))
+	case walcli.IsMainBranchRename(err):
+		abortErr(c, http.StatusBadRequest, err)
+	case walcli.IsNotFound(err):
+		abortLookup(c, err, fmt.Sprintf("branch %q not found", c.Param("branch")))
+	default:
+		abortErr(c, http.StatusInternalServerError, err)
+	}
+}
+
 func (r *Router) deleteBranch(c *gin.Context) {
 	projectID, branchID, ok := r.resolve(c)
 	if !ok {
@@ -383,7 +530,7 @@ func (r *Router) createSandbox(c *gin.Context) {
 	}
 	parent, err := r.services.Branches.GetBranch(projectID, from)
 	if err != nil {
-		abortErr(c, http.StatusNotFound, fmt.Errorf("parent branch %q not found", from))
+		abortLookup(c, err, fmt.Sprintf("parent branch %q not found", from))
 		return
 	}
 	ttl := time.Duration(body.TTLMinutes) * time.Minute
@@ -438,7 +585,7 @@ func (r *Router) createPin(c *gin.Context) {
 	}
 	branch, err := r.services.Branches.GetBranch(projectID, branchName)
 	if err != nil {
-		abortErr(c, http.StatusNotFound, fmt.Errorf("branch %q not found", branchName))
+		abortLookup(c, err, fmt.Sprintf("branch %q not found", branchName))
 		return
 	}
 	pin, err := r.services.Pins.Create(projectID, branch.ID, body.Name, body.LSN, body.Note)
@@ -633,10 +780,10 @@ func (r *Router) timeTravelInfo(c *gin.Context) {
 	}
 	branch, err := r.services.Branches.GetBranchByID(branchID)
 	if err != nil {
-		abortErr(c, http.StatusNotFound, err)
+		abortLookup(c, err, err.Error())
 		return
 	}
-	info, err := r.services.TimeTravel.GetTimeTravelInfo(branch)
+	info, err := r.services.ReadTimeTravel.GetTimeTravelInfo(branch)
 	if err != nil {
 		abortErr(c, http.StatusInternalServerError, err)
 		return
@@ -644,14 +791,233 @@ func (r *Router) timeTravelInfo(c *gin.Context) {
 	c.JSON(http.StatusOK, info)
 }
 
-func (r *Router) createSnapshot(c *gin.Context) {
+// validateRestoreTarget answers whether a restore to an LSN or RFC3339 time
+// would be accepted, without computing the preview. An out-of-range target,
+// or a time with no history, is a normal answer (valid: false); a failure
+// while checking is a 500.
+func (r *Router) validateRestoreTarget(c *gin.Context) {
 	_, branchID, ok := r.resolve(c)
 	if !ok {
 		return
 	}
+	var body struct {
+		LSN  *int64 `json:"lsn"`
+		Time string `json:"time"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		abortErr(c, http.StatusBadRequest, err)
+		return
+	}
+	if (body.LSN == nil) == (body.Time == "") {
+		abortErr(c, http.StatusBadRequest, fmt.Errorf("exactly one of lsn or time is required"))
+		return
+	}
+	branch, err := r.services.Branches.GetBranchByID(branchID)
+	if err != nil {
+		abortLookup(c, err, err.Error())
+		return
+	}
+	resp := gin.H{"base_lsn": branch.BaseLSN, "head_lsn": branch.HeadLSN}
+
+	var target int64
+	if body.LSN != nil {
+		target = *body.LSN
+	} else {
+		at, err := time.Parse(time.RFC3339, body.Time)
+		if err != nil {
+			abortErr(c, http.StatusBadRequest, fmt.Errorf("invalid time (want RFC3339): %w", err))
+			return
+		}
+		if target, err = r.services.TimeTravel.FindLSNAtTime(branch, at); err != nil {
+			if !walcli.IsOutOfRange(err) {
+				abortErr(c, http.StatusInternalServerError, err)
+				return
+			}
+			// No history at that time: nothing to restore to.
+			resp["valid"] = false
+			resp["reason"] = err.Error()
+			c.JSON(http.StatusOK, resp)
+			return
+		}
+	}
+	resp["target_lsn"] = target
+
+	if err := r.services.Restore.ValidateRestore(branchID, target); err != nil {
+		if !walcli.IsOutOfRange(err) {
+			abortLookup(c, err, err.Error())
+			return
+		}
+		resp["valid"] = false
+		resp["reason"] = err.Error()
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	resp["valid"] = true
+	c.JSON(http.StatusOK, resp)
+}
+
+// restoreTarget resolves a request's lsn, RFC3339 time or tag name to an
+// LSN on the branch, answering itself unless exactly one is given (400),
+// when the time has no history (400) or the tag is unknown (404).
+func (r *Router) restoreTarget(c *gin.Context, branchID string, lsn *int64, at, tag string) (int64, bool) {
+	given := 0
+	for _, set := range []bool{lsn != nil, at != "", tag != ""} {
+		if set {
+			given++
+		}
+	}
+	if given != 1 {
+		abortErr(c, http.StatusBadRequest, fmt.Errorf("exactly one of lsn, time or tag is required"))
+		return 0, false
+	}
+	if lsn != nil {
+		return *lsn, true
+	}
 	branch, err := r.services.Branches.GetBranchByID(branchID)
 	if err != nil {
+		abortLookup(c, err, err.Error())
+		return 0, false
+	}
+	if tag != "" {
+		target, err := r.services.TagLSN(branch, tag)
+		if err != nil {
+			abortRestoreErr(c, err)
+			return 0, false
+		}
+		return target, true
+	}
+	t, err := time.Parse(time.RFC3339, at)
+	if err != nil {
+		abortErr(c, http.StatusBadRequest, fmt.Errorf("invalid time (want RFC3339): %w", err))
+		return 0, false
+	}
+	target, err := r.services.TimeTravel.FindLSNAtTime(branch, t)
+	if err != nil {
+		abortRestoreErr(c, err)
+		return 0, false
+	}
+	return target, true
+}
+
+// restoreBranch rewinds a branch head to an LSN, time or tag. A reset
+// hides every later operation from readers, so it runs only with
+// ?confirm=true; without it the preview is returned with a 400. An
+// optional backup branch keeps the discarded operations reachable. A
+// protected branch is reset only with "force": true.
+func (r *Router) restoreBranch(c *gin.Context) {
+	_, branchID, ok := r.resolve(c)
+	if !ok {
+		return
+	}
+	var body struct {
+		LSN    *int64 `json:"lsn"`
+		Time   string `json:"time"`
+		Tag    string `json:"tag"`
+		Backup string `json:"backup"`
+		Force  bool   `json:"force"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		abortErr(c, http.StatusBadRequest, err)
+		return
+	}
+	target, ok := r.restoreTarget(c, branchID, body.LSN, body.Time, body.Tag)
+	if !ok {
+		return
+	}
+	preview, err := r.services.Restore.GetRestorePreview(branchID, target)
+	if err != nil {
+		abortRestoreErr(c, err)
+		return
+	}
+	if c.Query("confirm") != "true" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "a reset discards operations from the branch; repeat with ?confirm=true",
+			"preview": preview,
+		})
+		return
+	}
+
+	resetWithBackup, resetHead := r.services.Restore.ResetBranchToLSNWithBackup, r.services.Restore.ResetBranchToLSN
+	if body.Force {
+		resetWithBackup, resetHead = r.services.Restore.ForceResetBranchToLSNWithBackup, r.services.Restore.ForceResetBranchToLSN
+	}
+	resp := gin.H{"preview": preview}
+	if body.Backup != "" {
+		reset, backup, err := resetWithBackup(branchID, target, body.Backup)
+		if err != nil {
+			abortRestoreErr(c, err)
+			return
+		}
+		resp["branch"], resp["backup"] = reset, backup
+	} else {
+		reset, err := resetHead(branchID, target)
+		if err != nil {
+			abortRestoreErr(c, err)
+			return
+		}
+		resp["branch"] = reset
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// branchAt forks a new branch from a historical LSN, time or tag of this
+// one. The preview describes what the new branch leaves out of the source.
+func (r *Router) branchAt(c *gin.Context) {
+	projectID, branchID, ok := r.resolve(c)
+	if !ok {
+		return
+	}
+	var body struct {
+		Name string `json:"name" binding:"required"`
+		LSN  *int64 `json:"lsn"`
+		Time string `json:"time"`
+		Tag  string `json:"tag"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		abortErr(c, http.StatusBadRequest, err)
+		return
+	}
+	target, ok := r.restoreTarget(c, branchID, body.LSN, body.Time, body.Tag)
+	if !ok {
+		return
+	}
+	preview, err := r.services.Restore.GetRestorePreview(branchID, target)
+	if err != nil {
+		abortRestoreErr(c, err)
+		return
+	}
+	created, err := r.services.Restore.CreateBranchAtLSN(projectID, branchID, body.Name, target)
+	if err != nil {
+		abortRestoreErr(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"branch": created, "preview": preview})
+}
+
+// abortRestoreErr answers a failed restore or fork: 400 for a target
+// outside the branch or an ambiguous tag, 404 for a missing branch or tag,
+// 409 for a taken name or a protected branch, and 500 for the rest.
+func abortRestoreErr(c *gin.Context, err error) {
+	switch {
+	case walcli.IsOutOfRange(err), errors.Is(err, walcli.ErrAmbiguousTag):
+		abortErr(c, http.StatusBadRequest, err)
+	case walcli.IsNotFound(err), walcli.IsTagNotFound(err):
 		abortErr(c, http.StatusNotFound, err)
+	case walcli.IsBranchExists(err), walcli.IsBranchProtected(err):
+		abortErr(c, http.StatusConflict, err)
+	default:
+		abortErr(c, http.StatusInternalServerError, err)
+	}
+}
+
+func (r *Router) createSnapshot(c *gin.Context) {
+	_, branchID, ok := r.resolve(c)
+	if !ok {
+		return
+	}
+	branch, err := r.services.Branches.GetBranchByID(branchID)
+	if err != nil {
+		abortLookup(c, err, err.Error())
 		return
 	}
 	snaps, err := r.services.Snapshots.CreateSnapshot(c.Request.Context(), branchID, branch.HeadLSN)
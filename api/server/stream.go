@@ -0,0 +1,105 @@
+// The live entry stream: a WebSocket that pushes WAL entries as they are
+// appended, for consoles and agents that would otherwise poll the
+// timeline.
+
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/argon-lab/argon/pkg/walcli"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+)
+
+// streamEntries upgrades to a WebSocket and sends every entry appended to
+// ?project= (narrowed by ?branch= and ?collection=) as one JSON message,
+// until the client disconnects. include_documents=true adds the decoded
+// images, as on the timeline. Appends never wait on a reader: a client
+// that falls too far behind gets a final error message and is closed.
+func (r *Router) streamEntries(c *gin.Context) {
+	projectName := c.Query("project")
+	if projectName == "" {
+		abortErr(c, http.StatusBadRequest, fmt.Errorf("project query parameter is required"))
+		return
+	}
+	project, err := r.services.Projects.GetProjectByName(projectName)
+	if err != nil {
+		abortLookup(c, err, fmt.Sprintf("project %q not found", projectName))
+		return
+	}
+	branchID := ""
+	if branchName := c.Query("branch"); branchName != "" {
+		branch, err := r.services.Branches.GetBranch(project.ID, branchName)
+		if err != nil {
+			abortLookup(c, err, fmt.Sprintf("branch %q not found", branchName))
+			return
+		}
+		branchID = branch.ID
+	}
+	includeDocs := c.Query("include_documents") == "true"
+
+	// Subscribe before the handshake completes, so every entry appended
+	// after the client's dial returns reaches it.
+	sub := r.services.SubscribeEntries(project.ID, branchID, c.Query("collection"))
+	defer r.services.WAL.Unsubscribe(sub)
+
+	websocket.Server{Handshake: r.checkStreamOrigin, Handler: func(ws *websocket.Conn) {
+		defer func() { _ = ws.Close() }()
+
+		// Clients send nothing; reading only notices that they left.
+		gone := make(chan struct{})
+		go func() {
+			_, _ = io.Copy(io.Discard, ws)
+			close(gone)
+		}()
+
+		for {
+			select {
+			case <-gone:
+				return
+			case entry, ok := <-sub.Entries:
+				if !ok {
+					if sub.Dropped() {
+						_ = websocket.JSON.Send(ws, gin.H{"error": "stream fell too far behind; reconnect and catch up from the timeline"})
+					}
+					return
+				}
+				var msg interface{} = entry
+				if includeDocs {
+					withDocs, err := walcli.WithDocument(entry)
+					if err != nil {
+						_ = websocket.JSON.Send(ws, gin.H{"error": err.Error()})
+						return
+					}
+					msg = withDocs
+				}
+				if err := websocket.JSON.Send(ws, msg); err != nil {
+					return
+				}
+			}
+		}
+	}}.ServeHTTP(c.Writer, c.Request)
+}
+
+// checkStreamOrigin is the stream's WebSocket handshake check. Browsers
+// attach cookies and basic auth to cross-site upgrades without asking
+// CORS, so a page on any site could otherwise read the stream. Requests
+// without an Origin (not from a browser) pass; browser origins must be in
+// CORSOrigins, or, when it is empty, be the server's own. Only an explicit
+// "*" opens the stream to every site.
+func (r *Router) checkStreamOrigin(_ *websocket.Config, req *http.Request) error {
+	origin := req.Header.Get("Origin")
+	if origin == "" || r.opts.CORSOrigins == "*" || originAllowlist(r.opts.CORSOrigins)[origin] {
+		return nil
+	}
+	if r.opts.CORSOrigins == "" {
+		if u, err := url.Parse(origin); err == nil && u.Host == req.Host {
+			return nil
+		}
+	}
+	return fmt.Errorf("origin %q may not open the WAL stream", origin)
+}
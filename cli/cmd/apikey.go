@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/argon-lab/argon/pkg/walcli"
+	"github.com/spf13/cobra"
+)
+
+var apikeyCmd = &cobra.Command{
+	Use:   "apikey",
+	Short: "Manage API keys for the REST server",
+	Long: `API keys authenticate clients of the REST server when it runs with
+ARGON_REQUIRE_API_KEY=1. Every key is scoped to one project: it reaches
+that project's routes and nothing else.`,
+}
+
+var apikeyCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create an API key for a project",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		projectName, _ := cmd.Flags().GetString("project")
+		name, _ := cmd.Flags().GetString("name")
+
+		services, err := walcli.NewServices()
+		if err != nil {
+			return fmt.Errorf("failed to connect: %w", err)
+		}
+		project, err := services.Projects.GetProjectByName(projectName)
+		if err != nil {
+			return fmt.Errorf("project %q not found: %w", projectName, err)
+		}
+		key, _, err := services.APIKeys.Create(project.ID, name)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Created API key for project %s:\n\n  %s\n\n", projectName, key)
+		fmt.Println("Store it now: only its hash is kept, so it cannot be shown again.")
+		fmt.Println("Send it as \"Authorization: Bearer <key>\".")
+		return nil
+	},
+}
+
+func init() {
+	apikeyCreateCmd.Flags().StringP("project", "p", "", "Project name (required)")
+	apikeyCreateCmd.Flags().String("name", "", "Label to identify the key by")
+	_ = apikeyCreateCmd.MarkFlagRequired("project")
+
+	apikeyCmd.AddCommand(apikeyCreateCmd)
+	rootCmd.AddCommand(apikeyCmd)
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/argon-lab/argon/pkg/walcli"
+	"github.com/spf13/cobra"
+)
+
+var benchCmd = &cobra.Command{
+	Use:   "bench",
+	Short: "Run the standard benchmark workloads and report them as JSON",
+	Long: `Runs fixed workloads — bulk insert, materializing every inserted
+document, time travel to random LSNs, branch creation — in a throwaway
+project, and prints ops/sec and latency percentiles per workload as JSON.
+The same flags (and --seed) always do the same work, so reports from
+different builds are comparable; track them in CI to catch regressions.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := walcli.BenchConfig{}
+		cfg.Docs, _ = cmd.Flags().GetInt("docs")
+		cfg.BatchSize, _ = cmd.Flags().GetInt("batch")
+		cfg.Iterations, _ = cmd.Flags().GetInt("iterations")
+		cfg.Seed, _ = cmd.Flags().GetInt64("seed")
+		out, _ := cmd.Flags().GetString("out")
+		cfg.Project = fmt.Sprintf("argon-bench-%d", time.Now().UnixNano())
+
+		services, err := walcli.NewServices()
+		if err != nil {
+			return fmt.Errorf("failed to connect: %w", err)
+		}
+		report, err := services.RunBenchmark(cmd.Context(), cfg)
+		if err != nil {
+			return err
+		}
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		if out == "" {
+			_, err = fmt.Fprintln(cmd.OutOrStdout(), string(data))
+			return err
+		}
+		return os.WriteFile(out, append(data, '\n'), 0o644)
+	},
+}
+
+func init() {
+	benchCmd.Flags().Int("docs", 10000, "Documents to insert and materialize")
+	benchCmd.Flags().Int("batch", 500, "Documents per insert batch")
+	benchCmd.Flags().Int("iterations", 20, "Repetitions of the materialize, time-travel and branch workloads")
+	benchCmd.Flags().Int64("seed", 1, "Seed for generated documents and time-travel targets")
+	benchCmd.Flags().String("out", "", "Write the JSON report to this file instead of stdout")
+
+	rootCmd.AddCommand(benchCmd)
+}
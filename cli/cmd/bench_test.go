@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestBenchCommand runs "argon bench" at smoke-test size against the
+// deployment named by MONGODB_URI and checks the report's shape.
+func TestBenchCommand(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "bench.json")
+	rootCmd.SetArgs([]string{"bench", "--docs", "50", "--batch", "20", "--iterations", "3", "--seed", "7", "--out", out})
+	require.NoError(t, rootCmd.Execute())
+
+	raw, err := os.ReadFile(out)
+	require.NoError(t, err)
+	var report struct {
+		Config    map[string]interface{}            `json:"config"`
+		Workloads map[string]map[string]interface{} `json:"workloads"`
+	}
+	require.NoError(t, json.Unmarshal(raw, &report))
+	assert.EqualValues(t, 7, report.Config["seed"])
+
+	want := map[string]float64{"bulk_insert": 50, "materialize": 3, "time_travel": 3, "branch_create": 3}
+	require.Len(t, report.Workloads, len(want))
+	for name, ops := range want {
+		w, ok := report.Workloads[name]
+		require.True(t, ok, "missing workload %s", name)
+		assert.Equal(t, ops, w["ops"], name)
+		for _, key := range []string{"ops_per_sec", "p50_ms", "p95_ms", "p99_ms", "max_ms"} {
+			assert.Contains(t, w, key, name)
+		}
+	}
+}
@@ -1,7 +1,10 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
+	"os"
 
 	"github.com/argon-lab/argon/pkg/walcli"
 	"github.com/spf13/cobra"
@@ -24,6 +27,10 @@ var branchesCreateCmd = &cobra.Command{
 		if projectName == "" {
 			return fmt.Errorf("--project is required")
 		}
+		format, err := branchesOutput(cmd)
+		if err != nil {
+			return err
+		}
 
 		services, err := walcli.NewServices()
 		if err != nil {
@@ -41,18 +48,32 @@ var branchesCreateCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
-		branch, err := services.Branches.CreateBranch(projectID, branchName, fromBranch)
+		parent, err := services.Branches.GetBranch(projectID, fromBranch)
 		if err != nil {
+			if walcli.IsNotFound(err) {
+				return fmt.Errorf("source branch '%s' not found in project '%s'", fromBranch, projectName)
+			}
+			return fmt.Errorf("failed to get source branch: %w", err)
+		}
+		branch, err := services.Branches.CreateBranch(projectID, branchName, parent.ID)
+		if err != nil {
+			if walcli.IsBranchExists(err) {
+				return fmt.Errorf("branch '%s' already exists in project '%s'", branchName, projectName)
+			}
 			return fmt.Errorf("failed to create branch: %w", err)
 		}
 
-		fmt.Printf("⚡ Created branch '%s' (a metadata write, no data copied)\n", branch.Name)
-		fmt.Printf("   Project: %s\n", projectName)
-		fmt.Printf("   Based on: %s\n", fromBranch)
-		fmt.Printf("   Ready for instant experimentation!\n")
-		fmt.Println()
-		fmt.Println("Next steps:")
-		fmt.Printf("  argon time-travel info --project %s --branch %s\n", projectName, branchName)
+		out := cmd.OutOrStdout()
+		if format == "json" {
+			return writeJSON(out, branch)
+		}
+		fmt.Fprintf(out, "⚡ Created branch '%s' (a metadata write, no data copied)\n", branch.Name)
+		fmt.Fprintf(out, "   Project: %s\n", projectName)
+		fmt.Fprintf(out, "   Based on: %s\n", fromBranch)
+		fmt.Fprintf(out, "   Ready for instant experimentation!\n")
+		fmt.Fprintln(out)
+		fmt.Fprintln(out, "Next steps:")
+		fmt.Fprintf(out, "  argon time-travel info --project %s --branch %s\n", projectName, branchName)
 
 		return nil
 	},
@@ -67,6 +88,10 @@ var branchesListCmd = &cobra.Command{
 		if projectName == "" {
 			return fmt.Errorf("--project is required")
 		}
+		format, err := branchesOutput(cmd)
+		if err != nil {
+			return err
+		}
 
 		services, err := walcli.NewServices()
 		if err != nil {
@@ -82,21 +107,32 @@ var branchesListCmd = &cobra.Command{
 			return fmt.Errorf("failed to list branches: %w", err)
 		}
 
+		out := cmd.OutOrStdout()
+		if format == "json" {
+			if len(branches) == 0 {
+				return writeJSON(out, []struct{}{}) // [], not null
+			}
+			return writeJSON(out, branches)
+		}
+
 		if len(branches) == 0 {
-			fmt.Printf("No branches found in project '%s'.\n", projectName)
-			fmt.Println()
-			fmt.Println("Create your first branch:")
-			fmt.Printf("  argon branches create feature-x --project %s\n", projectName)
+			fmt.Fprintf(out, "No branches found in project '%s'.\n", projectName)
+			fmt.Fprintln(out)
+			fmt.Fprintln(out, "Create your first branch:")
+			fmt.Fprintf(out, "  argon branches create feature-x --project %s\n", projectName)
 			return nil
 		}
 
-		fmt.Printf("Branches in project '%s':\n\n", projectName)
+		fmt.Fprintf(out, "Branches in project '%s':\n\n", projectName)
 		for _, branch := range branches {
-			fmt.Printf("🌿 %s\n", branch.Name)
-			fmt.Printf("   LSN Range: %d → %d\n", branch.BaseLSN, branch.HeadLSN)
-			fmt.Printf("   Created: %v\n", branch.CreatedAt.Format("2006-01-02 15:04:05"))
-			fmt.Printf("   Features: ✅ Time travel, ✅ Instant creation\n")
-			fmt.Println()
+			fmt.Fprintf(out, "🌿 %s\n", branch.Name)
+			fmt.Fprintf(out, "   LSN Range: %d → %d\n", branch.BaseLSN, branch.HeadLSN)
+			fmt.Fprintf(out, "   Created: %v\n", branch.CreatedAt.Format("2006-01-02 15:04:05"))
+			fmt.Fprintf(out, "   Features: ✅ Time travel, ✅ Instant creation\n")
+			if branch.Protected {
+				fmt.Fprintf(out, "   🔒 Protected: read-only\n")
+			}
+			fmt.Fprintln(out)
 		}
 
 		return nil
@@ -119,6 +155,13 @@ var branchesDeleteCmd = &cobra.Command{
 		if branchName == "main" {
 			return fmt.Errorf("cannot delete main branch")
 		}
+		format, err := branchesOutput(cmd)
+		if err != nil {
+			return err
+		}
+		if err := guardDestructive(cmd, branchName); err != nil {
+			return err
+		}
 
 		services, err := walcli.NewServices()
 		if err != nil {
@@ -130,16 +173,194 @@ var branchesDeleteCmd = &cobra.Command{
 			return err
 		}
 		err = services.Branches.DeleteBranch(projectID, branchName)
-		if err != nil {
+		switch {
+		case walcli.IsNotFound(err):
+			return fmt.Errorf("branch '%s' not found in project '%s'", branchName, projectName)
+		case walcli.HasChildBranches(err):
+			return fmt.Errorf("branch '%s' has active child branches; delete them first", branchName)
+		case err != nil:
 			return fmt.Errorf("failed to delete branch: %w", err)
 		}
 
-		fmt.Printf("🗑️  Deleted branch '%s' from project '%s'\n", branchName, projectName)
+		out := cmd.OutOrStdout()
+		if format == "json" {
+			return writeJSON(out, map[string]interface{}{
+				"deleted": true,
+				"project": projectName,
+				"branch":  branchName,
+			})
+		}
+		fmt.Fprintf(out, "🗑️  Deleted branch '%s' from project '%s'\n", branchName, projectName)
+
+		return nil
+	},
+}
+
+var branchesRenameCmd = &cobra.Command{
+	Use:   "rename [old-name] [new-name]",
+	Short: "Rename a branch",
+	Long: `Rename a branch. Its history, snapshots and pins follow it: they are
+keyed by branch ID, not name. The main branch cannot be renamed.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		projectName, _ := cmd.Flags().GetString("project")
+
+		if projectName == "" {
+			return fmt.Errorf("--project is required")
+		}
+
+		oldName, newName := args[0], args[1]
+		format, err := branchesOutput(cmd)
+		if err != nil {
+			return err
+		}
+
+		services, err := walcli.NewServices()
+		if err != nil {
+			return fmt.Errorf("failed to connect to system: %w", err)
+		}
+
+		projectID, err := resolveProjectID(services, projectName)
+		if err != nil {
+			return err
+		}
+		branch, err := services.Branches.RenameBranch(projectID, oldName, newName)
+		switch {
+		case walcli.IsNotFound(err):
+			return fmt.Errorf("branch '%s' not found in project '%s'", oldName, projectName)
+		case walcli.IsBranchExists(err):
+			return fmt.Errorf("branch '%s' already exists in project '%s'", newName, projectName)
+		case walcli.IsMainBranchRename(err):
+			return err
+		case err != nil:
+			return fmt.Errorf("failed to rename branch: %w", err)
+		}
+
+		out := cmd.OutOrStdout()
+		if format == "json" {
+			return writeJSON(out, branch)
+		}
+		fmt.Fprintf(out, "✏️  Renamed branch '%s' to '%s' in project '%s'\n", oldName, newName, projectName)
 
 		return nil
 	},
 }
 
+var branchesProtectCmd = &cobra.Command{
+	Use:   "protect [branch-name]",
+	Short: "Make a branch read-only",
+	Long: `Protect a branch: writes, merges into it and undo are refused, and
+restores reset it only with --force. A checked-out branch's physical
+database is not guarded.`,
+	Args: cobra.ExactArgs(1),
+	RunE: setBranchProtection(true),
+}
+
+var branchesUnprotectCmd = &cobra.Command{
+	Use:   "unprotect [branch-name]",
+	Short: "Make a protected branch writable again",
+	Args:  cobra.ExactArgs(1),
+	RunE:  setBranchProtection(false),
+}
+
+// setBranchProtection is the body of "branches protect" and "unprotect".
+func setBranchProtection(protect bool) func(cmd *cobra.Command, args []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		projectName, _ := cmd.Flags().GetString("project")
+
+		if projectName == "" {
+			return fmt.Errorf("--project is required")
+		}
+		format, err := branchesOutput(cmd)
+		if err != nil {
+			return err
+		}
+
+		services, err := walcli.NewServices()
+		if err != nil {
+			return fmt.Errorf("failed to connect to system: %w", err)
+		}
+
+		branchName := args[0]
+		branchID, err := resolveBranch(services, projectName, branchName)
+		if err != nil {
+			return err
+		}
+		if err := services.Branches.SetProtected(branchID, protect); err != nil {
+			return fmt.Errorf("failed to update branch protection: %w", err)
+		}
+
+		out := cmd.OutOrStdout()
+		if format == "json" {
+			return writeJSON(out, map[string]interface{}{
+				"project":   projectName,
+				"branch":    branchName,
+				"protected": protect,
+			})
+		}
+		if protect {
+			fmt.Fprintf(out, "🔒 Protected branch '%s' in project '%s': it is now read-only\n", branchName, projectName)
+		} else {
+			fmt.Fprintf(out, "🔓 Unprotected branch '%s' in project '%s'\n", branchName, projectName)
+		}
+		return nil
+	}
+}
+
+// branchesOutput returns the --output format, checked before connecting:
+// the branches commands print a table or JSON.
+func branchesOutput(cmd *cobra.Command) (string, error) {
+	format, _ := cmd.Flags().GetString("output")
+	switch format {
+	case "", "table":
+		return "table", nil
+	case "json":
+		return format, nil
+	}
+	return "", fmt.Errorf("unsupported --output %q: use table or json", format)
+}
+
+// writeJSON prints v as indented JSON, for --output json.
+func writeJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+var branchesInfoCmd = &cobra.Command{
+	Use:   "info",
+	Short: "Show a branch's health summary",
+	Long: `Show HEAD and base LSNs, parent, entry count, per-collection document
+counts, ahead/behind relative to the parent, and the branch state in one view.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		projectName, _ := cmd.Flags().GetString("project")
+		branchName, _ := cmd.Flags().GetString("branch")
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		if projectName == "" || branchName == "" {
+			return fmt.Errorf("--project and --branch are required")
+		}
+
+		services, err := walcli.NewServices()
+		if err != nil {
+			return fmt.Errorf("failed to connect to system: %w", err)
+		}
+
+		info, err := services.BranchInfo(projectName, branchName)
+		if err != nil {
+			return err
+		}
+
+		if asJSON {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(info)
+		}
+		walcli.WriteBranchInfo(os.Stdout, info)
+		return nil
+	},
+}
+
 func init() {
 	// Add flags
 	branchesCreateCmd.Flags().StringP("project", "p", "", "Project name (required)")
@@ -151,11 +372,30 @@ func init() {
 
 	branchesDeleteCmd.Flags().StringP("project", "p", "", "Project name (required)")
 	_ = branchesDeleteCmd.MarkFlagRequired("project")
+	addConfirmFlag(branchesDeleteCmd)
+
+	branchesRenameCmd.Flags().StringP("project", "p", "", "Project name (required)")
+	_ = branchesRenameCmd.MarkFlagRequired("project")
+
+	for _, cmd := range []*cobra.Command{branchesProtectCmd, branchesUnprotectCmd} {
+		cmd.Flags().StringP("project", "p", "", "Project name (required)")
+		_ = cmd.MarkFlagRequired("project")
+	}
+
+	branchesInfoCmd.Flags().StringP("project", "p", "", "Project name (required)")
+	branchesInfoCmd.Flags().StringP("branch", "b", "", "Branch name (required)")
+	branchesInfoCmd.Flags().Bool("json", false, "Output as JSON")
+	_ = branchesInfoCmd.MarkFlagRequired("project")
+	_ = branchesInfoCmd.MarkFlagRequired("branch")
 
 	// Add subcommands
 	branchesCmd.AddCommand(branchesCreateCmd)
 	branchesCmd.AddCommand(branchesListCmd)
 	branchesCmd.AddCommand(branchesDeleteCmd)
+	branchesCmd.AddCommand(branchesRenameCmd)
+	branchesCmd.AddCommand(branchesProtectCmd)
+	branchesCmd.AddCommand(branchesUnprotectCmd)
+	branchesCmd.AddCommand(branchesInfoCmd)
 
 	// Add to root command
 	rootCmd.AddCommand(branchesCmd)
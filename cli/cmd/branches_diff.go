@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/argon-lab/argon/pkg/walcli"
+	"github.com/spf13/cobra"
+)
+
+var branchesDiffCmd = &cobra.Command{
+	Use:   "diff <branch-a> <branch-b>",
+	Short: "Show the documents that differ between two branches",
+	Long: `Diff compares the current states of any two branches of a project,
+document by document: documents only in B are added, only in A removed,
+and in both but unequal changed, with the fields that differ. Unlike
+"argon diff", which previews a merge into the parent, there is no fork
+point involved.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		projectName, _ := cmd.Flags().GetString("project")
+		collection, _ := cmd.Flags().GetString("collection")
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		services, err := walcli.NewServices()
+		if err != nil {
+			return fmt.Errorf("failed to connect to system: %w", err)
+		}
+		idA, err := resolveBranch(services, projectName, args[0])
+		if err != nil {
+			return err
+		}
+		idB, err := resolveBranch(services, projectName, args[1])
+		if err != nil {
+			return err
+		}
+		branchA, err := services.Branches.GetBranchByID(idA)
+		if err != nil {
+			return err
+		}
+		branchB, err := services.Branches.GetBranchByID(idB)
+		if err != nil {
+			return err
+		}
+
+		diff, err := services.Merge.DiffBranches(branchA, branchB, collection)
+		if err != nil {
+			return fmt.Errorf("diff failed: %w", err)
+		}
+		if asJSON {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(diff)
+		}
+		fmt.Printf("Comparing %s (LSN %d) → %s (LSN %d)\n", diff.BranchA, diff.LSNA, diff.BranchB, diff.LSNB)
+		for _, d := range diff.Documents {
+			fmt.Printf("  %-7s %s/%s\n", d.Kind, d.Collection, d.DocumentID)
+			for _, f := range d.Fields {
+				fmt.Printf("            %s: %v → %v\n", f.Path, f.A, f.B)
+			}
+		}
+		fmt.Printf("%d document(s) differ\n", len(diff.Documents))
+		return nil
+	},
+}
+
+func init() {
+	branchesDiffCmd.Flags().StringP("project", "p", "", "Project name (required)")
+	branchesDiffCmd.Flags().StringP("collection", "c", "", "Compare one collection (default: all)")
+	branchesDiffCmd.Flags().Bool("json", false, "Output as JSON")
+	_ = branchesDiffCmd.MarkFlagRequired("project")
+
+	branchesCmd.AddCommand(branchesDiffCmd)
+}
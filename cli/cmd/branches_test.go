@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/argon-lab/argon/pkg/walcli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// resetBranchesFlags restores the flags and output a branches test sets on
+// the shared command tree.
+func resetBranchesFlags(t *testing.T) {
+	t.Cleanup(func() {
+		_ = rootCmd.PersistentFlags().Set("output", "table")
+		_ = branchesCreateCmd.Flags().Set("from", "main")
+		rootCmd.SetOut(nil)
+	})
+}
+
+// TestBranchesRefuseBeforeConnecting needs no deployment: bad input fails
+// before the command connects.
+func TestBranchesRefuseBeforeConnecting(t *testing.T) {
+	resetBranchesFlags(t)
+
+	rootCmd.SetArgs([]string{"branches", "list", "-p", "any", "-o", "yaml"})
+	assert.ErrorContains(t, rootCmd.Execute(), `unsupported --output "yaml"`)
+
+	rootCmd.SetArgs([]string{"branches", "delete", "main", "-p", "any", "-o", "table"})
+	assert.ErrorContains(t, rootCmd.Execute(), "cannot delete main branch")
+}
+
+// TestBranchesCommands drives "argon branches" against a throwaway
+// database.
+func TestBranchesCommands(t *testing.T) {
+	resetBranchesFlags(t)
+	services := testServices(t)
+
+	projectName := "branches-cli"
+	project, err := services.Projects.CreateProject(projectName)
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	run := func(args ...string) error {
+		out.Reset()
+		rootCmd.SetArgs(append([]string{"branches"}, args...))
+		return rootCmd.Execute()
+	}
+
+	require.NoError(t, run("create", "feature", "-p", projectName, "--from", "main", "-o", "json"))
+	var created struct {
+		ID       string `json:"id"`
+		Name     string `json:"name"`
+		ParentID string `json:"parent_id"`
+	}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &created))
+	mainBranch, err := services.Branches.GetBranch(project.ID, "main")
+	require.NoError(t, err)
+	assert.Equal(t, "feature", created.Name)
+	assert.Equal(t, mainBranch.ID, created.ParentID)
+
+	assert.ErrorContains(t, run("create", "feature", "-p", projectName, "--from", "main"),
+		fmt.Sprintf("branch 'feature' already exists in project '%s'", projectName))
+	assert.ErrorContains(t, run("create", "other", "-p", projectName, "--from", "missing"),
+		"source branch 'missing' not found")
+	require.NoError(t, run("create", "child", "-p", projectName, "--from", "feature", "-o", "table"))
+	assert.Contains(t, out.String(), "Created branch 'child'")
+
+	require.NoError(t, run("list", "-p", projectName, "-o", "json"))
+	var listed []struct {
+		Name string `json:"name"`
+	}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &listed))
+	var names []string
+	for _, branch := range listed {
+		names = append(names, branch.Name)
+	}
+	assert.ElementsMatch(t, []string{"main", "feature", "child"}, names)
+
+	assert.ErrorContains(t, run("rename", "child", "feature", "-p", projectName),
+		fmt.Sprintf("branch 'feature' already exists in project '%s'", projectName))
+	require.NoError(t, run("rename", "child", "grandchild", "-p", projectName, "-o", "json"))
+	var renamed struct {
+		Name     string `json:"name"`
+		ParentID string `json:"parent_id"`
+	}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &renamed))
+	assert.Equal(t, "grandchild", renamed.Name)
+	assert.Equal(t, created.ID, renamed.ParentID)
+	require.NoError(t, run("rename", "grandchild", "child", "-p", projectName, "-o", "table"))
+	assert.Contains(t, out.String(), "Renamed branch 'grandchild' to 'child'")
+	assert.ErrorContains(t, run("rename", "grandchild", "other", "-p", projectName),
+		fmt.Sprintf("branch 'grandchild' not found in project '%s'", projectName))
+	assert.ErrorContains(t, run("rename", "main", "trunk", "-p", projectName), "cannot rename to or from main branch")
+	assert.ErrorContains(t, run("rename", "child", "main", "-p", projectName), "cannot rename to or from main branch")
+
+	assert.ErrorContains(t, run("delete", "feature", "-p", projectName),
+		"branch 'feature' has active child branches")
+	require.NoError(t, run("delete", "child", "-p", projectName, "-o", "json"))
+	assert.JSONEq(t, fmt.Sprintf(`{"deleted": true, "project": %q, "branch": "child"}`, projectName), out.String())
+	require.NoError(t, run("delete", "feature", "-p", projectName))
+	assert.ErrorContains(t, run("delete", "feature", "-p", projectName),
+		fmt.Sprintf("branch 'feature' not found in project '%s'", projectName))
+}
+
+// TestBranchesProtectCommands drives "argon branches protect/unprotect"
+// against a throwaway database.
+func TestBranchesProtectCommands(t *testing.T) {
+	resetBranchesFlags(t)
+	resetRestoreFlags(t)
+	services := testServices(t)
+	ctx := context.Background()
+
+	projectName := "protect-cli"
+	project, err := services.Projects.CreateProject(projectName)
+	require.NoError(t, err)
+	writer, err := services.WriterFor(projectName, "main")
+	require.NoError(t, err)
+	target, err := writer.Put(ctx, "docs", bson.M{"_id": "d1"})
+	require.NoError(t, err)
+	_, err = writer.Put(ctx, "docs", bson.M{"_id": "d2"})
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	run := func(args ...string) error {
+		out.Reset()
+		rootCmd.SetArgs(args)
+		return rootCmd.Execute()
+	}
+
+	require.NoError(t, run("branches", "protect", "main", "-p", projectName, "-o", "json"))
+	assert.JSONEq(t, fmt.Sprintf(`{"project": %q, "branch": "main", "protected": true}`, projectName), out.String())
+	writer, err = services.WriterFor(projectName, "main")
+	require.NoError(t, err)
+	_, err = writer.Put(ctx, "docs", bson.M{"_id": "d3"})
+	assert.True(t, walcli.IsBranchProtected(err), "%v", err)
+
+	// Resets need --force; forcing one leaves the branch protected.
+	assert.ErrorContains(t, run("restore", "-p", projectName, "-b", "main", "--to-lsn", fmt.Sprint(target), "--yes"),
+		`branch "main" is protected: pass --force`)
+	require.NoError(t, run("restore", "-p", projectName, "-b", "main", "--to-lsn", fmt.Sprint(target), "--yes", "--force"))
+	main, err := services.Branches.GetBranch(project.ID, "main")
+	require.NoError(t, err)
+	assert.Equal(t, target, main.HeadLSN)
+	assert.True(t, main.Protected)
+
+	require.NoError(t, run("branches", "unprotect", "main", "-p", projectName, "-o", "table"))
+	assert.Contains(t, out.String(), "Unprotected branch 'main'")
+	writer, err = services.WriterFor(projectName, "main")
+	require.NoError(t, err)
+	_, err = writer.Put(ctx, "docs", bson.M{"_id": "d3"})
+	require.NoError(t, err)
+
+	assert.ErrorContains(t, run("branches", "protect", "missing", "-p", projectName), `branch "missing" not found`)
+}
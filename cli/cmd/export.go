@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/argon-lab/argon/pkg/walcli"
+	"github.com/spf13/cobra"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/connstring"
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Copy a branch's state into a MongoDB database",
+	Long: `Export materializes every collection of a branch — at its head, or at
+--lsn — and bulk-inserts the documents into a database on any MongoDB
+deployment, keeping their _id values and types. Use it to seed
+integration tests from a branch. The target database must be empty
+unless --drop is given; in safe mode --drop also needs --confirm with the
+target database's name. Unlike "argon checkout", the exported database
+is a plain copy: writes to it are not captured.
+
+"argon export dump" writes the same state to mongodump-style files
+instead.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		projectName, _ := cmd.Flags().GetString("project")
+		branchName, _ := cmd.Flags().GetString("branch")
+		lsn, _ := cmd.Flags().GetInt64("lsn")
+		to, _ := cmd.Flags().GetString("to")
+		dbName, _ := cmd.Flags().GetString("db")
+		drop, _ := cmd.Flags().GetBool("drop")
+
+		if dbName == "" {
+			cs, err := connstring.Parse(to)
+			if err != nil {
+				return fmt.Errorf("invalid --to URI: %w", err)
+			}
+			dbName = cs.Database
+		}
+		if dbName == "" {
+			return fmt.Errorf("name the target database in the --to URI path or with --db")
+		}
+		if drop {
+			if err := guardDestructive(cmd, dbName); err != nil {
+				return err
+			}
+		}
+
+		services, err := walcli.NewServices()
+		if err != nil {
+			return fmt.Errorf("failed to connect: %w", err)
+		}
+		branchID, err := resolveBranch(services, projectName, branchName)
+		if err != nil {
+			return err
+		}
+		branch, err := services.Branches.GetBranchByID(branchID)
+		if err != nil {
+			return err
+		}
+
+		result, err := services.Materializer.ExportToDatabase(branch, to, dbName, lsn, drop)
+		if err != nil {
+			return fmt.Errorf("export failed: %w", err)
+		}
+		fmt.Printf("Exported %s/%s at LSN %d to database %q: %d collection(s), %d document(s)\n",
+			projectName, branch.Name, result.LSN, result.Database, result.Collections, result.Documents)
+		return nil
+	},
+}
+
+var exportDumpCmd = &cobra.Command{
+	Use:   "dump",
+	Short: "Write a branch's state as mongodump-compatible files",
+	Long: `Writes each collection of a branch — at its head, or at --lsn — to
+<collection>.bson and <collection>.metadata.json files in --out, the
+layout mongodump produces for one database (gzipped with --gzip). Load
+them with any dump consumer, e.g.
+
+  mongorestore --db NAME --dir DIR [--gzip]
+
+Collections are materialized and written one at a time, so a dump holds
+at most one collection in memory.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		projectName, _ := cmd.Flags().GetString("project")
+		branchName, _ := cmd.Flags().GetString("branch")
+		lsn, _ := cmd.Flags().GetInt64("lsn")
+		out, _ := cmd.Flags().GetString("out")
+		gz, _ := cmd.Flags().GetBool("gzip")
+
+		services, err := walcli.NewServices()
+		if err != nil {
+			return fmt.Errorf("failed to connect: %w", err)
+		}
+		branchID, err := resolveBranch(services, projectName, branchName)
+		if err != nil {
+			return err
+		}
+		branch, err := services.Branches.GetBranchByID(branchID)
+		if err != nil {
+			return err
+		}
+
+		result, err := services.Dump.ExportDump(branch, out, lsn, gz)
+		if err != nil {
+			return fmt.Errorf("dump failed: %w", err)
+		}
+		for _, c := range result.Collections {
+			fmt.Printf("  %-24s %d document(s)\n", c.Name, c.Documents)
+		}
+		fmt.Printf("Dumped %s/%s at LSN %d to %s: %d collection(s), %d document(s)\n",
+			projectName, branch.Name, result.LSN, result.Dir, len(result.Collections), result.Documents)
+		return nil
+	},
+}
+
+func init() {
+	exportCmd.Flags().StringP("project", "p", "", "Project name (required)")
+	exportCmd.Flags().StringP("branch", "b", "main", "Branch to export")
+	exportCmd.Flags().Int64("lsn", 0, "Export the state at this LSN (default: the head)")
+	exportCmd.Flags().String("to", "", "MongoDB URI of the target deployment (required)")
+	exportCmd.Flags().String("db", "", "Target database (default: the database in the --to URI path)")
+	exportCmd.Flags().Bool("drop", false, "Drop the target database first if it is not empty")
+	addConfirmFlag(exportCmd)
+	_ = exportCmd.MarkFlagRequired("project")
+	_ = exportCmd.MarkFlagRequired("to")
+
+	exportDumpCmd.Flags().StringP("project", "p", "", "Project name (required)")
+	exportDumpCmd.Flags().StringP("branch", "b", "main", "Branch to dump")
+	exportDumpCmd.Flags().Int64("lsn", 0, "Dump the state at this LSN (default: the head)")
+	exportDumpCmd.Flags().StringP("out", "o", "", "Output directory (required)")
+	exportDumpCmd.Flags().Bool("gzip", false, "Gzip the files, like mongodump --gzip")
+	_ = exportDumpCmd.MarkFlagRequired("project")
+	_ = exportDumpCmd.MarkFlagRequired("out")
+
+	exportCmd.AddCommand(exportDumpCmd)
+	rootCmd.AddCommand(exportCmd)
+}
@@ -5,6 +5,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -18,6 +21,7 @@ type ImportPreview struct {
 	TotalDocuments      int64             `json:"total_documents"`
 	EstimatedSize       int64             `json:"estimated_size_bytes"`
 	EstimatedWALEntries int64             `json:"estimated_wal_entries"`
+	Warnings            []string          `json:"warnings,omitempty"`
 }
 
 // CollectionInfo contains details about a collection to be imported
@@ -35,6 +39,13 @@ type ImportOptions struct {
 	ProjectName  string `json:"project_name"`
 	DryRun       bool   `json:"dry_run"`
 	BatchSize    int    `json:"batch_size"`
+	SourceOrder  string `json:"source_order"`
+	// IncludeIndexes records secondary indexes for exports to recreate.
+	IncludeIndexes bool `json:"include_indexes"`
+	Concurrency    int  `json:"concurrency"`
+	// Include and Exclude select collections by glob pattern.
+	Include []string `json:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
 }
 
 // ImportResult contains the result of an import operation
@@ -43,10 +54,18 @@ type ImportResult struct {
 	BranchID     string        `json:"branch_id"`
 	ImportedDocs int64         `json:"imported_documents"`
 	WALEntries   int64         `json:"wal_entries_created"`
+	Indexes      int64         `json:"imported_indexes"`
 	Collections  []string      `json:"imported_collections"`
 	Duration     time.Duration `json:"duration"`
 	StartLSN     int64         `json:"start_lsn"`
 	EndLSN       int64         `json:"end_lsn"`
+
+	// Incomplete marks a cancelled import; Checkpoint is passed back
+	// verbatim to resume it.
+	Incomplete bool            `json:"incomplete,omitempty"`
+	Checkpoint json.RawMessage `json:"checkpoint,omitempty"`
+	JobID      string          `json:"job_id,omitempty"`
+	Warnings   []string        `json:"warnings,omitempty"`
 }
 
 // importCmd represents the import command
@@ -61,6 +80,7 @@ time travel, branching, and other Argon features on your existing data.
 Available subcommands:
   preview  - Preview what would be imported
   database - Import an existing MongoDB database
+  resume   - Resume a failed or interrupted import
   status   - Check status of import operations`,
 }
 
@@ -75,6 +95,8 @@ This command analyzes the source database and shows:
 - Document counts and estimated sizes
 - Estimated WAL entries that would be created
 
+--include and --exclude select collections as for import database.
+
 Example:
   argon import preview --uri "mongodb://localhost:27017" --database "myapp"`,
 	RunE: func(cmd *cobra.Command, args []string) error {
@@ -83,6 +105,8 @@ Example:
 		// Get flags
 		mongoURI, _ := cmd.Flags().GetString("uri")
 		databaseName, _ := cmd.Flags().GetString("database")
+		include, _ := cmd.Flags().GetStringSlice("include")
+		exclude, _ := cmd.Flags().GetStringSlice("exclude")
 		outputFormat, _ := cmd.Flags().GetString("output")
 
 		if mongoURI == "" {
@@ -99,7 +123,7 @@ Example:
 		}
 
 		// Preview the import
-		previewData, err := services.ImportPreview(ctx, mongoURI, databaseName)
+		previewData, err := services.ImportPreview(ctx, mongoURI, databaseName, include, exclude)
 		if err != nil {
 			return fmt.Errorf("failed to preview import: %w", err)
 		}
@@ -128,8 +152,18 @@ var importDatabaseCmd = &cobra.Command{
 This creates a new Argon project and imports all data from the source
 database, enabling time travel and branching capabilities.
 
+Ctrl-C stops the import after the current batch and saves a checkpoint
+(--checkpoint, by default argon-import-<project>.json); pass it to
+--resume to continue into the same project.
+
+--include limits the import to collections matching any of its glob
+patterns (e.g. "users", "logs_*"); --exclude then drops collections
+matching any of its patterns. Both can be repeated or comma-separated.
+Patterns that match no collection are reported as warnings.
+
 Example:
-  argon import database --uri "mongodb://localhost:27017" --database "myapp" --project "imported-myapp"`,
+  argon import database --uri "mongodb://localhost:27017" --database "myapp" --project "imported-myapp"
+  argon import database --uri "mongodb://localhost:27017" --database "myapp" --project "imported-myapp" --exclude "tmp_*,cache"`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		ctx := context.Background()
 
@@ -139,7 +173,17 @@ Example:
 		projectName, _ := cmd.Flags().GetString("project")
 		dryRun, _ := cmd.Flags().GetBool("dry-run")
 		batchSize, _ := cmd.Flags().GetInt("batch-size")
+		sourceOrder, _ := cmd.Flags().GetString("order")
+		noIndexes, _ := cmd.Flags().GetBool("no-indexes")
+		concurrency, _ := cmd.Flags().GetInt("concurrency")
+		include, _ := cmd.Flags().GetStringSlice("include")
+		exclude, _ := cmd.Flags().GetStringSlice("exclude")
 		outputFormat, _ := cmd.Flags().GetString("output")
+		resumePath, _ := cmd.Flags().GetString("resume")
+		checkpointPath, _ := cmd.Flags().GetString("checkpoint")
+		if checkpointPath == "" {
+			checkpointPath = fmt.Sprintf("argon-import-%s.json", projectName)
+		}
 
 		if mongoURI == "" {
 			return fmt.Errorf("--uri flag is required")
@@ -151,6 +195,14 @@ Example:
 			return fmt.Errorf("--project flag is required")
 		}
 
+		var checkpoint []byte
+		if resumePath != "" {
+			var err error
+			if checkpoint, err = os.ReadFile(resumePath); err != nil {
+				return fmt.Errorf("failed to read checkpoint: %w", err)
+			}
+		}
+
 		// Initialize services
 		services, err := walcli.NewServices()
 		if err != nil {
@@ -159,28 +211,31 @@ Example:
 
 		// Configure import options
 		opts := ImportOptions{
-			MongoURI:     mongoURI,
-			DatabaseName: databaseName,
-			ProjectName:  projectName,
-			DryRun:       dryRun,
-			BatchSize:    batchSize,
+			MongoURI:       mongoURI,
+			DatabaseName:   databaseName,
+			ProjectName:    projectName,
+			DryRun:         dryRun,
+			BatchSize:      batchSize,
+			SourceOrder:    sourceOrder,
+			IncludeIndexes: !noIndexes,
+			Concurrency:    concurrency,
+			Include:        include,
+			Exclude:        exclude,
 		}
 
 		// Show confirmation unless dry run or --yes.
 		assumeYes, _ := cmd.Flags().GetBool("yes")
 		if !dryRun && !assumeYes {
-			if stat, statErr := os.Stdin.Stat(); statErr == nil && (stat.Mode()&os.ModeCharDevice) == 0 {
-				// Non-interactive (container, CI, pipe): a silent zero-exit
-				// cancel here has bitten people; demand an explicit --yes.
-				return fmt.Errorf("stdin is not a terminal; pass --yes to confirm the import")
+			if err := requireTerminal("import"); err != nil {
+				return err
+			}
+			if checkpoint != nil {
+				fmt.Printf("⚠️  About to resume importing database '%s' into project '%s'\n", databaseName, projectName)
+			} else {
+				fmt.Printf("⚠️  About to import database '%s' into new project '%s'\n", databaseName, projectName)
 			}
-			fmt.Printf("⚠️  About to import database '%s' into new project '%s'\n", databaseName, projectName)
 			fmt.Printf("   This will create WAL entries for all existing data.\n")
-			fmt.Printf("   Continue? (y/N): ")
-
-			var response string
-			_, _ = fmt.Scanln(&response)
-			if response != "y" && response != "Y" && response != "yes" {
+			if !askContinue() {
 				return fmt.Errorf("import cancelled")
 			}
 		}
@@ -191,13 +246,35 @@ Example:
 			fmt.Println("   (DRY RUN - no changes will be made)")
 		}
 
-		resultData, err := services.ImportDatabase(ctx, opts.MongoURI, opts.DatabaseName, opts.ProjectName, opts.DryRun, opts.BatchSize)
-		if err != nil {
-			return fmt.Errorf("failed to import database: %w", err)
+		// Ctrl-C cancels the import at the next batch boundary. Installed
+		// only now, so the prompt above can still be interrupted outright.
+		ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+		if outputFormat != "json" {
+			services.SetImportProgress(printImportProgress)
+		}
+		resultData, err := services.ImportDatabase(ctx, opts.MongoURI, opts.DatabaseName, opts.ProjectName, opts.DryRun, opts.BatchSize, opts.SourceOrder, opts.IncludeIndexes, opts.Concurrency, opts.Include, opts.Exclude, checkpoint)
+		if outputFormat != "json" {
+			fmt.Println()
 		}
 
 		// Convert to our CLI type
 		result := convertToImportResult(resultData)
+		if err != nil && result.Incomplete {
+			if werr := os.WriteFile(checkpointPath, result.Checkpoint, 0o600); werr != nil {
+				return fmt.Errorf("%w (and failed to save the checkpoint: %v)", err, werr)
+			}
+			fmt.Printf("⏸️  Import stopped after %s documents; checkpoint saved to %s\n", formatNumber(result.ImportedDocs), checkpointPath)
+			fmt.Printf("   Resume: argon import resume --project %s (or import database ... --resume %s)\n", projectName, checkpointPath)
+			cmd.SilenceUsage = true
+			return fmt.Errorf("import cancelled")
+		}
+		if err != nil {
+			if result.JobID != "" {
+				fmt.Printf("   Progress was saved; resume with: argon import resume --project %s\n", projectName)
+			}
+			return fmt.Errorf("failed to import database: %w", err)
+		}
 
 		// Output results
 		switch outputFormat {
@@ -211,6 +288,65 @@ Example:
 	},
 }
 
+// importResumeCmd resumes an import from its persisted job
+var importResumeCmd = &cobra.Command{
+	Use:   "resume",
+	Short: "Resume a failed or interrupted import",
+	Long: `Resume the import that created a project, from the progress it saved
+after every batch. Documents already imported are not imported again.
+
+The source URI is remembered unless it contains a password; then pass it
+again with --uri.
+
+An import that still looks running (it saved progress in the last few
+minutes) is refused, so two processes never import the same documents;
+pass --force once you know its process is gone.
+
+Example:
+  argon import resume --project "imported-myapp"`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		projectName, _ := cmd.Flags().GetString("project")
+		mongoURI, _ := cmd.Flags().GetString("uri")
+		outputFormat, _ := cmd.Flags().GetString("output")
+		force, _ := cmd.Flags().GetBool("force")
+
+		services, err := walcli.NewServices()
+		if err != nil {
+			return fmt.Errorf("failed to initialize services: %w", err)
+		}
+
+		fmt.Printf("🚀 Resuming import into project '%s'...\n", projectName)
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+		if outputFormat != "json" {
+			services.SetImportProgress(printImportProgress)
+		}
+		resultData, err := services.ResumeImport(ctx, projectName, mongoURI, force)
+		if outputFormat != "json" {
+			fmt.Println()
+		}
+		result := convertToImportResult(resultData)
+		if err != nil && result.Incomplete {
+			fmt.Printf("⏸️  Import stopped after %s more documents\n", formatNumber(result.ImportedDocs))
+			fmt.Printf("   Resume: argon import resume --project %s\n", projectName)
+			cmd.SilenceUsage = true
+			return fmt.Errorf("import cancelled")
+		}
+		if err != nil {
+			return fmt.Errorf("failed to resume import: %w", err)
+		}
+
+		switch outputFormat {
+		case "json":
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(result)
+		default:
+			return printImportResult(result, false)
+		}
+	},
+}
+
 // importStatusCmd shows the status of import operations
 var importStatusCmd = &cobra.Command{
 	Use:   "status",
@@ -273,11 +409,14 @@ func init() {
 	rootCmd.AddCommand(importCmd)
 	importCmd.AddCommand(importPreviewCmd)
 	importCmd.AddCommand(importDatabaseCmd)
+	importCmd.AddCommand(importResumeCmd)
 	importCmd.AddCommand(importStatusCmd)
 
 	// Preview command flags
 	importPreviewCmd.Flags().StringP("uri", "u", "", "MongoDB connection URI (required)")
 	importPreviewCmd.Flags().StringP("database", "d", "", "Database name to preview (required)")
+	importPreviewCmd.Flags().StringSlice("include", nil, "Only preview collections matching these glob patterns")
+	importPreviewCmd.Flags().StringSlice("exclude", nil, "Skip collections matching these glob patterns")
 	importPreviewCmd.Flags().StringP("output", "o", "table", "Output format: table, json")
 	_ = importPreviewCmd.MarkFlagRequired("uri")
 	_ = importPreviewCmd.MarkFlagRequired("database")
@@ -289,11 +428,25 @@ func init() {
 	importDatabaseCmd.Flags().Bool("dry-run", false, "Preview import without making changes")
 	importDatabaseCmd.Flags().BoolP("yes", "y", false, "Skip the confirmation prompt (required when stdin is not a terminal)")
 	importDatabaseCmd.Flags().Int("batch-size", 1000, "Number of documents to process in each batch")
+	importDatabaseCmd.Flags().String("order", "_id", "Source read order, which becomes WAL order: _id, natural")
+	importDatabaseCmd.Flags().Int("concurrency", 1, "Number of collections to import in parallel")
+	importDatabaseCmd.Flags().StringSlice("include", nil, "Only import collections matching these glob patterns")
+	importDatabaseCmd.Flags().StringSlice("exclude", nil, "Skip collections matching these glob patterns (applied after --include)")
+	importDatabaseCmd.Flags().Bool("no-indexes", false, "Don't record the source's secondary indexes (exports then create none)")
 	importDatabaseCmd.Flags().StringP("output", "o", "table", "Output format: table, json")
+	importDatabaseCmd.Flags().String("resume", "", "Resume a cancelled import from its checkpoint file")
+	importDatabaseCmd.Flags().String("checkpoint", "", "Where a cancelled import saves its checkpoint (default argon-import-<project>.json)")
 	_ = importDatabaseCmd.MarkFlagRequired("uri")
 	_ = importDatabaseCmd.MarkFlagRequired("database")
 	_ = importDatabaseCmd.MarkFlagRequired("project")
 
+	// Resume command flags
+	importResumeCmd.Flags().StringP("project", "p", "", "Project the import created (required)")
+	importResumeCmd.Flags().StringP("uri", "u", "", "MongoDB connection URI (required if the original contained a password)")
+	importResumeCmd.Flags().StringP("output", "o", "table", "Output format: table, json")
+	importResumeCmd.Flags().Bool("force", false, "Resume even if the import still looks running")
+	_ = importResumeCmd.MarkFlagRequired("project")
+
 	// Status command flags
 	importStatusCmd.Flags().StringP("project", "p", "", "Project name to check status (required)")
 	_ = importStatusCmd.MarkFlagRequired("project")
@@ -302,6 +455,7 @@ func init() {
 // printImportPreview prints the import preview in a user-friendly format
 func printImportPreview(preview *ImportPreview) error {
 	fmt.Printf("📋 Import Preview for Database '%s'\n\n", preview.DatabaseName)
+	printImportWarnings(preview.Warnings)
 
 	if len(preview.Collections) == 0 {
 		fmt.Println("   No collections found to import.")
@@ -329,8 +483,45 @@ func printImportPreview(preview *ImportPreview) error {
 	return nil
 }
 
+// printImportWarnings lists collection patterns that matched nothing.
+func printImportWarnings(warnings []string) {
+	for _, warning := range warnings {
+		fmt.Printf("⚠️  %s\n", warning)
+	}
+	if len(warnings) > 0 {
+		fmt.Println()
+	}
+}
+
+// printImportProgress redraws the progress line as batches land.
+func printImportProgress(p walcli.ImportProgress) {
+	fmt.Print("\r" + importProgressLine(p) + "\033[K")
+}
+
+// importProgressLine renders progress as a bar over the estimated total,
+// with counts, the ETA and the collection in flight.
+func importProgressLine(p walcli.ImportProgress) string {
+	const width = 30
+	fraction := 0.0
+	if p.TotalDocuments > 0 {
+		fraction = min(1, float64(p.Documents)/float64(p.TotalDocuments))
+	}
+	filled := int(fraction * width)
+	line := fmt.Sprintf("   [%s%s] %3.0f%%  %s/%s documents  %d/%d collections",
+		strings.Repeat("█", filled), strings.Repeat("░", width-filled), fraction*100,
+		formatNumber(p.Documents), formatNumber(p.TotalDocuments), p.Collections, p.TotalCollections)
+	if p.ETA > 0 {
+		line += fmt.Sprintf("  ETA %s", p.ETA.Round(time.Second))
+	}
+	if p.Collection != "" && p.Collections < p.TotalCollections {
+		line += "  " + p.Collection
+	}
+	return line
+}
+
 // printImportResult prints the import result in a user-friendly format
 func printImportResult(result *ImportResult, dryRun bool) error {
+	printImportWarnings(result.Warnings)
 	if dryRun {
 		fmt.Printf("✅ Dry Run Complete\n\n")
 		fmt.Printf("📊 Would Import:\n")
@@ -351,6 +542,7 @@ func printImportResult(result *ImportResult, dryRun bool) error {
 		fmt.Printf("   Branch ID: %s\n", result.BranchID)
 		fmt.Printf("   Documents Imported: %s\n", formatNumber(result.ImportedDocs))
 		fmt.Printf("   WAL Entries Created: %s\n", formatNumber(result.WALEntries))
+		fmt.Printf("   Indexes Recorded: %d\n", result.Indexes)
 		fmt.Printf("   Collections: %d\n", len(result.Collections))
 		fmt.Printf("   Duration: %v\n", result.Duration)
 		fmt.Printf("   LSN Range: %d - %d\n", result.StartLSN, result.EndLSN)
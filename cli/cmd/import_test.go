@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/argon-lab/argon/pkg/walcli"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImportProgressLine(t *testing.T) {
+	line := importProgressLine(walcli.ImportProgress{
+		Collection:       "orders",
+		Collections:      1,
+		TotalCollections: 4,
+		Documents:        2500,
+		TotalDocuments:   10000,
+		ETA:              90*time.Second + 400*time.Millisecond,
+	})
+	assert.Contains(t, line, "["+strings.Repeat("█", 7)+strings.Repeat("░", 23)+"]")
+	assert.Contains(t, line, " 25%")
+	assert.Contains(t, line, "2.5K/10.0K documents")
+	assert.Contains(t, line, "1/4 collections")
+	assert.Contains(t, line, "ETA 1m30s")
+	assert.True(t, strings.HasSuffix(line, "orders"))
+
+	// No estimate (an empty source) and a finished import: no bar
+	// progress, no ETA, no collection in flight.
+	assert.Contains(t, importProgressLine(walcli.ImportProgress{}), "["+strings.Repeat("░", 30)+"]   0%")
+	done := importProgressLine(walcli.ImportProgress{Collection: "z", Collections: 2, TotalCollections: 2, Documents: 10, TotalDocuments: 8})
+	assert.Contains(t, done, "100%")
+	assert.NotContains(t, done, "ETA")
+	assert.False(t, strings.HasSuffix(done, "z"))
+}
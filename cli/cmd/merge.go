@@ -175,7 +175,7 @@ func init() {
 		c.Flags().StringP("branch", "b", "", "Source branch to merge into its parent (required)")
 	}
 	mergeListCmd.Flags().StringP("project", "p", "", "Project name (required)")
-	mergeApplyCmd.Flags().String("strategy", "", "Conflict resolution: theirs (take the branch) or ours (keep the target)")
+	mergeApplyCmd.Flags().String("strategy", "", "Conflict resolution: theirs (take the branch), ours (keep the target) or abort (refuse)")
 
 	mergeCmd.AddCommand(mergePreviewCmd)
 	mergeCmd.AddCommand(mergeApplyCmd)
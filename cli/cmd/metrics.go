@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/argon-lab/argon/pkg/walcli"
 	"github.com/spf13/cobra"
@@ -32,12 +33,38 @@ var metricsCmd = &cobra.Command{
 		fmt.Printf("     Average Query: %v\n", snapshot.AvgQueryLatency)
 		fmt.Printf("     Average Materialization: %v\n", snapshot.AvgMaterialLatency)
 
+		if snapshot.ImageBytesRaw > 0 {
+			fmt.Printf("\n   Compression:\n")
+			fmt.Printf("     Images: %d bytes raw, %d bytes stored (%.2fx)\n",
+				snapshot.ImageBytesRaw, snapshot.ImageBytesStored, snapshot.CompressionRatio)
+		}
+
 		fmt.Printf("\n   System:\n")
 		fmt.Printf("     Current LSN: %d\n", snapshot.CurrentLSN)
 		fmt.Printf("     Active Branches: %d\n", snapshot.ActiveBranches)
 		fmt.Printf("     Active Projects: %d\n", snapshot.ActiveProjects)
 		fmt.Printf("     Last Operation: %v\n", snapshot.LastOperationTime.Format("2006-01-02 15:04:05"))
 
+		if len(snapshot.ProjectBranches) > 0 || len(snapshot.ProjectEntriesAppended) > 0 {
+			fmt.Printf("\n   Capacity (this process):\n")
+			projects := make(map[string]bool)
+			for id := range snapshot.ProjectBranches {
+				projects[id] = true
+			}
+			for id := range snapshot.ProjectEntriesAppended {
+				projects[id] = true
+			}
+			ids := make([]string, 0, len(projects))
+			for id := range projects {
+				ids = append(ids, id)
+			}
+			sort.Strings(ids)
+			for _, id := range ids {
+				fmt.Printf("     %s: %d branches, %d entries appended (%d/min)\n", id,
+					snapshot.ProjectBranches[id], snapshot.ProjectEntriesAppended[id], snapshot.ProjectEntriesPerMinute[id])
+			}
+		}
+
 		return nil
 	},
 }
@@ -1,7 +1,9 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
 
 	"github.com/argon-lab/argon/pkg/config"
 	"github.com/argon-lab/argon/pkg/walcli"
@@ -82,10 +84,68 @@ var projectsListCmd = &cobra.Command{
 	},
 }
 
+var projectsCloneCmd = &cobra.Command{
+	Use:   "clone",
+	Short: "Create a new project from another project's current state",
+	Long: `Clone copies the current state of the source project's main branch
+into a new project. History is not copied: the clone's main starts with one
+entry per document, so it materializes identically to the source at the
+moment of the clone and diverges independently from there.
+
+With --history the source main's history is copied as well: every entry
+is replayed into the clone in order, so the clone can be time-travelled
+like the source. A source whose early history garbage collection has
+already reclaimed cannot be cloned this way.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		source, _ := cmd.Flags().GetString("source")
+		name, _ := cmd.Flags().GetString("name")
+		asJSON, _ := cmd.Flags().GetBool("json")
+		history, _ := cmd.Flags().GetBool("history")
+
+		services, err := walcli.NewServices()
+		if err != nil {
+			return fmt.Errorf("failed to connect to system: %w", err)
+		}
+
+		if !asJSON {
+			fmt.Printf("Cloning project '%s' into '%s'...\n", source, name)
+		}
+		clone := services.CloneProject
+		if history {
+			clone = services.CloneProjectWithHistory
+		}
+		result, err := clone(source, name)
+		if err != nil {
+			return fmt.Errorf("failed to clone project: %w", err)
+		}
+
+		if asJSON {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(result)
+		}
+		fmt.Printf("   Copied %d document(s) from LSN %d\n", result.Documents, result.SourceLSN)
+		if history {
+			fmt.Printf("   Replayed %d history entries\n", result.Entries)
+		}
+		fmt.Printf("✅ Created project '%s'\n", result.ProjectName)
+		fmt.Printf("   Project ID: %s\n", result.ProjectID)
+		return nil
+	},
+}
+
 func init() {
+	projectsCloneCmd.Flags().String("source", "", "Project to clone (required)")
+	projectsCloneCmd.Flags().String("name", "", "Name for the new project (required)")
+	projectsCloneCmd.Flags().Bool("history", false, "Copy the source's history, not just its state")
+	projectsCloneCmd.Flags().Bool("json", false, "Output as JSON")
+	_ = projectsCloneCmd.MarkFlagRequired("source")
+	_ = projectsCloneCmd.MarkFlagRequired("name")
+
 	// Add subcommands
 	projectsCmd.AddCommand(projectsCreateCmd)
 	projectsCmd.AddCommand(projectsListCmd)
+	projectsCmd.AddCommand(projectsCloneCmd)
 
 	// Add to root command
 	rootCmd.AddCommand(projectsCmd)
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// TestProjectsCloneCommand drives "argon projects clone" against a
+// throwaway database.
+func TestProjectsCloneCommand(t *testing.T) {
+	services := testServices(t)
+	ctx := context.Background()
+
+	sourceName := "clone-src-cli"
+	cloneName := "clone-dst-cli"
+	source, err := services.Projects.CreateProject(sourceName)
+	require.NoError(t, err)
+
+	writer, err := services.WriterFor(sourceName, "main")
+	require.NoError(t, err)
+	_, err = writer.Put(ctx, "users", bson.M{"_id": "u1", "name": "ada"})
+	require.NoError(t, err)
+	_, err = writer.Put(ctx, "users", bson.M{"_id": "u2", "name": "grace"})
+	require.NoError(t, err)
+	_, err = writer.Put(ctx, "orders", bson.M{"_id": "o1", "total": int32(7)})
+	require.NoError(t, err)
+	_, _, err = writer.Delete(ctx, "users", "u2")
+	require.NoError(t, err)
+
+	rootCmd.SetArgs([]string{"projects", "clone", "--source", sourceName, "--name", cloneName})
+	require.NoError(t, rootCmd.Execute())
+
+	clone, err := services.Projects.GetProjectByName(cloneName)
+	require.NoError(t, err)
+	assert.NotEqual(t, source.ID, clone.ID)
+
+	sourceMain, err := services.Branches.GetBranch(source.ID, "main")
+	require.NoError(t, err)
+	cloneMain, err := services.Branches.GetBranch(clone.ID, "main")
+	require.NoError(t, err)
+	want, err := services.Materializer.MaterializeBranch(sourceMain)
+	require.NoError(t, err)
+	got, err := services.Materializer.MaterializeBranch(cloneMain)
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+
+	// The name is taken now: a second clone onto it is refused.
+	rootCmd.SetArgs([]string{"projects", "clone", "--source", sourceName, "--name", cloneName})
+	require.ErrorContains(t, rootCmd.Execute(), "already exists")
+}
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/argon-lab/argon/pkg/walcli"
@@ -15,27 +16,192 @@ var restoreCmd = &cobra.Command{
 RFC3339 time), or forks the historical state into a new branch without
 touching the original. Resets are recorded, never destructive: the
 discarded entries stay in the WAL for audit, and branches forked from
-the pre-reset head (or pins on it) keep reading the old state.`,
+the pre-reset head (or pins on it) keep reading the old state.
+
+Run without a subcommand to preview, confirm and apply in one step:
+
+  argon restore -p shop -b main --to-lsn 42            reset main to LSN 42
+  argon restore -p shop -b main --to-lsn 42 --dry-run  only show the preview
+  argon restore -p shop -b main --to-lsn 42 --create-branch before-42
+
+A reset prompts for confirmation unless --yes is given; --dry-run exits
+non-zero, so scripts cannot mistake a preview for a restore. A protected
+branch (see "argon branches protect") is reset only with --force.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if cmd.Flags().NFlag() == 0 {
+			return cmd.Help()
+		}
+		projectName, _ := cmd.Flags().GetString("project")
+		branchName, _ := cmd.Flags().GetString("branch")
+		newBranch, _ := cmd.Flags().GetString("create-branch")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		assumeYes, _ := cmd.Flags().GetBool("yes")
+		force, _ := cmd.Flags().GetBool("force")
+		if projectName == "" {
+			return fmt.Errorf("--project is required")
+		}
+		if !dryRun && newBranch == "" {
+			if err := guardDestructive(cmd, branchName); err != nil {
+				return err
+			}
+		}
+
+		services, err := walcli.NewServices()
+		if err != nil {
+			return fmt.Errorf("failed to connect: %w", err)
+		}
+		project, err := services.Projects.GetProjectByName(projectName)
+		if err != nil {
+			return fmt.Errorf("project %q not found: %w", projectName, err)
+		}
+		branchID, err := resolveBranch(services, projectName, branchName)
+		if err != nil {
+			return err
+		}
+		target, err := restoreTarget(cmd, services, branchID)
+		if err != nil {
+			return err
+		}
+		// Out-of-range targets fail here, with the reset's own messages,
+		// before anything is previewed or asked.
+		if err := services.Restore.ValidateRestore(branchID, target); err != nil {
+			cmd.SilenceUsage = true
+			return err
+		}
+		// So is a protected branch, before the prompt rather than after.
+		if newBranch == "" && !dryRun && !force {
+			if err := refuseProtected(services, branchID); err != nil {
+				cmd.SilenceUsage = true
+				return err
+			}
+		}
+
+		if newBranch != "" {
+			fmt.Printf("Fork:    new branch %q from %s at LSN %d\n", newBranch, branchName, target)
+			fmt.Printf("         %s itself is not changed.\n", branchName)
+		} else {
+			preview, err := services.Restore.GetRestorePreview(branchID, target)
+			if err != nil {
+				return err
+			}
+			printRestorePreview(preview.BranchName, preview.CurrentLSN, preview.TargetLSN,
+				preview.OperationsToDiscard, preview.AffectedCollections)
+		}
+
+		if dryRun {
+			cmd.SilenceUsage = true
+			if newBranch != "" {
+				return fmt.Errorf("dry run: branch %q was not created", newBranch)
+			}
+			return fmt.Errorf("dry run: branch %q was not reset", branchName)
+		}
+		if !assumeYes {
+			if err := requireTerminal("restore"); err != nil {
+				return err
+			}
+			if !askContinue() {
+				return fmt.Errorf("restore cancelled")
+			}
+		}
+
+		if newBranch != "" {
+			branch, err := services.Restore.CreateBranchAtLSN(project.ID, branchID, newBranch, target)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Created branch %q at LSN %d\n", branch.Name, branch.HeadLSN)
+			return nil
+		}
+		reset := services.Restore.ResetBranchToLSN
+		if force {
+			reset = services.Restore.ForceResetBranchToLSN
+		}
+		branch, err := reset(branchID, target)
+		if err != nil {
+			return err
+		}
+		printReset(branch.Name, branch.HeadLSN, branch.IsLive())
+		return nil
+	},
+}
+
+// refuseProtected fails when branchID is protected, pointing at --force.
+func refuseProtected(services *walcli.Services, branchID string) error {
+	branch, err := services.Branches.GetBranchByID(branchID)
+	if err != nil {
+		return err
+	}
+	if branch.Protected {
+		return fmt.Errorf("branch %q is protected: pass --force to reset it anyway", branch.Name)
+	}
+	return nil
+}
+
+// printRestorePreview reports what a reset of branch from head to target
+// would discard: discards operations, per collection in affected (printed
+// in name order).
+func printRestorePreview(branch string, head, target int64, discards int, affected map[string]int) {
+	fmt.Printf("Branch:  %s (head LSN %d)\n", branch, head)
+	fmt.Printf("Target:  LSN %d\n", target)
+	fmt.Printf("Discards %d operation(s)\n", discards)
+	printAffectedCollections(affected)
+	fmt.Println("Discarded entries stay in the WAL for audit; a reset is recorded, not destructive.")
 }
 
-// restoreTarget resolves the --lsn/--time flags to a concrete LSN.
+// printAffectedCollections lists per-collection discard counts by name.
+func printAffectedCollections(affected map[string]int) {
+	collections := make([]string, 0, len(affected))
+	for collection := range affected {
+		collections = append(collections, collection)
+	}
+	sort.Strings(collections)
+	for _, collection := range collections {
+		fmt.Printf("  %-24s %d\n", collection, affected[collection])
+	}
+}
+
+// printReset reports a completed reset, reminding that a checked-out
+// branch's physical database still holds the pre-reset state.
+func printReset(name string, head int64, live bool) {
+	fmt.Printf("Reset %s to LSN %d\n", name, head)
+	if live {
+		fmt.Println("The branch is checked out: run \"argon checkout\" again to refresh the physical database.")
+	}
+}
+
+// restoreTarget resolves the --lsn/--time/--tag flags to a concrete LSN.
+// The restore command itself spells --lsn as --to-lsn.
 func restoreTarget(cmd *cobra.Command, services *walcli.Services, branchID string) (int64, error) {
-	lsn, _ := cmd.Flags().GetInt64("lsn")
+	lsnFlag := "lsn"
+	if cmd.Flags().Lookup("to-lsn") != nil {
+		lsnFlag = "to-lsn"
+	}
+	lsn, _ := cmd.Flags().GetInt64(lsnFlag)
 	atTime, _ := cmd.Flags().GetString("time")
-	if (lsn == 0) == (atTime == "") {
-		return 0, fmt.Errorf("exactly one of --lsn or --time is required")
+	tag, _ := cmd.Flags().GetString("tag")
+	given := 0
+	for _, set := range []bool{lsn != 0, atTime != "", tag != ""} {
+		if set {
+			given++
+		}
+	}
+	if given != 1 {
+		return 0, fmt.Errorf("exactly one of --%s, --time or --tag is required", lsnFlag)
 	}
 	if lsn != 0 {
 		return lsn, nil
 	}
-	t, err := time.Parse(time.RFC3339, atTime)
-	if err != nil {
-		return 0, fmt.Errorf("invalid --time (want RFC3339, e.g. 2026-07-07T12:00:00Z): %w", err)
-	}
 	branch, err := services.Branches.GetBranchByID(branchID)
 	if err != nil {
 		return 0, err
 	}
+	if tag != "" {
+		return services.TagLSN(branch, tag)
+	}
+	t, err := time.Parse(time.RFC3339, atTime)
+	if err != nil {
+		return 0, fmt.Errorf("invalid --time (want RFC3339, e.g. 2026-07-07T12:00:00Z): %w", err)
+	}
 	target, err := services.TimeTravel.FindLSNAtTime(branch, t)
 	if err != nil {
 		return 0, fmt.Errorf("failed to resolve time to LSN: %w", err)
@@ -67,13 +233,8 @@ var restorePreviewCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
-		fmt.Printf("Branch:  %s (head LSN %d)\n", preview.BranchName, preview.CurrentLSN)
-		fmt.Printf("Target:  LSN %d\n", preview.TargetLSN)
-		fmt.Printf("Discards %d operation(s)\n", preview.OperationsToDiscard)
-		for collection, count := range preview.AffectedCollections {
-			fmt.Printf("  %-24s %d\n", collection, count)
-		}
-		fmt.Println("Discarded entries stay in the WAL for audit; a reset is recorded, not destructive.")
+		printRestorePreview(preview.BranchName, preview.CurrentLSN, preview.TargetLSN,
+			preview.OperationsToDiscard, preview.AffectedCollections)
 		return nil
 	},
 }
@@ -85,13 +246,16 @@ var restoreResetCmd = &cobra.Command{
 		projectName, _ := cmd.Flags().GetString("project")
 		branchName, _ := cmd.Flags().GetString("branch")
 		backup, _ := cmd.Flags().GetString("backup")
+		force, _ := cmd.Flags().GetBool("force")
+		if err := guardDestructive(cmd, branchName); err != nil {
+			return err
+		}
 
 		services, err := walcli.NewServices()
 		if err != nil {
 			return fmt.Errorf("failed to connect: %w", err)
 		}
-		project, err := services.Projects.GetProjectByName(projectName)
-		if err != nil {
+		if _, err := services.Projects.GetProjectByName(projectName); err != nil {
 			return fmt.Errorf("project %q not found: %w", projectName, err)
 		}
 		branchID, err := resolveBranch(services, projectName, branchName)
@@ -103,25 +267,35 @@ var restoreResetCmd = &cobra.Command{
 			return err
 		}
 
+		if !force {
+			if err := refuseProtected(services, branchID); err != nil {
+				return err
+			}
+		}
+
 		if backup != "" {
-			branch, err := services.Branches.GetBranchByID(branchID)
+			resetWithBackup := services.Restore.ResetBranchToLSNWithBackup
+			if force {
+				resetWithBackup = services.Restore.ForceResetBranchToLSNWithBackup
+			}
+			branch, backupBranch, err := resetWithBackup(branchID, target, backup)
 			if err != nil {
 				return err
 			}
-			if _, err := services.Restore.CreateBranchAtLSN(project.ID, branchID, backup, branch.HeadLSN); err != nil {
-				return fmt.Errorf("failed to create backup branch: %w", err)
-			}
-			fmt.Printf("Backup branch %q created at LSN %d\n", backup, branch.HeadLSN)
+			fmt.Printf("Backup branch %q created at LSN %d\n", backupBranch.Name, backupBranch.HeadLSN)
+			printReset(branch.Name, branch.HeadLSN, branch.IsLive())
+			return nil
 		}
 
-		branch, err := services.Restore.ResetBranchToLSN(branchID, target)
+		reset := services.Restore.ResetBranchToLSN
+		if force {
+			reset = services.Restore.ForceResetBranchToLSN
+		}
+		branch, err := reset(branchID, target)
 		if err != nil {
 			return err
 		}
-		fmt.Printf("Reset %s to LSN %d\n", branch.Name, branch.HeadLSN)
-		if branch.IsLive() {
-			fmt.Println("The branch is checked out: run \"argon checkout\" again to refresh the physical database.")
-		}
+		printReset(branch.Name, branch.HeadLSN, branch.IsLive())
 		return nil
 	},
 }
@@ -160,22 +334,95 @@ var restoreBranchCmd = &cobra.Command{
 	},
 }
 
+var restoreToTagCmd = &cobra.Command{
+	Use:   "to-tag",
+	Short: "Rewind the branch head to a tagged or pinned state",
+	Long: `Resolve a tag — the branch's own (see "argon tag") or a pin of that
+name on the branch (see "argon pin") — to its LSN and reset the branch
+there. With --dry-run the reset is only previewed and the command
+exits non-zero, so scripts cannot mistake a preview for a restore.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		projectName, _ := cmd.Flags().GetString("project")
+		branchName, _ := cmd.Flags().GetString("branch")
+		tag, _ := cmd.Flags().GetString("tag")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		force, _ := cmd.Flags().GetBool("force")
+		if !dryRun {
+			if err := guardDestructive(cmd, branchName); err != nil {
+				return err
+			}
+		}
+
+		services, err := walcli.NewServices()
+		if err != nil {
+			return fmt.Errorf("failed to connect: %w", err)
+		}
+		result, err := services.RestoreToTag(projectName, branchName, tag, dryRun, force)
+		if err != nil {
+			return err
+		}
+
+		preview := result.Preview
+		fmt.Printf("Branch:  %s (head LSN %d)\n", preview.BranchName, preview.CurrentLSN)
+		fmt.Printf("Target:  tag %q (LSN %d)\n", result.Tag, preview.TargetLSN)
+		fmt.Printf("Discards %d operation(s)\n", preview.OperationsToDiscard)
+		printAffectedCollections(preview.AffectedCollections)
+		if dryRun {
+			cmd.SilenceUsage = true
+			return fmt.Errorf("dry run: branch %q was not reset", preview.BranchName)
+		}
+		fmt.Printf("Reset %s to tag %q (LSN %d)\n", result.Branch.Name, result.Tag, result.Branch.HeadLSN)
+		if result.Branch.IsLive() {
+			fmt.Println("The branch is checked out: run \"argon checkout\" again to refresh the physical database.")
+		}
+		return nil
+	},
+}
+
 func addRestoreTargetFlags(cmd *cobra.Command) {
 	cmd.Flags().StringP("project", "p", "", "Project name (required)")
 	cmd.Flags().StringP("branch", "b", "main", "Branch to restore")
 	cmd.Flags().Int64("lsn", 0, "Target LSN")
 	cmd.Flags().String("time", "", "Target RFC3339 time (alternative to --lsn)")
+	cmd.Flags().String("tag", "", "Target tag or pin name (alternative to --lsn)")
 	_ = cmd.MarkFlagRequired("project")
 }
 
+// addForceFlag adds --force, which lets a reset rewind a protected branch.
+func addForceFlag(cmd *cobra.Command) {
+	cmd.Flags().Bool("force", false, "Reset the branch even if it is protected")
+}
+
 func init() {
+	restoreCmd.Flags().StringP("project", "p", "", "Project name (required)")
+	restoreCmd.Flags().StringP("branch", "b", "main", "Branch to restore")
+	restoreCmd.Flags().Int64("to-lsn", 0, "Target LSN")
+	restoreCmd.Flags().String("time", "", "Target RFC3339 time (alternative to --to-lsn)")
+	restoreCmd.Flags().String("tag", "", "Target tag or pin name (alternative to --to-lsn)")
+	restoreCmd.Flags().String("create-branch", "", "Fork the historical state into this new branch instead of resetting")
+	restoreCmd.Flags().Bool("dry-run", false, "Only show the preview (exits non-zero)")
+	restoreCmd.Flags().BoolP("yes", "y", false, "Skip the confirmation prompt (required when stdin is not a terminal)")
+	addConfirmFlag(restoreCmd)
+	addForceFlag(restoreCmd)
+
 	addRestoreTargetFlags(restorePreviewCmd)
 	addRestoreTargetFlags(restoreResetCmd)
 	restoreResetCmd.Flags().String("backup", "", "Fork this backup branch at the current head before resetting")
+	addConfirmFlag(restoreResetCmd)
+	addForceFlag(restoreResetCmd)
 	addRestoreTargetFlags(restoreBranchCmd)
 	restoreBranchCmd.Flags().String("as", "", "Name for the new branch (required)")
 	_ = restoreBranchCmd.MarkFlagRequired("as")
 
-	restoreCmd.AddCommand(restorePreviewCmd, restoreResetCmd, restoreBranchCmd)
+	restoreToTagCmd.Flags().StringP("project", "p", "", "Project name (required)")
+	restoreToTagCmd.Flags().StringP("branch", "b", "main", "Branch to restore")
+	restoreToTagCmd.Flags().String("tag", "", "Tag or pin name to restore to (required)")
+	restoreToTagCmd.Flags().Bool("dry-run", false, "Preview the reset without applying it (exits non-zero)")
+	_ = restoreToTagCmd.MarkFlagRequired("project")
+	_ = restoreToTagCmd.MarkFlagRequired("tag")
+	addConfirmFlag(restoreToTagCmd)
+	addForceFlag(restoreToTagCmd)
+
+	restoreCmd.AddCommand(restorePreviewCmd, restoreResetCmd, restoreBranchCmd, restoreToTagCmd)
 	rootCmd.AddCommand(restoreCmd)
 }
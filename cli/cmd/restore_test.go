@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/argon-lab/argon/pkg/walcli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// testServices points the commands at a metadata database of the test's
+// own on the deployment named by MONGODB_URI, dropped when the test ends,
+// and returns services on it.
+func testServices(t *testing.T) *walcli.Services {
+	t.Helper()
+	dbName := fmt.Sprintf("argon_cli_test_%d", time.Now().UnixNano())
+	t.Setenv("ARGON_WAL_DATABASE", dbName)
+	services, err := walcli.NewServices()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = services.Client.Database(dbName).Drop(context.Background())
+	})
+	return services
+}
+
+// TestRestoreToTagCommand drives "argon restore to-tag" against a
+// throwaway database.
+func TestRestoreToTagCommand(t *testing.T) {
+	services := testServices(t)
+	ctx := context.Background()
+
+	projectName := "restore-tag-cli"
+	project, err := services.Projects.CreateProject(projectName)
+	require.NoError(t, err)
+
+	writer, err := services.WriterFor(projectName, "main")
+	require.NoError(t, err)
+	_, err = writer.Put(ctx, "docs", bson.M{"_id": "d1"})
+	require.NoError(t, err)
+	main, err := services.Branches.GetBranch(project.ID, "main")
+	require.NoError(t, err)
+	tag, err := services.Pins.Create(project.ID, main.ID, "v1", 0, "")
+	require.NoError(t, err)
+	_, err = writer.Put(ctx, "docs", bson.M{"_id": "d2"})
+	require.NoError(t, err)
+	advanced, err := services.Branches.GetBranchByID(main.ID)
+	require.NoError(t, err)
+	require.Greater(t, advanced.HeadLSN, tag.LSN)
+
+	// A dry run exits non-zero and leaves the head where it was.
+	rootCmd.SetArgs([]string{"restore", "to-tag", "-p", projectName, "-b", "main", "--tag", "v1", "--dry-run"})
+	require.ErrorContains(t, rootCmd.Execute(), "dry run")
+	unchanged, err := services.Branches.GetBranchByID(main.ID)
+	require.NoError(t, err)
+	assert.Equal(t, advanced.HeadLSN, unchanged.HeadLSN)
+
+	require.NoError(t, restoreToTagCmd.Flags().Set("dry-run", "false"))
+	rootCmd.SetArgs([]string{"restore", "to-tag", "-p", projectName, "-b", "main", "--tag", "v1"})
+	require.NoError(t, rootCmd.Execute())
+	restored, err := services.Branches.GetBranchByID(main.ID)
+	require.NoError(t, err)
+	assert.Equal(t, tag.LSN, restored.HeadLSN)
+}
+
+// resetRestoreFlags clears the flags a restore test sets on the shared
+// command tree.
+func resetRestoreFlags(t *testing.T) {
+	t.Cleanup(func() {
+		for _, name := range []string{"to-lsn", "create-branch", "dry-run", "yes", "force"} {
+			_ = restoreCmd.Flags().Lookup(name).Value.Set(restoreCmd.Flags().Lookup(name).DefValue)
+		}
+	})
+}
+
+// TestRestoreCommandNeedsProject needs no deployment.
+func TestRestoreCommandNeedsProject(t *testing.T) {
+	resetRestoreFlags(t)
+	rootCmd.SetArgs([]string{"restore", "--to-lsn", "3"})
+	assert.ErrorContains(t, rootCmd.Execute(), "--project is required")
+}
+
+// TestRestoreCommand drives "argon restore --to-lsn" against a throwaway
+// database.
+func TestRestoreCommand(t *testing.T) {
+	resetRestoreFlags(t)
+	services := testServices(t)
+	ctx := context.Background()
+
+	projectName := "restore-cli"
+	project, err := services.Projects.CreateProject(projectName)
+	require.NoError(t, err)
+
+	writer, err := services.WriterFor(projectName, "main")
+	require.NoError(t, err)
+	target, err := writer.Put(ctx, "docs", bson.M{"_id": "d1"})
+	require.NoError(t, err)
+	head, err := writer.Put(ctx, "docs", bson.M{"_id": "d2"})
+	require.NoError(t, err)
+	main, err := services.Branches.GetBranch(project.ID, "main")
+	require.NoError(t, err)
+	require.Equal(t, head, main.HeadLSN)
+	restore := func(args ...string) error {
+		rootCmd.SetArgs(append([]string{"restore", "-p", projectName, "-b", "main"}, args...))
+		return rootCmd.Execute()
+	}
+
+	// Out of range fails with the reset's validation message.
+	require.ErrorContains(t, restore("--to-lsn", fmt.Sprint(head+10), "--yes"),
+		fmt.Sprintf("cannot restore to future LSN %d (current HEAD: %d)", head+10, head))
+
+	// A dry run previews, exits non-zero and leaves the head alone.
+	require.ErrorContains(t, restore("--to-lsn", fmt.Sprint(target), "--dry-run"), "dry run: branch \"main\" was not reset")
+	unchanged, err := services.Branches.GetBranchByID(main.ID)
+	require.NoError(t, err)
+	assert.Equal(t, head, unchanged.HeadLSN)
+
+	// --create-branch forks instead of resetting.
+	require.NoError(t, restoreCmd.Flags().Set("dry-run", "false"))
+	require.NoError(t, restore("--to-lsn", fmt.Sprint(target), "--create-branch", "before-d2", "--yes"))
+	fork, err := services.Branches.GetBranch(project.ID, "before-d2")
+	require.NoError(t, err)
+	assert.Equal(t, target, fork.HeadLSN)
+	state, err := services.Materializer.MaterializeBranch(fork)
+	require.NoError(t, err)
+	assert.Len(t, state["docs"], 1)
+	unchanged, err = services.Branches.GetBranchByID(main.ID)
+	require.NoError(t, err)
+	assert.Equal(t, head, unchanged.HeadLSN)
+
+	require.NoError(t, restoreCmd.Flags().Set("create-branch", ""))
+	require.NoError(t, restore("--to-lsn", fmt.Sprint(target), "--yes"))
+	restored, err := services.Branches.GetBranchByID(main.ID)
+	require.NoError(t, err)
+	assert.Equal(t, target, restored.HeadLSN)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/argon-lab/argon/pkg/walcli"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -48,15 +49,20 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&apiKey, "api-key", "", "Argon API key for authentication")
 	rootCmd.PersistentFlags().StringVar(&projectID, "project-id", "", "Argon project ID")
 	rootCmd.PersistentFlags().StringVarP(&output, "output", "o", "table", "Output format (json|yaml|table)")
+	rootCmd.PersistentFlags().Bool("safe", false, "Safe mode: destructive commands require --confirm (env ARGON_SAFE_MODE)")
 
 	// Bind flags to viper
 	_ = viper.BindPFlag("api-key", rootCmd.PersistentFlags().Lookup("api-key"))
 	_ = viper.BindPFlag("project-id", rootCmd.PersistentFlags().Lookup("project-id"))
 	_ = viper.BindPFlag("output", rootCmd.PersistentFlags().Lookup("output"))
+	_ = viper.BindPFlag("safe-mode", rootCmd.PersistentFlags().Lookup("safe"))
+	_ = viper.BindEnv("safe-mode", "ARGON_SAFE_MODE")
 }
 
 // initConfig reads in config file and ENV variables.
 func initConfig() {
+	walcli.SetupLogging(true)
+
 	if cfgFile != "" {
 		viper.SetConfigFile(cfgFile)
 	} else {
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Safe mode (--safe or ARGON_SAFE_MODE=true) is for shared environments:
+// commands that destroy or rewind a branch, or drop a database, refuse to
+// run unless --confirm repeats the name of the branch or database they act
+// on. Nothing is prompted, so the guard holds in scripts and CI as well.
+
+func safeMode() bool {
+	return viper.GetBool("safe-mode")
+}
+
+// guardDestructive refuses a destructive command in safe mode unless
+// --confirm names its target. Call it before connecting, so a refused
+// command has no side effects at all.
+func guardDestructive(cmd *cobra.Command, target string) error {
+	if !safeMode() {
+		return nil
+	}
+	if confirm, _ := cmd.Flags().GetString("confirm"); confirm == target {
+		return nil
+	}
+	cmd.SilenceUsage = true
+	return fmt.Errorf("safe mode: %q is destructive; pass --confirm %s to proceed", cmd.CommandPath(), target)
+}
+
+// requireTerminal refuses to prompt without a terminal: read from a pipe
+// or /dev/null the answer is empty, and a silent zero-exit cancel in a
+// container or CI job has bitten people. action names what --yes confirms.
+func requireTerminal(action string) error {
+	if stat, err := os.Stdin.Stat(); err == nil && (stat.Mode()&os.ModeCharDevice) == 0 {
+		return fmt.Errorf("stdin is not a terminal; pass --yes to confirm the %s", action)
+	}
+	return nil
+}
+
+// askContinue prompts "Continue? (y/N)" and reports whether the answer
+// was yes.
+func askContinue() bool {
+	fmt.Printf("   Continue? (y/N): ")
+	var response string
+	_, _ = fmt.Scanln(&response)
+	return response == "y" || response == "Y" || response == "yes"
+}
+
+func addConfirmFlag(cmd *cobra.Command) {
+	cmd.Flags().String("confirm", "", "Name of the branch (or database) acted on, repeated to confirm this destructive command in safe mode")
+}
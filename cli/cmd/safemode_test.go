@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// clearSafeFlag resets --safe to unset, so ARGON_SAFE_MODE applies again
+// (an explicitly set flag takes precedence over the environment).
+func clearSafeFlag() {
+	flag := rootCmd.PersistentFlags().Lookup("safe")
+	_ = flag.Value.Set("false")
+	flag.Changed = false
+}
+
+// resetSafeMode clears the flags a safe-mode test sets on the shared
+// command tree.
+func resetSafeMode(t *testing.T) {
+	t.Cleanup(func() {
+		clearSafeFlag()
+		_ = branchesDeleteCmd.Flags().Set("confirm", "")
+		_ = restoreResetCmd.Flags().Set("confirm", "")
+	})
+}
+
+// TestSafeModeRefusesBeforeConnecting needs no deployment: a refused
+// command fails before it connects.
+func TestSafeModeRefusesBeforeConnecting(t *testing.T) {
+	resetSafeMode(t)
+
+	rootCmd.SetArgs([]string{"--safe", "branches", "delete", "feature", "-p", "any"})
+	assert.ErrorContains(t, rootCmd.Execute(), "safe mode")
+
+	rootCmd.SetArgs([]string{"--safe", "restore", "reset", "-p", "any", "-b", "feature", "--lsn", "1", "--confirm", "main"})
+	assert.ErrorContains(t, rootCmd.Execute(), "--confirm feature", "confirming another branch does not count")
+
+	t.Setenv("ARGON_SAFE_MODE", "true")
+	clearSafeFlag()
+	require.NoError(t, restoreResetCmd.Flags().Set("confirm", ""))
+	rootCmd.SetArgs([]string{"branches", "delete", "feature", "-p", "any"})
+	assert.ErrorContains(t, rootCmd.Execute(), "safe mode", "ARGON_SAFE_MODE enables it too")
+}
+
+// TestSafeModeBranchDelete drives "argon branches delete" against a
+// throwaway database.
+func TestSafeModeBranchDelete(t *testing.T) {
+	resetSafeMode(t)
+	services := testServices(t)
+
+	projectName := "safe-mode-cli"
+	project, err := services.Projects.CreateProject(projectName)
+	require.NoError(t, err)
+	for _, name := range []string{"blocked", "open"} {
+		_, err := services.Branches.CreateBranch(project.ID, name, "")
+		require.NoError(t, err)
+	}
+
+	// Blocked in safe mode: the branch survives.
+	rootCmd.SetArgs([]string{"--safe", "branches", "delete", "blocked", "-p", projectName})
+	require.ErrorContains(t, rootCmd.Execute(), "safe mode")
+	_, err = services.Branches.GetBranch(project.ID, "blocked")
+	require.NoError(t, err)
+
+	// Confirmed in safe mode: deleted.
+	rootCmd.SetArgs([]string{"--safe", "branches", "delete", "blocked", "-p", projectName, "--confirm", "blocked"})
+	require.NoError(t, rootCmd.Execute())
+	_, err = services.Branches.GetBranch(project.ID, "blocked")
+	assert.Error(t, err)
+
+	// Outside safe mode no confirmation is needed.
+	clearSafeFlag()
+	require.NoError(t, branchesDeleteCmd.Flags().Set("confirm", ""))
+	rootCmd.SetArgs([]string{"branches", "delete", "open", "-p", projectName})
+	require.NoError(t, rootCmd.Execute())
+	_, err = services.Branches.GetBranch(project.ID, "open")
+	assert.Error(t, err)
+}
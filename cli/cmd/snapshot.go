@@ -101,6 +101,27 @@ var snapshotListCmd = &cobra.Command{
 	},
 }
 
+var snapshotSweepCmd = &cobra.Command{
+	Use:   "sweep",
+	Short: "Remove snapshots of branches nothing can read anymore",
+	Long: `Sweep removes snapshots left behind by deleted branches: branches
+whose record is gone, deletions whose cleanup failed, and force-deleted
+branches (a deleted project's main) that no live branch descends from.
+Chunks no remaining snapshot references are reclaimed with them.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		services, err := walcli.NewServices()
+		if err != nil {
+			return fmt.Errorf("failed to connect: %w", err)
+		}
+		branches, manifests, chunks, err := services.Snapshots.SweepOrphans(context.Background())
+		if err != nil {
+			return fmt.Errorf("sweep failed: %w", err)
+		}
+		fmt.Printf("Swept %d branch(es): %d snapshot(s), %d chunk(s) removed\n", branches, manifests, chunks)
+		return nil
+	},
+}
+
 func init() {
 	for _, c := range []*cobra.Command{snapshotCreateCmd, snapshotListCmd} {
 		c.Flags().StringP("project", "p", "", "Project name (required)")
@@ -108,5 +129,6 @@ func init() {
 	}
 	snapshotCmd.AddCommand(snapshotCreateCmd)
 	snapshotCmd.AddCommand(snapshotListCmd)
+	snapshotCmd.AddCommand(snapshotSweepCmd)
 	rootCmd.AddCommand(snapshotCmd)
 }
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/argon-lab/argon/pkg/walcli"
+	"github.com/spf13/cobra"
+)
+
+var tagCmd = &cobra.Command{
+	Use:   "tag",
+	Short: "Name points in a branch's history",
+	Long: `A tag names an LSN on a branch ("pre-migration"), so time travel and
+restores can address it by name. Tag names are unique per branch. Tags
+are lightweight: unlike pins (see "argon pin") they do not protect their
+history from garbage collection.`,
+}
+
+var tagCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Tag an LSN on a branch (default: the head)",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		projectName, _ := cmd.Flags().GetString("project")
+		branchName, _ := cmd.Flags().GetString("branch")
+		lsn, _ := cmd.Flags().GetInt64("lsn")
+
+		services, err := walcli.NewServices()
+		if err != nil {
+			return fmt.Errorf("failed to connect: %w", err)
+		}
+		branchID, err := resolveBranch(services, projectName, branchName)
+		if err != nil {
+			return err
+		}
+		tag, err := services.TimeTravel.CreateTag(branchID, args[0], lsn)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Tagged %s/%s at LSN %d as %q\n", projectName, branchName, tag.LSN, tag.Name)
+		return nil
+	},
+}
+
+var tagListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List a branch's tags",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		projectName, _ := cmd.Flags().GetString("project")
+		branchName, _ := cmd.Flags().GetString("branch")
+
+		services, err := walcli.NewServices()
+		if err != nil {
+			return fmt.Errorf("failed to connect: %w", err)
+		}
+		branchID, err := resolveBranch(services, projectName, branchName)
+		if err != nil {
+			return err
+		}
+		tags, err := services.TimeTravel.ListTags(branchID)
+		if err != nil {
+			return err
+		}
+		if len(tags) == 0 {
+			fmt.Println("No tags.")
+			return nil
+		}
+		fmt.Printf("%-24s %-10s %s\n", "NAME", "LSN", "CREATED")
+		for _, t := range tags {
+			fmt.Printf("%-24s %-10d %s\n", t.Name, t.LSN, t.CreatedAt.Format(time.RFC3339))
+		}
+		return nil
+	},
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{tagCreateCmd, tagListCmd} {
+		cmd.Flags().StringP("project", "p", "", "Project name (required)")
+		cmd.Flags().StringP("branch", "b", "main", "Branch name")
+		_ = cmd.MarkFlagRequired("project")
+	}
+	tagCreateCmd.Flags().Int64("lsn", 0, "LSN to tag (default: the branch head)")
+
+	tagCmd.AddCommand(tagCreateCmd, tagListCmd)
+	rootCmd.AddCommand(tagCmd)
+}
@@ -2,10 +2,14 @@ package cmd
 
 import (
 	"fmt"
+	"io"
+	"sort"
 	"strconv"
+	"time"
 
 	"github.com/argon-lab/argon/pkg/walcli"
 	"github.com/spf13/cobra"
+	"go.mongodb.org/mongo-driver/bson"
 )
 
 var timeTravelCmd = &cobra.Command{
@@ -54,6 +58,19 @@ var timeTravelInfoCmd = &cobra.Command{
 				info.LatestTime.Format("2006-01-02 15:04:05"))
 		}
 
+		if len(info.Collections) > 0 {
+			names := make([]string, 0, len(info.Collections))
+			for name := range info.Collections {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			fmt.Println("   Collections (earliest available):")
+			for _, name := range names {
+				c := info.Collections[name]
+				fmt.Printf("     %-24s LSN %d  %s\n", name, c.EarliestLSN, c.EarliestTime.Format("2006-01-02 15:04:05"))
+			}
+		}
+
 		fmt.Println()
 		fmt.Println("💡 Query any point in history:")
 		fmt.Printf("   argon time-travel query --project %s --branch %s --lsn %d\n",
@@ -66,23 +83,63 @@ var timeTravelInfoCmd = &cobra.Command{
 var timeTravelQueryCmd = &cobra.Command{
 	Use:   "query",
 	Short: "Query database state at a specific point in time",
+	Long: `Query a branch as of an LSN, a tag or pin, or a time (RFC3339; the latest
+entry at or before it). Without --collection, lists each collection's
+document count; with it, prints the documents, optionally narrowed by a
+MongoDB --filter.
+
+Example:
+  argon time-travel query -p shop -b main --time 2026-01-02T15:04:05Z -c orders --filter '{"status": "open"}' -o json`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		projectName, _ := cmd.Flags().GetString("project")
 		branchName, _ := cmd.Flags().GetString("branch")
 		lsnStr, _ := cmd.Flags().GetString("lsn")
+		tag, _ := cmd.Flags().GetString("tag")
+		timeStr, _ := cmd.Flags().GetString("time")
 		collection, _ := cmd.Flags().GetString("collection")
+		filterStr, _ := cmd.Flags().GetString("filter")
+		format, _ := cmd.Flags().GetString("output")
 
 		if projectName == "" || branchName == "" {
 			return fmt.Errorf("--project and --branch are required")
 		}
 
-		if lsnStr == "" {
-			return fmt.Errorf("--lsn is required for historical queries")
+		targets := 0
+		for _, set := range []bool{lsnStr != "", tag != "", timeStr != ""} {
+			if set {
+				targets++
+			}
+		}
+		if targets != 1 {
+			return fmt.Errorf("exactly one of --lsn, --tag or --time is required for historical queries")
 		}
 
-		lsn, err := strconv.ParseInt(lsnStr, 10, 64)
+		var lsn int64
+		if lsnStr != "" {
+			var err error
+			if lsn, err = strconv.ParseInt(lsnStr, 10, 64); err != nil || lsn < 0 {
+				return fmt.Errorf("invalid LSN %q: want a non-negative integer", lsnStr)
+			}
+		}
+		var at time.Time
+		if timeStr != "" {
+			var err error
+			if at, err = time.Parse(time.RFC3339, timeStr); err != nil {
+				return fmt.Errorf("invalid --time %q: want RFC3339, e.g. 2006-01-02T15:04:05Z", timeStr)
+			}
+		}
+		filter, err := walcli.ParseFilter(filterStr)
 		if err != nil {
-			return fmt.Errorf("invalid LSN: %w", err)
+			return err
+		}
+		if len(filter) > 0 && collection == "" {
+			return fmt.Errorf("--filter requires --collection")
+		}
+		if format == "" {
+			format = "table"
+		}
+		if format != "table" && format != "json" {
+			return fmt.Errorf("unsupported --output %q: use table or json", format)
 		}
 
 		services, err := walcli.NewServices()
@@ -98,33 +155,82 @@ var timeTravelQueryCmd = &cobra.Command{
 		if err != nil {
 			return fmt.Errorf("branch not found: %w", err)
 		}
+		switch {
+		case tag != "":
+			if lsn, err = services.TagLSN(branch, tag); err != nil {
+				return err
+			}
+		case timeStr != "":
+			if lsn, err = services.TimeTravel.FindLSNAtTime(branch, at); err != nil {
+				return fmt.Errorf("no state of '%s/%s' at %s: %w", projectName, branchName, timeStr, err)
+			}
+		}
 
-		fmt.Printf("🔍 Querying database state at LSN %d...\n\n", lsn)
-
-		if collection != "" {
-			// Query specific collection
-			state, err := services.TimeTravel.MaterializeAtLSN(branch, collection, lsn)
+		out := cmd.OutOrStdout()
+		if collection == "" {
+			state, err := services.TimeTravel.GetBranchStateAtLSN(branch, lsn)
 			if err != nil {
 				return fmt.Errorf("failed to query historical state: %w", err)
 			}
-
-			fmt.Printf("Collection '%s' had %d documents at LSN %d:\n", collection, len(state), lsn)
-			for docID := range state {
-				fmt.Printf("  📄 %s\n", docID)
+			counts := make(map[string]int, len(state))
+			for name, docs := range state {
+				counts[name] = len(docs)
 			}
-		} else {
-			// Show available collections
-			fmt.Println("Available collections at this point in time:")
-			fmt.Println("  (Use --collection flag to see documents)")
-			fmt.Println()
-			fmt.Printf("Example: argon time-travel query --project %s --branch %s --lsn %d --collection users\n",
-				projectName, branchName, lsn)
+			return writeCollectionCounts(out, format, lsn, counts)
 		}
 
-		return nil
+		state, err := services.TimeTravel.QueryAtLSN(branch, collection, filter, lsn)
+		if err != nil {
+			return fmt.Errorf("failed to query historical state: %w", err)
+		}
+		return writeHistoricalDocuments(out, format, collection, lsn, walcli.OrderDocuments(state, ""))
 	},
 }
 
+// writeCollectionCounts prints each collection's document count at lsn.
+func writeCollectionCounts(w io.Writer, format string, lsn int64, counts map[string]int) error {
+	if format == "json" {
+		return writeJSON(w, map[string]interface{}{"lsn": lsn, "collections": counts})
+	}
+	if len(counts) == 0 {
+		fmt.Fprintf(w, "No collections at LSN %d.\n", lsn)
+		return nil
+	}
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintf(w, "Collections at LSN %d:\n", lsn)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-24s %d documents\n", name, counts[name])
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "  (Use --collection to see documents)")
+	return nil
+}
+
+// writeHistoricalDocuments prints a collection's documents at lsn: JSON
+// for scripts, or one relaxed extended JSON line per document, which keeps
+// ObjectIDs and dates recognizable.
+func writeHistoricalDocuments(w io.Writer, format, collection string, lsn int64, docs []bson.M) error {
+	if format == "json" {
+		if docs == nil {
+			docs = []bson.M{}
+		}
+		return writeJSON(w, map[string]interface{}{"lsn": lsn, "collection": collection, "documents": docs})
+	}
+	fmt.Fprintf(w, "Collection '%s' had %d documents at LSN %d:\n", collection, len(docs), lsn)
+	for _, doc := range docs {
+		line, err := walcli.DocumentExtJSON(doc)
+		if err != nil {
+			return fmt.Errorf("failed to format document %v: %w", doc["_id"], err)
+		}
+		fmt.Fprintf(w, "  %s\n", line)
+	}
+	return nil
+}
+
 func init() {
 	// Add flags
 	timeTravelInfoCmd.Flags().StringP("project", "p", "", "Project name (required)")
@@ -134,11 +240,13 @@ func init() {
 
 	timeTravelQueryCmd.Flags().StringP("project", "p", "", "Project name (required)")
 	timeTravelQueryCmd.Flags().StringP("branch", "b", "", "Branch name (required)")
-	timeTravelQueryCmd.Flags().String("lsn", "", "LSN to query (required)")
+	timeTravelQueryCmd.Flags().String("lsn", "", "LSN to query")
+	timeTravelQueryCmd.Flags().String("tag", "", "Tag or pin name to query (alternative to --lsn)")
+	timeTravelQueryCmd.Flags().String("time", "", "Time to query, RFC3339 (alternative to --lsn)")
 	timeTravelQueryCmd.Flags().StringP("collection", "c", "", "Collection name")
+	timeTravelQueryCmd.Flags().String("filter", "", "MongoDB query filter as JSON (requires --collection)")
 	_ = timeTravelQueryCmd.MarkFlagRequired("project")
 	_ = timeTravelQueryCmd.MarkFlagRequired("branch")
-	_ = timeTravelQueryCmd.MarkFlagRequired("lsn")
 
 	// Add subcommands
 	timeTravelCmd.AddCommand(timeTravelInfoCmd)
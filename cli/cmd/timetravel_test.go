@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// resetTimeTravelFlags clears the query flags a test sets on the shared
+// command tree.
+func resetTimeTravelFlags(t *testing.T) {
+	t.Cleanup(func() {
+		for _, name := range []string{"lsn", "tag", "time", "collection", "filter"} {
+			_ = timeTravelQueryCmd.Flags().Set(name, "")
+		}
+		_ = rootCmd.PersistentFlags().Set("output", "table")
+		rootCmd.SetOut(nil)
+	})
+}
+
+// TestTimeTravelQueryFlags needs no deployment: bad flags fail before the
+// command connects.
+func TestTimeTravelQueryFlags(t *testing.T) {
+	cases := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"no target", nil, "exactly one of --lsn, --tag or --time"},
+		{"lsn and time", []string{"--lsn", "3", "--time", "2026-01-02T15:04:05Z"}, "exactly one of --lsn, --tag or --time"},
+		{"bad lsn", []string{"--lsn", "three"}, `invalid LSN "three"`},
+		{"negative lsn", []string{"--lsn", "-1"}, `invalid LSN "-1"`},
+		{"bad time", []string{"--time", "yesterday"}, `invalid --time "yesterday": want RFC3339`},
+		{"bad filter", []string{"--lsn", "3", "-c", "users", "--filter", "{status"}, "invalid filter JSON"},
+		{"filter without collection", []string{"--lsn", "3", "--filter", `{"a": 1}`}, "--filter requires --collection"},
+		{"bad output", []string{"--lsn", "3", "-o", "yaml"}, `unsupported --output "yaml"`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resetTimeTravelFlags(t)
+			rootCmd.SetArgs(append([]string{"time-travel", "query", "-p", "any", "-b", "main"}, tc.args...))
+			assert.ErrorContains(t, rootCmd.Execute(), tc.want)
+		})
+	}
+}
+
+// TestTimeTravelQueryOutput checks both output formats, without a
+// deployment.
+func TestTimeTravelQueryOutput(t *testing.T) {
+	docs := []bson.M{
+		{"_id": "u1", "name": "ada", "joined": time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{"_id": "u2", "name": "grace"},
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, writeHistoricalDocuments(&out, "table", "users", 7, docs))
+	assert.Equal(t, "Collection 'users' had 2 documents at LSN 7:\n"+
+		`  {"_id":"u1","joined":{"$date":"2026-01-02T00:00:00Z"},"name":"ada"}`+"\n"+
+		`  {"_id":"u2","name":"grace"}`+"\n", out.String())
+
+	out.Reset()
+	require.NoError(t, writeHistoricalDocuments(&out, "json", "users", 7, nil))
+	assert.JSONEq(t, `{"lsn": 7, "collection": "users", "documents": []}`, out.String())
+
+	out.Reset()
+	require.NoError(t, writeCollectionCounts(&out, "table", 7, map[string]int{"users": 2, "orders": 10}))
+	assert.Equal(t, "Collections at LSN 7:\n"+
+		"  orders                   10 documents\n"+
+		"  users                    2 documents\n\n"+
+		"  (Use --collection to see documents)\n", out.String())
+
+	out.Reset()
+	require.NoError(t, writeCollectionCounts(&out, "json", 7, map[string]int{"users": 2}))
+	assert.JSONEq(t, `{"lsn": 7, "collections": {"users": 2}}`, out.String())
+}
+
+// TestTimeTravelQueryCommand drives "argon time-travel query" against a
+// throwaway database.
+func TestTimeTravelQueryCommand(t *testing.T) {
+	resetTimeTravelFlags(t)
+	services := testServices(t)
+
+	projectName := "time-travel-cli"
+	_, err := services.Projects.CreateProject(projectName)
+	require.NoError(t, err)
+
+	writer, err := services.WriterFor(projectName, "main")
+	require.NoError(t, err)
+	lsn, err := writer.Put(context.Background(), "users", bson.M{"_id": "u1", "status": "open"})
+	require.NoError(t, err)
+	_, err = writer.Put(context.Background(), "users", bson.M{"_id": "u2", "status": "closed"})
+	require.NoError(t, err)
+	_, err = writer.Put(context.Background(), "users", bson.M{"_id": "u3", "status": "open"})
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	var result struct {
+		LSN       int64            `json:"lsn"`
+		Documents []map[string]any `json:"documents"`
+	}
+
+	rootCmd.SetArgs([]string{"time-travel", "query", "-p", projectName, "-b", "main",
+		"--lsn", fmt.Sprint(lsn), "-c", "users", "-o", "json"})
+	require.NoError(t, rootCmd.Execute())
+	require.NoError(t, json.Unmarshal(out.Bytes(), &result))
+	assert.Equal(t, lsn, result.LSN)
+	require.Len(t, result.Documents, 1)
+	assert.Equal(t, "u1", result.Documents[0]["_id"])
+
+	// --time resolves to the latest entry at or before it: the head.
+	out.Reset()
+	rootCmd.SetArgs([]string{"time-travel", "query", "-p", projectName, "-b", "main", "--lsn", "",
+		"--time", time.Now().UTC().Format(time.RFC3339Nano), "-c", "users", "--filter", `{"status": "open"}`, "-o", "json"})
+	require.NoError(t, rootCmd.Execute())
+	require.NoError(t, json.Unmarshal(out.Bytes(), &result))
+	assert.Greater(t, result.LSN, lsn)
+	require.Len(t, result.Documents, 2)
+	assert.Equal(t, "u3", result.Documents[1]["_id"])
+
+	// Beyond the head is an error, not an empty result.
+	rootCmd.SetArgs([]string{"time-travel", "query", "-p", projectName, "-b", "main", "--time", "",
+		"--filter", "", "--lsn", fmt.Sprint(result.LSN + 100), "-c", "users"})
+	assert.ErrorContains(t, rootCmd.Execute(), "beyond branch HEAD")
+}
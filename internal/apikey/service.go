@@ -0,0 +1,110 @@
+// Package apikey issues and verifies project-scoped API keys for the REST
+// control plane.
+//
+// A key is shown once, when it is created; only its SHA-256 hash is
+// stored (keys are 32 random bytes, so a slow password hash buys nothing).
+// Every key belongs to exactly one project: the server lets it address
+// that project's routes and nothing else.
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// keyPrefix marks Argon keys, so a leaked one is recognizable in logs and
+// secret scanners.
+const keyPrefix = "argon_"
+
+// ErrInvalidKey is returned for a key that was never issued.
+var ErrInvalidKey = errors.New("invalid API key")
+
+// Key is a stored API key. The plaintext is never persisted.
+type Key struct {
+	ID        string    `bson:"_id" json:"id"`
+	ProjectID string    `bson:"project_id" json:"project_id"`
+	Name      string    `bson:"name,omitempty" json:"name,omitempty"`
+	Hash      string    `bson:"hash" json:"-"`
+	Hint      string    `bson:"hint" json:"hint"` // first characters, for identification
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+}
+
+// Service manages API keys.
+type Service struct {
+	collection *mongo.Collection
+}
+
+// NewService creates the API key service and its indexes.
+func NewService(db *mongo.Database) (*Service, error) {
+	s := &Service{collection: db.Collection("api_keys")}
+	_, err := s.collection.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "hash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "project_id", Value: 1}},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create API key indexes: %w", err)
+	}
+	return s, nil
+}
+
+// Create issues a key for a project and returns its plaintext, which
+// cannot be recovered later.
+func (s *Service) Create(projectID, name string) (string, *Key, error) {
+	if projectID == "" {
+		return "", nil, errors.New("an API key must belong to a project")
+	}
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", nil, fmt.Errorf("failed to generate API key: %w", err)
+	}
+	plaintext := keyPrefix + hex.EncodeToString(raw)
+	key := &Key{
+		ID:        primitive.NewObjectID().Hex(),
+		ProjectID: projectID,
+		Name:      name,
+		Hash:      hashKey(plaintext),
+		Hint:      plaintext[:len(keyPrefix)+6],
+		CreatedAt: time.Now(),
+	}
+	if _, err := s.collection.InsertOne(context.Background(), key); err != nil {
+		return "", nil, fmt.Errorf("failed to store API key: %w", err)
+	}
+	return plaintext, key, nil
+}
+
+// Authenticate returns the key matching a plaintext, or ErrInvalidKey.
+func (s *Service) Authenticate(ctx context.Context, plaintext string) (*Key, error) {
+	if !strings.HasPrefix(plaintext, keyPrefix) {
+		return nil, ErrInvalidKey
+	}
+	var key Key
+	err := s.collection.FindOne(ctx, bson.M{"hash": hashKey(plaintext)}).Decode(&key)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrInvalidKey
+		}
+		return nil, err
+	}
+	return &key, nil
+}
+
+func hashKey(plaintext string) string {
+	sum := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,66 @@
+package wal
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/argon-lab/argon/internal/wal"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// BranchCreation is the audit record of how a branch came to be: where it
+// was forked from and the create_branch WAL entry that recorded it.
+type BranchCreation struct {
+	BranchID   string    `json:"branch_id"`
+	BranchName string    `json:"branch_name"`
+	ParentID   string    `json:"parent_id,omitempty"`
+	ParentName string    `json:"parent_name,omitempty"`
+	ForkLSN    int64     `json:"fork_lsn"`
+	CreatedAt  time.Time `json:"created_at"`
+	CreatedLSN int64     `json:"created_lsn"`
+	// Entry is nil when the branch predates CreatedLSN being recorded, or
+	// its creation entry is no longer in the WAL.
+	Entry *wal.Entry `json:"entry,omitempty"`
+}
+
+// GetBranchCreationEntry returns a branch's creation record. Deleted
+// branches, and deleted parents, still resolve: their history is what an
+// audit asks about.
+func (s *BranchService) GetBranchCreationEntry(branchID string) (*BranchCreation, error) {
+	branch, err := s.GetBranchByIDAny(branchID)
+	if err != nil {
+		return nil, err
+	}
+	creation := &BranchCreation{
+		BranchID:   branch.ID,
+		BranchName: branch.Name,
+		ParentID:   branch.ParentID,
+		ForkLSN:    branch.BaseLSN,
+		CreatedAt:  branch.CreatedAt,
+		CreatedLSN: branch.CreatedLSN,
+	}
+	if branch.ParentID != "" {
+		if parent, err := s.GetBranchByIDAny(branch.ParentID); err == nil {
+			creation.ParentName = parent.Name
+		}
+	}
+	if branch.CreatedLSN == 0 {
+		return creation, nil
+	}
+
+	entry, err := s.wal.GetEntry(branch.ProjectID, branch.CreatedLSN)
+	if err == mongo.ErrNoDocuments {
+		return creation, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read creation entry: %w", err)
+	}
+	// Guard against a CreatedLSN that does not point at this branch's
+	// creation, rather than presenting an unrelated entry as its origin.
+	// Older creation entries carry only the name, which a rename changes.
+	if entry.Operation == wal.OpCreateBranch &&
+		(entry.BranchID == branch.ID || entry.Metadata["branch_name"] == branch.Name) {
+		creation.Entry = entry
+	}
+	return creation, nil
+}
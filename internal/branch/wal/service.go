@@ -2,10 +2,11 @@ package wal
 
 import (
 	"context"
-	"errors"
 	"fmt"
+	"log/slog"
 	"time"
 
+	"github.com/argon-lab/argon/internal/logging"
 	"github.com/argon-lab/argon/internal/wal"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -29,6 +30,11 @@ type BranchService struct {
 	// refuses the deletion. Used to keep pinned branches alive without
 	// this package depending on the pin package.
 	deleteGuard func(branchID string) error
+
+	// onRename runs after RenameBranch commits. Used to update records
+	// that denormalize the branch name (pins) without this package
+	// depending on theirs.
+	onRename func(branchID, newName string)
 }
 
 // SetDeleteGuard registers a check that can refuse DeleteBranch.
@@ -42,6 +48,12 @@ func (s *BranchService) SetDeleteHook(hook func(branchID string)) {
 	s.onDelete = hook
 }
 
+// SetRenameHook registers a callback invoked after a successful
+// RenameBranch.
+func (s *BranchService) SetRenameHook(hook func(branchID, newName string)) {
+	s.onRename = hook
+}
+
 // NewBranchService creates a new WAL branch service
 func NewBranchService(db *mongo.Database, walService *wal.Service) (*BranchService, error) {
 	s := &BranchService{
@@ -83,7 +95,7 @@ func (s *BranchService) CreateBranch(projectID, name, parentID string) (*wal.Bra
 	// Check if branch already exists
 	existing, _ := s.GetBranch(projectID, name)
 	if existing != nil {
-		return nil, errors.New("branch already exists")
+		return nil, wal.ErrBranchExists
 	}
 
 	// Get parent branch if specified
@@ -141,6 +153,14 @@ func (s *BranchService) CreateBranch(projectID, name, parentID string) (*wal.Bra
 	if err != nil {
 		return nil, fmt.Errorf("failed to create branch: %w", err)
 	}
+	s.refreshBranchGauge(projectID)
+	slog.Info("branch created",
+		slog.String(logging.KeyProjectID, projectID),
+		slog.String(logging.KeyBranchID, branchID),
+		slog.String("branch", name),
+		slog.String("parent_id", parentID),
+		slog.Int64(logging.KeyLSN, lsn),
+		slog.String(logging.KeyOperation, string(wal.OpCreateBranch)))
 
 	return branch, nil
 }
@@ -158,7 +178,7 @@ func (s *BranchService) GetBranch(projectID, name string) (*wal.Branch, error) {
 
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return nil, errors.New("branch not found")
+			return nil, wal.ErrBranchNotFound
 		}
 		return nil, err
 	}
@@ -177,6 +197,9 @@ func (s *BranchService) GetBranchByID(branchID string) (*wal.Branch, error) {
 	}).Decode(&branch)
 
 	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, wal.ErrBranchNotFound
+		}
 		return nil, err
 	}
 
@@ -192,6 +215,9 @@ func (s *BranchService) GetBranchByIDAny(branchID string) (*wal.Branch, error) {
 
 	err := s.collection.FindOne(ctx, bson.M{"_id": branchID}).Decode(&branch)
 	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, wal.ErrBranchNotFound
+		}
 		return nil, err
 	}
 
@@ -209,6 +235,28 @@ func (s *BranchService) SetExpiry(branchID string, expiresAt *time.Time) error {
 	return err
 }
 
+// SetProtected marks a live branch read-only, or writable again. Writers
+// advance the head only while the flag is clear (see AdvanceWriterHead),
+// so the change applies to those already open.
+func (s *BranchService) SetProtected(branchID string, protected bool) error {
+	ctx := context.Background()
+	update := bson.M{"$unset": bson.M{"protected": ""}}
+	if protected {
+		update = bson.M{"$set": bson.M{"protected": true}}
+	}
+	result, err := s.collection.UpdateOne(ctx, bson.M{"_id": branchID, "is_deleted": false}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return wal.ErrBranchNotFound
+	}
+	slog.Info("branch protection changed",
+		slog.String(logging.KeyBranchID, branchID),
+		slog.Bool("protected", protected))
+	return nil
+}
+
 // SetCheckoutState records (or clears, with empty values) a branch's
 // physical-database checkout.
 func (s *BranchService) SetCheckoutState(branchID, physicalDB, state string, checkedOutLSN int64) error {
@@ -262,6 +310,35 @@ func (s *BranchService) ListBranches(projectID string) ([]*wal.Branch, error) {
 	return branches, nil
 }
 
+// ListBranchesPaged lists one page of a project's branches, ordered by
+// name, along with the total number of branches.
+func (s *BranchService) ListBranchesPaged(projectID string, limit, offset int64) ([]*wal.Branch, int64, error) {
+	ctx := context.Background()
+	filter := bson.M{
+		"project_id": projectID,
+		"is_deleted": false,
+	}
+	total, err := s.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "name", Value: 1}, {Key: "_id", Value: 1}}).
+		SetSkip(offset).
+		SetLimit(limit)
+	cursor, err := s.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() { _ = cursor.Close(ctx) }()
+
+	branches := []*wal.Branch{}
+	if err := cursor.All(ctx, &branches); err != nil {
+		return nil, 0, err
+	}
+	return branches, total, nil
+}
+
 // ListBranchesAny lists all branches for a project including deleted ones.
 // Deleted branches can still anchor live descendants' history, so tools
 // that walk every timeline (e.g. WAL migration) must see them.
@@ -293,7 +370,7 @@ func (s *BranchService) DeleteBranch(projectID, name string) error {
 
 	// Validate it's not the main branch (unless force is specified)
 	if branch.Name == "main" {
-		return errors.New("cannot delete main branch")
+		return wal.ErrMainBranch
 	}
 
 	// Check for child branches
@@ -306,7 +383,7 @@ func (s *BranchService) DeleteBranch(projectID, name string) error {
 		return err
 	}
 	if childCount > 0 {
-		return errors.New("cannot delete branch with active children")
+		return wal.ErrBranchHasChildren
 	}
 
 	if s.deleteGuard != nil {
@@ -326,7 +403,7 @@ func (s *BranchService) DeleteBranch(projectID, name string) error {
 		},
 	}
 
-	_, err = s.wal.Append(entry)
+	lsn, err := s.wal.Append(entry)
 	if err != nil {
 		return fmt.Errorf("failed to append WAL entry: %w", err)
 	}
@@ -339,6 +416,13 @@ func (s *BranchService) DeleteBranch(projectID, name string) error {
 	if err != nil {
 		return err
 	}
+	s.refreshBranchGauge(projectID)
+	slog.Info("branch deleted",
+		slog.String(logging.KeyProjectID, projectID),
+		slog.String(logging.KeyBranchID, branch.ID),
+		slog.String("branch", name),
+		slog.Int64(logging.KeyLSN, lsn),
+		slog.String(logging.KeyOperation, string(wal.OpDeleteBranch)))
 
 	// Safe because DeleteBranch refuses branches with children: nothing
 	// can reach this branch's snapshots through an ancestry chain anymore.
@@ -349,20 +433,142 @@ func (s *BranchService) DeleteBranch(projectID, name string) error {
 	return nil
 }
 
-// UpdateBranchHead advances the head LSN of a branch. $max keeps the head
-// monotonic under concurrent writers: with $set, a writer holding a smaller
-// LSN could land after one holding a larger LSN and move the head backwards,
-// hiding already-written entries from materialization. To move a head
-// backwards deliberately (restore/reset), use SetBranchHead.
-func (s *BranchService) UpdateBranchHead(branchID string, newLSN int64) error {
+// RenameBranch renames a live branch. Branch IDs are what history and
+// snapshots key on, so only the branch record and name-keyed lookups
+// change. Main can be neither renamed nor replaced. A name already taken
+// in the project, including by a deleted branch still holding it, is
+// ErrBranchExists; the unique (project, name) index settles concurrent
+// renames to the same name, so exactly one of them wins.
+func (s *BranchService) RenameBranch(projectID, oldName, newName string) (*wal.Branch, error) {
 	ctx := context.Background()
-	_, err := s.collection.UpdateOne(ctx,
-		bson.M{"_id": branchID},
-		bson.M{"$max": bson.M{"head_lsn": newLSN}},
+	if newName == "" {
+		return nil, fmt.Errorf("new branch name must not be empty")
+	}
+	if oldName == "main" || newName == "main" {
+		return nil, wal.ErrMainBranchRename
+	}
+
+	branch, err := s.GetBranch(projectID, oldName)
+	if err != nil {
+		return nil, err
+	}
+	if newName == oldName {
+		return branch, nil
+	}
+	if existing, _ := s.GetBranch(projectID, newName); existing != nil {
+		return nil, wal.ErrBranchExists
+	}
+
+	// Renaming before logging keeps a lost race out of the WAL: the
+	// loser's update fails on the unique index and appends nothing.
+	result, err := s.collection.UpdateOne(ctx,
+		bson.M{"_id": branch.ID, "name": oldName, "is_deleted": false},
+		bson.M{"$set": bson.M{"name": newName}},
 	)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, wal.ErrBranchExists
+		}
+		return nil, fmt.Errorf("failed to rename branch: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		// Deleted or renamed since the lookup.
+		return nil, wal.ErrBranchNotFound
+	}
+
+	entry := &wal.Entry{
+		ProjectID: projectID,
+		BranchID:  branch.ID,
+		Operation: wal.OpRenameBranch,
+		Metadata: map[string]interface{}{
+			"old_name": oldName,
+			"new_name": newName,
+		},
+	}
+	lsn, err := s.wal.Append(entry)
+	if err != nil {
+		// Put the old name back so the rename is all or nothing.
+		_, _ = s.collection.UpdateOne(ctx,
+			bson.M{"_id": branch.ID, "name": newName},
+			bson.M{"$set": bson.M{"name": oldName}},
+		)
+		return nil, fmt.Errorf("failed to append WAL entry: %w", err)
+	}
+	slog.Info("branch renamed",
+		slog.String(logging.KeyProjectID, projectID),
+		slog.String(logging.KeyBranchID, branch.ID),
+		slog.String("old_name", oldName),
+		slog.String("branch", newName),
+		slog.Int64(logging.KeyLSN, lsn),
+		slog.String(logging.KeyOperation, string(wal.OpRenameBranch)))
+
+	if s.onRename != nil {
+		s.onRename(branch.ID, newName)
+	}
+
+	branch.Name = newName
+	return branch, nil
+}
+
+// UpdateBranchHead advances the head LSN of a branch and stamps
+// LastActivityAt with at, the timestamp of the entry at newLSN (a zero at
+// leaves it alone). Matching only heads below newLSN keeps the head
+// monotonic under concurrent writers: an unconditional $set from a writer
+// holding a smaller LSN could land after one holding a larger LSN and move
+// the head backwards, hiding already-written entries from materialization.
+// It also leaves the activity time alone when the head does not move. To
+// move a head backwards deliberately (restore/reset), use SetBranchHead.
+func (s *BranchService) UpdateBranchHead(branchID string, newLSN int64, at time.Time) error {
+	_, err := s.advanceHead(bson.M{"_id": branchID}, newLSN, at)
 	return err
 }
 
+// AdvanceWriterHead is UpdateBranchHead for Argon's own write path: the
+// head only moves while the branch is neither protected nor checked out,
+// tested in the same update, so writers need not re-read the branch before
+// every write. If the branch was closed before the head reached newLSN,
+// it returns an error wrapping ErrBranchProtected or ErrBranchCheckedOut
+// and the entries up to newLSN stay unpublished.
+func (s *BranchService) AdvanceWriterHead(branchID string, newLSN int64, at time.Time) error {
+	for {
+		res, err := s.advanceHead(bson.M{
+			"_id":       branchID,
+			"protected": bson.M{"$ne": true},
+			"state":     bson.M{"$ne": wal.BranchStateLive},
+		}, newLSN, at)
+		if err != nil {
+			return err
+		}
+		if res.MatchedCount > 0 {
+			return nil
+		}
+		// Either another writer moved the head past newLSN or the branch
+		// is closed; only the latter is a refusal.
+		branch, err := s.GetBranchByID(branchID)
+		if err != nil {
+			return err
+		}
+		switch {
+		case branch.HeadLSN >= newLSN:
+			return nil
+		case branch.Protected:
+			return fmt.Errorf("%w: %s is read-only; unprotect it to write", wal.ErrBranchProtected, branch.Name)
+		case branch.IsLive():
+			return fmt.Errorf("%w: %s is live in %s; write through a MongoDB driver instead", wal.ErrBranchCheckedOut, branch.Name, branch.PhysicalDB)
+		}
+		// Reopened in between: try again.
+	}
+}
+
+func (s *BranchService) advanceHead(filter bson.M, newLSN int64, at time.Time) (*mongo.UpdateResult, error) {
+	filter["head_lsn"] = bson.M{"$lt": newLSN}
+	set := bson.M{"head_lsn": newLSN}
+	if !at.IsZero() {
+		set["last_activity_at"] = at
+	}
+	return s.collection.UpdateOne(context.Background(), filter, bson.M{"$set": set})
+}
+
 // SetBranchHead sets the head LSN of a branch unconditionally. This is the
 // restore/reset path: unlike UpdateBranchHead it can move a head backwards,
 // so it must only be used when discarding history is the intent.
@@ -427,8 +633,58 @@ func (s *BranchService) ForceDeleteBranch(projectID, name string) error {
 		bson.M{"_id": branch.ID},
 		bson.M{"$set": bson.M{"is_deleted": true}},
 	)
+	if err != nil {
+		return err
+	}
+	s.refreshBranchGauge(projectID)
 
-	return err
+	return nil
+}
+
+// refreshBranchGauge recounts a project's active branches into the WAL
+// metrics. Best effort: a failed count leaves the gauge stale rather than
+// failing the branch operation that already succeeded.
+func (s *BranchService) refreshBranchGauge(projectID string) {
+	count, err := s.collection.CountDocuments(context.Background(), bson.M{
+		"project_id": projectID,
+		"is_deleted": false,
+	})
+	if err != nil {
+		return
+	}
+	s.wal.Metrics().SetProjectBranches(projectID, int(count))
+}
+
+// PurgeBranch removes a branch record outright, leaving no deleted-branch
+// tombstone to hold its name. Only for a branch that never became usable,
+// such as a fork whose seeding failed; regular deletion keeps the record.
+// The WAL keeps its create_branch entry, so a purged delete_branch entry
+// is appended to close it.
+func (s *BranchService) PurgeBranch(branchID string) error {
+	var purged wal.Branch
+	err := s.collection.FindOneAndDelete(context.Background(), bson.M{"_id": branchID}).Decode(&purged)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	s.refreshBranchGauge(purged.ProjectID)
+
+	_, err = s.wal.Append(&wal.Entry{
+		ProjectID: purged.ProjectID,
+		BranchID:  purged.Name,
+		Operation: wal.OpDeleteBranch,
+		Metadata: map[string]interface{}{
+			"branch_id": purged.ID,
+			"final_lsn": purged.HeadLSN,
+			"purged":    true,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to append WAL entry: %w", err)
+	}
+	return nil
 }
 
 // CreateBranchWithData creates a branch with specific metadata
@@ -438,7 +694,7 @@ func (s *BranchService) CreateBranchWithData(branch *wal.Branch) error {
 	// Check if branch already exists
 	existing, _ := s.GetBranch(branch.ProjectID, branch.Name)
 	if existing != nil {
-		return errors.New("branch already exists")
+		return wal.ErrBranchExists
 	}
 
 	// Create WAL entry for branch creation
@@ -453,13 +709,14 @@ func (s *BranchService) CreateBranchWithData(branch *wal.Branch) error {
 		},
 	}
 
-	_, err := s.wal.Append(entry)
+	lsn, err := s.wal.Append(entry)
 	if err != nil {
 		return fmt.Errorf("failed to append WAL entry: %w", err)
 	}
 
 	// Set default values
 	branch.IsDeleted = false
+	branch.CreatedLSN = lsn
 	if branch.CreatedAt.IsZero() {
 		branch.CreatedAt = time.Now()
 	}
@@ -469,6 +726,7 @@ func (s *BranchService) CreateBranchWithData(branch *wal.Branch) error {
 	if err != nil {
 		return fmt.Errorf("failed to create branch: %w", err)
 	}
+	s.refreshBranchGauge(branch.ProjectID)
 
 	return nil
 }
@@ -0,0 +1,112 @@
+package wal
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/argon-lab/argon/internal/wal"
+)
+
+// BranchNode is one branch in a project's branch tree. ForkLSN is the
+// parent LSN the branch started from (its BaseLSN); roots fork from 0.
+type BranchNode struct {
+	ID        string        `json:"id"`
+	Name      string        `json:"name"`
+	ParentID  string        `json:"parent_id,omitempty"`
+	ForkLSN   int64         `json:"fork_lsn"`
+	HeadLSN   int64         `json:"head_lsn"`
+	CreatedAt time.Time     `json:"created_at"`
+	State     string        `json:"state,omitempty"`
+	ExpiresAt *time.Time    `json:"expires_at,omitempty"`
+	Orphaned  bool          `json:"orphaned,omitempty"`
+	Children  []*BranchNode `json:"children"`
+}
+
+// GetBranchTree returns a project's live branches arranged by parentage,
+// roots first. A branch whose parent is not live (force-deleted, or a
+// dangling pointer) cannot hang under it, so it is surfaced as an extra
+// root marked Orphaned rather than dropped: its history is still readable
+// through the WAL. Siblings are ordered by creation time. A branch that
+// is its own ancestor could never be placed, so it fails the whole tree
+// with ErrBranchCycle naming the branch.
+func (s *BranchService) GetBranchTree(projectID string) ([]*BranchNode, error) {
+	branches, err := s.ListBranches(projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make(map[string]*BranchNode, len(branches))
+	for _, b := range branches {
+		nodes[b.ID] = newBranchNode(b)
+	}
+	if err := checkAncestry(branches, nodes); err != nil {
+		return nil, err
+	}
+
+	roots := make([]*BranchNode, 0)
+	for _, b := range branches {
+		node := nodes[b.ID]
+		if b.ParentID == "" {
+			roots = append(roots, node)
+			continue
+		}
+		parent, ok := nodes[b.ParentID]
+		if !ok {
+			node.Orphaned = true
+			roots = append(roots, node)
+			continue
+		}
+		parent.Children = append(parent.Children, node)
+	}
+
+	sortBranchNodes(roots)
+	return roots, nil
+}
+
+// checkAncestry walks every branch's parent chain up to a root or an
+// orphan, and reports the first branch met twice on one walk.
+func checkAncestry(branches []*wal.Branch, nodes map[string]*BranchNode) error {
+	placed := make(map[string]bool, len(nodes))
+	for _, b := range branches {
+		path := make(map[string]bool)
+		for node := nodes[b.ID]; node != nil && !placed[node.ID]; node = nodes[node.ParentID] {
+			if path[node.ID] {
+				return fmt.Errorf("%w: branch %s is its own ancestor", wal.ErrBranchCycle, node.Name)
+			}
+			path[node.ID] = true
+		}
+		for id := range path {
+			placed[id] = true
+		}
+	}
+	return nil
+}
+
+func newBranchNode(b *wal.Branch) *BranchNode {
+	return &BranchNode{
+		ID:        b.ID,
+		Name:      b.Name,
+		ParentID:  b.ParentID,
+		ForkLSN:   b.BaseLSN,
+		HeadLSN:   b.HeadLSN,
+		CreatedAt: b.CreatedAt,
+		State:     b.State,
+		ExpiresAt: b.ExpiresAt,
+		Children:  []*BranchNode{},
+	}
+}
+
+// sortBranchNodes orders every level of the tree by creation time, with
+// the name as a tiebreak so the output is stable for equal timestamps.
+func sortBranchNodes(nodes []*BranchNode) {
+	sort.Slice(nodes, func(i, j int) bool {
+		if !nodes[i].CreatedAt.Equal(nodes[j].CreatedAt) {
+			return nodes[i].CreatedAt.Before(nodes[j].CreatedAt)
+		}
+		return nodes[i].Name < nodes[j].Name
+	})
+	for _, n := range nodes {
+		sortBranchNodes(n.Children)
+	}
+}
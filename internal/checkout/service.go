@@ -49,7 +49,7 @@ func NewService(client *mongo.Client, metaDB *mongo.Database, branches *branchwa
 // are globally unique, so the project doesn't need to appear; the fixed
 // prefix keeps Argon-owned databases recognizable and clear of user names.
 func PhysicalDBName(branchID string) string {
-	return "argon_br_" + branchID
+	return wal.PhysicalDBPrefix + branchID
 }
 
 // Info describes a completed checkout.
@@ -0,0 +1,6 @@
+package compact
+
+// SetBeforeAppendHook exposes beforeAppend to the external test package.
+func (s *Service) SetBeforeAppendHook(hook func()) {
+	s.beforeAppend = hook
+}
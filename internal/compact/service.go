@@ -0,0 +1,162 @@
+// Package compact collapses the history of hot documents.
+//
+// Every put carries a full post-image, so a document updated a million
+// times costs a million entries to replay. Compacting it appends one
+// synthetic put of its current state at a new LSN and marks the branch's
+// earlier entries for that document as superseded by it. Reads whose bound
+// reaches the compaction skip the superseded entries; reads below it —
+// time travel to an earlier LSN, children forked before the compaction,
+// pins — still replay them, so no historical answer changes. Superseded
+// entries become eligible for reclamation once no such reader can exist
+// (see the gc package).
+package compact
+
+import (
+	"errors"
+	"fmt"
+
+	branchwal "github.com/argon-lab/argon/internal/branch/wal"
+	"github.com/argon-lab/argon/internal/materializer"
+	"github.com/argon-lab/argon/internal/wal"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Result describes one compaction.
+type Result struct {
+	BranchID   string `json:"branch_id"`
+	Collection string `json:"collection"`
+	DocumentID string `json:"document_id"`
+	// LSN is the synthetic put carrying the document's current state.
+	LSN int64 `json:"lsn"`
+	// Superseded is how many of the branch's entries it replaced.
+	Superseded int64 `json:"superseded"`
+}
+
+// maxAttempts bounds how often a compaction restarts because the document
+// was written while it ran.
+const maxAttempts = 3
+
+// errConcurrentWrite aborts one compaction attempt whose image went stale.
+var errConcurrentWrite = errors.New("document was written during compaction")
+
+// Service compacts document histories.
+type Service struct {
+	wal          *wal.Service
+	branches     *branchwal.BranchService
+	materializer *materializer.Service
+	// beforeAppend runs between reading a document's current state and
+	// appending its compaction entry, the window a concurrent write can
+	// land in. Set only by the package's tests.
+	beforeAppend func()
+}
+
+// NewService creates a new compaction service
+func NewService(walService *wal.Service, branches *branchwal.BranchService, mat *materializer.Service) *Service {
+	return &Service{wal: walService, branches: branches, materializer: mat}
+}
+
+// CompactDocument replaces a document's history on the branch, up to the
+// head, with a single put of its current state. Only the branch's own
+// entries are superseded: inherited history belongs to ancestors, whose
+// other readers still need it.
+//
+// The branch is re-read rather than trusted, and a write to the document
+// that lands between the read and the append restarts the compaction:
+// publishing the stale image would overwrite that write.
+func (s *Service) CompactDocument(branch *wal.Branch, collection, documentID string) (*Result, error) {
+	if collection == "" || documentID == "" {
+		return nil, fmt.Errorf("compaction requires a collection and document ID")
+	}
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		res, err := s.compactOnce(branch.ID, collection, documentID)
+		if errors.Is(err, errConcurrentWrite) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if res.LSN > branch.HeadLSN {
+			branch.HeadLSN = res.LSN
+		}
+		return res, nil
+	}
+	return nil, fmt.Errorf("document %s/%s kept changing during compaction (%d attempts): retry when it is quieter",
+		collection, documentID, maxAttempts)
+}
+
+func (s *Service) compactOnce(branchID, collection, documentID string) (*Result, error) {
+	branch, err := s.branches.GetBranchByID(branchID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get branch: %w", err)
+	}
+	if branch.IsLive() {
+		// The ingester owns a checked-out branch's head.
+		return nil, fmt.Errorf("branch %s is checked out as %s: release it before compacting", branch.Name, branch.PhysicalDB)
+	}
+	readLSN := branch.HeadLSN
+
+	current, err := s.materializer.MaterializeDocument(branch, collection, documentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to materialize %s/%s: %w", collection, documentID, err)
+	}
+	if current == nil {
+		return nil, fmt.Errorf("document %s/%s does not exist at HEAD: nothing to compact", collection, documentID)
+	}
+	image, err := bson.Marshal(current)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s/%s: %w", collection, documentID, err)
+	}
+
+	if s.beforeAppend != nil {
+		s.beforeAppend()
+	}
+	entry := &wal.Entry{
+		ProjectID:  branch.ProjectID,
+		BranchID:   branch.ID,
+		Operation:  wal.OpPut,
+		Collection: collection,
+		DocumentID: documentID,
+		PostImage:  image,
+		// Same state before and after: undoing a compaction is a no-op.
+		PreImage: image,
+		Actor:    "compact",
+		Metadata: map[string]interface{}{"compaction": true},
+	}
+	lsn, err := s.wal.Append(entry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to append compaction entry: %w", err)
+	}
+
+	// Anything written to the document between the read and the append
+	// is newer than the image. Retract the entry before the head makes it
+	// visible.
+	interleaved, err := s.wal.GetDocumentHistory(branch.ID, collection, documentID, readLSN+1, lsn-1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for concurrent writes: %w", err)
+	}
+	if len(interleaved) > 0 {
+		if err := s.wal.RetractEntry(branch.ProjectID, lsn); err != nil {
+			return nil, fmt.Errorf("failed to retract stale compaction entry: %w", err)
+		}
+		return nil, errConcurrentWrite
+	}
+
+	if err := s.branches.UpdateBranchHead(branch.ID, lsn, entry.Timestamp); err != nil {
+		return nil, fmt.Errorf("failed to advance branch head: %w", err)
+	}
+
+	superseded, err := s.wal.MarkSuperseded(branch.ID, collection, documentID, lsn)
+	if err != nil {
+		// The compaction entry is already correct on its own; a failed
+		// mark only leaves the old entries being replayed redundantly.
+		return nil, fmt.Errorf("failed to mark superseded entries: %w", err)
+	}
+
+	return &Result{
+		BranchID:   branch.ID,
+		Collection: collection,
+		DocumentID: documentID,
+		LSN:        lsn,
+		Superseded: superseded,
+	}, nil
+}
@@ -0,0 +1,85 @@
+package compact_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	branchwal "github.com/argon-lab/argon/internal/branch/wal"
+	"github.com/argon-lab/argon/internal/compact"
+	"github.com/argon-lab/argon/internal/materializer"
+	"github.com/argon-lab/argon/internal/wal"
+	"github.com/argon-lab/argon/internal/walwriter"
+)
+
+func setupTestDB(t *testing.T) *mongo.Database {
+	ctx := context.Background()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI("mongodb://localhost:27017"))
+	require.NoError(t, err)
+	db := client.Database(fmt.Sprintf("argon_compact_test_%d", time.Now().UnixNano()))
+	t.Cleanup(func() {
+		if err := db.Drop(context.Background()); err != nil {
+			t.Logf("Failed to drop test database: %v", err)
+		}
+		if err := client.Disconnect(context.Background()); err != nil {
+			t.Logf("Failed to disconnect: %v", err)
+		}
+	})
+	return db
+}
+
+func TestCompactDocument_InterleavedWrite(t *testing.T) {
+	db := setupTestDB(t)
+	walService, err := wal.NewService(db)
+	require.NoError(t, err)
+	branchService, err := branchwal.NewBranchService(db, walService)
+	require.NoError(t, err)
+	mat := materializer.NewService(walService, branchService)
+	branch, err := branchService.CreateBranch("compact-race", "main", "")
+	require.NoError(t, err)
+	writer := walwriter.New(walService, branchService, mat, branch)
+	ctx := context.Background()
+
+	for i := 1; i <= 5; i++ {
+		_, err := writer.Put(ctx, "counters", bson.M{"_id": "hot", "n": int32(i)})
+		require.NoError(t, err)
+	}
+	// A stale branch value: its head predates the last write.
+	stale := *branch
+	stale.HeadLSN--
+
+	svc := compact.NewService(walService, branchService, mat)
+	interleaved := false
+	svc.SetBeforeAppendHook(func() {
+		if interleaved {
+			return
+		}
+		interleaved = true
+		_, err := writer.Put(ctx, "counters", bson.M{"_id": "hot", "n": int32(6)})
+		require.NoError(t, err)
+	})
+
+	res, err := svc.CompactDocument(&stale, "counters", "hot")
+	require.NoError(t, err)
+	assert.True(t, interleaved)
+	assert.Equal(t, int64(6), res.Superseded)
+
+	head, err := branchService.GetBranchByID(branch.ID)
+	require.NoError(t, err)
+	assert.Equal(t, res.LSN, head.HeadLSN)
+	doc, err := mat.MaterializeDocument(head, "counters", "hot")
+	require.NoError(t, err)
+	assert.Equal(t, int32(6), doc["n"], "the interleaved write must survive compaction")
+
+	replay, err := walService.GetReplayEntries(ctx, head, "counters", "hot", 0, head.HeadLSN)
+	require.NoError(t, err)
+	require.Len(t, replay, 1)
+	assert.Equal(t, res.LSN, replay[0].LSN)
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Log formats for LOG_FORMAT.
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// Config is the process configuration read from the environment.
+type Config struct {
+	Features *Features
+
+	// LogFormat is LOG_FORMAT: "text" (the default) or "json".
+	LogFormat string
+	// LogLevel is LOG_LEVEL: debug, info (the default), warn or error.
+	LogLevel slog.Level
+}
+
+// Load reads the configuration from the environment. Unset or
+// unrecognized values fall back to their defaults, as feature flags do.
+func Load() *Config {
+	cfg := &Config{
+		Features:  GetFeatures(),
+		LogFormat: LogFormatText,
+		LogLevel:  slog.LevelInfo,
+	}
+	if strings.EqualFold(os.Getenv("LOG_FORMAT"), LogFormatJSON) {
+		cfg.LogFormat = LogFormatJSON
+	}
+	if level := os.Getenv("LOG_LEVEL"); level != "" {
+		var parsed slog.Level
+		if err := parsed.UnmarshalText([]byte(level)); err == nil {
+			cfg.LogLevel = parsed
+		}
+	}
+	return cfg
+}
@@ -0,0 +1,238 @@
+// Package exporter writes a branch's materialized state out as a
+// mongodump-style dump: one <collection>.bson file (a plain stream of BSON
+// documents) and one <collection>.metadata.json file per collection,
+// optionally gzipped, which mongorestore and other mongodump consumers
+// load as they would any dump. It is the reverse of importing a database.
+//
+// Collections are materialized and written one at a time. Replay needs a
+// collection's whole state before any document is final, so an export
+// holds the largest collection's materialized documents in memory, plus a
+// slice ordering them by _id; only the encoding streams, through a
+// buffered writer, one document at a time.
+package exporter
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/argon-lab/argon/internal/materializer"
+	"github.com/argon-lab/argon/internal/wal"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Collection describes one dumped collection.
+type Collection struct {
+	Name      string `json:"name"`
+	Documents int64  `json:"documents"`
+	Bytes     int64  `json:"bytes"` // uncompressed BSON size
+	Path      string `json:"path"`
+}
+
+// Result summarizes a dump.
+type Result struct {
+	Dir         string       `json:"dir"`
+	LSN         int64        `json:"lsn"`
+	Gzip        bool         `json:"gzip"`
+	Collections []Collection `json:"collections"`
+	Documents   int64        `json:"documents"`
+}
+
+// Service dumps branches to mongodump-compatible files.
+type Service struct {
+	materializer *materializer.Service
+}
+
+// NewService creates a new dump export service.
+func NewService(mat *materializer.Service) *Service {
+	return &Service{materializer: mat}
+}
+
+// ExportDump writes every collection of a branch as of atLSN (0 means the
+// head) into dir, which is created if missing. With gzip set the files get
+// a .gz suffix, as with mongodump --gzip. Existing files for the same
+// collections are replaced; each file is written under a temporary name
+// and renamed when complete. Memory use is bounded by the largest
+// collection, which is materialized whole before it is written.
+func (s *Service) ExportDump(branch *wal.Branch, dir string, atLSN int64, gzip bool) (*Result, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("dump requires an output directory")
+	}
+	if atLSN == 0 {
+		atLSN = branch.HeadLSN
+	}
+	if atLSN < 0 || atLSN > branch.HeadLSN {
+		return nil, fmt.Errorf("dump LSN %d is beyond branch HEAD %d", atLSN, branch.HeadLSN)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory %s: %w", dir, err)
+	}
+
+	names, err := s.materializer.CollectionsAtLSN(branch, atLSN)
+	if err != nil {
+		return nil, err
+	}
+	indexes, err := s.materializer.IndexesAtLSN(branch, atLSN)
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{Dir: dir, LSN: atLSN, Gzip: gzip, Collections: []Collection{}}
+	for _, name := range names {
+		state, err := s.materializer.MaterializeCollectionAtLSN(branch, name, atLSN)
+		if err != nil {
+			return nil, fmt.Errorf("failed to materialize %s: %w", name, err)
+		}
+		coll, err := writeCollection(dir, name, state, indexes[name], gzip)
+		if err != nil {
+			return nil, fmt.Errorf("collection %s: %w", name, err)
+		}
+		result.Collections = append(result.Collections, *coll)
+		result.Documents += coll.Documents
+	}
+	return result, nil
+}
+
+// writeCollection writes one collection's documents, in _id order, and its
+// metadata.
+func writeCollection(dir, name string, state map[string]bson.M, indexes []bson.D, gz bool) (*Collection, error) {
+	suffix := ""
+	if gz {
+		suffix = ".gz"
+	}
+	dataPath, err := dumpPath(dir, name, ".bson"+suffix)
+	if err != nil {
+		return nil, err
+	}
+	metadataPath, err := dumpPath(dir, name, ".metadata.json"+suffix)
+	if err != nil {
+		return nil, err
+	}
+	coll := &Collection{Name: name, Path: dataPath}
+	err = writeFile(coll.Path, gz, func(w io.Writer) error {
+		for _, doc := range materializer.SortedDocuments(state) {
+			raw, err := bson.Marshal(doc)
+			if err != nil {
+				return fmt.Errorf("failed to encode document %v: %w", doc["_id"], err)
+			}
+			if _, err := w.Write(raw); err != nil {
+				return err
+			}
+			coll.Documents++
+			coll.Bytes += int64(len(raw))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	metadata, err := Metadata(name, indexes)
+	if err != nil {
+		return nil, err
+	}
+	err = writeFile(metadataPath, gz, func(w io.Writer) error {
+		_, err := w.Write(metadata)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return coll, nil
+}
+
+// dumpPath is the file in dir holding a collection's data (by ext). Any
+// WAL writer can name a collection, so the name is escaped and the result
+// checked to stay directly inside dir.
+func dumpPath(dir, collection, ext string) (string, error) {
+	path := filepath.Join(dir, EscapeCollectionName(collection)+ext)
+	if filepath.Dir(path) != filepath.Clean(dir) {
+		return "", fmt.Errorf("collection name %q does not map to a file in %s", collection, dir)
+	}
+	return path, nil
+}
+
+// EscapeCollectionName makes a collection name safe as a file name the way
+// mongodump does, percent-encoding "%", path separators, NUL and the dots
+// of a ".." run; mongorestore decodes the names back.
+func EscapeCollectionName(name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		dotRun := c == '.' && ((i+1 < len(name) && name[i+1] == '.') || (i > 0 && name[i-1] == '.'))
+		if c == '%' || c == '/' || c == '\\' || c == 0 || dotRun {
+			fmt.Fprintf(&b, "%%%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// Metadata is a collection's .metadata.json content in mongodump's
+// format: canonical extended JSON with default options, the _id index and
+// the given secondary index definitions (as recorded at import; see
+// materializer.IndexesAtLSN), which mongorestore recreates.
+func Metadata(collection string, indexes []bson.D) ([]byte, error) {
+	list := bson.A{
+		bson.D{
+			{Key: "v", Value: int32(2)},
+			{Key: "key", Value: bson.D{{Key: "_id", Value: int32(1)}}},
+			{Key: "name", Value: "_id_"},
+		},
+	}
+	for _, spec := range indexes {
+		list = append(list, append(bson.D{{Key: "v", Value: int32(2)}}, spec...))
+	}
+	return bson.MarshalExtJSON(bson.D{
+		{Key: "options", Value: bson.D{}},
+		{Key: "indexes", Value: list},
+		{Key: "collectionName", Value: collection},
+		{Key: "type", Value: "collection"},
+	}, true, false)
+}
+
+// writeFile streams fill's output into path through a buffer (and gzip,
+// when set), renaming a temporary file into place only once it is fully
+// written.
+func writeFile(path string, gz bool, fill func(w io.Writer) error) (err error) {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer func() {
+		if err != nil {
+			_ = f.Close()
+			_ = os.Remove(f.Name())
+		}
+	}()
+
+	buffered := bufio.NewWriter(f)
+	var w io.Writer = buffered
+	var zw *gzip.Writer
+	if gz {
+		zw = gzip.NewWriter(buffered)
+		w = zw
+	}
+	if err = fill(w); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if zw != nil {
+		if err = zw.Close(); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+	if err = buffered.Flush(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err = f.Close(); err != nil {
+		return fmt.Errorf("failed to close %s: %w", path, err)
+	}
+	if err = os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("failed to finalize %s: %w", path, err)
+	}
+	return nil
+}
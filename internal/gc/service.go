@@ -25,6 +25,13 @@
 // (S = 0) nothing is deleted, no matter how old — history that is anyone's
 // only source of truth is never dropped.
 //
+// Entries superseded by a document compaction (see the compact package)
+// follow a simpler rule: they are only read by readers whose bound lies
+// below the compaction LSN, so they go once the compaction is older than
+// the retention window and below every live child's fork point and every
+// pin — no snapshot is required, since the compaction entry itself carries
+// the state.
+//
 // Deleting entries below the cutoff also deletes discarded-range entries
 // and pre-images in that region, which ends their audit/undo availability.
 // That is exactly what a retention window means; pick it accordingly.
@@ -86,6 +93,9 @@ type BranchReport struct {
 	BranchName     string
 	EntriesRemoved int64
 	Cutoffs        map[string]int64 // collection -> reclaim cutoff LSN
+	// SupersededCutoff is the newest compaction LSN whose superseded
+	// entries were (or, in a dry run, would be) reclaimed.
+	SupersededCutoff int64
 }
 
 // Report summarizes a project GC run.
@@ -162,6 +172,27 @@ func (s *Service) gcBranch(ctx context.Context, branch *wal.Branch, liveChildren
 		}
 	}
 
+	// Superseded entries: every remaining reader bound must be at or above
+	// the compaction. Bounds equal to a fork or pin LSN already see it, so
+	// the cutoff may sit exactly on one.
+	supersededCutoff := retentionLSN
+	for _, child := range liveChildren {
+		supersededCutoff = min64(supersededCutoff, child.BaseLSN)
+	}
+	for _, pinLSN := range pinnedLSNs {
+		supersededCutoff = min64(supersededCutoff, pinLSN)
+	}
+	if supersededCutoff > 0 {
+		report.SupersededCutoff = supersededCutoff
+		if !dryRun {
+			removed, err := s.wal.DeleteSupersededEntries(branch, supersededCutoff)
+			if err != nil {
+				return nil, fmt.Errorf("failed to delete superseded entries: %w", err)
+			}
+			report.EntriesRemoved += removed
+		}
+	}
+
 	for _, collection := range collections {
 		// S: coverage for the branch's own (and post-fork) readers — must
 		// be valid for every possible future read bound.
@@ -0,0 +1,65 @@
+package importer
+
+import (
+	"fmt"
+	"path"
+)
+
+// selectCollections applies an import's collection filters to the source's
+// collection names, keeping their order. System collections never import.
+// With include patterns only matching names do; exclude patterns then
+// remove names, so a name matching both is excluded. Patterns use
+// path.Match syntax ("logs_*", "tmp?", "[ab]*"). A pattern matching no
+// collection yields a warning rather than an error: a typo should be
+// visible, but must not fail an import of everything else.
+func selectCollections(names, include, exclude []string) (selected, warnings []string) {
+	includeHits := make([]bool, len(include))
+	excludeHits := make([]bool, len(exclude))
+	for _, name := range names {
+		if isSystemCollection(name) {
+			continue
+		}
+		included := len(include) == 0 || matchAny(include, name, includeHits)
+		// Match excludes even against names not included, so a warning
+		// only ever means the pattern matches nothing in the source.
+		excluded := matchAny(exclude, name, excludeHits)
+		if included && !excluded {
+			selected = append(selected, name)
+		}
+	}
+	for i, hit := range includeHits {
+		if !hit {
+			warnings = append(warnings, fmt.Sprintf("include pattern %q matches no collection", include[i]))
+		}
+	}
+	for i, hit := range excludeHits {
+		if !hit {
+			warnings = append(warnings, fmt.Sprintf("exclude pattern %q matches no collection", exclude[i]))
+		}
+	}
+	return selected, warnings
+}
+
+// matchAny reports whether name matches any pattern, marking every
+// pattern that does in hits. Patterns are validated up front (see
+// validatePatterns), so match errors cannot occur here.
+func matchAny(patterns []string, name string, hits []bool) bool {
+	matched := false
+	for i, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			hits[i] = true
+			matched = true
+		}
+	}
+	return matched
+}
+
+// validatePatterns rejects malformed collection patterns.
+func validatePatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid collection pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
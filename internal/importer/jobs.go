@@ -0,0 +1,328 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/argon-lab/argon/internal/redact"
+	"github.com/argon-lab/argon/internal/wal"
+)
+
+// Import job statuses. A job left running by a crashed process is
+// resumable once it has gone JobStaleAfter without saving progress.
+const (
+	JobRunning   = "running"
+	JobFailed    = "failed"
+	JobCompleted = "completed"
+)
+
+// JobStaleAfter is how long a running job must go without saving progress
+// before it is taken for abandoned: an importer saves after every batch.
+const JobStaleAfter = 5 * time.Minute
+
+var (
+	// ErrJobNotFound is returned for an unknown import job.
+	ErrJobNotFound = errors.New("import job not found")
+	// ErrJobInUse is returned when another process owns an import job.
+	ErrJobInUse = errors.New("import job is in use by another process")
+)
+
+// ImportJob is the persisted record of an import: what it reads and how
+// far it got. Its checkpoint is saved after every appended batch, so an
+// import that fails for any reason — not just cancellation — can be
+// resumed with ResumeImport. There is one job per project, since an
+// import always creates its project.
+type ImportJob struct {
+	ID           string `bson:"_id" json:"id"`
+	ProjectID    string `bson:"project_id" json:"project_id"`
+	ProjectName  string `bson:"project_name" json:"project_name"`
+	DatabaseName string `bson:"database_name" json:"database_name"`
+	// SourceURI is kept only when it carries no password; otherwise the
+	// resume must supply it again.
+	SourceURI      string `bson:"source_uri,omitempty" json:"source_uri,omitempty"`
+	BatchSize      int    `bson:"batch_size" json:"batch_size"`
+	SourceOrder    string `bson:"source_order,omitempty" json:"source_order,omitempty"`
+	Concurrency    int    `bson:"concurrency,omitempty" json:"concurrency,omitempty"`
+	IncludeIndexes bool   `bson:"include_indexes" json:"include_indexes"`
+	// IncludeCollections and ExcludeCollections are the import's
+	// collection patterns; a resume imports the same collections.
+	IncludeCollections []string         `bson:"include_collections,omitempty" json:"include_collections,omitempty"`
+	ExcludeCollections []string         `bson:"exclude_collections,omitempty" json:"exclude_collections,omitempty"`
+	Checkpoint         ImportCheckpoint `bson:"checkpoint" json:"checkpoint"`
+	Progress           ImportProgress   `bson:"progress" json:"progress"`
+	Status             string           `bson:"status" json:"status"`
+	Error              string           `bson:"error,omitempty" json:"error,omitempty"`
+	StartedAt          time.Time        `bson:"started_at" json:"started_at"`
+	UpdatedAt          time.Time        `bson:"updated_at" json:"updated_at"`
+	// Owner identifies the process importing the job; only it saves
+	// progress, so a takeover stops the previous owner at its next batch.
+	Owner string `bson:"owner,omitempty" json:"-"`
+}
+
+// Running reports whether the job has not ended, as far as its record
+// shows.
+func (j *ImportJob) Running() bool {
+	return j.Status == JobRunning
+}
+
+// EnableJobs persists import jobs in db's import_jobs collection and
+// creates its indexes. Without it imports are only resumable from the
+// checkpoint a cancelled import returns.
+func (s *ImportService) EnableJobs(db *mongo.Database) error {
+	jobs := db.Collection("import_jobs")
+	_, err := jobs.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "project_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create import job indexes: %w", err)
+	}
+	s.jobs = jobs
+	return nil
+}
+
+// GetJob returns an import job by ID.
+func (s *ImportService) GetJob(jobID string) (*ImportJob, error) {
+	return s.findJob(bson.M{"_id": jobID})
+}
+
+// GetJobByProject returns the job that imported a project.
+func (s *ImportService) GetJobByProject(projectID string) (*ImportJob, error) {
+	return s.findJob(bson.M{"project_id": projectID})
+}
+
+func (s *ImportService) findJob(filter bson.M) (*ImportJob, error) {
+	if s.jobs == nil {
+		return nil, errors.New("import jobs are not enabled")
+	}
+	var job ImportJob
+	if err := s.jobs.FindOne(context.Background(), filter).Decode(&job); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrJobNotFound
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// ResumeImport continues an incomplete import job into its project,
+// reporting to progressFn if set. mongoURI may be empty when the job kept
+// its source URI. Documents
+// already in the WAL are not imported again, even when the job's
+// checkpoint trails the WAL by a batch (a failure between appending a
+// batch and saving the checkpoint). A running job that saved progress
+// within JobStaleAfter is refused with ErrJobInUse unless force is set.
+func (s *ImportService) ResumeImport(ctx context.Context, jobID, mongoURI string, force bool, progressFn func(ImportProgress)) (_ *ImportResult, err error) {
+	job, err := s.GetJob(jobID)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status == JobCompleted {
+		return nil, fmt.Errorf("import job %s already completed", job.ID)
+	}
+	if mongoURI == "" {
+		mongoURI = job.SourceURI
+	}
+	if mongoURI == "" {
+		return nil, fmt.Errorf("import job %s needs the source URI: it has credentials, which are not stored", job.ID)
+	}
+	if err := s.claimJob(job, force); err != nil {
+		return nil, err
+	}
+	defer func() {
+		// A resume that fails before the import records its end must
+		// not leave the job looking busy.
+		if err != nil && job.Running() {
+			s.finishJob(job, redact.Error(err, mongoURI))
+		}
+	}()
+	checkpoint, err := s.reconcileCheckpoint(job)
+	if err != nil {
+		return nil, err
+	}
+	includeIndexes := job.IncludeIndexes
+	return s.importDatabase(ctx, ImportOptions{
+		MongoURI:           mongoURI,
+		DatabaseName:       job.DatabaseName,
+		ProjectName:        job.ProjectName,
+		BatchSize:          job.BatchSize,
+		SourceOrder:        job.SourceOrder,
+		Concurrency:        job.Concurrency,
+		IncludeIndexes:     &includeIndexes,
+		IncludeCollections: job.IncludeCollections,
+		ExcludeCollections: job.ExcludeCollections,
+		ResumeFrom:         checkpoint,
+		ProgressFn:         progressFn,
+	}, job)
+}
+
+// claimJob makes this process the job's owner. The claim only lands if
+// the record is still the one read, so of two processes resuming a job at
+// once exactly one proceeds.
+func (s *ImportService) claimJob(job *ImportJob, force bool) error {
+	if job.Running() && !force {
+		if idle := time.Since(job.UpdatedAt); idle < JobStaleAfter {
+			return fmt.Errorf("%w: import job %s saved progress %s ago; force the resume if its importer is gone",
+				ErrJobInUse, job.ID, idle.Round(time.Second))
+		}
+	}
+	owner := primitive.NewObjectID().Hex()
+	now := time.Now()
+	res, err := s.jobs.UpdateOne(context.Background(),
+		bson.M{"_id": job.ID, "updated_at": job.UpdatedAt},
+		bson.M{"$set": bson.M{"owner": owner, "status": JobRunning, "updated_at": now}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to claim import job %s: %w", job.ID, err)
+	}
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("%w: import job %s changed while it was being claimed", ErrJobInUse, job.ID)
+	}
+	job.Owner, job.Status, job.UpdatedAt = owner, JobRunning, now
+	return nil
+}
+
+// reconcileCheckpoint brings a job's checkpoint up to the WAL. Entries
+// past the checkpoint are accepted only if the import wrote them — put and
+// index entries for the checkpoint's collection, or any collection for a
+// parallel import — and their documents are counted into the offset (a
+// parallel resume counts per collection anyway). Anything else means the
+// project was written to since, and the import cannot continue.
+func (s *ImportService) reconcileCheckpoint(job *ImportJob) (*ImportCheckpoint, error) {
+	project, err := s.projectService.GetProject(job.ProjectID)
+	if err != nil {
+		return nil, fmt.Errorf("project of import job %s: %w", job.ID, err)
+	}
+	branch, err := s.branchService.GetBranch(project.ID, "main")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get main branch: %w", err)
+	}
+	checkpoint := job.Checkpoint
+	if branch.HeadLSN == checkpoint.LSN {
+		return &checkpoint, nil
+	}
+	trailing, err := s.walService.GetEntries(bson.M{
+		"project_id": project.ID,
+		"branch_id":  branch.ID,
+		"lsn":        bson.M{"$gt": checkpoint.LSN, "$lte": branch.HeadLSN},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read entries past the checkpoint: %w", err)
+	}
+	for _, entry := range trailing {
+		if entry.Actor != "importer" || (!checkpoint.Parallel && entry.Collection != checkpoint.Collection) ||
+			(entry.Operation != wal.OpPut && entry.Operation != wal.OpCreateIndex) {
+			return nil, fmt.Errorf("project '%s' changed since the import stopped (LSN %d)", project.Name, entry.LSN)
+		}
+		if entry.Operation == wal.OpPut && !checkpoint.Parallel {
+			checkpoint.Offset++
+		}
+	}
+	checkpoint.LSN = branch.HeadLSN
+	return &checkpoint, nil
+}
+
+// trackJob returns the job an import records its progress in: the given
+// one, the existing job of a project a checkpoint resume continues, or a
+// new job. Nil when jobs are not enabled.
+func (s *ImportService) trackJob(job *ImportJob, opts ImportOptions, project *wal.Project, branch *wal.Branch) (*ImportJob, error) {
+	if s.jobs == nil || job != nil {
+		return job, nil
+	}
+	if opts.ResumeFrom != nil {
+		existing, err := s.GetJobByProject(project.ID)
+		if err == nil {
+			if err := s.claimJob(existing, false); err != nil {
+				return nil, err
+			}
+			return existing, nil
+		}
+		if !errors.Is(err, ErrJobNotFound) {
+			return nil, err
+		}
+	}
+	now := time.Now()
+	job = &ImportJob{
+		ID:                 primitive.NewObjectID().Hex(),
+		ProjectID:          project.ID,
+		ProjectName:        project.Name,
+		DatabaseName:       opts.DatabaseName,
+		BatchSize:          opts.BatchSize,
+		SourceOrder:        opts.SourceOrder,
+		Concurrency:        opts.Concurrency,
+		IncludeIndexes:     opts.IncludeIndexes == nil || *opts.IncludeIndexes,
+		IncludeCollections: opts.IncludeCollections,
+		ExcludeCollections: opts.ExcludeCollections,
+		Checkpoint:         ImportCheckpoint{ProjectID: project.ID, LSN: branch.HeadLSN, Parallel: opts.Concurrency > 1},
+		Status:             JobRunning,
+		Owner:              primitive.NewObjectID().Hex(),
+		StartedAt:          now,
+		UpdatedAt:          now,
+	}
+	if redact.URI(opts.MongoURI) == opts.MongoURI {
+		job.SourceURI = opts.MongoURI
+	}
+	if _, err := s.jobs.InsertOne(context.Background(), job); err != nil {
+		return nil, fmt.Errorf("failed to record import job: %w", err)
+	}
+	return job, nil
+}
+
+// saveProgress records that an import's documents are in the WAL up to
+// offset documents into collection. It uses a fresh context so a
+// cancelled import still records its final batch, and holds the tracker
+// lock so parallel workers' saves land in head order. An import whose job
+// was taken over stops with ErrJobInUse.
+func (s *ImportService) saveProgress(t *importTracker, collection string, offset int64) error {
+	if t.job == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	job := t.job
+	job.Checkpoint = *t.checkpointLocked(collection, offset)
+	job.Status = JobRunning
+	job.Error = ""
+	job.UpdatedAt = time.Now()
+	res, err := s.jobs.UpdateOne(context.Background(), bson.M{"_id": job.ID, "owner": job.Owner}, bson.M{"$set": bson.M{
+		"checkpoint": job.Checkpoint,
+		"progress":   job.Progress,
+		"status":     job.Status,
+		"error":      job.Error,
+		"updated_at": job.UpdatedAt,
+	}})
+	if err != nil {
+		return fmt.Errorf("failed to save import progress: %w", err)
+	}
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("%w: import job %s was taken over", ErrJobInUse, job.ID)
+	}
+	return nil
+}
+
+// finishJob records how an import ended; importErr must already be
+// redacted. Failures to do so are not reported: the checkpoint, which is
+// what a resume needs, is already saved. A job taken over by another
+// process is left to its new owner.
+func (s *ImportService) finishJob(job *ImportJob, importErr error) {
+	job.Status = JobCompleted
+	job.Error = ""
+	if importErr != nil {
+		job.Status = JobFailed
+		job.Error = importErr.Error()
+	}
+	job.UpdatedAt = time.Now()
+	_, _ = s.jobs.UpdateOne(context.Background(), bson.M{"_id": job.ID, "owner": job.Owner}, bson.M{"$set": bson.M{
+		"progress":   job.Progress,
+		"status":     job.Status,
+		"error":      job.Error,
+		"updated_at": job.UpdatedAt,
+	}})
+}
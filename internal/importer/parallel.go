@@ -0,0 +1,126 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/argon-lab/argon/internal/wal"
+)
+
+// importTracker is the bookkeeping one import's batches share: the branch
+// head they advance, the job their progress is saved in, and aggregate
+// progress. Parallel collection workers share a tracker, so it is locked.
+type importTracker struct {
+	mu       sync.Mutex
+	branch   *wal.Branch
+	job      *ImportJob
+	progress ImportProgress
+	// parallel imports record checkpoints without a position; resumes
+	// recover each collection's from the WAL instead.
+	parallel bool
+
+	// progressFn, started and startDocs feed progress reports; see
+	// report.
+	progressFn func(ImportProgress)
+	started    time.Time
+	startDocs  int64
+}
+
+// checkpoint describes the import's current position.
+func (t *importTracker) checkpoint(collection string, offset int64) *ImportCheckpoint {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.checkpointLocked(collection, offset)
+}
+
+func (t *importTracker) checkpointLocked(collection string, offset int64) *ImportCheckpoint {
+	if t.parallel {
+		return &ImportCheckpoint{ProjectID: t.branch.ProjectID, LSN: t.branch.HeadLSN, Parallel: true}
+	}
+	return &ImportCheckpoint{
+		ProjectID:  t.branch.ProjectID,
+		Collection: collection,
+		Offset:     offset,
+		LSN:        t.branch.HeadLSN,
+	}
+}
+
+// importParallel imports collections on opts.Concurrency workers. Each
+// collection is still read and appended in order by a single worker;
+// batches from different collections interleave in the WAL, each in its
+// own LSN block. The first failure stops the other workers. A resumed
+// import finds each collection's position by counting its imported
+// documents, since no single offset describes parallel progress.
+func (s *ImportService) importParallel(ctx context.Context, sourceDB *mongo.Database, names []string, opts ImportOptions, t *importTracker, result *ImportResult) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		firstErr error
+		done     []string
+		wg       sync.WaitGroup
+	)
+	work := make(chan string)
+	for i := 0; i < opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range work {
+				docs, batches, indexes, err := s.importParallelCollection(ctx, sourceDB, name, opts, t)
+				mu.Lock()
+				result.ImportedDocs += docs
+				result.WALEntries += docs + indexes
+				result.Batches += batches
+				result.Indexes += indexes
+				if err == nil {
+					done = append(done, name)
+				} else if firstErr == nil {
+					firstErr = err
+					cancel()
+				}
+				mu.Unlock()
+				if err == nil {
+					s.collectionDone(t, name, 0)
+				}
+			}
+		}()
+	}
+feed:
+	for _, name := range names {
+		select {
+		case work <- name:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(work)
+	wg.Wait()
+
+	sort.Strings(done)
+	result.Collections = append(result.Collections, done...)
+	return firstErr
+}
+
+// importParallelCollection imports one collection's documents and indexes
+// for importParallel, picking up after the documents a resumed import
+// already holds.
+func (s *ImportService) importParallelCollection(ctx context.Context, sourceDB *mongo.Database, name string, opts ImportOptions, t *importTracker) (docs, batches, indexes int64, err error) {
+	var skip int64
+	if opts.ResumeFrom != nil {
+		if skip, err = s.walService.CountCollectionEntries(t.branch.ID, name, wal.OpPut); err != nil {
+			return 0, 0, 0, fmt.Errorf("failed to find the resume position of %s: %w", name, err)
+		}
+	}
+	docs, batches, err = s.importCollection(ctx, sourceDB, name, t, opts.BatchSize, opts.SourceOrder, skip)
+	if err != nil {
+		return docs, batches, 0, fmt.Errorf("failed to import collection %s: %w", name, err)
+	}
+	indexes, err = s.importIndexesIfPending(ctx, sourceDB, name, opts, t)
+	return docs, batches, indexes, err
+}
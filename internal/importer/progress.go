@@ -0,0 +1,68 @@
+package importer
+
+import (
+	"time"
+
+	"github.com/argon-lab/argon/internal/wal"
+)
+
+// ImportProgress is an import's aggregate progress across collections,
+// reported to ImportOptions.ProgressFn after every batch and every
+// completed collection, and saved with the import's job. A dry run
+// reports the estimated totals it counts, one collection at a time.
+type ImportProgress struct {
+	// Collection is the collection of the latest batch; with parallel
+	// imports, one of several in flight.
+	Collection       string `bson:"collection,omitempty" json:"collection,omitempty"`
+	Collections      int    `bson:"collections" json:"collections"` // completed
+	TotalCollections int    `bson:"total_collections" json:"total_collections"`
+	// Documents counts documents in the WAL, including those a resumed
+	// import found already there; TotalDocuments is the source's
+	// estimated count.
+	Documents      int64 `bson:"documents" json:"documents"`
+	TotalDocuments int64 `bson:"total_documents" json:"total_documents"`
+	Batches        int64 `bson:"batches" json:"batches"`
+	// ETA extrapolates this run's rate to the remaining documents; zero
+	// until there is a rate, and once the estimate is reached.
+	ETA    time.Duration `bson:"eta" json:"eta"`
+	DryRun bool          `bson:"dry_run,omitempty" json:"dry_run,omitempty"`
+}
+
+// batchDone counts an appended batch and reports progress.
+func (s *ImportService) batchDone(t *importTracker, collection string, batch []*wal.Entry) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.progress.Collection = collection
+	t.progress.Documents += int64(len(batch))
+	t.progress.Batches++
+	t.report()
+}
+
+// collectionDone counts a completed collection and reports progress.
+// counted adds documents a dry run counted rather than imported.
+func (s *ImportService) collectionDone(t *importTracker, collection string, counted int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.progress.Collection = collection
+	t.progress.Collections++
+	t.progress.Documents += counted
+	t.report()
+}
+
+// report refreshes the ETA, copies progress into the job for its next
+// save, and calls the progress callback. Callers hold t.mu, so callbacks
+// never run concurrently.
+func (t *importTracker) report() {
+	t.progress.ETA = 0
+	done := t.progress.Documents - t.startDocs
+	left := t.progress.TotalDocuments - t.progress.Documents
+	if done > 0 && left > 0 && !t.progress.DryRun {
+		t.progress.ETA = time.Duration(float64(time.Since(t.started)) * float64(left) / float64(done))
+	}
+	if t.job != nil {
+		t.job.Progress = t.progress
+	}
+	if t.progressFn != nil {
+		t.progressFn(t.progress)
+	}
+}
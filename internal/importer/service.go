@@ -2,7 +2,10 @@ package importer
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"sort"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -10,22 +13,47 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 
 	branchwal "github.com/argon-lab/argon/internal/branch/wal"
+	"github.com/argon-lab/argon/internal/logging"
 	projectwal "github.com/argon-lab/argon/internal/project/wal"
+	"github.com/argon-lab/argon/internal/redact"
 	"github.com/argon-lab/argon/internal/wal"
 )
 
+// Limits a single import batch must stay under. MongoDB caps a BSON
+// document at 16MB and a write batch at 100,000 operations; a batch is
+// flushed early, before BatchSize is reached, when the next document would
+// cross either limit. Entry overhead (ids, LSN, timestamps) is small but
+// not zero, so the byte budget keeps a megabyte of headroom.
+const (
+	maxImportBatchBytes = 15 * 1024 * 1024
+	maxImportBatchCount = 100000
+)
+
+// ErrTooManyImports is returned by ImportDatabase when the concurrency
+// limit is reached and the service is configured not to wait.
+var ErrTooManyImports = errors.New("too many concurrent imports")
+
 // ImportService handles importing existing MongoDB databases into Argon WAL system
 type ImportService struct {
 	walService     *wal.Service
 	projectService *projectwal.ProjectService
 	branchService  *branchwal.BranchService
 
+	// slots bounds concurrent imports (nil: unbounded). With waitForSlot,
+	// an import over the bound queues until a slot frees or its context
+	// ends; without, it fails fast with ErrTooManyImports.
+	slots       chan struct{}
+	waitForSlot bool
+
 	// onImported runs after a successful import with the freshly loaded
 	// branch. Wired to snapshot creation: an imported project starts with
 	// its entire history in raw entries, so without an immediate snapshot
 	// every read replays the whole import until some other write trips the
 	// auto-snapshot threshold.
 	onImported func(branch *wal.Branch)
+
+	// jobs persists import progress (nil: not enabled); see EnableJobs.
+	jobs *mongo.Collection
 }
 
 // SetImportedHook registers a callback invoked after each successful import.
@@ -33,6 +61,40 @@ func (s *ImportService) SetImportedHook(hook func(branch *wal.Branch)) {
 	s.onImported = hook
 }
 
+// SetConcurrencyLimit bounds how many ImportDatabase calls run at once;
+// max <= 0 removes the bound. Several large imports at once can overwhelm
+// the deployment. With wait, excess imports queue; otherwise they fail
+// with ErrTooManyImports. Set it before imports start.
+func (s *ImportService) SetConcurrencyLimit(max int, wait bool) {
+	s.slots = nil
+	if max > 0 {
+		s.slots = make(chan struct{}, max)
+	}
+	s.waitForSlot = wait
+}
+
+// acquireSlot takes an import slot, returning its release function.
+func (s *ImportService) acquireSlot(ctx context.Context) (func(), error) {
+	if s.slots == nil {
+		return func() {}, nil
+	}
+	release := func() { <-s.slots }
+	if !s.waitForSlot {
+		select {
+		case s.slots <- struct{}{}:
+			return release, nil
+		default:
+			return nil, ErrTooManyImports
+		}
+	}
+	select {
+	case s.slots <- struct{}{}:
+		return release, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("waiting for an import slot: %w", ctx.Err())
+	}
+}
+
 // ImportPreview contains information about what would be imported
 type ImportPreview struct {
 	DatabaseName    string            `json:"database_name"`
@@ -40,6 +102,8 @@ type ImportPreview struct {
 	TotalDocuments  int64             `json:"total_documents"`
 	EstimatedSize   int64             `json:"estimated_size_bytes"`
 	EstimatedWALEntries int64         `json:"estimated_wal_entries"`
+	// Warnings lists collection patterns that matched nothing.
+	Warnings []string `json:"warnings,omitempty"`
 }
 
 // CollectionInfo contains details about a collection to be imported
@@ -50,6 +114,13 @@ type CollectionInfo struct {
 	IndexCount    int    `json:"index_count"`
 }
 
+// Source orders for ImportOptions.SourceOrder. Documents are appended in
+// the order they are read, so the source order becomes the WAL LSN order.
+const (
+	SourceOrderID      = "_id"     // ascending _id (the default)
+	SourceOrderNatural = "natural" // the source's natural (storage) order
+)
+
 // ImportOptions configures the import process
 type ImportOptions struct {
 	MongoURI     string `json:"mongo_uri"`
@@ -57,6 +128,44 @@ type ImportOptions struct {
 	ProjectName  string `json:"project_name"`
 	DryRun       bool   `json:"dry_run"`
 	BatchSize    int    `json:"batch_size"`
+	SourceOrder  string `json:"source_order"`
+	// IncludeIndexes records each collection's secondary indexes
+	// (OpCreateIndex entries) so exports can recreate them. Nil means
+	// true; the default _id index is never recorded.
+	IncludeIndexes *bool `json:"include_indexes,omitempty"`
+	// Concurrency is how many collections import at once; 0 or 1 imports
+	// them one after another.
+	Concurrency int `json:"concurrency,omitempty"`
+	// IncludeCollections, if set, limits the import to collections
+	// matching one of its glob patterns (path.Match syntax);
+	// ExcludeCollections then drops collections matching any of its
+	// patterns. Patterns matching nothing are reported in
+	// ImportResult.Warnings.
+	IncludeCollections []string `json:"include_collections,omitempty"`
+	ExcludeCollections []string `json:"exclude_collections,omitempty"`
+	// ProgressFn, if set, receives the import's progress after every
+	// batch and completed collection. Calls never overlap.
+	ProgressFn func(ImportProgress) `json:"-"`
+
+	// ResumeFrom continues an incomplete import from its checkpoint, into
+	// the project it created.
+	ResumeFrom *ImportCheckpoint `json:"resume_from,omitempty"`
+}
+
+// ImportCheckpoint is where an incomplete import stopped. Collections are
+// imported in name order and each in source order, so the position is a
+// collection and how many of its documents are already in the WAL. LSN is
+// the branch head at that point: a resume refuses a project written to
+// since, whose head would have moved.
+type ImportCheckpoint struct {
+	ProjectID  string `bson:"project_id" json:"project_id"`
+	Collection string `bson:"collection" json:"collection"`
+	Offset     int64  `bson:"offset" json:"offset"`
+	LSN        int64  `bson:"lsn" json:"lsn"`
+	// Parallel marks a checkpoint of an import that imported collections
+	// in parallel. Collection and Offset are then unused: the resume
+	// counts each collection's imported documents instead.
+	Parallel bool `bson:"parallel,omitempty" json:"parallel,omitempty"`
 }
 
 // ImportResult contains the result of an import operation
@@ -66,9 +175,20 @@ type ImportResult struct {
 	ImportedDocs    int64             `json:"imported_documents"`
 	WALEntries      int64             `json:"wal_entries_created"`
 	Collections     []string          `json:"imported_collections"`
+	Batches         int64             `json:"batches"`
+	Indexes         int64             `json:"imported_indexes"`
 	Duration        time.Duration     `json:"duration"`
 	StartLSN        int64             `json:"start_lsn"`
 	EndLSN          int64             `json:"end_lsn"`
+	// Incomplete marks an import stopped by its context; Checkpoint is
+	// where to resume it.
+	Incomplete      bool              `json:"incomplete,omitempty"`
+	Checkpoint      *ImportCheckpoint `json:"checkpoint,omitempty"`
+	// JobID identifies the persisted import job (see EnableJobs), which
+	// ResumeImport continues after any failure.
+	JobID           string            `json:"job_id,omitempty"`
+	// Warnings lists collection patterns that matched nothing.
+	Warnings        []string          `json:"warnings,omitempty"`
 }
 
 // NewImportService creates a new import service
@@ -80,8 +200,16 @@ func NewImportService(walService *wal.Service, projectService *projectwal.Projec
 	}
 }
 
-// PreviewImport analyzes a MongoDB database and returns import preview information
-func (s *ImportService) PreviewImport(ctx context.Context, mongoURI, databaseName string) (*ImportPreview, error) {
+// PreviewImport analyzes a MongoDB database and returns import preview
+// information for the collections an import with the same include and
+// exclude patterns would read (see ImportOptions.IncludeCollections).
+func (s *ImportService) PreviewImport(ctx context.Context, mongoURI, databaseName string, include, exclude []string) (_ *ImportPreview, err error) {
+	// Driver errors echo the source URI; keep its password out of them.
+	defer func() { err = redact.Error(err, mongoURI) }()
+	if err := validatePatterns(append(append([]string(nil), include...), exclude...)); err != nil {
+		return nil, err
+	}
+
 	// Connect to source MongoDB
 	client, err := mongo.Connect(ctx, options.Client().ApplyURI(mongoURI))
 	if err != nil {
@@ -102,18 +230,17 @@ func (s *ImportService) PreviewImport(ctx context.Context, mongoURI, databaseNam
 		return nil, fmt.Errorf("failed to list collections: %w", err)
 	}
 
+	sort.Strings(collectionNames)
+	selected, warnings := selectCollections(collectionNames, include, exclude)
+
 	preview := &ImportPreview{
 		DatabaseName: databaseName,
-		Collections:  make([]CollectionInfo, 0, len(collectionNames)),
+		Collections:  make([]CollectionInfo, 0, len(selected)),
+		Warnings:     warnings,
 	}
 
 	// Analyze each collection
-	for _, collName := range collectionNames {
-		// Skip system collections
-		if isSystemCollection(collName) {
-			continue
-		}
-
+	for _, collName := range selected {
 		collection := db.Collection(collName)
 		
 		// Get document count
@@ -168,6 +295,20 @@ func (s *ImportService) PreviewImport(ctx context.Context, mongoURI, databaseNam
 
 // ImportDatabase imports an existing MongoDB database into Argon WAL system
 func (s *ImportService) ImportDatabase(ctx context.Context, opts ImportOptions) (*ImportResult, error) {
+	return s.importDatabase(ctx, opts, nil)
+}
+
+// importDatabase runs an import, recording its progress in job. A nil job
+// is created (or, for a checkpoint resume, looked up) once the target
+// project is known, if jobs are enabled.
+func (s *ImportService) importDatabase(ctx context.Context, opts ImportOptions, job *ImportJob) (_ *ImportResult, err error) {
+	defer func() {
+		err = redact.Error(err, opts.MongoURI)
+		if err != nil {
+			slog.Warn("import failed", slog.String("project", opts.ProjectName),
+				slog.String("database", opts.DatabaseName), logging.Err(err))
+		}
+	}()
 	startTime := time.Now()
 
 	// Validate options
@@ -175,10 +316,19 @@ func (s *ImportService) ImportDatabase(ctx context.Context, opts ImportOptions)
 		return nil, fmt.Errorf("invalid import options: %w", err)
 	}
 
+	release, err := s.acquireSlot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
 	// Set default batch size if not specified
 	if opts.BatchSize <= 0 {
 		opts.BatchSize = 1000
 	}
+	if opts.BatchSize > maxImportBatchCount {
+		opts.BatchSize = maxImportBatchCount
+	}
 
 	// Connect to source MongoDB
 	sourceClient, err := mongo.Connect(ctx, options.Client().ApplyURI(opts.MongoURI))
@@ -195,14 +345,18 @@ func (s *ImportService) ImportDatabase(ctx context.Context, opts ImportOptions)
 
 	// Check if project already exists
 	existingProject, err := s.projectService.GetProjectByName(opts.ProjectName)
-	if err == nil && existingProject != nil {
+	if opts.ResumeFrom == nil && err == nil && existingProject != nil {
 		return nil, fmt.Errorf("project '%s' already exists", opts.ProjectName)
 	}
 
 	// Create new project if not in dry run mode
 	var project *wal.Project
 	var branch *wal.Branch
-	if !opts.DryRun {
+	if opts.ResumeFrom != nil {
+		if project, branch, err = s.resumeTarget(existingProject, opts.ResumeFrom); err != nil {
+			return nil, err
+		}
+	} else if !opts.DryRun {
 		project, err = s.projectService.CreateProject(opts.ProjectName)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create project: %w", err)
@@ -215,53 +369,163 @@ func (s *ImportService) ImportDatabase(ctx context.Context, opts ImportOptions)
 		}
 	}
 
+	if !opts.DryRun {
+		if job, err = s.trackJob(job, opts, project, branch); err != nil {
+			return nil, err
+		}
+		if job != nil {
+			defer func() { s.finishJob(job, redact.Error(err, opts.MongoURI)) }()
+		}
+	}
+
+	t := &importTracker{
+		branch:     branch,
+		job:        job,
+		parallel:   opts.Concurrency > 1 || (opts.ResumeFrom != nil && opts.ResumeFrom.Parallel),
+		progressFn: opts.ProgressFn,
+		started:    startTime,
+		progress:   ImportProgress{DryRun: opts.DryRun},
+	}
+
+	// Where this run starts, for a cancellation before its first batch.
+	var startCollection string
+	var startOffset int64
+	if cp := opts.ResumeFrom; cp != nil {
+		startCollection, startOffset = cp.Collection, cp.Offset
+	}
+
 	// Get list of collections to import
 	collectionNames, err := sourceDB.ListCollectionNames(ctx, bson.D{})
+	if err != nil && ctx.Err() != nil && project != nil {
+		// Cancelled after the project was created: nothing more is
+		// imported, and the checkpoint is where this run started.
+		result := &ImportResult{ProjectID: project.ID, BranchID: branch.ID, StartLSN: s.walService.GetCurrentLSN(project.ID)}
+		return s.cancelledImport(result, t, startCollection, startOffset, startTime, ctx.Err())
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to list collections: %w", err)
 	}
+	// A fixed order makes an interrupted import's position meaningful.
+	sort.Strings(collectionNames)
+	names, warnings := selectCollections(collectionNames, opts.IncludeCollections, opts.ExcludeCollections)
+	t.progress.TotalCollections = len(names)
+	estimates := make(map[string]int64, len(names))
+	for _, collName := range names {
+		count, err := sourceDB.Collection(collName).EstimatedDocumentCount(ctx)
+		if err != nil && ctx.Err() != nil && project != nil {
+			result := &ImportResult{ProjectID: project.ID, BranchID: branch.ID, StartLSN: s.walService.GetCurrentLSN(project.ID)}
+			return s.cancelledImport(result, t, startCollection, startOffset, startTime, ctx.Err())
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to count documents in collection %s: %w", collName, err)
+		}
+		estimates[collName] = count
+		t.progress.TotalDocuments += count
+	}
+	if cp := opts.ResumeFrom; cp != nil {
+		// Progress and the ETA start from what the WAL already holds.
+		for _, collName := range names {
+			imported, err := s.walService.CountCollectionEntries(branch.ID, collName, wal.OpPut)
+			if err != nil {
+				return nil, fmt.Errorf("failed to count imported documents of %s: %w", collName, err)
+			}
+			t.progress.Documents += imported
+			if !t.parallel && collName < cp.Collection {
+				t.progress.Collections++
+			}
+		}
+		t.startDocs = t.progress.Documents
+	}
 
 	result := &ImportResult{
 		Collections: make([]string, 0),
 		Duration:    0,
+		Warnings:    warnings,
 	}
 
 	if !opts.DryRun {
 		result.ProjectID = project.ID
 		result.BranchID = branch.ID
 		result.StartLSN = s.walService.GetCurrentLSN(project.ID)
+		if job != nil {
+			result.JobID = job.ID
+		}
+	}
+
+	if !opts.DryRun && opts.Concurrency > 1 {
+		if err := s.importParallel(ctx, sourceDB, names, opts, t, result); err != nil {
+			if ctx.Err() != nil {
+				return s.cancelledImport(result, t, "", 0, startTime, ctx.Err())
+			}
+			return nil, err
+		}
+		names = nil // all imported; skip the sequential loop
 	}
 
 	// Import each collection
-	for _, collName := range collectionNames {
-		// Skip system collections
-		if isSystemCollection(collName) {
-			continue
+	for _, collName := range names {
+		var skip int64
+		if cp := opts.ResumeFrom; cp != nil && !t.parallel {
+			if collName < cp.Collection {
+				continue
+			}
+			if collName == cp.Collection {
+				skip = cp.Offset
+			}
+		}
+		if !opts.DryRun && ctx.Err() != nil {
+			return s.cancelledImport(result, t, collName, skip, startTime, ctx.Err())
 		}
 
 		if opts.DryRun {
 			// In dry run, just count documents
-			collection := sourceDB.Collection(collName)
-			count, err := collection.EstimatedDocumentCount(ctx)
-			if err != nil {
-				return nil, fmt.Errorf("failed to count documents in collection %s: %w", collName, err)
-			}
-			result.ImportedDocs += count
+			result.ImportedDocs += estimates[collName]
 			result.Collections = append(result.Collections, collName)
+			s.collectionDone(t, collName, estimates[collName])
 		} else {
+			if t.parallel && opts.ResumeFrom != nil {
+				if skip, err = s.walService.CountCollectionEntries(branch.ID, collName, wal.OpPut); err != nil {
+					return nil, fmt.Errorf("failed to find the resume position of %s: %w", collName, err)
+				}
+			}
+			// Record the position before the first batch, so a job's
+			// checkpoint never trails into an earlier collection.
+			if err := s.saveProgress(t, collName, skip); err != nil {
+				return nil, err
+			}
 			// Actually import the collection
-			imported, walEntries, err := s.importCollection(ctx, sourceDB, collName, branch, opts.BatchSize)
+			imported, batches, err := s.importCollection(ctx, sourceDB, collName, t, opts.BatchSize, opts.SourceOrder, skip)
+			result.ImportedDocs += imported
+			result.WALEntries += imported
+			result.Batches += batches
+			if err != nil && ctx.Err() != nil {
+				return s.cancelledImport(result, t, collName, skip+imported, startTime, ctx.Err())
+			}
 			if err != nil {
 				return nil, fmt.Errorf("failed to import collection %s: %w", collName, err)
 			}
-			result.ImportedDocs += imported
-			result.WALEntries += walEntries
+			indexes, err := s.importIndexesIfPending(ctx, sourceDB, collName, opts, t)
+			if err != nil {
+				return nil, err
+			}
+			result.Indexes += indexes
+			result.WALEntries += indexes
 			result.Collections = append(result.Collections, collName)
+			s.collectionDone(t, collName, 0)
 		}
 	}
 
 	if !opts.DryRun {
 		result.EndLSN = s.walService.GetCurrentLSN(project.ID)
+		slog.Info("import completed",
+			slog.String(logging.KeyProjectID, project.ID),
+			slog.String(logging.KeyBranchID, branch.ID),
+			slog.String("database", opts.DatabaseName),
+			slog.Int64("start_lsn", result.StartLSN),
+			slog.Int64("end_lsn", result.EndLSN),
+			slog.Int64("documents", result.ImportedDocs),
+			slog.Int("collections", len(result.Collections)),
+			slog.Duration("duration", time.Since(startTime)))
 		if s.onImported != nil {
 			branch, err := s.branchService.GetBranchByID(branch.ID)
 			if err == nil {
@@ -274,63 +538,194 @@ func (s *ImportService) ImportDatabase(ctx context.Context, opts ImportOptions)
 	return result, nil
 }
 
-// importCollection imports a single collection into the WAL system.
+// resumeTarget checks that a checkpoint still describes its project: the
+// same project, its main head untouched since the import stopped.
+func (s *ImportService) resumeTarget(project *wal.Project, cp *ImportCheckpoint) (*wal.Project, *wal.Branch, error) {
+	if project == nil || project.ID != cp.ProjectID {
+		return nil, nil, fmt.Errorf("checkpoint belongs to project %s, which does not exist under this name", cp.ProjectID)
+	}
+	branch, err := s.branchService.GetBranch(project.ID, "main")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get main branch: %w", err)
+	}
+	if branch.HeadLSN != cp.LSN {
+		return nil, nil, fmt.Errorf("project '%s' changed since the checkpoint (head LSN %d, checkpoint %d)", project.Name, branch.HeadLSN, cp.LSN)
+	}
+	return project, branch, nil
+}
+
+// cancelledImport completes the partial result of an import whose context
+// ended, with the checkpoint to resume it from. Every batch read before
+// the cancellation has been appended, so the checkpoint is exact.
+func (s *ImportService) cancelledImport(result *ImportResult, t *importTracker, collection string, offset int64, startTime time.Time, cause error) (*ImportResult, error) {
+	result.Incomplete = true
+	result.Checkpoint = t.checkpoint(collection, offset)
+	result.EndLSN = s.walService.GetCurrentLSN(t.branch.ProjectID)
+	result.Duration = time.Since(startTime)
+	if t.parallel {
+		return result, fmt.Errorf("import cancelled: %w", cause)
+	}
+	return result, fmt.Errorf("import cancelled (resume at collection %q, offset %d): %w", collection, offset, cause)
+}
+
+// importCollection imports a single collection into the WAL system and
+// returns how many documents it imported in how many batches.
 // Imports write put entries directly (one batched append per batch of
 // documents) instead of going through the interceptor: the target project
 // is freshly created, so per-document duplicate checks and filter
 // resolution would be pure overhead.
-func (s *ImportService) importCollection(ctx context.Context, sourceDB *mongo.Database, collectionName string, branch *wal.Branch, batchSize int) (int64, int64, error) {
+func (s *ImportService) importCollection(ctx context.Context, sourceDB *mongo.Database, collectionName string, t *importTracker, batchSize int, order string, skip int64) (int64, int64, error) {
 	collection := sourceDB.Collection(collectionName)
+	branch := t.branch
 
-	// Create a cursor to read all documents
-	cursor, err := collection.Find(ctx, bson.D{})
+	// Read in a stable order: an unsorted cursor's order is unspecified,
+	// and it becomes the LSN order of the imported history.
+	findOpts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	if order == SourceOrderNatural {
+		findOpts = options.Find().SetSort(bson.D{{Key: "$natural", Value: 1}})
+	}
+	if skip > 0 {
+		findOpts.SetSkip(skip)
+	}
+	cursor, err := collection.Find(ctx, bson.D{}, findOpts)
 	if err != nil {
 		return 0, 0, fmt.Errorf("failed to create cursor for collection %s: %w", collectionName, err)
 	}
 	defer func() { _ = cursor.Close(ctx) }()
 
-	var importedCount int64
-	var walEntriesCount int64
+	var importedCount, batches int64
 	entries := make([]*wal.Entry, 0, batchSize)
+	batchBytes := 0
+
+	flush := func() error {
+		if len(entries) == 0 {
+			return nil
+		}
+		if err := s.appendImportBatch(t, entries); err != nil {
+			return err
+		}
+		importedCount += int64(len(entries))
+		batches++
+		s.batchDone(t, collectionName, entries)
+		if err := s.saveProgress(t, collectionName, skip+importedCount); err != nil {
+			return err
+		}
+		entries = entries[:0]
+		batchBytes = 0
+		return nil
+	}
 
 	// Process documents in batches
 	for cursor.Next(ctx) {
 		var doc bson.M
 		if err := cursor.Decode(&doc); err != nil {
-			return importedCount, walEntriesCount, fmt.Errorf("failed to decode document: %w", err)
+			return importedCount, batches, fmt.Errorf("failed to decode document: %w", err)
 		}
 
 		entry, err := importEntry(branch, collectionName, doc)
 		if err != nil {
-			return importedCount, walEntriesCount, err
+			return importedCount, batches, err
+		}
+
+		// Split before a document that would push the batch over the
+		// size limit; a lone oversized document still goes on its own.
+		if batchBytes+len(entry.PostImage) > maxImportBatchBytes {
+			if err := flush(); err != nil {
+				return importedCount, batches, fmt.Errorf("failed to process batch: %w", err)
+			}
 		}
 		entries = append(entries, entry)
+		batchBytes += len(entry.PostImage)
 
-		// Process batch when it's full
+		// Process batch when it's full; a cancelled import stops here,
+		// with everything it read appended.
 		if len(entries) >= batchSize {
-			if err := s.appendImportBatch(branch, entries); err != nil {
-				return importedCount, walEntriesCount, fmt.Errorf("failed to process batch: %w", err)
+			if err := flush(); err != nil {
+				return importedCount, batches, fmt.Errorf("failed to process batch: %w", err)
+			}
+			if err := ctx.Err(); err != nil {
+				return importedCount, batches, err
 			}
-			importedCount += int64(len(entries))
-			walEntriesCount += int64(len(entries))
-			entries = entries[:0] // Reset batch
 		}
 	}
 
 	// Process remaining documents
-	if len(entries) > 0 {
-		if err := s.appendImportBatch(branch, entries); err != nil {
-			return importedCount, walEntriesCount, fmt.Errorf("failed to process final batch: %w", err)
-		}
-		importedCount += int64(len(entries))
-		walEntriesCount += int64(len(entries))
+	if err := flush(); err != nil {
+		return importedCount, batches, fmt.Errorf("failed to process final batch: %w", err)
 	}
 
 	if err := cursor.Err(); err != nil {
-		return importedCount, walEntriesCount, fmt.Errorf("cursor error: %w", err)
+		return importedCount, batches, fmt.Errorf("cursor error: %w", err)
 	}
 
-	return importedCount, walEntriesCount, nil
+	return importedCount, batches, nil
+}
+
+// importIndexes records a collection's secondary index definitions, after
+// its documents, as OpCreateIndex entries. The definitions are kept as
+// listIndexes reports them — key, name and options such as unique,
+// expireAfterSeconds or partialFilterExpression — minus the version and
+// namespace fields, which the target chooses.
+func (s *ImportService) importIndexes(ctx context.Context, sourceDB *mongo.Database, collectionName string, t *importTracker) (int64, error) {
+	branch := t.branch
+	cursor, err := sourceDB.Collection(collectionName).Indexes().List(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var listed []bson.D
+	if err := cursor.All(ctx, &listed); err != nil {
+		return 0, err
+	}
+	entries := make([]*wal.Entry, 0, len(listed))
+	for _, index := range listed {
+		spec := make(bson.D, 0, len(index))
+		for _, e := range index {
+			if e.Key != "v" && e.Key != "ns" {
+				spec = append(spec, e)
+			}
+		}
+		if wal.IndexName(spec) == "_id_" {
+			continue
+		}
+		entries = append(entries, &wal.Entry{
+			ProjectID:  branch.ProjectID,
+			BranchID:   branch.ID,
+			Operation:  wal.OpCreateIndex,
+			Collection: collectionName,
+			Actor:      "importer",
+			Metadata:   map[string]interface{}{"index": spec},
+		})
+	}
+	if len(entries) == 0 {
+		return 0, nil
+	}
+	if err := s.appendImportBatch(t, entries); err != nil {
+		return 0, err
+	}
+	return int64(len(entries)), nil
+}
+
+// importIndexesIfPending records a collection's indexes unless they are
+// not wanted or, for a collection a resume may have reached before, were
+// recorded before the import stopped.
+func (s *ImportService) importIndexesIfPending(ctx context.Context, sourceDB *mongo.Database, collectionName string, opts ImportOptions, t *importTracker) (int64, error) {
+	if opts.IncludeIndexes != nil && !*opts.IncludeIndexes {
+		return 0, nil
+	}
+	if cp := opts.ResumeFrom; cp != nil && (cp.Parallel || cp.Collection == collectionName) {
+		recorded, err := s.walService.CountCollectionEntries(t.branch.ID, collectionName, wal.OpCreateIndex)
+		if err != nil {
+			return 0, fmt.Errorf("failed to check recorded indexes of %s: %w", collectionName, err)
+		}
+		if recorded > 0 {
+			return 0, nil
+		}
+	}
+	indexes, err := s.importIndexes(ctx, sourceDB, collectionName, t)
+	if err != nil {
+		return 0, fmt.Errorf("failed to import indexes of collection %s: %w", collectionName, err)
+	}
+	return indexes, nil
 }
 
 // importEntry builds a put entry for one imported document.
@@ -354,19 +749,23 @@ func importEntry(branch *wal.Branch, collectionName string, doc bson.M) (*wal.En
 	}, nil
 }
 
-// appendImportBatch appends one batch of entries and advances the branch head.
-func (s *ImportService) appendImportBatch(branch *wal.Branch, entries []*wal.Entry) error {
+// appendImportBatch appends one batch of entries and advances the branch
+// head. AppendBatch reserves the batch its own contiguous LSN block, and
+// head updates only move forward, so parallel workers can append at once.
+func (s *ImportService) appendImportBatch(t *importTracker, entries []*wal.Entry) error {
 	lsns, err := s.walService.AppendBatch(entries)
 	if err != nil {
 		return err
 	}
 	last := lsns[len(lsns)-1]
-	if err := s.branchService.UpdateBranchHead(branch.ID, last); err != nil {
+	if err := s.branchService.UpdateBranchHead(t.branch.ID, last, entries[len(entries)-1].Timestamp); err != nil {
 		return fmt.Errorf("failed to update branch head: %w", err)
 	}
-	if last > branch.HeadLSN {
-		branch.HeadLSN = last
+	t.mu.Lock()
+	if last > t.branch.HeadLSN {
+		t.branch.HeadLSN = last
 	}
+	t.mu.Unlock()
 	return nil
 }
 
@@ -381,6 +780,30 @@ func (s *ImportService) validateImportOptions(opts ImportOptions) error {
 	if opts.ProjectName == "" {
 		return fmt.Errorf("project_name is required")
 	}
+	switch opts.SourceOrder {
+	case "", SourceOrderID, SourceOrderNatural:
+	default:
+		return fmt.Errorf("source_order must be %q or %q, got %q", SourceOrderID, SourceOrderNatural, opts.SourceOrder)
+	}
+	if opts.Concurrency < 0 {
+		return fmt.Errorf("concurrency must not be negative, got %d", opts.Concurrency)
+	}
+	if err := validatePatterns(opts.IncludeCollections); err != nil {
+		return err
+	}
+	if err := validatePatterns(opts.ExcludeCollections); err != nil {
+		return err
+	}
+	if opts.ResumeFrom != nil {
+		// Natural order is not guaranteed to repeat between reads, so an
+		// offset into it does not identify the same documents twice.
+		if opts.SourceOrder == SourceOrderNatural {
+			return fmt.Errorf("imports in %q order cannot resume", SourceOrderNatural)
+		}
+		if opts.DryRun {
+			return fmt.Errorf("a resumed import cannot be a dry run")
+		}
+	}
 	return nil
 }
 
@@ -5,22 +5,25 @@
 // time travel, diff and undo keep working on directly-written data.
 //
 // Delivery is at-least-once: the resume token is persisted after entries
-// are appended, so a crash between the two can re-deliver events. That is
-// safe by construction — puts and deletes replay idempotently — at the cost
-// of occasional duplicate entries in the log.
+// are appended, so a crash between the two can re-deliver events. Along
+// with the token the ingester records the branch head it corresponds to;
+// on restart, re-delivered events that match the entries appended past
+// that checkpoint are dropped instead of being appended twice.
 package ingest
 
 import (
+	"bytes"
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
-	"log"
+	"log/slog"
 	"sync"
 	"time"
 
 	branchwal "github.com/argon-lab/argon/internal/branch/wal"
 	"github.com/argon-lab/argon/internal/checkout"
+	"github.com/argon-lab/argon/internal/logging"
 	"github.com/argon-lab/argon/internal/wal"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -30,12 +33,19 @@ import (
 // maxBatch bounds how many change events are appended in one WAL batch.
 const maxBatch = 200
 
+// ProjectLookup resolves a branch's project for its capture policy.
+type ProjectLookup interface {
+	GetProject(projectID string) (*wal.Project, error)
+}
+
 // Service turns change streams into WAL entries.
 type Service struct {
 	client   *mongo.Client
 	wal      *wal.Service
 	branches *branchwal.BranchService
 	state    *mongo.Collection // wal_ingest_state: resume tokens per branch
+	open     SourceOpener
+	projects ProjectLookup
 
 	// Collections observed with pre/post images enabled, per run.
 	seenMu sync.Mutex
@@ -50,10 +60,19 @@ func NewService(client *mongo.Client, metaDB *mongo.Database, walService *wal.Se
 		wal:      walService,
 		branches: branches,
 		state:    metaDB.Collection("wal_ingest_state"),
+		open:     openChangeStream,
 		seen:     make(map[string]bool),
 	}
 }
 
+// SetProjectLookup wires in project resolution, so Run honors each
+// project's capture policy; without it every collection is captured. A
+// setter because the project service is built on the branch service this
+// one already depends on.
+func (s *Service) SetProjectLookup(projects ProjectLookup) {
+	s.projects = projects
+}
+
 // RunOption configures a Run invocation.
 type RunOption func(*runConfig)
 
@@ -73,7 +92,8 @@ func WithReady(ch chan<- struct{}) RunOption {
 // Run watches the branch's physical database until the context is
 // canceled, converting every data change into WAL entries and advancing
 // the branch head. It resumes from the persisted token when one exists, so
-// restarts don't lose events.
+// restarts don't lose events, and skips re-delivered events already in the
+// WAL, so they aren't recorded twice.
 func (s *Service) Run(ctx context.Context, branchID string, opts ...RunOption) error {
 	var cfg runConfig
 	for _, opt := range opts {
@@ -89,18 +109,40 @@ func (s *Service) Run(ctx context.Context, branchID string, opts ...RunOption) e
 
 	physical := s.client.Database(branch.PhysicalDB)
 
-	csOpts := options.ChangeStream().
-		SetFullDocument(options.UpdateLookup).
-		SetFullDocumentBeforeChange(options.WhenAvailable).
-		SetMaxAwaitTime(500 * time.Millisecond)
+	// The policy is read once per run: a change applies from the next.
+	var capture wal.CaptureConfig
+	if s.projects != nil {
+		project, err := s.projects.GetProject(branch.ProjectID)
+		if err != nil {
+			return fmt.Errorf("failed to load project %s: %w", branch.ProjectID, err)
+		}
+		capture = project.Capture
+	}
 
-	if token, err := s.loadResumeToken(ctx, branchID); err != nil {
+	state, err := s.loadState(ctx, branchID)
+	if err != nil {
 		return err
-	} else if token != nil {
-		csOpts.SetResumeAfter(token)
+	}
+	// Entries appended after the checkpoint whose token never got
+	// persisted: the stream re-delivers their events first. checkpoint is
+	// the LSN the next persisted token corresponds to, when no appended
+	// batch determines it.
+	var unconfirmed []*wal.Entry
+	var checkpoint int64
+	if state == nil {
+		// A fresh stream position corresponds to the current head.
+		checkpoint = branch.HeadLSN
+	} else if state.LSN > 0 && branch.HeadLSN > state.LSN {
+		if unconfirmed, err = s.unconfirmedEntries(branch, state.LSN); err != nil {
+			return err
+		}
 	}
 
-	stream, err := physical.Watch(ctx, mongo.Pipeline{}, csOpts)
+	var resumeAfter bson.Raw
+	if state != nil {
+		resumeAfter = state.ResumeToken
+	}
+	stream, err := s.open(ctx, physical, resumeAfter)
 	if err != nil {
 		return fmt.Errorf("failed to open change stream on %s: %w", branch.PhysicalDB, err)
 	}
@@ -114,22 +156,37 @@ func (s *Service) Run(ctx context.Context, branchID string, opts ...RunOption) e
 	// token a later restart would open the stream at "now" and silently
 	// skip whatever landed while unsupervised; with this checkpoint the
 	// capture gap closes at stream open instead of at the first event.
-	if err := s.flush(branch, nil, stream.ResumeToken()); err != nil {
+	if err := s.flush(branch, nil, stream.ResumeToken(), checkpoint); err != nil {
 		return err
 	}
+	checkpoint = 0
 
 	batch := make([]*wal.Entry, 0, maxBatch)
 	for {
 		if ctx.Err() != nil {
 			// Drain what we have, then stop.
-			return s.flush(branch, batch, stream.ResumeToken())
+			return s.flush(branch, batch, stream.ResumeToken(), checkpoint)
 		}
 
 		if stream.TryNext(ctx) {
-			entry, err := s.convertEvent(ctx, physical, branch, stream.Current)
+			entry, err := s.convertEvent(ctx, physical, branch, stream.Event())
 			if err != nil {
 				return err
 			}
+			if entry != nil && len(unconfirmed) > 0 {
+				if sameChange(unconfirmed[0], entry) {
+					checkpoint = unconfirmed[0].LSN
+					unconfirmed = unconfirmed[1:]
+					entry = nil
+				} else {
+					// The stream moved past the re-delivered range.
+					unconfirmed = nil
+				}
+			}
+			if entry != nil && !capture.Captures(entry.Collection) {
+				// Uncaptured: the write stays in the physical database only.
+				entry = nil
+			}
 			if entry != nil {
 				batch = append(batch, entry)
 			}
@@ -140,17 +197,18 @@ func (s *Service) Run(ctx context.Context, branchID string, opts ...RunOption) e
 
 		if err := stream.Err(); err != nil {
 			if ctx.Err() != nil {
-				return s.flush(branch, batch, stream.ResumeToken())
+				return s.flush(branch, batch, stream.ResumeToken(), checkpoint)
 			}
 			return fmt.Errorf("change stream error: %w", err)
 		}
 
 		// Stream drained (or batch full): flush and persist the token.
 		if len(batch) > 0 {
-			if err := s.flush(branch, batch, stream.ResumeToken()); err != nil {
+			if err := s.flush(branch, batch, stream.ResumeToken(), checkpoint); err != nil {
 				return err
 			}
 			batch = batch[:0]
+			checkpoint = 0
 		}
 	}
 }
@@ -195,8 +253,10 @@ func (s *Service) convertEvent(ctx context.Context, physical *mongo.Database, br
 		if len(event.FullDocument) == 0 {
 			// The document vanished between the update and the lookup; the
 			// upcoming delete event carries the removal.
-			log.Printf("ingest: skipping %s on %s without a post-image (document deleted before lookup)",
-				event.OperationType, event.NS.Collection)
+			slog.Debug("ingest: skipping change without a post-image (document deleted before lookup)",
+				slog.String(logging.KeyBranchID, branch.ID),
+				slog.String(logging.KeyOperation, event.OperationType),
+				slog.String("collection", event.NS.Collection))
 			return nil, nil
 		}
 		s.ensurePrePostImages(ctx, physical, event.NS.Collection)
@@ -227,8 +287,10 @@ func (s *Service) convertEvent(ctx context.Context, physical *mongo.Database, br
 	case "drop", "dropDatabase", "rename":
 		// Collection-level DDL carries no document images; representing it
 		// needs dedicated WAL operations (roadmap). Loud, not silent:
-		log.Printf("ingest: %s on %s is not captured in the WAL yet — branch history for this collection is now incomplete",
-			event.OperationType, event.NS.Collection)
+		slog.Warn("ingest: change not captured in the WAL yet; branch history for this collection is now incomplete",
+			slog.String(logging.KeyBranchID, branch.ID),
+			slog.String(logging.KeyOperation, event.OperationType),
+			slog.String("collection", event.NS.Collection))
 		return nil, nil
 
 	case "invalidate":
@@ -253,10 +315,12 @@ func (s *Service) ensurePrePostImages(ctx context.Context, physical *mongo.Datab
 }
 
 // flush appends a batch, advances the branch head and persists the resume
-// token — in that order, so a crash can only re-deliver, never lose. It
-// runs on its own context: durability of already-received events must not
+// token — in that order, so a crash can only re-deliver, never lose. The
+// token is checkpointed together with the LSN of the last entry appended,
+// or with lsn when the batch is empty (0 keeps the recorded one). It runs
+// on its own context: durability of already-received events must not
 // depend on whether the caller is being canceled at that instant.
-func (s *Service) flush(branch *wal.Branch, batch []*wal.Entry, token bson.Raw) error {
+func (s *Service) flush(branch *wal.Branch, batch []*wal.Entry, token bson.Raw, lsn int64) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
 	if len(batch) > 0 {
@@ -264,16 +328,21 @@ func (s *Service) flush(branch *wal.Branch, batch []*wal.Entry, token bson.Raw)
 		if err != nil {
 			return fmt.Errorf("failed to append ingested entries: %w", err)
 		}
-		if err := s.branches.UpdateBranchHead(branch.ID, lsns[len(lsns)-1]); err != nil {
+		if err := s.branches.UpdateBranchHead(branch.ID, lsns[len(lsns)-1], batch[len(batch)-1].Timestamp); err != nil {
 			return fmt.Errorf("failed to advance branch head: %w", err)
 		}
+		lsn = lsns[len(lsns)-1]
 	}
 	if len(token) == 0 {
 		return nil
 	}
+	set := bson.M{"resume_token": token, "updated_at": time.Now()}
+	if lsn > 0 {
+		set["lsn"] = lsn
+	}
 	_, err := s.state.UpdateOne(ctx,
 		bson.M{"_id": branch.ID},
-		bson.M{"$set": bson.M{"resume_token": token, "updated_at": time.Now()}},
+		bson.M{"$set": set},
 		options.Update().SetUpsert(true),
 	)
 	if err != nil {
@@ -282,18 +351,56 @@ func (s *Service) flush(branch *wal.Branch, batch []*wal.Entry, token bson.Raw)
 	return nil
 }
 
-func (s *Service) loadResumeToken(ctx context.Context, branchID string) (bson.Raw, error) {
-	var doc struct {
-		ResumeToken bson.Raw `bson:"resume_token"`
-	}
-	err := s.state.FindOne(ctx, bson.M{"_id": branchID}).Decode(&doc)
+// streamState is a branch's persisted stream position.
+type streamState struct {
+	ResumeToken bson.Raw `bson:"resume_token"`
+	// LSN is the branch head the token corresponds to: every entry
+	// ingested up to the token is at or below it. Absent in state written
+	// before it was recorded.
+	LSN int64 `bson:"lsn"`
+}
+
+// loadState returns the persisted stream position, nil when there is none.
+func (s *Service) loadState(ctx context.Context, branchID string) (*streamState, error) {
+	var state streamState
+	err := s.state.FindOne(ctx, bson.M{"_id": branchID}).Decode(&state)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			return nil, nil
 		}
 		return nil, fmt.Errorf("failed to load resume token: %w", err)
 	}
-	return doc.ResumeToken, nil
+	if state.ResumeToken == nil {
+		return nil, nil
+	}
+	return &state, nil
+}
+
+// unconfirmedEntries lists the ingested entries past the checkpoint LSN, in
+// LSN order — the ones a crash left without a persisted token.
+func (s *Service) unconfirmedEntries(branch *wal.Branch, checkpoint int64) ([]*wal.Entry, error) {
+	entries, err := s.wal.GetBranchEntries(branch.ID, "", checkpoint+1, branch.HeadLSN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load unconfirmed ingested entries: %w", err)
+	}
+	unconfirmed := entries[:0]
+	for _, e := range entries {
+		if e.Actor == "ingest" && e.IsData() {
+			unconfirmed = append(unconfirmed, e)
+		}
+	}
+	return unconfirmed, nil
+}
+
+// sameChange reports whether a re-delivered event's entry records the same
+// change as one already in the WAL.
+func sameChange(recorded, redelivered *wal.Entry) bool {
+	return recorded.Operation == redelivered.Operation &&
+		recorded.Collection == redelivered.Collection &&
+		recorded.DocumentID == redelivered.DocumentID &&
+		recorded.TxnID == redelivered.TxnID &&
+		bytes.Equal(recorded.PostImage, redelivered.PostImage) &&
+		bytes.Equal(recorded.PreImage, redelivered.PreImage)
 }
 
 // ClearResumeState drops the persisted token (used when a branch is
@@ -0,0 +1,62 @@
+package ingest
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ChangeSource is the stream of change events Run consumes. In production
+// it is a MongoDB change stream on the branch's physical database.
+type ChangeSource interface {
+	// TryNext advances to the next event without blocking indefinitely,
+	// reporting whether one is available.
+	TryNext(ctx context.Context) bool
+	// Event is the raw change event TryNext advanced to.
+	Event() bson.Raw
+	// ResumeToken is the position after the last event returned: opening a
+	// source after it delivers the next event, and none before.
+	ResumeToken() bson.Raw
+	Err() error
+	Close(ctx context.Context) error
+}
+
+// SourceOpener opens a ChangeSource on a physical database, resuming
+// after the given token when it is non-nil.
+type SourceOpener func(ctx context.Context, physical *mongo.Database, resumeAfter bson.Raw) (ChangeSource, error)
+
+// SetSourceOpener replaces how Run opens its change source; nil restores
+// the MongoDB change stream. For tests that script change events.
+func (s *Service) SetSourceOpener(open SourceOpener) {
+	if open == nil {
+		open = openChangeStream
+	}
+	s.open = open
+}
+
+// openChangeStream watches the whole physical database with exact pre- and
+// post-images.
+func openChangeStream(ctx context.Context, physical *mongo.Database, resumeAfter bson.Raw) (ChangeSource, error) {
+	csOpts := options.ChangeStream().
+		SetFullDocument(options.UpdateLookup).
+		SetFullDocumentBeforeChange(options.WhenAvailable).
+		SetMaxAwaitTime(500 * time.Millisecond)
+	if resumeAfter != nil {
+		csOpts.SetResumeAfter(resumeAfter)
+	}
+	stream, err := physical.Watch(ctx, mongo.Pipeline{}, csOpts)
+	if err != nil {
+		return nil, err
+	}
+	return changeStream{stream}, nil
+}
+
+// changeStream adapts *mongo.ChangeStream, whose current event is a field.
+type changeStream struct {
+	*mongo.ChangeStream
+}
+
+func (c changeStream) Event() bson.Raw { return c.Current }
@@ -0,0 +1,50 @@
+// Package logging configures the structured logger services write to:
+// log/slog, as text or JSON, with field names shared across services so
+// log pipelines can index them. Services log through slog's default
+// logger; Setup installs the configured one, and routes the standard
+// log package through it too.
+package logging
+
+import (
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/argon-lab/argon/internal/config"
+)
+
+// Field names. Use these rather than ad-hoc spellings, so one query finds
+// every line about a branch.
+const (
+	KeyProjectID = "project_id"
+	KeyBranchID  = "branch_id"
+	KeyLSN       = "lsn"
+	KeyOperation = "operation"
+	KeyError     = "error"
+)
+
+// New returns a logger writing format ("json" or "text") to w, dropping
+// records below level.
+func New(w io.Writer, format string, level slog.Level) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: level}
+	if format == config.LogFormatJSON {
+		return slog.New(slog.NewJSONHandler(w, opts))
+	}
+	return slog.New(slog.NewTextHandler(w, opts))
+}
+
+// Setup installs the logger cfg describes, writing to stderr, as the
+// process default.
+func Setup(cfg *config.Config) *slog.Logger {
+	logger := New(os.Stderr, cfg.LogFormat, cfg.LogLevel)
+	slog.SetDefault(logger)
+	return logger
+}
+
+// Err is the error field, omitted when err is nil.
+func Err(err error) slog.Attr {
+	if err == nil {
+		return slog.Attr{}
+	}
+	return slog.String(KeyError, err.Error())
+}
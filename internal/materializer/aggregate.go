@@ -0,0 +1,345 @@
+package materializer
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/argon-lab/argon/internal/mongoexpr"
+	"github.com/argon-lab/argon/internal/wal"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Aggregate runs a minimal aggregation pipeline over a collection at the
+// branch head. It is not MongoDB aggregation: the supported stages are
+// $match (the time-travel filter language), $group with the $sum, $avg
+// and $count accumulators, and $sort. The pipeline is validated before
+// anything is materialized.
+func (s *Service) Aggregate(branch *wal.Branch, collection string, pipeline []bson.M) ([]bson.M, error) {
+	return s.AggregateContext(context.Background(), branch, collection, pipeline)
+}
+
+// AggregateContext is Aggregate bounded by ctx.
+func (s *Service) AggregateContext(ctx context.Context, branch *wal.Branch, collection string, pipeline []bson.M) ([]bson.M, error) {
+	p, err := CompilePipeline(pipeline)
+	if err != nil {
+		return nil, err
+	}
+	state, err := s.MaterializeCollectionAtLSNContext(ctx, branch, collection, branch.HeadLSN)
+	if err != nil {
+		return nil, err
+	}
+	return p.Run(SortedDocuments(state))
+}
+
+// Pipeline is an aggregation pipeline compiled by CompilePipeline.
+type Pipeline struct {
+	stages []pipelineStage
+}
+
+// pipelineStage transforms the documents flowing through a pipeline.
+type pipelineStage func(docs []bson.M) ([]bson.M, error)
+
+// CompilePipeline validates a pipeline — each stage a one-key document
+// such as {$match: {...}} — and compiles its filters once.
+func CompilePipeline(pipeline []bson.M) (*Pipeline, error) {
+	p := &Pipeline{stages: make([]pipelineStage, 0, len(pipeline))}
+	for i, stage := range pipeline {
+		if len(stage) != 1 {
+			return nil, fmt.Errorf("pipeline stage %d must have exactly one operator, got %d", i, len(stage))
+		}
+		for name, spec := range stage {
+			var compiled pipelineStage
+			var err error
+			switch name {
+			case "$match":
+				compiled, err = compileMatchStage(spec)
+			case "$group":
+				compiled, err = compileGroupStage(spec)
+			case "$sort":
+				compiled, err = compileSortStage(spec)
+			default:
+				return nil, fmt.Errorf("unsupported aggregation stage %q (supported: $match, $group, $sort)", name)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("pipeline stage %d (%s): %w", i, name, err)
+			}
+			p.stages = append(p.stages, compiled)
+		}
+	}
+	return p, nil
+}
+
+// Run passes docs through the pipeline's stages in order.
+func (p *Pipeline) Run(docs []bson.M) ([]bson.M, error) {
+	var err error
+	for _, stage := range p.stages {
+		if docs, err = stage(docs); err != nil {
+			return nil, err
+		}
+	}
+	return docs, nil
+}
+
+func compileMatchStage(spec interface{}) (pipelineStage, error) {
+	filter, ok := stageDocument(spec)
+	if !ok {
+		return nil, fmt.Errorf("$match requires a filter document")
+	}
+	matcher, err := mongoexpr.CompileFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	return func(docs []bson.M) ([]bson.M, error) {
+		matched := make([]bson.M, 0, len(docs))
+		for _, doc := range docs {
+			ok, err := matcher.Match(doc)
+			if err != nil {
+				return nil, fmt.Errorf("failed to match document %v: %w", doc["_id"], err)
+			}
+			if ok {
+				matched = append(matched, doc)
+			}
+		}
+		return matched, nil
+	}, nil
+}
+
+// accumulator is one compiled $group output field.
+type accumulator struct {
+	field string
+	op    string // $sum, $avg or $count
+	expr  interface{}
+}
+
+// groupState folds one group's documents.
+type groupState struct {
+	id     interface{}
+	ints   []int64   // per accumulator: sum of integer values
+	floats []float64 // per accumulator: sum of other numeric values
+	float  []bool    // per accumulator: whether any value was not an integer
+	counts []int64   // per accumulator: values (or documents) seen
+}
+
+func compileGroupStage(spec interface{}) (pipelineStage, error) {
+	group, ok := stageDocument(spec)
+	if !ok {
+		return nil, fmt.Errorf("$group requires a document")
+	}
+	idExpr, ok := group["_id"]
+	if !ok {
+		return nil, fmt.Errorf("$group requires an _id (null groups every document together)")
+	}
+	fields := make([]string, 0, len(group))
+	for field := range group {
+		if field != "_id" {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+	accs := make([]accumulator, 0, len(fields))
+	for _, field := range fields {
+		acc, ok := stageDocument(group[field])
+		if !ok || len(acc) != 1 {
+			return nil, fmt.Errorf("$group field %q must be a single accumulator such as {$sum: \"$total\"}", field)
+		}
+		for op, expr := range acc {
+			switch op {
+			case "$sum", "$avg":
+			case "$count":
+				if args, ok := stageDocument(expr); !ok || len(args) != 0 {
+					return nil, fmt.Errorf("$count in $group field %q takes no arguments: {$count: {}}", field)
+				}
+			default:
+				return nil, fmt.Errorf("unsupported $group accumulator %q in field %q (supported: $sum, $avg, $count)", op, field)
+			}
+			accs = append(accs, accumulator{field: field, op: op, expr: expr})
+		}
+	}
+
+	return func(docs []bson.M) ([]bson.M, error) {
+		groups := make(map[string]*groupState)
+		var order []*groupState
+		for _, doc := range docs {
+			id := evalExpression(doc, idExpr)
+			key, err := mongoexpr.CanonicalBytes(id)
+			if err != nil {
+				return nil, fmt.Errorf("cannot group by %v: %w", id, err)
+			}
+			g, ok := groups[string(key)]
+			if !ok {
+				g = &groupState{
+					id:     id,
+					ints:   make([]int64, len(accs)),
+					floats: make([]float64, len(accs)),
+					float:  make([]bool, len(accs)),
+					counts: make([]int64, len(accs)),
+				}
+				groups[string(key)] = g
+				order = append(order, g)
+			}
+			for i, acc := range accs {
+				if acc.op == "$count" {
+					g.counts[i]++
+					continue
+				}
+				g.add(i, evalExpression(doc, acc.expr))
+			}
+		}
+
+		// Groups come out in _id order, so results are stable run to run.
+		sort.SliceStable(order, func(i, j int) bool { return compareSortValues(order[i].id, order[j].id) < 0 })
+		out := make([]bson.M, 0, len(order))
+		for _, g := range order {
+			result := bson.M{"_id": g.id}
+			for i, acc := range accs {
+				result[acc.field] = g.result(i, acc.op)
+			}
+			out = append(out, result)
+		}
+		return out, nil
+	}, nil
+}
+
+// add folds a numeric value into accumulator i. Non-numeric and missing
+// values are ignored, as MongoDB's $sum and $avg ignore them.
+func (g *groupState) add(i int, v interface{}) {
+	switch n := v.(type) {
+	case int:
+		g.ints[i] += int64(n)
+	case int32:
+		g.ints[i] += int64(n)
+	case int64:
+		g.ints[i] += n
+	case float64, primitive.Decimal128:
+		g.floats[i] += toSortFloat(n)
+		g.float[i] = true
+	default:
+		return
+	}
+	g.counts[i]++
+}
+
+// result is accumulator i's final value: $sum is an int64 while every
+// value was an integer and a float64 otherwise; $avg is a float64, or nil
+// when the group had no numeric values.
+func (g *groupState) result(i int, op string) interface{} {
+	switch op {
+	case "$count":
+		return g.counts[i]
+	case "$avg":
+		if g.counts[i] == 0 {
+			return nil
+		}
+		return (float64(g.ints[i]) + g.floats[i]) / float64(g.counts[i])
+	}
+	if g.float[i] {
+		return float64(g.ints[i]) + g.floats[i]
+	}
+	return g.ints[i]
+}
+
+// evalExpression evaluates a $group expression against doc: "$field.path"
+// reads a field (nil when missing), documents evaluate each value, and
+// anything else is a constant.
+func evalExpression(doc bson.M, expr interface{}) interface{} {
+	switch e := expr.(type) {
+	case string:
+		if strings.HasPrefix(e, "$") {
+			return fieldValue(doc, e[1:])
+		}
+	case bson.M:
+		out := make(bson.M, len(e))
+		for k, v := range e {
+			out[k] = evalExpression(doc, v)
+		}
+		return out
+	case bson.D:
+		out := make(bson.D, len(e))
+		for i, elem := range e {
+			out[i] = bson.E{Key: elem.Key, Value: evalExpression(doc, elem.Value)}
+		}
+		return out
+	}
+	return expr
+}
+
+// sortKey is one field of a $sort specification.
+type sortKey struct {
+	field string
+	desc  bool
+}
+
+func compileSortStage(spec interface{}) (pipelineStage, error) {
+	var keys []sortKey
+	add := func(field string, dir interface{}) error {
+		switch toSortFloat(dir) {
+		case 1:
+			keys = append(keys, sortKey{field: field})
+		case -1:
+			keys = append(keys, sortKey{field: field, desc: true})
+		default:
+			return fmt.Errorf("$sort direction for %q must be 1 or -1", field)
+		}
+		return nil
+	}
+	switch s := spec.(type) {
+	case bson.D:
+		for _, e := range s {
+			if err := add(e.Key, e.Value); err != nil {
+				return nil, err
+			}
+		}
+	case bson.M:
+		// A map has no key order, so only one key is unambiguous.
+		if len(s) > 1 {
+			return nil, fmt.Errorf("$sort on several fields needs an ordered document")
+		}
+		for field, dir := range s {
+			if err := add(field, dir); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("$sort requires a document")
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("$sort requires at least one field")
+	}
+
+	return func(docs []bson.M) ([]bson.M, error) {
+		sort.SliceStable(docs, func(i, j int) bool {
+			for _, key := range keys {
+				c := compareSortValues(fieldValue(docs[i], key.field), fieldValue(docs[j], key.field))
+				if c == 0 {
+					continue
+				}
+				if key.desc {
+					return c > 0
+				}
+				return c < 0
+			}
+			return false
+		})
+		return docs, nil
+	}, nil
+}
+
+// stageDocument reads a stage operand as a document.
+func stageDocument(v interface{}) (bson.M, bool) {
+	switch d := v.(type) {
+	case bson.M:
+		return d, true
+	case map[string]interface{}:
+		return d, true
+	case bson.D:
+		m := make(bson.M, len(d))
+		for _, e := range d {
+			m[e.Key] = e.Value
+		}
+		return m, true
+	}
+	return nil, false
+}
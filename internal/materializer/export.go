@@ -0,0 +1,151 @@
+package materializer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/argon-lab/argon/internal/redact"
+	"github.com/argon-lab/argon/internal/wal"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// exportBatchSize bounds bulk-insert batches during an export.
+const exportBatchSize = 1000
+
+// ExportResult describes a completed export.
+type ExportResult struct {
+	Database    string
+	LSN         int64
+	Collections int
+	Documents   int64
+	Indexes     int
+}
+
+// ExportToDatabase materializes every collection of a branch as of atLSN
+// (0 means the head) and bulk-inserts the documents into targetDBName on
+// the deployment at targetURI. Collections are materialized and inserted
+// one at a time, so an export holds at most one collection's state in
+// memory. Documents keep their stored _id values and types, and recorded
+// secondary indexes (see IndexesAtLSN) are recreated once the documents
+// are in. The target database must be empty unless drop is set, in which
+// case it is dropped first; Argon's own databases — the WAL's and
+// checked-out branches' — are never a target. Unlike a checkout, the
+// exported database is not tracked: later writes to it are not captured.
+func (s *Service) ExportToDatabase(branch *wal.Branch, targetURI, targetDBName string, atLSN int64, drop bool) (*ExportResult, error) {
+	ctx := context.Background()
+	if targetDBName == "" {
+		return nil, fmt.Errorf("target database name must not be empty")
+	}
+	if targetDBName == s.wal.DatabaseName() || strings.HasPrefix(targetDBName, wal.PhysicalDBPrefix) {
+		return nil, fmt.Errorf("target database %q belongs to Argon and cannot be exported into", targetDBName)
+	}
+	if atLSN == 0 {
+		atLSN = branch.HeadLSN
+	}
+	if atLSN < 0 || atLSN > branch.HeadLSN {
+		return nil, fmt.Errorf("export LSN %d is beyond branch HEAD %d", atLSN, branch.HeadLSN)
+	}
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(targetURI))
+	if err != nil {
+		return nil, redact.Error(fmt.Errorf("failed to connect to export target: %w", err), targetURI)
+	}
+	defer func() { _ = client.Disconnect(ctx) }()
+	target := client.Database(targetDBName)
+
+	existing, err := target.ListCollectionNames(ctx, bson.M{})
+	if err != nil {
+		return nil, redact.Error(fmt.Errorf("failed to inspect export target: %w", err), targetURI)
+	}
+	if len(existing) > 0 {
+		if !drop {
+			return nil, fmt.Errorf("target database %q is not empty (%d collection(s)); drop it first or export with drop",
+				targetDBName, len(existing))
+		}
+		if err := target.Drop(ctx); err != nil {
+			return nil, redact.Error(fmt.Errorf("failed to drop export target: %w", err), targetURI)
+		}
+	}
+
+	collections, err := s.CollectionsAtLSN(branch, atLSN)
+	if err != nil {
+		return nil, err
+	}
+	indexes, err := s.IndexesAtLSN(branch, atLSN)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ExportResult{Database: targetDBName, LSN: atLSN}
+	for _, collection := range collections {
+		docs, err := s.MaterializeCollectionAtLSNContext(ctx, branch, collection, atLSN)
+		if err != nil {
+			return nil, fmt.Errorf("failed to materialize %s: %w", collection, err)
+		}
+		count, err := exportCollection(ctx, target.Collection(collection), docs)
+		if err != nil {
+			return nil, redact.Error(fmt.Errorf("collection %s: %w", collection, err), targetURI)
+		}
+		result.Collections++
+		result.Documents += count
+		if specs := indexes[collection]; len(specs) > 0 {
+			if err := createIndexes(ctx, target, collection, specs); err != nil {
+				return nil, redact.Error(fmt.Errorf("collection %s: %w", collection, err), targetURI)
+			}
+			result.Indexes += len(specs)
+		}
+	}
+	return result, nil
+}
+
+// createIndexes recreates recorded index definitions after the documents
+// are in. The definitions go to createIndexes as recorded, so options
+// (unique, TTL, partial filters, collations) carry over unchanged.
+func createIndexes(ctx context.Context, db *mongo.Database, collection string, specs []bson.D) error {
+	list := make(bson.A, len(specs))
+	for i, spec := range specs {
+		list[i] = spec
+	}
+	err := db.RunCommand(ctx, bson.D{
+		{Key: "createIndexes", Value: collection},
+		{Key: "indexes", Value: list},
+	}).Err()
+	if err != nil {
+		return fmt.Errorf("failed to create indexes: %w", err)
+	}
+	return nil
+}
+
+// exportCollection bulk-inserts one collection's documents in _id order,
+// creating the collection even when it is empty.
+func exportCollection(ctx context.Context, coll *mongo.Collection, docs map[string]bson.M) (int64, error) {
+	if len(docs) == 0 {
+		err := coll.Database().CreateCollection(ctx, coll.Name())
+		return 0, err
+	}
+	batch := make([]interface{}, 0, exportBatchSize)
+	var total int64
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if _, err := coll.InsertMany(ctx, batch, options.InsertMany().SetOrdered(false)); err != nil {
+			return fmt.Errorf("failed to insert documents: %w", err)
+		}
+		total += int64(len(batch))
+		batch = batch[:0]
+		return nil
+	}
+	for _, doc := range SortedDocuments(docs) {
+		batch = append(batch, doc)
+		if len(batch) >= exportBatchSize {
+			if err := flush(); err != nil {
+				return total, err
+			}
+		}
+	}
+	return total, flush()
+}
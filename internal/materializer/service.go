@@ -8,10 +8,14 @@
 package materializer
 
 import (
+	"context"
 	"fmt"
+	"sort"
+	"time"
 
 	"github.com/argon-lab/argon/internal/wal"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // BranchLookup resolves branch metadata during ancestry traversal.
@@ -38,6 +42,8 @@ type Service struct {
 	wal       *wal.Service
 	branches  BranchLookup
 	snapshots SnapshotSource
+	metrics   *wal.Metrics
+	slow      *wal.SlowQueryLog
 }
 
 // NewService creates a new materializer service
@@ -45,9 +51,25 @@ func NewService(walService *wal.Service, branches BranchLookup) *Service {
 	return &Service{
 		wal:      walService,
 		branches: branches,
+		metrics:  wal.GlobalMetrics,
+		slow:     wal.NewSlowQueryLog(wal.DefaultSlowQueryThreshold, 0),
 	}
 }
 
+// SetMetrics replaces the metrics collection that materializations are
+// recorded in (the global collector by default).
+func (s *Service) SetMetrics(m *wal.Metrics) {
+	s.metrics = m
+}
+
+// SlowQueries returns the log of collection materializations that
+// exceeded the slow-query threshold. The log belongs to this service, not
+// to the metrics collection, so tuning its threshold never affects another
+// service sharing the global collector.
+func (s *Service) SlowQueries() *wal.SlowQueryLog {
+	return s.slow
+}
+
 // SetSnapshotSource wires in a snapshot provider. A setter rather than a
 // constructor argument because the snapshot service itself needs the
 // materializer to build snapshots — the two reference each other.
@@ -55,6 +77,16 @@ func (s *Service) SetSnapshotSource(src SnapshotSource) {
 	s.snapshots = src
 }
 
+// WithWAL returns a materializer reading entries through walService — a
+// read-preference view, for a read path kept off the primary. It keeps
+// this service's branch lookup, snapshot source, metrics and slow-query
+// log as wired at the time of the call.
+func (s *Service) WithWAL(walService *wal.Service) *Service {
+	view := *s
+	view.wal = walService
+	return &view
+}
+
 // segment is one ancestor's contribution to a branch's history: the
 // ancestor's own entries in [fromLSN, toLSN], minus its discarded ranges.
 type segment struct {
@@ -115,21 +147,107 @@ func (s *Service) ancestrySegments(branch *wal.Branch, targetLSN int64) ([]segme
 // targetLSN, following the branch's ancestry chain. When a snapshot source
 // is wired in, replay starts from the nearest usable snapshot (searching
 // leaf-most hop first, since a leaf snapshot covers the entire inherited
-// chain beneath it) and only the delta above it is replayed.
+// chain beneath it) and only the delta above it is replayed. Any entry that
+// fails to apply fails the whole call.
 func (s *Service) MaterializeCollectionAtLSN(branch *wal.Branch, collection string, targetLSN int64) (map[string]bson.M, error) {
+	return s.MaterializeCollectionAtLSNContext(context.Background(), branch, collection, targetLSN)
+}
+
+// MaterializeCollectionAtLSNContext is MaterializeCollectionAtLSN bounded
+// by ctx: cancellation or an expired deadline stops the replay between
+// segments and aborts in-flight WAL reads, returning ctx's error.
+func (s *Service) MaterializeCollectionAtLSNContext(ctx context.Context, branch *wal.Branch, collection string, targetLSN int64) (map[string]bson.M, error) {
+	state, _, err := s.materializeCollection(ctx, branch, collection, targetLSN, replayOptions{})
+	return state, err
+}
+
+// MaterializeCollectionCommittedAtLSN is MaterializeCollectionAtLSN for
+// stricter read consistency: entries still pending confirmation (see
+// wal.Service.AppendPending) are skipped. Snapshots may contain pending
+// writes, so this always replays from the branch root.
+func (s *Service) MaterializeCollectionCommittedAtLSN(branch *wal.Branch, collection string, targetLSN int64) (map[string]bson.M, error) {
+	state, _, err := s.materializeCollection(context.Background(), branch, collection, targetLSN, replayOptions{committedOnly: true})
+	return state, err
+}
+
+// SkippedEntry records a WAL entry that lenient materialization could not
+// apply.
+type SkippedEntry struct {
+	LSN        int64  `json:"lsn"`
+	BranchID   string `json:"branch_id"`
+	DocumentID string `json:"document_id,omitempty"`
+	Reason     string `json:"reason"`
+}
+
+// MaterializeCollectionAtLSNLenient is MaterializeCollectionAtLSN for
+// reads that must not be blocked by one bad entry (e.g. a corrupt
+// post-image): entries that fail to apply are skipped and reported, and
+// replay continues. The returned state is only as correct as the skipped
+// entries allow — a skipped put leaves the document at its previous state.
+// Failures to read the WAL itself still fail the call.
+func (s *Service) MaterializeCollectionAtLSNLenient(branch *wal.Branch, collection string, targetLSN int64) (map[string]bson.M, []SkippedEntry, error) {
+	return s.materializeCollection(context.Background(), branch, collection, targetLSN, replayOptions{lenient: true})
+}
+
+// replayOptions tunes a collection replay. lenient skips entries that fail
+// to apply instead of failing; committedOnly skips pending entries.
+type replayOptions struct {
+	lenient       bool
+	committedOnly bool
+}
+
+// materializeCollection times the replay and records it in the metrics;
+// reads below the head are logged as time travel.
+func (s *Service) materializeCollection(ctx context.Context, branch *wal.Branch, collection string, targetLSN int64, opts replayOptions) (map[string]bson.M, []SkippedEntry, error) {
+	start := time.Now()
+	state, skipped, stats, err := s.replayCollection(ctx, branch, collection, targetLSN, opts)
+	elapsed := time.Since(start)
+
+	s.metrics.RecordMaterialization(elapsed, err == nil)
+	kind := wal.SlowQueryMaterialize
+	if targetLSN < branch.HeadLSN {
+		kind = wal.SlowQueryTimeTravel
+	}
+	s.slow.Observe(wal.SlowQuery{
+		Kind:            kind,
+		ProjectID:       branch.ProjectID,
+		BranchID:        branch.ID,
+		BranchName:      branch.Name,
+		Collection:      collection,
+		FromLSN:         stats.fromLSN,
+		ToLSN:           targetLSN,
+		EntriesReplayed: stats.replayed,
+		Duration:        elapsed,
+	})
+	return state, skipped, err
+}
+
+// replayStats describes the work a collection replay did: the first LSN
+// replayed (above any snapshot it started from) and how many entries were
+// applied.
+type replayStats struct {
+	fromLSN  int64
+	replayed int
+}
+
+func (s *Service) replayCollection(ctx context.Context, branch *wal.Branch, collection string, targetLSN int64, opts replayOptions) (map[string]bson.M, []SkippedEntry, replayStats, error) {
+	var stats replayStats
+	if err := ctx.Err(); err != nil {
+		return nil, nil, stats, err
+	}
 	segments, err := s.ancestrySegments(branch, targetLSN)
 	if err != nil {
-		return nil, err
+		return nil, nil, stats, err
 	}
 
 	state := make(map[string]bson.M)
 	startIdx := 0
-	if s.snapshots != nil {
+	if s.snapshots != nil && !opts.committedOnly {
 		for i := len(segments) - 1; i >= 0; i-- {
 			seg := segments[i]
 			snapState, snapLSN, ok, err := s.snapshots.FindUsable(seg.branch, collection, seg.fromLSN, seg.toLSN, seg.toLSN)
 			if err != nil {
-				return nil, fmt.Errorf("failed to look up snapshot for branch %s: %w", seg.branch.ID, err)
+				return nil, nil, stats, fmt.Errorf("failed to look up snapshot for branch %s: %w", seg.branch.ID, err)
 			}
 			if ok {
 				// A snapshot's state already covers everything at or below
@@ -143,25 +261,45 @@ func (s *Service) MaterializeCollectionAtLSN(branch *wal.Branch, collection stri
 		}
 	}
 
+	if startIdx < len(segments) {
+		stats.fromLSN = segments[startIdx].fromLSN
+	}
+
+	var skipped []SkippedEntry
 	for _, seg := range segments[startIdx:] {
 		if seg.fromLSN > seg.toLSN {
 			continue // Snapshot sits exactly at the segment's end.
 		}
-		entries, err := s.wal.GetBranchEntries(seg.branch.ID, collection, seg.fromLSN, seg.toLSN)
+		if err := ctx.Err(); err != nil {
+			return nil, nil, stats, err
+		}
+		entries, err := s.wal.GetReplayEntries(ctx, seg.branch, collection, "", seg.fromLSN, seg.toLSN)
 		if err != nil {
-			return nil, fmt.Errorf("failed to get entries for branch %s: %w", seg.branch.ID, err)
+			return nil, nil, stats, fmt.Errorf("failed to get entries for branch %s: %w", seg.branch.ID, err)
 		}
 		for _, entry := range entries {
 			if seg.branch.IsDiscardedForRead(entry.LSN, seg.toLSN) {
 				continue
 			}
+			if opts.committedOnly && entry.Pending {
+				continue
+			}
+			stats.replayed++
 			if err := s.ApplyEntry(state, entry); err != nil {
-				return nil, fmt.Errorf("failed to apply entry LSN %d: %w", entry.LSN, err)
+				if !opts.lenient {
+					return nil, nil, stats, fmt.Errorf("failed to apply entry LSN %d: %w", entry.LSN, err)
+				}
+				skipped = append(skipped, SkippedEntry{
+					LSN:        entry.LSN,
+					BranchID:   entry.BranchID,
+					DocumentID: entry.DocumentID,
+					Reason:     err.Error(),
+				})
 			}
 		}
 	}
 
-	return state, nil
+	return state, skipped, stats, nil
 }
 
 // MaterializeCollection builds the current state of a collection for a branch
@@ -169,11 +307,120 @@ func (s *Service) MaterializeCollection(branch *wal.Branch, collection string) (
 	return s.MaterializeCollectionAtLSN(branch, collection, branch.HeadLSN)
 }
 
+// ApplyEntriesFrom advances a caller-held state of one collection from
+// fromLSN to toLSN: initialState must be the collection as of fromLSN (nil
+// means empty), and only the entries in (fromLSN, toLSN] along the
+// branch's ancestry are applied. This lets external consumers keep their
+// own cache and update it incrementally instead of rematerializing.
+//
+// The result is a new map; initialState is left untouched so it remains a
+// valid state at fromLSN. A reset that happened between the two bounds can
+// retroactively hide entries already folded into initialState; that case
+// is reported as an error, and the caller must rematerialize in full.
+func (s *Service) ApplyEntriesFrom(initialState map[string]bson.M, branch *wal.Branch, collection string, fromLSN, toLSN int64) (map[string]bson.M, error) {
+	if fromLSN < 0 || fromLSN > toLSN {
+		return nil, fmt.Errorf("invalid LSN range (%d, %d]", fromLSN, toLSN)
+	}
+	if toLSN > branch.HeadLSN {
+		return nil, fmt.Errorf("target LSN %d is beyond branch HEAD %d", toLSN, branch.HeadLSN)
+	}
+
+	segments, err := s.ancestrySegments(branch, toLSN)
+	if err != nil {
+		return nil, err
+	}
+	initialSegments, err := s.ancestrySegments(branch, fromLSN)
+	if err != nil {
+		return nil, err
+	}
+	initialBound := make(map[string]int64, len(initialSegments))
+	for _, seg := range initialSegments {
+		initialBound[seg.branch.ID] = seg.toLSN
+	}
+	for _, seg := range segments {
+		bound, ok := initialBound[seg.branch.ID]
+		if !ok {
+			continue
+		}
+		for _, r := range seg.branch.DiscardedRanges {
+			if r.From <= bound && bound <= r.To && seg.toLSN > r.To {
+				return nil, fmt.Errorf("branch %s discarded LSNs %d-%d after LSN %d was read: rematerialize instead of applying incrementally", seg.branch.Name, r.From, r.To, fromLSN)
+			}
+		}
+	}
+
+	state := make(map[string]bson.M, len(initialState))
+	for id, doc := range initialState {
+		state[id] = doc
+	}
+	for _, seg := range segments {
+		from := seg.fromLSN
+		if from <= fromLSN {
+			from = fromLSN + 1
+		}
+		if from > seg.toLSN {
+			continue
+		}
+		if from == fromLSN+1 {
+			// initialState left out any transaction fromLSN falls inside;
+			// its earlier entries are applied here, with the rest of it.
+			safe, err := s.wal.TxnSafeLSN(seg.branch.ID, fromLSN)
+			if err != nil {
+				return nil, err
+			}
+			from = safe + 1
+			if from < seg.fromLSN {
+				from = seg.fromLSN
+			}
+		}
+		entries, err := s.wal.GetReplayEntries(context.Background(), seg.branch, collection, "", from, seg.toLSN)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get entries for branch %s: %w", seg.branch.ID, err)
+		}
+		for _, entry := range entries {
+			if seg.branch.IsDiscardedForRead(entry.LSN, seg.toLSN) {
+				continue
+			}
+			if err := s.ApplyEntry(state, entry); err != nil {
+				return nil, fmt.Errorf("failed to apply entry LSN %d: %w", entry.LSN, err)
+			}
+		}
+	}
+	return state, nil
+}
+
 // MaterializeBranchAtLSN builds the state of every collection in a branch as
 // of targetLSN, keyed by collection name. Collections are discovered across
 // the ancestry chain (entries and snapshots) and each is materialized
 // through the snapshot-aware single-collection path.
 func (s *Service) MaterializeBranchAtLSN(branch *wal.Branch, targetLSN int64) (map[string]map[string]bson.M, error) {
+	return s.MaterializeBranchAtLSNContext(context.Background(), branch, targetLSN)
+}
+
+// MaterializeBranchAtLSNContext is MaterializeBranchAtLSN bounded by ctx.
+func (s *Service) MaterializeBranchAtLSNContext(ctx context.Context, branch *wal.Branch, targetLSN int64) (map[string]map[string]bson.M, error) {
+	collections, err := s.CollectionsAtLSN(branch, targetLSN)
+	if err != nil {
+		return nil, err
+	}
+
+	state := make(map[string]map[string]bson.M, len(collections))
+	for _, name := range collections {
+		collState, err := s.MaterializeCollectionAtLSNContext(ctx, branch, name, targetLSN)
+		if err != nil {
+			return nil, err
+		}
+		state[name] = collState
+	}
+
+	return state, nil
+}
+
+// CollectionsAtLSN lists, sorted, the collections with history in a
+// branch's ancestry up to targetLSN (entries and snapshots) — those
+// MaterializeBranchAtLSN would build — without materializing any. Callers
+// that must not hold a whole branch in memory materialize them one by one.
+func (s *Service) CollectionsAtLSN(branch *wal.Branch, targetLSN int64) ([]string, error) {
 	segments, err := s.ancestrySegments(branch, targetLSN)
 	if err != nil {
 		return nil, err
@@ -199,16 +446,62 @@ func (s *Service) MaterializeBranchAtLSN(branch *wal.Branch, targetLSN int64) (m
 		}
 	}
 
-	state := make(map[string]map[string]bson.M, len(collections))
+	names := make([]string, 0, len(collections))
 	for name := range collections {
-		collState, err := s.MaterializeCollectionAtLSN(branch, name, targetLSN)
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// IndexesAtLSN returns the secondary index definitions recorded
+// (OpCreateIndex) in a branch's ancestry up to targetLSN, keyed by
+// collection, each collection's in recording order. A later definition
+// with the same name replaces an earlier one.
+func (s *Service) IndexesAtLSN(branch *wal.Branch, targetLSN int64) (map[string][]bson.D, error) {
+	segments, err := s.ancestrySegments(branch, targetLSN)
+	if err != nil {
+		return nil, err
+	}
+	indexes := make(map[string][]bson.D)
+	for _, seg := range segments {
+		entries, err := s.wal.GetEntries(bson.M{
+			"branch_id": seg.branch.ID,
+			"operation": wal.OpCreateIndex,
+			"lsn":       bson.M{"$gte": seg.fromLSN, "$lte": seg.toLSN},
+		}, options.Find().SetSort(bson.M{"lsn": 1}))
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("failed to list indexes for branch %s: %w", seg.branch.ID, err)
+		}
+		for _, entry := range entries {
+			if seg.branch.IsDiscardedForRead(entry.LSN, seg.toLSN) {
+				continue
+			}
+			spec, err := entry.IndexSpec()
+			if err != nil {
+				return nil, err
+			}
+			indexes[entry.Collection] = replaceIndex(indexes[entry.Collection], spec)
 		}
-		state[name] = collState
 	}
+	return indexes, nil
+}
 
-	return state, nil
+// replaceIndex adds spec to specs, in place of a same-named definition.
+func replaceIndex(specs []bson.D, spec bson.D) []bson.D {
+	for i, existing := range specs {
+		if wal.IndexName(existing) == wal.IndexName(spec) {
+			specs[i] = spec
+			return specs
+		}
+	}
+	return append(specs, spec)
+}
+
+// TxnSafeLSN moves an LSN that falls inside one of the branch's
+// transactions to just before it; see wal.Service.TxnSafeLSN.
+func (s *Service) TxnSafeLSN(branch *wal.Branch, lsn int64) (int64, error) {
+	return s.wal.TxnSafeLSN(branch.ID, lsn)
 }
 
 // MaterializeBranch builds the complete current state of all collections in
@@ -217,36 +510,90 @@ func (s *Service) MaterializeBranch(branch *wal.Branch) (map[string]map[string]b
 	return s.MaterializeBranchAtLSN(branch, branch.HeadLSN)
 }
 
+// HistoryAtLSN returns the entries whose replay yields the branch's state
+// at targetLSN, across every collection and in LSN order: each ancestor's
+// own entries within its segment, as replay reads them. Snapshots are not
+// consulted, so history that garbage collection has already folded into a
+// snapshot is missing from the result.
+func (s *Service) HistoryAtLSN(branch *wal.Branch, targetLSN int64) ([]*wal.Entry, error) {
+	ctx := context.Background()
+	segments, err := s.ancestrySegments(branch, targetLSN)
+	if err != nil {
+		return nil, err
+	}
+
+	var history []*wal.Entry
+	for _, seg := range segments {
+		names, err := s.wal.DistinctCollections(seg.branch.ID, seg.fromLSN, seg.toLSN)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list collections for branch %s: %w", seg.branch.ID, err)
+		}
+		var entries []*wal.Entry
+		for _, name := range names {
+			collEntries, err := s.wal.GetReplayEntries(ctx, seg.branch, name, "", seg.fromLSN, seg.toLSN)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get entries for branch %s: %w", seg.branch.ID, err)
+			}
+			for _, entry := range collEntries {
+				if !seg.branch.IsDiscardedForRead(entry.LSN, seg.toLSN) {
+					entries = append(entries, entry)
+				}
+			}
+		}
+		// Segments are disjoint and root-first, so sorting within each
+		// keeps the whole history in LSN order.
+		sort.Slice(entries, func(i, j int) bool { return entries[i].LSN < entries[j].LSN })
+		history = append(history, entries...)
+	}
+	return history, nil
+}
+
 // MaterializeDocumentAtLSN reconstructs one document as of targetLSN, or nil
 // if it does not exist at that point. This is a point lookup: it reads only
 // the document's own history via the (branch, collection, document, lsn)
 // index instead of replaying the whole collection.
 func (s *Service) MaterializeDocumentAtLSN(branch *wal.Branch, collection, documentID string, targetLSN int64) (bson.M, error) {
-	segments, err := s.ancestrySegments(branch, targetLSN)
+	entries, err := s.DocumentEntriesAtLSN(branch, collection, documentID, targetLSN)
 	if err != nil {
 		return nil, err
 	}
 
 	state := make(map[string]bson.M)
+	for _, entry := range entries {
+		if err := s.ApplyEntry(state, entry); err != nil {
+			return nil, fmt.Errorf("failed to apply entry LSN %d: %w", entry.LSN, err)
+		}
+	}
+
+	if doc, exists := state[documentID]; exists {
+		return doc, nil
+	}
+	return nil, nil // Document doesn't exist or was deleted.
+}
+
+// DocumentEntriesAtLSN returns the entries that make up one document's
+// state as of targetLSN, in replay order: its own history across the
+// ancestry chain, without entries discarded by resets or superseded by
+// compaction.
+func (s *Service) DocumentEntriesAtLSN(branch *wal.Branch, collection, documentID string, targetLSN int64) ([]*wal.Entry, error) {
+	segments, err := s.ancestrySegments(branch, targetLSN)
+	if err != nil {
+		return nil, err
+	}
+
+	var visible []*wal.Entry
 	for _, seg := range segments {
-		entries, err := s.wal.GetDocumentHistory(seg.branch.ID, collection, documentID, seg.fromLSN, seg.toLSN)
+		entries, err := s.wal.GetReplayEntries(context.Background(), seg.branch, collection, documentID, seg.fromLSN, seg.toLSN)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get document history for branch %s: %w", seg.branch.ID, err)
 		}
 		for _, entry := range entries {
-			if seg.branch.IsDiscardedForRead(entry.LSN, seg.toLSN) {
-				continue
-			}
-			if err := s.ApplyEntry(state, entry); err != nil {
-				return nil, fmt.Errorf("failed to apply entry LSN %d: %w", entry.LSN, err)
+			if !seg.branch.IsDiscardedForRead(entry.LSN, seg.toLSN) {
+				visible = append(visible, entry)
 			}
 		}
 	}
-
-	if doc, exists := state[documentID]; exists {
-		return doc, nil
-	}
-	return nil, nil // Document doesn't exist or was deleted.
+	return visible, nil
 }
 
 // MaterializeDocument gets the current state of a specific document.
@@ -0,0 +1,168 @@
+package materializer
+
+import (
+	"bytes"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/argon-lab/argon/internal/mongoexpr"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SortedDocuments flattens a materialized collection into a slice ordered
+// by _id. Materialized state is a map, so without this every read would
+// come back in a different order run to run.
+func SortedDocuments(state map[string]bson.M) []bson.M {
+	ids := make([]string, 0, len(state))
+	for id := range state {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if c := compareSortValues(state[ids[i]]["_id"], state[ids[j]]["_id"]); c != 0 {
+			return c < 0
+		}
+		return ids[i] < ids[j]
+	})
+	docs := make([]bson.M, len(ids))
+	for i, id := range ids {
+		docs[i] = state[id]
+	}
+	return docs
+}
+
+// SortDocuments orders docs by a (possibly dotted) field, descending when
+// desc is set. The sort is stable, so documents with equal values keep
+// their incoming order — the _id order when docs came from
+// SortedDocuments.
+func SortDocuments(docs []bson.M, field string, desc bool) {
+	sort.SliceStable(docs, func(i, j int) bool {
+		c := compareSortValues(fieldValue(docs[i], field), fieldValue(docs[j], field))
+		if desc {
+			return c > 0
+		}
+		return c < 0
+	})
+}
+
+// fieldValue resolves a dotted path, returning nil when any part is missing.
+func fieldValue(doc bson.M, path string) interface{} {
+	var current interface{} = doc
+	for _, part := range strings.Split(path, ".") {
+		var next interface{}
+		switch d := current.(type) {
+		case bson.M:
+			next = d[part]
+		case map[string]interface{}:
+			next = d[part]
+		case bson.D:
+			for _, e := range d {
+				if e.Key == part {
+					next = e.Value
+					break
+				}
+			}
+		default:
+			return nil
+		}
+		current = next
+	}
+	return current
+}
+
+// compareSortValues is a total order over BSON values, so sorting is
+// deterministic whatever the input order: values group by type bracket
+// (missing/null, numbers, strings, ObjectIDs, booleans, dates, then
+// everything else) and compare naturally within it. Values without a
+// natural order fall back to their canonical encoding.
+func compareSortValues(a, b interface{}) int {
+	ba, bb := sortBracket(a), sortBracket(b)
+	if ba != bb {
+		if ba < bb {
+			return -1
+		}
+		return 1
+	}
+	switch ba {
+	case 0:
+		return 0
+	case 1:
+		x, y := toSortFloat(a), toSortFloat(b)
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	case 2:
+		return strings.Compare(a.(string), b.(string))
+	case 3:
+		x, y := a.(primitive.ObjectID), b.(primitive.ObjectID)
+		return bytes.Compare(x[:], y[:])
+	case 4:
+		x, y := a.(bool), b.(bool)
+		switch {
+		case x == y:
+			return 0
+		case !x:
+			return -1
+		}
+		return 1
+	case 5:
+		x, y := a.(primitive.DateTime), b.(primitive.DateTime)
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	}
+	x, _ := mongoexpr.CanonicalBytes(a)
+	y, _ := mongoexpr.CanonicalBytes(b)
+	return bytes.Compare(x, y)
+}
+
+func sortBracket(v interface{}) int {
+	switch v.(type) {
+	case nil, primitive.Null, primitive.Undefined:
+		return 0
+	case int, int32, int64, float64, primitive.Decimal128:
+		return 1
+	case string:
+		return 2
+	case primitive.ObjectID:
+		return 3
+	case bool:
+		return 4
+	case primitive.DateTime:
+		return 5
+	}
+	return 6
+}
+
+func toSortFloat(v interface{}) float64 {
+	switch n := v.(type) {
+	case int:
+		return float64(n)
+	case int32:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case float64:
+		if math.IsNaN(n) {
+			return math.Inf(-1)
+		}
+		return n
+	case primitive.Decimal128:
+		f, err := strconv.ParseFloat(n.String(), 64)
+		if err != nil || math.IsNaN(f) {
+			return math.Inf(-1)
+		}
+		return f
+	}
+	return 0
+}
@@ -0,0 +1,151 @@
+package merge
+
+import (
+	"fmt"
+
+	"github.com/argon-lab/argon/internal/mongoexpr"
+	"github.com/argon-lab/argon/internal/wal"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Document difference kinds, reading from side A to side B.
+const (
+	DiffAdded   = "added"   // only in B
+	DiffRemoved = "removed" // only in A
+	DiffChanged = "changed" // in both, unequal
+)
+
+// DocumentDiff is one document that differs between the two sides.
+type DocumentDiff struct {
+	Collection string `json:"collection"`
+	DocumentID string `json:"document_id"`
+	Kind       string `json:"kind"`
+	A          bson.M `json:"a,omitempty"`
+	B          bson.M `json:"b,omitempty"`
+	// Fields lists the field-level changes of a changed document.
+	Fields []FieldDiff `json:"fields,omitempty"`
+}
+
+// FieldDiff is one field that differs between the two versions of a
+// document. Path is dotted for fields inside embedded documents; arrays
+// compare as a whole. A missing side is nil.
+type FieldDiff struct {
+	Path string      `json:"path"`
+	A    interface{} `json:"a,omitempty"`
+	B    interface{} `json:"b,omitempty"`
+}
+
+// BranchDiff is a two-way comparison of two branch states.
+type BranchDiff struct {
+	BranchA   string         `json:"branch_a"`
+	LSNA      int64          `json:"lsn_a"`
+	BranchB   string         `json:"branch_b"`
+	LSNB      int64          `json:"lsn_b"`
+	Documents []DocumentDiff `json:"documents"`
+}
+
+// DiffBranches compares the current states of any two branches of a
+// project — not just a branch and its parent, and with no fork point
+// involved (unlike Compute, which diffs three-way for a merge).
+func (s *Service) DiffBranches(branchA, branchB *wal.Branch, collection string) (*BranchDiff, error) {
+	return s.DiffBranchesAtLSN(branchA, branchA.HeadLSN, branchB, branchB.HeadLSN, collection)
+}
+
+// DiffBranchesAtLSN compares branch A as of lsnA with branch B as of lsnB —
+// any two branches, past or present. An empty collection compares every
+// collection. Each LSN must lie within its branch's [base, head] range.
+// Documents are ordered by collection, then ID; identical documents do not
+// appear.
+func (s *Service) DiffBranchesAtLSN(branchA *wal.Branch, lsnA int64, branchB *wal.Branch, lsnB int64, collection string) (*BranchDiff, error) {
+	stateA, err := s.stateAt(branchA, lsnA, collection)
+	if err != nil {
+		return nil, err
+	}
+	stateB, err := s.stateAt(branchB, lsnB, collection)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &BranchDiff{BranchA: branchA.Name, LSNA: lsnA, BranchB: branchB.Name, LSNB: lsnB, Documents: []DocumentDiff{}}
+	for _, coll := range unionKeys3(stateA, stateB) {
+		docsA, docsB := stateA[coll], stateB[coll]
+		for _, id := range unionKeys3(docsA, docsB) {
+			a, b := docsA[id], docsB[id]
+			unequal, err := docsUnequal(a, b)
+			if err != nil {
+				return nil, fmt.Errorf("failed to compare %s/%s: %w", coll, id, err)
+			}
+			if !unequal {
+				continue
+			}
+			d := DocumentDiff{Collection: coll, DocumentID: id, A: a, B: b}
+			switch {
+			case a == nil:
+				d.Kind = DiffAdded
+			case b == nil:
+				d.Kind = DiffRemoved
+			default:
+				d.Kind = DiffChanged
+				if d.Fields, err = fieldDiffs("", a, b); err != nil {
+					return nil, fmt.Errorf("failed to compare %s/%s: %w", coll, id, err)
+				}
+			}
+			diff.Documents = append(diff.Documents, d)
+		}
+	}
+	return diff, nil
+}
+
+// stateAt materializes one side of a diff, keyed by collection.
+func (s *Service) stateAt(branch *wal.Branch, lsn int64, collection string) (map[string]map[string]bson.M, error) {
+	if lsn < branch.BaseLSN || lsn > branch.HeadLSN {
+		return nil, fmt.Errorf("LSN %d is outside branch %s range [%d, %d]", lsn, branch.Name, branch.BaseLSN, branch.HeadLSN)
+	}
+	if collection == "" {
+		state, err := s.materializer.MaterializeBranchAtLSN(branch, lsn)
+		if err != nil {
+			return nil, fmt.Errorf("failed to materialize branch %s at LSN %d: %w", branch.Name, lsn, err)
+		}
+		return state, nil
+	}
+	docs, err := s.materializer.MaterializeCollectionAtLSN(branch, collection, lsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to materialize %s on branch %s at LSN %d: %w", collection, branch.Name, lsn, err)
+	}
+	return map[string]map[string]bson.M{collection: docs}, nil
+}
+
+// fieldDiffs lists the fields that differ between two versions of a
+// document, descending into embedded documents present on both sides.
+func fieldDiffs(prefix string, a, b bson.M) ([]FieldDiff, error) {
+	var diffs []FieldDiff
+	for _, key := range unionKeys3(a, b) {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		va, inA := a[key]
+		vb, inB := b[key]
+		if inA && inB {
+			subA, aIsDoc := va.(bson.M)
+			subB, bIsDoc := vb.(bson.M)
+			if aIsDoc && bIsDoc {
+				sub, err := fieldDiffs(path, subA, subB)
+				if err != nil {
+					return nil, err
+				}
+				diffs = append(diffs, sub...)
+				continue
+			}
+			equal, err := mongoexpr.CanonicalEqual(va, vb)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", path, err)
+			}
+			if equal {
+				continue
+			}
+		}
+		diffs = append(diffs, FieldDiff{Path: path, A: va, B: vb})
+	}
+	return diffs, nil
+}
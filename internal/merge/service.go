@@ -10,7 +10,7 @@
 //   - only theirs changed         → adopt theirs (put or delete)
 //   - both changed identically    → nothing to do
 //   - both changed differently    → conflict, resolved by an explicit
-//     strategy (theirs/ours) or by aborting
+//     strategy (theirs/ours) or by aborting (abort, or no strategy)
 //
 // Comparison is canonical (sorted-key) BSON equality. Plans are persisted
 // pending, then applied exactly once against the exact target head they
@@ -48,6 +48,7 @@ const (
 const (
 	StrategyTheirs = "theirs"
 	StrategyOurs   = "ours"
+	StrategyAbort  = "abort" // refuse a conflicted plan, apply a clean one
 )
 
 // Change is one document the merge would adopt from the source branch.
@@ -250,8 +251,8 @@ type ApplyResult struct {
 }
 
 // Apply executes a pending plan against the exact heads it was computed
-// for. Conflicts require an explicit strategy; without one, a conflicted
-// plan refuses to apply.
+// for. Conflicts require an explicit strategy; without one, or with
+// StrategyAbort, a conflicted plan refuses to apply and stays pending.
 func (s *Service) Apply(ctx context.Context, planID primitive.ObjectID, strategy string) (*ApplyResult, error) {
 	plan, err := s.GetPlan(ctx, planID)
 	if err != nil {
@@ -265,6 +266,9 @@ func (s *Service) Apply(ctx context.Context, planID primitive.ObjectID, strategy
 	if err != nil {
 		return nil, fmt.Errorf("target branch not found: %w", err)
 	}
+	if target.Protected {
+		return nil, fmt.Errorf("%w: %s is read-only; unprotect it to merge into it", wal.ErrBranchProtected, target.Name)
+	}
 	source, err := s.branches.GetBranchByID(plan.SourceBranchID)
 	if err != nil {
 		return nil, fmt.Errorf("source branch not found: %w", err)
@@ -293,10 +297,12 @@ func (s *Service) Apply(ctx context.Context, planID primitive.ObjectID, strategy
 			sortChanges(changes)
 		case StrategyOurs:
 			resolved = len(plan.Conflicts) // Keep ours: nothing to write.
+		case StrategyAbort:
+			return nil, fmt.Errorf("merge aborted: plan has %d conflict(s)", len(plan.Conflicts))
 		case "":
 			return nil, fmt.Errorf("plan has %d conflict(s); pass a strategy (theirs/ours) to resolve them", len(plan.Conflicts))
 		default:
-			return nil, fmt.Errorf("unknown strategy %q (want theirs or ours)", strategy)
+			return nil, fmt.Errorf("unknown strategy %q (want theirs, ours or abort)", strategy)
 		}
 	}
 
@@ -328,7 +334,7 @@ func (s *Service) Apply(ctx context.Context, planID primitive.ObjectID, strategy
 	if lsn, err := s.wal.Append(mergeRecord); err != nil {
 		return nil, fmt.Errorf("failed to record the merge: %w", err)
 	} else if !target.IsLive() {
-		if err := s.branches.UpdateBranchHead(target.ID, lsn); err != nil {
+		if err := s.branches.UpdateBranchHead(target.ID, lsn, mergeRecord.Timestamp); err != nil {
 			return nil, fmt.Errorf("failed to advance target head: %w", err)
 		}
 	}
@@ -347,6 +353,10 @@ func (s *Service) Apply(ctx context.Context, planID primitive.ObjectID, strategy
 func (s *Service) applyWAL(ctx context.Context, target, source *wal.Branch, changes []Change) error {
 	writer := walwriter.New(s.wal, s.branches, s.materializer, target)
 	writer.SetActor("merge:" + source.Name)
+	// The images are already in the source's WAL, possibly via import or
+	// ingest, which do not apply the writer's key policy; a merge must
+	// carry them over unchanged.
+	writer.SetReserved(walwriter.Reserved{})
 
 	// Group puts per collection for contiguous batches; deletes go singly.
 	putsByCollection := make(map[string][]bson.M)
@@ -383,8 +383,12 @@ func firstMatch(collState map[string]bson.M, filter bson.M) (string, bson.M, boo
 	}
 	sort.Strings(docIDs)
 
+	matcher, err := mongoexpr.CompileFilter(filter)
+	if err != nil {
+		return "", nil, false, err
+	}
 	for _, docID := range docIDs {
-		matched, err := mongoexpr.MatchesFilter(collState[docID], filter)
+		matched, err := matcher.Match(collState[docID])
 		if err != nil {
 			return "", nil, false, err
 		}
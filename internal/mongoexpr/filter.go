@@ -3,9 +3,13 @@ package mongoexpr
 import (
 	"bytes"
 	"fmt"
+	"math"
 	"reflect"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -13,41 +17,276 @@ import (
 )
 
 // Package mongoexpr evaluates MongoDB filter and update expressions in
-// process. Live traffic never runs through it — applications query and
-// write checked-out branches on real mongod. It survives for exactly two
-// consumers: the v1→v2 WAL migration, which must resolve legacy expression
-// entries one final time, and canonical BSON comparison/serialization
-// (canonical.go) used by snapshots and diffs. Replay correctness never
-// depends on this code.
+// process, over documents materialized from the WAL. It is Argon's query
+// engine for history: time-travel queries (QueryAtLSN) and aggregation
+// match their filters through it; sorting, projection, merge diffs and
+// snapshots use its canonical BSON comparison and serialization
+// (canonical.go) and document helpers; and the v1→v2 WAL migration
+// resolves legacy update expressions with it one final time. Applications
+// querying a checked-out branch on real mongod do not go through it, and
+// replay applies post-images without evaluating any expression.
 //
 // Filter support: implicit equality, $eq, $ne, $gt, $gte, $lt, $lte, $in,
-// $nin, $exists, $regex, $size, $all, $elemMatch, $and, $or, $nor, $not,
-// dotted paths, and match-any-element semantics for arrays. Unsupported
-// operators fail loudly instead of being silently skipped.
+// $nin, $exists, $regex, $size, $all, $elemMatch (on documents or
+// scalars), $mod, $and, $or, $nor, $not, dotted paths (through arrays too:
+// "items.sku", "items.0.sku"), and match-any-element semantics for arrays,
+// plus $pred for registered Go predicates (see RegisterPredicate).
+// Unsupported operators fail loudly instead of being silently skipped.
 
 // MatchesFilter reports whether a document matches a MongoDB query filter.
+// Callers evaluating one filter against many documents should compile it
+// once with CompileFilter instead.
 func MatchesFilter(doc bson.M, filter bson.M) (bool, error) {
-	for key, expected := range filter {
+	m, err := CompileFilter(filter)
+	if err != nil {
+		return false, err
+	}
+	return m.Match(doc)
+}
+
+// Condition costs, cheapest first. Evaluation order only affects speed —
+// every condition of a filter must hold, so any order gives the same
+// answer — and ordering by cost lets a cheap mismatch skip the regexes and
+// nested array scans behind it.
+const (
+	costEquality = iota // implicit equality
+	costCompare         // $eq, $ne, $gt..$lte, $exists, $in, $nin, $size
+	costArray           // $all
+	costRegex           // $regex
+	costNested          // $elemMatch, $not
+	costOpaque          // $pred: a Go function of unknown cost
+	costLogical         // $and, $or, $nor: added to their costliest branch
+)
+
+// Matcher is a filter compiled for repeated evaluation: logical operators
+// are resolved and conditions sorted cheapest first once, rather than per
+// document. $and stops at the first false condition, $or at the first true
+// one, and $nor at the first match. A Matcher is safe for concurrent use.
+type Matcher struct {
+	conds []condition
+	evals *int64
+}
+
+// condition is one top-level filter key.
+type condition struct {
+	key   string
+	value interface{}
+	ops   *opSet
+	subs  []*Matcher // branches of $and/$or/$nor
+	pred  Predicate  // resolved $pred
+	cost  int
+}
+
+// opSet is a compiled operator document such as {$gt: 5, $lt: 10}.
+type opSet struct {
+	ops   bson.M
+	order []string       // operator keys, cheapest first
+	elem  *Matcher       // compiled $elemMatch operand on documents
+	elemV *opSet         // compiled $elemMatch operand on scalars
+	not   *opSet         // compiled $not operand
+	mod   [2]int64       // validated $mod operand: divisor, remainder
+	re    *regexp.Regexp // compiled $regex operand, with its $options
+}
+
+// supportedOperators are the field operators Match evaluates.
+var supportedOperators = map[string]bool{
+	"$eq": true, "$ne": true, "$gt": true, "$gte": true, "$lt": true, "$lte": true,
+	"$in": true, "$nin": true, "$exists": true, "$regex": true, "$options": true,
+	"$size": true, "$all": true, "$elemMatch": true, "$not": true, "$mod": true,
+}
+
+// CompileFilter validates a filter — its logical structure and every
+// operator name, however deeply nested — and orders its conditions for
+// evaluation.
+func CompileFilter(filter bson.M) (*Matcher, error) {
+	return compileFilter(filter, nil)
+}
+
+// CompileInstrumentedFilter is CompileFilter with a condition counter
+// behind Evaluations, for tests and benchmarks of evaluation order. The
+// counter costs an atomic add per condition, so production callers use
+// CompileFilter.
+func CompileInstrumentedFilter(filter bson.M) (*Matcher, error) {
+	return compileFilter(filter, new(int64))
+}
+
+func compileFilter(filter bson.M, evals *int64) (*Matcher, error) {
+	m := &Matcher{conds: make([]condition, 0, len(filter)), evals: evals}
+	for key, value := range filter {
+		cond := condition{key: key, value: value}
 		switch key {
-		case "$and":
-			conds, err := toFilterSlice(expected, "$and")
+		case "$and", "$or", "$nor":
+			branches, err := toFilterSlice(value, key)
 			if err != nil {
-				return false, err
+				return nil, err
+			}
+			// As in MongoDB: an empty list would make $and vacuously true
+			// and $or never true, which is never what the caller meant.
+			if len(branches) == 0 {
+				return nil, fmt.Errorf("%s requires a nonempty array", key)
+			}
+			maxCost := 0
+			for _, branch := range branches {
+				sub, err := compileFilter(branch, evals)
+				if err != nil {
+					return nil, err
+				}
+				if c := sub.cost(); c > maxCost {
+					maxCost = c
+				}
+				cond.subs = append(cond.subs, sub)
+			}
+			cond.cost = costLogical + maxCost
+		case "$pred":
+			fn, err := lookupPredicate(value)
+			if err != nil {
+				return nil, err
+			}
+			cond.pred = fn
+			cond.cost = costOpaque
+		default:
+			if strings.HasPrefix(key, "$") {
+				return nil, fmt.Errorf("unsupported top-level query operator %q", key)
+			}
+			if ops, ok := asOperatorDoc(value); ok {
+				set, err := compileOps(ops)
+				if err != nil {
+					return nil, err
+				}
+				cond.ops = set
+				cond.cost = operatorCost(set.order[len(set.order)-1])
+			} else {
+				cond.cost = costEquality
+			}
+		}
+		m.conds = append(m.conds, cond)
+	}
+	sort.SliceStable(m.conds, func(i, j int) bool {
+		if m.conds[i].cost != m.conds[j].cost {
+			return m.conds[i].cost < m.conds[j].cost
+		}
+		return m.conds[i].key < m.conds[j].key
+	})
+	return m, nil
+}
+
+// compileOps validates an operator document and compiles its nested
+// $elemMatch and $not operands and its $regex once, rather than per array
+// element or document.
+func compileOps(ops bson.M) (*opSet, error) {
+	set := &opSet{ops: ops, order: orderOperators(ops)}
+	for op, operand := range ops {
+		if !supportedOperators[op] {
+			return nil, fmt.Errorf("unsupported query operator %q", op)
+		}
+		switch op {
+		case "$elemMatch":
+			cond, ok := toBSONM(operand)
+			if !ok {
+				return nil, fmt.Errorf("$elemMatch requires a document operand")
+			}
+			// {$elemMatch: {$gte: 80, $lt: 85}} applies operators to each
+			// element itself, which is how scalar arrays are matched.
+			if ops, isOps := asOperatorDoc(cond); isOps && !hasFilterKey(ops) {
+				elemV, err := compileOps(ops)
+				if err != nil {
+					return nil, err
+				}
+				set.elemV = elemV
+				break
+			}
+			// The whole $elemMatch counts as one condition of its field.
+			elem, err := compileFilter(cond, nil)
+			if err != nil {
+				return nil, err
+			}
+			set.elem = elem
+		case "$not":
+			cond, ok := toBSONM(operand)
+			if !ok {
+				return nil, fmt.Errorf("$not requires an operator document")
 			}
-			for _, cond := range conds {
-				ok, err := MatchesFilter(doc, cond)
+			not, err := compileOps(cond)
+			if err != nil {
+				return nil, err
+			}
+			set.not = not
+		case "$mod":
+			mod, err := compileMod(operand)
+			if err != nil {
+				return nil, err
+			}
+			set.mod = mod
+		case "$regex":
+			re, err := compileRegex(operand, ops["$options"])
+			if err != nil {
+				return nil, err
+			}
+			set.re = re
+		case "$options":
+			if _, ok := ops["$regex"]; !ok {
+				return nil, fmt.Errorf("$options requires a $regex")
+			}
+		}
+	}
+	return set, nil
+}
+
+// compileMod validates a $mod operand — [divisor, remainder], both
+// numeric — truncating each to an integer as MongoDB does.
+func compileMod(operand interface{}) ([2]int64, error) {
+	arr, ok := asArray(operand)
+	if !ok || len(arr) != 2 {
+		return [2]int64{}, fmt.Errorf("$mod requires a [divisor, remainder] array")
+	}
+	divisor, ok := toFloat(arr[0])
+	if !ok {
+		return [2]int64{}, fmt.Errorf("$mod divisor must be a number")
+	}
+	remainder, ok := toFloat(arr[1])
+	if !ok {
+		return [2]int64{}, fmt.Errorf("$mod remainder must be a number")
+	}
+	if int64(divisor) == 0 {
+		return [2]int64{}, fmt.Errorf("$mod divisor must not be zero")
+	}
+	return [2]int64{int64(divisor), int64(remainder)}, nil
+}
+
+// cost is the matcher's costliest condition.
+func (m *Matcher) cost() int {
+	if len(m.conds) == 0 {
+		return 0
+	}
+	return m.conds[len(m.conds)-1].cost
+}
+
+// Evaluations returns how many field conditions this matcher (including
+// nested branches) has evaluated so far. Always 0 unless the matcher came
+// from CompileInstrumentedFilter.
+func (m *Matcher) Evaluations() int64 {
+	if m.evals == nil {
+		return 0
+	}
+	return atomic.LoadInt64(m.evals)
+}
+
+// Match reports whether a document matches the compiled filter.
+func (m *Matcher) Match(doc bson.M) (bool, error) {
+	for i := range m.conds {
+		cond := &m.conds[i]
+		switch cond.key {
+		case "$and":
+			for _, sub := range cond.subs {
+				ok, err := sub.Match(doc)
 				if err != nil || !ok {
 					return false, err
 				}
 			}
 		case "$or":
-			conds, err := toFilterSlice(expected, "$or")
-			if err != nil {
-				return false, err
-			}
 			matched := false
-			for _, cond := range conds {
-				ok, err := MatchesFilter(doc, cond)
+			for _, sub := range cond.subs {
+				ok, err := sub.Match(doc)
 				if err != nil {
 					return false, err
 				}
@@ -60,12 +299,8 @@ func MatchesFilter(doc bson.M, filter bson.M) (bool, error) {
 				return false, nil
 			}
 		case "$nor":
-			conds, err := toFilterSlice(expected, "$nor")
-			if err != nil {
-				return false, err
-			}
-			for _, cond := range conds {
-				ok, err := MatchesFilter(doc, cond)
+			for _, sub := range cond.subs {
+				ok, err := sub.Match(doc)
 				if err != nil {
 					return false, err
 				}
@@ -74,10 +309,10 @@ func MatchesFilter(doc bson.M, filter bson.M) (bool, error) {
 				}
 			}
 		default:
-			if strings.HasPrefix(key, "$") {
-				return false, fmt.Errorf("unsupported top-level query operator %q", key)
+			if m.evals != nil {
+				atomic.AddInt64(m.evals, 1)
 			}
-			ok, err := matchesField(doc, key, expected)
+			ok, err := cond.match(doc)
 			if err != nil || !ok {
 				return false, err
 			}
@@ -86,25 +321,56 @@ func MatchesFilter(doc bson.M, filter bson.M) (bool, error) {
 	return true, nil
 }
 
-// matchesField evaluates a single field condition (dotted paths allowed).
-func matchesField(doc bson.M, path string, expected interface{}) (bool, error) {
-	value, exists := lookupPath(doc, path)
-
-	if opDoc, ok := asOperatorDoc(expected); ok {
-		return matchesOperators(value, exists, opDoc)
+func (c *condition) match(doc bson.M) (bool, error) {
+	if c.pred != nil {
+		return c.pred(doc), nil
+	}
+	value, exists := lookupFilterPath(doc, strings.Split(c.key, "."))
+	if c.ops != nil {
+		return c.ops.match(value, exists)
 	}
-
 	// Implicit equality.
 	if !exists {
-		return isBSONNull(expected), nil
+		return isBSONNull(c.value), nil
 	}
-	return valuesMatch(value, expected), nil
+	return valuesMatch(value, c.value), nil
 }
 
-// matchesOperators evaluates an operator document like {$gt: 5, $lt: 10}
-// against a field value.
-func matchesOperators(value interface{}, exists bool, operators bson.M) (bool, error) {
-	for op, operand := range operators {
+func operatorCost(op string) int {
+	switch op {
+	case "$all":
+		return costArray
+	case "$regex", "$options":
+		return costRegex
+	case "$elemMatch", "$not":
+		return costNested
+	default:
+		return costCompare
+	}
+}
+
+// orderOperators lists an operator document's keys cheapest first.
+func orderOperators(ops bson.M) []string {
+	order := make([]string, 0, len(ops))
+	for op := range ops {
+		order = append(order, op)
+	}
+	sort.Slice(order, func(i, j int) bool {
+		ci, cj := operatorCost(order[i]), operatorCost(order[j])
+		if ci != cj {
+			return ci < cj
+		}
+		return order[i] < order[j]
+	})
+	return order
+}
+
+// match evaluates the operators cheapest first, stopping at the first one
+// that fails.
+func (set *opSet) match(value interface{}, exists bool) (bool, error) {
+	operators := set.ops
+	for _, op := range set.order {
+		operand := operators[op]
 		switch op {
 		case "$eq":
 			if !exists || !valuesMatch(value, operand) {
@@ -118,6 +384,10 @@ func matchesOperators(value interface{}, exists bool, operators bson.M) (bool, e
 			if !exists || !compareMatch(value, operand, op) {
 				return false, nil
 			}
+		case "$mod":
+			if !exists || !modMatch(value, set.mod) {
+				return false, nil
+			}
 		case "$in":
 			arr, err := toSlice(operand, "$in")
 			if err != nil {
@@ -157,11 +427,18 @@ func matchesOperators(value interface{}, exists bool, operators bson.M) (bool, e
 			if !exists {
 				return false, nil
 			}
-			ok, err := regexMatch(value, operand, operators["$options"])
-			if err != nil {
-				return false, err
-			}
-			if !ok {
+			if !anyCandidate(value, func(v interface{}) bool {
+				if str, ok := v.(string); ok {
+					return set.re.MatchString(str)
+				}
+				arr, _ := asArray(v)
+				for _, item := range arr {
+					if str, ok := item.(string); ok && set.re.MatchString(str) {
+						return true
+					}
+				}
+				return false
+			}) {
 				return false, nil
 			}
 		case "$options":
@@ -170,8 +447,10 @@ func matchesOperators(value interface{}, exists bool, operators bson.M) (bool, e
 			if !exists {
 				return false, nil
 			}
-			arr, ok := asArray(value)
-			if !ok || int64(len(arr)) != toInt64(operand) {
+			if !anyCandidate(value, func(v interface{}) bool {
+				arr, ok := asArray(v)
+				return ok && int64(len(arr)) == toInt64(operand)
+			}) {
 				return false, nil
 			}
 		case "$all":
@@ -182,7 +461,12 @@ func matchesOperators(value interface{}, exists bool, operators bson.M) (bool, e
 			if !exists {
 				return false, nil
 			}
+			// Across a fanout, $all is an $and of equalities: each value
+			// may come from a different candidate.
 			arr, ok := asArray(value)
+			if f, isFanout := value.(fanout); isFanout {
+				arr, ok = flattenCandidates(f), true
+			}
 			if !ok {
 				return false, nil
 			}
@@ -202,26 +486,33 @@ func matchesOperators(value interface{}, exists bool, operators bson.M) (bool, e
 			if !exists {
 				return false, nil
 			}
-			cond, ok := toBSONM(operand)
-			if !ok {
-				return false, fmt.Errorf("$elemMatch requires a document operand")
-			}
-			arr, isArr := asArray(value)
-			if !isArr {
-				return false, nil
+			candidates := []interface{}{value}
+			if f, isFanout := value.(fanout); isFanout {
+				candidates = f.values
 			}
 			matched := false
-			for _, item := range arr {
-				elemDoc, isDoc := toBSONM(item)
-				if !isDoc {
+			for _, candidate := range candidates {
+				arr, isArr := asArray(candidate)
+				if !isArr {
 					continue
 				}
-				ok, err := MatchesFilter(elemDoc, cond)
-				if err != nil {
-					return false, err
+				for _, item := range arr {
+					var ok bool
+					var err error
+					if set.elemV != nil {
+						ok, err = set.elemV.match(item, true)
+					} else if elemDoc, isDoc := toBSONM(item); isDoc {
+						ok, err = set.elem.Match(elemDoc)
+					}
+					if err != nil {
+						return false, err
+					}
+					if ok {
+						matched = true
+						break
+					}
 				}
-				if ok {
-					matched = true
+				if matched {
 					break
 				}
 			}
@@ -229,11 +520,7 @@ func matchesOperators(value interface{}, exists bool, operators bson.M) (bool, e
 				return false, nil
 			}
 		case "$not":
-			cond, ok := toBSONM(operand)
-			if !ok {
-				return false, fmt.Errorf("$not requires an operator document")
-			}
-			ok2, err := matchesOperators(value, exists, cond)
+			ok2, err := set.not.match(value, exists)
 			if err != nil {
 				return false, err
 			}
@@ -265,22 +552,123 @@ func lookupPath(doc bson.M, path string) (interface{}, bool) {
 	return current, true
 }
 
-// valuesMatch implements MongoDB equality semantics for filters: direct
-// equality, or — when the stored value is an array — equality of any element.
-func valuesMatch(value, expected interface{}) bool {
-	if compareBSONValues(value, expected) == 0 {
-		return true
+// fanout is the value of a path that crossed an array: one candidate per
+// element holding the rest of the path ("items.tags" on line items is each
+// item's tags). As in MongoDB, a condition holds when it holds for any
+// candidate, and a candidate that is itself an array matches through its
+// elements too. It is a struct so asArray never mistakes it for an array.
+type fanout struct {
+	values []interface{}
+}
+
+// anyCandidate reports whether fn holds for value, or for any candidate
+// of a fanout.
+func anyCandidate(value interface{}, fn func(interface{}) bool) bool {
+	f, ok := value.(fanout)
+	if !ok {
+		return fn(value)
+	}
+	for _, v := range f.values {
+		if fn(v) {
+			return true
+		}
 	}
-	if arr, ok := asArray(value); ok {
-		for _, item := range arr {
-			if compareBSONValues(item, expected) == 0 {
-				return true
+	return false
+}
+
+// flattenCandidates lists a fanout's candidates with array candidates
+// expanded one level, the values $all draws from.
+func flattenCandidates(f fanout) []interface{} {
+	var flat []interface{}
+	for _, v := range f.values {
+		if arr, ok := asArray(v); ok {
+			flat = append(flat, arr...)
+		} else {
+			flat = append(flat, v)
+		}
+	}
+	return flat
+}
+
+// lookupFilterPath resolves a dotted field path for matching. Unlike
+// lookupPath, it follows paths through arrays as MongoDB queries do: a
+// numeric part indexes the array, and any other part is resolved in every
+// document element, giving a fanout of the values found. The path exists
+// if any element has it.
+func lookupFilterPath(current interface{}, parts []string) (interface{}, bool) {
+	for i, part := range parts {
+		if arr, ok := asArray(current); ok {
+			if idx, err := strconv.Atoi(part); err == nil && idx >= 0 {
+				if idx >= len(arr) {
+					return nil, false
+				}
+				current = arr[idx]
+				continue
 			}
+			var values []interface{}
+			for _, item := range arr {
+				if _, isDoc := toBSONM(item); !isDoc {
+					continue
+				}
+				v, found := lookupFilterPath(item, parts[i:])
+				if !found {
+					continue
+				}
+				// A path crossing nested arrays yields one flat set of
+				// candidates.
+				if nested, ok := v.(fanout); ok {
+					values = append(values, nested.values...)
+				} else {
+					values = append(values, v)
+				}
+			}
+			if len(values) == 0 {
+				return nil, false
+			}
+			return fanout{values: values}, true
+		}
+		asDoc, ok := toBSONM(current)
+		if !ok {
+			return nil, false
+		}
+		next, exists := asDoc[part]
+		if !exists {
+			return nil, false
+		}
+		current = next
+	}
+	return current, true
+}
+
+// hasFilterKey reports whether an operator document holds $and, $or, $nor
+// or $pred, which make it a filter rather than a set of field operators.
+func hasFilterKey(ops bson.M) bool {
+	for _, key := range []string{"$and", "$or", "$nor", "$pred"} {
+		if _, ok := ops[key]; ok {
+			return true
 		}
 	}
 	return false
 }
 
+// valuesMatch implements MongoDB equality semantics for filters: direct
+// equality, or — when the stored value is an array — equality of any element.
+func valuesMatch(value, expected interface{}) bool {
+	return anyCandidate(value, func(v interface{}) bool {
+		if compareBSONValues(v, expected) == 0 {
+			return true
+		}
+		if arr, ok := asArray(v); ok {
+			for _, item := range arr {
+				if compareBSONValues(item, expected) == 0 {
+					return true
+				}
+			}
+		}
+		return false
+	})
+}
+
 // compareMatch evaluates $gt/$gte/$lt/$lte with array-any-element semantics.
 func compareMatch(value, operand interface{}, op string) bool {
 	try := func(v interface{}) bool {
@@ -300,17 +688,44 @@ func compareMatch(value, operand interface{}, op string) bool {
 		}
 		return false
 	}
-	if try(value) {
-		return true
-	}
-	if arr, ok := asArray(value); ok {
-		for _, item := range arr {
-			if try(item) {
-				return true
+	return anyCandidate(value, func(v interface{}) bool {
+		if try(v) {
+			return true
+		}
+		if arr, ok := asArray(v); ok {
+			for _, item := range arr {
+				if try(item) {
+					return true
+				}
 			}
 		}
+		return false
+	})
+}
+
+// modMatch evaluates $mod with array-any-element semantics. Numbers are
+// truncated to integers first; non-numeric values never match.
+func modMatch(value interface{}, mod [2]int64) bool {
+	try := func(v interface{}) bool {
+		n, ok := toFloat(v)
+		if !ok || math.IsNaN(n) || math.IsInf(n, 0) {
+			return false
+		}
+		return int64(n)%mod[0] == mod[1]
 	}
-	return false
+	return anyCandidate(value, func(v interface{}) bool {
+		if try(v) {
+			return true
+		}
+		if arr, ok := asArray(v); ok {
+			for _, item := range arr {
+				if try(item) {
+					return true
+				}
+			}
+		}
+		return false
+	})
 }
 
 // compareBSONValues compares two BSON values with numeric cross-type
@@ -426,6 +841,18 @@ func comparableTypes(a, b interface{}) bool {
 
 // --- small conversion helpers ---
 
+// AsDocument returns v as a bson.M if it is an embedded document (bson.M,
+// map[string]interface{} or bson.D).
+func AsDocument(v interface{}) (bson.M, bool) {
+	return toBSONM(v)
+}
+
+// AsArray returns v's elements if it is an array (bson.A, []interface{}
+// or a typed Go slice); BSON binary is not an array.
+func AsArray(v interface{}) ([]interface{}, bool) {
+	return asArray(v)
+}
+
 func toBSONM(v interface{}) (bson.M, bool) {
 	switch d := v.(type) {
 	case bson.M:
@@ -544,12 +971,10 @@ func isBSONNull(v interface{}) bool {
 	return ok
 }
 
-func regexMatch(value, pattern, opts interface{}) (bool, error) {
-	str, ok := value.(string)
-	if !ok {
-		return false, nil
-	}
-
+// compileRegex compiles a $regex operand — a pattern string or a BSON
+// regex — with the i, m and s flags of its $options sibling, which take
+// precedence over a BSON regex's own options.
+func compileRegex(pattern, opts interface{}) (*regexp.Regexp, error) {
 	var expr string
 	switch p := pattern.(type) {
 	case string:
@@ -560,7 +985,7 @@ func regexMatch(value, pattern, opts interface{}) (bool, error) {
 			opts = p.Options
 		}
 	default:
-		return false, fmt.Errorf("$regex requires a string or regex operand, got %T", pattern)
+		return nil, fmt.Errorf("$regex requires a string or regex operand, got %T", pattern)
 	}
 
 	if optStr, ok := opts.(string); ok && optStr != "" {
@@ -570,7 +995,7 @@ func regexMatch(value, pattern, opts interface{}) (bool, error) {
 			case 'i', 'm', 's':
 				flags += string(o)
 			default:
-				return false, fmt.Errorf("unsupported $regex option %q", string(o))
+				return nil, fmt.Errorf("unsupported $regex option %q", string(o))
 			}
 		}
 		expr = "(?" + flags + ")" + expr
@@ -578,7 +1003,7 @@ func regexMatch(value, pattern, opts interface{}) (bool, error) {
 
 	re, err := regexp.Compile(expr)
 	if err != nil {
-		return false, fmt.Errorf("invalid $regex pattern: %w", err)
+		return nil, fmt.Errorf("invalid $regex pattern: %w", err)
 	}
-	return re.MatchString(str), nil
+	return re, nil
 }
@@ -0,0 +1,50 @@
+package mongoexpr
+
+import (
+	"fmt"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Predicate is a named Go filter condition, referenced in filters as
+// {$pred: "name"} and evaluated against the whole document. It is the
+// in-process alternative to $where: callers get computed conditions
+// without shipping JavaScript to evaluate.
+type Predicate func(doc bson.M) bool
+
+var predicates = struct {
+	sync.RWMutex
+	byName map[string]Predicate
+}{byName: make(map[string]Predicate)}
+
+// RegisterPredicate makes fn available to filters as {$pred: name}.
+// Registering a name again replaces it, and a nil fn removes it; matchers
+// already compiled keep the function they resolved. Predicates run
+// wherever a Matcher does, so they must be safe for concurrent use and
+// must not modify the document.
+func RegisterPredicate(name string, fn func(bson.M) bool) {
+	predicates.Lock()
+	defer predicates.Unlock()
+	if fn == nil {
+		delete(predicates.byName, name)
+		return
+	}
+	predicates.byName[name] = fn
+}
+
+// lookupPredicate resolves a $pred operand, failing on anything but the
+// name of a registered predicate.
+func lookupPredicate(operand interface{}) (Predicate, error) {
+	name, ok := operand.(string)
+	if !ok {
+		return nil, fmt.Errorf("$pred needs a predicate name, got %T", operand)
+	}
+	predicates.RLock()
+	fn := predicates.byName[name]
+	predicates.RUnlock()
+	if fn == nil {
+		return nil, fmt.Errorf("$pred: no predicate registered as %q", name)
+	}
+	return fn, nil
+}
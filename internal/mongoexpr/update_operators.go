@@ -142,6 +142,11 @@ func applyUpdateOperator(doc bson.M, op, path string, value interface{}, isUpser
 		if !ok {
 			return fmt.Errorf("$rename requires a string target for field %s", path)
 		}
+		// As in MongoDB: a rename onto itself, or into or out of its own
+		// subtree, is refused rather than losing the value.
+		if newPath == path || strings.HasPrefix(newPath, path+".") || strings.HasPrefix(path, newPath+".") {
+			return fmt.Errorf("$rename source %s and target %s must not be on the same path", path, newPath)
+		}
 		if current, exists := lookupPath(doc, path); exists {
 			unsetPath(doc, path)
 			setPath(doc, newPath, current)
@@ -241,25 +246,41 @@ func applyPull(doc bson.M, path string, condition interface{}) error {
 		return fmt.Errorf("cannot $pull from non-array field %s", path)
 	}
 
+	// Compile the condition once for the whole array.
+	var (
+		ops    *opSet
+		filter *Matcher
+		err    error
+	)
+	if opDoc, isOp := asOperatorDoc(condition); isOp {
+		if ops, err = compileOps(opDoc); err != nil {
+			return err
+		}
+	} else if condDoc, isDoc := toBSONM(condition); isDoc {
+		if filter, err = CompileFilter(condDoc); err != nil {
+			return err
+		}
+	}
+
 	kept := make([]interface{}, 0, len(arr))
 	for _, item := range arr {
 		remove := false
-		if opDoc, isOp := asOperatorDoc(condition); isOp {
-			ok, err := matchesOperators(item, true, opDoc)
+		switch {
+		case ops != nil:
+			ok, err := ops.match(item, true)
 			if err != nil {
 				return err
 			}
 			remove = ok
-		} else if condDoc, isDoc := toBSONM(condition); isDoc {
-			itemDoc, isItemDoc := toBSONM(item)
-			if isItemDoc {
-				ok, err := MatchesFilter(itemDoc, condDoc)
+		case filter != nil:
+			if itemDoc, isItemDoc := toBSONM(item); isItemDoc {
+				ok, err := filter.Match(itemDoc)
 				if err != nil {
 					return err
 				}
 				remove = ok
 			}
-		} else {
+		default:
 			remove = compareBSONValues(item, condition) == 0
 		}
 		if !remove {
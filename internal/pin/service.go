@@ -46,6 +46,9 @@ type Pin struct {
 	CreatedAt  time.Time `bson:"created_at" json:"created_at"`
 }
 
+// ErrPinNotFound is returned by Get and Delete for an unknown pin name.
+var ErrPinNotFound = errors.New("pin not found")
+
 // Service manages pins.
 type Service struct {
 	collection *mongo.Collection
@@ -122,7 +125,7 @@ func (s *Service) Get(projectID, name string) (*Pin, error) {
 		bson.M{"project_id": projectID, "name": name}).Decode(&pin)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return nil, fmt.Errorf("pin %q not found", name)
+			return nil, fmt.Errorf("%w: %q", ErrPinNotFound, name)
 		}
 		return nil, err
 	}
@@ -154,7 +157,7 @@ func (s *Service) Delete(projectID, name string) error {
 		return err
 	}
 	if res.DeletedCount == 0 {
-		return fmt.Errorf("pin %q not found", name)
+		return fmt.Errorf("%w: %q", ErrPinNotFound, name)
 	}
 	return nil
 }
@@ -192,3 +195,12 @@ func (s *Service) RequireNoPins(branchID string) error {
 	}
 	return nil
 }
+
+// RenameBranch updates the branch name recorded on a branch's pins; it is
+// the branch service's rename hook.
+func (s *Service) RenameBranch(branchID, newName string) error {
+	_, err := s.collection.UpdateMany(context.Background(),
+		bson.M{"branch_id": branchID},
+		bson.M{"$set": bson.M{"branch_name": newName}})
+	return err
+}
@@ -4,9 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
 	"time"
 
 	branchwal "github.com/argon-lab/argon/internal/branch/wal"
+	"github.com/argon-lab/argon/internal/logging"
 	"github.com/argon-lab/argon/internal/wal"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -20,6 +22,14 @@ type ProjectService struct {
 	collection *mongo.Collection
 	wal        *wal.Service
 	branches   *branchwal.BranchService
+
+	// protectMain creates new projects' main branches protected.
+	protectMain bool
+}
+
+// SetProtectMain makes CreateProject protect each new main branch.
+func (s *ProjectService) SetProtectMain(protect bool) {
+	s.protectMain = protect
 }
 
 // NewProjectService creates a new WAL project service
@@ -79,6 +89,11 @@ func (s *ProjectService) CreateProject(name string) (*wal.Project, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to create main branch: %w", err)
 	}
+	if s.protectMain {
+		if err := s.branches.SetProtected(mainBranch.ID, true); err != nil {
+			return nil, fmt.Errorf("failed to protect main branch: %w", err)
+		}
+	}
 
 	// Create project record
 	project := &wal.Project{
@@ -106,7 +121,7 @@ func (s *ProjectService) GetProject(projectID string) (*wal.Project, error) {
 	err := s.collection.FindOne(ctx, bson.M{"_id": projectID}).Decode(&project)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return nil, errors.New("project not found")
+			return nil, wal.ErrProjectNotFound
 		}
 		return nil, err
 	}
@@ -122,7 +137,7 @@ func (s *ProjectService) GetProjectByName(name string) (*wal.Project, error) {
 	err := s.collection.FindOne(ctx, bson.M{"name": name}).Decode(&project)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return nil, errors.New("project not found")
+			return nil, wal.ErrProjectNotFound
 		}
 		return nil, err
 	}
@@ -140,6 +155,22 @@ func (s *ProjectService) GetProjectIDByName(name string) (string, error) {
 	return project.ID, nil
 }
 
+// SetCaptureConfig replaces a project's per-collection capture policy.
+// Running ingesters pick it up when they next start; entries already in
+// the WAL are kept either way.
+func (s *ProjectService) SetCaptureConfig(projectID string, capture wal.CaptureConfig) error {
+	res, err := s.collection.UpdateOne(context.Background(),
+		bson.M{"_id": projectID},
+		bson.M{"$set": bson.M{"capture": capture}})
+	if err != nil {
+		return fmt.Errorf("failed to update capture config: %w", err)
+	}
+	if res.MatchedCount == 0 {
+		return wal.ErrProjectNotFound
+	}
+	return nil
+}
+
 // ListProjects lists all WAL-enabled projects
 func (s *ProjectService) ListProjects() ([]*wal.Project, error) {
 	ctx := context.Background()
@@ -157,6 +188,32 @@ func (s *ProjectService) ListProjects() ([]*wal.Project, error) {
 	return projects, nil
 }
 
+// ListProjectsPaged lists one page of projects, ordered by name, along with
+// the total number of projects.
+func (s *ProjectService) ListProjectsPaged(limit, offset int64) ([]*wal.Project, int64, error) {
+	ctx := context.Background()
+	filter := bson.M{"use_wal": true}
+	total, err := s.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "name", Value: 1}, {Key: "_id", Value: 1}}).
+		SetSkip(offset).
+		SetLimit(limit)
+	cursor, err := s.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() { _ = cursor.Close(ctx) }()
+
+	projects := []*wal.Project{}
+	if err := cursor.All(ctx, &projects); err != nil {
+		return nil, 0, err
+	}
+	return projects, total, nil
+}
+
 // DeleteProject deletes a project and all its branches
 func (s *ProjectService) DeleteProject(projectID string) error {
 	ctx := context.Background()
@@ -178,7 +235,10 @@ func (s *ProjectService) DeleteProject(projectID string) error {
 		if branch.Name != "main" {
 			if err := s.branches.DeleteBranch(projectID, branch.Name); err != nil {
 				// Log error but continue
-				fmt.Printf("Failed to delete branch %s: %v\n", branch.Name, err)
+				slog.Warn("project delete: failed to delete branch",
+					slog.String(logging.KeyProjectID, projectID),
+					slog.String(logging.KeyBranchID, branch.ID),
+					slog.String("branch", branch.Name), logging.Err(err))
 			}
 		}
 	}
@@ -199,7 +259,8 @@ func (s *ProjectService) DeleteProject(projectID string) error {
 
 	// Force delete main branch (special case for project deletion)
 	if err := s.branches.ForceDeleteBranch(projectID, "main"); err != nil {
-		fmt.Printf("Failed to delete main branch: %v\n", err)
+		slog.Warn("project delete: failed to delete main branch",
+			slog.String(logging.KeyProjectID, projectID), logging.Err(err))
 	}
 
 	// Delete project record
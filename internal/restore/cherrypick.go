@@ -0,0 +1,154 @@
+package restore
+
+import (
+	"fmt"
+
+	"github.com/argon-lab/argon/internal/wal"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// CherryPick copies the source branch's own data entries in [fromLSN,
+// toLSN] onto the target branch, in order, as new entries (new LSNs, the
+// target's branch ID) — pulling one specific change across without a full
+// merge. Entries inherited from the source's ancestors, control records and
+// discarded history are not picked. Pre-images are recomputed against the
+// target, so undo and time travel on the target see its own prior state;
+// deletes of documents the target does not have are skipped. Each picked
+// transaction stays atomic on the target. It returns the number of entries
+// appended; a range that splits a transaction is refused.
+func (s *Service) CherryPick(sourceBranchID, targetBranchID string, fromLSN, toLSN int64) (int, error) {
+	if sourceBranchID == targetBranchID {
+		return 0, fmt.Errorf("cannot cherry-pick a branch onto itself")
+	}
+	if fromLSN > toLSN {
+		return 0, fmt.Errorf("invalid range: from LSN %d is after to LSN %d", fromLSN, toLSN)
+	}
+	source, err := s.branches.GetBranchByID(sourceBranchID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get source branch: %w", err)
+	}
+	target, err := s.branches.GetBranchByID(targetBranchID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get target branch: %w", err)
+	}
+	if source.ProjectID != target.ProjectID {
+		return 0, fmt.Errorf("branches %s and %s belong to different projects", source.Name, target.Name)
+	}
+	if target.IsLive() {
+		// The ingester owns a checked-out branch's head.
+		return 0, fmt.Errorf("branch %s is checked out as %s: release it before cherry-picking onto it", target.Name, target.PhysicalDB)
+	}
+	if target.Protected {
+		return 0, fmt.Errorf("%w: %s is read-only; unprotect it to cherry-pick onto it", wal.ErrBranchProtected, target.Name)
+	}
+	if fromLSN < source.BaseLSN || toLSN > source.HeadLSN {
+		return 0, fmt.Errorf("range [%d, %d] is outside branch %s's range [%d, %d]",
+			fromLSN, toLSN, source.Name, source.BaseLSN, source.HeadLSN)
+	}
+	// Picking part of a transaction would land it half-applied.
+	if safe, err := s.wal.TxnSafeLSN(source.ID, toLSN); err != nil {
+		return 0, err
+	} else if safe != toLSN {
+		return 0, fmt.Errorf("range [%d, %d] ends inside a transaction: end it at %d or after the transaction", fromLSN, toLSN, safe)
+	}
+	if safe, err := s.wal.TxnSafeLSN(source.ID, fromLSN-1); err != nil {
+		return 0, err
+	} else if safe != fromLSN-1 {
+		return 0, fmt.Errorf("range [%d, %d] starts inside a transaction: start it at %d", fromLSN, toLSN, safe+1)
+	}
+
+	entries, err := s.wal.GetBranchEntries(source.ID, "", fromLSN, toLSN)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read source entries: %w", err)
+	}
+
+	// images tracks each touched document's state on the target as the
+	// picked entries apply, for the pre-images; nil means absent.
+	images := make(map[[2]string]bson.Raw)
+	imageOf := func(collection, documentID string) (bson.Raw, error) {
+		key := [2]string{collection, documentID}
+		if image, ok := images[key]; ok {
+			return image, nil
+		}
+		doc, err := s.materializer.MaterializeDocument(target, collection, documentID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to materialize %s/%s on branch %s: %w", collection, documentID, target.Name, err)
+		}
+		var image bson.Raw
+		if doc != nil {
+			if image, err = bson.Marshal(doc); err != nil {
+				return nil, fmt.Errorf("failed to marshal %s/%s: %w", collection, documentID, err)
+			}
+		}
+		images[key] = image
+		return image, nil
+	}
+
+	// A source transaction lands as one batch of its own under a fresh
+	// TxnID, so the target sees all of it or none of it; other entries are
+	// batched by size. batchTxn is the source TxnID of the pending batch.
+	picked := 0
+	batch := make([]*wal.Entry, 0, externalForkBatchSize)
+	batchTxn := ""
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if batchTxn != "" {
+			id := primitive.NewObjectID().Hex()
+			for _, e := range batch {
+				e.TxnID = id
+			}
+		}
+		if err := s.appendSeedBatch(target, batch); err != nil {
+			return err
+		}
+		picked += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+	for _, entry := range entries {
+		if !entry.IsData() || source.IsDiscardedForRead(entry.LSN, source.HeadLSN) {
+			continue
+		}
+		if entry.IsLegacy() {
+			return picked, fmt.Errorf("entry LSN %d uses the legacy schema-v1 format; run the WAL migration first", entry.LSN)
+		}
+		pre, err := imageOf(entry.Collection, entry.DocumentID)
+		if err != nil {
+			return picked, err
+		}
+		if entry.Operation == wal.OpDelete && pre == nil {
+			continue
+		}
+		if entry.TxnID != batchTxn || (batchTxn == "" && len(batch) == externalForkBatchSize) {
+			if err := flush(); err != nil {
+				return picked, err
+			}
+			batchTxn = entry.TxnID
+		}
+		metadata := make(map[string]interface{}, len(entry.Metadata)+2)
+		for k, v := range entry.Metadata {
+			metadata[k] = v
+		}
+		metadata["cherry_picked_from"] = source.ID
+		metadata["source_lsn"] = entry.LSN
+		batch = append(batch, &wal.Entry{
+			ProjectID:  target.ProjectID,
+			BranchID:   target.ID,
+			Operation:  entry.Operation,
+			Collection: entry.Collection,
+			DocumentID: entry.DocumentID,
+			PostImage:  entry.PostImage,
+			PreImage:   pre,
+			Actor:      entry.Actor,
+			Metadata:   metadata,
+		})
+		images[[2]string{entry.Collection, entry.DocumentID}] = entry.PostImage
+	}
+	if err := flush(); err != nil {
+		return picked, err
+	}
+	return picked, nil
+}
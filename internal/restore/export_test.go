@@ -0,0 +1,8 @@
+package restore
+
+import "github.com/argon-lab/argon/internal/wal"
+
+// SetSeedBatchHook exposes seedHook to the external test package.
+func (s *Service) SetSeedBatchHook(hook func(batch []*wal.Entry) error) {
+	s.seedHook = hook
+}
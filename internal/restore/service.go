@@ -1,22 +1,46 @@
 package restore
 
 import (
+	"errors"
 	"fmt"
+	"sort"
 	"time"
 
 	branchwal "github.com/argon-lab/argon/internal/branch/wal"
 	"github.com/argon-lab/argon/internal/materializer"
+	"github.com/argon-lab/argon/internal/mongoexpr"
 	"github.com/argon-lab/argon/internal/timetravel"
 	"github.com/argon-lab/argon/internal/wal"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// ProjectLookup resolves projects for cross-project forks.
+type ProjectLookup interface {
+	GetProject(projectID string) (*wal.Project, error)
+}
+
+// externalForkBatchSize bounds each append when seeding a cross-project
+// fork.
+const externalForkBatchSize = 1000
+
 // Service provides branch restore and creation from historical points
 type Service struct {
 	wal          *wal.Service
 	branches     *branchwal.BranchService
 	materializer *materializer.Service
 	timeTravel   *timetravel.Service
+	projects     ProjectLookup
+	// seedHook runs before each batch of a cross-project fork is
+	// appended; an error aborts the fork. Tests inject seed failures here.
+	seedHook func(batch []*wal.Entry) error
+}
+
+// SetProjectLookup wires in project resolution, which cross-project forks
+// need to validate both ends. A setter because the project service is
+// built on top of the branch service this one already depends on.
+func (s *Service) SetProjectLookup(projects ProjectLookup) {
+	s.projects = projects
 }
 
 // NewService creates a new restore service
@@ -34,21 +58,41 @@ func NewService(
 	}
 }
 
-// ResetBranchToLSN resets a branch to a historical LSN
+// ResetBranchToLSN resets a branch to a historical LSN. A target inside
+// a transaction moves to just before it; the returned head says where the
+// reset landed. Protected branches are refused; see ForceResetBranchToLSN.
 func (s *Service) ResetBranchToLSN(branchID string, targetLSN int64) (*wal.Branch, error) {
+	return s.resetBranchToLSN(branchID, targetLSN, false)
+}
+
+// ForceResetBranchToLSN is ResetBranchToLSN that also resets protected
+// branches, for an operator who means it (--force).
+func (s *Service) ForceResetBranchToLSN(branchID string, targetLSN int64) (*wal.Branch, error) {
+	return s.resetBranchToLSN(branchID, targetLSN, true)
+}
+
+func (s *Service) resetBranchToLSN(branchID string, targetLSN int64, force bool) (*wal.Branch, error) {
 	// Get the branch
 	branch, err := s.branches.GetBranchByID(branchID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get branch: %w", err)
 	}
+	if branch.Protected && !force {
+		return nil, protectedError(branch)
+	}
 
 	// Validate target LSN
 	if targetLSN < branch.BaseLSN {
-		return nil, fmt.Errorf("target LSN %d is before branch base LSN %d", targetLSN, branch.BaseLSN)
+		return nil, fmt.Errorf("%w: target LSN %d is before branch base LSN %d", ErrTargetOutOfRange, targetLSN, branch.BaseLSN)
 	}
 
 	if targetLSN > branch.HeadLSN {
-		return nil, fmt.Errorf("target LSN %d is beyond branch HEAD %d", targetLSN, branch.HeadLSN)
+		return nil, fmt.Errorf("%w: target LSN %d is beyond branch HEAD %d", ErrTargetOutOfRange, targetLSN, branch.HeadLSN)
+	}
+	// A reset never splits a transaction: inside one, it lands before it.
+	targetLSN, err = s.wal.TxnSafeLSN(branchID, targetLSN)
+	if err != nil {
+		return nil, err
 	}
 
 	// Safety check: warn if resetting would lose data
@@ -99,7 +143,52 @@ func (s *Service) ResetBranchToTime(branchID string, timestamp time.Time) (*wal.
 	return s.ResetBranchToLSN(branchID, targetLSN)
 }
 
-// CreateBranchAtLSN creates a new branch from a historical point
+// ResetBranchToLSNWithBackup resets a branch like ResetBranchToLSN, first
+// forking backupName at the current head so the operations the reset
+// discards stay reachable. It returns the reset branch and the backup. The
+// target is validated before anything is created, and a backup whose reset
+// then fails is removed again, so a failed call leaves neither behind.
+func (s *Service) ResetBranchToLSNWithBackup(branchID string, targetLSN int64, backupName string) (*wal.Branch, *wal.Branch, error) {
+	return s.resetWithBackup(branchID, targetLSN, backupName, false)
+}
+
+// ForceResetBranchToLSNWithBackup is ResetBranchToLSNWithBackup that also
+// resets protected branches.
+func (s *Service) ForceResetBranchToLSNWithBackup(branchID string, targetLSN int64, backupName string) (*wal.Branch, *wal.Branch, error) {
+	return s.resetWithBackup(branchID, targetLSN, backupName, true)
+}
+
+func (s *Service) resetWithBackup(branchID string, targetLSN int64, backupName string, force bool) (*wal.Branch, *wal.Branch, error) {
+	if backupName == "" {
+		return nil, nil, fmt.Errorf("backup branch name must not be empty")
+	}
+	if err := s.ValidateRestore(branchID, targetLSN); err != nil {
+		return nil, nil, err
+	}
+	branch, err := s.branches.GetBranchByID(branchID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get branch: %w", err)
+	}
+	if branch.Protected && !force {
+		return nil, nil, protectedError(branch)
+	}
+
+	backup, err := s.CreateBranchAtLSN(branch.ProjectID, branchID, backupName, branch.HeadLSN)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create backup branch: %w", err)
+	}
+	reset, err := s.resetBranchToLSN(branchID, targetLSN, force)
+	if err != nil {
+		if derr := s.branches.PurgeBranch(backup.ID); derr != nil {
+			return nil, nil, fmt.Errorf("%w (and failed to remove the backup branch: %v)", err, derr)
+		}
+		return nil, nil, err
+	}
+	return reset, backup, nil
+}
+
+// CreateBranchAtLSN creates a new branch from a historical point. A point
+// inside a transaction moves to just before it.
 func (s *Service) CreateBranchAtLSN(projectID, sourceBranchID, newBranchName string, targetLSN int64) (*wal.Branch, error) {
 	// Get the source branch
 	sourceBranch, err := s.branches.GetBranchByID(sourceBranchID)
@@ -109,8 +198,12 @@ func (s *Service) CreateBranchAtLSN(projectID, sourceBranchID, newBranchName str
 
 	// Validate target LSN
 	if targetLSN < sourceBranch.BaseLSN || targetLSN > sourceBranch.HeadLSN {
-		return nil, fmt.Errorf("target LSN %d is outside source branch range [%d, %d]",
-			targetLSN, sourceBranch.BaseLSN, sourceBranch.HeadLSN)
+		return nil, fmt.Errorf("%w: target LSN %d is outside source branch range [%d, %d]",
+			ErrTargetOutOfRange, targetLSN, sourceBranch.BaseLSN, sourceBranch.HeadLSN)
+	}
+	targetLSN, err = s.wal.TxnSafeLSN(sourceBranchID, targetLSN)
+	if err != nil {
+		return nil, err
 	}
 
 	// Create the new branch. ParentID anchors the ancestry chain: without
@@ -167,6 +260,290 @@ func (s *Service) CreateBranchFromPin(projectID, sourceBranchID, newBranchName s
 	return newBranch, nil
 }
 
+// CreateBranchFromExternal seeds a new branch in targetProject from another
+// project's branch as of lsn (0 means the source head). LSNs are scoped per
+// project, so the new branch cannot reference the source through its
+// ancestry chain: the source state is materialized and written as the new
+// branch's base instead, one put per document. The result is a root branch
+// fully independent of the source — later writes on either side never
+// reach the other.
+func (s *Service) CreateBranchFromExternal(targetProjectID, sourceProjectID, sourceBranchID string, lsn int64, name string) (*wal.Branch, error) {
+	if name == "" {
+		return nil, fmt.Errorf("branch name must not be empty")
+	}
+	if s.projects == nil {
+		return nil, fmt.Errorf("cross-project forks require a project lookup")
+	}
+	if _, err := s.projects.GetProject(targetProjectID); err != nil {
+		return nil, fmt.Errorf("target project %s: %w", targetProjectID, err)
+	}
+	state, actor, err := s.externalState(sourceProjectID, sourceBranchID, lsn)
+	if err != nil {
+		return nil, err
+	}
+
+	branch, err := s.branches.CreateBranch(targetProjectID, name, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create branch: %w", err)
+	}
+	if err := s.seedBranch(branch, state, actor); err != nil {
+		// A half-seeded branch would pass for a faithful fork and hold
+		// the name a retry needs: remove it and its entries.
+		if _, derr := s.wal.DeleteBranchEntries(branch.ID); derr != nil {
+			return nil, fmt.Errorf("%w (and failed to remove the partial branch's entries: %v)", err, derr)
+		}
+		if derr := s.branches.PurgeBranch(branch.ID); derr != nil {
+			return nil, fmt.Errorf("%w (and failed to remove the partial branch: %v)", err, derr)
+		}
+		return nil, err
+	}
+	return branch, nil
+}
+
+// SeedBranchFromExternal is CreateBranchFromExternal into an existing,
+// still-empty branch — a freshly created project's main, when cloning a
+// project. It returns the number of documents written. On failure the
+// target holds a partial seed; the caller owns its cleanup.
+func (s *Service) SeedBranchFromExternal(target *wal.Branch, sourceProjectID, sourceBranchID string, lsn int64) (int, error) {
+	if s.projects == nil {
+		return 0, fmt.Errorf("cross-project forks require a project lookup")
+	}
+	if target.HeadLSN > target.CreatedLSN {
+		return 0, fmt.Errorf("branch %s already has entries; only an empty branch can be seeded", target.Name)
+	}
+	state, actor, err := s.externalState(sourceProjectID, sourceBranchID, lsn)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.seedBranch(target, state, actor); err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, docs := range state {
+		count += len(docs)
+	}
+	return count, nil
+}
+
+// ReplayBranchFromExternal copies another project's branch history as of
+// lsn (0 means the source head) into an existing, still-empty branch: a
+// WAL-to-WAL import. Unlike SeedBranchFromExternal, which writes only the
+// resulting state, every entry along the source's ancestry is re-appended
+// in order (with new LSNs and timestamps, keeping actor, images and
+// metadata; control records are left behind), so the target's history can be time-travelled like the
+// source's. It fails without writing when the source history no longer
+// replays to the source state — garbage collection folded part of it into
+// snapshots. It returns the number of documents and of entries written;
+// on failure the target holds a partial copy and the caller owns its
+// cleanup.
+func (s *Service) ReplayBranchFromExternal(target *wal.Branch, sourceProjectID, sourceBranchID string, lsn int64) (documents, entries int, err error) {
+	if s.projects == nil {
+		return 0, 0, fmt.Errorf("cross-project forks require a project lookup")
+	}
+	if target.HeadLSN > target.CreatedLSN {
+		return 0, 0, fmt.Errorf("branch %s already has entries; only an empty branch can be seeded", target.Name)
+	}
+	state, _, err := s.externalState(sourceProjectID, sourceBranchID, lsn)
+	if err != nil {
+		return 0, 0, err
+	}
+	source, err := s.branches.GetBranchByID(sourceBranchID)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get source branch: %w", err)
+	}
+	if lsn == 0 {
+		lsn = source.HeadLSN
+	}
+	history, err := s.materializer.HistoryAtLSN(source, lsn)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read source history: %w", err)
+	}
+	if err := s.checkHistoryReplays(history, state); err != nil {
+		return 0, 0, err
+	}
+	// Only data and index entries are copied, as CherryPick does: the
+	// source's control records describe the source, not the target.
+	copied := history[:0]
+	for _, entry := range history {
+		switch entry.Operation {
+		case wal.OpPut, wal.OpDelete, wal.OpCreateIndex:
+			copied = append(copied, entry)
+		}
+	}
+	history = copied
+
+	batch := make([]*wal.Entry, 0, externalForkBatchSize)
+	for i, entry := range history {
+		batch = append(batch, &wal.Entry{
+			ProjectID:  target.ProjectID,
+			BranchID:   target.ID,
+			Operation:  entry.Operation,
+			Collection: entry.Collection,
+			DocumentID: entry.DocumentID,
+			PostImage:  entry.PostImage,
+			PreImage:   entry.PreImage,
+			TxnID:      entry.TxnID,
+			Actor:      entry.Actor,
+			Metadata:   entry.Metadata,
+		})
+		// Cut batches between transactions, never inside one.
+		last := i == len(history)-1
+		if last || (len(batch) >= externalForkBatchSize && (entry.TxnID == "" || history[i+1].TxnID != entry.TxnID)) {
+			if err := s.appendSeedBatch(target, batch); err != nil {
+				return 0, 0, err
+			}
+			batch = batch[:0]
+		}
+	}
+	for _, docs := range state {
+		documents += len(docs)
+	}
+	return documents, len(history), nil
+}
+
+// checkHistoryReplays verifies that replaying history from empty yields
+// state, so a WAL-to-WAL import never silently drops documents whose
+// entries garbage collection already reclaimed.
+func (s *Service) checkHistoryReplays(history []*wal.Entry, state map[string]map[string]bson.M) error {
+	replayed := make(map[string]map[string]bson.M)
+	for _, entry := range history {
+		if !entry.IsData() {
+			continue
+		}
+		docs, ok := replayed[entry.Collection]
+		if !ok {
+			docs = make(map[string]bson.M)
+			replayed[entry.Collection] = docs
+		}
+		if err := s.materializer.ApplyEntry(docs, entry); err != nil {
+			return fmt.Errorf("failed to replay source entry LSN %d: %w", entry.LSN, err)
+		}
+	}
+	incomplete := func(collection string) error {
+		return fmt.Errorf("source history of collection %s is incomplete (garbage-collected into snapshots); clone its state instead", collection)
+	}
+	for collection, docs := range state {
+		if len(docs) != len(replayed[collection]) {
+			return incomplete(collection)
+		}
+		for id, doc := range docs {
+			equal, err := mongoexpr.CanonicalEqual(doc, replayed[collection][id])
+			if err != nil {
+				return fmt.Errorf("failed to compare %s/%s: %w", collection, id, err)
+			}
+			if !equal {
+				return incomplete(collection)
+			}
+		}
+	}
+	for collection, docs := range replayed {
+		if _, ok := state[collection]; !ok && len(docs) > 0 {
+			return incomplete(collection)
+		}
+	}
+	return nil
+}
+
+// externalState validates a cross-project source and materializes it as of
+// lsn (0 means the source head), returning the state and the actor that
+// seed entries carry.
+func (s *Service) externalState(sourceProjectID, sourceBranchID string, lsn int64) (map[string]map[string]bson.M, string, error) {
+	if _, err := s.projects.GetProject(sourceProjectID); err != nil {
+		return nil, "", fmt.Errorf("source project %s: %w", sourceProjectID, err)
+	}
+
+	source, err := s.branches.GetBranchByID(sourceBranchID)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get source branch: %w", err)
+	}
+	// The caller names the project it is reading from; a branch ID alone
+	// must not grant access to another project's data.
+	if source.ProjectID != sourceProjectID {
+		return nil, "", fmt.Errorf("branch %s does not belong to project %s", sourceBranchID, sourceProjectID)
+	}
+	if lsn == 0 {
+		lsn = source.HeadLSN
+	}
+	if lsn < 0 || lsn > source.HeadLSN {
+		return nil, "", fmt.Errorf("target LSN %d is outside source branch range [0, %d]", lsn, source.HeadLSN)
+	}
+
+	state, err := s.materializer.MaterializeBranchAtLSN(source, lsn)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to materialize source branch at LSN %d: %w", lsn, err)
+	}
+	return state, fmt.Sprintf("fork:%s/%s@%d", sourceProjectID, source.Name, lsn), nil
+}
+
+// seedBranch writes a materialized state into a fresh branch as puts, in
+// collection and document ID order.
+func (s *Service) seedBranch(branch *wal.Branch, state map[string]map[string]bson.M, actor string) error {
+	collections := make([]string, 0, len(state))
+	for collection := range state {
+		collections = append(collections, collection)
+	}
+	sort.Strings(collections)
+
+	for _, collection := range collections {
+		docs := state[collection]
+		ids := make([]string, 0, len(docs))
+		for id := range docs {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+
+		batch := make([]*wal.Entry, 0, externalForkBatchSize)
+		for _, id := range ids {
+			post, err := bson.Marshal(docs[id])
+			if err != nil {
+				return fmt.Errorf("failed to marshal %s/%s: %w", collection, id, err)
+			}
+			batch = append(batch, &wal.Entry{
+				ProjectID:  branch.ProjectID,
+				BranchID:   branch.ID,
+				Operation:  wal.OpPut,
+				Collection: collection,
+				DocumentID: id,
+				PostImage:  post,
+				Actor:      actor,
+			})
+			if len(batch) == externalForkBatchSize {
+				if err := s.appendSeedBatch(branch, batch); err != nil {
+					return err
+				}
+				batch = batch[:0]
+			}
+		}
+		if len(batch) > 0 {
+			if err := s.appendSeedBatch(branch, batch); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// appendSeedBatch appends one batch of seed entries and advances the head.
+func (s *Service) appendSeedBatch(branch *wal.Branch, entries []*wal.Entry) error {
+	if s.seedHook != nil {
+		if err := s.seedHook(entries); err != nil {
+			return err
+		}
+	}
+	lsns, err := s.wal.AppendBatch(entries)
+	if err != nil {
+		return fmt.Errorf("failed to append seed entries: %w", err)
+	}
+	last := lsns[len(lsns)-1]
+	if err := s.branches.UpdateBranchHead(branch.ID, last, entries[len(entries)-1].Timestamp); err != nil {
+		return fmt.Errorf("failed to update branch head: %w", err)
+	}
+	if last > branch.HeadLSN {
+		branch.HeadLSN = last
+	}
+	return nil
+}
+
 // CreateBranchAtTime creates a new branch from a specific timestamp
 func (s *Service) CreateBranchAtTime(projectID, sourceBranchID, newBranchName string, timestamp time.Time) (*wal.Branch, error) {
 	// Get the source branch
@@ -195,8 +572,12 @@ func (s *Service) GetRestorePreview(branchID string, targetLSN int64) (*RestoreP
 
 	// Validate target LSN
 	if targetLSN < branch.BaseLSN || targetLSN > branch.HeadLSN {
-		return nil, fmt.Errorf("invalid target LSN %d for branch range [%d, %d]",
-			targetLSN, branch.BaseLSN, branch.HeadLSN)
+		return nil, fmt.Errorf("%w: invalid target LSN %d for branch range [%d, %d]",
+			ErrTargetOutOfRange, targetLSN, branch.BaseLSN, branch.HeadLSN)
+	}
+	targetLSN, err = s.wal.TxnSafeLSN(branchID, targetLSN)
+	if err != nil {
+		return nil, err
 	}
 
 	// Get entries that would be discarded
@@ -238,16 +619,26 @@ func (s *Service) GetRestorePreview(branchID string, targetLSN int64) (*RestoreP
 
 // RestorePreview contains information about what a restore would do
 type RestorePreview struct {
-	BranchID            string
-	BranchName          string
-	CurrentLSN          int64
-	TargetLSN           int64
-	OperationsToDiscard int
-	AffectedCollections map[string]int // collection -> operation count
-	CurrentCollections  []string
-	TargetCollections   []string
+	BranchID            string         `json:"branch_id"`
+	BranchName          string         `json:"branch_name"`
+	CurrentLSN          int64          `json:"current_lsn"`
+	TargetLSN           int64          `json:"target_lsn"`
+	OperationsToDiscard int            `json:"operations_to_discard"`
+	AffectedCollections map[string]int `json:"affected_collections"` // collection -> operation count
+	CurrentCollections  []string       `json:"current_collections"`
+	TargetCollections   []string       `json:"target_collections"`
 }
 
+// protectedError refuses an unforced reset of a protected branch.
+func protectedError(branch *wal.Branch) error {
+	return fmt.Errorf("%w: %s is read-only; unprotect it or force the reset", wal.ErrBranchProtected, branch.Name)
+}
+
+// ErrTargetOutOfRange is wrapped by ValidateRestore, the resets, previews
+// and CreateBranchAtLSN when the target lies outside the branch's [base,
+// head] range, as opposed to a failure while checking.
+var ErrTargetOutOfRange = errors.New("restore target out of range")
+
 // ValidateRestore checks if a restore operation is safe
 func (s *Service) ValidateRestore(branchID string, targetLSN int64) error {
 	branch, err := s.branches.GetBranchByID(branchID)
@@ -257,13 +648,13 @@ func (s *Service) ValidateRestore(branchID string, targetLSN int64) error {
 
 	// Check LSN range
 	if targetLSN < branch.BaseLSN {
-		return fmt.Errorf("cannot restore to LSN %d before branch creation (base LSN: %d)",
-			targetLSN, branch.BaseLSN)
+		return fmt.Errorf("%w: cannot restore to LSN %d before branch creation (base LSN: %d)",
+			ErrTargetOutOfRange, targetLSN, branch.BaseLSN)
 	}
 
 	if targetLSN > branch.HeadLSN {
-		return fmt.Errorf("cannot restore to future LSN %d (current HEAD: %d)",
-			targetLSN, branch.HeadLSN)
+		return fmt.Errorf("%w: cannot restore to future LSN %d (current HEAD: %d)",
+			ErrTargetOutOfRange, targetLSN, branch.HeadLSN)
 	}
 
 	// Check if branch has dependent branches (future enhancement)
@@ -0,0 +1,92 @@
+package restore_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	branchwal "github.com/argon-lab/argon/internal/branch/wal"
+	"github.com/argon-lab/argon/internal/materializer"
+	projectwal "github.com/argon-lab/argon/internal/project/wal"
+	"github.com/argon-lab/argon/internal/restore"
+	"github.com/argon-lab/argon/internal/timetravel"
+	"github.com/argon-lab/argon/internal/wal"
+	"github.com/argon-lab/argon/internal/walwriter"
+)
+
+func setupTestDB(t *testing.T) *mongo.Database {
+	ctx := context.Background()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI("mongodb://localhost:27017"))
+	require.NoError(t, err)
+	db := client.Database(fmt.Sprintf("argon_restore_test_%d", time.Now().UnixNano()))
+	t.Cleanup(func() {
+		if err := db.Drop(context.Background()); err != nil {
+			t.Logf("Failed to drop test database: %v", err)
+		}
+		if err := client.Disconnect(context.Background()); err != nil {
+			t.Logf("Failed to disconnect: %v", err)
+		}
+	})
+	return db
+}
+
+func TestCreateBranchFromExternal_FailedSeed(t *testing.T) {
+	db := setupTestDB(t)
+	walService, err := wal.NewService(db)
+	require.NoError(t, err)
+	branchService, err := branchwal.NewBranchService(db, walService)
+	require.NoError(t, err)
+	projectService, err := projectwal.NewProjectService(db, walService, branchService)
+	require.NoError(t, err)
+	materializerService := materializer.NewService(walService, branchService)
+	timeTravelService := timetravel.NewService(walService, materializerService)
+	restoreService := restore.NewService(walService, branchService, materializerService, timeTravelService)
+	restoreService.SetProjectLookup(projectService)
+	ctx := context.Background()
+
+	source, err := projectService.CreateProject("fork-source")
+	require.NoError(t, err)
+	target, err := projectService.CreateProject("fork-target")
+	require.NoError(t, err)
+	sourceMain, err := branchService.GetBranch(source.ID, "main")
+	require.NoError(t, err)
+	sourceWriter := walwriter.New(walService, branchService, materializerService, sourceMain)
+	_, err = sourceWriter.Put(ctx, "users", bson.M{"_id": "u1", "name": "Alice"})
+	require.NoError(t, err)
+	_, err = sourceWriter.Put(ctx, "orders", bson.M{"_id": "o1", "total": int32(10)})
+	require.NoError(t, err)
+	forkLSN := walService.GetCurrentLSN(source.ID)
+
+	batches := 0
+	restoreService.SetSeedBatchHook(func(batch []*wal.Entry) error {
+		batches++
+		if batches == 2 {
+			return errors.New("injected seed failure")
+		}
+		return nil
+	})
+	_, err = restoreService.CreateBranchFromExternal(target.ID, source.ID, sourceMain.ID, forkLSN, "retried")
+	require.ErrorContains(t, err, "injected seed failure")
+	restoreService.SetSeedBatchHook(nil)
+
+	_, err = branchService.GetBranch(target.ID, "retried")
+	assert.Error(t, err, "the partial branch is removed")
+
+	// The name is free again, and the retry seeds the full state.
+	forked, err := restoreService.CreateBranchFromExternal(target.ID, source.ID, sourceMain.ID, forkLSN, "retried")
+	require.NoError(t, err)
+	forked, err = branchService.GetBranchByID(forked.ID)
+	require.NoError(t, err)
+	state, err := materializerService.MaterializeBranch(forked)
+	require.NoError(t, err)
+	assert.Len(t, state["users"], 1)
+	assert.Len(t, state["orders"], 1)
+}
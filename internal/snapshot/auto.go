@@ -2,8 +2,9 @@ package snapshot
 
 import (
 	"context"
-	"log"
+	"log/slog"
 
+	"github.com/argon-lab/argon/internal/logging"
 	"github.com/argon-lab/argon/internal/wal"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -89,7 +90,11 @@ func (s *Service) MaybeSnapshot(branch *wal.Branch) {
 	run := func() {
 		defer release()
 		if err := s.snapshotIfStale(branch, cfg.Threshold); err != nil {
-			log.Printf("auto-snapshot for branch %s failed: %v", branch.ID, err)
+			slog.Warn("auto-snapshot failed",
+				slog.String(logging.KeyProjectID, branch.ProjectID),
+				slog.String(logging.KeyBranchID, branch.ID),
+				slog.Int64(logging.KeyLSN, branch.HeadLSN),
+				slog.String(logging.KeyOperation, "snapshot"), logging.Err(err))
 		}
 	}
 
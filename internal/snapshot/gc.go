@@ -2,8 +2,10 @@ package snapshot
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
+	"github.com/argon-lab/argon/internal/wal"
 	"go.mongodb.org/mongo-driver/bson"
 )
 
@@ -77,3 +79,74 @@ func (s *Service) CleanupBranch(ctx context.Context, branchID string) (manifests
 	}
 	return manifestsRemoved, int64(len(orphaned)), nil
 }
+
+// SweepOrphans removes the snapshots of branches nothing can read anymore,
+// which the delete hook misses: branches whose record is gone entirely,
+// deleted branches whose cleanup failed, and force-deleted branches (a
+// deleted project's main) once no live branch of their project still
+// descends from them. It returns how many branches were swept.
+func (s *Service) SweepOrphans(ctx context.Context) (branchesSwept, manifestsRemoved, chunksRemoved int64, err error) {
+	ids, err := s.manifests.Distinct(ctx, "branch_id", bson.M{})
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to list snapshotted branches: %w", err)
+	}
+
+	// Per project, the branches some live branch's ancestry reaches.
+	anchoredByProject := make(map[string]map[string]bool)
+	anchored := func(projectID string) (map[string]bool, error) {
+		if set, ok := anchoredByProject[projectID]; ok {
+			return set, nil
+		}
+		live, err := s.branches.ListBranches(projectID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list branches of project %s: %w", projectID, err)
+		}
+		set := make(map[string]bool)
+		for _, b := range live {
+			for id := b.ID; id != "" && !set[id]; {
+				set[id] = true
+				ancestor, err := s.branches.GetBranchByIDAny(id)
+				if errors.Is(err, wal.ErrBranchNotFound) {
+					break
+				}
+				if err != nil {
+					return nil, fmt.Errorf("failed to resolve branch %s: %w", id, err)
+				}
+				id = ancestor.ParentID
+			}
+		}
+		anchoredByProject[projectID] = set
+		return set, nil
+	}
+
+	for _, v := range ids {
+		branchID, ok := v.(string)
+		if !ok {
+			continue
+		}
+		branch, err := s.branches.GetBranchByIDAny(branchID)
+		switch {
+		case errors.Is(err, wal.ErrBranchNotFound):
+		case err != nil:
+			return branchesSwept, manifestsRemoved, chunksRemoved, fmt.Errorf("failed to resolve branch %s: %w", branchID, err)
+		case !branch.IsDeleted:
+			continue
+		default:
+			set, err := anchored(branch.ProjectID)
+			if err != nil {
+				return branchesSwept, manifestsRemoved, chunksRemoved, err
+			}
+			if set[branchID] {
+				continue
+			}
+		}
+		manifests, chunks, err := s.CleanupBranch(ctx, branchID)
+		if err != nil {
+			return branchesSwept, manifestsRemoved, chunksRemoved, err
+		}
+		branchesSwept++
+		manifestsRemoved += manifests
+		chunksRemoved += chunks
+	}
+	return branchesSwept, manifestsRemoved, chunksRemoved, nil
+}
@@ -89,6 +89,16 @@ func (s *Service) CreateSnapshot(ctx context.Context, branchID string, lsn int64
 	if lsn <= branch.BaseLSN || lsn > branch.HeadLSN {
 		return nil, fmt.Errorf("snapshot LSN %d outside branch range (%d, %d]", lsn, branch.BaseLSN, branch.HeadLSN)
 	}
+	// Replay resumes right above a snapshot, so one must not sit inside a
+	// transaction, whose earlier entries it leaves out.
+	safe, err := s.materializer.TxnSafeLSN(branch, lsn)
+	if err != nil {
+		return nil, err
+	}
+	if safe <= branch.BaseLSN {
+		return nil, fmt.Errorf("snapshot LSN %d falls inside the branch's first transaction", lsn)
+	}
+	lsn = safe
 
 	state, err := s.materializer.MaterializeBranchAtLSN(branch, lsn)
 	if err != nil {
@@ -0,0 +1,194 @@
+package timetravel
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/argon-lab/argon/internal/mongoexpr"
+	"github.com/argon-lab/argon/internal/wal"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Projection is a compiled MongoDB-style field projection: either an
+// inclusion list or an exclusion list, never both, with _id included
+// unless excluded explicitly.
+type Projection struct {
+	include bool
+	fields  projectionTree
+	dropID  bool
+}
+
+// projectionTree holds projected dot paths split into parts; a nil subtree
+// marks the end of a path.
+type projectionTree map[string]projectionTree
+
+// CompileProjection validates a projection of field → 1/0 (or true/false),
+// with dot paths for embedded fields. Mixing inclusions and exclusions is
+// an error, except that _id may always be excluded.
+func CompileProjection(projection bson.M) (*Projection, error) {
+	p := &Projection{fields: projectionTree{}}
+	mode := 0 // 1 inclusion, -1 exclusion
+	for path, value := range projection {
+		include, err := projectionFlag(path, value)
+		if err != nil {
+			return nil, err
+		}
+		if path == "_id" {
+			p.dropID = !include
+			continue
+		}
+		want := -1
+		if include {
+			want = 1
+		}
+		if mode != 0 && mode != want {
+			return nil, fmt.Errorf("projection cannot mix inclusion and exclusion (field %q)", path)
+		}
+		mode = want
+		if err := p.fields.add(path); err != nil {
+			return nil, err
+		}
+	}
+	// {_id: 1} on its own keeps just _id.
+	p.include = mode == 1 || (mode == 0 && len(projection) == 1 && !p.dropID)
+	return p, nil
+}
+
+func projectionFlag(path string, value interface{}) (bool, error) {
+	if path == "" || strings.HasPrefix(path, "$") {
+		return false, fmt.Errorf("invalid projection field %q", path)
+	}
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case int:
+		return v != 0, nil
+	case int32:
+		return v != 0, nil
+	case int64:
+		return v != 0, nil
+	case float64:
+		return v != 0, nil
+	}
+	return false, fmt.Errorf("projection value for %q must be 1/0 or true/false, got %T", path, value)
+}
+
+func (t projectionTree) add(path string) error {
+	parts := strings.Split(path, ".")
+	node := t
+	for i, part := range parts {
+		if part == "" {
+			return fmt.Errorf("invalid projection field %q", path)
+		}
+		sub, exists := node[part]
+		last := i == len(parts)-1
+		switch {
+		case exists && (sub == nil || last):
+			return fmt.Errorf("projection path %q collides with another projected path", path)
+		case last:
+			node[part] = nil
+		case !exists:
+			sub = projectionTree{}
+			node[part] = sub
+		}
+		node = sub
+	}
+	return nil
+}
+
+// Apply returns the projected copy of doc; doc itself is not modified.
+func (p *Projection) Apply(doc bson.M) bson.M {
+	var out bson.M
+	if p.include {
+		out = includeFields(doc, p.fields)
+		if id, ok := doc["_id"]; ok && !p.dropID {
+			out["_id"] = id
+		}
+	} else {
+		out = excludeFields(doc, p.fields)
+		if p.dropID {
+			delete(out, "_id")
+		}
+	}
+	return out
+}
+
+func includeFields(doc bson.M, tree projectionTree) bson.M {
+	out := bson.M{}
+	for key, sub := range tree {
+		value, ok := doc[key]
+		if !ok {
+			continue
+		}
+		if sub == nil {
+			out[key] = value
+			continue
+		}
+		if embedded, isDoc := mongoexpr.AsDocument(value); isDoc {
+			out[key] = includeFields(embedded, sub)
+		} else if items, isArr := mongoexpr.AsArray(value); isArr {
+			// Paths into an array project each embedded document and
+			// drop scalar elements, as in MongoDB.
+			arr := bson.A{}
+			for _, item := range items {
+				if itemDoc, isDoc := mongoexpr.AsDocument(item); isDoc {
+					arr = append(arr, includeFields(itemDoc, sub))
+				}
+			}
+			out[key] = arr
+		}
+	}
+	return out
+}
+
+func excludeFields(doc bson.M, tree projectionTree) bson.M {
+	out := make(bson.M, len(doc))
+	for key, value := range doc {
+		sub, projected := tree[key]
+		switch {
+		case !projected:
+			out[key] = value
+		case sub == nil:
+			// Excluded.
+		default:
+			if embedded, isDoc := mongoexpr.AsDocument(value); isDoc {
+				out[key] = excludeFields(embedded, sub)
+			} else if items, isArr := mongoexpr.AsArray(value); isArr {
+				arr := make(bson.A, len(items))
+				for i, item := range items {
+					if itemDoc, isDoc := mongoexpr.AsDocument(item); isDoc {
+						arr[i] = excludeFields(itemDoc, sub)
+					} else {
+						arr[i] = item
+					}
+				}
+				out[key] = arr
+			} else {
+				out[key] = value
+			}
+		}
+	}
+	return out
+}
+
+// MaterializeAtLSNWithProjection is MaterializeAtLSN with each document
+// pruned by projection (see CompileProjection). A nil or empty projection
+// returns whole documents.
+func (s *Service) MaterializeAtLSNWithProjection(branch *wal.Branch, collection string, targetLSN int64, projection bson.M) (map[string]bson.M, error) {
+	if len(projection) == 0 {
+		return s.MaterializeAtLSN(branch, collection, targetLSN)
+	}
+	p, err := CompileProjection(projection)
+	if err != nil {
+		return nil, err
+	}
+	state, err := s.MaterializeAtLSNContext(context.Background(), branch, collection, targetLSN)
+	if err != nil {
+		return nil, err
+	}
+	for id, doc := range state {
+		state[id] = p.Apply(doc)
+	}
+	return state, nil
+}
@@ -0,0 +1,53 @@
+package timetravel
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/argon-lab/argon/internal/mongoexpr"
+	"github.com/argon-lab/argon/internal/wal"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// QueryAtLSN returns the documents of a collection that match a MongoDB
+// query filter as of an LSN, keyed like MaterializeAtLSN. A nil or empty
+// filter matches every document. The filter is validated before anything
+// is materialized.
+func (s *Service) QueryAtLSN(branch *wal.Branch, collection string, filter bson.M, targetLSN int64) (map[string]bson.M, error) {
+	return s.QueryAtLSNContext(context.Background(), branch, collection, filter, targetLSN)
+}
+
+// QueryAtLSNContext is QueryAtLSN bounded by ctx.
+func (s *Service) QueryAtLSNContext(ctx context.Context, branch *wal.Branch, collection string, filter bson.M, targetLSN int64) (map[string]bson.M, error) {
+	matcher, err := mongoexpr.CompileFilter(filter)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter: %w", err)
+	}
+	state, err := s.MaterializeAtLSNContext(ctx, branch, collection, targetLSN)
+	if err != nil {
+		return nil, err
+	}
+	if len(filter) == 0 {
+		return state, nil
+	}
+	for id, doc := range state {
+		matched, err := matcher.Match(doc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to match document %s: %w", id, err)
+		}
+		if !matched {
+			delete(state, id)
+		}
+	}
+	return state, nil
+}
+
+// CountAtLSN counts the documents of a collection matching a filter as of
+// an LSN.
+func (s *Service) CountAtLSN(branch *wal.Branch, collection string, filter bson.M, targetLSN int64) (int, error) {
+	state, err := s.QueryAtLSN(branch, collection, filter, targetLSN)
+	if err != nil {
+		return 0, err
+	}
+	return len(state), nil
+}
@@ -1,12 +1,16 @@
 package timetravel
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/argon-lab/argon/internal/materializer"
 	"github.com/argon-lab/argon/internal/wal"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 // Service provides time travel capabilities for WAL-based branches. All
@@ -16,6 +20,10 @@ import (
 type Service struct {
 	wal          *wal.Service
 	materializer *materializer.Service
+
+	// Set by EnableTags.
+	tags     *mongo.Collection
+	branches BranchLookup
 }
 
 // NewService creates a new time travel service
@@ -28,10 +36,15 @@ func NewService(walService *wal.Service, materializerService *materializer.Servi
 
 // MaterializeAtLSN reconstructs the state of a collection at a specific LSN
 func (s *Service) MaterializeAtLSN(branch *wal.Branch, collection string, targetLSN int64) (map[string]bson.M, error) {
+	return s.MaterializeAtLSNContext(context.Background(), branch, collection, targetLSN)
+}
+
+// MaterializeAtLSNContext is MaterializeAtLSN bounded by ctx.
+func (s *Service) MaterializeAtLSNContext(ctx context.Context, branch *wal.Branch, collection string, targetLSN int64) (map[string]bson.M, error) {
 	if err := s.validateTargetLSN(branch, targetLSN); err != nil {
 		return nil, err
 	}
-	return s.materializer.MaterializeCollectionAtLSN(branch, collection, targetLSN)
+	return s.materializer.MaterializeCollectionAtLSNContext(ctx, branch, collection, targetLSN)
 }
 
 // MaterializeAtTime reconstructs the state of a collection at a specific timestamp
@@ -43,11 +56,15 @@ func (s *Service) MaterializeAtTime(branch *wal.Branch, collection string, times
 	return s.MaterializeAtLSN(branch, collection, targetLSN)
 }
 
+// ErrNoHistoryAtTime is returned by FindLSNAtTime for a timestamp the
+// branch has no history at: one in the future or before its first entry.
+var ErrNoHistoryAtTime = errors.New("no history at time")
+
 // FindLSNAtTime finds the latest LSN on the branch at or before the given
 // timestamp.
 func (s *Service) FindLSNAtTime(branch *wal.Branch, timestamp time.Time) (int64, error) {
 	if timestamp.After(time.Now()) {
-		return 0, fmt.Errorf("cannot find LSN for future timestamp %v", timestamp)
+		return 0, fmt.Errorf("%w: cannot find LSN for future timestamp %v", ErrNoHistoryAtTime, timestamp)
 	}
 
 	entries, err := s.wal.GetEntriesByTimestamp(branch.ProjectID, timestamp)
@@ -64,7 +81,7 @@ func (s *Service) FindLSNAtTime(branch *wal.Branch, timestamp time.Time) (int64,
 	}
 
 	if latestLSN == 0 {
-		return 0, fmt.Errorf("no entries found before timestamp %v", timestamp)
+		return 0, fmt.Errorf("%w: no entries found before timestamp %v", ErrNoHistoryAtTime, timestamp)
 	}
 
 	return latestLSN, nil
@@ -72,10 +89,15 @@ func (s *Service) FindLSNAtTime(branch *wal.Branch, timestamp time.Time) (int64,
 
 // GetBranchStateAtLSN returns the complete state of all collections at a specific LSN
 func (s *Service) GetBranchStateAtLSN(branch *wal.Branch, targetLSN int64) (map[string]map[string]bson.M, error) {
+	return s.GetBranchStateAtLSNContext(context.Background(), branch, targetLSN)
+}
+
+// GetBranchStateAtLSNContext is GetBranchStateAtLSN bounded by ctx.
+func (s *Service) GetBranchStateAtLSNContext(ctx context.Context, branch *wal.Branch, targetLSN int64) (map[string]map[string]bson.M, error) {
 	if err := s.validateTargetLSN(branch, targetLSN); err != nil {
 		return nil, err
 	}
-	return s.materializer.MaterializeBranchAtLSN(branch, targetLSN)
+	return s.materializer.MaterializeBranchAtLSNContext(ctx, branch, targetLSN)
 }
 
 // GetDocumentHistoryAtLSN returns the history of a document up to a specific LSN
@@ -86,6 +108,43 @@ func (s *Service) GetDocumentHistoryAtLSN(branch *wal.Branch, collection, docume
 	return s.wal.GetDocumentHistory(branch.ID, collection, documentID, 0, targetLSN)
 }
 
+// GetDeletedDocument returns what a document looked like just before its
+// most recent delete on the branch at or before targetLSN, with that
+// delete's LSN, actor and time. Deletes carry their pre-image, so this is
+// one history read rather than a replay; a delete recorded without one
+// (captured from a change stream that had no pre-image) falls back to
+// materializing the document just below the delete. A document with no
+// such delete is wal.ErrNoEntriesFound.
+func (s *Service) GetDeletedDocument(branch *wal.Branch, collection, documentID string, targetLSN int64) (*DeletedDocument, error) {
+	if err := s.validateTargetLSN(branch, targetLSN); err != nil {
+		return nil, err
+	}
+	history, err := s.wal.GetDocumentHistory(branch.ID, collection, documentID, 0, targetLSN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get document history: %w", err)
+	}
+
+	for i := len(history) - 1; i >= 0; i-- {
+		entry := history[i]
+		if entry.Operation != wal.OpDelete || branch.IsDiscardedForRead(entry.LSN, targetLSN) {
+			continue
+		}
+		deleted := &DeletedDocument{LSN: entry.LSN, Actor: entry.Actor, Timestamp: entry.Timestamp}
+		if len(entry.PreImage) > 0 {
+			if err := bson.Unmarshal(entry.PreImage, &deleted.Document); err != nil {
+				return nil, fmt.Errorf("failed to decode pre-image of LSN %d: %w", entry.LSN, err)
+			}
+		} else {
+			deleted.Document, err = s.materializer.MaterializeDocumentAtLSN(branch, collection, documentID, entry.LSN-1)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return deleted, nil
+	}
+	return nil, fmt.Errorf("%w: %s/%s was not deleted at or before LSN %d", wal.ErrNoEntriesFound, collection, documentID, targetLSN)
+}
+
 // FindModifiedCollections returns collections that were modified between two LSNs
 func (s *Service) FindModifiedCollections(branch *wal.Branch, fromLSN, toLSN int64) ([]string, error) {
 	entries, err := s.wal.GetBranchEntries(branch.ID, "", fromLSN, toLSN)
@@ -108,6 +167,43 @@ func (s *Service) FindModifiedCollections(branch *wal.Branch, fromLSN, toLSN int
 	return result, nil
 }
 
+// GetDocumentsModifiedInRange returns the documents of one collection that
+// were written between two LSNs (inclusive), most recently changed first,
+// each with the operations applied to it in LSN order. It is the
+// per-document counterpart of FindModifiedCollections.
+func (s *Service) GetDocumentsModifiedInRange(branch *wal.Branch, collection string, fromLSN, toLSN int64) ([]ModifiedDocument, error) {
+	if collection == "" {
+		return nil, fmt.Errorf("collection is required")
+	}
+	entries, err := s.wal.GetBranchEntries(branch.ID, collection, fromLSN, toLSN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get WAL entries: %w", err)
+	}
+
+	byID := make(map[string]*ModifiedDocument)
+	for _, entry := range entries {
+		if !entry.IsData() || entry.DocumentID == "" {
+			continue
+		}
+		doc, ok := byID[entry.DocumentID]
+		if !ok {
+			doc = &ModifiedDocument{DocumentID: entry.DocumentID}
+			byID[entry.DocumentID] = doc
+		}
+		doc.Operations = append(doc.Operations, entry.Operation)
+		doc.LastLSN = entry.LSN
+	}
+
+	result := make([]ModifiedDocument, 0, len(byID))
+	for _, doc := range byID {
+		result = append(result, *doc)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].LastLSN > result[j].LastLSN
+	})
+	return result, nil
+}
+
 // GetTimeTravelInfo returns metadata about available time travel range
 func (s *Service) GetTimeTravelInfo(branch *wal.Branch) (*TimeTravelInfo, error) {
 	allEntries, err := s.wal.GetBranchEntries(branch.ID, "", 0, branch.HeadLSN)
@@ -131,9 +227,22 @@ func (s *Service) GetTimeTravelInfo(branch *wal.Branch) (*TimeTravelInfo, error)
 			EarliestLSN: branch.BaseLSN,
 			LatestLSN:   branch.HeadLSN,
 			EntryCount:  0,
+			Collections: map[string]CollectionTimeRange{},
 		}, nil
 	}
 
+	// Entries arrive in LSN order, so a collection's first entry is its
+	// earliest travel point.
+	collections := make(map[string]CollectionTimeRange)
+	for _, entry := range entries {
+		if _, seen := collections[entry.Collection]; !seen {
+			collections[entry.Collection] = CollectionTimeRange{
+				EarliestLSN:  entry.LSN,
+				EarliestTime: entry.Timestamp,
+			}
+		}
+	}
+
 	return &TimeTravelInfo{
 		BranchID:     branch.ID,
 		BranchName:   branch.Name,
@@ -142,6 +251,7 @@ func (s *Service) GetTimeTravelInfo(branch *wal.Branch) (*TimeTravelInfo, error)
 		EarliestTime: entries[0].Timestamp,
 		LatestTime:   entries[len(entries)-1].Timestamp,
 		EntryCount:   len(entries),
+		Collections:  collections,
 	}, nil
 }
 
@@ -155,6 +265,21 @@ func (s *Service) validateTargetLSN(branch *wal.Branch, targetLSN int64) error {
 	return nil
 }
 
+// DeletedDocument is a document as it was just before a delete.
+type DeletedDocument struct {
+	LSN       int64 // the delete
+	Actor     string
+	Timestamp time.Time
+	Document  bson.M
+}
+
+// ModifiedDocument is one document touched within an LSN range.
+type ModifiedDocument struct {
+	DocumentID string
+	Operations []wal.OperationType // in LSN order
+	LastLSN    int64               // the most recent change in the range
+}
+
 // TimeTravelInfo contains metadata about time travel capabilities
 type TimeTravelInfo struct {
 	BranchID     string
@@ -164,4 +289,12 @@ type TimeTravelInfo struct {
 	EarliestTime time.Time
 	LatestTime   time.Time
 	EntryCount   int
+	Collections  map[string]CollectionTimeRange // keyed by collection name
+}
+
+// CollectionTimeRange is where a collection's history begins: before its
+// first entry there is nothing of it to travel to.
+type CollectionTimeRange struct {
+	EarliestLSN  int64
+	EarliestTime time.Time
 }
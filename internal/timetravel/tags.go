@@ -0,0 +1,144 @@
+package timetravel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/argon-lab/argon/internal/wal"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Tag names an LSN on a branch ("pre-migration"), so history can be
+// addressed by name instead of by raw LSN. Tags are unique per branch.
+// Unlike pins they are lightweight: they do not hold back garbage
+// collection or branch deletion, and a tag whose history was reclaimed no
+// longer materializes.
+type Tag struct {
+	ID        string    `bson:"_id" json:"id"`
+	BranchID  string    `bson:"branch_id" json:"branch_id"`
+	Name      string    `bson:"name" json:"name"`
+	LSN       int64     `bson:"lsn" json:"lsn"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+}
+
+// BranchLookup resolves branch IDs for tag validation; the branch service
+// implements it.
+type BranchLookup interface {
+	GetBranchByID(branchID string) (*wal.Branch, error)
+}
+
+// ErrTagNotFound is returned by GetTag for an unknown tag name.
+var ErrTagNotFound = errors.New("tag not found")
+
+// EnableTags stores tags in db's tags collection and creates its indexes.
+// Without it the tag methods return an error.
+func (s *Service) EnableTags(db *mongo.Database, branches BranchLookup) error {
+	tags := db.Collection("tags")
+	_, err := tags.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "branch_id", Value: 1}, {Key: "name", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create tag indexes: %w", err)
+	}
+	s.tags = tags
+	s.branches = branches
+	return nil
+}
+
+// CreateTag names an LSN on a branch. An LSN of 0 tags the branch's
+// current head; otherwise the LSN must lie within [base, head].
+func (s *Service) CreateTag(branchID, name string, lsn int64) (*Tag, error) {
+	if s.tags == nil {
+		return nil, errors.New("tags are not enabled")
+	}
+	if name == "" {
+		return nil, errors.New("tag name must not be empty")
+	}
+	branch, err := s.branches.GetBranchByID(branchID)
+	if err != nil {
+		return nil, fmt.Errorf("branch not found: %w", err)
+	}
+	if lsn == 0 {
+		lsn = branch.HeadLSN
+	}
+	if lsn < branch.BaseLSN || lsn > branch.HeadLSN {
+		return nil, fmt.Errorf("tag LSN %d is outside branch range [%d, %d]",
+			lsn, branch.BaseLSN, branch.HeadLSN)
+	}
+
+	tag := &Tag{
+		ID:        primitive.NewObjectID().Hex(),
+		BranchID:  branch.ID,
+		Name:      name,
+		LSN:       lsn,
+		CreatedAt: time.Now(),
+	}
+	if _, err := s.tags.InsertOne(context.Background(), tag); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, fmt.Errorf("tag %q already exists on this branch", name)
+		}
+		return nil, fmt.Errorf("failed to create tag: %w", err)
+	}
+	return tag, nil
+}
+
+// GetTag returns a branch's tag by name.
+func (s *Service) GetTag(branchID, name string) (*Tag, error) {
+	if s.tags == nil {
+		return nil, errors.New("tags are not enabled")
+	}
+	var tag Tag
+	err := s.tags.FindOne(context.Background(),
+		bson.M{"branch_id": branchID, "name": name}).Decode(&tag)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("%w: %q", ErrTagNotFound, name)
+		}
+		return nil, err
+	}
+	return &tag, nil
+}
+
+// ListTags returns a branch's tags in LSN order.
+func (s *Service) ListTags(branchID string) ([]*Tag, error) {
+	if s.tags == nil {
+		return nil, errors.New("tags are not enabled")
+	}
+	ctx := context.Background()
+	cursor, err := s.tags.Find(ctx, bson.M{"branch_id": branchID},
+		options.Find().SetSort(bson.D{{Key: "lsn", Value: 1}, {Key: "name", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = cursor.Close(ctx) }()
+	tags := make([]*Tag, 0)
+	if err := cursor.All(ctx, &tags); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// MaterializeAtTag reconstructs the state of a collection at the LSN a
+// branch tag names.
+func (s *Service) MaterializeAtTag(branch *wal.Branch, collection, name string) (map[string]bson.M, error) {
+	tag, err := s.GetTag(branch.ID, name)
+	if err != nil {
+		return nil, err
+	}
+	return s.MaterializeAtLSN(branch, collection, tag.LSN)
+}
+
+// DeleteBranchTags removes a deleted branch's tags.
+func (s *Service) DeleteBranchTags(branchID string) error {
+	if s.tags == nil {
+		return nil
+	}
+	_, err := s.tags.DeleteMany(context.Background(), bson.M{"branch_id": branchID})
+	return err
+}
@@ -0,0 +1,64 @@
+package timetravel
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/argon-lab/argon/internal/wal"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// DocumentVersion is one step of a document's timeline: the document as
+// it stood right after the entry at LSN.
+type DocumentVersion struct {
+	LSN       int64             `json:"lsn"`
+	Timestamp time.Time         `json:"timestamp"`
+	Operation wal.OperationType `json:"operation"`
+	// State is nil after a delete: a tombstone.
+	State bson.M `json:"state"`
+	// Lifecycle counts the document's lives from 1: a put after a delete
+	// re-creates the document and starts the next one.
+	Lifecycle int `json:"lifecycle"`
+}
+
+// GetDocumentTimeline returns a document's history on a branch up to its
+// head, oldest first, as the states it passed through rather than raw WAL
+// entries. History inherited from ancestors is included; entries a reset
+// discarded are not. A transaction's changes to the document are folded
+// into one version, the state after it, since no reader can observe the
+// steps in between. A document that never existed has an empty timeline.
+func (s *Service) GetDocumentTimeline(branch *wal.Branch, collection, documentID string) ([]DocumentVersion, error) {
+	if collection == "" || documentID == "" {
+		return nil, fmt.Errorf("collection and document ID are required")
+	}
+	entries, err := s.materializer.DocumentEntriesAtLSN(branch, collection, documentID, branch.HeadLSN)
+	if err != nil {
+		return nil, err
+	}
+
+	state := make(map[string]bson.M, 1)
+	timeline := make([]DocumentVersion, 0, len(entries))
+	lifecycle := 0
+	existed := false
+	for i, entry := range entries {
+		if err := s.materializer.ApplyEntry(state, entry); err != nil {
+			return nil, fmt.Errorf("failed to apply entry LSN %d: %w", entry.LSN, err)
+		}
+		if entry.TxnID != "" && i+1 < len(entries) && entries[i+1].TxnID == entry.TxnID {
+			continue
+		}
+		doc, exists := state[documentID]
+		if exists && !existed {
+			lifecycle++
+		}
+		existed = exists
+		timeline = append(timeline, DocumentVersion{
+			LSN:       entry.LSN,
+			Timestamp: entry.Timestamp,
+			Operation: entry.Operation,
+			State:     doc,
+			Lifecycle: lifecycle,
+		})
+	}
+	return timeline, nil
+}
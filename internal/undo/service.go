@@ -176,6 +176,9 @@ func (s *Service) Apply(ctx context.Context, branch *wal.Branch, plan *Plan) (re
 	if branch.ID != plan.BranchID {
 		return 0, 0, fmt.Errorf("plan belongs to branch %s, not %s", plan.BranchID, branch.ID)
 	}
+	if branch.Protected {
+		return 0, 0, fmt.Errorf("%w: %s is read-only; unprotect it to undo", wal.ErrBranchProtected, branch.Name)
+	}
 	if branch.IsLive() {
 		return s.applyPhysical(ctx, branch, plan)
 	}
@@ -236,7 +239,7 @@ func (s *Service) applyWAL(branch *wal.Branch, plan *Plan) (restored, deleted in
 	if err != nil {
 		return 0, 0, fmt.Errorf("failed to append compensations: %w", err)
 	}
-	if err := s.branches.UpdateBranchHead(branch.ID, lsns[len(lsns)-1]); err != nil {
+	if err := s.branches.UpdateBranchHead(branch.ID, lsns[len(lsns)-1], entries[len(entries)-1].Timestamp); err != nil {
 		return 0, 0, fmt.Errorf("failed to advance branch head: %w", err)
 	}
 	return restored, deleted, nil
@@ -0,0 +1,214 @@
+package wal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// alertQueueSize bounds the alerts waiting for delivery.
+const alertQueueSize = 100
+
+// AlertSink delivers monitor alerts outside the process. Deliver is called
+// from the monitor's delivery goroutine, one alert at a time, for new
+// alerts and again (with Resolved set) when they resolve; ctx is cancelled
+// when the monitor stops.
+type AlertSink interface {
+	Deliver(ctx context.Context, alert Alert) error
+}
+
+// deliver queues an alert for the sinks without blocking; a full queue
+// drops it. Callers hold m.mu.
+func (m *Monitor) deliver(alert Alert) {
+	if len(m.config.AlertSinks) == 0 {
+		return
+	}
+	select {
+	case m.deliveries <- alert:
+	default:
+		log.Printf("WAL Monitor: alert queue full, dropped delivery of '%s'", alert.Title)
+	}
+}
+
+func (m *Monitor) deliveryLoop() {
+	defer m.wg.Done()
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case alert := <-m.deliveries:
+			m.mu.RLock()
+			sinks := append([]AlertSink(nil), m.config.AlertSinks...)
+			m.mu.RUnlock()
+			for _, sink := range sinks {
+				if err := sink.Deliver(m.ctx, alert); err != nil {
+					log.Printf("WAL Monitor: failed to deliver alert '%s': %v", alert.Title, err)
+				}
+			}
+		}
+	}
+}
+
+// persist queues an alert for the store, if there is one. Callers hold
+// m.mu.
+func (m *Monitor) persist(alert Alert) {
+	if m.store == nil {
+		return
+	}
+	m.persistQueue = append(m.persistQueue, alert)
+	select {
+	case m.persistReady <- struct{}{}:
+	default:
+	}
+}
+
+// persistLoop writes queued alerts to the store in order. On Stop it
+// writes what is still queued before returning.
+func (m *Monitor) persistLoop() {
+	defer m.wg.Done()
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			m.flushPersistQueue()
+			return
+		case <-m.persistReady:
+			m.flushPersistQueue()
+		}
+	}
+}
+
+func (m *Monitor) flushPersistQueue() {
+	m.mu.Lock()
+	queued, store := m.persistQueue, m.store
+	m.persistQueue = nil
+	m.mu.Unlock()
+	for _, alert := range queued {
+		// Runs after Stop too: the queue drains whatever the context.
+		if err := store.Deliver(context.Background(), alert); err != nil {
+			log.Printf("WAL Monitor: failed to persist alert '%s': %v", alert.Title, err)
+		}
+	}
+}
+
+// WebhookSink POSTs alerts as JSON to a URL: Argon's own payload, or a
+// Slack incoming-webhook message. Network errors, 429 and 5xx responses
+// are retried with exponential backoff until the context is done; other
+// responses are final.
+type WebhookSink struct {
+	URL         string
+	Slack       bool
+	Client      *http.Client
+	MaxAttempts int           // default 4
+	Backoff     time.Duration // first retry delay, doubling; default 500ms
+}
+
+// NewWebhookSink returns a sink posting Argon's JSON alert payload.
+func NewWebhookSink(url string) *WebhookSink {
+	return &WebhookSink{URL: url}
+}
+
+// NewSlackSink returns a sink posting to a Slack incoming webhook.
+func NewSlackSink(url string) *WebhookSink {
+	return &WebhookSink{URL: url, Slack: true}
+}
+
+// WebhookPayload is the JSON body a non-Slack WebhookSink posts.
+type WebhookPayload struct {
+	Level      AlertLevel             `json:"level"`
+	Title      string                 `json:"title"`
+	Message    string                 `json:"message"`
+	Timestamp  time.Time              `json:"timestamp"`
+	Data       map[string]interface{} `json:"data,omitempty"`
+	Resolved   bool                   `json:"resolved"`
+	ResolvedAt *time.Time             `json:"resolved_at,omitempty"`
+}
+
+// Deliver implements AlertSink.
+func (w *WebhookSink) Deliver(ctx context.Context, alert Alert) error {
+	body, err := json.Marshal(w.payload(alert))
+	if err != nil {
+		return fmt.Errorf("failed to encode alert: %w", err)
+	}
+	client := w.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	attempts := w.MaxAttempts
+	if attempts <= 0 {
+		attempts = 4
+	}
+	backoff := w.Backoff
+	if backoff <= 0 {
+		backoff = 500 * time.Millisecond
+	}
+
+	for attempt := 1; ; attempt++ {
+		retry, err := w.post(ctx, client, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= attempts {
+			return fmt.Errorf("webhook delivery failed after %d attempt(s): %w", attempt, err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// post makes one delivery attempt and reports whether a failure is worth
+// retrying.
+func (w *WebhookSink) post(ctx context.Context, client *http.Client, body []byte) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	switch {
+	case resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("webhook returned %s", resp.Status)
+	default:
+		return false, fmt.Errorf("webhook returned %s", resp.Status)
+	}
+}
+
+func (w *WebhookSink) payload(alert Alert) interface{} {
+	if w.Slack {
+		text := fmt.Sprintf(":rotating_light: *[%s] %s*\n%s", alert.Level, alert.Title, alert.Message)
+		if alert.Resolved {
+			text = fmt.Sprintf(":white_check_mark: *Resolved: %s*\n%s", alert.Title, alert.Message)
+		}
+		return map[string]string{"text": text}
+	}
+	p := WebhookPayload{
+		Level:     alert.Level,
+		Title:     alert.Title,
+		Message:   alert.Message,
+		Timestamp: alert.Timestamp,
+		Data:      alert.Data,
+		Resolved:  alert.Resolved,
+	}
+	if alert.Resolved {
+		at := alert.ResolvedAt
+		p.ResolvedAt = &at
+	}
+	return p
+}
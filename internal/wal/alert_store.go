@@ -0,0 +1,82 @@
+package wal
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AlertStore persists monitor alerts in the alerts collection, so alert
+// history survives restarts. The monitor writes to it from a queue of its
+// own, in the order alerts were raised and resolved; it also satisfies
+// AlertSink.
+type AlertStore struct {
+	collection *mongo.Collection
+}
+
+// NewAlertStore creates the alert store and its indexes.
+func NewAlertStore(db *mongo.Database) (*AlertStore, error) {
+	s := &AlertStore{collection: db.Collection("alerts")}
+	_, err := s.collection.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "timestamp", Value: -1}}},
+		{Keys: bson.D{{Key: "resolved", Value: 1}, {Key: "title", Value: 1}}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create alert indexes: %w", err)
+	}
+	return s, nil
+}
+
+// Deliver records a new alert, or marks the newest active alert with the
+// same title resolved.
+func (s *AlertStore) Deliver(ctx context.Context, alert Alert) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if !alert.Resolved {
+		_, err := s.collection.InsertOne(ctx, alert)
+		return err
+	}
+	err := s.collection.FindOneAndUpdate(ctx,
+		bson.M{"title": alert.Title, "resolved": false},
+		bson.M{"$set": bson.M{"resolved": true, "resolved_at": alert.ResolvedAt}},
+		options.FindOneAndUpdate().SetSort(bson.D{{Key: "timestamp", Value: -1}})).Err()
+	if err == mongo.ErrNoDocuments {
+		// Raised before the store was attached: nothing to resolve.
+		return nil
+	}
+	return err
+}
+
+// Active returns the unresolved alerts, oldest first.
+func (s *AlertStore) Active() ([]Alert, error) {
+	return s.find(bson.M{"resolved": false}, 1)
+}
+
+// History returns alerts raised at or after since, newest first; a
+// non-empty level keeps only that level.
+func (s *AlertStore) History(since time.Time, level AlertLevel) ([]Alert, error) {
+	filter := bson.M{"timestamp": bson.M{"$gte": since}}
+	if level != "" {
+		filter["level"] = level
+	}
+	return s.find(filter, -1)
+}
+
+func (s *AlertStore) find(filter bson.M, order int) ([]Alert, error) {
+	ctx := context.Background()
+	opts := options.Find().SetSort(bson.D{{Key: "timestamp", Value: order}})
+	cursor, err := s.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = cursor.Close(ctx) }()
+	alerts := make([]Alert, 0)
+	if err := cursor.All(ctx, &alerts); err != nil {
+		return nil, err
+	}
+	return alerts, nil
+}
@@ -2,7 +2,9 @@ package wal
 
 import (
 	"container/list"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -24,6 +26,11 @@ type Cache struct {
 	branchCache    map[string]*CachedBranch
 	branchCacheTTL time.Duration
 
+	// Counters, updated atomically
+	queryHits, queryMisses, queryExpired    int64
+	branchHits, branchMisses, branchExpired int64
+	recentHits, recentMisses                int64
+
 	// Coordination
 	mu            sync.RWMutex
 	cleanupTicker *time.Ticker
@@ -65,6 +72,9 @@ type LRUCache struct {
 	items    map[string]*list.Element
 	order    *list.List
 	mu       sync.RWMutex
+
+	// Counters, updated atomically
+	hits, misses int64
 }
 
 // LRUItem represents an item in the LRU cache
@@ -192,6 +202,7 @@ func (c *Cache) GetQueryResult(queryKey string) (interface{}, bool) {
 	c.mu.RUnlock()
 
 	if !exists {
+		atomic.AddInt64(&c.queryMisses, 1)
 		return nil, false
 	}
 
@@ -200,8 +211,11 @@ func (c *Cache) GetQueryResult(queryKey string) (interface{}, bool) {
 		c.mu.Lock()
 		delete(c.queryCache, queryKey)
 		c.mu.Unlock()
+		atomic.AddInt64(&c.queryMisses, 1)
+		atomic.AddInt64(&c.queryExpired, 1)
 		return nil, false
 	}
+	atomic.AddInt64(&c.queryHits, 1)
 
 	// Update access time and hit count
 	c.mu.Lock()
@@ -237,6 +251,7 @@ func (c *Cache) GetBranchMetadata(branchID string) (interface{}, bool) {
 	c.mu.RUnlock()
 
 	if !exists {
+		atomic.AddInt64(&c.branchMisses, 1)
 		return nil, false
 	}
 
@@ -245,8 +260,11 @@ func (c *Cache) GetBranchMetadata(branchID string) (interface{}, bool) {
 		c.mu.Lock()
 		delete(c.branchCache, branchID)
 		c.mu.Unlock()
+		atomic.AddInt64(&c.branchMisses, 1)
+		atomic.AddInt64(&c.branchExpired, 1)
 		return nil, false
 	}
+	atomic.AddInt64(&c.branchHits, 1)
 
 	// Update access time
 	c.mu.Lock()
@@ -310,8 +328,10 @@ func (c *Cache) GetRecentEntry(lsn int64) (interface{}, bool) {
 	c.mu.RUnlock()
 
 	if !exists {
+		atomic.AddInt64(&c.recentMisses, 1)
 		return nil, false
 	}
+	atomic.AddInt64(&c.recentHits, 1)
 
 	data := elem.Value.(map[string]interface{})
 	return data["entry"], true
@@ -319,7 +339,7 @@ func (c *Cache) GetRecentEntry(lsn int64) (interface{}, bool) {
 
 // InvalidateState removes cached states for a collection
 func (c *Cache) InvalidateState(collection string) {
-	c.stateCache.InvalidateByPrefix(collection + ":")
+	c.stateCache.InvalidateByPrefix(collection + stateKeySeparator)
 }
 
 // InvalidateBranch removes cached data for a branch
@@ -332,9 +352,11 @@ func (c *Cache) InvalidateBranch(branchID string) {
 // GetStats returns cache performance statistics
 func (c *Cache) GetStats() CacheStats {
 	return CacheStats{
-		StateCache:  c.stateCache.GetStats(),
-		QueryCache:  c.getQueryCacheStats(),
-		BranchCache: c.getBranchCacheStats(),
+		StateCache:   c.stateCache.GetStats(),
+		QueryCache:   c.getQueryCacheStats(),
+		BranchCache:  c.getBranchCacheStats(),
+		RecentHits:   atomic.LoadInt64(&c.recentHits),
+		RecentMisses: atomic.LoadInt64(&c.recentMisses),
 	}
 }
 
@@ -363,8 +385,10 @@ func (lru *LRUCache) Get(key string) (map[string]interface{}, bool) {
 
 	elem, exists := lru.items[key]
 	if !exists {
+		atomic.AddInt64(&lru.misses, 1)
 		return nil, false
 	}
+	atomic.AddInt64(&lru.hits, 1)
 
 	// Move to front (most recently used)
 	lru.order.MoveToFront(elem)
@@ -438,6 +462,8 @@ func (lru *LRUCache) GetStats() LRUStats {
 		Size:     lru.size,
 		Capacity: lru.capacity,
 		Items:    len(lru.items),
+		Hits:     atomic.LoadInt64(&lru.hits),
+		Misses:   atomic.LoadInt64(&lru.misses),
 	}
 }
 
@@ -451,9 +477,14 @@ func (lru *LRUCache) evictOldest() {
 	}
 }
 
+// stateKeySeparator splits a state key's collection from its LSN. MongoDB
+// collection names cannot contain a NUL, so a collection's key prefix never
+// matches another collection whose name merely starts with it.
+const stateKeySeparator = "\x00"
+
 // Helper methods
 func (c *Cache) stateKey(collection string, lsn int64) string {
-	return collection + ":" + string(rune(lsn))
+	return collection + stateKeySeparator + strconv.FormatInt(lsn, 10)
 }
 
 func (c *Cache) estimateStateSize(state map[string]interface{}) int64 {
@@ -482,6 +513,7 @@ func (c *Cache) cleanup() {
 	for key, cached := range c.queryCache {
 		if now.After(cached.ExpiresAt) {
 			delete(c.queryCache, key)
+			atomic.AddInt64(&c.queryExpired, 1)
 		}
 	}
 
@@ -489,6 +521,7 @@ func (c *Cache) cleanup() {
 	for key, cached := range c.branchCache {
 		if now.After(cached.ExpiresAt) {
 			delete(c.branchCache, key)
+			atomic.AddInt64(&c.branchExpired, 1)
 		}
 	}
 }
@@ -498,7 +531,10 @@ func (c *Cache) getQueryCacheStats() QueryCacheStats {
 	defer c.mu.RUnlock()
 
 	return QueryCacheStats{
-		Items: len(c.queryCache),
+		Items:   len(c.queryCache),
+		Hits:    atomic.LoadInt64(&c.queryHits),
+		Misses:  atomic.LoadInt64(&c.queryMisses),
+		Expired: atomic.LoadInt64(&c.queryExpired),
 	}
 }
 
@@ -507,6 +543,9 @@ func (c *Cache) getBranchCacheStats() BranchCacheStats {
 	defer c.mu.RUnlock()
 
 	return BranchCacheStats{
-		Items: len(c.branchCache),
+		Items:   len(c.branchCache),
+		Hits:    atomic.LoadInt64(&c.branchHits),
+		Misses:  atomic.LoadInt64(&c.branchMisses),
+		Expired: atomic.LoadInt64(&c.branchExpired),
 	}
 }
@@ -6,6 +6,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
+	"strings"
 
 	"github.com/klauspost/compress/zstd"
 	"github.com/klauspost/compress/snappy"
@@ -35,6 +36,24 @@ type CompressionConfig struct {
 	Level int
 }
 
+// compressionNames maps WAL_COMPRESSION values to compression types.
+var compressionNames = map[string]CompressionType{
+	"none":   CompressionNone,
+	"gzip":   CompressionGzip,
+	"zstd":   CompressionZstd,
+	"snappy": CompressionSnappy,
+}
+
+// ParseCompressionType reads a compression name: none, gzip, zstd or
+// snappy.
+func ParseCompressionType(name string) (CompressionType, error) {
+	t, ok := compressionNames[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return 0, fmt.Errorf("unknown compression %q (expected none, gzip, zstd or snappy)", name)
+	}
+	return t, nil
+}
+
 // DefaultCompressionConfig returns the default compression configuration
 func DefaultCompressionConfig() *CompressionConfig {
 	return &CompressionConfig{
@@ -61,7 +80,7 @@ func NewCompressor(config *CompressionConfig) (*Compressor, error) {
 		config: config,
 	}
 
-	// Initialize zstd encoder/decoder if needed
+	// Initialize the zstd encoder if needed
 	if config.Type == CompressionZstd {
 		encoder, err := zstd.NewWriter(nil, 
 			zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(config.Level)))
@@ -69,13 +88,16 @@ func NewCompressor(config *CompressionConfig) (*Compressor, error) {
 			return nil, fmt.Errorf("failed to create zstd encoder: %w", err)
 		}
 		c.zstdWriter = encoder
+	}
 
-		decoder, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(0))
-		if err != nil {
-			return nil, fmt.Errorf("failed to create zstd decoder: %w", err)
-		}
-		c.zstdReader = decoder
+	// The decoder is always needed: the type byte of each stored value,
+	// not the configured type, decides how it is read, so entries written
+	// under another setting still decompress.
+	decoder, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(0))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create zstd decoder: %w", err)
 	}
+	c.zstdReader = decoder
 
 	return c, nil
 }
@@ -291,5 +313,8 @@ func (c *Compressor) Close() error {
 	if c.zstdWriter != nil {
 		_ = c.zstdWriter.Close()
 	}
+	if c.zstdReader != nil {
+		c.zstdReader.Close()
+	}
 	return nil
 }
\ No newline at end of file
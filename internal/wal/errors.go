@@ -12,6 +12,7 @@ var (
 	ErrLSNOutOfRange    = errors.New("LSN out of range")
 	ErrInvalidOperation = errors.New("invalid operation")
 	ErrInvalidEntry     = errors.New("invalid WAL entry")
+	ErrEntryCorrupt     = errors.New("WAL entry is corrupt")
 
 	// Connection and database errors
 	ErrDatabaseConnection  = errors.New("database connection failed")
@@ -24,6 +25,12 @@ var (
 	ErrBranchExists       = errors.New("branch already exists")
 	ErrProjectExists      = errors.New("project already exists")
 	ErrInvalidBranchState = errors.New("invalid branch state")
+	ErrMainBranch         = errors.New("cannot delete main branch")
+	ErrMainBranchRename   = errors.New("cannot rename to or from main branch")
+	ErrBranchHasChildren  = errors.New("cannot delete branch with active children")
+	ErrBranchProtected    = errors.New("branch is protected")
+	ErrBranchCheckedOut   = errors.New("branch is checked out")
+	ErrBranchCycle        = errors.New("branch ancestry has a cycle")
 
 	// Time travel errors
 	ErrTimeTravelFailed      = errors.New("time travel operation failed")
@@ -32,9 +32,51 @@ type Metrics struct {
 	ActiveProjects    int       `json:"active_projects"`
 	LastOperationTime time.Time `json:"last_operation_time"`
 
+	// Image bytes appended: as written by clients and as stored after
+	// compression, including each image's 5-byte header.
+	ImageBytesRaw    int64 `json:"image_bytes_raw"`
+	ImageBytesStored int64 `json:"image_bytes_stored"`
+
 	// Internal tracking
 	latencyTracker *LatencyTracker
 	mu             sync.RWMutex
+
+	// Per-project capacity tracking, guarded by mu.
+	projectBranches map[string]int
+	projectGrowth   map[string]*entryGrowth
+}
+
+// entryRateWindow is the span, in one-second buckets, over which appended
+// entries are counted for the per-minute growth rate.
+const entryRateWindow = 60
+
+// entryGrowth tracks one project's appended WAL entries: a running total
+// and a ring of per-second buckets covering the last minute.
+type entryGrowth struct {
+	total   int64
+	buckets [entryRateWindow]struct{ second, count int64 }
+}
+
+func (g *entryGrowth) record(now time.Time, n int64) {
+	sec := now.Unix()
+	b := &g.buckets[sec%entryRateWindow]
+	if b.second != sec {
+		b.second, b.count = sec, 0
+	}
+	b.count += n
+	g.total += n
+}
+
+// lastMinute sums the buckets still inside the window.
+func (g *entryGrowth) lastMinute(now time.Time) int64 {
+	sec := now.Unix()
+	var sum int64
+	for _, b := range g.buckets {
+		if sec-b.second < entryRateWindow {
+			sum += b.count
+		}
+	}
+	return sum
 }
 
 // LatencyTracker tracks operation latencies with moving averages
@@ -55,6 +97,8 @@ func NewMetrics() *Metrics {
 			materialSamples: make([]time.Duration, 0, 100),
 			maxSamples:      100,
 		},
+		projectBranches: make(map[string]int),
+		projectGrowth:   make(map[string]*entryGrowth),
 	}
 }
 
@@ -130,6 +174,34 @@ func (m *Metrics) UpdateActiveProjects(count int) {
 	m.mu.Unlock()
 }
 
+// SetProjectBranches sets the active (non-deleted) branch count gauge for
+// a project.
+func (m *Metrics) SetProjectBranches(projectID string, count int) {
+	m.mu.Lock()
+	m.projectBranches[projectID] = count
+	m.mu.Unlock()
+}
+
+// RecordEntriesAppended counts n entries appended to a project's WAL,
+// feeding its total and its entries-per-minute growth rate.
+func (m *Metrics) RecordEntriesAppended(projectID string, n int) {
+	m.mu.Lock()
+	g := m.projectGrowth[projectID]
+	if g == nil {
+		g = &entryGrowth{}
+		m.projectGrowth[projectID] = g
+	}
+	g.record(time.Now(), int64(n))
+	m.mu.Unlock()
+}
+
+// RecordImageBytes counts the images of an appended entry: raw bytes in,
+// stored bytes out.
+func (m *Metrics) RecordImageBytes(raw, stored int) {
+	atomic.AddInt64(&m.ImageBytesRaw, int64(raw))
+	atomic.AddInt64(&m.ImageBytesStored, int64(stored))
+}
+
 // MetricsSnapshot represents a read-only snapshot of metrics without mutexes
 type MetricsSnapshot struct {
 	// Operation counters
@@ -155,6 +227,19 @@ type MetricsSnapshot struct {
 	ActiveBranches    int       `json:"active_branches"`
 	ActiveProjects    int       `json:"active_projects"`
 	LastOperationTime time.Time `json:"last_operation_time"`
+
+	// Compression of appended images. CompressionRatio is raw over stored
+	// bytes (2.5 means images take 40% of their raw size); 0 until an
+	// image has been appended.
+	ImageBytesRaw    int64   `json:"image_bytes_raw"`
+	ImageBytesStored int64   `json:"image_bytes_stored"`
+	CompressionRatio float64 `json:"compression_ratio"`
+
+	// Per-project capacity: active branches, entries appended since the
+	// process started, and entries appended over the last minute.
+	ProjectBranches         map[string]int   `json:"project_branches"`
+	ProjectEntriesAppended  map[string]int64 `json:"project_entries_appended"`
+	ProjectEntriesPerMinute map[string]int64 `json:"project_entries_per_minute"`
 }
 
 // GetSnapshot returns a read-only snapshot of current metrics
@@ -179,6 +264,23 @@ func (m *Metrics) GetSnapshot() MetricsSnapshot {
 		ActiveBranches:     m.ActiveBranches,
 		ActiveProjects:     m.ActiveProjects,
 		LastOperationTime:  m.LastOperationTime,
+		ImageBytesRaw:      atomic.LoadInt64(&m.ImageBytesRaw),
+		ImageBytesStored:   atomic.LoadInt64(&m.ImageBytesStored),
+
+		ProjectBranches:         make(map[string]int, len(m.projectBranches)),
+		ProjectEntriesAppended:  make(map[string]int64, len(m.projectGrowth)),
+		ProjectEntriesPerMinute: make(map[string]int64, len(m.projectGrowth)),
+	}
+	if snapshot.ImageBytesStored > 0 {
+		snapshot.CompressionRatio = float64(snapshot.ImageBytesRaw) / float64(snapshot.ImageBytesStored)
+	}
+	for projectID, count := range m.projectBranches {
+		snapshot.ProjectBranches[projectID] = count
+	}
+	now := time.Now()
+	for projectID, g := range m.projectGrowth {
+		snapshot.ProjectEntriesAppended[projectID] = g.total
+		snapshot.ProjectEntriesPerMinute[projectID] = g.lastMinute(now)
 	}
 
 	return snapshot
@@ -221,11 +323,15 @@ func (m *Metrics) Reset() {
 	atomic.StoreInt64(&m.MaterialErrors, 0)
 	atomic.StoreInt64(&m.ConnectionErrors, 0)
 	atomic.StoreInt64(&m.CurrentLSN, 0)
+	atomic.StoreInt64(&m.ImageBytesRaw, 0)
+	atomic.StoreInt64(&m.ImageBytesStored, 0)
 
 	m.mu.Lock()
 	m.ActiveBranches = 0
 	m.ActiveProjects = 0
 	m.LastOperationTime = time.Time{}
+	m.projectBranches = make(map[string]int)
+	m.projectGrowth = make(map[string]*entryGrowth)
 	m.AvgAppendLatency = 0
 	m.AvgQueryLatency = 0
 	m.AvgMaterialLatency = 0
@@ -26,10 +26,17 @@ const (
 	OpDeleteBranch  OperationType = "delete_branch"
 	OpCreateProject OperationType = "create_project"
 	OpDeleteProject OperationType = "delete_project"
+	// OpRenameBranch records a branch rename, the old and new names in
+	// Metadata["old_name"] and Metadata["new_name"].
+	OpRenameBranch OperationType = "rename_branch"
 	// OpMerge records that another branch's changes were merged in; the
 	// data itself arrives as ordinary puts/deletes, this entry is the
 	// audit marker carrying the plan metadata.
 	OpMerge OperationType = "merge"
+	// OpCreateIndex records a secondary index of a collection, its
+	// definition (as listIndexes reports it) in Metadata["index"].
+	// Replay ignores it; exports to real databases recreate the index.
+	OpCreateIndex OperationType = "create_index"
 )
 
 // Legacy schema-v1 data operations. v1 update/delete entries stored the
@@ -74,10 +81,29 @@ type Entry struct {
 
 	// TxnID groups entries that must become visible atomically.
 	TxnID string `bson:"txn_id,omitempty" json:"txn_id,omitempty"`
+	// TxnLastLSN is set on the entries of a transaction appended as one
+	// batch: the LSN of its last entry there. Reads bounded below it skip
+	// the entry, so no LSN shows the transaction half-applied.
+	TxnLastLSN int64 `bson:"txn_last,omitempty" json:"txn_last,omitempty"`
 	// Actor identifies who produced the write, e.g. "user:jake" or
 	// "agent:session-42". Powers per-session undo and audit trails.
 	Actor string `bson:"actor,omitempty" json:"actor,omitempty"`
 
+	// SupersededBy is the LSN of a compaction entry on the same branch that
+	// carries this entry's effect. Readers whose bound reaches that LSN
+	// skip this entry; readers below it (time travel, children forked
+	// earlier) still replay it. Zero means not superseded.
+	SupersededBy int64 `bson:"superseded_by,omitempty" json:"superseded_by,omitempty"`
+
+	// Pending marks an entry appended but not yet confirmed durable by its
+	// writer (see AppendPending/ConfirmEntry). Default reads include it;
+	// committed-only materialization skips it. Absent means committed.
+	Pending bool `bson:"pending,omitempty" json:"pending,omitempty"`
+
+	// Checksum is the CRC32C of the entry as stored, set on append and
+	// verified on every read. Entries from earlier releases have none.
+	Checksum uint32 `bson:"checksum,omitempty" json:"checksum,omitempty"`
+
 	Metadata map[string]interface{} `bson:"metadata,omitempty" json:"metadata,omitempty"`
 }
 
@@ -115,8 +141,12 @@ func (e *Entry) ValidateForAppend() error {
 		if e.BranchID == "" || e.Collection == "" || e.DocumentID == "" {
 			return fmt.Errorf("delete entry requires branch, collection and document ID")
 		}
-	case OpCreateBranch, OpDeleteBranch, OpCreateProject, OpDeleteProject, OpMerge:
+	case OpCreateBranch, OpDeleteBranch, OpRenameBranch, OpCreateProject, OpDeleteProject, OpMerge:
 		// Control entries carry their payload in Metadata.
+	case OpCreateIndex:
+		if e.BranchID == "" || e.Collection == "" || e.Metadata["index"] == nil {
+			return fmt.Errorf("create_index entry requires branch, collection and index definition")
+		}
 	case LegacyOpInsert, LegacyOpUpdate:
 		return fmt.Errorf("operation %q is a legacy schema-v1 operation and can no longer be written", e.Operation)
 	default:
@@ -170,6 +200,16 @@ type Branch struct {
 	// sweep releases and deletes it (storage reclaimed through the delete
 	// hook). Merge or discard it before then — or extend it.
 	ExpiresAt *time.Time `bson:"expires_at,omitempty" json:"expires_at,omitempty"`
+
+	// LastActivityAt is the timestamp of the entry the head last advanced
+	// to, maintained by UpdateBranchHead so activity views need not scan
+	// entries. Nil until the branch's first write.
+	LastActivityAt *time.Time `bson:"last_activity_at,omitempty" json:"last_activity_at,omitempty"`
+
+	// Protected makes a branch read-only to Argon's write paths: the SDK
+	// interceptor, merges, undo and resets (unless forced) refuse it. A
+	// checked-out branch's physical database is not guarded.
+	Protected bool `bson:"protected,omitempty" json:"protected,omitempty"`
 }
 
 // IsExpired reports whether a sandbox branch has passed its TTL.
@@ -180,6 +220,10 @@ func (b *Branch) IsExpired(now time.Time) bool {
 // BranchStateLive marks a branch as checked out into a physical database.
 const BranchStateLive = "live"
 
+// PhysicalDBPrefix starts the name of every database a branch is checked
+// out into, keeping Argon-owned databases recognizable.
+const PhysicalDBPrefix = "argon_br_"
+
 // IsLive reports whether the branch is checked out into a physical
 // database (writes flow through mongod and the change-stream ingester, not
 // the SDK interceptor).
@@ -218,6 +262,35 @@ func (e *Entry) IsData() bool {
 	}
 }
 
+// IndexSpec returns the index definition an OpCreateIndex entry records.
+func (e *Entry) IndexSpec() (bson.D, error) {
+	if e.Operation != OpCreateIndex || e.Metadata["index"] == nil {
+		return nil, fmt.Errorf("entry LSN %d records no index", e.LSN)
+	}
+	raw, err := bson.Marshal(bson.M{"index": e.Metadata["index"]})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode index of entry LSN %d: %w", e.LSN, err)
+	}
+	var decoded struct {
+		Index bson.D `bson:"index"`
+	}
+	if err := bson.Unmarshal(raw, &decoded); err != nil {
+		return nil, fmt.Errorf("failed to decode index of entry LSN %d: %w", e.LSN, err)
+	}
+	return decoded.Index, nil
+}
+
+// IndexName returns the name field of an index definition.
+func IndexName(spec bson.D) string {
+	for _, e := range spec {
+		if e.Key == "name" {
+			name, _ := e.Value.(string)
+			return name
+		}
+	}
+	return ""
+}
+
 // Project represents a WAL-enabled project
 type Project struct {
 	ID           string    `bson:"_id" json:"id"`
@@ -225,4 +298,35 @@ type Project struct {
 	MainBranchID string    `bson:"main_branch_id" json:"main_branch_id"`
 	CreatedAt    time.Time `bson:"created_at" json:"created_at"`
 	UseWAL       bool      `bson:"use_wal" json:"use_wal"`
+
+	// Capture selects which collections are versioned.
+	Capture CaptureConfig `bson:"capture,omitempty" json:"capture,omitempty"`
+}
+
+// CaptureConfig is a project's per-collection WAL capture policy. Deny
+// wins over Allow; a non-empty Allow captures only the collections it
+// lists. The zero value captures everything. Uncaptured collections (an
+// ephemeral cache, say) live only in checked-out physical databases: the
+// ingester passes their writes by, and programmatic writers refuse them.
+type CaptureConfig struct {
+	Allow []string `bson:"allow,omitempty" json:"allow,omitempty"`
+	Deny  []string `bson:"deny,omitempty" json:"deny,omitempty"`
+}
+
+// Captures reports whether writes to the collection are versioned.
+func (c CaptureConfig) Captures(collection string) bool {
+	for _, name := range c.Deny {
+		if name == collection {
+			return false
+		}
+	}
+	if len(c.Allow) == 0 {
+		return true
+	}
+	for _, name := range c.Allow {
+		if name == collection {
+			return true
+		}
+	}
+	return false
 }
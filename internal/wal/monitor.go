@@ -2,8 +2,10 @@ package wal
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"runtime"
 	"sync"
 	"time"
 )
@@ -15,6 +17,21 @@ type Monitor struct {
 	alerts       []Alert
 	config       MonitorConfig
 
+	// ping probes the database for the connectivity check; nil skips it.
+	ping func(ctx context.Context) error
+	// heapSamples are the latest HeapAlloc readings, oldest first, for
+	// leak detection.
+	heapSamples []uint64
+	// deliveries queues alerts for the configured sinks.
+	deliveries chan Alert
+	// store persists alerts across restarts; nil keeps them in memory only.
+	// Alerts and resolutions reach it through persistQueue, in order and
+	// never dropped, unlike sink deliveries; persistReady wakes the loop
+	// that drains it.
+	store        *AlertStore
+	persistQueue []Alert
+	persistReady chan struct{}
+
 	// State tracking
 	isHealthy        bool
 	lastCheck        time.Time
@@ -34,6 +51,11 @@ type MonitorConfig struct {
 	AlertThresholds       AlertThresholds
 	EnableLogging         bool
 	EnableMetricsExport   bool
+
+	// AlertSinks receive every new alert and every resolution, from a
+	// bounded queue drained in the background: a slow or failing sink
+	// never delays health checks, and alerts beyond the queue are dropped.
+	AlertSinks []AlertSink
 }
 
 // AlertThresholds defines when to trigger alerts
@@ -42,6 +64,12 @@ type AlertThresholds struct {
 	MaxLatency             time.Duration // Maximum acceptable latency
 	MaxConsecutiveFailures int           // Maximum consecutive health check failures
 	MinSuccessRate         float64       // Minimum success rate (0.0-1.0)
+
+	// Memory: the heap ceiling (0 disables it) and the number of
+	// consecutive checks over which a strictly growing heap is reported
+	// as a possible leak (0 defaults to 10; negative disables it).
+	MaxHeapBytes     uint64
+	HeapGrowthWindow int
 }
 
 // HealthCheck defines a health check function
@@ -56,13 +84,13 @@ type HealthCheck struct {
 
 // Alert represents a system alert
 type Alert struct {
-	Level      AlertLevel
-	Title      string
-	Message    string
-	Timestamp  time.Time
-	Data       map[string]interface{}
-	Resolved   bool
-	ResolvedAt time.Time
+	Level      AlertLevel             `bson:"level" json:"level"`
+	Title      string                 `bson:"title" json:"title"`
+	Message    string                 `bson:"message" json:"message"`
+	Timestamp  time.Time              `bson:"timestamp" json:"timestamp"`
+	Data       map[string]interface{} `bson:"data,omitempty" json:"data,omitempty"`
+	Resolved   bool                   `bson:"resolved" json:"resolved"`
+	ResolvedAt time.Time              `bson:"resolved_at,omitempty" json:"resolved_at,omitempty"`
 }
 
 // AlertLevel defines alert severity
@@ -99,6 +127,9 @@ func NewMonitor(metrics *Metrics, config MonitorConfig) *Monitor {
 	if config.AlertThresholds.MinSuccessRate == 0 {
 		config.AlertThresholds.MinSuccessRate = 0.95 // 95% success rate
 	}
+	if config.AlertThresholds.HeapGrowthWindow == 0 {
+		config.AlertThresholds.HeapGrowthWindow = 10
+	}
 
 	monitor := &Monitor{
 		metrics:      metrics,
@@ -106,6 +137,8 @@ func NewMonitor(metrics *Metrics, config MonitorConfig) *Monitor {
 		alerts:       make([]Alert, 0),
 		config:       config,
 		isHealthy:    true,
+		deliveries:   make(chan Alert, alertQueueSize),
+		persistReady: make(chan struct{}, 1),
 		ctx:          ctx,
 		cancel:       cancel,
 	}
@@ -118,10 +151,12 @@ func NewMonitor(metrics *Metrics, config MonitorConfig) *Monitor {
 
 // Start begins monitoring
 func (m *Monitor) Start() {
-	m.wg.Add(2)
+	m.wg.Add(4)
 
 	go m.healthCheckLoop()
 	go m.metricsReportLoop()
+	go m.deliveryLoop()
+	go m.persistLoop()
 
 	if m.config.EnableLogging {
 		log.Println("WAL Monitor: Started health monitoring")
@@ -164,12 +199,13 @@ func (m *Monitor) GetHealthStatus() map[string]interface{} {
 	successRates := m.metrics.GetSuccessRate()
 
 	status["metrics"] = map[string]interface{}{
-		"total_operations": snapshot.AppendOps + snapshot.QueryOps + snapshot.MaterialOps,
-		"success_rates":    successRates,
-		"current_lsn":      snapshot.CurrentLSN,
-		"active_branches":  snapshot.ActiveBranches,
-		"active_projects":  snapshot.ActiveProjects,
-		"last_operation":   snapshot.LastOperationTime,
+		"total_operations":  snapshot.AppendOps + snapshot.QueryOps + snapshot.MaterialOps,
+		"success_rates":     successRates,
+		"current_lsn":       snapshot.CurrentLSN,
+		"active_branches":   snapshot.ActiveBranches,
+		"active_projects":   snapshot.ActiveProjects,
+		"last_operation":    snapshot.LastOperationTime,
+		"compression_ratio": snapshot.CompressionRatio,
 	}
 
 	return status
@@ -190,11 +226,71 @@ func (m *Monitor) AddHealthCheck(check HealthCheck) {
 	m.healthChecks = append(m.healthChecks, check)
 }
 
+// SetAlertStore persists alerts through store and reloads the alerts it
+// still holds as active, so a restarted process can resolve what its
+// predecessor raised. Every alert and resolution is persisted, in order,
+// however far the store falls behind; Stop waits for the backlog. Call it
+// before Start.
+func (m *Monitor) SetAlertStore(store *AlertStore) error {
+	active, err := store.Active()
+	if err != nil {
+		return fmt.Errorf("failed to load active alerts: %w", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.store = store
+	m.alerts = append(active, m.alerts...)
+	return nil
+}
+
+// GetAlertHistory returns alerts raised at or after since, newest first;
+// a non-empty level keeps only that level. With an alert store the
+// history spans restarts, otherwise it is this process's.
+func (m *Monitor) GetAlertHistory(since time.Time, level AlertLevel) ([]Alert, error) {
+	m.mu.RLock()
+	store := m.store
+	if store != nil {
+		// The query runs unlocked: it must not hold up raising alerts.
+		m.mu.RUnlock()
+		return store.History(since, level)
+	}
+	defer m.mu.RUnlock()
+	history := make([]Alert, 0)
+	for i := len(m.alerts) - 1; i >= 0; i-- {
+		a := m.alerts[i]
+		if !a.Timestamp.Before(since) && (level == "" || a.Level == level) {
+			history = append(history, a)
+		}
+	}
+	return history, nil
+}
+
+// AddAlertSink registers a sink in addition to MonitorConfig.AlertSinks.
+func (m *Monitor) AddAlertSink(sink AlertSink) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.config.AlertSinks = append(m.config.AlertSinks, sink)
+}
+
+// SetPinger wires in the database probe behind the critical connectivity
+// check, typically the client's Ping. Without one the check cannot tell
+// and passes.
+func (m *Monitor) SetPinger(ping func(ctx context.Context) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ping = ping
+}
+
 // TriggerAlert creates a new alert
 func (m *Monitor) TriggerAlert(level AlertLevel, title, message string, data map[string]interface{}) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	m.triggerAlert(level, title, message, data)
+}
 
+// triggerAlert is TriggerAlert for callers holding m.mu.
+func (m *Monitor) triggerAlert(level AlertLevel, title, message string, data map[string]interface{}) {
 	alert := Alert{
 		Level:     level,
 		Title:     title,
@@ -204,7 +300,7 @@ func (m *Monitor) TriggerAlert(level AlertLevel, title, message string, data map
 		Resolved:  false,
 	}
 
-	m.alerts = append(m.alerts, alert)
+	m.raise(alert)
 
 	if m.config.EnableLogging {
 		log.Printf("WAL Monitor Alert [%s]: %s - %s", level, title, message)
@@ -215,7 +311,11 @@ func (m *Monitor) TriggerAlert(level AlertLevel, title, message string, data map
 func (m *Monitor) ResolveAlert(title string) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	m.resolveAlert(title)
+}
 
+// resolveAlert is ResolveAlert for callers holding m.mu.
+func (m *Monitor) resolveAlert(title string) {
 	for i := range m.alerts {
 		if m.alerts[i].Title == title && !m.alerts[i].Resolved {
 			m.alerts[i].Resolved = true
@@ -224,6 +324,8 @@ func (m *Monitor) ResolveAlert(title string) {
 			if m.config.EnableLogging {
 				log.Printf("WAL Monitor: Resolved alert '%s'", title)
 			}
+			m.persist(m.alerts[i])
+			m.deliver(m.alerts[i])
 			break
 		}
 	}
@@ -235,7 +337,7 @@ func (m *Monitor) addDefaultHealthChecks() {
 	m.healthChecks = append(m.healthChecks, HealthCheck{
 		Name:        "database_connectivity",
 		Description: "Verify MongoDB connection is active",
-		Check:       m.checkDatabaseConnectivity,
+		Check:       func() error { return m.checkDatabaseConnectivity(5 * time.Second) },
 		Interval:    30 * time.Second,
 		Timeout:     5 * time.Second,
 		Critical:    true,
@@ -297,14 +399,23 @@ func (m *Monitor) metricsReportLoop() {
 }
 
 func (m *Monitor) runHealthChecks() {
+	// Checks run without the lock: a slow ping must not block IsHealthy.
+	m.mu.RLock()
+	checks := append([]HealthCheck(nil), m.healthChecks...)
+	m.mu.RUnlock()
+	errs := make([]error, len(checks))
+	for i, check := range checks {
+		errs[i] = m.runSingleHealthCheck(check)
+	}
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	m.lastCheck = time.Now()
 	allHealthy := true
 
-	for _, check := range m.healthChecks {
-		err := m.runSingleHealthCheck(check)
+	for i, check := range checks {
+		err := errs[i]
 		if err != nil {
 			if check.Critical {
 				allHealthy = false
@@ -314,7 +425,7 @@ func (m *Monitor) runHealthChecks() {
 			m.triggerHealthCheckAlert(check, err)
 		} else {
 			// Resolve any existing alerts for this check
-			m.ResolveAlert(fmt.Sprintf("health_check_%s", check.Name))
+			m.resolveAlert(fmt.Sprintf("health_check_%s", check.Name))
 		}
 	}
 
@@ -322,13 +433,13 @@ func (m *Monitor) runHealthChecks() {
 		m.consecutiveFails = 0
 		if !m.isHealthy {
 			m.isHealthy = true
-			m.ResolveAlert("system_unhealthy")
+			m.resolveAlert("system_unhealthy")
 		}
 	} else {
 		m.consecutiveFails++
 		if m.consecutiveFails >= m.config.AlertThresholds.MaxConsecutiveFailures {
 			m.isHealthy = false
-			m.TriggerAlert(AlertLevelCritical, "system_unhealthy",
+			m.triggerAlert(AlertLevelCritical, "system_unhealthy",
 				fmt.Sprintf("System unhealthy after %d consecutive failures", m.consecutiveFails),
 				map[string]interface{}{
 					"consecutive_failures": m.consecutiveFails,
@@ -368,6 +479,12 @@ func (m *Monitor) triggerHealthCheckAlert(check HealthCheck, err error) {
 		"error":             err.Error(),
 		"critical":          check.Critical,
 	}
+	var detailed *checkError
+	if errors.As(err, &detailed) {
+		for k, v := range detailed.data {
+			data[k] = v
+		}
+	}
 
 	alert := Alert{
 		Level:     level,
@@ -378,7 +495,21 @@ func (m *Monitor) triggerHealthCheckAlert(check HealthCheck, err error) {
 		Resolved:  false,
 	}
 
+	m.raise(alert)
+}
+
+// raise records an alert and queues it for delivery, unless an alert with
+// the same title is still active: a condition that persists across checks
+// is reported once, not once per check. Callers hold m.mu.
+func (m *Monitor) raise(alert Alert) {
+	for _, a := range m.alerts {
+		if a.Title == alert.Title && !a.Resolved {
+			return
+		}
+	}
 	m.alerts = append(m.alerts, alert)
+	m.persist(alert)
+	m.deliver(alert)
 }
 
 func (m *Monitor) getActiveAlerts() []Alert {
@@ -405,9 +536,18 @@ func (m *Monitor) exportMetrics() {
 }
 
 // Health check implementations
-func (m *Monitor) checkDatabaseConnectivity() error {
-	// This would typically ping the database
-	// For now, we'll assume it's healthy
+func (m *Monitor) checkDatabaseConnectivity(timeout time.Duration) error {
+	m.mu.RLock()
+	ping := m.ping
+	m.mu.RUnlock()
+	if ping == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(m.ctx, timeout)
+	defer cancel()
+	if err := ping(ctx); err != nil {
+		return fmt.Errorf("database ping failed: %w", err)
+	}
 	return nil
 }
 
@@ -437,8 +577,57 @@ func (m *Monitor) checkPerformanceMetrics() error {
 	return nil
 }
 
+// checkError is a failed check's error with details for its alert's Data.
+type checkError struct {
+	msg  string
+	data map[string]interface{}
+}
+
+func (e *checkError) Error() string { return e.msg }
+
 func (m *Monitor) checkMemoryUsage() error {
-	// This would typically check memory usage
-	// For now, we'll assume it's healthy
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	heap := stats.HeapAlloc
+	limits := m.config.AlertThresholds
+
+	m.mu.Lock()
+	growing := false
+	if window := limits.HeapGrowthWindow; window > 1 {
+		m.heapSamples = append(m.heapSamples, heap)
+		if len(m.heapSamples) > window {
+			m.heapSamples = m.heapSamples[len(m.heapSamples)-window:]
+		}
+		growing = len(m.heapSamples) == window
+		for i := 1; growing && i < len(m.heapSamples); i++ {
+			growing = m.heapSamples[i] > m.heapSamples[i-1]
+		}
+	}
+	var first uint64
+	if len(m.heapSamples) > 0 {
+		first = m.heapSamples[0]
+	}
+	m.mu.Unlock()
+
+	data := map[string]interface{}{
+		"heap_alloc_bytes": heap,
+		"heap_sys_bytes":   stats.HeapSys,
+		"max_heap_bytes":   limits.MaxHeapBytes,
+	}
+	if limits.MaxHeapBytes > 0 && heap > limits.MaxHeapBytes {
+		return &checkError{
+			msg:  fmt.Sprintf("heap %d bytes exceeds ceiling %d bytes", heap, limits.MaxHeapBytes),
+			data: data,
+		}
+	}
+	if growing {
+		data["possible_leak"] = true
+		data["heap_growth_bytes"] = heap - first
+		return &checkError{
+			msg: fmt.Sprintf("heap grew on each of the last %d checks (%d to %d bytes): possible leak",
+				limits.HeapGrowthWindow, first, heap),
+			data: data,
+		}
+	}
 	return nil
 }
@@ -0,0 +1,216 @@
+package wal
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// pruneDeleteBatch bounds how many LSNs one prune delete names.
+const pruneDeleteBatch = 1000
+
+// PruneBlockedError is returned when a branch still reads history inside
+// the range PruneBefore was asked to drop.
+type PruneBlockedError struct {
+	BranchID   string
+	BranchName string
+	Reason     string
+}
+
+func (e *PruneBlockedError) Error() string {
+	return fmt.Sprintf("cannot prune: branch %s (%s) %s", e.BranchName, e.BranchID, e.Reason)
+}
+
+// SetBranchLister registers how PruneBefore lists a project's branches,
+// deleted ones included.
+func (s *Service) SetBranchLister(list func(projectID string) ([]*Branch, error)) {
+	s.listBranches = list
+}
+
+// SetPinLookup registers the pinned-LSN lookup PruneBefore uses to protect
+// pinned history.
+func (s *Service) SetPinLookup(lookup func(branchID string) ([]int64, error)) {
+	s.pinLSNs = lookup
+}
+
+// PruneBefore drops a project's history below lsn: every data entry below
+// it is deleted except, per branch and document, the newest visible one,
+// which still carries the document's state. Reads at lsn and above are
+// unchanged; time travel below lsn is given up. Control entries, pending
+// entries and legacy entries are kept, and so is a transaction that
+// straddles lsn, from its first entry on.
+//
+// That only holds if nothing reads at a bound inside the range, so it
+// refuses — with a *PruneBlockedError naming the branch — when a live
+// branch's head is below lsn, a live branch was forked below lsn, a pin
+// on a live branch is below lsn, or a reset window spans lsn. Returns the
+// number of entries deleted.
+func (s *Service) PruneBefore(projectID string, lsn int64) (int64, error) {
+	if s.listBranches == nil {
+		return 0, fmt.Errorf("cannot prune: no branch lister registered")
+	}
+	branches, err := s.listBranches(projectID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list branches: %w", err)
+	}
+	// A transaction straddling lsn is hidden from reads up to its end, so
+	// its early entries cannot stand in for the state before it: each
+	// branch prunes only below the first transaction reaching lsn.
+	bounds := make([]int64, len(branches))
+	for i, b := range branches {
+		safe, err := s.TxnSafeLSN(b.ID, lsn-1)
+		if err != nil {
+			return 0, fmt.Errorf("branch %s (%s): %w", b.Name, b.ID, err)
+		}
+		bounds[i] = safe + 1
+		if err := s.checkPruneDependency(b, bounds[i]); err != nil {
+			return 0, err
+		}
+	}
+
+	var removed int64
+	for i, b := range branches {
+		n, err := s.pruneBranch(b, bounds[i])
+		removed += n
+		if err != nil {
+			return removed, fmt.Errorf("branch %s (%s): %w", b.Name, b.ID, err)
+		}
+	}
+	return removed, nil
+}
+
+// PruneOlderThan is PruneBefore at the first LSN appended at or after
+// cutoff, so every entry older than cutoff is eligible.
+func (s *Service) PruneOlderThan(projectID string, cutoff time.Time) (int64, error) {
+	var entry struct {
+		LSN int64 `bson:"lsn"`
+	}
+	err := s.collection.FindOne(context.Background(),
+		bson.M{"project_id": projectID, "timestamp": bson.M{"$lt": cutoff}},
+		options.FindOne().SetSort(bson.M{"lsn": -1}).SetProjection(bson.M{"lsn": 1}),
+	).Decode(&entry)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to find the prune point: %w", err)
+	}
+	return s.PruneBefore(projectID, entry.LSN+1)
+}
+
+// checkPruneDependency refuses a live branch that reads at a bound below
+// lsn. Deleted branches read nothing themselves; live children reading
+// through them are checked on their own.
+func (s *Service) checkPruneDependency(b *Branch, lsn int64) error {
+	if b.IsDeleted {
+		return nil
+	}
+	blocked := func(format string, args ...interface{}) error {
+		return &PruneBlockedError{BranchID: b.ID, BranchName: b.Name, Reason: fmt.Sprintf(format, args...)}
+	}
+	if b.HeadLSN < lsn {
+		return blocked("has its head at LSN %d, below %d", b.HeadLSN, lsn)
+	}
+	if b.BaseLSN > 0 && b.BaseLSN < lsn {
+		return blocked("was created at LSN %d, below %d", b.BaseLSN, lsn)
+	}
+	for _, r := range b.DiscardedRanges {
+		// Which entries in such a window are visible depends on the
+		// reader's bound, so no single survivor per document is right.
+		if r.From < lsn && r.To >= lsn {
+			return blocked("has a reset window [%d, %d] spanning LSN %d", r.From, r.To, lsn)
+		}
+	}
+	if s.pinLSNs != nil {
+		pins, err := s.pinLSNs(b.ID)
+		if err != nil {
+			return fmt.Errorf("failed to look up pins on branch %s: %w", b.Name, err)
+		}
+		for _, p := range pins {
+			if p < lsn {
+				return blocked("is pinned at LSN %d, below %d", p, lsn)
+			}
+		}
+	}
+	return nil
+}
+
+// pruneBranch deletes a branch's data entries below lsn other than each
+// document's newest visible one. Entries in reset windows below lsn are
+// invisible to every remaining reader, so they never survive. A (deleted)
+// branch with a window spanning lsn is left whole.
+func (s *Service) pruneBranch(b *Branch, lsn int64) (int64, error) {
+	for _, r := range b.DiscardedRanges {
+		if r.From < lsn && r.To >= lsn {
+			return 0, nil
+		}
+	}
+	ctx := context.Background()
+	cursor, err := s.collection.Find(ctx,
+		bson.M{
+			"branch_id": b.ID,
+			"operation": bson.M{"$in": []OperationType{OpPut, OpDelete}},
+			"v":         bson.M{"$gte": EntrySchemaVersion},
+			"pending":   bson.M{"$ne": true},
+			"lsn":       bson.M{"$lt": lsn},
+		},
+		options.Find().
+			SetSort(bson.D{{Key: "collection", Value: 1}, {Key: "document_id", Value: 1}, {Key: "lsn", Value: 1}}).
+			SetProjection(bson.M{"collection": 1, "document_id": 1, "lsn": 1}),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to scan entries: %w", err)
+	}
+	defer func() { _ = cursor.Close(ctx) }()
+
+	var (
+		removed  int64
+		doomed   []int64
+		key      [2]string
+		survivor int64 // newest visible LSN of the current document
+	)
+	flush := func(force bool) error {
+		if len(doomed) == 0 || (!force && len(doomed) < pruneDeleteBatch) {
+			return nil
+		}
+		res, err := s.collection.DeleteMany(ctx, bson.M{"project_id": b.ProjectID, "lsn": bson.M{"$in": doomed}})
+		if err != nil {
+			return fmt.Errorf("failed to delete entries: %w", err)
+		}
+		removed += res.DeletedCount
+		doomed = doomed[:0]
+		return nil
+	}
+	for cursor.Next(ctx) {
+		var e struct {
+			Collection string `bson:"collection"`
+			DocumentID string `bson:"document_id"`
+			LSN        int64  `bson:"lsn"`
+		}
+		if err := cursor.Decode(&e); err != nil {
+			return removed, fmt.Errorf("failed to decode entry: %w", err)
+		}
+		if k := [2]string{e.Collection, e.DocumentID}; k != key {
+			key, survivor = k, 0
+		}
+		if b.IsDiscardedForRead(e.LSN, lsn) {
+			doomed = append(doomed, e.LSN)
+		} else {
+			if survivor != 0 {
+				doomed = append(doomed, survivor)
+			}
+			survivor = e.LSN
+		}
+		if err := flush(false); err != nil {
+			return removed, err
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return removed, fmt.Errorf("failed to scan entries: %w", err)
+	}
+	return removed, flush(true)
+}
@@ -2,12 +2,14 @@ package wal
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
 // Service manages WAL operations
@@ -17,6 +19,22 @@ type Service struct {
 	sequencer  *Sequencer
 	metrics    *Metrics
 	compressor *Compressor
+
+	// listBranches and pinLSNs back PruneBefore's dependency check. Wired
+	// by the caller, since the branch and pin packages depend on this one.
+	listBranches func(projectID string) ([]*Branch, error)
+	pinLSNs      func(branchID string) ([]int64, error)
+
+	// readPref is set on read views (see WithReadPreference); nil keeps
+	// the client's, which is the primary unless the URI says otherwise.
+	// anchor is the primary-read collection a view's reads are made
+	// causally consistent with (see consistentRead).
+	readPref *readpref.ReadPref
+	anchor   *mongo.Collection
+
+	// subscribers receive entries as they are appended (see Subscribe).
+	// Shared by read views.
+	subscribers *subscriberRegistry
 }
 
 // legacyIndexNames are indexes from earlier releases whose keys or options
@@ -39,6 +57,8 @@ func NewService(db *mongo.Database) (*Service, error) {
 		sequencer:  NewSequencer(db),
 		metrics:    GlobalMetrics,
 		compressor: compressor,
+
+		subscribers: newSubscriberRegistry(),
 	}
 
 	ctx := context.Background()
@@ -74,9 +94,28 @@ func NewService(db *mongo.Database) (*Service, error) {
 				{Key: "lsn", Value: 1},
 			},
 		},
+		{
+			// Per-actor audit reads ("everything user X did") span every
+			// branch of a project.
+			Keys: bson.D{
+				{Key: "project_id", Value: 1},
+				{Key: "actor", Value: 1},
+				{Key: "lsn", Value: 1},
+			},
+		},
 		{
 			Keys: bson.M{"timestamp": 1},
 		},
+		{
+			// Transaction boundaries for TxnSafeLSN; only transactional
+			// entries carry txn_last.
+			Keys: bson.D{
+				{Key: "branch_id", Value: 1},
+				{Key: "txn_last", Value: 1},
+				{Key: "lsn", Value: 1},
+			},
+			Options: options.Index().SetPartialFilterExpression(bson.M{"txn_last": bson.M{"$gt": 0}}),
+		},
 	}
 
 	if _, err2 := s.collection.Indexes().CreateMany(ctx, indexes); err2 != nil {
@@ -87,6 +126,65 @@ func NewService(db *mongo.Database) (*Service, error) {
 	return s, nil
 }
 
+// WithReadPreference returns a view of the service whose WAL reads use rp,
+// typically a secondary so heavy reads stay off the primary that serves
+// writes. The view shares the sequencer, metrics and compressor, and is
+// meant for read paths only. A secondary can lag the primary, so each of
+// the view's entry reads waits until the member it reads from has caught
+// up with the primary as of the read: a branch head read from the primary
+// beforehand always has all its entries.
+func (s *Service) WithReadPreference(rp *readpref.ReadPref) (*Service, error) {
+	collection, err := s.collection.Clone(options.Collection().SetReadPreference(rp))
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure read preference: %w", err)
+	}
+	anchor, err := s.collection.Clone(options.Collection().SetReadPreference(readpref.Primary()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure read preference: %w", err)
+	}
+	view := *s
+	view.collection = collection
+	view.readPref = rp
+	view.anchor = anchor
+	return &view, nil
+}
+
+// consistentRead prepares ctx for one of a read view's queries: in a
+// causally consistent session anchored by a primary read, the driver sends
+// the query with afterClusterTime, and the member serving it waits until
+// it has replicated everything the primary had at the anchor. Outside a
+// view it returns ctx unchanged. end releases the session.
+func (s *Service) consistentRead(ctx context.Context) (_ context.Context, end func(), err error) {
+	if s.anchor == nil {
+		return ctx, func() {}, nil
+	}
+	session, err := s.db.Client().StartSession(options.Session().SetCausalConsistency(true))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to start read session: %w", err)
+	}
+	sessionCtx := mongo.NewSessionContext(ctx, session)
+	err = s.anchor.FindOne(sessionCtx, bson.M{}, options.FindOne().SetProjection(bson.M{"_id": 1})).Err()
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		session.EndSession(ctx)
+		return nil, nil, fmt.Errorf("failed to anchor read at the primary: %w", err)
+	}
+	return sessionCtx, func() { session.EndSession(ctx) }, nil
+}
+
+// DatabaseName is the metadata database the service keeps the WAL in.
+func (s *Service) DatabaseName() string {
+	return s.db.Name()
+}
+
+// ReadPreference reports where the service reads WAL entries from; the
+// primary unless this is a WithReadPreference view.
+func (s *Service) ReadPreference() *readpref.ReadPref {
+	if s.readPref == nil {
+		return readpref.Primary()
+	}
+	return s.readPref
+}
+
 // Append adds a new entry to the WAL
 func (s *Service) Append(entry *Entry) (int64, error) {
 	if err := entry.ValidateForAppend(); err != nil {
@@ -101,19 +199,35 @@ func (s *Service) Append(entry *Entry) (int64, error) {
 	entry.LSN = lsn
 	entry.Timestamp = time.Now()
 
+	// Subscribers get the entry as appended, before compression clears
+	// its images.
+	var published *Entry
+	if !entry.Pending && s.subscribers.active() {
+		snapshot := *entry
+		published = &snapshot
+	}
+
 	// Compress entry before storing
-	if err := s.compressor.CompressEntry(entry); err != nil {
+	if err := s.compressEntry(entry); err != nil {
 		return 0, fmt.Errorf("failed to compress WAL entry: %w", err)
 	}
+	doc, err := sealEntry(entry)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode WAL entry: %w", err)
+	}
 
 	ctx := context.Background()
-	if _, err := s.collection.InsertOne(ctx, entry); err != nil {
+	if _, err := s.collection.InsertOne(ctx, doc); err != nil {
 		// The reserved LSN becomes a gap in the sequence. Gaps are
 		// harmless: consumers rely on ordering, never on density, so
 		// reservations are never rolled back (a rollback under
 		// concurrency could hand an already-used LSN to a later writer).
 		return 0, fmt.Errorf("failed to append WAL entry: %w", err)
 	}
+	s.metrics.RecordEntriesAppended(entry.ProjectID, 1)
+	if published != nil {
+		s.subscribers.publish(published)
+	}
 
 	return lsn, nil
 }
@@ -142,21 +256,40 @@ func (s *Service) AppendBatch(entries []*Entry) ([]int64, error) {
 		return nil, err
 	}
 
+	// Each transaction's entries record where its block ends.
+	txnLast := make(map[string]int64)
+	for i, entry := range entries {
+		if entry.TxnID != "" {
+			txnLast[entry.TxnID] = firstLSN + int64(i)
+		}
+	}
+
 	now := time.Now()
 	lsns := make([]int64, len(entries))
 	documents := make([]interface{}, len(entries))
+	var published []*Entry
+	publishing := s.subscribers.active()
 
 	for i, entry := range entries {
 		entry.LSN = firstLSN + int64(i)
 		entry.Timestamp = now
+		entry.TxnLastLSN = txnLast[entry.TxnID]
 		lsns[i] = entry.LSN
+		if publishing && !entry.Pending {
+			snapshot := *entry
+			published = append(published, &snapshot)
+		}
 
 		// Compress entry before storing
-		if err := s.compressor.CompressEntry(entry); err != nil {
+		if err := s.compressEntry(entry); err != nil {
 			return nil, fmt.Errorf("failed to compress WAL entry %d: %w", i, err)
 		}
+		doc, err := sealEntry(entry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode WAL entry %d: %w", i, err)
+		}
 
-		documents[i] = entry
+		documents[i] = doc
 	}
 
 	ctx := context.Background()
@@ -164,31 +297,41 @@ func (s *Service) AppendBatch(entries []*Entry) ([]int64, error) {
 		// Any unwritten reserved LSNs become gaps, which are harmless.
 		return nil, fmt.Errorf("failed to append WAL entries batch: %w", err)
 	}
+	s.metrics.RecordEntriesAppended(projectID, len(entries))
+	if len(published) > 0 {
+		s.subscribers.publish(published...)
+	}
 
 	return lsns, nil
 }
 
 // GetEntry retrieves a single WAL entry by project and LSN. LSNs are unique
-// only within a project.
+// only within a project. An entry failing its checksum is an
+// ErrEntryCorrupt.
 func (s *Service) GetEntry(projectID string, lsn int64) (*Entry, error) {
 	ctx := context.Background()
-	var entry Entry
-	err := s.collection.FindOne(ctx, bson.M{"project_id": projectID, "lsn": lsn}).Decode(&entry)
+	doc, err := s.collection.FindOne(ctx, bson.M{"project_id": projectID, "lsn": lsn}).Raw()
 	if err != nil {
 		return nil, err
 	}
-
-	// Decompress entry after retrieval
-	if err := s.compressor.DecompressEntry(&entry); err != nil {
-		return nil, fmt.Errorf("failed to decompress WAL entry: %w", err)
-	}
-
-	return &entry, nil
+	return s.decodeEntry(doc)
 }
 
 // GetEntries retrieves WAL entries within an LSN range
 func (s *Service) GetEntries(filter bson.M, opts ...*options.FindOptions) ([]*Entry, error) {
-	ctx := context.Background()
+	return s.GetEntriesContext(context.Background(), filter, opts...)
+}
+
+// GetEntriesContext is GetEntries bounded by ctx: a cancelled or expired
+// context aborts the query. Every entry is checked against its checksum;
+// the first that fails aborts the read with an ErrEntryCorrupt, so a
+// corrupt entry can never silently yield a wrong state.
+func (s *Service) GetEntriesContext(ctx context.Context, filter bson.M, opts ...*options.FindOptions) ([]*Entry, error) {
+	ctx, end, err := s.consistentRead(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer end()
 	cursor, err := s.collection.Find(ctx, filter, opts...)
 	if err != nil {
 		return nil, err
@@ -196,15 +339,17 @@ func (s *Service) GetEntries(filter bson.M, opts ...*options.FindOptions) ([]*En
 	defer func() { _ = cursor.Close(ctx) }()
 
 	var entries []*Entry
-	if err := cursor.All(ctx, &entries); err != nil {
-		return nil, err
-	}
-
-	// Decompress all entries
-	for _, entry := range entries {
-		if err := s.compressor.DecompressEntry(entry); err != nil {
-			return nil, fmt.Errorf("failed to decompress WAL entry LSN %d: %w", entry.LSN, err)
+	for cursor.Next(ctx) {
+		// Current is only valid until the next call to Next, and decoded
+		// images may alias it.
+		entry, err := s.decodeEntry(append(bson.Raw(nil), cursor.Current...))
+		if err != nil {
+			return nil, err
 		}
+		entries = append(entries, entry)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
 	}
 
 	return entries, nil
@@ -228,6 +373,153 @@ func (s *Service) GetBranchEntries(branchID, collection string, startLSN, endLSN
 	return s.GetEntries(filter, opts)
 }
 
+// GetEntriesByUser retrieves a project's entries written by one actor,
+// across all branches, within an LSN range, oldest first. A toLSN of 0
+// means no upper bound; a positive limit caps the number returned.
+func (s *Service) GetEntriesByUser(projectID, userID string, fromLSN, toLSN, limit int64) ([]*Entry, error) {
+	if userID == "" {
+		return nil, fmt.Errorf("user ID must not be empty")
+	}
+	lsnRange := bson.M{"$gte": fromLSN}
+	if toLSN > 0 {
+		lsnRange["$lte"] = toLSN
+	}
+	filter := bson.M{
+		"project_id": projectID,
+		"actor":      userID,
+		"lsn":        lsnRange,
+	}
+
+	opts := options.Find().SetSort(bson.M{"lsn": 1})
+	if limit > 0 {
+		opts.SetLimit(limit)
+	}
+	return s.GetEntries(filter, opts)
+}
+
+// CountBranchEntries counts a branch's own data entries (document changes,
+// not control records) within an LSN range, without loading them. Entries
+// in a discarded range that applies to a read up to endLSN are not
+// counted: after a reset, the abandoned window is not part of the branch.
+func (s *Service) CountBranchEntries(branch *Branch, startLSN, endLSN int64) (int64, error) {
+	filter := bson.M{
+		"branch_id": branch.ID,
+		"operation": bson.M{"$in": []OperationType{OpPut, OpDelete, LegacyOpInsert, LegacyOpUpdate}},
+		"lsn": bson.M{
+			"$gte": startLSN,
+			"$lte": endLSN,
+		},
+	}
+	discarded := make([]bson.M, 0, len(branch.DiscardedRanges))
+	for _, r := range branch.DiscardedRanges {
+		if endLSN > r.To {
+			discarded = append(discarded, bson.M{"lsn": bson.M{"$gte": r.From, "$lte": r.To}})
+		}
+	}
+	if len(discarded) > 0 {
+		filter["$nor"] = discarded
+	}
+	return s.collection.CountDocuments(context.Background(), filter)
+}
+
+// CountCollectionEntries counts a branch's entries of one operation in one
+// collection, across all LSNs, without loading them.
+func (s *Service) CountCollectionEntries(branchID, collection string, op OperationType) (int64, error) {
+	return s.collection.CountDocuments(context.Background(), bson.M{
+		"branch_id":  branchID,
+		"collection": collection,
+		"operation":  op,
+	})
+}
+
+// GetReplayEntries is the materialization read of one segment: a branch's
+// own entries for a collection (and, when documentID is set, one document)
+// in [startLSN, endLSN], minus entries superseded by a compaction the read
+// can see. A compaction is visible when its LSN is at or below endLSN and
+// not in a discarded range that applies to the read — a reset that
+// abandons the compaction entry also restores the history it replaced.
+// Entries of a transaction that ends past endLSN are skipped too: a read
+// inside a transaction's block sees the state before it.
+func (s *Service) GetReplayEntries(ctx context.Context, branch *Branch, collection, documentID string, startLSN, endLSN int64) ([]*Entry, error) {
+	visible := []bson.M{
+		{"superseded_by": bson.M{"$exists": false}},
+		{"superseded_by": bson.M{"$gt": endLSN}},
+	}
+	for _, r := range branch.DiscardedRanges {
+		if endLSN > r.To {
+			visible = append(visible, bson.M{"superseded_by": bson.M{"$gte": r.From, "$lte": r.To}})
+		}
+	}
+	filter := bson.M{
+		"branch_id":  branch.ID,
+		"collection": collection,
+		"lsn": bson.M{
+			"$gte": startLSN,
+			"$lte": endLSN,
+		},
+		"$or":      visible,
+		"txn_last": bson.M{"$not": bson.M{"$gt": endLSN}},
+	}
+	if documentID != "" {
+		filter["document_id"] = documentID
+	}
+
+	opts := options.Find().SetSort(bson.M{"lsn": 1})
+	return s.GetEntriesContext(ctx, filter, opts)
+}
+
+// TxnSafeLSN returns lsn, or, when lsn falls inside the block of one of
+// the branch's transactions (the block starts at or below it and ends
+// above it), the LSN just before the block: the last point at which the
+// transaction is wholly absent. Restores and forks land there, never
+// between a transaction's entries.
+func (s *Service) TxnSafeLSN(branchID string, lsn int64) (int64, error) {
+	// Blocks are disjoint LSN ranges, so the first block ending after lsn
+	// is the only one that can contain it; its first entry says where.
+	ctx, end, err := s.consistentRead(context.Background())
+	if err != nil {
+		return 0, err
+	}
+	defer end()
+	var first struct {
+		LSN int64 `bson:"lsn"`
+	}
+	err = s.collection.FindOne(ctx,
+		bson.M{"branch_id": branchID, "txn_last": bson.M{"$gt": lsn}},
+		options.FindOne().
+			SetSort(bson.D{{Key: "txn_last", Value: 1}, {Key: "lsn", Value: 1}}).
+			SetProjection(bson.M{"lsn": 1}),
+	).Decode(&first)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return lsn, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up transactions around LSN %d: %w", lsn, err)
+	}
+	if first.LSN <= lsn {
+		return first.LSN - 1, nil
+	}
+	return lsn, nil
+}
+
+// MarkSuperseded flags a document's data entries on one branch with LSN
+// below by as superseded by the compaction entry at by. Entries already
+// superseded keep their earlier mark. Returns how many entries were marked.
+func (s *Service) MarkSuperseded(branchID, collection, documentID string, by int64) (int64, error) {
+	res, err := s.collection.UpdateMany(context.Background(), bson.M{
+		"branch_id":     branchID,
+		"collection":    collection,
+		"document_id":   documentID,
+		"operation":     bson.M{"$in": []OperationType{OpPut, OpDelete}},
+		"lsn":           bson.M{"$lt": by},
+		"superseded_by": bson.M{"$exists": false},
+	}, bson.M{"$set": bson.M{"superseded_by": by}})
+	if err != nil {
+		return 0, err
+	}
+	return res.ModifiedCount, nil
+}
+
 // GetEntriesByTimestamp retrieves entries up to a specific timestamp
 func (s *Service) GetEntriesByTimestamp(projectID string, timestamp time.Time) ([]*Entry, error) {
 	filter := bson.M{
@@ -270,7 +562,11 @@ func (s *Service) GetDocumentHistory(branchID, collection, documentID string, st
 // DistinctCollections returns the collections touched by a branch's own
 // entries within an LSN range.
 func (s *Service) DistinctCollections(branchID string, startLSN, endLSN int64) ([]string, error) {
-	ctx := context.Background()
+	ctx, end, err := s.consistentRead(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer end()
 	values, err := s.collection.Distinct(ctx, "collection", bson.M{
 		"branch_id":  branchID,
 		"collection": bson.M{"$ne": ""},
@@ -348,6 +644,70 @@ func (s *Service) DeleteDataEntriesUpTo(branchID, collection string, cutoff int6
 	return res.DeletedCount, nil
 }
 
+// DeleteSupersededEntries removes a branch's entries superseded by a
+// compaction at or below safeLSN, skipping compactions that fall in one of
+// the branch's discarded ranges (their supersession no longer holds).
+// Callers pick safeLSN so that no reader bound lies below it (see the gc
+// package).
+func (s *Service) DeleteSupersededEntries(branch *Branch, safeLSN int64) (int64, error) {
+	filter := bson.M{
+		"branch_id":     branch.ID,
+		"superseded_by": bson.M{"$lte": safeLSN},
+	}
+	if len(branch.DiscardedRanges) > 0 {
+		discarded := make([]bson.M, 0, len(branch.DiscardedRanges))
+		for _, r := range branch.DiscardedRanges {
+			discarded = append(discarded, bson.M{"superseded_by": bson.M{"$gte": r.From, "$lte": r.To}})
+		}
+		filter["$nor"] = discarded
+	}
+	res, err := s.collection.DeleteMany(context.Background(), filter)
+	if err != nil {
+		return 0, err
+	}
+	return res.DeletedCount, nil
+}
+
+// AppendPending is the first phase of a two-phase append: the entry is
+// written like Append but marked pending until ConfirmEntry is called, so
+// committed-only readers ignore it if the writer's mirrored work fails.
+func (s *Service) AppendPending(entry *Entry) (int64, error) {
+	entry.Pending = true
+	return s.Append(entry)
+}
+
+// ConfirmEntry is the second phase of a two-phase append: it clears the
+// pending mark on the entry at lsn. Confirming an entry that is not
+// pending is an error.
+func (s *Service) ConfirmEntry(projectID string, lsn int64) error {
+	res, err := s.collection.UpdateOne(context.Background(),
+		bson.M{"project_id": projectID, "lsn": lsn, "pending": true},
+		bson.M{"$unset": bson.M{"pending": ""}})
+	if err != nil {
+		return fmt.Errorf("failed to confirm WAL entry %d: %w", lsn, err)
+	}
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("no pending WAL entry at LSN %d", lsn)
+	}
+	if s.subscribers.active() {
+		// Subscribers see a two-phase entry only once it is confirmed.
+		entry, err := s.GetEntry(projectID, lsn)
+		if err != nil {
+			return fmt.Errorf("failed to read confirmed WAL entry %d: %w", lsn, err)
+		}
+		s.subscribers.publish(entry)
+	}
+	return nil
+}
+
+// RetractEntry removes one entry by LSN. Only safe for an entry its writer
+// appended but never published: the branch head has not been advanced to
+// it, so no reader could have observed it. The LSN becomes a gap.
+func (s *Service) RetractEntry(projectID string, lsn int64) error {
+	_, err := s.collection.DeleteOne(context.Background(), bson.M{"project_id": projectID, "lsn": lsn})
+	return err
+}
+
 // DeleteBranchEntries removes every entry belonging to a branch. Only safe
 // once the branch is deleted and no live descendant can traverse it.
 func (s *Service) DeleteBranchEntries(branchID string) (int64, error) {
@@ -364,11 +724,47 @@ func (s *Service) GetMetrics() MetricsSnapshot {
 	return s.metrics.GetSnapshot()
 }
 
+// Metrics returns the registry the service records into, for services
+// layered on the WAL to report their own gauges.
+func (s *Service) Metrics() *Metrics {
+	return s.metrics
+}
+
 // GetSuccessRates returns success rates for operations
 func (s *Service) GetSuccessRates() map[string]float64 {
 	return s.metrics.GetSuccessRate()
 }
 
+// SetCompression switches how appended images are compressed. Reads are
+// unaffected: each stored image records its own compression, so entries
+// written under any setting still read. Call it during setup, before
+// taking read views (WithReadPreference), which share the compressor.
+func (s *Service) SetCompression(t CompressionType) error {
+	config := DefaultCompressionConfig()
+	config.Type = t
+	compressor, err := NewCompressor(config)
+	if err != nil {
+		return fmt.Errorf("failed to create compressor: %w", err)
+	}
+	old := s.compressor
+	s.compressor = compressor
+	if old != nil {
+		_ = old.Close()
+	}
+	return nil
+}
+
+// compressEntry compresses entry's images for storage and records the
+// raw and stored sizes behind the compression ratio metric.
+func (s *Service) compressEntry(entry *Entry) error {
+	raw := len(entry.PostImage) + len(entry.PreImage)
+	if err := s.compressor.CompressEntry(entry); err != nil {
+		return err
+	}
+	s.metrics.RecordImageBytes(raw, len(entry.CompressedPostImage)+len(entry.CompressedPreImage))
+	return nil
+}
+
 // Close cleans up resources used by the service
 func (s *Service) Close() error {
 	if s.compressor != nil {
@@ -0,0 +1,121 @@
+package wal
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultSlowQueryThreshold is the duration above which a read is logged
+// as slow unless configured otherwise.
+const DefaultSlowQueryThreshold = 500 * time.Millisecond
+
+// defaultSlowQueryCapacity bounds how many slow queries are retained.
+const defaultSlowQueryCapacity = 100
+
+// Slow query kinds.
+const (
+	SlowQueryMaterialize = "materialize" // a read at the branch head
+	SlowQueryTimeTravel  = "time_travel" // a read below the branch head
+)
+
+// SlowQuery describes one read that exceeded the slow-query threshold.
+type SlowQuery struct {
+	Kind            string        `json:"kind"`
+	ProjectID       string        `json:"project_id"`
+	BranchID        string        `json:"branch_id"`
+	BranchName      string        `json:"branch_name"`
+	Collection      string        `json:"collection"`
+	FromLSN         int64         `json:"from_lsn"`
+	ToLSN           int64         `json:"to_lsn"`
+	EntriesReplayed int           `json:"entries_replayed"`
+	Duration        time.Duration `json:"duration"`
+	Timestamp       time.Time     `json:"timestamp"`
+}
+
+// SlowQueryLog keeps the most recent slow queries in a bounded ring.
+type SlowQueryLog struct {
+	mu        sync.RWMutex
+	threshold time.Duration
+	capacity  int
+	entries   []SlowQuery
+	next      int
+	total     int64
+}
+
+// NewSlowQueryLog creates a log that retains up to capacity queries at or
+// above threshold. A threshold of zero or less disables logging.
+func NewSlowQueryLog(threshold time.Duration, capacity int) *SlowQueryLog {
+	if capacity <= 0 {
+		capacity = defaultSlowQueryCapacity
+	}
+	return &SlowQueryLog{
+		threshold: threshold,
+		capacity:  capacity,
+		entries:   make([]SlowQuery, 0, capacity),
+	}
+}
+
+// SetThreshold changes the slow-query threshold; zero or less disables
+// logging.
+func (l *SlowQueryLog) SetThreshold(threshold time.Duration) {
+	l.mu.Lock()
+	l.threshold = threshold
+	l.mu.Unlock()
+}
+
+// Threshold returns the current slow-query threshold.
+func (l *SlowQueryLog) Threshold() time.Duration {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.threshold
+}
+
+// Observe records the query if it is slow, evicting the oldest entry when
+// the log is full. It reports whether the query was recorded.
+func (l *SlowQueryLog) Observe(q SlowQuery) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.threshold <= 0 || q.Duration < l.threshold {
+		return false
+	}
+	if q.Timestamp.IsZero() {
+		q.Timestamp = time.Now()
+	}
+	if len(l.entries) < l.capacity {
+		l.entries = append(l.entries, q)
+	} else {
+		l.entries[l.next] = q
+	}
+	l.next = (l.next + 1) % l.capacity
+	l.total++
+	return true
+}
+
+// Entries returns the retained slow queries, newest first.
+func (l *SlowQueryLog) Entries() []SlowQuery {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	out := make([]SlowQuery, 0, len(l.entries))
+	for i := 1; i <= len(l.entries); i++ {
+		idx := (l.next - i + len(l.entries)) % len(l.entries)
+		out = append(out, l.entries[idx])
+	}
+	return out
+}
+
+// Total returns how many slow queries have been observed, including ones
+// since evicted.
+func (l *SlowQueryLog) Total() int64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.total
+}
+
+// Reset discards every retained entry.
+func (l *SlowQueryLog) Reset() {
+	l.mu.Lock()
+	l.entries = l.entries[:0]
+	l.next = 0
+	l.total = 0
+	l.mu.Unlock()
+}
@@ -0,0 +1,123 @@
+package wal
+
+import (
+	"sync"
+)
+
+// DefaultSubscriptionBuffer is how many entries a subscriber may fall
+// behind by before it is dropped.
+const DefaultSubscriptionBuffer = 256
+
+// StreamFilter selects the entries a subscription receives. Empty fields
+// match everything.
+type StreamFilter struct {
+	ProjectID  string
+	BranchID   string
+	Collection string
+}
+
+func (f StreamFilter) matches(entry *Entry) bool {
+	return (f.ProjectID == "" || entry.ProjectID == f.ProjectID) &&
+		(f.BranchID == "" || entry.BranchID == f.BranchID) &&
+		(f.Collection == "" || entry.Collection == f.Collection)
+}
+
+// Subscription receives entries as they are appended. Entries arrives
+// closed once the subscription is cancelled or dropped for falling behind;
+// Dropped tells the two apart.
+type Subscription struct {
+	Entries <-chan *Entry
+
+	entries  chan *Entry
+	filter   StreamFilter
+	registry *subscriberRegistry
+	dropped  bool
+}
+
+// Dropped reports whether the subscription was closed because its
+// consumer fell more than its buffer behind. Only meaningful once Entries
+// is closed.
+func (sub *Subscription) Dropped() bool {
+	sub.registry.mu.Lock()
+	defer sub.registry.mu.Unlock()
+	return sub.dropped
+}
+
+// subscriberRegistry fans appended entries out to subscriptions. Publishing
+// never blocks: a subscriber whose buffer is full is dropped, so a slow
+// consumer cannot stall writers.
+type subscriberRegistry struct {
+	mu   sync.Mutex
+	subs map[*Subscription]struct{}
+}
+
+func newSubscriberRegistry() *subscriberRegistry {
+	return &subscriberRegistry{subs: make(map[*Subscription]struct{})}
+}
+
+// active reports whether anyone is subscribed, so appends can skip the
+// copy they make for publishing when nobody is listening.
+func (r *subscriberRegistry) active() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.subs) > 0
+}
+
+func (r *subscriberRegistry) publish(entries ...*Entry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for sub := range r.subs {
+		for _, entry := range entries {
+			if !sub.filter.matches(entry) {
+				continue
+			}
+			select {
+			case sub.entries <- entry:
+			default:
+				sub.dropped = true
+				r.removeLocked(sub)
+			}
+			if sub.dropped {
+				break
+			}
+		}
+	}
+}
+
+func (r *subscriberRegistry) removeLocked(sub *Subscription) {
+	if _, ok := r.subs[sub]; !ok {
+		return
+	}
+	delete(r.subs, sub)
+	close(sub.entries)
+}
+
+// Subscribe registers for entries matching filter as they are appended, up
+// to buffer entries ahead of the consumer (DefaultSubscriptionBuffer when
+// buffer <= 0). Pending entries are delivered when confirmed. Entries are
+// shared between subscribers and must not be modified. Call Unsubscribe
+// when done.
+func (s *Service) Subscribe(filter StreamFilter, buffer int) *Subscription {
+	if buffer <= 0 {
+		buffer = DefaultSubscriptionBuffer
+	}
+	entries := make(chan *Entry, buffer)
+	sub := &Subscription{
+		Entries:  entries,
+		entries:  entries,
+		filter:   filter,
+		registry: s.subscribers,
+	}
+	s.subscribers.mu.Lock()
+	s.subscribers.subs[sub] = struct{}{}
+	s.subscribers.mu.Unlock()
+	return sub
+}
+
+// Unsubscribe cancels sub and closes its channel. Unsubscribing a dropped
+// or already cancelled subscription is a no-op.
+func (s *Service) Unsubscribe(sub *Subscription) {
+	sub.registry.mu.Lock()
+	defer sub.registry.mu.Unlock()
+	sub.registry.removeLocked(sub)
+}
@@ -0,0 +1,260 @@
+// Package walexport writes WAL entries out of MongoDB as segment files.
+//
+// A segment is a plain stream of BSON documents (the same framing as a
+// mongodump .bson file), one per entry in LSN order, carrying the entry's
+// stored fields plus its uncompressed images. Segments are cut at a
+// configurable entry count and/or byte size and named after the LSN range
+// they hold, so a large export stays manageable and its segments can be
+// loaded independently and in parallel.
+package walexport
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/argon-lab/argon/internal/wal"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// readBatchSize bounds how many entries are held in memory while exporting.
+const readBatchSize = 1000
+
+// Options selects what to export and how to split it.
+type Options struct {
+	// OutputDir receives the segment files; it is created if missing.
+	OutputDir string
+	// BranchID and Collection narrow the export; empty means all.
+	BranchID   string
+	Collection string
+	// FromLSN and ToLSN bound the export (inclusive); ToLSN 0 means no
+	// upper bound.
+	FromLSN int64
+	ToLSN   int64
+	// MaxEntriesPerSegment and MaxSegmentBytes cut a new segment before
+	// either limit would be exceeded; 0 disables a limit. A single entry
+	// larger than MaxSegmentBytes gets a segment of its own.
+	MaxEntriesPerSegment int
+	MaxSegmentBytes      int64
+}
+
+// Record is the on-disk form of one exported entry. The stored entry keeps
+// its images compressed and out of its BSON form; a record carries them
+// decompressed so segments are readable without Argon's compressor.
+type Record struct {
+	wal.Entry `bson:",inline"`
+	Post      bson.Raw `bson:"post_image,omitempty"`
+	Pre       bson.Raw `bson:"pre_image,omitempty"`
+}
+
+// Segment describes one written segment file.
+type Segment struct {
+	Path    string `json:"path"`
+	FromLSN int64  `json:"from_lsn"`
+	ToLSN   int64  `json:"to_lsn"`
+	Entries int    `json:"entries"`
+	Bytes   int64  `json:"bytes"`
+}
+
+// Result summarizes an export.
+type Result struct {
+	Segments []Segment `json:"segments"`
+	Entries  int       `json:"entries"`
+	Bytes    int64     `json:"bytes"`
+}
+
+// Service exports WAL entries to segment files.
+type Service struct {
+	wal *wal.Service
+}
+
+// NewService creates a new export service
+func NewService(walService *wal.Service) *Service {
+	return &Service{wal: walService}
+}
+
+// SegmentName is the file name of a segment holding [fromLSN, toLSN].
+// LSNs are zero-padded so a directory listing sorts in log order.
+func SegmentName(fromLSN, toLSN int64) string {
+	return fmt.Sprintf("wal-%020d-%020d.bson", fromLSN, toLSN)
+}
+
+// Export writes a project's entries matching opts to segment files.
+func (s *Service) Export(ctx context.Context, projectID string, opts Options) (*Result, error) {
+	if projectID == "" {
+		return nil, fmt.Errorf("export requires a project ID")
+	}
+	if opts.OutputDir == "" {
+		return nil, fmt.Errorf("export requires an output directory")
+	}
+	if opts.MaxEntriesPerSegment < 0 || opts.MaxSegmentBytes < 0 {
+		return nil, fmt.Errorf("segment limits must not be negative")
+	}
+	if err := os.MkdirAll(opts.OutputDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory %s: %w", opts.OutputDir, err)
+	}
+
+	w := &segmentWriter{dir: opts.OutputDir, maxEntries: opts.MaxEntriesPerSegment, maxBytes: opts.MaxSegmentBytes}
+	defer w.abort()
+
+	lastLSN := opts.FromLSN - 1
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		lsnRange := bson.M{"$gt": lastLSN}
+		if opts.ToLSN > 0 {
+			lsnRange["$lte"] = opts.ToLSN
+		}
+		filter := bson.M{"project_id": projectID, "lsn": lsnRange}
+		if opts.BranchID != "" {
+			filter["branch_id"] = opts.BranchID
+		}
+		if opts.Collection != "" {
+			filter["collection"] = opts.Collection
+		}
+		entries, err := s.wal.GetEntries(filter, options.Find().SetSort(bson.M{"lsn": 1}).SetLimit(readBatchSize))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read WAL entries after LSN %d: %w", lastLSN, err)
+		}
+		for _, entry := range entries {
+			if err := w.write(entry); err != nil {
+				return nil, err
+			}
+			lastLSN = entry.LSN
+		}
+		if len(entries) < readBatchSize {
+			break
+		}
+	}
+
+	if err := w.close(); err != nil {
+		return nil, err
+	}
+	return w.result, nil
+}
+
+// ReadSegment loads every entry from one segment file, images included.
+func ReadSegment(path string) ([]*wal.Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open segment %s: %w", path, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	var entries []*wal.Entry
+	for {
+		raw, err := bson.NewFromIOReader(f)
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read segment %s: %w", path, err)
+		}
+		var rec Record
+		if err := bson.Unmarshal(raw, &rec); err != nil {
+			return nil, fmt.Errorf("failed to decode record in segment %s: %w", path, err)
+		}
+		entry := rec.Entry
+		entry.PostImage = rec.Post
+		entry.PreImage = rec.Pre
+		entries = append(entries, &entry)
+	}
+}
+
+// segmentWriter streams records into the current segment and rotates it at
+// the configured limits. The open segment is written under a temporary
+// name and renamed once its final LSN is known, so a partially written
+// export never leaves a segment whose name claims entries it lacks.
+type segmentWriter struct {
+	dir        string
+	maxEntries int
+	maxBytes   int64
+
+	file    *os.File
+	current Segment
+	result  *Result
+}
+
+func (w *segmentWriter) write(entry *wal.Entry) error {
+	raw, err := bson.Marshal(Record{Entry: *entry, Post: entry.PostImage, Pre: entry.PreImage})
+	if err != nil {
+		return fmt.Errorf("failed to encode entry LSN %d: %w", entry.LSN, err)
+	}
+	size := int64(len(raw))
+
+	if w.file != nil && w.full(size) {
+		if err := w.rotate(); err != nil {
+			return err
+		}
+	}
+	if w.file == nil {
+		f, err := os.CreateTemp(w.dir, "wal-segment-*.tmp")
+		if err != nil {
+			return fmt.Errorf("failed to create segment file: %w", err)
+		}
+		w.file = f
+		w.current = Segment{FromLSN: entry.LSN}
+	}
+
+	if _, err := w.file.Write(raw); err != nil {
+		return fmt.Errorf("failed to write entry LSN %d: %w", entry.LSN, err)
+	}
+	w.current.ToLSN = entry.LSN
+	w.current.Entries++
+	w.current.Bytes += size
+	return nil
+}
+
+// full reports whether adding a record of the given size would push the
+// open segment past a limit.
+func (w *segmentWriter) full(size int64) bool {
+	if w.maxEntries > 0 && w.current.Entries >= w.maxEntries {
+		return true
+	}
+	return w.maxBytes > 0 && w.current.Bytes+size > w.maxBytes
+}
+
+// rotate finalizes the open segment under its LSN-range name.
+func (w *segmentWriter) rotate() error {
+	if w.result == nil {
+		w.result = &Result{Segments: []Segment{}}
+	}
+	if w.file == nil {
+		return nil
+	}
+	tmpName := w.file.Name()
+	if err := w.file.Close(); err != nil {
+		w.file = nil
+		_ = os.Remove(tmpName)
+		return fmt.Errorf("failed to close segment: %w", err)
+	}
+	w.file = nil
+
+	w.current.Path = filepath.Join(w.dir, SegmentName(w.current.FromLSN, w.current.ToLSN))
+	if err := os.Rename(tmpName, w.current.Path); err != nil {
+		_ = os.Remove(tmpName)
+		return fmt.Errorf("failed to finalize segment %s: %w", w.current.Path, err)
+	}
+	w.result.Segments = append(w.result.Segments, w.current)
+	w.result.Entries += w.current.Entries
+	w.result.Bytes += w.current.Bytes
+	return nil
+}
+
+func (w *segmentWriter) close() error {
+	return w.rotate()
+}
+
+// abort discards an unfinished segment after a failed export.
+func (w *segmentWriter) abort() {
+	if w.file != nil {
+		name := w.file.Name()
+		_ = w.file.Close()
+		_ = os.Remove(name)
+		w.file = nil
+	}
+}
@@ -0,0 +1,179 @@
+package walwriter
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/argon-lab/argon/internal/wal"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Tx buffers puts and deletes on one branch and appends them on Commit as a
+// single contiguous LSN block sharing a TxnID. The branch head moves past
+// the whole block at once, so readers see all of the transaction or none
+// of it; FirstLSN-1 and LastLSN of the result are its pre- and
+// post-transaction states for time travel. A Tx is not safe for concurrent
+// use, and the writer should not write outside it until it ends.
+type Tx struct {
+	w       *Writer
+	id      string
+	entries []*wal.Entry
+	// staged is each touched document's state within the transaction
+	// (nil once deleted), keyed by collection and document ID, so later
+	// operations see earlier ones.
+	staged map[[2]string]bson.Raw
+	done   bool
+}
+
+// TxResult describes a committed transaction's block of entries.
+type TxResult struct {
+	TxnID    string
+	FirstLSN int64
+	LastLSN  int64
+	LSNs     []int64
+}
+
+// BeginTx starts a transaction on the writer's branch.
+func (w *Writer) BeginTx() (*Tx, error) {
+	if err := w.guardWritable(); err != nil {
+		return nil, err
+	}
+	return &Tx{w: w, id: primitive.NewObjectID().Hex(), staged: make(map[[2]string]bson.Raw)}, nil
+}
+
+// WithTransaction runs fn in a transaction on the writer's branch and
+// commits what it staged once fn returns nil. An error from fn, a panic in
+// it or a cancelled ctx aborts the transaction instead, leaving nothing in
+// the WAL. The result is Commit's: nil when fn staged nothing.
+func (w *Writer) WithTransaction(ctx context.Context, fn func(tx *Tx) error) (*TxResult, error) {
+	tx, err := w.BeginTx()
+	if err != nil {
+		return nil, err
+	}
+	// A no-op once committed; otherwise it discards what fn staged.
+	defer tx.Abort()
+	if err := fn(tx); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return tx.Commit(ctx)
+}
+
+// ID returns the TxnID the transaction's entries will carry.
+func (tx *Tx) ID() string { return tx.id }
+
+// Put stages a document's new state. Nothing is written until Commit; the
+// collection and document are checked now, as Writer.Put checks them.
+func (tx *Tx) Put(ctx context.Context, collection string, doc bson.M) error {
+	if tx.done {
+		return fmt.Errorf("transaction %s has ended", tx.id)
+	}
+	if err := tx.w.checkCaptured(collection); err != nil {
+		return err
+	}
+	entry, err := tx.w.putEntry(collection, doc)
+	if err != nil {
+		return err
+	}
+	pre, err := tx.current(collection, entry.DocumentID)
+	if err != nil {
+		return err
+	}
+	entry.PreImage = pre
+	tx.stage(entry, entry.PostImage)
+	return nil
+}
+
+// Delete stages a document's removal, reporting whether it existed (as of
+// the transaction's earlier operations).
+func (tx *Tx) Delete(ctx context.Context, collection string, id interface{}) (bool, error) {
+	if tx.done {
+		return false, fmt.Errorf("transaction %s has ended", tx.id)
+	}
+	if err := tx.w.checkCaptured(collection); err != nil {
+		return false, err
+	}
+	docID := wal.DocumentIDString(id)
+	pre, err := tx.current(collection, docID)
+	if err != nil {
+		return false, err
+	}
+	if pre == nil {
+		return false, nil
+	}
+	tx.stage(&wal.Entry{
+		ProjectID:  tx.w.branch.ProjectID,
+		BranchID:   tx.w.branch.ID,
+		Operation:  wal.OpDelete,
+		Collection: collection,
+		DocumentID: docID,
+		PreImage:   pre,
+		Actor:      tx.w.actor,
+	}, nil)
+	return true, nil
+}
+
+// Commit appends the staged operations and advances the branch head past
+// them. If the append fails, entries already written are retracted: the
+// head never reached them, so no reader saw them. Committing an empty
+// transaction is a no-op that returns a nil result.
+func (tx *Tx) Commit(ctx context.Context) (*TxResult, error) {
+	if tx.done {
+		return nil, fmt.Errorf("transaction %s has ended", tx.id)
+	}
+	tx.done = true
+	if len(tx.entries) == 0 {
+		return nil, nil
+	}
+	if err := tx.w.guardWritable(); err != nil {
+		return nil, err
+	}
+
+	lsns, err := tx.w.wal.AppendBatch(tx.entries)
+	if err != nil {
+		return nil, tx.retract(err)
+	}
+	if err := tx.w.advanceHead(tx.entries[len(tx.entries)-1]); err != nil {
+		return nil, tx.retract(err)
+	}
+	return &TxResult{TxnID: tx.id, FirstLSN: lsns[0], LastLSN: lsns[len(lsns)-1], LSNs: lsns}, nil
+}
+
+// Abort discards the staged operations. Aborting an ended transaction is
+// a no-op.
+func (tx *Tx) Abort() {
+	tx.done = true
+	tx.entries = nil
+	tx.staged = nil
+}
+
+// current returns a document's state as the transaction sees it: staged
+// if an earlier operation touched it, otherwise the branch's.
+func (tx *Tx) current(collection, docID string) (bson.Raw, error) {
+	if state, ok := tx.staged[[2]string{collection, docID}]; ok {
+		return state, nil
+	}
+	return tx.w.preImage(collection, docID)
+}
+
+func (tx *Tx) stage(entry *wal.Entry, state bson.Raw) {
+	entry.TxnID = tx.id
+	tx.entries = append(tx.entries, entry)
+	tx.staged[[2]string{entry.Collection, entry.DocumentID}] = state
+}
+
+// retract removes whatever part of the block was written before cause.
+func (tx *Tx) retract(cause error) error {
+	for _, entry := range tx.entries {
+		if entry.LSN == 0 {
+			continue
+		}
+		if err := tx.w.wal.RetractEntry(entry.ProjectID, entry.LSN); err != nil {
+			return fmt.Errorf("%w (and failed to retract transaction %s: %v)", cause, tx.id, err)
+		}
+	}
+	return cause
+}
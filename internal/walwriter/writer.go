@@ -8,7 +8,9 @@ package walwriter
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 
 	branchwal "github.com/argon-lab/argon/internal/branch/wal"
 	"github.com/argon-lab/argon/internal/materializer"
@@ -22,6 +24,69 @@ type AutoSnapshotter interface {
 	MaybeSnapshot(branch *wal.Branch)
 }
 
+// Reserved names the top-level keys a written document may not use. The
+// zero value reserves nothing.
+type Reserved struct {
+	Fields   []string // exact keys
+	Prefixes []string // key prefixes
+}
+
+// DefaultReserved keeps the "_argon" namespace for Argon's own metadata, so
+// it can be stamped onto documents without colliding with user data, and
+// "$"-prefixed keys, which filters would read as operators.
+var DefaultReserved = Reserved{Fields: []string{"_argon"}, Prefixes: []string{"$"}}
+
+// match returns the first top-level key of doc the set reserves.
+func (r Reserved) match(doc bson.M) (string, bool) {
+	for key := range doc {
+		for _, f := range r.Fields {
+			if key == f {
+				return key, true
+			}
+		}
+		for _, p := range r.Prefixes {
+			if strings.HasPrefix(key, p) {
+				return key, true
+			}
+		}
+	}
+	return "", false
+}
+
+// ReservedFieldError reports a document that uses a reserved top-level key.
+type ReservedFieldError struct {
+	Collection string
+	Field      string
+}
+
+func (e *ReservedFieldError) Error() string {
+	return fmt.Sprintf("field %q is reserved and cannot be written to collection %s", e.Field, e.Collection)
+}
+
+// CaptureDisabledError reports a write to a collection the project does
+// not capture. Such collections exist only in checked-out physical
+// databases, so a programmatic write, which has no other place to land,
+// is refused rather than dropped.
+type CaptureDisabledError struct {
+	Collection string
+}
+
+func (e *CaptureDisabledError) Error() string {
+	return fmt.Sprintf("collection %s is excluded from WAL capture", e.Collection)
+}
+
+// IDGenerator supplies _id values for documents written without one.
+type IDGenerator interface {
+	NewID() interface{}
+}
+
+// ObjectIDGenerator is the default IDGenerator: a fresh ObjectID, as
+// MongoDB drivers generate.
+type ObjectIDGenerator struct{}
+
+// NewID returns a new ObjectID.
+func (ObjectIDGenerator) NewID() interface{} { return primitive.NewObjectID() }
+
 // Writer appends puts and deletes to one branch's WAL.
 type Writer struct {
 	wal          *wal.Service
@@ -30,6 +95,9 @@ type Writer struct {
 	branch       *wal.Branch
 	actor        string
 	autoSnapshot AutoSnapshotter
+	reserved     Reserved
+	ids          IDGenerator
+	capture      wal.CaptureConfig
 }
 
 // New creates a writer for a branch. The materializer supplies pre-images
@@ -40,6 +108,8 @@ func New(walService *wal.Service, branches *branchwal.BranchService, mat *materi
 		branches:     branches,
 		materializer: mat,
 		branch:       branch,
+		reserved:     DefaultReserved,
+		ids:          ObjectIDGenerator{},
 	}
 }
 
@@ -50,10 +120,61 @@ func (w *Writer) SetActor(actor string) { w.actor = actor }
 // SetAutoSnapshotter enables threshold-based automatic snapshotting.
 func (w *Writer) SetAutoSnapshotter(a AutoSnapshotter) { w.autoSnapshot = a }
 
-// guardNotLive rejects writes to checked-out branches: their WAL is fed
-// from the physical database's change stream, and writing through both
-// paths would double-log.
-func (w *Writer) guardNotLive() error {
+// SetReserved replaces the reserved top-level keys (DefaultReserved unless
+// set). Writers replaying images that already passed validation on another
+// branch — merges — pass the zero value.
+func (w *Writer) SetReserved(r Reserved) { w.reserved = r }
+
+// SetIDGenerator replaces how missing _ids are generated (ObjectIDs unless
+// set), for deterministic tests or custom key schemes such as ULIDs. A nil
+// generator restores the ObjectID default.
+func (w *Writer) SetIDGenerator(g IDGenerator) {
+	if g == nil {
+		g = ObjectIDGenerator{}
+	}
+	w.ids = g
+}
+
+// SetCapture applies the project's capture policy (everything unless set);
+// writes to collections it excludes fail with CaptureDisabledError.
+func (w *Writer) SetCapture(c wal.CaptureConfig) { w.capture = c }
+
+// checkCaptured rejects writes to collections excluded from capture.
+func (w *Writer) checkCaptured(collection string) error {
+	if !w.capture.Captures(collection) {
+		return &CaptureDisabledError{Collection: collection}
+	}
+	return nil
+}
+
+// checkReserved rejects documents whose top-level keys are reserved.
+func (w *Writer) checkReserved(collection string, doc bson.M) error {
+	if key, ok := w.reserved.match(doc); ok {
+		return &ReservedFieldError{Collection: collection, Field: key}
+	}
+	return nil
+}
+
+// guardWritable rejects writes to protected branches, and to checked-out
+// branches: their WAL is fed from the physical database's change stream,
+// and writing through both paths would double-log. It goes by the writer's
+// view of the branch; the head advance enforces the current state, so a
+// branch closed after the writer was made still refuses its writes. Only
+// a writer that has seen its branch closed re-reads it, to notice it
+// reopening.
+func (w *Writer) guardWritable() error {
+	if w.branch.Protected || w.branch.IsLive() {
+		current, err := w.branches.GetBranchByID(w.branch.ID)
+		if err != nil {
+			return fmt.Errorf("failed to check branch %s: %w", w.branch.Name, err)
+		}
+		w.branch.Protected = current.Protected
+		w.branch.State = current.State
+		w.branch.PhysicalDB = current.PhysicalDB
+	}
+	if w.branch.Protected {
+		return fmt.Errorf("%w: %s is read-only; unprotect it to write", wal.ErrBranchProtected, w.branch.Name)
+	}
 	if w.branch.IsLive() {
 		return fmt.Errorf("branch %s is checked out as %s: write through a MongoDB driver instead", w.branch.Name, w.branch.PhysicalDB)
 	}
@@ -73,35 +194,23 @@ func (w *Writer) Put(ctx context.Context, collection string, doc bson.M) (int64,
 // PutMany sets several documents in one batched append (one contiguous LSN
 // range).
 func (w *Writer) PutMany(ctx context.Context, collection string, docs []bson.M) ([]int64, error) {
-	if err := w.guardNotLive(); err != nil {
+	if err := w.guardWritable(); err != nil {
 		return nil, err
 	}
 	if len(docs) == 0 {
 		return nil, fmt.Errorf("PutMany requires at least one document")
 	}
+	if err := w.checkCaptured(collection); err != nil {
+		return nil, err
+	}
 
 	entries := make([]*wal.Entry, 0, len(docs))
 	for i, doc := range docs {
-		normalized, id, err := normalizeDoc(doc)
+		entry, err := w.putEntry(collection, doc)
 		if err != nil {
 			return nil, fmt.Errorf("document %d: %w", i, err)
 		}
-		docID := wal.DocumentIDString(id)
-
-		post, err := bson.Marshal(normalized)
-		if err != nil {
-			return nil, fmt.Errorf("document %d: failed to marshal: %w", i, err)
-		}
-		entry := &wal.Entry{
-			ProjectID:  w.branch.ProjectID,
-			BranchID:   w.branch.ID,
-			Operation:  wal.OpPut,
-			Collection: collection,
-			DocumentID: docID,
-			PostImage:  post,
-			Actor:      w.actor,
-		}
-		if pre, err := w.preImage(collection, docID); err != nil {
+		if pre, err := w.preImage(collection, entry.DocumentID); err != nil {
 			return nil, err
 		} else if pre != nil {
 			entry.PreImage = pre
@@ -113,16 +222,43 @@ func (w *Writer) PutMany(ctx context.Context, collection string, docs []bson.M)
 	if err != nil {
 		return nil, err
 	}
-	if err := w.advanceHead(lsns[len(lsns)-1]); err != nil {
-		return nil, err
+	if err := w.advanceHead(entries[len(entries)-1]); err != nil {
+		return nil, w.retractRefused(entries, err)
 	}
 	return lsns, nil
 }
 
+// putEntry validates doc and builds its put entry, without a pre-image.
+func (w *Writer) putEntry(collection string, doc bson.M) (*wal.Entry, error) {
+	normalized, id, err := normalizeDoc(doc, w.ids)
+	if err != nil {
+		return nil, err
+	}
+	if err := w.checkReserved(collection, normalized); err != nil {
+		return nil, err
+	}
+	post, err := bson.Marshal(normalized)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal: %w", err)
+	}
+	return &wal.Entry{
+		ProjectID:  w.branch.ProjectID,
+		BranchID:   w.branch.ID,
+		Operation:  wal.OpPut,
+		Collection: collection,
+		DocumentID: wal.DocumentIDString(id),
+		PostImage:  post,
+		Actor:      w.actor,
+	}, nil
+}
+
 // Delete removes a document by its _id value. Returns (lsn, true) when the
 // document existed, (0, false) when there was nothing to delete.
 func (w *Writer) Delete(ctx context.Context, collection string, id interface{}) (int64, bool, error) {
-	if err := w.guardNotLive(); err != nil {
+	if err := w.guardWritable(); err != nil {
+		return 0, false, err
+	}
+	if err := w.checkCaptured(collection); err != nil {
 		return 0, false, err
 	}
 	docID := wal.DocumentIDString(id)
@@ -148,12 +284,26 @@ func (w *Writer) Delete(ctx context.Context, collection string, id interface{})
 	if err != nil {
 		return 0, false, err
 	}
-	if err := w.advanceHead(lsn); err != nil {
-		return 0, false, err
+	if err := w.advanceHead(entry); err != nil {
+		return 0, false, w.retractRefused([]*wal.Entry{entry}, err)
 	}
 	return lsn, true, nil
 }
 
+// retractRefused removes entries whose head advance a closed branch
+// refused: the head never reached them, so no reader saw them.
+func (w *Writer) retractRefused(entries []*wal.Entry, cause error) error {
+	if !errors.Is(cause, wal.ErrBranchProtected) && !errors.Is(cause, wal.ErrBranchCheckedOut) {
+		return cause
+	}
+	for _, entry := range entries {
+		if err := w.wal.RetractEntry(entry.ProjectID, entry.LSN); err != nil {
+			return fmt.Errorf("%w (and failed to retract LSN %d: %v)", cause, entry.LSN, err)
+		}
+	}
+	return cause
+}
+
 // preImage point-looks-up the document's current state, marshalled, or nil.
 func (w *Writer) preImage(collection, docID string) (bson.Raw, error) {
 	current, err := w.materializer.MaterializeDocument(w.branch, collection, docID)
@@ -170,12 +320,23 @@ func (w *Writer) preImage(collection, docID string) (bson.Raw, error) {
 	return raw, nil
 }
 
-func (w *Writer) advanceHead(lsn int64) error {
-	if err := w.branches.UpdateBranchHead(w.branch.ID, lsn); err != nil {
+// advanceHead publishes the writer's entries up to last, which was
+// appended after them. A branch closed since the writer last looked
+// refuses; the writer then sees it closed until it reopens.
+func (w *Writer) advanceHead(last *wal.Entry) error {
+	if err := w.branches.AdvanceWriterHead(w.branch.ID, last.LSN, last.Timestamp); err != nil {
+		switch {
+		case errors.Is(err, wal.ErrBranchProtected):
+			w.branch.Protected = true
+			return err
+		case errors.Is(err, wal.ErrBranchCheckedOut):
+			w.branch.State = wal.BranchStateLive
+			return err
+		}
 		return fmt.Errorf("failed to advance branch head: %w", err)
 	}
-	if lsn > w.branch.HeadLSN {
-		w.branch.HeadLSN = lsn
+	if last.LSN > w.branch.HeadLSN {
+		w.branch.HeadLSN = last.LSN
 	}
 	if w.autoSnapshot != nil {
 		w.autoSnapshot.MaybeSnapshot(w.branch)
@@ -184,8 +345,8 @@ func (w *Writer) advanceHead(lsn int64) error {
 }
 
 // normalizeDoc round-trips the document through BSON (a private, normalized
-// copy) and ensures it has an _id.
-func normalizeDoc(doc bson.M) (bson.M, interface{}, error) {
+// copy) and ensures it has an _id, generating one with ids if missing.
+func normalizeDoc(doc bson.M, ids IDGenerator) (bson.M, interface{}, error) {
 	raw, err := bson.Marshal(doc)
 	if err != nil {
 		return nil, nil, fmt.Errorf("document must be BSON-marshallable: %w", err)
@@ -196,7 +357,7 @@ func normalizeDoc(doc bson.M) (bson.M, interface{}, error) {
 	}
 	id, exists := normalized["_id"]
 	if !exists || id == nil {
-		id = primitive.NewObjectID()
+		id = ids.NewID()
 		normalized["_id"] = id
 	}
 	return normalized, id, nil
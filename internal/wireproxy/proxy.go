@@ -35,13 +35,14 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net"
 	"strings"
 	"sync"
 	"time"
 
 	branchwal "github.com/argon-lab/argon/internal/branch/wal"
+	"github.com/argon-lab/argon/internal/logging"
 	"go.mongodb.org/mongo-driver/bson"
 )
 
@@ -111,7 +112,7 @@ func (p *Proxy) handle(ctx context.Context, client net.Conn) {
 
 	upstream, err := net.Dial("tcp", p.upstreamAddr)
 	if err != nil {
-		log.Printf("wireproxy: upstream dial failed: %v", err)
+		slog.Error("wireproxy: upstream dial failed", slog.String("upstream", p.upstreamAddr), logging.Err(err))
 		return
 	}
 	defer func() { _ = upstream.Close() }()
@@ -146,7 +147,7 @@ func (p *Proxy) pump(client net.Conn, upstream net.Conn) {
 		}
 		length := int(binary.LittleEndian.Uint32(header))
 		if length < 16 || length > maxMessageSize {
-			log.Printf("wireproxy: dropping connection with invalid message length %d", length)
+			slog.Warn("wireproxy: dropping connection with invalid message length", slog.Int("length", length))
 			return
 		}
 		message := make([]byte, length)
@@ -176,7 +177,7 @@ func (p *Proxy) rewrite(message []byte) (forward []byte, errReply []byte) {
 		// Should not happen — we strip compression from handshakes — but a
 		// compressed alias command would slip through unrewritten, so pass
 		// it along and let the server reject the unknown database loudly.
-		log.Printf("wireproxy: unexpected OP_COMPRESSED message; compression negotiation slipped through")
+		slog.Warn("wireproxy: unexpected OP_COMPRESSED message; compression negotiation slipped through")
 		return message, nil
 	}
 	if opCode != opMsg {
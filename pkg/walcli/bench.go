@@ -0,0 +1,168 @@
+package walcli
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// BenchConfig sizes a benchmark run. Workload shapes and the random
+// choices inside them (documents, time-travel LSNs) derive from Seed, so
+// two runs with the same config do the same work.
+type BenchConfig struct {
+	Project    string `json:"project"`    // throwaway project, created and deleted by the run
+	Docs       int    `json:"docs"`       // documents inserted, and so materialized
+	BatchSize  int    `json:"batch_size"` // documents per insert batch
+	Iterations int    `json:"iterations"` // repetitions of the read and branch workloads
+	Seed       int64  `json:"seed"`
+}
+
+// BenchResult summarizes one workload. Latencies are per operation.
+type BenchResult struct {
+	Ops       int     `json:"ops"`
+	OpsPerSec float64 `json:"ops_per_sec"`
+	P50Ms     float64 `json:"p50_ms"`
+	P95Ms     float64 `json:"p95_ms"`
+	P99Ms     float64 `json:"p99_ms"`
+	MaxMs     float64 `json:"max_ms"`
+}
+
+// BenchReport is the machine-readable output of RunBenchmark.
+type BenchReport struct {
+	Config    BenchConfig             `json:"config"`
+	StartedAt time.Time               `json:"started_at"`
+	Workloads map[string]*BenchResult `json:"workloads"`
+}
+
+// Benchmark workload names, the keys of BenchReport.Workloads.
+const (
+	BenchBulkInsert  = "bulk_insert"
+	BenchMaterialize = "materialize"
+	BenchTimeTravel  = "time_travel"
+	BenchBranch      = "branch_create"
+)
+
+// RunBenchmark runs the standard workloads against a throwaway project:
+// batched inserts (one op per document), full materialization of the
+// inserted collection, time travel to random LSNs, and branch creation.
+// The project is deleted afterwards, also when a workload fails.
+func (s *Services) RunBenchmark(ctx context.Context, cfg BenchConfig) (*BenchReport, error) {
+	if cfg.Project == "" || cfg.Docs <= 0 || cfg.BatchSize <= 0 || cfg.Iterations <= 0 {
+		return nil, fmt.Errorf("benchmark needs a project name and positive docs, batch size and iterations")
+	}
+	project, err := s.Projects.CreateProject(cfg.Project)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create benchmark project: %w", err)
+	}
+	defer func() { _ = s.Projects.DeleteProject(project.ID) }()
+
+	rng := rand.New(rand.NewSource(cfg.Seed))
+	report := &BenchReport{Config: cfg, StartedAt: time.Now().UTC(), Workloads: make(map[string]*BenchResult)}
+	const collection = "bench"
+
+	writer, err := s.WriterFor(cfg.Project, "main")
+	if err != nil {
+		return nil, err
+	}
+	var (
+		latencies []time.Duration
+		lsns      []int64
+		total     time.Duration
+	)
+	for start := 0; start < cfg.Docs; start += cfg.BatchSize {
+		n := cfg.BatchSize
+		if start+n > cfg.Docs {
+			n = cfg.Docs - start
+		}
+		docs := make([]bson.M, n)
+		for i := range docs {
+			docs[i] = bson.M{"_id": fmt.Sprintf("doc-%06d", start+i), "n": rng.Int63(), "tag": fmt.Sprintf("t%d", rng.Intn(16))}
+		}
+		began := time.Now()
+		batch, err := writer.PutMany(ctx, collection, docs)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", BenchBulkInsert, err)
+		}
+		took := time.Since(began)
+		total += took
+		for range docs {
+			latencies = append(latencies, took/time.Duration(n))
+		}
+		lsns = append(lsns, batch...)
+	}
+	report.Workloads[BenchBulkInsert] = summarize(latencies, total)
+
+	main, err := s.Branches.GetBranch(project.ID, "main")
+	if err != nil {
+		return nil, err
+	}
+	report.Workloads[BenchMaterialize], err = timeOps(cfg.Iterations, func(int) error {
+		state, err := s.Materializer.MaterializeCollection(main, collection)
+		if err == nil && len(state) != cfg.Docs {
+			err = fmt.Errorf("materialized %d documents, want %d", len(state), cfg.Docs)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", BenchMaterialize, err)
+	}
+
+	targets := make([]int64, cfg.Iterations)
+	for i := range targets {
+		targets[i] = lsns[rng.Intn(len(lsns))]
+	}
+	report.Workloads[BenchTimeTravel], err = timeOps(cfg.Iterations, func(i int) error {
+		_, err := s.TimeTravel.MaterializeAtLSN(main, collection, targets[i])
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", BenchTimeTravel, err)
+	}
+
+	report.Workloads[BenchBranch], err = timeOps(cfg.Iterations, func(i int) error {
+		_, err := s.Branches.CreateBranch(project.ID, fmt.Sprintf("bench-%d", i), main.ID)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", BenchBranch, err)
+	}
+	return report, nil
+}
+
+// timeOps runs op n times and summarizes the latencies.
+func timeOps(n int, op func(i int) error) (*BenchResult, error) {
+	latencies := make([]time.Duration, 0, n)
+	var total time.Duration
+	for i := 0; i < n; i++ {
+		began := time.Now()
+		if err := op(i); err != nil {
+			return nil, err
+		}
+		took := time.Since(began)
+		total += took
+		latencies = append(latencies, took)
+	}
+	return summarize(latencies, total), nil
+}
+
+func summarize(latencies []time.Duration, total time.Duration) *BenchResult {
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+	pct := func(p float64) float64 {
+		return ms(latencies[int(p*float64(len(latencies)-1))])
+	}
+	res := &BenchResult{Ops: len(latencies)}
+	if len(latencies) == 0 {
+		return res
+	}
+	if total > 0 {
+		res.OpsPerSec = float64(len(latencies)) / total.Seconds()
+	}
+	res.P50Ms, res.P95Ms, res.P99Ms = pct(0.50), pct(0.95), pct(0.99)
+	res.MaxMs = ms(latencies[len(latencies)-1])
+	return res
+}
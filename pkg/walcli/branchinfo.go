@@ -0,0 +1,195 @@
+package walcli
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/argon-lab/argon/internal/wal"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Branch states reported by BranchInfo.
+const (
+	BranchStateActive  = "active"
+	BranchStateLive    = "live"    // checked out into a physical database
+	BranchStateSandbox = "sandbox" // ephemeral, within its TTL
+	BranchStateExpired = "expired" // sandbox past its TTL, awaiting the sweep
+)
+
+// BranchInfo consolidates what an operator checks about one branch.
+type BranchInfo struct {
+	Project     string         `json:"project"`
+	Branch      string         `json:"branch"`
+	BranchID    string         `json:"branch_id"`
+	Parent      string         `json:"parent,omitempty"`
+	HeadLSN     int64          `json:"head_lsn"`
+	BaseLSN     int64          `json:"base_lsn"`
+	EntryCount  int64          `json:"entry_count"`
+	Collections map[string]int `json:"collections"`
+	Ahead       int64          `json:"ahead"`
+	Behind      int64          `json:"behind"`
+	State       string         `json:"state"`
+	Protected   bool           `json:"protected"` // frozen: read-only until unprotected
+	PhysicalDB  string         `json:"physical_db,omitempty"`
+	ExpiresAt   *time.Time     `json:"expires_at,omitempty"`
+	Discarded   []wal.LSNRange `json:"discarded_ranges,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+	LastActive  *time.Time     `json:"last_activity_at,omitempty"`
+
+	// Sizes profiles each collection's live documents, for capacity
+	// planning.
+	Sizes map[string]DocumentSizeStats `json:"document_sizes"`
+}
+
+// DocumentSizeStats profiles the BSON sizes of a collection's documents at
+// the branch head. Each live document is sized by re-encoding its
+// materialized state, which holds the same fields as the post-image its
+// latest WAL put recorded.
+type DocumentSizeStats struct {
+	Documents  int   `json:"documents"`
+	TotalBytes int64 `json:"total_bytes"`
+	AvgBytes   int64 `json:"avg_bytes"`
+	MaxBytes   int64 `json:"max_bytes"`
+}
+
+// BranchInfo gathers a branch's health summary. Ahead counts the branch's
+// own entries since its fork point; Behind counts the parent's entries
+// written after that fork point, i.e. what a rebase would pick up.
+func (s *Services) BranchInfo(projectName, branchName string) (*BranchInfo, error) {
+	project, err := s.Projects.GetProjectByName(projectName)
+	if err != nil {
+		return nil, fmt.Errorf("project %q not found: %w", projectName, err)
+	}
+	branch, err := s.Branches.GetBranch(project.ID, branchName)
+	if err != nil {
+		return nil, fmt.Errorf("branch %q not found: %w", branchName, err)
+	}
+
+	info := &BranchInfo{
+		Project:     project.Name,
+		Branch:      branch.Name,
+		BranchID:    branch.ID,
+		HeadLSN:     branch.HeadLSN,
+		BaseLSN:     branch.BaseLSN,
+		State:       branchState(branch, time.Now()),
+		Protected:   branch.Protected,
+		PhysicalDB:  branch.PhysicalDB,
+		ExpiresAt:   branch.ExpiresAt,
+		Discarded:   branch.DiscardedRanges,
+		CreatedAt:   branch.CreatedAt,
+		LastActive:  branch.LastActivityAt,
+		Collections: map[string]int{},
+		Sizes:       map[string]DocumentSizeStats{},
+	}
+
+	if info.EntryCount, err = s.WAL.CountBranchEntries(branch, 0, branch.HeadLSN); err != nil {
+		return nil, fmt.Errorf("failed to count entries: %w", err)
+	}
+	if info.Ahead, err = s.WAL.CountBranchEntries(branch, branch.BaseLSN+1, branch.HeadLSN); err != nil {
+		return nil, fmt.Errorf("failed to count entries since fork: %w", err)
+	}
+	if branch.ParentID != "" {
+		parent, err := s.Branches.GetBranchByIDAny(branch.ParentID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get parent branch: %w", err)
+		}
+		info.Parent = parent.Name
+		if info.Behind, err = s.WAL.CountBranchEntries(parent, branch.BaseLSN+1, parent.HeadLSN); err != nil {
+			return nil, fmt.Errorf("failed to count parent entries since fork: %w", err)
+		}
+	}
+
+	state, err := s.Materializer.MaterializeBranch(branch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to materialize branch: %w", err)
+	}
+	for collection, docs := range state {
+		info.Collections[collection] = len(docs)
+		sizes, err := documentSizes(docs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to size collection %s: %w", collection, err)
+		}
+		info.Sizes[collection] = sizes
+	}
+	return info, nil
+}
+
+func documentSizes(docs map[string]bson.M) (DocumentSizeStats, error) {
+	stats := DocumentSizeStats{Documents: len(docs)}
+	for _, doc := range docs {
+		raw, err := bson.Marshal(doc)
+		if err != nil {
+			return stats, err
+		}
+		size := int64(len(raw))
+		stats.TotalBytes += size
+		if size > stats.MaxBytes {
+			stats.MaxBytes = size
+		}
+	}
+	if stats.Documents > 0 {
+		stats.AvgBytes = stats.TotalBytes / int64(stats.Documents)
+	}
+	return stats, nil
+}
+
+func branchState(branch *wal.Branch, now time.Time) string {
+	switch {
+	case branch.IsLive():
+		return BranchStateLive
+	case branch.IsExpired(now):
+		return BranchStateExpired
+	case branch.ExpiresAt != nil:
+		return BranchStateSandbox
+	default:
+		return BranchStateActive
+	}
+}
+
+// WriteBranchInfo renders a branch summary for terminal output.
+func WriteBranchInfo(w io.Writer, info *BranchInfo) {
+	parent := info.Parent
+	if parent == "" {
+		parent = "(root)"
+	}
+	fmt.Fprintf(w, "🌿 %s/%s\n", info.Project, info.Branch)
+	fmt.Fprintf(w, "   State: %s\n", info.State)
+	if info.Protected {
+		fmt.Fprintf(w, "   Protected: yes (read-only)\n")
+	} else {
+		fmt.Fprintf(w, "   Protected: no\n")
+	}
+	if info.PhysicalDB != "" {
+		fmt.Fprintf(w, "   Physical DB: %s\n", info.PhysicalDB)
+	}
+	if info.ExpiresAt != nil {
+		fmt.Fprintf(w, "   Expires: %s\n", info.ExpiresAt.Format("2006-01-02 15:04:05"))
+	}
+	fmt.Fprintf(w, "   Parent: %s\n", parent)
+	fmt.Fprintf(w, "   HEAD LSN: %d\n", info.HeadLSN)
+	fmt.Fprintf(w, "   Base LSN: %d\n", info.BaseLSN)
+	fmt.Fprintf(w, "   Entries: %d\n", info.EntryCount)
+	fmt.Fprintf(w, "   Ahead/Behind parent: %d/%d\n", info.Ahead, info.Behind)
+	if info.LastActive != nil {
+		fmt.Fprintf(w, "   Last activity: %s\n", info.LastActive.Format("2006-01-02 15:04:05"))
+	}
+	if len(info.Discarded) > 0 {
+		fmt.Fprintf(w, "   Discarded ranges: %d\n", len(info.Discarded))
+	}
+
+	names := make([]string, 0, len(info.Collections))
+	for name := range info.Collections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintf(w, "   Collections: %d\n", len(names))
+	for _, name := range names {
+		line := fmt.Sprintf("     %s: %d documents", name, info.Collections[name])
+		if sizes, ok := info.Sizes[name]; ok && sizes.Documents > 0 {
+			line += fmt.Sprintf(" (avg %d B, max %d B)", sizes.AvgBytes, sizes.MaxBytes)
+		}
+		fmt.Fprintln(w, line)
+	}
+}
@@ -0,0 +1,92 @@
+package walcli
+
+import (
+	"fmt"
+)
+
+// ProjectClone is the outcome of CloneProject.
+type ProjectClone struct {
+	ProjectID    string `json:"project_id"`
+	ProjectName  string `json:"project_name"`
+	SourceID     string `json:"source_id"`
+	SourceName   string `json:"source_name"`
+	SourceLSN    int64  `json:"source_lsn"`
+	Documents    int    `json:"documents"`
+	Entries      int    `json:"entries,omitempty"` // history entries, with CloneProjectWithHistory
+	MainBranchID string `json:"main_branch_id"`
+}
+
+// CloneProject creates project newName whose main branch starts from the
+// current state of sourceName's main. Only the state is copied, not the
+// history: the clone's main begins with one seed entry per document. A
+// clone that fails part-way is removed so the name stays free for a retry.
+func (s *Services) CloneProject(sourceName, newName string) (*ProjectClone, error) {
+	return s.cloneProject(sourceName, newName, false)
+}
+
+// CloneProjectWithHistory is CloneProject copying the history too: every
+// entry along the source main's ancestry is replayed into the clone's
+// main, so the clone can be time-travelled like the source. It refuses a
+// source whose early history garbage collection has already reclaimed.
+func (s *Services) CloneProjectWithHistory(sourceName, newName string) (*ProjectClone, error) {
+	return s.cloneProject(sourceName, newName, true)
+}
+
+func (s *Services) cloneProject(sourceName, newName string, history bool) (*ProjectClone, error) {
+	if newName == "" {
+		return nil, fmt.Errorf("new project name must not be empty")
+	}
+	if _, err := s.Projects.GetProjectByName(newName); err == nil {
+		return nil, fmt.Errorf("project %q already exists", newName)
+	} else if !IsNotFound(err) {
+		return nil, err
+	}
+	source, err := s.Projects.GetProjectByName(sourceName)
+	if err != nil {
+		return nil, fmt.Errorf("project %q not found: %w", sourceName, err)
+	}
+	sourceMain, err := s.Branches.GetBranch(source.ID, "main")
+	if err != nil {
+		return nil, fmt.Errorf("source main branch: %w", err)
+	}
+
+	project, err := s.Projects.CreateProject(newName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create project: %w", err)
+	}
+	main, err := s.Branches.GetBranch(project.ID, "main")
+	if err != nil {
+		return nil, s.discardClone(project.ID, "", err)
+	}
+	clone := &ProjectClone{
+		ProjectID:    project.ID,
+		ProjectName:  project.Name,
+		SourceID:     source.ID,
+		SourceName:   source.Name,
+		SourceLSN:    sourceMain.HeadLSN,
+		MainBranchID: main.ID,
+	}
+	if history {
+		clone.Documents, clone.Entries, err = s.Restore.ReplayBranchFromExternal(main, source.ID, sourceMain.ID, sourceMain.HeadLSN)
+	} else {
+		clone.Documents, err = s.Restore.SeedBranchFromExternal(main, source.ID, sourceMain.ID, sourceMain.HeadLSN)
+	}
+	if err != nil {
+		return nil, s.discardClone(project.ID, main.ID, err)
+	}
+	return clone, nil
+}
+
+// discardClone removes a partially created clone, returning cause annotated
+// with any cleanup failure.
+func (s *Services) discardClone(projectID, mainID string, cause error) error {
+	if mainID != "" {
+		if _, err := s.WAL.DeleteBranchEntries(mainID); err != nil {
+			return fmt.Errorf("%w (and failed to remove the partial clone's entries: %v)", cause, err)
+		}
+	}
+	if err := s.Projects.DeleteProject(projectID); err != nil {
+		return fmt.Errorf("%w (and failed to remove the partial clone: %v)", cause, err)
+	}
+	return cause
+}
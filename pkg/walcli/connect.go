@@ -0,0 +1,83 @@
+package walcli
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/argon-lab/argon/internal/logging"
+)
+
+const (
+	// maxConnectBackoff caps the doubling wait between connection attempts.
+	maxConnectBackoff = 30 * time.Second
+	// connectPingTimeout bounds each attempt, so an unreachable deployment
+	// costs one attempt rather than the driver's full server-selection wait.
+	connectPingTimeout = 10 * time.Second
+)
+
+// ConnectRetry is how long NewServices waits for the deployment at startup:
+// up to Attempts pings, the wait between them starting at Interval and
+// doubling (capped at 30s). Orchestrated deployments often start argon
+// before MongoDB is accepting connections. Attempts <= 0 skips the check.
+type ConnectRetry struct {
+	Attempts int
+	Interval time.Duration
+}
+
+// ConnectRetryFromEnv reads ARGON_CONNECT_ATTEMPTS (default 1: fail on the
+// first unreachable ping) and ARGON_CONNECT_RETRY_INTERVAL (a Go duration,
+// default 1s).
+func ConnectRetryFromEnv() (ConnectRetry, error) {
+	retry := ConnectRetry{Attempts: 1, Interval: time.Second}
+	if v := os.Getenv("ARGON_CONNECT_ATTEMPTS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return retry, fmt.Errorf("invalid ARGON_CONNECT_ATTEMPTS %q: %w", v, err)
+		}
+		retry.Attempts = n
+	}
+	if v := os.Getenv("ARGON_CONNECT_RETRY_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return retry, fmt.Errorf("invalid ARGON_CONNECT_RETRY_INTERVAL %q: %w", v, err)
+		}
+		retry.Interval = d
+	}
+	return retry, nil
+}
+
+// Wait calls ping until it succeeds, the attempts run out, or ctx ends,
+// returning the last ping error in the latter two cases.
+func (r ConnectRetry) Wait(ctx context.Context, ping func(context.Context) error) error {
+	wait := r.Interval
+	var err error
+	for attempt := 1; attempt <= r.Attempts; attempt++ {
+		pingCtx, cancel := context.WithTimeout(ctx, connectPingTimeout)
+		err = ping(pingCtx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if attempt == r.Attempts {
+			break
+		}
+		slog.Warn("waiting for MongoDB", slog.Int("attempt", attempt),
+			slog.Int("attempts", r.Attempts), logging.Err(err))
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("gave up waiting for MongoDB: %w", ctx.Err())
+		case <-time.After(wait):
+		}
+		if wait *= 2; wait > maxConnectBackoff {
+			wait = maxConnectBackoff
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("MongoDB unreachable after %d attempt(s): %w", r.Attempts, err)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package walcli
+
+import (
+	"errors"
+
+	"github.com/argon-lab/argon/internal/apikey"
+	"github.com/argon-lab/argon/internal/importer"
+	"github.com/argon-lab/argon/internal/restore"
+	"github.com/argon-lab/argon/internal/timetravel"
+	"github.com/argon-lab/argon/internal/wal"
+)
+
+// IsNotFound reports whether err means a project, branch or import job
+// does not exist, as opposed to a failure while looking it up.
+func IsNotFound(err error) bool {
+	return errors.Is(err, wal.ErrProjectNotFound) || errors.Is(err, wal.ErrBranchNotFound) ||
+		errors.Is(err, importer.ErrJobNotFound)
+}
+
+// IsBranchExists reports whether err means a branch name is already taken
+// in its project.
+func IsBranchExists(err error) bool {
+	return errors.Is(err, wal.ErrBranchExists)
+}
+
+// HasChildBranches reports whether err means a branch cannot be deleted
+// because branches forked from it still exist.
+func HasChildBranches(err error) bool {
+	return errors.Is(err, wal.ErrBranchHasChildren)
+}
+
+// IsMainBranchRename reports whether err means a rename would rename or
+// replace a project's main branch.
+func IsMainBranchRename(err error) bool {
+	return errors.Is(err, wal.ErrMainBranchRename)
+}
+
+// IsBranchProtected reports whether err means a write or reset was
+// refused because the branch is protected.
+func IsBranchProtected(err error) bool {
+	return errors.Is(err, wal.ErrBranchProtected)
+}
+
+// IsOutOfRange reports whether err means a restore target lies outside the
+// branch's range, or names a time the branch has no history at, as opposed
+// to a failure while checking it.
+func IsOutOfRange(err error) bool {
+	return errors.Is(err, restore.ErrTargetOutOfRange) || errors.Is(err, timetravel.ErrNoHistoryAtTime)
+}
+
+// IsTagNotFound reports whether err means a tag or pin name resolves to
+// nothing on the branch, as opposed to a failure while looking it up.
+func IsTagNotFound(err error) bool {
+	return errors.Is(err, timetravel.ErrTagNotFound)
+}
+
+// IsInvalidAPIKey reports whether err means an API key was never issued,
+// as opposed to a failure while checking it.
+func IsInvalidAPIKey(err error) bool {
+	return errors.Is(err, apikey.ErrInvalidKey)
+}
@@ -0,0 +1,21 @@
+package walcli
+
+import (
+	"log/slog"
+	"os"
+
+	"github.com/argon-lab/argon/internal/config"
+	"github.com/argon-lab/argon/internal/logging"
+)
+
+// SetupLogging installs the process logger described by LOG_FORMAT and
+// LOG_LEVEL. Interactive tools pass quiet, which drops service logs below
+// warnings unless LOG_LEVEL asks for them: a command's own output already
+// says what happened.
+func SetupLogging(quiet bool) {
+	cfg := config.Load()
+	if quiet && os.Getenv("LOG_LEVEL") == "" {
+		cfg.LogLevel = slog.LevelWarn
+	}
+	logging.Setup(cfg)
+}
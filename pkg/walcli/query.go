@@ -0,0 +1,242 @@
+package walcli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/argon-lab/argon/internal/materializer"
+	"github.com/argon-lab/argon/internal/mongoexpr"
+	"github.com/argon-lab/argon/internal/timetravel"
+	"github.com/argon-lab/argon/internal/wal"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// OrderDocuments flattens a materialized collection for display. Documents
+// come back ordered by _id unless sortBy names a field ("-field" sorts
+// descending), in which case _id only breaks ties. Either way two identical
+// reads return the same order.
+func OrderDocuments(state map[string]bson.M, sortBy string) []bson.M {
+	docs := materializer.SortedDocuments(state)
+	if field := strings.TrimPrefix(sortBy, "-"); field != "" && field != "_id" {
+		materializer.SortDocuments(docs, field, strings.HasPrefix(sortBy, "-"))
+	} else if sortBy == "-_id" {
+		for i, j := 0, len(docs)-1; i < j; i, j = i+1, j-1 {
+			docs[i], docs[j] = docs[j], docs[i]
+		}
+	}
+	return docs
+}
+
+// DocumentExtJSON renders a document as one line of relaxed extended JSON
+// with its keys sorted, recursively: decoded documents are maps, whose
+// marshalled field order would otherwise change from run to run.
+func DocumentExtJSON(doc bson.M) ([]byte, error) {
+	canonical, err := mongoexpr.Canonicalize(doc)
+	if err != nil {
+		return nil, err
+	}
+	return bson.MarshalExtJSON(canonical, false, false)
+}
+
+// FieldProjection compiles a comma-separated field list — "name,address.city"
+// to include, "-bio,-_id" to exclude — into a projection. An empty list
+// means whole documents (nil). Mixing inclusions and exclusions, other than
+// excluding _id, is an error.
+func FieldProjection(fields string) (*timetravel.Projection, error) {
+	projection := bson.M{}
+	for _, field := range strings.Split(fields, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if strings.HasPrefix(field, "-") {
+			projection[strings.TrimPrefix(field, "-")] = 0
+		} else {
+			projection[field] = 1
+		}
+	}
+	if len(projection) == 0 {
+		return nil, nil
+	}
+	return timetravel.CompileProjection(projection)
+}
+
+// EntryWithDocuments is a WAL entry with its images decoded. Entries
+// serialize without document bodies by default; timeline views opt in.
+type EntryWithDocuments struct {
+	*wal.Entry
+	Document bson.M `json:"document,omitempty"` // post-image of a put
+	Previous bson.M `json:"previous,omitempty"` // pre-image, when recorded
+}
+
+// WithDocuments decodes each entry's post- and pre-image.
+func WithDocuments(entries []*wal.Entry) ([]EntryWithDocuments, error) {
+	out := make([]EntryWithDocuments, len(entries))
+	for i, entry := range entries {
+		withDocs, err := WithDocument(entry)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = withDocs
+	}
+	return out, nil
+}
+
+// WithDocument decodes one entry's post- and pre-image.
+func WithDocument(entry *wal.Entry) (EntryWithDocuments, error) {
+	out := EntryWithDocuments{Entry: entry}
+	if len(entry.PostImage) > 0 {
+		if err := bson.Unmarshal(entry.PostImage, &out.Document); err != nil {
+			return out, fmt.Errorf("failed to decode post-image of LSN %d: %w", entry.LSN, err)
+		}
+	}
+	if len(entry.PreImage) > 0 {
+		if err := bson.Unmarshal(entry.PreImage, &out.Previous); err != nil {
+			return out, fmt.Errorf("failed to decode pre-image of LSN %d: %w", entry.LSN, err)
+		}
+	}
+	return out, nil
+}
+
+// ParseFilter reads a MongoDB query filter written as (relaxed) extended
+// JSON — plain JSON works, and {"$oid": …} or {"$date": …} keep their BSON
+// types. An empty string is the empty filter, which matches everything.
+func ParseFilter(filter string) (bson.M, error) {
+	if strings.TrimSpace(filter) == "" {
+		return bson.M{}, nil
+	}
+	// Decode ordered: an embedded document compared as a value keeps its
+	// field order, which a map would lose.
+	var parsed bson.D
+	if err := bson.UnmarshalExtJSON([]byte(filter), false, &parsed); err != nil {
+		return nil, fmt.Errorf("invalid filter JSON: %w", err)
+	}
+	return filterDocument(parsed), nil
+}
+
+// filterDocument turns a decoded query document into the map filters are
+// written with: logical operators hold further query documents, every
+// other key a field condition.
+func filterDocument(d bson.D) bson.M {
+	m := make(bson.M, len(d))
+	for _, e := range d {
+		switch e.Key {
+		case "$and", "$or", "$nor":
+			m[e.Key] = plainFilter(e.Value)
+		default:
+			m[e.Key] = fieldCondition(e.Value)
+		}
+	}
+	return m
+}
+
+// fieldCondition turns an operator document such as {$gt: 5} into a map
+// and leaves any other value to plainValue. $elemMatch holds a query
+// document (or operators) and $not operators.
+func fieldCondition(v interface{}) interface{} {
+	d, ok := v.(bson.D)
+	if !ok || !isOperatorDocument(d) {
+		return plainValue(v)
+	}
+	ops := make(bson.M, len(d))
+	for _, e := range d {
+		switch e.Key {
+		case "$elemMatch":
+			if cond, isDoc := e.Value.(bson.D); isDoc && !isOperatorDocument(cond) {
+				ops[e.Key] = filterDocument(cond)
+			} else {
+				ops[e.Key] = fieldCondition(e.Value)
+			}
+		case "$not":
+			ops[e.Key] = fieldCondition(e.Value)
+		default:
+			ops[e.Key] = plainValue(e.Value)
+		}
+	}
+	return ops
+}
+
+// plainFilter turns the arrays of a logical operator into slices of query
+// documents.
+func plainFilter(v interface{}) interface{} {
+	switch t := v.(type) {
+	case bson.D:
+		return filterDocument(t)
+	case bson.A:
+		out := make([]interface{}, len(t))
+		for i, val := range t {
+			out[i] = plainFilter(val)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// plainValue turns arrays into slices, keeping embedded documents ordered
+// as bson.D: MongoDB compares them field by field, in order.
+func plainValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case bson.D:
+		for i := range t {
+			t[i].Value = plainValue(t[i].Value)
+		}
+		return t
+	case bson.A:
+		out := make([]interface{}, len(t))
+		for i, val := range t {
+			out[i] = plainValue(val)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+func isOperatorDocument(d bson.D) bool {
+	if len(d) == 0 {
+		return false
+	}
+	for _, e := range d {
+		if !strings.HasPrefix(e.Key, "$") {
+			return false
+		}
+	}
+	return true
+}
+
+// ParsePipeline reads an aggregation pipeline — a JSON array of one-key
+// stages — written as (relaxed) extended JSON, as ParseFilter does.
+// $sort keeps its field order; other stages become plain filters. The
+// pipeline is compiled too, so an unsupported stage or a bad filter is
+// reported here rather than when it runs.
+func ParsePipeline(pipeline string) ([]bson.M, error) {
+	if strings.TrimSpace(pipeline) == "" {
+		return nil, fmt.Errorf("pipeline must not be empty")
+	}
+	var parsed struct {
+		Stages []bson.D `bson:"stages"`
+	}
+	if err := bson.UnmarshalExtJSON([]byte(`{"stages": `+pipeline+`}`), false, &parsed); err != nil {
+		return nil, fmt.Errorf("invalid pipeline JSON: want an array of stage documents: %w", err)
+	}
+	stages := make([]bson.M, len(parsed.Stages))
+	for i, stage := range parsed.Stages {
+		stages[i] = make(bson.M, len(stage))
+		for _, e := range stage {
+			if e.Key == "$sort" {
+				stages[i][e.Key] = e.Value
+				continue
+			}
+			if doc, ok := e.Value.(bson.D); ok {
+				stages[i][e.Key] = filterDocument(doc)
+				continue
+			}
+			stages[i][e.Key] = plainValue(e.Value)
+		}
+	}
+	if _, err := materializer.CompilePipeline(stages); err != nil {
+		return nil, err
+	}
+	return stages, nil
+}
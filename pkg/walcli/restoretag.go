@@ -0,0 +1,102 @@
+package walcli
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/argon-lab/argon/internal/pin"
+	"github.com/argon-lab/argon/internal/restore"
+	"github.com/argon-lab/argon/internal/timetravel"
+	"github.com/argon-lab/argon/internal/wal"
+)
+
+// TagRestore is the outcome of RestoreToTag: the preview of what the reset
+// discards, and — unless it was a dry run — the branch after the reset.
+type TagRestore struct {
+	Tag     string
+	Preview *restore.RestorePreview
+	Branch  *wal.Branch // nil on a dry run
+}
+
+// RestoreToTag resets a branch to the LSN a tag references (see TagLSN),
+// or only previews the reset when dryRun is set. The tag must name an LSN
+// within the branch's current range [base, head]: a tag above a reset head
+// still reads its pinned state, but resetting "forward" into a discarded
+// range is not a reset. A protected branch is reset only with force.
+func (s *Services) RestoreToTag(projectName, branchName, tag string, dryRun, force bool) (*TagRestore, error) {
+	project, err := s.Projects.GetProjectByName(projectName)
+	if err != nil {
+		return nil, fmt.Errorf("project %q not found: %w", projectName, err)
+	}
+	if branchName == "" {
+		branchName = "main"
+	}
+	branch, err := s.Branches.GetBranch(project.ID, branchName)
+	if err != nil {
+		return nil, fmt.Errorf("branch %q not found: %w", branchName, err)
+	}
+	lsn, err := s.TagLSN(branch, tag)
+	if err != nil {
+		return nil, err
+	}
+	if lsn < branch.BaseLSN || lsn > branch.HeadLSN {
+		return nil, fmt.Errorf("%w: tag %q (LSN %d) is outside branch range [%d, %d]",
+			restore.ErrTargetOutOfRange, tag, lsn, branch.BaseLSN, branch.HeadLSN)
+	}
+
+	preview, err := s.Restore.GetRestorePreview(branch.ID, lsn)
+	if err != nil {
+		return nil, err
+	}
+	result := &TagRestore{Tag: tag, Preview: preview}
+	if dryRun {
+		return result, nil
+	}
+	reset := s.Restore.ResetBranchToLSN
+	if force {
+		reset = s.Restore.ForceResetBranchToLSN
+	}
+	if result.Branch, err = reset(branch.ID, lsn); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ErrAmbiguousTag is wrapped by TagLSN when a name is both a branch tag and
+// a project pin.
+var ErrAmbiguousTag = errors.New("ambiguous tag")
+
+// TagLSN resolves a tag name on a branch to its LSN: the branch's own tag
+// (argon tag create) or a project pin of that name on the branch — a pin
+// is a tag that also survives garbage collection. A name that is both is
+// ambiguous and refused. A name that resolves to nothing on the branch
+// wraps timetravel.ErrTagNotFound; an ambiguous one wraps ErrAmbiguousTag.
+func (s *Services) TagLSN(branch *wal.Branch, name string) (int64, error) {
+	var tag *timetravel.Tag
+	if s.TimeTravel != nil {
+		t, err := s.TimeTravel.GetTag(branch.ID, name)
+		if err != nil && !errors.Is(err, timetravel.ErrTagNotFound) {
+			return 0, fmt.Errorf("failed to look up tag %q: %w", name, err)
+		}
+		tag = t
+	}
+	var p *pin.Pin
+	if s.Pins != nil {
+		found, err := s.Pins.Get(branch.ProjectID, name)
+		if err != nil && !errors.Is(err, pin.ErrPinNotFound) {
+			return 0, fmt.Errorf("failed to look up pin %q: %w", name, err)
+		}
+		p = found
+	}
+	switch {
+	case tag != nil && p != nil:
+		return 0, fmt.Errorf("%w: %q is both a tag on branch %q and a project pin: delete one to restore to the other", ErrAmbiguousTag, name, branch.Name)
+	case tag != nil:
+		return tag.LSN, nil
+	case p == nil:
+		return 0, fmt.Errorf("%w: %q on branch %q", timetravel.ErrTagNotFound, name, branch.Name)
+	case p.BranchID != branch.ID:
+		return 0, fmt.Errorf("%w: %q pins branch %q, not %q", timetravel.ErrTagNotFound, name, p.BranchName, branch.Name)
+	}
+	return p.LSN, nil
+}
@@ -2,20 +2,29 @@ package walcli
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"log/slog"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/argon-lab/argon/internal/apikey"
 	branchwal "github.com/argon-lab/argon/internal/branch/wal"
 	"github.com/argon-lab/argon/internal/checkout"
+	"github.com/argon-lab/argon/internal/compact"
+	"github.com/argon-lab/argon/internal/exporter"
 	"github.com/argon-lab/argon/internal/gc"
 	"github.com/argon-lab/argon/internal/importer"
 	"github.com/argon-lab/argon/internal/ingest"
+	"github.com/argon-lab/argon/internal/logging"
 	"github.com/argon-lab/argon/internal/materializer"
 	"github.com/argon-lab/argon/internal/merge"
 	"github.com/argon-lab/argon/internal/migrate"
 	"github.com/argon-lab/argon/internal/pin"
 	projectwal "github.com/argon-lab/argon/internal/project/wal"
+	"github.com/argon-lab/argon/internal/redact"
 	"github.com/argon-lab/argon/internal/restore"
 	"github.com/argon-lab/argon/internal/sandbox"
 	"github.com/argon-lab/argon/internal/snapshot"
@@ -24,8 +33,10 @@ import (
 	"github.com/argon-lab/argon/internal/walwriter"
 	"github.com/argon-lab/argon/internal/wireproxy"
 	"github.com/argon-lab/argon/internal/wal"
+	"github.com/argon-lab/argon/internal/walexport"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
 // Services holds all WAL-related services for CLI use
@@ -46,33 +57,137 @@ type Services struct {
 	Merge        *merge.Service
 	Sandbox      *sandbox.Service
 	Pins         *pin.Service
+	Export       *walexport.Service
+	Dump         *exporter.Service
+	Compact      *compact.Service
+	APIKeys      *apikey.Service
 	Monitor      *wal.Monitor
-	MongoURI     string
+	// ReadMaterializer and ReadTimeTravel serve read-only queries. They
+	// are Materializer and TimeTravel unless UseReadPreference moved WAL
+	// reads to a secondary.
+	ReadMaterializer *materializer.Service
+	ReadTimeTravel   *timetravel.Service
+	// Reserved is the top-level key policy every writer from WriterFor
+	// enforces (walwriter.DefaultReserved unless configured).
+	Reserved walwriter.Reserved
+	MongoURI string
 	// Client is the deployment connection, exposed for tools that read
 	// physical branch databases (e.g. convergence verification).
 	Client *mongo.Client
+
+	// importProgress receives import progress; see SetImportProgress.
+	importProgress func(ImportProgress)
 }
 
 // NewServices creates all WAL services against the deployment named by
-// MONGODB_URI (default localhost) and the standard argon_wal metadata
-// database.
+// MONGODB_URI (default localhost) and the metadata database named by
+// ARGON_WAL_DATABASE (default argon_wal).
 func NewServices() (*Services, error) {
 	mongoURI := os.Getenv("MONGODB_URI")
 	if mongoURI == "" {
 		mongoURI = "mongodb://localhost:27017"
 	}
-	return NewServicesAt(mongoURI, "argon_wal")
+	dbName := os.Getenv("ARGON_WAL_DATABASE")
+	if dbName == "" {
+		dbName = "argon_wal"
+	}
+	retry, err := ConnectRetryFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	services, err := newServicesAt(mongoURI, dbName, retry)
+	if err != nil {
+		return nil, err
+	}
+	if v, ok := os.LookupEnv("ARGON_RESERVED_FIELDS"); ok {
+		services.Reserved = parseReserved(v)
+	}
+	// ARGON_MAX_CONCURRENT_IMPORTS bounds concurrent imports; excess ones
+	// queue, or fail fast when ARGON_IMPORT_FAIL_FAST is true.
+	if v := os.Getenv("ARGON_MAX_CONCURRENT_IMPORTS"); v != "" {
+		max, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ARGON_MAX_CONCURRENT_IMPORTS %q: %w", v, err)
+		}
+		failFast := false
+		if v := os.Getenv("ARGON_IMPORT_FAIL_FAST"); v != "" {
+			if failFast, err = strconv.ParseBool(v); err != nil {
+				return nil, fmt.Errorf("invalid ARGON_IMPORT_FAIL_FAST %q: %w", v, err)
+			}
+		}
+		services.Importer.SetConcurrencyLimit(max, !failFast)
+	}
+	// Monitor alerts go to a generic webhook and/or a Slack webhook.
+	if v := os.Getenv("ARGON_ALERT_WEBHOOK_URL"); v != "" {
+		services.Monitor.AddAlertSink(wal.NewWebhookSink(v))
+	}
+	if v := os.Getenv("ARGON_ALERT_SLACK_WEBHOOK_URL"); v != "" {
+		services.Monitor.AddAlertSink(wal.NewSlackSink(v))
+	}
+	// ARGON_PROTECT_MAIN creates new projects with a read-only main.
+	if v := os.Getenv("ARGON_PROTECT_MAIN"); v != "" {
+		protect, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ARGON_PROTECT_MAIN %q: %w", v, err)
+		}
+		services.Projects.SetProtectMain(protect)
+	}
+	// WAL_COMPRESSION picks how appended images are compressed (zstd by
+	// default); entries written under any setting still read.
+	if v := os.Getenv("WAL_COMPRESSION"); v != "" {
+		t, err := wal.ParseCompressionType(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid WAL_COMPRESSION %q: %w", v, err)
+		}
+		if err := services.WAL.SetCompression(t); err != nil {
+			return nil, err
+		}
+	}
+	// ARGON_READ_PREFERENCE moves read-only queries off the primary.
+	if v := os.Getenv("ARGON_READ_PREFERENCE"); v != "" {
+		if err := services.UseReadPreference(v); err != nil {
+			return nil, fmt.Errorf("invalid ARGON_READ_PREFERENCE %q: %w", v, err)
+		}
+	}
+	return services, nil
+}
+
+// parseReserved reads a comma-separated reserved-key list; a trailing "*"
+// marks a prefix ("_argon,$*" is the default policy). An empty list
+// disables the check.
+func parseReserved(v string) walwriter.Reserved {
+	var r walwriter.Reserved
+	for _, key := range strings.Split(v, ",") {
+		key = strings.TrimSpace(key)
+		switch {
+		case key == "":
+		case strings.HasSuffix(key, "*"):
+			r.Prefixes = append(r.Prefixes, strings.TrimSuffix(key, "*"))
+		default:
+			r.Fields = append(r.Fields, key)
+		}
+	}
+	return r
 }
 
 // NewServicesAt creates all WAL services against an explicit deployment and
 // metadata database — for embedding (REST server, tests) where the global
 // environment must not decide.
 func NewServicesAt(mongoURI, dbName string) (*Services, error) {
+	return newServicesAt(mongoURI, dbName, ConnectRetry{})
+}
+
+func newServicesAt(mongoURI, dbName string, retry ConnectRetry) (*Services, error) {
 	// Connect to MongoDB
 	ctx := context.Background()
 	client, err := mongo.Connect(ctx, options.Client().ApplyURI(mongoURI))
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to MongoDB: %w", err)
+		return nil, fmt.Errorf("failed to connect to MongoDB: %w", redact.Error(err, mongoURI))
+	}
+	ping := func(ctx context.Context) error { return redact.Error(client.Ping(ctx, nil), mongoURI) }
+	if err := retry.Wait(ctx, ping); err != nil {
+		_ = client.Disconnect(ctx)
+		return nil, err
 	}
 
 	db := client.Database(dbName)
@@ -95,8 +210,15 @@ func NewServicesAt(mongoURI, dbName string) (*Services, error) {
 
 	materializerService := materializer.NewService(walService, branchService)
 	timeTravelService := timetravel.NewService(walService, materializerService)
+	if err := timeTravelService.EnableTags(db, branchService); err != nil {
+		return nil, err
+	}
 	restoreService := restore.NewService(walService, branchService, materializerService, timeTravelService)
+	restoreService.SetProjectLookup(projectService)
 	importerService := importer.NewImportService(walService, projectService, branchService)
+	if err := importerService.EnableJobs(db); err != nil {
+		return nil, err
+	}
 	migrateService, err := migrate.NewService(db, branchService, materializerService)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create migration service: %w", err)
@@ -117,6 +239,7 @@ func NewServicesAt(mongoURI, dbName string) (*Services, error) {
 	gcService := gc.NewService(walService, branchService, snapshotService)
 	checkoutService := checkout.NewService(client, db, branchService, materializerService)
 	ingestService := ingest.NewService(client, db, walService, branchService)
+	ingestService.SetProjectLookup(projectService)
 	undoService := undo.NewService(walService, branchService, client)
 	mergeService := merge.NewService(db, walService, branchService, materializerService, client)
 	sandboxService := sandbox.NewService(branchService, checkoutService)
@@ -124,22 +247,41 @@ func NewServicesAt(mongoURI, dbName string) (*Services, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to create pin service: %w", err)
 	}
-	// Pinned history must survive GC, and pinned branches must survive
-	// deletion.
+	apiKeyService, err := apikey.NewService(db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create API key service: %w", err)
+	}
+	// Pinned history must survive GC and pruning, and pinned branches must
+	// survive deletion.
 	gcService.SetPinLookup(pinService.LSNsForBranch)
+	walService.SetPinLookup(pinService.LSNsForBranch)
+	walService.SetBranchLister(branchService.ListBranchesAny)
 	branchService.SetDeleteGuard(pinService.RequireNoPins)
+	// Pins record their branch's name; keep it current across renames.
+	branchService.SetRenameHook(func(branchID, newName string) {
+		if err := pinService.RenameBranch(branchID, newName); err != nil {
+			slog.Warn("failed to rename branch on its pins",
+				slog.String(logging.KeyBranchID, branchID), logging.Err(err))
+		}
+	})
 	// Snapshot immediately after imports: an imported history is otherwise
 	// pure linear replay until something trips the auto-snapshot threshold.
 	importerService.SetImportedHook(func(branch *wal.Branch) {
 		if _, err := snapshotService.CreateSnapshot(context.Background(), branch.ID, branch.HeadLSN); err != nil {
-			fmt.Printf("Warning: post-import snapshot failed for branch %s: %v\n", branch.ID, err)
+			slog.Warn("post-import snapshot failed",
+				slog.String(logging.KeyBranchID, branch.ID), slog.Int64(logging.KeyLSN, branch.HeadLSN), logging.Err(err))
 		}
 	})
 	// Reclaim a deleted branch's WAL entries and snapshots. Safe because
 	// regular deletion refuses branches with children.
 	branchService.SetDeleteHook(func(branchID string) {
 		if _, _, _, err := gcService.ReclaimDeletedBranch(context.Background(), branchID); err != nil {
-			fmt.Printf("Warning: failed to reclaim storage for branch %s: %v\n", branchID, err)
+			slog.Warn("failed to reclaim deleted branch storage",
+				slog.String(logging.KeyBranchID, branchID), logging.Err(err))
+		}
+		if err := timeTravelService.DeleteBranchTags(branchID); err != nil {
+			slog.Warn("failed to delete deleted branch tags",
+				slog.String(logging.KeyBranchID, branchID), logging.Err(err))
 		}
 	})
 
@@ -157,6 +299,14 @@ func NewServicesAt(mongoURI, dbName string) (*Services, error) {
 		},
 	}
 	monitor := wal.NewMonitor(wal.GlobalMetrics, monitorConfig)
+	monitor.SetPinger(ping)
+	alertStore, err := wal.NewAlertStore(db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create alert store: %w", err)
+	}
+	if err := monitor.SetAlertStore(alertStore); err != nil {
+		return nil, err
+	}
 	monitor.Start()
 
 	return &Services{
@@ -176,12 +326,45 @@ func NewServicesAt(mongoURI, dbName string) (*Services, error) {
 		Merge:        mergeService,
 		Sandbox:      sandboxService,
 		Pins:         pinService,
+		Export:       walexport.NewService(walService),
+		Dump:         exporter.NewService(materializerService),
+		Compact:      compact.NewService(walService, branchService, materializerService),
+		APIKeys:      apiKeyService,
 		Monitor:      monitor,
+		Reserved:     walwriter.DefaultReserved,
 		MongoURI:     mongoURI,
 		Client:       client,
+
+		ReadMaterializer: materializerService,
+		ReadTimeTravel:   timeTravelService,
 	}, nil
 }
 
+// UseReadPreference routes ReadMaterializer and ReadTimeTravel through a
+// WAL view with the given read preference mode ("secondaryPreferred",
+// "nearest", ...), so heavy dashboard queries stay off the primary that
+// serves writes. Writes, and reads that precede a write, keep using the
+// primary. Each read waits for its secondary to catch up with the primary
+// as of the read (see wal.Service.WithReadPreference), so a state labeled
+// with a head LSN read from the primary has all of that head's entries.
+func (s *Services) UseReadPreference(mode string) error {
+	m, err := readpref.ModeFromString(mode)
+	if err != nil {
+		return err
+	}
+	rp, err := readpref.New(m)
+	if err != nil {
+		return err
+	}
+	view, err := s.WAL.WithReadPreference(rp)
+	if err != nil {
+		return err
+	}
+	s.ReadMaterializer = s.Materializer.WithWAL(view)
+	s.ReadTimeTravel = timetravel.NewService(view, s.ReadMaterializer)
+	return nil
+}
+
 // WriterFor returns a programmatic writer for a branch — the public write
 // entry point for tools and benchmarks outside this module (which cannot
 // import internal packages but can call methods on the returned value).
@@ -200,9 +383,24 @@ func (s *Services) WriterFor(projectName, branchName string) (*walwriter.Writer,
 	}
 	writer := walwriter.New(s.WAL, s.Branches, s.Materializer, branch)
 	writer.SetAutoSnapshotter(s.Snapshots)
+	writer.SetReserved(s.Reserved)
+	writer.SetCapture(project.Capture)
 	return writer, nil
 }
 
+// SubscribeEntries streams entries as they are appended to a project,
+// narrowed to one branch and collection when those are non-empty. Release
+// the subscription with WAL.Unsubscribe.
+func (s *Services) SubscribeEntries(projectID, branchID, collection string) *wal.Subscription {
+	return s.WAL.Subscribe(wal.StreamFilter{ProjectID: projectID, BranchID: branchID, Collection: collection}, 0)
+}
+
+// AlertHistory wraps Monitor.GetAlertHistory for the API module, which
+// cannot name the alert level type.
+func (s *Services) AlertHistory(since time.Time, level string) ([]wal.Alert, error) {
+	return s.Monitor.GetAlertHistory(since, wal.AlertLevel(level))
+}
+
 // BuildUndoPlan and ApplyUndoPlan wrap the undo service for CLI use (the
 // cli module cannot import internal packages).
 func (s *Services) BuildUndoPlan(branchID string, fromLSN, toLSN int64, actor string) (*undo.Plan, error) {
@@ -240,26 +438,94 @@ func (s *Services) RunGC(ctx context.Context, projectID string, retention time.D
 	return s.GC.RunProject(ctx, projectID, gc.Config{RetentionWindow: retention, DryRun: dryRun})
 }
 
-// ImportPreview wraps the importer preview functionality for CLI use
-func (s *Services) ImportPreview(ctx context.Context, mongoURI, databaseName string) (interface{}, error) {
-	return s.Importer.PreviewImport(ctx, mongoURI, databaseName)
+// ImportPreview wraps the importer preview functionality for CLI use.
+// include and exclude are collection glob patterns, as for ImportDatabase.
+func (s *Services) ImportPreview(ctx context.Context, mongoURI, databaseName string, include, exclude []string) (interface{}, error) {
+	return s.Importer.PreviewImport(ctx, mongoURI, databaseName, include, exclude)
 }
 
-// ImportDatabase wraps the importer database functionality for CLI use
-func (s *Services) ImportDatabase(ctx context.Context, mongoURI, databaseName, projectName string, dryRun bool, batchSize int) (interface{}, error) {
+// ImportDatabase wraps the importer database functionality for CLI use.
+// checkpoint is the JSON checkpoint of an incomplete import to resume, or
+// nil to start a new one. include and exclude select collections by glob
+// pattern; empty include imports every collection. A cancelled import returns its partial result
+// (marked incomplete, with the checkpoint) alongside the error.
+func (s *Services) ImportDatabase(ctx context.Context, mongoURI, databaseName, projectName string, dryRun bool, batchSize int, sourceOrder string, includeIndexes bool, concurrency int, include, exclude []string, checkpoint []byte) (interface{}, error) {
 	// Use a map to avoid importing the internal types
 	opts := map[string]interface{}{
-		"mongo_uri":     mongoURI,
-		"database_name": databaseName,
-		"project_name":  projectName,
-		"dry_run":       dryRun,
-		"batch_size":    batchSize,
+		"mongo_uri":       mongoURI,
+		"database_name":   databaseName,
+		"project_name":    projectName,
+		"dry_run":         dryRun,
+		"batch_size":      batchSize,
+		"source_order":    sourceOrder,
+		"include_indexes": includeIndexes,
+		"concurrency":     concurrency,
+		"include":         include,
+		"exclude":         exclude,
+		"resume_from":     checkpoint,
 	}
 	
 	// Create a struct that matches the internal ImportOptions
 	return s.callImportDatabase(ctx, opts)
 }
 
+// ImportProgress mirrors importer.ImportProgress for the CLI module.
+type ImportProgress struct {
+	Collection       string
+	Collections      int
+	TotalCollections int
+	Documents        int64
+	TotalDocuments   int64
+	ETA              time.Duration
+	DryRun           bool
+}
+
+// SetImportProgress reports the progress of the imports and resumes these
+// services run to fn.
+func (s *Services) SetImportProgress(fn func(ImportProgress)) {
+	s.importProgress = fn
+}
+
+// progressFn adapts the SetImportProgress callback to the importer.
+func (s *Services) progressFn() func(importer.ImportProgress) {
+	fn := s.importProgress
+	if fn == nil {
+		return nil
+	}
+	return func(p importer.ImportProgress) {
+		fn(ImportProgress{
+			Collection:       p.Collection,
+			Collections:      p.Collections,
+			TotalCollections: p.TotalCollections,
+			Documents:        p.Documents,
+			TotalDocuments:   p.TotalDocuments,
+			ETA:              p.ETA,
+			DryRun:           p.DryRun,
+		})
+	}
+}
+
+// ResumeImport continues the failed or interrupted import that created a
+// project, from its persisted job. mongoURI may be empty if the original
+// source URI carried no password. force takes over a job that still looks
+// running. Like ImportDatabase, a cancelled resume returns its partial
+// result alongside the error.
+func (s *Services) ResumeImport(ctx context.Context, projectName, mongoURI string, force bool) (interface{}, error) {
+	project, err := s.Projects.GetProjectByName(projectName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get project '%s': %w", projectName, err)
+	}
+	job, err := s.Importer.GetJobByProject(project.ID)
+	if err != nil {
+		return nil, fmt.Errorf("no import to resume for project '%s': %w", projectName, err)
+	}
+	result, err := s.Importer.ResumeImport(ctx, job.ID, mongoURI, force, s.progressFn())
+	if result == nil {
+		return nil, err
+	}
+	return result, err
+}
+
 // callImportDatabase creates the proper options struct and calls the service
 func (s *Services) callImportDatabase(ctx context.Context, opts map[string]interface{}) (interface{}, error) {
 	// Import the internal package here where it's allowed
@@ -269,7 +535,26 @@ func (s *Services) callImportDatabase(ctx context.Context, opts map[string]inter
 		ProjectName:  opts["project_name"].(string),
 		DryRun:       opts["dry_run"].(bool),
 		BatchSize:    opts["batch_size"].(int),
+		SourceOrder:  opts["source_order"].(string),
+		Concurrency:  opts["concurrency"].(int),
+		ProgressFn:   s.progressFn(),
+
+		IncludeCollections: opts["include"].([]string),
+		ExcludeCollections: opts["exclude"].([]string),
 	}
-	
-	return s.Importer.ImportDatabase(ctx, importOpts)
+	includeIndexes := opts["include_indexes"].(bool)
+	importOpts.IncludeIndexes = &includeIndexes
+	if raw := opts["resume_from"].([]byte); len(raw) > 0 {
+		var checkpoint importer.ImportCheckpoint
+		if err := json.Unmarshal(raw, &checkpoint); err != nil {
+			return nil, fmt.Errorf("invalid import checkpoint: %w", err)
+		}
+		importOpts.ResumeFrom = &checkpoint
+	}
+
+	result, err := s.Importer.ImportDatabase(ctx, importOpts)
+	if result == nil {
+		return nil, err
+	}
+	return result, err
 }
@@ -0,0 +1,150 @@
+package wal_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/argon-lab/argon/internal/materializer"
+	"github.com/argon-lab/argon/internal/walwriter"
+	"github.com/argon-lab/argon/pkg/walcli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// sampleOrders are orders in three statuses, with integer and float totals.
+func sampleOrders() []bson.M {
+	return []bson.M{
+		{"_id": "o1", "status": "paid", "total": int32(30), "region": "eu"},
+		{"_id": "o2", "status": "open", "total": int32(5), "region": "us"},
+		{"_id": "o3", "status": "paid", "total": int64(12), "region": "us"},
+		{"_id": "o4", "status": "open", "total": 7.5, "region": "eu"},
+		{"_id": "o5", "status": "refunded", "total": int32(20), "region": "eu"},
+		{"_id": "o6", "status": "open", "total": "n/a", "region": "us"},
+	}
+}
+
+func runPipeline(t *testing.T, docs []bson.M, pipeline ...bson.M) []bson.M {
+	t.Helper()
+	p, err := materializer.CompilePipeline(pipeline)
+	require.NoError(t, err)
+	out, err := p.Run(docs)
+	require.NoError(t, err)
+	return out
+}
+
+func TestAggregate_GroupOrdersByStatus(t *testing.T) {
+	out := runPipeline(t, sampleOrders(), bson.M{"$group": bson.M{
+		"_id":     "$status",
+		"revenue": bson.M{"$sum": "$total"},
+		"average": bson.M{"$avg": "$total"},
+		"orders":  bson.M{"$count": bson.M{}},
+		"ones":    bson.M{"$sum": 1},
+	}})
+	// Groups come out in _id order. Non-numeric totals are skipped by
+	// $sum and $avg but still counted by $count; a float makes the sum a
+	// float.
+	assert.Equal(t, []bson.M{
+		{"_id": "open", "revenue": 12.5, "average": 6.25, "orders": int64(3), "ones": int64(3)},
+		{"_id": "paid", "revenue": int64(42), "average": 21.0, "orders": int64(2), "ones": int64(2)},
+		{"_id": "refunded", "revenue": int64(20), "average": 20.0, "orders": int64(1), "ones": int64(1)},
+	}, out)
+}
+
+func TestAggregate_MatchGroupSort(t *testing.T) {
+	out := runPipeline(t, sampleOrders(),
+		bson.M{"$match": bson.M{"status": bson.M{"$in": bson.A{"paid", "open"}}, "total": bson.M{"$gte": 6}}},
+		bson.M{"$group": bson.M{"_id": bson.M{"region": "$region"}, "revenue": bson.M{"$sum": "$total"}}},
+		bson.M{"$sort": bson.M{"revenue": -1}},
+	)
+	assert.Equal(t, []bson.M{
+		{"_id": bson.M{"region": "eu"}, "revenue": 37.5},
+		{"_id": bson.M{"region": "us"}, "revenue": int64(12)},
+	}, out)
+
+	// _id null folds everything into one group; an empty input gives none.
+	out = runPipeline(t, sampleOrders(), bson.M{"$group": bson.M{"_id": nil, "orders": bson.M{"$count": bson.M{}}}})
+	assert.Equal(t, []bson.M{{"_id": nil, "orders": int64(6)}}, out)
+	assert.Empty(t, runPipeline(t, nil, bson.M{"$group": bson.M{"_id": "$status", "n": bson.M{"$count": bson.M{}}}}))
+
+	// Multi-key sorts take an ordered document; equal keys keep input order.
+	out = runPipeline(t, sampleOrders(), bson.M{"$sort": bson.D{{Key: "region", Value: 1}, {Key: "status", Value: -1}}})
+	var ids []string
+	for _, doc := range out {
+		ids = append(ids, doc["_id"].(string))
+	}
+	assert.Equal(t, []string{"o5", "o1", "o4", "o3", "o2", "o6"}, ids)
+}
+
+func TestAggregate_RejectsUnsupported(t *testing.T) {
+	for _, tc := range []struct {
+		pipeline []bson.M
+		want     string
+	}{
+		{[]bson.M{{"$lookup": bson.M{"from": "users"}}}, `unsupported aggregation stage "$lookup"`},
+		{[]bson.M{{"$match": bson.M{}}, {"$unwind": "$items"}}, `unsupported aggregation stage "$unwind"`},
+		{[]bson.M{{"$match": bson.M{}, "$sort": bson.M{"a": 1}}}, "exactly one operator"},
+		{[]bson.M{{"$match": bson.M{"a": bson.M{"$near": 1}}}}, `pipeline stage 0 ($match): unsupported query operator "$near"`},
+		{[]bson.M{{"$group": bson.M{"total": bson.M{"$sum": 1}}}}, "$group requires an _id"},
+		{[]bson.M{{"$group": bson.M{"_id": "$a", "top": bson.M{"$max": "$b"}}}}, `unsupported $group accumulator "$max"`},
+		{[]bson.M{{"$group": bson.M{"_id": "$a", "n": bson.M{"$count": "$b"}}}}, "takes no arguments"},
+		{[]bson.M{{"$sort": bson.M{"a": 1, "b": -1}}}, "needs an ordered document"},
+		{[]bson.M{{"$sort": bson.M{"a": 2}}}, "must be 1 or -1"},
+	} {
+		_, err := materializer.CompilePipeline(tc.pipeline)
+		assert.ErrorContains(t, err, tc.want, "%v", tc.pipeline)
+	}
+}
+
+func TestParsePipeline(t *testing.T) {
+	pipeline, err := walcli.ParsePipeline(`[
+		{"$match": {"placed": {"$gte": {"$date": "2026-01-01T00:00:00Z"}}}},
+		{"$group": {"_id": "$status", "n": {"$count": {}}}},
+		{"$sort": {"n": -1, "_id": 1}}
+	]`)
+	require.NoError(t, err)
+	require.Len(t, pipeline, 3)
+	assert.IsType(t, bson.M{}, pipeline[0]["$match"])
+	assert.Equal(t, bson.D{{Key: "n", Value: int32(-1)}, {Key: "_id", Value: int32(1)}}, pipeline[2]["$sort"])
+	out := runPipeline(t, sampleOrders(), pipeline[1:]...)
+	assert.Equal(t, bson.M{"_id": "open", "n": int64(3)}, out[0])
+
+	for _, raw := range []string{"", `{"$match": {}}`, `[{"$match": {]`} {
+		_, err := walcli.ParsePipeline(raw)
+		assert.Error(t, err, raw)
+	}
+
+	// A pipeline that parses but does not compile is refused here too.
+	_, err = walcli.ParsePipeline(`[{"$lookup": {"from": "users"}}]`)
+	assert.ErrorContains(t, err, `unsupported aggregation stage "$lookup"`)
+}
+
+func TestAggregate_BranchHead(t *testing.T) {
+	db := setupTestDB(t)
+	f := newSnapshotFixture(t, db)
+	ctx := context.Background()
+
+	main, err := f.branches.CreateBranch("aggregate", "main", "")
+	require.NoError(t, err)
+	writer := walwriter.New(f.wal, f.branches, f.mat, main)
+	for _, order := range sampleOrders() {
+		_, err := writer.Put(ctx, "orders", order)
+		require.NoError(t, err)
+	}
+	// A later write moves an order between groups; the head sees it.
+	_, err = writer.Put(ctx, "orders", bson.M{"_id": "o2", "status": "paid", "total": int32(5), "region": "us"})
+	require.NoError(t, err)
+	main, err = f.branches.GetBranchByID(main.ID)
+	require.NoError(t, err)
+
+	out, err := f.mat.Aggregate(main, "orders", []bson.M{
+		{"$match": bson.M{"status": "paid"}},
+		{"$group": bson.M{"_id": "$status", "revenue": bson.M{"$sum": "$total"}, "orders": bson.M{"$count": bson.M{}}}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []bson.M{{"_id": "paid", "revenue": int64(47), "orders": int64(3)}}, out)
+
+	_, err = f.mat.Aggregate(main, "orders", []bson.M{{"$project": bson.M{"total": 1}}})
+	assert.ErrorContains(t, err, fmt.Sprintf("unsupported aggregation stage %q", "$project"))
+}
@@ -0,0 +1,120 @@
+package wal_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/argon-lab/argon/internal/wal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// waitForHistory polls until the store holds want alerts since the given
+// time: the monitor persists from a background queue.
+func waitForHistory(t *testing.T, store *wal.AlertStore, since time.Time, want int) []wal.Alert {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		history, err := store.History(since, "")
+		require.NoError(t, err)
+		if len(history) >= want {
+			return history
+		}
+		require.True(t, time.Now().Before(deadline), "alerts never persisted (have %d, want %d)", len(history), want)
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestAlertStore_HistorySurvivesRestart(t *testing.T) {
+	db := setupTestDB(t)
+	store, err := wal.NewAlertStore(db)
+	require.NoError(t, err)
+	start := time.Now().Add(-time.Second)
+	config := wal.MonitorConfig{HealthCheckInterval: time.Hour, MetricsReportInterval: time.Hour}
+
+	first := wal.NewMonitor(wal.NewMetrics(), config)
+	require.NoError(t, first.SetAlertStore(store))
+	first.Start()
+	first.TriggerAlert(wal.AlertLevelWarning, "slow_disk", "latency high", map[string]interface{}{"ms": 900})
+	first.TriggerAlert(wal.AlertLevelCritical, "system_unhealthy", "down", nil)
+	first.ResolveAlert("slow_disk")
+	waitForHistory(t, store, start, 2)
+	require.Eventually(t, func() bool {
+		active, err := store.Active()
+		return err == nil && len(active) == 1
+	}, 10*time.Second, 20*time.Millisecond)
+	first.Stop()
+
+	// A new process sees the history and still holds the active alert.
+	second := wal.NewMonitor(wal.NewMetrics(), config)
+	require.NoError(t, second.SetAlertStore(store))
+	second.Start()
+	defer second.Stop()
+	active := second.GetActiveAlerts()
+	require.Len(t, active, 1)
+	assert.Equal(t, "system_unhealthy", active[0].Title)
+
+	history, err := second.GetAlertHistory(start, "")
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, "system_unhealthy", history[0].Title, "newest first")
+	assert.Equal(t, "slow_disk", history[1].Title)
+	assert.True(t, history[1].Resolved)
+	assert.False(t, history[1].ResolvedAt.IsZero())
+	assert.EqualValues(t, 900, history[1].Data["ms"])
+
+	critical, err := second.GetAlertHistory(start, wal.AlertLevelCritical)
+	require.NoError(t, err)
+	require.Len(t, critical, 1)
+	later, err := second.GetAlertHistory(time.Now().Add(time.Minute), "")
+	require.NoError(t, err)
+	assert.Empty(t, later)
+
+	// Resolving after the restart reaches the persisted alert.
+	second.ResolveAlert("system_unhealthy")
+	require.Eventually(t, func() bool {
+		active, err := store.Active()
+		return err == nil && len(active) == 0
+	}, 10*time.Second, 20*time.Millisecond)
+}
+
+// slowSink takes its time over every delivery.
+type slowSink struct{}
+
+func (slowSink) Deliver(context.Context, wal.Alert) error {
+	time.Sleep(50 * time.Millisecond)
+	return nil
+}
+
+func TestAlertStore_PersistsPastFullDeliveryQueue(t *testing.T) {
+	db := setupTestDB(t)
+	store, err := wal.NewAlertStore(db)
+	require.NoError(t, err)
+	start := time.Now().Add(-time.Second)
+
+	// A slow sink backs up the bounded delivery queue; persistence has a
+	// queue of its own and loses nothing.
+	monitor := wal.NewMonitor(wal.NewMetrics(), wal.MonitorConfig{
+		HealthCheckInterval:   time.Hour,
+		MetricsReportInterval: time.Hour,
+		AlertSinks:            []wal.AlertSink{slowSink{}},
+	})
+	require.NoError(t, monitor.SetAlertStore(store))
+	monitor.Start()
+	const n = 150
+	for i := 0; i < n; i++ {
+		title := fmt.Sprintf("alert_%d", i)
+		monitor.TriggerAlert(wal.AlertLevelWarning, title, "burst", nil)
+		monitor.ResolveAlert(title)
+	}
+	monitor.Stop()
+
+	history, err := store.History(start, "")
+	require.NoError(t, err)
+	assert.Len(t, history, n)
+	active, err := store.Active()
+	require.NoError(t, err)
+	assert.Empty(t, active)
+}
@@ -0,0 +1,179 @@
+package wal_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	branchwal "github.com/argon-lab/argon/internal/branch/wal"
+	"github.com/argon-lab/argon/internal/materializer"
+	projectwal "github.com/argon-lab/argon/internal/project/wal"
+	"github.com/argon-lab/argon/internal/restore"
+	"github.com/argon-lab/argon/internal/timetravel"
+	"github.com/argon-lab/argon/internal/wal"
+	"github.com/argon-lab/argon/internal/walwriter"
+	"github.com/argon-lab/argon/pkg/walcli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestBranchInfo_Summary(t *testing.T) {
+	db := setupTestDB(t)
+	walService, err := wal.NewService(db)
+	require.NoError(t, err)
+	branchService, err := branchwal.NewBranchService(db, walService)
+	require.NoError(t, err)
+	projectService, err := projectwal.NewProjectService(db, walService, branchService)
+	require.NoError(t, err)
+	mat := materializer.NewService(walService, branchService)
+	services := &walcli.Services{WAL: walService, Branches: branchService, Projects: projectService, Materializer: mat}
+	ctx := context.Background()
+
+	project, err := projectService.CreateProject("info-project")
+	require.NoError(t, err)
+	main, err := branchService.GetBranch(project.ID, "main")
+	require.NoError(t, err)
+	mainWriter := walwriter.New(walService, branchService, mat, main)
+	_, err = mainWriter.PutMany(ctx, "users", []bson.M{{"_id": "u1"}, {"_id": "u2"}})
+	require.NoError(t, err)
+
+	feature, err := branchService.CreateBranch(project.ID, "feature", main.ID)
+	require.NoError(t, err)
+	featureWriter := walwriter.New(walService, branchService, mat, feature)
+	_, err = featureWriter.Put(ctx, "orders", bson.M{"_id": "o1"})
+	require.NoError(t, err)
+	_, _, err = featureWriter.Delete(ctx, "users", "u2")
+	require.NoError(t, err)
+
+	// Three more writes on main after the fork put the feature behind.
+	for _, id := range []string{"u3", "u4", "u5"} {
+		_, err = mainWriter.Put(ctx, "users", bson.M{"_id": id})
+		require.NoError(t, err)
+	}
+
+	info, err := services.BranchInfo("info-project", "feature")
+	require.NoError(t, err)
+	assert.Equal(t, "main", info.Parent)
+	assert.Equal(t, feature.BaseLSN, info.BaseLSN)
+	assert.Equal(t, feature.HeadLSN, info.HeadLSN)
+	assert.Equal(t, int64(2), info.EntryCount)
+	assert.Equal(t, int64(2), info.Ahead)
+	assert.Equal(t, int64(3), info.Behind)
+	assert.Equal(t, map[string]int{"users": 1, "orders": 1}, info.Collections)
+	assert.Equal(t, walcli.BranchStateActive, info.State)
+	assert.False(t, info.Protected)
+
+	var out bytes.Buffer
+	walcli.WriteBranchInfo(&out, info)
+	printed := out.String()
+	for _, want := range []string{
+		"info-project/feature",
+		"State: active",
+		"Protected: no",
+		"Parent: main",
+		"Entries: 2",
+		"Ahead/Behind parent: 2/3",
+		"orders: 1 documents",
+		"users: 1 documents",
+	} {
+		assert.Contains(t, printed, want)
+	}
+
+	rootInfo, err := services.BranchInfo("info-project", "main")
+	require.NoError(t, err)
+	assert.Empty(t, rootInfo.Parent)
+	assert.Equal(t, int64(0), rootInfo.Behind)
+	assert.Equal(t, 5, rootInfo.Collections["users"])
+	assert.Equal(t, int64(5), rootInfo.EntryCount)
+
+	// A protected (frozen) branch says so.
+	require.NoError(t, branchService.SetProtected(feature.ID, true))
+	frozen, err := services.BranchInfo("info-project", "feature")
+	require.NoError(t, err)
+	assert.True(t, frozen.Protected)
+	out.Reset()
+	walcli.WriteBranchInfo(&out, frozen)
+	assert.Contains(t, out.String(), "Protected: yes (read-only)")
+	require.NoError(t, branchService.SetProtected(feature.ID, false))
+
+	// Discarded history no longer counts once the branches move on: reset
+	// both to the fork point, then write once on each.
+	restoreService := restore.NewService(walService, branchService, mat, timetravel.NewService(walService, mat))
+	_, err = restoreService.ResetBranchToLSN(feature.ID, feature.BaseLSN)
+	require.NoError(t, err)
+	_, err = restoreService.ResetBranchToLSN(main.ID, feature.BaseLSN)
+	require.NoError(t, err)
+	feature, err = branchService.GetBranchByID(feature.ID)
+	require.NoError(t, err)
+	_, err = walwriter.New(walService, branchService, mat, feature).Put(ctx, "orders", bson.M{"_id": "o2"})
+	require.NoError(t, err)
+	main, err = branchService.GetBranchByID(main.ID)
+	require.NoError(t, err)
+	_, err = walwriter.New(walService, branchService, mat, main).Put(ctx, "users", bson.M{"_id": "u6"})
+	require.NoError(t, err)
+
+	info, err = services.BranchInfo("info-project", "feature")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), info.EntryCount)
+	assert.Equal(t, int64(1), info.Ahead)
+	assert.Equal(t, int64(1), info.Behind)
+	assert.Len(t, info.Discarded, 1)
+	assert.Equal(t, map[string]int{"users": 2, "orders": 1}, info.Collections)
+
+	rootInfo, err = services.BranchInfo("info-project", "main")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), rootInfo.EntryCount)
+}
+
+func TestBranchInfo_DocumentSizes(t *testing.T) {
+	db := setupTestDB(t)
+	walService, err := wal.NewService(db)
+	require.NoError(t, err)
+	branchService, err := branchwal.NewBranchService(db, walService)
+	require.NoError(t, err)
+	projectService, err := projectwal.NewProjectService(db, walService, branchService)
+	require.NoError(t, err)
+	mat := materializer.NewService(walService, branchService)
+	services := &walcli.Services{WAL: walService, Branches: branchService, Projects: projectService, Materializer: mat}
+	ctx := context.Background()
+
+	project, err := projectService.CreateProject("sizes-project")
+	require.NoError(t, err)
+	main, err := branchService.GetBranch(project.ID, "main")
+	require.NoError(t, err)
+	writer := walwriter.New(walService, branchService, mat, main)
+
+	// Payloads of 100 B, 1 KB and 10 KB; the 10 KB one is later shrunk,
+	// so only its latest state counts.
+	var total, max int64
+	for i, n := range []int{100, 1000, 10000} {
+		doc := bson.M{"_id": fmt.Sprintf("d%d", i), "payload": strings.Repeat("x", n)}
+		_, err := writer.Put(ctx, "blobs", doc)
+		require.NoError(t, err)
+	}
+	_, err = writer.Put(ctx, "blobs", bson.M{"_id": "d2", "payload": strings.Repeat("x", 5000)})
+	require.NoError(t, err)
+	for i, n := range []int{100, 1000, 5000} {
+		raw, err := bson.Marshal(bson.M{"_id": fmt.Sprintf("d%d", i), "payload": strings.Repeat("x", n)})
+		require.NoError(t, err)
+		total += int64(len(raw))
+		if int64(len(raw)) > max {
+			max = int64(len(raw))
+		}
+	}
+
+	info, err := services.BranchInfo("sizes-project", "main")
+	require.NoError(t, err)
+	sizes := info.Sizes["blobs"]
+	assert.Equal(t, 3, sizes.Documents)
+	assert.InDelta(t, total, sizes.TotalBytes, 8)
+	assert.InDelta(t, total/3, sizes.AvgBytes, 8)
+	assert.InDelta(t, max, sizes.MaxBytes, 8)
+
+	var out bytes.Buffer
+	walcli.WriteBranchInfo(&out, info)
+	assert.Contains(t, out.String(), fmt.Sprintf("blobs: 3 documents (avg %d B, max %d B)", sizes.AvgBytes, sizes.MaxBytes))
+}
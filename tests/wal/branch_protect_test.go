@@ -0,0 +1,150 @@
+package wal_test
+
+import (
+	"context"
+	"testing"
+
+	projectwal "github.com/argon-lab/argon/internal/project/wal"
+	"github.com/argon-lab/argon/internal/undo"
+	"github.com/argon-lab/argon/internal/wal"
+	"github.com/argon-lab/argon/internal/walwriter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestBranchProtection_RefusesWritesUntilUnprotected(t *testing.T) {
+	db := setupTestDB(t)
+	f := newSnapshotFixture(t, db)
+	ctx := context.Background()
+
+	main, err := f.branches.CreateBranch("protect", "main", "")
+	require.NoError(t, err)
+	writer := walwriter.New(f.wal, f.branches, f.mat, main)
+	_, err = writer.Put(ctx, "docs", bson.M{"_id": "d1", "v": 1})
+	require.NoError(t, err)
+
+	openTx, err := writer.BeginTx()
+	require.NoError(t, err)
+	require.NoError(t, openTx.Put(ctx, "docs", bson.M{"_id": "d3"}))
+
+	require.NoError(t, f.branches.SetProtected(main.ID, true))
+	main, err = f.branches.GetBranchByID(main.ID)
+	require.NoError(t, err)
+	assert.True(t, main.Protected)
+	head := main.HeadLSN
+
+	// A writer opened before the change sees it too.
+	_, err = writer.Put(ctx, "docs", bson.M{"_id": "d2"})
+	assert.ErrorIs(t, err, wal.ErrBranchProtected)
+	_, err = writer.PutMany(ctx, "docs", []bson.M{{"_id": "d2"}})
+	assert.ErrorIs(t, err, wal.ErrBranchProtected)
+	_, err = openTx.Commit(ctx)
+	assert.ErrorIs(t, err, wal.ErrBranchProtected)
+	// Its refused entries were taken back out of the WAL.
+	stale, err := f.wal.GetBranchEntries(main.ID, "docs", head+1, f.wal.GetCurrentLSN("protect"))
+	require.NoError(t, err)
+	assert.Empty(t, stale)
+
+	protected := walwriter.New(f.wal, f.branches, f.mat, main)
+	_, err = protected.Put(ctx, "docs", bson.M{"_id": "d2"})
+	assert.ErrorIs(t, err, wal.ErrBranchProtected)
+	assert.ErrorContains(t, err, "main is read-only")
+	_, _, err = protected.Delete(ctx, "docs", "d1")
+	assert.ErrorIs(t, err, wal.ErrBranchProtected)
+	_, err = protected.BeginTx()
+	assert.ErrorIs(t, err, wal.ErrBranchProtected)
+
+	// Undo is a write too.
+	undoService := undo.NewService(f.wal, f.branches, db.Client())
+	plan, err := undoService.BuildPlan(main, head, head, "")
+	require.NoError(t, err)
+	_, _, err = undoService.Apply(ctx, main, plan)
+	assert.ErrorIs(t, err, wal.ErrBranchProtected)
+
+	// So is cherry-picking onto it.
+	feature, err := f.branches.CreateBranch("protect", "feature", main.ID)
+	require.NoError(t, err)
+	picked, err := walwriter.New(f.wal, f.branches, f.mat, feature).Put(ctx, "docs", bson.M{"_id": "f1"})
+	require.NoError(t, err)
+	_, err = f.restore.CherryPick(feature.ID, main.ID, picked, picked)
+	assert.ErrorIs(t, err, wal.ErrBranchProtected)
+
+	// Nothing reached the WAL.
+	main, err = f.branches.GetBranchByID(main.ID)
+	require.NoError(t, err)
+	assert.Equal(t, head, main.HeadLSN)
+
+	require.NoError(t, f.branches.SetProtected(main.ID, false))
+	main, err = f.branches.GetBranchByID(main.ID)
+	require.NoError(t, err)
+	assert.False(t, main.Protected)
+	writable := walwriter.New(f.wal, f.branches, f.mat, main)
+	_, err = writable.Put(ctx, "docs", bson.M{"_id": "d2"})
+	require.NoError(t, err)
+	_, existed, err := writable.Delete(ctx, "docs", "d1")
+	require.NoError(t, err)
+	assert.True(t, existed)
+	// The writer refused earlier can write again once the branch reopens.
+	_, err = writer.Put(ctx, "docs", bson.M{"_id": "d4"})
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, f.branches.SetProtected("missing", true), wal.ErrBranchNotFound)
+}
+
+func TestBranchProtection_ResetNeedsForce(t *testing.T) {
+	db := setupTestDB(t)
+	f := newSnapshotFixture(t, db)
+	ctx := context.Background()
+
+	main, err := f.branches.CreateBranch("protect-reset", "main", "")
+	require.NoError(t, err)
+	writer := walwriter.New(f.wal, f.branches, f.mat, main)
+	target, err := writer.Put(ctx, "docs", bson.M{"_id": "d1"})
+	require.NoError(t, err)
+	head, err := writer.Put(ctx, "docs", bson.M{"_id": "d2"})
+	require.NoError(t, err)
+	require.NoError(t, f.branches.SetProtected(main.ID, true))
+
+	_, err = f.restore.ResetBranchToLSN(main.ID, target)
+	assert.ErrorIs(t, err, wal.ErrBranchProtected)
+	_, _, err = f.restore.ResetBranchToLSNWithBackup(main.ID, target, "before-reset")
+	assert.ErrorIs(t, err, wal.ErrBranchProtected)
+	// A refused reset leaves no backup behind.
+	_, err = f.branches.GetBranch("protect-reset", "before-reset")
+	assert.ErrorIs(t, err, wal.ErrBranchNotFound)
+	unchanged, err := f.branches.GetBranchByID(main.ID)
+	require.NoError(t, err)
+	assert.Equal(t, head, unchanged.HeadLSN)
+
+	reset, backup, err := f.restore.ForceResetBranchToLSNWithBackup(main.ID, target, "before-reset")
+	require.NoError(t, err)
+	assert.Equal(t, target, reset.HeadLSN)
+	assert.Equal(t, head, backup.HeadLSN)
+	// Forcing a reset does not lift the protection.
+	assert.True(t, reset.Protected)
+}
+
+func TestBranchProtection_ProtectMainByDefault(t *testing.T) {
+	db := setupTestDB(t)
+	f := newSnapshotFixture(t, db)
+	projects, err := projectwal.NewProjectService(db, f.wal, f.branches)
+	require.NoError(t, err)
+
+	open, err := projects.CreateProject("protect-default-off")
+	require.NoError(t, err)
+	main, err := f.branches.GetBranchByID(open.MainBranchID)
+	require.NoError(t, err)
+	assert.False(t, main.Protected)
+
+	projects.SetProtectMain(true)
+	guarded, err := projects.CreateProject("protect-default-on")
+	require.NoError(t, err)
+	main, err = f.branches.GetBranchByID(guarded.MainBranchID)
+	require.NoError(t, err)
+	assert.True(t, main.Protected)
+	// Only main: branches forked from it start writable.
+	feature, err := f.branches.CreateBranch(guarded.ID, "feature", main.ID)
+	require.NoError(t, err)
+	assert.False(t, feature.Protected)
+}
@@ -0,0 +1,157 @@
+package wal_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/argon-lab/argon/internal/pin"
+	"github.com/argon-lab/argon/internal/wal"
+	"github.com/argon-lab/argon/internal/walwriter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestBranchRename_KeepsHistoryAndPins(t *testing.T) {
+	db := setupTestDB(t)
+	f := newSnapshotFixture(t, db)
+	pins, err := pin.NewService(db, f.branches)
+	require.NoError(t, err)
+	f.branches.SetRenameHook(func(branchID, newName string) {
+		require.NoError(t, pins.RenameBranch(branchID, newName))
+	})
+	ctx := context.Background()
+
+	main, err := f.branches.CreateBranch("rename", "main", "")
+	require.NoError(t, err)
+	feature, err := f.branches.CreateBranch("rename", "feature", main.ID)
+	require.NoError(t, err)
+	writer := walwriter.New(f.wal, f.branches, f.mat, feature)
+	_, err = writer.Put(ctx, "docs", bson.M{"_id": "d1", "v": 1})
+	require.NoError(t, err)
+	_, err = pins.Create("rename", feature.ID, "baseline", 0, "")
+	require.NoError(t, err)
+
+	renamed, err := f.branches.RenameBranch("rename", "feature", "experiment")
+	require.NoError(t, err)
+	assert.Equal(t, feature.ID, renamed.ID)
+	assert.Equal(t, "experiment", renamed.Name)
+
+	_, err = f.branches.GetBranch("rename", "feature")
+	assert.ErrorIs(t, err, wal.ErrBranchNotFound)
+	got, err := f.branches.GetBranch("rename", "experiment")
+	require.NoError(t, err)
+	assert.Equal(t, feature.ID, got.ID)
+	assert.Equal(t, main.ID, got.ParentID)
+
+	// Data is keyed by branch ID, so the renamed branch reads the same.
+	state, err := f.mat.MaterializeBranch(got)
+	require.NoError(t, err)
+	assert.Len(t, state["docs"], 1)
+
+	// Pins follow the branch, and its creation record still resolves.
+	p, err := pins.Get("rename", "baseline")
+	require.NoError(t, err)
+	assert.Equal(t, "experiment", p.BranchName)
+	creation, err := f.branches.GetBranchCreationEntry(feature.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "experiment", creation.BranchName)
+	require.NotNil(t, creation.Entry)
+	assert.Equal(t, feature.CreatedLSN, creation.Entry.LSN)
+
+	// The rename itself is in the WAL.
+	entries, err := f.wal.GetEntries(bson.M{"project_id": "rename", "operation": wal.OpRenameBranch})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	last := entries[0]
+	assert.Equal(t, wal.OpRenameBranch, last.Operation)
+	assert.Equal(t, feature.ID, last.BranchID)
+	assert.Equal(t, "feature", last.Metadata["old_name"])
+	assert.Equal(t, "experiment", last.Metadata["new_name"])
+
+	// Renaming to the current name is a no-op.
+	same, err := f.branches.RenameBranch("rename", "experiment", "experiment")
+	require.NoError(t, err)
+	assert.Equal(t, feature.ID, same.ID)
+	entries, err = f.wal.GetEntries(bson.M{"project_id": "rename", "operation": wal.OpRenameBranch})
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestBranchRename_Rejections(t *testing.T) {
+	db := setupTestDB(t)
+	f := newSnapshotFixture(t, db)
+
+	main, err := f.branches.CreateBranch("rename-reject", "main", "")
+	require.NoError(t, err)
+	for _, name := range []string{"a", "b", "gone"} {
+		_, err := f.branches.CreateBranch("rename-reject", name, main.ID)
+		require.NoError(t, err)
+	}
+	require.NoError(t, f.branches.DeleteBranch("rename-reject", "gone"))
+
+	_, err = f.branches.RenameBranch("rename-reject", "a", "b")
+	assert.ErrorIs(t, err, wal.ErrBranchExists)
+	// A deleted branch still holds its name.
+	_, err = f.branches.RenameBranch("rename-reject", "a", "gone")
+	assert.ErrorIs(t, err, wal.ErrBranchExists)
+	_, err = f.branches.RenameBranch("rename-reject", "main", "trunk")
+	assert.ErrorIs(t, err, wal.ErrMainBranchRename)
+	_, err = f.branches.RenameBranch("rename-reject", "a", "main")
+	assert.ErrorIs(t, err, wal.ErrMainBranchRename)
+	_, err = f.branches.RenameBranch("rename-reject", "missing", "c")
+	assert.ErrorIs(t, err, wal.ErrBranchNotFound)
+	_, err = f.branches.RenameBranch("rename-reject", "a", "")
+	assert.Error(t, err)
+
+	// Failed renames change nothing.
+	for _, name := range []string{"main", "a", "b"} {
+		_, err := f.branches.GetBranch("rename-reject", name)
+		assert.NoError(t, err, name)
+	}
+}
+
+func TestBranchRename_ConcurrentSameName(t *testing.T) {
+	db := setupTestDB(t)
+	f := newSnapshotFixture(t, db)
+
+	main, err := f.branches.CreateBranch("rename-race", "main", "")
+	require.NoError(t, err)
+	const racers = 4
+	for i := 0; i < racers; i++ {
+		_, err := f.branches.CreateBranch("rename-race", fmt.Sprintf("b%d", i), main.ID)
+		require.NoError(t, err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, racers)
+	for i := 0; i < racers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = f.branches.RenameBranch("rename-race", fmt.Sprintf("b%d", i), "winner")
+		}(i)
+	}
+	wg.Wait()
+
+	// The unique index lets exactly one rename through.
+	won := 0
+	for _, err := range errs {
+		if err == nil {
+			won++
+			continue
+		}
+		assert.True(t, errors.Is(err, wal.ErrBranchExists), "%v", err)
+	}
+	assert.Equal(t, 1, won)
+	branches, err := f.branches.ListBranches("rename-race")
+	require.NoError(t, err)
+	assert.Len(t, branches, racers+1)
+
+	entries, err := f.wal.GetEntries(bson.M{"project_id": "rename-race", "operation": wal.OpRenameBranch})
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "losing renames must not reach the WAL")
+}
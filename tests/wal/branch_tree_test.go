@@ -0,0 +1,114 @@
+package wal_test
+
+import (
+	"context"
+	"testing"
+
+	branchwal "github.com/argon-lab/argon/internal/branch/wal"
+	"github.com/argon-lab/argon/internal/materializer"
+	"github.com/argon-lab/argon/internal/wal"
+	"github.com/argon-lab/argon/internal/walwriter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// findNode returns the node with the given name anywhere in the tree.
+func findNode(nodes []*branchwal.BranchNode, name string) *branchwal.BranchNode {
+	for _, n := range nodes {
+		if n.Name == name {
+			return n
+		}
+		if found := findNode(n.Children, name); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func TestBranchTree_MultiLevelHierarchy(t *testing.T) {
+	db := setupTestDB(t)
+	walService, err := wal.NewService(db)
+	require.NoError(t, err)
+	branchService, err := branchwal.NewBranchService(db, walService)
+	require.NoError(t, err)
+	mat := materializer.NewService(walService, branchService)
+	ctx := context.Background()
+
+	main, err := branchService.CreateBranch("tree-project", "main", "")
+	require.NoError(t, err)
+	_, err = walwriter.New(walService, branchService, mat, main).Put(ctx, "docs", bson.M{"_id": "a"})
+	require.NoError(t, err)
+	main, _ = branchService.GetBranchByID(main.ID)
+
+	featA, err := branchService.CreateBranch("tree-project", "feature-a", main.ID)
+	require.NoError(t, err)
+	_, err = branchService.CreateBranch("tree-project", "feature-b", main.ID)
+	require.NoError(t, err)
+	_, err = walwriter.New(walService, branchService, mat, featA).Put(ctx, "docs", bson.M{"_id": "b"})
+	require.NoError(t, err)
+	featA, _ = branchService.GetBranchByID(featA.ID)
+	_, err = branchService.CreateBranch("tree-project", "experiment", featA.ID)
+	require.NoError(t, err)
+
+	tree, err := branchService.GetBranchTree("tree-project")
+	require.NoError(t, err)
+	require.Len(t, tree, 1, "main is the only root")
+
+	root := tree[0]
+	assert.Equal(t, "main", root.Name)
+	assert.False(t, root.Orphaned)
+	assert.Equal(t, int64(0), root.ForkLSN)
+	assert.Equal(t, main.HeadLSN, root.HeadLSN)
+	require.Len(t, root.Children, 2)
+	assert.Equal(t, "feature-a", root.Children[0].Name, "siblings are ordered by creation")
+	assert.Equal(t, "feature-b", root.Children[1].Name)
+
+	a := root.Children[0]
+	assert.Equal(t, main.HeadLSN, a.ForkLSN)
+	assert.Equal(t, featA.HeadLSN, a.HeadLSN)
+	assert.Greater(t, a.HeadLSN, a.ForkLSN)
+	require.Len(t, a.Children, 1)
+	assert.Equal(t, "experiment", a.Children[0].Name)
+	assert.Equal(t, featA.HeadLSN, a.Children[0].ForkLSN)
+	assert.Empty(t, a.Children[0].Children)
+	assert.Empty(t, root.Children[1].Children)
+
+	// Force-deleting a parent leaves its child without a live parent: it
+	// surfaces as an orphaned root instead of vanishing.
+	require.NoError(t, branchService.ForceDeleteBranch("tree-project", "feature-a"))
+	tree, err = branchService.GetBranchTree("tree-project")
+	require.NoError(t, err)
+	require.Len(t, tree, 2)
+	assert.Nil(t, findNode(tree, "feature-a"))
+	orphan := findNode(tree, "experiment")
+	require.NotNil(t, orphan)
+	assert.True(t, orphan.Orphaned)
+	assert.Equal(t, featA.ID, orphan.ParentID)
+	assert.Len(t, findNode(tree, "main").Children, 1)
+}
+
+func TestBranchTree_CycleIsAnError(t *testing.T) {
+	db := setupTestDB(t)
+	walService, err := wal.NewService(db)
+	require.NoError(t, err)
+	branchService, err := branchwal.NewBranchService(db, walService)
+	require.NoError(t, err)
+
+	main, err := branchService.CreateBranch("cycle-project", "main", "")
+	require.NoError(t, err)
+	left, err := branchService.CreateBranch("cycle-project", "left", main.ID)
+	require.NoError(t, err)
+	right, err := branchService.CreateBranch("cycle-project", "right", left.ID)
+	require.NoError(t, err)
+
+	// Corrupt the parent pointers so left and right are each other's
+	// parent: neither can be placed under a root.
+	_, err = db.Collection("wal_branches").UpdateOne(context.Background(),
+		bson.M{"_id": left.ID}, bson.M{"$set": bson.M{"parent_id": right.ID}})
+	require.NoError(t, err)
+
+	_, err = branchService.GetBranchTree("cycle-project")
+	require.ErrorIs(t, err, wal.ErrBranchCycle)
+	assert.Regexp(t, `branch (left|right) is its own ancestor`, err.Error())
+}
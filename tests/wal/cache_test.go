@@ -0,0 +1,118 @@
+package wal_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/argon-lab/argon/internal/wal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache_StateKeys(t *testing.T) {
+	cache := wal.NewCache(wal.CacheConfig{})
+	defer cache.Close()
+
+	t.Run("Distinct LSNs and collections do not collide", func(t *testing.T) {
+		states := map[string]map[int64]string{
+			"users":  {65: "users@65", 97: "users@97", 1 << 40: "users@big"},
+			"orders": {65: "orders@65", 97: "orders@97"},
+		}
+		for collection, byLSN := range states {
+			for lsn, tag := range byLSN {
+				cache.SetMaterializedState(collection, lsn, map[string]interface{}{"tag": tag})
+			}
+		}
+		for collection, byLSN := range states {
+			for lsn, tag := range byLSN {
+				state, ok := cache.GetMaterializedState(collection, lsn)
+				require.True(t, ok, "%s@%d", collection, lsn)
+				assert.Equal(t, tag, state["tag"])
+			}
+		}
+		_, ok := cache.GetMaterializedState("users", 66)
+		assert.False(t, ok)
+	})
+
+	t.Run("Invalidation is scoped to one collection", func(t *testing.T) {
+		cache.SetMaterializedState("users", 10, map[string]interface{}{"tag": "users"})
+		cache.SetMaterializedState("users_archive", 10, map[string]interface{}{"tag": "archive"})
+		cache.SetMaterializedState("users:old", 10, map[string]interface{}{"tag": "old"})
+
+		cache.InvalidateState("users")
+
+		_, ok := cache.GetMaterializedState("users", 10)
+		assert.False(t, ok)
+		_, ok = cache.GetMaterializedState("users", 65)
+		assert.False(t, ok)
+		_, ok = cache.GetMaterializedState("users_archive", 10)
+		assert.True(t, ok)
+		_, ok = cache.GetMaterializedState("users:old", 10)
+		assert.True(t, ok)
+		_, ok = cache.GetMaterializedState("orders", 65)
+		assert.True(t, ok)
+	})
+}
+
+func TestCache_Stats(t *testing.T) {
+	t.Run("Gets count hits, misses and expiries", func(t *testing.T) {
+		cache := wal.NewCache(wal.CacheConfig{
+			QueryCacheTTL:   20 * time.Millisecond,
+			BranchCacheTTL:  20 * time.Millisecond,
+			CleanupInterval: time.Hour,
+		})
+		defer cache.Close()
+
+		cache.SetMaterializedState("users", 1, map[string]interface{}{})
+		cache.GetMaterializedState("users", 1)
+		cache.GetMaterializedState("users", 1)
+		cache.GetMaterializedState("users", 2)
+
+		cache.SetQueryResult("q1", "result")
+		cache.GetQueryResult("q1")
+		cache.GetQueryResult("q2")
+
+		cache.SetBranchMetadata("b1", "meta")
+		cache.GetBranchMetadata("b1")
+		cache.GetBranchMetadata("b2")
+
+		cache.AddRecentEntry(7, "entry")
+		cache.GetRecentEntry(7)
+		cache.GetRecentEntry(8)
+
+		time.Sleep(30 * time.Millisecond)
+		_, ok := cache.GetQueryResult("q1")
+		assert.False(t, ok)
+		_, ok = cache.GetBranchMetadata("b1")
+		assert.False(t, ok)
+
+		stats := cache.GetStats()
+		assert.Equal(t, int64(2), stats.StateCache.Hits)
+		assert.Equal(t, int64(1), stats.StateCache.Misses)
+		assert.Equal(t, wal.QueryCacheStats{Items: 0, Hits: 1, Misses: 2, Expired: 1}, stats.QueryCache)
+		assert.Equal(t, wal.BranchCacheStats{Items: 0, Hits: 1, Misses: 2, Expired: 1}, stats.BranchCache)
+		assert.Equal(t, int64(1), stats.RecentHits)
+		assert.Equal(t, int64(1), stats.RecentMisses)
+	})
+
+	t.Run("Cleanup counts the entries it expires", func(t *testing.T) {
+		cache := wal.NewCache(wal.CacheConfig{
+			QueryCacheTTL:   time.Millisecond,
+			BranchCacheTTL:  time.Millisecond,
+			CleanupInterval: 5 * time.Millisecond,
+		})
+		defer cache.Close()
+
+		cache.SetQueryResult("q1", "result")
+		cache.SetQueryResult("q2", "result")
+		cache.SetBranchMetadata("b1", "meta")
+
+		assert.Eventually(t, func() bool {
+			stats := cache.GetStats()
+			return stats.QueryCache.Expired == 2 && stats.BranchCache.Expired == 1
+		}, time.Second, 5*time.Millisecond)
+		stats := cache.GetStats()
+		assert.Zero(t, stats.QueryCache.Items)
+		assert.Zero(t, stats.QueryCache.Misses, "cleanup expiries are not misses")
+	})
+}
@@ -0,0 +1,70 @@
+package wal_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/argon-lab/argon/internal/wal"
+	"github.com/argon-lab/argon/internal/walwriter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestMetrics_ProjectCapacity(t *testing.T) {
+	m := wal.NewMetrics()
+	m.RecordEntriesAppended("p1", 3)
+	m.RecordEntriesAppended("p1", 2)
+	m.RecordEntriesAppended("p2", 1)
+	m.SetProjectBranches("p1", 4)
+
+	snap := m.GetSnapshot()
+	assert.Equal(t, map[string]int64{"p1": 5, "p2": 1}, snap.ProjectEntriesAppended)
+	assert.Equal(t, map[string]int64{"p1": 5, "p2": 1}, snap.ProjectEntriesPerMinute)
+	assert.Equal(t, map[string]int{"p1": 4}, snap.ProjectBranches)
+
+	// Snapshots are copies.
+	snap.ProjectBranches["p1"] = 99
+	assert.Equal(t, 4, m.GetSnapshot().ProjectBranches["p1"])
+
+	m.Reset()
+	snap = m.GetSnapshot()
+	assert.Empty(t, snap.ProjectEntriesAppended)
+	assert.Empty(t, snap.ProjectBranches)
+}
+
+func TestMetrics_BranchGaugeAndEntryGrowth(t *testing.T) {
+	db := setupTestDB(t)
+	f := newSnapshotFixture(t, db)
+	project := fmt.Sprintf("capacity-%d", time.Now().UnixNano())
+	metrics := f.wal.Metrics()
+
+	main, err := f.branches.CreateBranch(project, "main", "")
+	require.NoError(t, err)
+	assert.Equal(t, 1, metrics.GetSnapshot().ProjectBranches[project])
+
+	before := metrics.GetSnapshot()
+	writer := walwriter.New(f.wal, f.branches, f.mat, main)
+	for i := 0; i < 3; i++ {
+		_, err := writer.Put(context.Background(), "docs", bson.M{"_id": fmt.Sprintf("d%d", i)})
+		require.NoError(t, err)
+	}
+	_, err = writer.PutMany(context.Background(), "docs", []bson.M{{"_id": "m1"}, {"_id": "m2"}})
+	require.NoError(t, err)
+
+	after := metrics.GetSnapshot()
+	assert.Equal(t, before.ProjectEntriesAppended[project]+5, after.ProjectEntriesAppended[project])
+	assert.GreaterOrEqual(t, after.ProjectEntriesPerMinute[project], before.ProjectEntriesPerMinute[project]+5,
+		"the growth rate advances with the writes")
+
+	_, err = f.branches.CreateBranch(project, "feature", main.ID)
+	require.NoError(t, err)
+	_, err = f.branches.CreateBranch(project, "scratch", main.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 3, metrics.GetSnapshot().ProjectBranches[project])
+
+	require.NoError(t, f.branches.DeleteBranch(project, "scratch"))
+	assert.Equal(t, 2, metrics.GetSnapshot().ProjectBranches[project], "deleted branches leave the gauge")
+}
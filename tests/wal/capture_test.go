@@ -0,0 +1,147 @@
+package wal_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	branchwal "github.com/argon-lab/argon/internal/branch/wal"
+	"github.com/argon-lab/argon/internal/materializer"
+	projectwal "github.com/argon-lab/argon/internal/project/wal"
+	"github.com/argon-lab/argon/internal/wal"
+	"github.com/argon-lab/argon/internal/walwriter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestCaptureConfig_Captures(t *testing.T) {
+	assert.True(t, wal.CaptureConfig{}.Captures("anything"))
+
+	deny := wal.CaptureConfig{Deny: []string{"cache"}}
+	assert.False(t, deny.Captures("cache"))
+	assert.True(t, deny.Captures("orders"))
+
+	allow := wal.CaptureConfig{Allow: []string{"orders", "users"}, Deny: []string{"users"}}
+	assert.True(t, allow.Captures("orders"))
+	assert.False(t, allow.Captures("users"), "deny wins over allow")
+	assert.False(t, allow.Captures("sessions"))
+}
+
+// captureLookup serves a fixed capture policy to the ingester.
+type captureLookup struct{ capture wal.CaptureConfig }
+
+func (l captureLookup) GetProject(projectID string) (*wal.Project, error) {
+	return &wal.Project{ID: projectID, Capture: l.capture}, nil
+}
+
+func TestCapture_WriterRefusesDeniedCollection(t *testing.T) {
+	db := setupTestDB(t)
+	walService, err := wal.NewService(db)
+	require.NoError(t, err)
+	branchService, err := branchwal.NewBranchService(db, walService)
+	require.NoError(t, err)
+	projectService, err := projectwal.NewProjectService(db, walService, branchService)
+	require.NoError(t, err)
+	project, err := projectService.CreateProject("capture-writer")
+	require.NoError(t, err)
+
+	require.NoError(t, projectService.SetCaptureConfig(project.ID, wal.CaptureConfig{Deny: []string{"cache"}}))
+	project, err = projectService.GetProject(project.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cache"}, project.Capture.Deny)
+	assert.ErrorIs(t, projectService.SetCaptureConfig("missing", wal.CaptureConfig{}), wal.ErrProjectNotFound)
+
+	main, err := branchService.GetBranch(project.ID, "main")
+	require.NoError(t, err)
+	w := walwriter.New(walService, branchService, materializer.NewService(walService, branchService), main)
+	w.SetCapture(project.Capture)
+	ctx := context.Background()
+
+	_, err = w.Put(ctx, "cache", bson.M{"_id": "c1"})
+	var denied *walwriter.CaptureDisabledError
+	require.True(t, errors.As(err, &denied), "got %v", err)
+	assert.Equal(t, "cache", denied.Collection)
+	_, _, err = w.Delete(ctx, "cache", "c1")
+	assert.True(t, errors.As(err, &denied))
+
+	_, err = w.Put(ctx, "items", bson.M{"_id": "i1"})
+	require.NoError(t, err)
+
+	branch, err := branchService.GetBranchByID(main.ID)
+	require.NoError(t, err)
+	cached, err := walService.GetBranchEntries(branch.ID, "cache", 0, branch.HeadLSN)
+	require.NoError(t, err)
+	assert.Empty(t, cached)
+	items, err := walService.GetBranchEntries(branch.ID, "items", 0, branch.HeadLSN)
+	require.NoError(t, err)
+	assert.Len(t, items, 1)
+}
+
+func TestCapture_TxRefusesDeniedCollection(t *testing.T) {
+	db := setupTestDB(t)
+	walService, err := wal.NewService(db)
+	require.NoError(t, err)
+	branchService, err := branchwal.NewBranchService(db, walService)
+	require.NoError(t, err)
+	projectService, err := projectwal.NewProjectService(db, walService, branchService)
+	require.NoError(t, err)
+	project, err := projectService.CreateProject("capture-tx")
+	require.NoError(t, err)
+
+	main, err := branchService.GetBranch(project.ID, "main")
+	require.NoError(t, err)
+	w := walwriter.New(walService, branchService, materializer.NewService(walService, branchService), main)
+	w.SetCapture(wal.CaptureConfig{Deny: []string{"cache"}})
+	ctx := context.Background()
+
+	_, err = w.WithTransaction(ctx, func(tx *walwriter.Tx) error {
+		if err := tx.Put(ctx, "items", bson.M{"_id": "i1"}); err != nil {
+			return err
+		}
+		return tx.Put(ctx, "cache", bson.M{"_id": "c1"})
+	})
+	var denied *walwriter.CaptureDisabledError
+	require.True(t, errors.As(err, &denied), "got %v", err)
+	assert.Equal(t, "cache", denied.Collection)
+
+	tx, err := w.BeginTx()
+	require.NoError(t, err)
+	_, err = tx.Delete(ctx, "cache", "c1")
+	assert.True(t, errors.As(err, &denied))
+	var reserved *walwriter.ReservedFieldError
+	assert.True(t, errors.As(tx.Put(ctx, "items", bson.M{"_id": "i2", "_argon": 1}), &reserved))
+	tx.Abort()
+
+	// The aborted transaction left nothing behind, not even its allowed put.
+	branch, err := branchService.GetBranchByID(main.ID)
+	require.NoError(t, err)
+	entries, err := walService.GetBranchEntries(branch.ID, "", 0, branch.HeadLSN)
+	require.NoError(t, err)
+	for _, e := range entries {
+		assert.NotEqual(t, wal.OpPut, e.Operation, "unexpected %s/%s", e.Collection, e.DocumentID)
+	}
+}
+
+func TestCapture_IngesterSkipsDeniedCollection(t *testing.T) {
+	f := newIngestFixture(t, "capture-ingest")
+	f.ingest.SetProjectLookup(captureLookup{wal.CaptureConfig{Deny: []string{"cache"}}})
+	defer f.ingest.SetProjectLookup(nil)
+
+	stop := f.startIngester(t)
+	ctx := context.Background()
+	_, err := f.physical.Collection("cache").InsertOne(ctx, bson.M{"_id": "c1"})
+	require.NoError(t, err)
+	_, err = f.physical.Collection("docs").InsertOne(ctx, bson.M{"_id": "d1"})
+	require.NoError(t, err)
+	f.waitForEntries(t, "docs", 1)
+	stop()
+
+	// The denied write passed straight through to the physical database.
+	assert.Contains(t, f.physicalState(t, "cache"), "c1")
+	branch, err := f.branches.GetBranchByID(f.branchID)
+	require.NoError(t, err)
+	cached, err := f.wal.GetBranchEntries(f.branchID, "cache", 0, branch.HeadLSN)
+	require.NoError(t, err)
+	assert.Empty(t, cached)
+}
@@ -0,0 +1,79 @@
+package wal_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/argon-lab/argon/internal/compact"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestCompactDocument(t *testing.T) {
+	db := setupTestDB(t)
+	walService, branchService, mat, branch, writer := newMaterializerFixture(t, db, "compact-project", "main")
+	ctx := context.Background()
+
+	_, err := writer.Put(ctx, "counters", bson.M{"_id": "cold", "n": int32(0)})
+	require.NoError(t, err)
+	var midLSN int64
+	for i := 1; i <= 50; i++ {
+		lsn, err := writer.Put(ctx, "counters", bson.M{"_id": "hot", "n": int32(i)})
+		require.NoError(t, err)
+		if i == 20 {
+			midLSN = lsn
+		}
+	}
+	child, err := branchService.CreateBranch(branch.ProjectID, "before-compaction", branch.ID)
+	require.NoError(t, err)
+
+	before, err := mat.MaterializeCollection(branch, "counters")
+	require.NoError(t, err)
+	replay, err := walService.GetReplayEntries(ctx, branch, "counters", "hot", 0, branch.HeadLSN)
+	require.NoError(t, err)
+	require.Len(t, replay, 50)
+
+	res, err := compact.NewService(walService, branchService, mat).CompactDocument(branch, "counters", "hot")
+	require.NoError(t, err)
+	assert.Equal(t, int64(50), res.Superseded)
+	assert.Equal(t, branch.HeadLSN, res.LSN)
+
+	t.Run("State is unchanged and replay shrinks", func(t *testing.T) {
+		after, err := mat.MaterializeCollection(branch, "counters")
+		require.NoError(t, err)
+		assert.Equal(t, before, after)
+
+		replay, err := walService.GetReplayEntries(ctx, branch, "counters", "hot", 0, branch.HeadLSN)
+		require.NoError(t, err)
+		require.Len(t, replay, 1)
+		assert.Equal(t, res.LSN, replay[0].LSN)
+
+		doc, err := mat.MaterializeDocument(branch, "counters", "hot")
+		require.NoError(t, err)
+		assert.Equal(t, int32(50), doc["n"])
+	})
+
+	t.Run("Earlier readers still see the full history", func(t *testing.T) {
+		doc, err := mat.MaterializeDocumentAtLSN(branch, "counters", "hot", midLSN)
+		require.NoError(t, err)
+		assert.Equal(t, int32(20), doc["n"])
+
+		childState, err := mat.MaterializeCollection(child, "counters")
+		require.NoError(t, err)
+		assert.Equal(t, before, childState)
+	})
+
+	t.Run("Writes continue after compaction", func(t *testing.T) {
+		_, err := writer.Put(ctx, "counters", bson.M{"_id": "hot", "n": int32(51)})
+		require.NoError(t, err)
+		doc, err := mat.MaterializeDocument(branch, "counters", "hot")
+		require.NoError(t, err)
+		assert.Equal(t, int32(51), doc["n"])
+	})
+
+	t.Run("Missing documents are rejected", func(t *testing.T) {
+		_, err := compact.NewService(walService, branchService, mat).CompactDocument(branch, "counters", "nope")
+		assert.Error(t, err)
+	})
+}
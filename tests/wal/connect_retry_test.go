@@ -0,0 +1,71 @@
+package wal_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/argon-lab/argon/pkg/walcli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyDeployment is unreachable for its first down pings, then available.
+type flakyDeployment struct {
+	down  int
+	pings int
+}
+
+func (f *flakyDeployment) ping(ctx context.Context) error {
+	f.pings++
+	if f.pings <= f.down {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func TestConnectRetry_WaitsForDeployment(t *testing.T) {
+	retry := walcli.ConnectRetry{Attempts: 5, Interval: time.Millisecond}
+
+	// Comes up on the third ping: connected, no further pings.
+	deployment := &flakyDeployment{down: 2}
+	require.NoError(t, retry.Wait(context.Background(), deployment.ping))
+	assert.Equal(t, 3, deployment.pings)
+
+	// Never comes up within the bound: the last error surfaces.
+	deployment = &flakyDeployment{down: 10}
+	err := retry.Wait(context.Background(), deployment.ping)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "connection refused")
+	assert.Equal(t, 5, deployment.pings)
+
+	// A cancelled context stops the wait between attempts.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	deployment = &flakyDeployment{down: 10}
+	err = walcli.ConnectRetry{Attempts: 5, Interval: time.Hour}.Wait(ctx, deployment.ping)
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, deployment.pings)
+
+	// No attempts configured: no check at all.
+	deployment = &flakyDeployment{down: 10}
+	require.NoError(t, walcli.ConnectRetry{}.Wait(context.Background(), deployment.ping))
+	assert.Zero(t, deployment.pings)
+}
+
+func TestConnectRetry_FromEnv(t *testing.T) {
+	retry, err := walcli.ConnectRetryFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, walcli.ConnectRetry{Attempts: 1, Interval: time.Second}, retry)
+
+	t.Setenv("ARGON_CONNECT_ATTEMPTS", "20")
+	t.Setenv("ARGON_CONNECT_RETRY_INTERVAL", "250ms")
+	retry, err = walcli.ConnectRetryFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, walcli.ConnectRetry{Attempts: 20, Interval: 250 * time.Millisecond}, retry)
+
+	t.Setenv("ARGON_CONNECT_RETRY_INTERVAL", "soon")
+	_, err = walcli.ConnectRetryFromEnv()
+	assert.Error(t, err)
+}
@@ -0,0 +1,187 @@
+package wal_test
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	branchwal "github.com/argon-lab/argon/internal/branch/wal"
+	"github.com/argon-lab/argon/internal/exporter"
+	"github.com/argon-lab/argon/internal/importer"
+	"github.com/argon-lab/argon/internal/materializer"
+	projectwal "github.com/argon-lab/argon/internal/project/wal"
+	"github.com/argon-lab/argon/internal/wal"
+	"github.com/argon-lab/argon/internal/walwriter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestExportDump_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	walDB := setupTestDB(t)
+	sourceDB := setupTestSourceDB(t, "test_source_dump_roundtrip")
+	createTestImportData(t, sourceDB)
+
+	walService, err := wal.NewService(walDB)
+	require.NoError(t, err)
+	branchService, err := branchwal.NewBranchService(walDB, walService)
+	require.NoError(t, err)
+	projectService, err := projectwal.NewProjectService(walDB, walService, branchService)
+	require.NoError(t, err)
+	mat := materializer.NewService(walService, branchService)
+	dumps := exporter.NewService(mat)
+
+	imported, err := importer.NewImportService(walService, projectService, branchService).ImportDatabase(ctx, importer.ImportOptions{
+		MongoURI:     getTestMongoURI(),
+		DatabaseName: "test_source_dump_roundtrip",
+		ProjectName:  "dump-project",
+		BatchSize:    100,
+	})
+	require.NoError(t, err)
+	branch, err := branchService.GetBranchByID(imported.BranchID)
+	require.NoError(t, err)
+
+	for _, gz := range []bool{false, true} {
+		t.Run(fmt.Sprintf("gzip=%v", gz), func(t *testing.T) {
+			dir := t.TempDir()
+			result, err := dumps.ExportDump(branch, dir, 0, gz)
+			require.NoError(t, err)
+			assert.Equal(t, branch.HeadLSN, result.LSN)
+			assert.EqualValues(t, 3, result.Documents)
+			require.Len(t, result.Collections, 2)
+			assert.Equal(t, "products", result.Collections[0].Name)
+
+			suffix := ""
+			if gz {
+				suffix = ".gz"
+			}
+			for _, name := range []string{"users", "products"} {
+				assert.FileExists(t, filepath.Join(dir, name+".bson"+suffix))
+				assert.FileExists(t, filepath.Join(dir, name+".metadata.json"+suffix))
+			}
+
+			// Restore into a scratch database and compare with the source.
+			scratch := restoreDump(t, dir, gz)
+			for _, name := range []string{"users", "products"} {
+				var want, got []bson.M
+				cursor, err := sourceDB.Collection(name).Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"_id": 1}))
+				require.NoError(t, err)
+				require.NoError(t, cursor.All(ctx, &want))
+				cursor, err = scratch.Collection(name).Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"_id": 1}))
+				require.NoError(t, err)
+				require.NoError(t, cursor.All(ctx, &got))
+				assert.Equal(t, want, got, name)
+			}
+		})
+	}
+
+	_, err = dumps.ExportDump(branch, t.TempDir(), branch.HeadLSN+100, false)
+	require.ErrorContains(t, err, "beyond")
+}
+
+func TestExportDump_Metadata(t *testing.T) {
+	raw, err := exporter.Metadata("users", []bson.D{
+		{{Key: "key", Value: bson.D{{Key: "email", Value: int32(1)}}}, {Key: "name", Value: "email_1"}, {Key: "unique", Value: true}},
+	})
+	require.NoError(t, err)
+	var metadata bson.M
+	require.NoError(t, bson.UnmarshalExtJSON(raw, true, &metadata))
+	assert.Equal(t, "users", metadata["collectionName"])
+	indexes := metadata["indexes"].(bson.A)
+	require.Len(t, indexes, 2)
+	assert.Equal(t, "_id_", indexes[0].(bson.M)["name"])
+	assert.Equal(t, "email_1", indexes[1].(bson.M)["name"])
+	assert.Equal(t, true, indexes[1].(bson.M)["unique"])
+	assert.EqualValues(t, 2, indexes[1].(bson.M)["v"])
+}
+
+func TestExportDump_EscapesCollectionNames(t *testing.T) {
+	assert.Equal(t, "users", exporter.EscapeCollectionName("users"))
+	assert.Equal(t, "a.b", exporter.EscapeCollectionName("a.b"))
+	assert.Equal(t, "%2E%2E%2F%2E%2E%2Fetc%2Fx", exporter.EscapeCollectionName("../../etc/x"))
+	assert.Equal(t, "50%25%5Coff%00", exporter.EscapeCollectionName("50%\\off\x00"))
+
+	walDB := setupTestDB(t)
+	walService, err := wal.NewService(walDB)
+	require.NoError(t, err)
+	branchService, err := branchwal.NewBranchService(walDB, walService)
+	require.NoError(t, err)
+	projectService, err := projectwal.NewProjectService(walDB, walService, branchService)
+	require.NoError(t, err)
+	project, err := projectService.CreateProject("dump-escape")
+	require.NoError(t, err)
+	branch, err := branchService.GetBranch(project.ID, "main")
+	require.NoError(t, err)
+	mat := materializer.NewService(walService, branchService)
+	_, err = walwriter.New(walService, branchService, mat, branch).Put(context.Background(), "../escape", bson.M{"_id": "e1"})
+	require.NoError(t, err)
+	branch, err = branchService.GetBranchByID(branch.ID)
+	require.NoError(t, err)
+
+	root := t.TempDir()
+	dir := filepath.Join(root, "out")
+	result, err := exporter.NewService(mat).ExportDump(branch, dir, 0, false)
+	require.NoError(t, err)
+	require.Len(t, result.Collections, 1)
+	assert.Equal(t, filepath.Join(dir, "%2E%2E%2Fescape.bson"), result.Collections[0].Path)
+	assert.NoFileExists(t, filepath.Join(root, "escape.bson"))
+}
+
+// restoreDump loads a dump directory into a fresh database: with
+// mongorestore when it is installed, otherwise by reading the .bson files
+// the way mongorestore does.
+func restoreDump(t *testing.T, dir string, gz bool) *mongo.Database {
+	ctx := context.Background()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(getTestMongoURI()))
+	require.NoError(t, err)
+	db := client.Database(fmt.Sprintf("argon_dump_restore_%d", time.Now().UnixNano()))
+	t.Cleanup(func() {
+		_ = db.Drop(context.Background())
+		_ = client.Disconnect(context.Background())
+	})
+
+	if bin, err := exec.LookPath("mongorestore"); err == nil {
+		args := []string{"--uri", getTestMongoURI(), "--db", db.Name(), "--dir", dir}
+		if gz {
+			args = append(args, "--gzip")
+		}
+		out, err := exec.Command(bin, args...).CombinedOutput()
+		require.NoError(t, err, "%s", out)
+		return db
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.bson*"))
+	require.NoError(t, err)
+	for _, path := range files {
+		f, err := os.Open(path)
+		require.NoError(t, err)
+		var r io.Reader = f
+		if gz {
+			zr, err := gzip.NewReader(f)
+			require.NoError(t, err)
+			r = zr
+		}
+		name := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(path), ".gz"), ".bson")
+		for {
+			doc, err := bson.NewFromIOReader(r)
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			_, err = db.Collection(name).InsertOne(ctx, doc)
+			require.NoError(t, err)
+		}
+		_ = f.Close()
+	}
+	return db
+}
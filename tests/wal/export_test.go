@@ -0,0 +1,100 @@
+package wal_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	branchwal "github.com/argon-lab/argon/internal/branch/wal"
+	"github.com/argon-lab/argon/internal/materializer"
+	projectwal "github.com/argon-lab/argon/internal/project/wal"
+	"github.com/argon-lab/argon/internal/wal"
+	"github.com/argon-lab/argon/internal/walwriter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestMaterializer_ExportToDatabase(t *testing.T) {
+	const uri = "mongodb://localhost:27017"
+	db := setupTestDB(t)
+	walService, err := wal.NewService(db)
+	require.NoError(t, err)
+	branchService, err := branchwal.NewBranchService(db, walService)
+	require.NoError(t, err)
+	projectService, err := projectwal.NewProjectService(db, walService, branchService)
+	require.NoError(t, err)
+	mat := materializer.NewService(walService, branchService)
+	ctx := context.Background()
+
+	project, err := projectService.CreateProject("export-project")
+	require.NoError(t, err)
+	main, err := branchService.GetBranch(project.ID, "main")
+	require.NoError(t, err)
+	writer := walwriter.New(walService, branchService, mat, main)
+	oid := primitive.NewObjectID()
+	_, err = writer.Put(ctx, "users", bson.M{"_id": oid, "name": "ada", "address": bson.M{"city": "Oslo"}})
+	require.NoError(t, err)
+	firstLSN, err := writer.Put(ctx, "users", bson.M{"_id": int32(7), "name": "grace"})
+	require.NoError(t, err)
+	_, err = writer.Put(ctx, "orders", bson.M{"_id": "o1", "total": 12.5})
+	require.NoError(t, err)
+	main, err = branchService.GetBranchByID(main.ID)
+	require.NoError(t, err)
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	require.NoError(t, err)
+	target := client.Database(fmt.Sprintf("argon_export_test_%d", time.Now().UnixNano()))
+	t.Cleanup(func() {
+		_ = target.Drop(context.Background())
+		_ = client.Disconnect(context.Background())
+	})
+
+	result, err := mat.ExportToDatabase(main, uri, target.Name(), 0, false)
+	require.NoError(t, err)
+	assert.Equal(t, main.HeadLSN, result.LSN)
+	assert.Equal(t, 2, result.Collections)
+	assert.EqualValues(t, 3, result.Documents)
+
+	// Read back through the driver: counts, field values and _id types.
+	count, err := target.Collection("users").CountDocuments(ctx, bson.M{})
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, count)
+	var ada bson.M
+	require.NoError(t, target.Collection("users").FindOne(ctx, bson.M{"_id": oid}).Decode(&ada))
+	assert.Equal(t, "ada", ada["name"])
+	assert.Equal(t, "Oslo", ada["address"].(bson.M)["city"])
+	var grace bson.M
+	require.NoError(t, target.Collection("users").FindOne(ctx, bson.M{"_id": int32(7)}).Decode(&grace))
+	assert.Equal(t, "grace", grace["name"])
+	var order bson.M
+	require.NoError(t, target.Collection("orders").FindOne(ctx, bson.M{"_id": "o1"}).Decode(&order))
+	assert.Equal(t, 12.5, order["total"])
+
+	// A non-empty target is refused unless dropped first.
+	_, err = mat.ExportToDatabase(main, uri, target.Name(), firstLSN, false)
+	require.ErrorContains(t, err, "not empty")
+	result, err = mat.ExportToDatabase(main, uri, target.Name(), firstLSN, true)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Collections)
+	assert.EqualValues(t, 2, result.Documents)
+	names, err := target.ListCollectionNames(ctx, bson.M{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"users"}, names)
+
+	_, err = mat.ExportToDatabase(main, uri, target.Name(), main.HeadLSN+100, true)
+	require.ErrorContains(t, err, "beyond")
+
+	// Argon's own databases are refused before anything is dropped.
+	_, err = mat.ExportToDatabase(main, uri, db.Name(), 0, true)
+	require.ErrorContains(t, err, "belongs to Argon")
+	_, err = mat.ExportToDatabase(main, uri, wal.PhysicalDBPrefix+main.ID, 0, true)
+	require.ErrorContains(t, err, "belongs to Argon")
+	walCount, err := db.Collection("wal_log").CountDocuments(ctx, bson.M{})
+	require.NoError(t, err)
+	assert.NotZero(t, walCount)
+}
@@ -0,0 +1,106 @@
+package wal_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	branchwal "github.com/argon-lab/argon/internal/branch/wal"
+	"github.com/argon-lab/argon/internal/importer"
+	"github.com/argon-lab/argon/internal/materializer"
+	projectwal "github.com/argon-lab/argon/internal/project/wal"
+	"github.com/argon-lab/argon/internal/wal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestImport_IndexesSurviveExport(t *testing.T) {
+	ctx := context.Background()
+	walDB := setupTestDB(t)
+	sourceDB := setupTestSourceDB(t, "test_source_import_indexes")
+	createTestImportData(t, sourceDB)
+	_, err := sourceDB.Collection("users").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "email", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "created_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(3600)},
+		{Keys: bson.D{{Key: "name", Value: 1}, {Key: "age", Value: -1}}, Options: options.Index().SetName("name_age")},
+	})
+	require.NoError(t, err)
+
+	walService, err := wal.NewService(walDB)
+	require.NoError(t, err)
+	branchService, err := branchwal.NewBranchService(walDB, walService)
+	require.NoError(t, err)
+	projectService, err := projectwal.NewProjectService(walDB, walService, branchService)
+	require.NoError(t, err)
+	importService := importer.NewImportService(walService, projectService, branchService)
+	mat := materializer.NewService(walService, branchService)
+
+	result, err := importService.ImportDatabase(ctx, importer.ImportOptions{
+		MongoURI:     getTestMongoURI(),
+		DatabaseName: "test_source_import_indexes",
+		ProjectName:  "import-indexes",
+	})
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, result.Indexes, "the _id index is not recorded")
+	assert.EqualValues(t, 6, result.WALEntries)
+
+	// Index entries do not change materialized state.
+	branch, err := branchService.GetBranchByID(result.BranchID)
+	require.NoError(t, err)
+	users, err := mat.MaterializeCollection(branch, "users")
+	require.NoError(t, err)
+	assert.Len(t, users, 2)
+	indexes, err := mat.IndexesAtLSN(branch, branch.HeadLSN)
+	require.NoError(t, err)
+	require.Len(t, indexes["users"], 3)
+	assert.Empty(t, indexes["products"])
+
+	// Export recreates them, options included.
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(getTestMongoURI()))
+	require.NoError(t, err)
+	target := client.Database(fmt.Sprintf("argon_index_export_%d", time.Now().UnixNano()))
+	t.Cleanup(func() {
+		_ = target.Drop(context.Background())
+		_ = client.Disconnect(context.Background())
+	})
+	exported, err := mat.ExportToDatabase(branch, getTestMongoURI(), target.Name(), 0, false)
+	require.NoError(t, err)
+	assert.Equal(t, 3, exported.Indexes)
+
+	cursor, err := target.Collection("users").Indexes().List(ctx)
+	require.NoError(t, err)
+	var listed []bson.M
+	require.NoError(t, cursor.All(ctx, &listed))
+	byName := make(map[string]bson.M)
+	for _, index := range listed {
+		byName[index["name"].(string)] = index
+	}
+	require.Len(t, byName, 4)
+	assert.Equal(t, true, byName["email_1"]["unique"])
+	assert.EqualValues(t, 3600, byName["created_at_1"]["expireAfterSeconds"])
+	assert.Equal(t, bson.M{"name": int32(1), "age": int32(-1)}, byName["name_age"]["key"])
+
+	// The unique index is enforced on the exported copy.
+	_, err = target.Collection("users").InsertOne(ctx, bson.M{"_id": "dup", "email": "john@example.com"})
+	assert.True(t, mongo.IsDuplicateKeyError(err))
+
+	// Opting out records none.
+	off := false
+	result, err = importService.ImportDatabase(ctx, importer.ImportOptions{
+		MongoURI:       getTestMongoURI(),
+		DatabaseName:   "test_source_import_indexes",
+		ProjectName:    "import-no-indexes",
+		IncludeIndexes: &off,
+	})
+	require.NoError(t, err)
+	assert.Zero(t, result.Indexes)
+	branch, err = branchService.GetBranchByID(result.BranchID)
+	require.NoError(t, err)
+	indexes, err = mat.IndexesAtLSN(branch, branch.HeadLSN)
+	require.NoError(t, err)
+	assert.Empty(t, indexes)
+}
@@ -3,6 +3,7 @@ package wal_test
 import (
 	"context"
 	"fmt"
+	"sync"
 	"testing"
 	"time"
 
@@ -318,3 +319,139 @@ func TestIngest_SecondTransactionGetsDistinctID(t *testing.T) {
 	assert.NotEqual(t, entries[0].TxnID, entries[1].TxnID,
 		"consecutive transactions on one session must not share an ID")
 }
+
+// scriptedSource is a fake change stream over a shared, growing list of
+// events; the resume token of event i is {_data: i}.
+type scriptedSource struct {
+	script *eventScript
+	pos    int
+}
+
+type eventScript struct {
+	mu      sync.Mutex
+	events  []bson.Raw
+	resumes []bson.Raw // resumeAfter tokens each open received
+}
+
+func (s *eventScript) add(t *testing.T, event bson.M) {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	event["_id"] = bson.M{"_data": int32(len(s.events) + 1)}
+	raw, err := bson.Marshal(event)
+	require.NoError(t, err)
+	s.events = append(s.events, raw)
+}
+
+func (s *eventScript) open(_ context.Context, _ *mongo.Database, resumeAfter bson.Raw) (ingest.ChangeSource, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.resumes = append(s.resumes, resumeAfter)
+	src := &scriptedSource{script: s}
+	if resumeAfter != nil {
+		src.pos = int(resumeAfter.Lookup("_data").Int32())
+	}
+	return src, nil
+}
+
+func (s *scriptedSource) TryNext(ctx context.Context) bool {
+	s.script.mu.Lock()
+	available := s.pos < len(s.script.events)
+	s.script.mu.Unlock()
+	if available {
+		s.pos++
+		return true
+	}
+	// Behave like a drained stream's bounded wait.
+	select {
+	case <-ctx.Done():
+	case <-time.After(10 * time.Millisecond):
+	}
+	return false
+}
+
+func (s *scriptedSource) Event() bson.Raw {
+	s.script.mu.Lock()
+	defer s.script.mu.Unlock()
+	return s.script.events[s.pos-1]
+}
+
+func (s *scriptedSource) ResumeToken() bson.Raw {
+	raw, _ := bson.Marshal(bson.M{"_data": int32(s.pos)})
+	return raw
+}
+
+func (s *scriptedSource) Err() error                  { return nil }
+func (s *scriptedSource) Close(context.Context) error { return nil }
+
+func insertEvent(id string, n int32) bson.M {
+	return bson.M{
+		"operationType": "insert",
+		"ns":            bson.M{"coll": "docs"},
+		"documentKey":   bson.M{"_id": id},
+		"fullDocument":  bson.M{"_id": id, "n": n},
+	}
+}
+
+func TestIngest_ResumeTokenDeduplicatesRedelivery(t *testing.T) {
+	f := newIngestFixture(t, "ingest-dedup")
+	script := &eventScript{}
+	f.ingest.SetSourceOpener(script.open)
+	defer f.ingest.SetSourceOpener(nil)
+
+	for i := 1; i <= 3; i++ {
+		script.add(t, insertEvent(fmt.Sprintf("d%d", i), int32(i)))
+	}
+	stop := f.startIngester(t)
+	f.waitForEntries(t, "docs", 3)
+	stop()
+
+	// Simulate a crash after the last two entries were appended but before
+	// their token was persisted: the checkpoint is back at the first event.
+	entries, err := f.wal.GetBranchEntries(f.branchID, "docs", 0, 1<<62)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	token, err := bson.Marshal(bson.M{"_data": int32(1)})
+	require.NoError(t, err)
+	_, err = f.metaDB.Collection("wal_ingest_state").UpdateOne(context.Background(),
+		bson.M{"_id": f.branchID},
+		bson.M{"$set": bson.M{"resume_token": bson.Raw(token), "lsn": entries[0].LSN}})
+	require.NoError(t, err)
+
+	// Events that arrive while the ingester is down.
+	script.add(t, insertEvent("d4", 4))
+	script.add(t, insertEvent("d2", 20)) // a genuine later change to d2
+
+	stop = f.startIngester(t)
+	f.waitForEntries(t, "docs", 5)
+	stop()
+
+	script.mu.Lock()
+	require.Len(t, script.resumes, 2)
+	assert.Nil(t, script.resumes[0], "first run starts without a token")
+	assert.EqualValues(t, 1, script.resumes[1].Lookup("_data").Int32(), "restart resumes after the persisted token")
+	script.mu.Unlock()
+
+	branch, err := f.branches.GetBranchByID(f.branchID)
+	require.NoError(t, err)
+	entries, err = f.wal.GetBranchEntries(f.branchID, "docs", 0, branch.HeadLSN)
+	require.NoError(t, err)
+	ids := make([]string, len(entries))
+	for i, e := range entries {
+		ids[i] = e.DocumentID
+	}
+	assert.Equal(t, []string{"d1", "d2", "d3", "d4", "d2"}, ids,
+		"re-delivered d2 and d3 must not be appended twice")
+
+	state, err := f.matFull.MaterializeCollection(branch, "docs")
+	require.NoError(t, err)
+	assert.EqualValues(t, 20, state["d2"]["n"])
+	assert.Len(t, state, 4)
+
+	// A clean restart past everything re-delivers nothing.
+	stop = f.startIngester(t)
+	stop()
+	after, err := f.wal.GetBranchEntries(f.branchID, "docs", 0, 1<<62)
+	require.NoError(t, err)
+	assert.Len(t, after, 5)
+}
@@ -0,0 +1,89 @@
+package wal_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/argon-lab/argon/internal/config"
+	"github.com/argon-lab/argon/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// captureLogs routes the default logger to a JSON buffer for the rest of
+// the test.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(logging.New(&buf, config.LogFormatJSON, slog.LevelDebug))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &buf
+}
+
+// logLine returns the first JSON log line with message msg.
+func logLine(t *testing.T, buf *bytes.Buffer, msg string) map[string]interface{} {
+	t.Helper()
+	scanner := bufio.NewScanner(bytes.NewReader(buf.Bytes()))
+	for scanner.Scan() {
+		var line map[string]interface{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &line), "not a JSON log line: %s", scanner.Text())
+		if line["msg"] == msg {
+			return line
+		}
+	}
+	t.Fatalf("no %q log line in:\n%s", msg, buf.String())
+	return nil
+}
+
+func TestLogging_BranchCreateJSON(t *testing.T) {
+	db := setupTestDB(t)
+	f := newSnapshotFixture(t, db)
+	logs := captureLogs(t)
+
+	main, err := f.branches.CreateBranch("logging", "main", "")
+	require.NoError(t, err)
+	feature, err := f.branches.CreateBranch("logging", "feature", main.ID)
+	require.NoError(t, err)
+
+	line := logLine(t, logs, "branch created")
+	for _, key := range []string{"time", "level", "msg", "project_id", "branch_id", "lsn", "operation"} {
+		assert.Contains(t, line, key)
+	}
+	assert.Equal(t, "INFO", line["level"])
+	assert.Equal(t, "logging", line["project_id"])
+	assert.Equal(t, main.ID, line["branch_id"])
+	assert.Equal(t, "create_branch", line["operation"])
+
+	require.NoError(t, f.branches.DeleteBranch("logging", "feature"))
+	deleted := logLine(t, logs, "branch deleted")
+	assert.Equal(t, feature.ID, deleted["branch_id"])
+	assert.Equal(t, "delete_branch", deleted["operation"])
+}
+
+func TestLogging_ConfigFromEnv(t *testing.T) {
+	t.Setenv("LOG_FORMAT", "")
+	t.Setenv("LOG_LEVEL", "")
+	cfg := config.Load()
+	assert.Equal(t, config.LogFormatText, cfg.LogFormat)
+	assert.Equal(t, slog.LevelInfo, cfg.LogLevel)
+
+	t.Setenv("LOG_FORMAT", "JSON")
+	t.Setenv("LOG_LEVEL", "debug")
+	cfg = config.Load()
+	assert.Equal(t, config.LogFormatJSON, cfg.LogFormat)
+	assert.Equal(t, slog.LevelDebug, cfg.LogLevel)
+
+	// Unrecognized values fall back rather than fail startup.
+	t.Setenv("LOG_FORMAT", "xml")
+	t.Setenv("LOG_LEVEL", "loud")
+	cfg = config.Load()
+	assert.Equal(t, config.LogFormatText, cfg.LogFormat)
+	assert.Equal(t, slog.LevelInfo, cfg.LogLevel)
+
+	var buf bytes.Buffer
+	logging.New(&buf, config.LogFormatJSON, slog.LevelWarn).Info("dropped")
+	assert.Empty(t, buf.String())
+}
@@ -2,7 +2,9 @@ package wal_test
 
 import (
 	"context"
+	"fmt"
 	"testing"
+	"time"
 
 	branchwal "github.com/argon-lab/argon/internal/branch/wal"
 	"github.com/argon-lab/argon/internal/materializer"
@@ -11,6 +13,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
@@ -276,4 +279,294 @@ func TestMaterializer_Ancestry(t *testing.T) {
 		assert.Contains(t, state, "a", "root entries inherited")
 		assert.Contains(t, state, "g2", "parent entries inherited")
 	})
+
+	t.Run("Every level writes and stitches at its own LSNs", func(t *testing.T) {
+		root, err := branchService.CreateBranch("ancestry-levels", "main", "")
+		require.NoError(t, err)
+		put := func(branch *wal.Branch, id, v string) int64 {
+			lsn, err := walwriter.New(walService, branchService, mat, branch).Put(ctx, "items", bson.M{"_id": id, "v": v})
+			require.NoError(t, err)
+			return lsn
+		}
+		refresh := func(branch *wal.Branch) *wal.Branch {
+			b, err := branchService.GetBranchByID(branch.ID)
+			require.NoError(t, err)
+			return b
+		}
+
+		put(root, "shared", "root")
+		rootOnlyLSN := put(root, "root-only", "root")
+		root = refresh(root)
+		feature, err := branchService.CreateBranch("ancestry-levels", "feature", root.ID)
+		require.NoError(t, err)
+		put(root, "root-late", "after feature fork")
+
+		featureLSN := put(feature, "shared", "feature")
+		feature = refresh(feature)
+		sub, err := branchService.CreateBranch("ancestry-levels", "sub-feature", feature.ID)
+		require.NoError(t, err)
+		put(feature, "feature-late", "after sub fork")
+
+		subLSN := put(sub, "sub-only", "sub")
+		sub = refresh(sub)
+
+		state, err := mat.MaterializeCollection(sub, "items")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{
+			"shared":    "feature",
+			"root-only": "root",
+			"sub-only":  "sub",
+		}, values(state), "each ancestor contributes only its writes up to the fork")
+
+		// Reads below the head stop at the right point in each segment.
+		for _, tc := range []struct {
+			lsn  int64
+			want map[string]string
+		}{
+			{rootOnlyLSN, map[string]string{"shared": "root", "root-only": "root"}},
+			{featureLSN, map[string]string{"shared": "feature", "root-only": "root"}},
+			{subLSN, map[string]string{"shared": "feature", "root-only": "root", "sub-only": "sub"}},
+		} {
+			state, err := mat.MaterializeCollectionAtLSN(sub, "items", tc.lsn)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, values(state), "at LSN %d", tc.lsn)
+		}
+	})
+}
+
+// values maps each document ID to its "v" field.
+func values(state map[string]bson.M) map[string]string {
+	out := make(map[string]string, len(state))
+	for id, doc := range state {
+		out[id], _ = doc["v"].(string)
+	}
+	return out
+}
+
+func TestMaterializer_LenientSkipsCorruptEntries(t *testing.T) {
+	db := setupTestDB(t)
+	walService, branchService, mat, branch, writer := newMaterializerFixture(t, db, "lenient-project", "main")
+	ctx := context.Background()
+
+	_, err := writer.Put(ctx, "docs", bson.M{"_id": "a", "v": int32(1)})
+	require.NoError(t, err)
+
+	// A put whose post-image is not a BSON document: it passes the append
+	// boundary (which only checks presence) but cannot be replayed.
+	corruptLSN, err := walService.Append(&wal.Entry{
+		ProjectID:  branch.ProjectID,
+		BranchID:   branch.ID,
+		Operation:  wal.OpPut,
+		Collection: "docs",
+		DocumentID: "a",
+		PostImage:  bson.Raw{0x01, 0x02, 0x03},
+	})
+	require.NoError(t, err)
+	require.NoError(t, branchService.UpdateBranchHead(branch.ID, corruptLSN, time.Now()))
+
+	_, err = writer.Put(ctx, "docs", bson.M{"_id": "b", "v": int32(2)})
+	require.NoError(t, err)
+	branch, _ = branchService.GetBranchByID(branch.ID)
+
+	t.Run("Strict mode is the default and fails", func(t *testing.T) {
+		_, err := mat.MaterializeCollection(branch, "docs")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), fmt.Sprintf("failed to apply entry LSN %d", corruptLSN))
+	})
+
+	t.Run("Lenient mode skips and reports", func(t *testing.T) {
+		state, skipped, err := mat.MaterializeCollectionAtLSNLenient(branch, "docs", branch.HeadLSN)
+		require.NoError(t, err)
+		require.Len(t, skipped, 1)
+		assert.Equal(t, corruptLSN, skipped[0].LSN)
+		assert.Equal(t, "a", skipped[0].DocumentID)
+		assert.NotEmpty(t, skipped[0].Reason)
+
+		require.Len(t, state, 2)
+		assert.EqualValues(t, 1, state["a"]["v"], "the skipped put leaves the previous state")
+		assert.EqualValues(t, 2, state["b"]["v"], "replay continues past the bad entry")
+	})
+
+	t.Run("Clean reads report nothing", func(t *testing.T) {
+		_, skipped, err := mat.MaterializeCollectionAtLSNLenient(branch, "docs", corruptLSN-1)
+		require.NoError(t, err)
+		assert.Empty(t, skipped)
+	})
+}
+
+func TestMaterializer_SlowQueryLog(t *testing.T) {
+	db := setupTestDB(t)
+	_, branchService, mat, branch, writer := newMaterializerFixture(t, db, "slow-project", "main")
+	ctx := context.Background()
+
+	metrics := wal.NewMetrics()
+	mat.SetMetrics(metrics)
+
+	docs := make([]bson.M, 0, 500)
+	for i := 0; i < 500; i++ {
+		docs = append(docs, bson.M{"_id": fmt.Sprintf("doc-%03d", i), "n": int32(i)})
+	}
+	_, err := writer.PutMany(ctx, "big", docs)
+	require.NoError(t, err)
+	branch, _ = branchService.GetBranchByID(branch.ID)
+
+	t.Run("Fast reads are not logged", func(t *testing.T) {
+		mat.SlowQueries().SetThreshold(time.Hour)
+		_, err := mat.MaterializeCollection(branch, "big")
+		require.NoError(t, err)
+		assert.Empty(t, mat.SlowQueries().Entries())
+		assert.EqualValues(t, 1, metrics.GetSnapshot().MaterialOps)
+	})
+
+	t.Run("Reads over the threshold are logged", func(t *testing.T) {
+		mat.SlowQueries().SetThreshold(time.Microsecond)
+		_, err := mat.MaterializeCollection(branch, "big")
+		require.NoError(t, err)
+		_, err = mat.MaterializeCollectionAtLSN(branch, "big", branch.HeadLSN-100)
+		require.NoError(t, err)
+
+		logged := mat.SlowQueries().Entries()
+		require.Len(t, logged, 2)
+
+		travel, head := logged[0], logged[1] // newest first
+		assert.Equal(t, wal.SlowQueryMaterialize, head.Kind)
+		assert.Equal(t, branch.ID, head.BranchID)
+		assert.Equal(t, "big", head.Collection)
+		assert.Equal(t, branch.HeadLSN, head.ToLSN)
+		assert.Equal(t, 500, head.EntriesReplayed)
+		assert.GreaterOrEqual(t, head.Duration, time.Microsecond)
+
+		assert.Equal(t, wal.SlowQueryTimeTravel, travel.Kind)
+		assert.Equal(t, branch.HeadLSN-100, travel.ToLSN)
+		assert.Equal(t, 400, travel.EntriesReplayed)
+		assert.LessOrEqual(t, travel.FromLSN, travel.ToLSN)
+	})
+}
+
+func TestMaterializer_ApplyEntriesFrom(t *testing.T) {
+	db := setupTestDB(t)
+	walService, branchService, mat, branch, writer := newMaterializerFixture(t, db, "delta-project", "main")
+	ctx := context.Background()
+
+	// A consumer's cache, advanced in steps and checked against a full
+	// materialization at every step.
+	var cache map[string]bson.M
+	var cachedLSN int64
+	advance := func(t *testing.T, b *wal.Branch) {
+		t.Helper()
+		b, err := branchService.GetBranchByID(b.ID)
+		require.NoError(t, err)
+		next, err := mat.ApplyEntriesFrom(cache, b, "items", cachedLSN, b.HeadLSN)
+		require.NoError(t, err)
+		full, err := mat.MaterializeCollection(b, "items")
+		require.NoError(t, err)
+		assert.Equal(t, full, next)
+		cache, cachedLSN = next, b.HeadLSN
+	}
+
+	_, err := writer.PutMany(ctx, "items", []bson.M{{"_id": "a", "v": int32(1)}, {"_id": "b", "v": int32(1)}})
+	require.NoError(t, err)
+	advance(t, branch)
+	require.Len(t, cache, 2)
+
+	snapshot := cache
+	_, err = writer.Put(ctx, "items", bson.M{"_id": "a", "v": int32(2)})
+	require.NoError(t, err)
+	_, _, err = writer.Delete(ctx, "items", "b")
+	require.NoError(t, err)
+	_, err = writer.Put(ctx, "other", bson.M{"_id": "x"})
+	require.NoError(t, err)
+	advance(t, branch)
+	assert.Len(t, snapshot, 2, "the caller's initial state is not modified")
+	assert.Equal(t, int32(2), cache["a"]["v"])
+	assert.NotContains(t, cache, "b")
+
+	// Continue on a child: the range now spans the fork point.
+	branch, _ = branchService.GetBranchByID(branch.ID)
+	child, err := branchService.CreateBranch(branch.ProjectID, "delta-child", branch.ID)
+	require.NoError(t, err)
+	childWriter := walwriter.New(walService, branchService, mat, child)
+	_, err = childWriter.Put(ctx, "items", bson.M{"_id": "c", "v": int32(3)})
+	require.NoError(t, err)
+	advance(t, child)
+	assert.Len(t, cache, 2)
+
+	_, err = mat.ApplyEntriesFrom(nil, branch, "items", branch.HeadLSN, branch.HeadLSN-1)
+	assert.Error(t, err)
+}
+
+func TestMaterializer_CommittedOnly(t *testing.T) {
+	db := setupTestDB(t)
+	walService, branchService, mat, branch, writer := newMaterializerFixture(t, db, "committed-project", "main")
+	ctx := context.Background()
+
+	_, err := writer.Put(ctx, "orders", bson.M{"_id": "o1", "status": "new"})
+	require.NoError(t, err)
+
+	// A two-phase append whose confirmation has not happened yet.
+	pending := &wal.Entry{
+		ProjectID:  branch.ProjectID,
+		BranchID:   branch.ID,
+		Operation:  wal.OpPut,
+		Collection: "orders",
+		DocumentID: "o2",
+		PostImage:  mustMarshalBSON(bson.M{"_id": "o2", "status": "new"}),
+	}
+	lsn, err := walService.AppendPending(pending)
+	require.NoError(t, err)
+	require.NoError(t, branchService.UpdateBranchHead(branch.ID, lsn, time.Now()))
+	branch, err = branchService.GetBranchByID(branch.ID)
+	require.NoError(t, err)
+
+	state, err := mat.MaterializeCollection(branch, "orders")
+	require.NoError(t, err)
+	assert.Len(t, state, 2, "default reads include unconfirmed entries")
+
+	committed, err := mat.MaterializeCollectionCommittedAtLSN(branch, "orders", branch.HeadLSN)
+	require.NoError(t, err)
+	assert.Len(t, committed, 1)
+	assert.Contains(t, committed, "o1")
+	assert.NotContains(t, committed, "o2")
+
+	require.NoError(t, walService.ConfirmEntry(branch.ProjectID, lsn))
+	committed, err = mat.MaterializeCollectionCommittedAtLSN(branch, "orders", branch.HeadLSN)
+	require.NoError(t, err)
+	assert.Contains(t, committed, "o2", "confirmed entries are committed")
+
+	assert.Error(t, walService.ConfirmEntry(branch.ProjectID, lsn), "already confirmed")
+}
+
+func TestMaterializer_SortedDocuments(t *testing.T) {
+	oid := primitive.NewObjectID()
+	state := map[string]bson.M{
+		"b":       {"_id": "b", "rank": int32(1)},
+		"a":       {"_id": "a", "rank": int32(2)},
+		"10":      {"_id": int32(10), "rank": int32(1)},
+		"9":       {"_id": int64(9)},
+		oid.Hex(): {"_id": oid, "rank": 1.5},
+		`{"k":1}`: {"_id": bson.M{"k": int32(1)}, "rank": int32(0)},
+		"c":       {"_id": "c", "meta": bson.M{"rank": int32(3)}},
+	}
+	ids := func(docs []bson.M) []interface{} {
+		out := make([]interface{}, len(docs))
+		for i, d := range docs {
+			out[i] = d["_id"]
+		}
+		return out
+	}
+
+	// Map iteration order varies run to run; the result must not.
+	first := ids(materializer.SortedDocuments(state))
+	for i := 0; i < 20; i++ {
+		require.Equal(t, first, ids(materializer.SortedDocuments(state)))
+	}
+	// Numbers compare numerically and before strings.
+	assert.Equal(t, []interface{}{int64(9), int32(10), "a", "b", "c", oid, bson.M{"k": int32(1)}}, first)
+
+	// A field sort keeps _id order among equal (and missing) values.
+	docs := materializer.SortedDocuments(state)
+	materializer.SortDocuments(docs, "rank", false)
+	assert.Equal(t, []interface{}{int64(9), "c", bson.M{"k": int32(1)}, int32(10), "b", oid, "a"}, ids(docs))
+	materializer.SortDocuments(docs, "meta.rank", true)
+	assert.Equal(t, "c", docs[0]["_id"])
 }
@@ -213,6 +213,25 @@ func TestMerge_ConflictStrategies(t *testing.T) {
 		require.NoError(t, err)
 		assert.Equal(t, "ours", state["contested"]["v"])
 	})
+
+	t.Run("Strategy abort refuses and leaves the plan pending", func(t *testing.T) {
+		f := setup("merge-conflict-d")
+		plan, err := f.merge.Preview(ctx, f.feature.ID)
+		require.NoError(t, err)
+
+		_, err = f.merge.Apply(ctx, plan.ID, merge.StrategyAbort)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "aborted")
+
+		stored, err := f.merge.GetPlan(ctx, plan.ID)
+		require.NoError(t, err)
+		assert.Equal(t, merge.StatusPending, stored.Status)
+
+		f.refresh(t)
+		state, err := f.matFull.MaterializeCollection(f.main, "docs")
+		require.NoError(t, err)
+		assert.Equal(t, "ours", state["contested"]["v"])
+	})
 }
 
 func TestMerge_DeleteVersusModifyConflict(t *testing.T) {
@@ -300,3 +319,119 @@ func TestMerge_IntoLiveTarget(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, f.physicalState(t, "docs"), walState)
 }
+
+func TestMerge_DiffBranches(t *testing.T) {
+	db := setupTestDB(t)
+	f := newMergeFixture(t, db, "merge-diff")
+	ctx := context.Background()
+
+	_, err := f.featWriter.Put(ctx, "docs", bson.M{"_id": "contested", "v": "feature", "meta": bson.M{"rev": int32(2), "by": "ann"}})
+	require.NoError(t, err)
+	_, err = f.featWriter.Put(ctx, "docs", bson.M{"_id": "new", "v": "feature"})
+	require.NoError(t, err)
+	_, _, err = f.featWriter.Delete(ctx, "docs", "doomed")
+	require.NoError(t, err)
+	_, err = f.mainWriter.Put(ctx, "docs", bson.M{"_id": "contested", "v": "main", "meta": bson.M{"rev": int32(1), "by": "ann"}})
+	require.NoError(t, err)
+	_, err = f.mainWriter.Put(ctx, "other", bson.M{"_id": "o1"})
+	require.NoError(t, err)
+	f.refresh(t)
+
+	diff, err := f.merge.DiffBranches(f.main, f.feature, "docs")
+	require.NoError(t, err)
+	assert.Equal(t, f.main.HeadLSN, diff.LSNA)
+	assert.Equal(t, f.feature.HeadLSN, diff.LSNB)
+	require.Len(t, diff.Documents, 3, "stable is identical on both sides and left out")
+	contested, doomed, added := diff.Documents[0], diff.Documents[1], diff.Documents[2]
+	assert.Equal(t, "contested", contested.DocumentID)
+	assert.Equal(t, merge.DiffChanged, contested.Kind)
+	assert.Equal(t, []merge.FieldDiff{
+		{Path: "meta.rev", A: int32(1), B: int32(2)},
+		{Path: "v", A: "main", B: "feature"},
+	}, contested.Fields)
+	assert.Equal(t, merge.DiffRemoved, doomed.Kind)
+	assert.Empty(t, doomed.Fields)
+	assert.Equal(t, merge.DiffAdded, added.Kind)
+	assert.Equal(t, "new", added.DocumentID)
+
+	// Every collection, and the reverse direction.
+	diff, err = f.merge.DiffBranches(f.feature, f.main, "")
+	require.NoError(t, err)
+	kinds := map[string]string{}
+	for _, d := range diff.Documents {
+		kinds[d.Collection+"/"+d.DocumentID] = d.Kind
+	}
+	assert.Equal(t, map[string]string{
+		"docs/contested": merge.DiffChanged,
+		"docs/doomed":    merge.DiffAdded,
+		"docs/new":       merge.DiffRemoved,
+		"other/o1":       merge.DiffAdded,
+	}, kinds)
+
+	diff, err = f.merge.DiffBranches(f.main, f.main, "")
+	require.NoError(t, err)
+	assert.Empty(t, diff.Documents)
+}
+
+func TestMerge_DiffBranchesAtLSN(t *testing.T) {
+	db := setupTestDB(t)
+	f := newMergeFixture(t, db, "merge-diff-lsn")
+	ctx := context.Background()
+	forkLSN := f.main.HeadLSN
+
+	_, err := f.featWriter.Put(ctx, "docs", bson.M{"_id": "contested", "v": "feature"})
+	require.NoError(t, err)
+	_, err = f.featWriter.Put(ctx, "docs", bson.M{"_id": "new", "v": "feature"})
+	require.NoError(t, err)
+	_, _, err = f.featWriter.Delete(ctx, "docs", "doomed")
+	require.NoError(t, err)
+	// Main moves on after the fork; the earlier main state must not see it.
+	_, err = f.mainWriter.Put(ctx, "docs", bson.M{"_id": "stable", "v": "main"})
+	require.NoError(t, err)
+	_, err = f.mainWriter.Put(ctx, "other", bson.M{"_id": "o1"})
+	require.NoError(t, err)
+	f.refresh(t)
+
+	kinds := func(diff *merge.BranchDiff) map[string]string {
+		out := map[string]string{}
+		for _, d := range diff.Documents {
+			out[d.Collection+"/"+d.DocumentID] = d.Kind
+		}
+		return out
+	}
+
+	// Main as of the fork against feature now.
+	diff, err := f.merge.DiffBranchesAtLSN(f.main, forkLSN, f.feature, f.feature.HeadLSN, "docs")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"docs/contested": merge.DiffChanged,
+		"docs/doomed":    merge.DiffRemoved,
+		"docs/new":       merge.DiffAdded,
+	}, kinds(diff))
+	require.Equal(t, "docs", diff.Documents[0].Collection)
+	assert.Equal(t, "contested", diff.Documents[0].DocumentID, "ordered by ID")
+	assert.Equal(t, "base", diff.Documents[0].A["v"])
+	assert.Equal(t, "feature", diff.Documents[0].B["v"])
+
+	// Main now, across all collections, picks up main's own later writes.
+	diff, err = f.merge.DiffBranchesAtLSN(f.main, f.main.HeadLSN, f.feature, f.feature.HeadLSN, "")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"docs/contested": merge.DiffChanged,
+		"docs/doomed":    merge.DiffRemoved,
+		"docs/new":       merge.DiffAdded,
+		"docs/stable":    merge.DiffChanged,
+		"other/o1":       merge.DiffRemoved,
+	}, kinds(diff))
+
+	// A branch against its own past.
+	diff, err = f.merge.DiffBranchesAtLSN(f.main, forkLSN, f.main, forkLSN, "")
+	require.NoError(t, err)
+	assert.Empty(t, diff.Documents)
+
+	// LSNs outside either branch's range are refused.
+	_, err = f.merge.DiffBranchesAtLSN(f.main, f.main.HeadLSN+1, f.feature, f.feature.HeadLSN, "docs")
+	assert.Error(t, err)
+	_, err = f.merge.DiffBranchesAtLSN(f.main, forkLSN, f.feature, f.feature.BaseLSN-1, "docs")
+	assert.Error(t, err)
+}
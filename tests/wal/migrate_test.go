@@ -3,6 +3,7 @@ package wal_test
 import (
 	"context"
 	"testing"
+	"time"
 
 	branchwal "github.com/argon-lab/argon/internal/branch/wal"
 	"github.com/argon-lab/argon/internal/materializer"
@@ -81,7 +82,7 @@ func TestMigrate_LegacyWAL(t *testing.T) {
 			"update": bson.M{"$set": bson.M{"x": 1}},
 		})
 	lastLSN := noopLSN
-	require.NoError(t, branchService.UpdateBranchHead(branch.ID, lastLSN))
+	require.NoError(t, branchService.UpdateBranchHead(branch.ID, lastLSN, time.Now()))
 	branch, err = branchService.GetBranchByID(branch.ID)
 	require.NoError(t, err)
 
@@ -151,7 +152,7 @@ func TestMigrate_MixedHistoryAndBranches(t *testing.T) {
 		wal.LegacyOpInsert, "items", "i1", bson.M{"_id": "i1", "v": int32(1)})
 	lsn := insertLegacyEntry(t, db, walService, compressor, "mixed-project", main.ID,
 		wal.LegacyOpInsert, "items", "i2", bson.M{"_id": "i2", "v": int32(2)})
-	require.NoError(t, branchService.UpdateBranchHead(main.ID, lsn))
+	require.NoError(t, branchService.UpdateBranchHead(main.ID, lsn, time.Now()))
 	main, _ = branchService.GetBranchByID(main.ID)
 
 	// Fork a child at the legacy point, then write v2 entries on both.
@@ -170,7 +171,7 @@ func TestMigrate_MixedHistoryAndBranches(t *testing.T) {
 	})
 	require.NoError(t, err)
 	childHead := walService.GetCurrentLSN("mixed-project")
-	require.NoError(t, branchService.UpdateBranchHead(child.ID, childHead))
+	require.NoError(t, branchService.UpdateBranchHead(child.ID, childHead, time.Now()))
 
 	// Migrate: parent's legacy prefix must be rewritten and the child's
 	// ancestry (which crosses the migrated segment) must materialize.
@@ -184,3 +185,60 @@ func TestMigrate_MixedHistoryAndBranches(t *testing.T) {
 	assert.EqualValues(t, 1, state["i1"]["v"])
 	assert.EqualValues(t, 3, state["i3"]["v"])
 }
+
+// TestMigrate_ArraySetOperators resolves legacy $addToSet/$pull/$pop
+// updates into post-images and checks every intermediate state, not just
+// the final one: a reviewer added twice must count once at each LSN.
+func TestMigrate_ArraySetOperators(t *testing.T) {
+	db := setupTestDB(t)
+	walService, err := wal.NewService(db)
+	require.NoError(t, err)
+	branchService, err := branchwal.NewBranchService(db, walService)
+	require.NoError(t, err)
+	mat := materializer.NewService(walService, branchService)
+	migrator, err := migrate.NewService(db, branchService, mat)
+	require.NoError(t, err)
+	compressor, err := wal.NewCompressor(nil)
+	require.NoError(t, err)
+
+	branch, err := branchService.CreateBranch("array-project", "main", "")
+	require.NoError(t, err)
+	update := func(set bson.M) int64 {
+		return insertLegacyEntry(t, db, walService, compressor, "array-project", branch.ID,
+			wal.LegacyOpUpdate, "articles", "", bson.M{"filter": bson.M{"_id": "a1"}, "update": set})
+	}
+
+	steps := []struct {
+		lsn  int64
+		want bson.A
+	}{
+		{insertLegacyEntry(t, db, walService, compressor, "array-project", branch.ID,
+			wal.LegacyOpInsert, "articles", "a1", bson.M{"_id": "a1", "title": "draft"}), nil},
+		{update(bson.M{"$addToSet": bson.M{"reviewers": "r1"}}), bson.A{"r1"}},
+		{update(bson.M{"$addToSet": bson.M{"reviewers": "r1"}}), bson.A{"r1"}},
+		{update(bson.M{"$addToSet": bson.M{"reviewers": bson.M{"$each": bson.A{"r2", "r1", "r3"}}}}), bson.A{"r1", "r2", "r3"}},
+		{update(bson.M{"$pull": bson.M{"reviewers": "r1"}}), bson.A{"r2", "r3"}},
+		{update(bson.M{"$addToSet": bson.M{"reviewers": "r1"}}), bson.A{"r2", "r3", "r1"}},
+		{update(bson.M{"$pop": bson.M{"reviewers": -1}}), bson.A{"r3", "r1"}},
+		{update(bson.M{"$pop": bson.M{"reviewers": 1}}), bson.A{"r3"}},
+		{update(bson.M{"$pop": bson.M{"missing": 1}}), bson.A{"r3"}},
+	}
+	head := steps[len(steps)-1].lsn
+	require.NoError(t, branchService.UpdateBranchHead(branch.ID, head, time.Now()))
+	branch, err = branchService.GetBranchByID(branch.ID)
+	require.NoError(t, err)
+
+	_, err = migrator.MigrateProject(context.Background(), "array-project")
+	require.NoError(t, err)
+
+	for _, step := range steps {
+		state, err := mat.MaterializeCollectionAtLSN(branch, "articles", step.lsn)
+		require.NoError(t, err)
+		require.Contains(t, state, "a1", "LSN %d", step.lsn)
+		if step.want == nil {
+			assert.NotContains(t, state["a1"], "reviewers", "LSN %d", step.lsn)
+			continue
+		}
+		assert.Equal(t, step.want, state["a1"]["reviewers"], "LSN %d", step.lsn)
+	}
+}
@@ -0,0 +1,683 @@
+package wal_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/argon-lab/argon/internal/mongoexpr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// naiveMatch evaluates every condition of a filter — no short-circuiting,
+// no reordering — and counts the field conditions it evaluated.
+func naiveMatch(t testing.TB, doc, filter bson.M, evals *int) bool {
+	result := true
+	for key, value := range filter {
+		switch key {
+		case "$and", "$or", "$nor":
+			var results []bool
+			for _, branch := range value.(bson.A) {
+				results = append(results, naiveMatch(t, doc, branch.(bson.M), evals))
+			}
+			anyTrue, allTrue := false, true
+			for _, r := range results {
+				anyTrue = anyTrue || r
+				allTrue = allTrue && r
+			}
+			switch key {
+			case "$and":
+				result = result && allTrue
+			case "$or":
+				result = result && anyTrue
+			case "$nor":
+				result = result && !anyTrue
+			}
+		default:
+			*evals++
+			ok, err := mongoexpr.MatchesFilter(doc, bson.M{key: value})
+			require.NoError(t, err)
+			result = result && ok
+		}
+	}
+	return result
+}
+
+func filterCorpus() ([]bson.M, []bson.M) {
+	var docs []bson.M
+	for i := 0; i < 200; i++ {
+		docs = append(docs, bson.M{
+			"_id":    fmt.Sprintf("d%03d", i),
+			"status": []string{"active", "archived", "pending"}[i%3],
+			"score":  int32(i % 17),
+			"bio":    fmt.Sprintf("user %d likes %s", i, []string{"go", "rust", "mongo"}[i%3]),
+			"tags":   bson.A{fmt.Sprintf("t%d", i%5), "common"},
+			"orders": bson.A{bson.M{"sku": fmt.Sprintf("s%d", i%7), "qty": int32(i % 4)}},
+		})
+	}
+	filters := []bson.M{
+		{"status": "active", "bio": bson.M{"$regex": "mongo$"}},
+		{"bio": bson.M{"$regex": "^user 1"}, "score": bson.M{"$gte": 5, "$lt": 10}},
+		{"$or": bson.A{bson.M{"status": "pending"}, bson.M{"orders": bson.M{"$elemMatch": bson.M{"qty": 3}}}}},
+		{"$and": bson.A{bson.M{"tags": bson.M{"$all": bson.A{"common", "t2"}}}, bson.M{"score": bson.M{"$ne": 4}}}},
+		{"$nor": bson.A{bson.M{"status": "archived"}, bson.M{"bio": bson.M{"$regex": "rust"}}}, "score": bson.M{"$in": bson.A{1, 2, 3}}},
+		{"$or": bson.A{
+			bson.M{"$and": bson.A{bson.M{"status": "active"}, bson.M{"score": bson.M{"$gt": 10}}}},
+			bson.M{"bio": bson.M{"$not": bson.M{"$regex": "go"}}, "tags": "t1"},
+		}},
+	}
+	return docs, filters
+}
+
+func TestMongoexpr_CompiledMatchesNaiveEvaluation(t *testing.T) {
+	docs, filters := filterCorpus()
+	for i, filter := range filters {
+		m, err := mongoexpr.CompileInstrumentedFilter(filter)
+		require.NoError(t, err)
+		naiveEvals := 0
+		for _, doc := range docs {
+			want := naiveMatch(t, doc, filter, &naiveEvals)
+			got, err := m.Match(doc)
+			require.NoError(t, err)
+			assert.Equal(t, want, got, "filter %d, doc %s", i, doc["_id"])
+		}
+		assert.LessOrEqual(t, m.Evaluations(), int64(naiveEvals), "filter %d", i)
+	}
+
+	// Cheap conditions run first: a failed equality skips the regex.
+	m, err := mongoexpr.CompileInstrumentedFilter(bson.M{"bio": bson.M{"$regex": "go"}, "status": "none"})
+	require.NoError(t, err)
+	ok, err := m.Match(docs[0])
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, int64(1), m.Evaluations())
+
+	_, err = mongoexpr.CompileFilter(bson.M{"$where": "this.a > 1"})
+	assert.Error(t, err)
+
+	// Production matchers do not count.
+	plain, err := mongoexpr.CompileFilter(bson.M{"status": "active"})
+	require.NoError(t, err)
+	_, err = plain.Match(docs[0])
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), plain.Evaluations())
+}
+
+func TestMongoexpr_CompileRejectsUnsupportedOperators(t *testing.T) {
+	// A cheap mismatch ordered first must not hide an unsupported
+	// operator behind it: compilation fails before any document is seen.
+	for _, filter := range []bson.M{
+		{"a": 1, "b": bson.M{"$bogus": 1}},
+		{"orders": bson.M{"$elemMatch": bson.M{"qty": bson.M{"$bogus": 1}}}},
+		{"bio": bson.M{"$not": bson.M{"$bogus": "go"}}},
+		{"$or": bson.A{bson.M{"a": 1}, bson.M{"b": bson.M{"$bogus": 1}}}},
+		{"orders": bson.M{"$elemMatch": "qty"}},
+	} {
+		_, err := mongoexpr.CompileFilter(filter)
+		assert.Error(t, err, "%v", filter)
+		_, err = mongoexpr.MatchesFilter(bson.M{"a": 2}, filter)
+		assert.Error(t, err, "%v", filter)
+	}
+}
+
+func TestMongoexpr_LogicalOperators(t *testing.T) {
+	doc := bson.M{"_id": "u1", "status": "active", "score": int32(7), "tags": bson.A{"a", "b"}}
+	cases := []struct {
+		name   string
+		filter bson.M
+		want   bool
+	}{
+		{"$and all true", bson.M{"$and": bson.A{bson.M{"status": "active"}, bson.M{"score": bson.M{"$gt": 5}}}}, true},
+		{"$and one false", bson.M{"$and": bson.A{bson.M{"status": "active"}, bson.M{"score": bson.M{"$gt": 7}}}}, false},
+		{"$or one true", bson.M{"$or": bson.A{bson.M{"status": "archived"}, bson.M{"tags": "b"}}}, true},
+		{"$or none true", bson.M{"$or": bson.A{bson.M{"status": "archived"}, bson.M{"tags": "c"}}}, false},
+		{"$nor none true", bson.M{"$nor": bson.A{bson.M{"status": "archived"}, bson.M{"missing": bson.M{"$exists": true}}}}, true},
+		{"$nor one true", bson.M{"$nor": bson.A{bson.M{"status": "archived"}, bson.M{"score": int32(7)}}}, false},
+		{"[]interface{} operand", bson.M{"$or": []interface{}{bson.M{"score": bson.M{"$lt": 0}}, map[string]interface{}{"status": "active"}}}, true},
+		{"nested", bson.M{"$and": bson.A{
+			bson.M{"$or": bson.A{bson.M{"status": "pending"}, bson.M{"score": bson.M{"$in": bson.A{6, 7}}}}},
+			bson.M{"$nor": bson.A{bson.M{"tags": "z"}}},
+		}}, true},
+		{"with field condition", bson.M{"status": "active", "$or": bson.A{bson.M{"score": int32(1)}}}, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := mongoexpr.MatchesFilter(doc, tc.filter)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+
+	for _, filter := range []bson.M{
+		{"$or": bson.A{}},
+		{"$and": []interface{}{}},
+		{"$nor": bson.M{"status": "active"}},
+		{"$or": bson.A{"status"}},
+	} {
+		_, err := mongoexpr.CompileFilter(filter)
+		assert.Error(t, err, "%v", filter)
+	}
+}
+
+func TestMongoexpr_Mod(t *testing.T) {
+	var docs []bson.M
+	for i := 0; i < 30; i++ {
+		docs = append(docs, bson.M{"_id": fmt.Sprintf("d%02d", i), "id": int32(i)})
+	}
+	docs = append(docs,
+		bson.M{"_id": "float", "id": 20.7},          // truncated to 20
+		bson.M{"_id": "string", "id": "20"},         // non-numeric: never matches
+		bson.M{"_id": "array", "id": bson.A{3, 40}}, // any element
+		bson.M{"_id": "missing"},
+	)
+
+	var selected []string
+	for _, doc := range docs {
+		ok, err := mongoexpr.MatchesFilter(doc, bson.M{"id": bson.M{"$mod": bson.A{10, 0}}})
+		require.NoError(t, err)
+		if ok {
+			selected = append(selected, doc["_id"].(string))
+		}
+	}
+	assert.Equal(t, []string{"d00", "d10", "d20", "float", "array"}, selected)
+
+	// Under $not, non-numeric and missing fields match.
+	ok, err := mongoexpr.MatchesFilter(bson.M{"id": "20"}, bson.M{"id": bson.M{"$not": bson.M{"$mod": bson.A{10, 0}}}})
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = mongoexpr.MatchesFilter(bson.M{"id": int64(-7)}, bson.M{"id": bson.M{"$mod": bson.A{4.9, -3}}})
+	require.NoError(t, err)
+	assert.True(t, ok, "divisor truncates to 4; the remainder keeps the dividend's sign")
+
+	for _, operand := range []interface{}{
+		bson.A{10},
+		bson.A{10, 0, 1},
+		bson.A{"10", 0},
+		bson.A{10, "0"},
+		bson.A{0, 0},
+		10,
+	} {
+		_, err := mongoexpr.CompileFilter(bson.M{"id": bson.M{"$mod": operand}})
+		assert.Error(t, err, "%v", operand)
+	}
+}
+
+func TestMongoexpr_Regex(t *testing.T) {
+	docs := []bson.M{
+		{"_id": "upper", "name": "MongoDB Atlas"},
+		{"_id": "lower", "name": "mongodb community"},
+		{"_id": "other", "name": "Postgres"},
+		{"_id": "number", "name": 42}, // not a string: never matches
+		{"_id": "missing"},            // no field: never matches
+		{"_id": "nested", "profile": bson.M{"name": "Mongo"}},
+	}
+	selected := func(filter bson.M) []string {
+		t.Helper()
+		var ids []string
+		for _, doc := range docs {
+			ok, err := mongoexpr.MatchesFilter(doc, filter)
+			require.NoError(t, err)
+			if ok {
+				ids = append(ids, doc["_id"].(string))
+			}
+		}
+		return ids
+	}
+
+	assert.Equal(t, []string{"lower"}, selected(bson.M{"name": bson.M{"$regex": "^mongo"}}))
+	assert.Equal(t, []string{"upper", "lower"}, selected(bson.M{"name": bson.M{"$regex": "^mongo", "$options": "i"}}))
+	assert.Equal(t, []string{"upper", "lower"}, selected(bson.M{"name": bson.M{"$regex": primitive.Regex{Pattern: "^mongo", Options: "i"}}}))
+	assert.Equal(t, []string{"nested"}, selected(bson.M{"profile.name": bson.M{"$regex": "go$", "$options": "i"}}))
+	// Under $not, non-strings and missing fields match.
+	assert.Equal(t, []string{"other", "number", "missing", "nested"},
+		selected(bson.M{"name": bson.M{"$not": bson.M{"$regex": "mongo", "$options": "i"}}}))
+
+	// Bad patterns and options fail when the filter compiles, before any
+	// document is read.
+	for _, ops := range []bson.M{
+		{"$regex": "("},
+		{"$regex": "a", "$options": "x"},
+		{"$regex": 7},
+		{"$options": "i"},
+	} {
+		_, err := mongoexpr.CompileFilter(bson.M{"name": ops})
+		assert.Error(t, err, "%v", ops)
+	}
+	_, err := mongoexpr.MatchesFilter(bson.M{"name": 42}, bson.M{"name": bson.M{"$regex": "("}})
+	assert.ErrorContains(t, err, "invalid $regex pattern")
+}
+
+func TestMongoexpr_Exists(t *testing.T) {
+	docs := []bson.M{
+		{"_id": "full", "address": bson.M{"city": "Oslo", "zip": nil}},
+		{"_id": "partial", "address": bson.M{"city": "Lima"}},
+		{"_id": "scalar", "address": "unknown"},
+		{"_id": "none"},
+	}
+	selected := func(filter bson.M) []string {
+		t.Helper()
+		var ids []string
+		for _, doc := range docs {
+			ok, err := mongoexpr.MatchesFilter(doc, filter)
+			require.NoError(t, err)
+			if ok {
+				ids = append(ids, doc["_id"].(string))
+			}
+		}
+		return ids
+	}
+
+	assert.Equal(t, []string{"full", "partial", "scalar"}, selected(bson.M{"address": bson.M{"$exists": true}}))
+	assert.Equal(t, []string{"full", "partial"}, selected(bson.M{"address.city": bson.M{"$exists": true}}))
+	// A null value is present; a path through a scalar is not.
+	assert.Equal(t, []string{"full"}, selected(bson.M{"address.zip": bson.M{"$exists": true}}))
+	assert.Equal(t, []string{"partial", "scalar", "none"}, selected(bson.M{"address.zip": bson.M{"$exists": false}}))
+	assert.Equal(t, []string{"partial"}, selected(bson.M{
+		"address.city": bson.M{"$exists": true, "$regex": "^l", "$options": "i"},
+		"address.zip":  bson.M{"$exists": false},
+	}))
+}
+
+// lineItemOrders are orders embedding arrays of sub-documents and scalars.
+func lineItemOrders() []bson.M {
+	return []bson.M{
+		{"_id": "o1", "items": bson.A{
+			bson.M{"sku": "A", "qty": int32(2), "price": 9.5},
+			bson.M{"sku": "B", "qty": int32(10), "price": 1.0},
+		}, "tags": bson.A{"gift", "express"}, "scores": bson.A{72, 81}},
+		{"_id": "o2", "items": bson.A{
+			bson.M{"sku": "A", "qty": int32(12), "price": 8.0},
+		}, "tags": bson.A{"express"}, "scores": bson.A{90}},
+		{"_id": "o3", "items": bson.A{}, "tags": bson.A{}, "scores": bson.A{}},
+		{"_id": "o4", "items": "none", "tags": "gift"},
+		{"_id": "o5"},
+	}
+}
+
+func TestMongoexpr_ArrayOperators(t *testing.T) {
+	docs := lineItemOrders()
+	selected := func(filter bson.M) []string {
+		t.Helper()
+		var ids []string
+		for _, doc := range docs {
+			ok, err := mongoexpr.MatchesFilter(doc, filter)
+			require.NoError(t, err)
+			if ok {
+				ids = append(ids, doc["_id"].(string))
+			}
+		}
+		return ids
+	}
+
+	// $elemMatch: one element must satisfy every condition. Without it,
+	// "items.sku" and "items.qty" may be satisfied by different items.
+	assert.Equal(t, []string{"o2"}, selected(bson.M{"items": bson.M{"$elemMatch": bson.M{"sku": "A", "qty": bson.M{"$gt": 5}}}}))
+	assert.Equal(t, []string{"o1", "o2"}, selected(bson.M{"items.sku": "A", "items.qty": bson.M{"$gt": 5}}))
+	assert.Equal(t, []string{"o1"}, selected(bson.M{"items": bson.M{"$elemMatch": bson.M{
+		"$or": bson.A{bson.M{"sku": "B"}, bson.M{"price": bson.M{"$gt": 100}}},
+	}}}))
+	// On scalar arrays, $elemMatch applies operators to the elements.
+	assert.Equal(t, []string{"o1"}, selected(bson.M{"scores": bson.M{"$elemMatch": bson.M{"$gte": 80, "$lt": 85}}}))
+	assert.Equal(t, []string{"o1", "o2"}, selected(bson.M{"scores": bson.M{"$elemMatch": bson.M{"$gt": 80}}}))
+	// Scalars never $elemMatch a document condition, nor non-arrays.
+	assert.Empty(t, selected(bson.M{"tags": bson.M{"$elemMatch": bson.M{"sku": "A"}}}))
+
+	// $all: every listed value, in any order.
+	assert.Equal(t, []string{"o1"}, selected(bson.M{"tags": bson.M{"$all": bson.A{"express", "gift"}}}))
+	assert.Equal(t, []string{"o1", "o2"}, selected(bson.M{"tags": bson.M{"$all": bson.A{"express"}}}))
+	assert.Equal(t, []string{"o1", "o2"}, selected(bson.M{"items.sku": bson.M{"$all": bson.A{"A"}}}))
+
+	// $size: exact length; missing fields and non-arrays never match.
+	assert.Equal(t, []string{"o1"}, selected(bson.M{"items": bson.M{"$size": 2}}))
+	assert.Equal(t, []string{"o3"}, selected(bson.M{"items": bson.M{"$size": 0}}))
+	assert.Equal(t, []string{"o2", "o3", "o4", "o5"}, selected(bson.M{"items": bson.M{"$not": bson.M{"$size": 2}}}))
+
+	// Dotted paths through arrays: numeric parts index, others fan out.
+	assert.Equal(t, []string{"o1", "o2"}, selected(bson.M{"items.0.sku": "A"}))
+	assert.Equal(t, []string{"o1"}, selected(bson.M{"items.1.sku": bson.M{"$exists": true}}))
+	assert.Equal(t, []string{"o1"}, selected(bson.M{"items.price": bson.M{"$lt": 2}}))
+	assert.Equal(t, []string{"o1", "o2"}, selected(bson.M{"items.sku": bson.M{"$exists": true}}))
+	assert.Equal(t, []string{"o3", "o4", "o5"}, selected(bson.M{"items.sku": bson.M{"$exists": false}}))
+
+	_, err := mongoexpr.CompileFilter(bson.M{"scores": bson.M{"$elemMatch": bson.M{"$near": 1}}})
+	assert.ErrorContains(t, err, "unsupported query operator")
+}
+
+func TestMongoexpr_ArraysThroughArrays(t *testing.T) {
+	// Each item's tags is itself an array: "items.tags" has one candidate
+	// per item, and each candidate matches through its elements.
+	doc := bson.M{"_id": "n1", "items": bson.A{
+		bson.M{"tags": bson.A{"a", "b"}, "dims": bson.A{bson.A{1, 2}}},
+		bson.M{"tags": bson.A{"c"}},
+	}}
+	for _, tc := range []struct {
+		filter bson.M
+		want   bool
+	}{
+		{bson.M{"items.tags": "a"}, true},
+		{bson.M{"items.tags": "c"}, true},
+		{bson.M{"items.tags": "z"}, false},
+		{bson.M{"items.tags": bson.A{"a", "b"}}, true},
+		{bson.M{"items.tags": bson.A{bson.A{"a", "b"}, bson.A{"c"}}}, false},
+		{bson.M{"items.tags": bson.M{"$in": bson.A{"z", "c"}}}, true},
+		{bson.M{"items.tags": bson.M{"$ne": "b"}}, false},
+		{bson.M{"items.tags": bson.M{"$regex": "^b"}}, true},
+		{bson.M{"items.tags": bson.M{"$gt": "b"}}, true},
+		// $size measures each item's array, not the list of items.
+		{bson.M{"items.tags": bson.M{"$size": 1}}, true},
+		{bson.M{"items.tags": bson.M{"$size": 2}}, true},
+		{bson.M{"items.tags": bson.M{"$size": 3}}, false},
+		// $all may draw its values from different items.
+		{bson.M{"items.tags": bson.M{"$all": bson.A{"a", "c"}}}, true},
+		{bson.M{"items.tags": bson.M{"$elemMatch": bson.M{"$eq": "c"}}}, true},
+		// Only one level of nesting is looked through.
+		{bson.M{"items.dims": 1}, false},
+		{bson.M{"items.dims": bson.A{1, 2}}, true},
+	} {
+		ok, err := mongoexpr.MatchesFilter(doc, tc.filter)
+		require.NoError(t, err)
+		assert.Equal(t, tc.want, ok, "%v", tc.filter)
+	}
+}
+
+func BenchmarkMongoexpr_ShortCircuit(b *testing.B) {
+	docs, filters := filterCorpus()
+	b.Run("compiled", func(b *testing.B) {
+		var evals int64
+		for i := 0; i < b.N; i++ {
+			for _, filter := range filters {
+				m, _ := mongoexpr.CompileInstrumentedFilter(filter)
+				for _, doc := range docs {
+					_, _ = m.Match(doc)
+				}
+				evals += m.Evaluations()
+			}
+		}
+		b.ReportMetric(float64(evals)/float64(b.N), "evals/op")
+	})
+	b.Run("naive", func(b *testing.B) {
+		evals := 0
+		for i := 0; i < b.N; i++ {
+			for _, filter := range filters {
+				for _, doc := range docs {
+					naiveMatch(b, doc, filter, &evals)
+				}
+			}
+		}
+		b.ReportMetric(float64(evals)/float64(b.N), "evals/op")
+	})
+}
+
+func TestMongoexpr_AddToSetAndPop(t *testing.T) {
+	doc, err := mongoexpr.ApplyUpdate(bson.M{"_id": "a1", "reviewers": bson.A{"r1"}},
+		bson.M{"$addToSet": bson.M{"reviewers": bson.M{"$each": bson.A{"r1", "r2", "r2"}}}}, false)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"r1", "r2"}, doc["reviewers"])
+
+	// $pop on a missing or empty array is a no-op.
+	for _, start := range []bson.M{{"_id": "a1"}, {"_id": "a1", "reviewers": bson.A{}}} {
+		popped, err := mongoexpr.ApplyUpdate(start, bson.M{"$pop": bson.M{"reviewers": 1}}, false)
+		require.NoError(t, err)
+		assert.Equal(t, start, popped)
+	}
+
+	// Like MongoDB, array operators on a non-array field fail.
+	_, err = mongoexpr.ApplyUpdate(bson.M{"_id": "a1", "reviewers": "r1"}, bson.M{"$pop": bson.M{"reviewers": -1}}, false)
+	assert.Error(t, err)
+	_, err = mongoexpr.ApplyUpdate(bson.M{"_id": "a1", "reviewers": "r1"}, bson.M{"$addToSet": bson.M{"reviewers": "r2"}}, false)
+	assert.Error(t, err)
+}
+
+func TestMongoexpr_RegisteredPredicate(t *testing.T) {
+	// A computed condition no operator expresses: total equals the sum of
+	// the line items.
+	mongoexpr.RegisterPredicate("balanced", func(doc bson.M) bool {
+		var sum int32
+		items, _ := doc["items"].(bson.A)
+		for _, item := range items {
+			n, _ := item.(int32)
+			sum += n
+		}
+		return sum == doc["total"]
+	})
+	t.Cleanup(func() { mongoexpr.RegisterPredicate("balanced", nil) })
+
+	docs := []bson.M{
+		{"_id": "o1", "status": "paid", "total": int32(5), "items": bson.A{int32(2), int32(3)}},
+		{"_id": "o2", "status": "paid", "total": int32(9), "items": bson.A{int32(2), int32(3)}},
+		{"_id": "o3", "status": "open", "total": int32(4), "items": bson.A{int32(4)}},
+		{"_id": "o4", "status": "open", "total": int32(1), "items": bson.A{}},
+	}
+	matching := func(filter bson.M) []string {
+		m, err := mongoexpr.CompileFilter(filter)
+		require.NoError(t, err)
+		var ids []string
+		for _, doc := range docs {
+			ok, err := m.Match(doc)
+			require.NoError(t, err)
+			if ok {
+				ids = append(ids, doc["_id"].(string))
+			}
+		}
+		return ids
+	}
+
+	assert.Equal(t, []string{"o1", "o3"}, matching(bson.M{"$pred": "balanced"}))
+	assert.Equal(t, []string{"o1"}, matching(bson.M{"$pred": "balanced", "status": "paid"}))
+	assert.Equal(t, []string{"o2", "o4"}, matching(bson.M{"$nor": bson.A{bson.M{"$pred": "balanced"}}}))
+	assert.Equal(t, []string{"o1", "o3", "o4"},
+		matching(bson.M{"$or": bson.A{bson.M{"$pred": "balanced"}, bson.M{"total": int32(1)}}}))
+
+	// The cheap condition runs first; the predicate only sees survivors.
+	calls := 0
+	mongoexpr.RegisterPredicate("counted", func(bson.M) bool { calls++; return true })
+	t.Cleanup(func() { mongoexpr.RegisterPredicate("counted", nil) })
+	assert.Equal(t, []string{"o3", "o4"}, matching(bson.M{"$pred": "counted", "status": "open"}))
+	assert.Equal(t, 2, calls)
+
+	// Unknown names and non-string operands fail at compile time, and an
+	// unregistered predicate is gone for new filters.
+	for _, filter := range []bson.M{{"$pred": "missing"}, {"$pred": 1}, {"total": bson.M{"$pred": "balanced"}}} {
+		_, err := mongoexpr.CompileFilter(filter)
+		assert.Error(t, err, "%v", filter)
+	}
+	mongoexpr.RegisterPredicate("balanced", nil)
+	_, err := mongoexpr.CompileFilter(bson.M{"$pred": "balanced"})
+	assert.Error(t, err)
+}
+
+func TestMongoexpr_DeeplyNestedLogic(t *testing.T) {
+	doc := bson.M{
+		"_id": "o1", "status": "shipped", "total": int32(120), "region": "eu",
+		"lines": bson.A{bson.M{"sku": "a", "qty": int32(2)}, bson.M{"sku": "b", "qty": int32(9)}},
+	}
+	cases := []struct {
+		name   string
+		filter bson.M
+		want   bool
+	}{
+		{"$and > $or > $and", bson.M{"$and": bson.A{
+			bson.M{"$or": bson.A{
+				bson.M{"$and": bson.A{bson.M{"status": "shipped"}, bson.M{"total": bson.M{"$gte": 100}}}},
+				bson.M{"status": "pending"},
+			}},
+			bson.M{"region": "eu"},
+		}}, true},
+		{"$and > $or > $and, inner $and false", bson.M{"$and": bson.A{
+			bson.M{"$or": bson.A{
+				bson.M{"$and": bson.A{bson.M{"status": "shipped"}, bson.M{"total": bson.M{"$gt": 120}}}},
+				bson.M{"status": "pending"},
+			}},
+			bson.M{"region": "eu"},
+		}}, false},
+		{"$or > $and > $or", bson.M{"$or": bson.A{
+			bson.M{"region": "us"},
+			bson.M{"$and": bson.A{
+				bson.M{"$or": bson.A{bson.M{"total": int32(1)}, bson.M{"region": "eu"}}},
+				bson.M{"$or": bson.A{bson.M{"status": "shipped"}, bson.M{"total": int32(2)}}},
+			}},
+		}}, true},
+		{"$nor > $and > $or", bson.M{"$nor": bson.A{
+			bson.M{"$and": bson.A{
+				bson.M{"region": "eu"},
+				bson.M{"$or": bson.A{bson.M{"total": bson.M{"$lt": 0}}, bson.M{"status": "shipped"}}},
+			}},
+		}}, false},
+		{"$or inside $elemMatch", bson.M{"lines": bson.M{"$elemMatch": bson.M{
+			"$or": bson.A{bson.M{"sku": "z"}, bson.M{"$and": bson.A{bson.M{"sku": "b"}, bson.M{"qty": bson.M{"$gt": 5}}}}},
+		}}}, true},
+		{"$elemMatch conditions hold on one element", bson.M{"lines": bson.M{"$elemMatch": bson.M{
+			"$and": bson.A{bson.M{"sku": "a"}, bson.M{"qty": int32(9)}},
+		}}}, false},
+		{"sibling field beside nested logic", bson.M{"total": int32(120), "$and": bson.A{
+			bson.M{"$or": bson.A{bson.M{"$and": bson.A{bson.M{"region": "eu"}, bson.M{"status": "lost"}}}}},
+		}}, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := mongoexpr.MatchesFilter(doc, tc.filter)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+
+	// Alternate $and/$or twenty levels deep; the leaf decides.
+	nest := func(leaf bson.M) bson.M {
+		filter := leaf
+		for depth := 0; depth < 20; depth++ {
+			op := "$and"
+			if depth%2 == 1 {
+				op = "$or"
+			}
+			filter = bson.M{op: bson.A{filter, bson.M{"_id": bson.M{"$exists": depth%2 == 0}}}}
+		}
+		return filter
+	}
+	// Each $and's sibling holds and each $or's sibling fails, so the
+	// result is exactly the leaf.
+	for leaf, want := range map[string]bool{"shipped": true, "lost": false} {
+		got, err := mongoexpr.MatchesFilter(doc, nest(bson.M{"status": leaf}))
+		require.NoError(t, err)
+		assert.Equal(t, want, got, leaf)
+		var evals int
+		assert.Equal(t, want, naiveMatch(t, doc, nest(bson.M{"status": leaf}), &evals), leaf)
+	}
+}
+
+func TestMongoexpr_MulMinMaxRename(t *testing.T) {
+	start := bson.M{"_id": "p1", "qty": int32(5), "price": 2.5, "meta": bson.M{"old": "x", "keep": true}}
+
+	doc, err := mongoexpr.ApplyUpdate(start, bson.M{"$mul": bson.M{"qty": int32(3), "price": int32(2), "absent": int64(4)}}, false)
+	require.NoError(t, err)
+	assert.Equal(t, int32(15), doc["qty"])
+	assert.Equal(t, 5.0, doc["price"])
+	// $mul on a missing field sets zero of the operand's type.
+	assert.Equal(t, int64(0), doc["absent"])
+	_, err = mongoexpr.ApplyUpdate(bson.M{"_id": "p1", "qty": "five"}, bson.M{"$mul": bson.M{"qty": 2}}, false)
+	assert.Error(t, err)
+
+	// $min/$max replace only a smaller/larger value, and initialize a
+	// missing field.
+	doc, err = mongoexpr.ApplyUpdate(start, bson.M{
+		"$min": bson.M{"qty": int32(3), "low": int32(7)},
+		"$max": bson.M{"price": 1.0, "high": "z"},
+	}, false)
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), doc["qty"])
+	assert.Equal(t, int32(7), doc["low"])
+	assert.Equal(t, 2.5, doc["price"])
+	assert.Equal(t, "z", doc["high"])
+
+	// $rename moves nested paths and overwrites an existing target; the
+	// source key is gone either way. A missing source is a no-op.
+	doc, err = mongoexpr.ApplyUpdate(start, bson.M{"$rename": bson.M{"meta.old": "info.renamed", "qty": "price", "nope": "x"}}, false)
+	require.NoError(t, err)
+	assert.Equal(t, bson.M{"_id": "p1", "price": int32(5), "meta": bson.M{"keep": true}, "info": bson.M{"renamed": "x"}}, doc)
+	assert.Equal(t, int32(5), start["qty"], "the input document is not mutated")
+
+	for _, target := range []string{"qty", "qty.sub", "meta"} {
+		source := "qty"
+		if target == "meta" {
+			source = "meta.old"
+		}
+		_, err = mongoexpr.ApplyUpdate(start, bson.M{"$rename": bson.M{source: target}}, false)
+		assert.ErrorContains(t, err, "same path", target)
+	}
+}
+
+func TestMongoexpr_DottedPaths(t *testing.T) {
+	doc := bson.M{
+		"_id":      "o1",
+		"metadata": bson.M{"version": int32(2), "owner": bson.M{"team": "core"}},
+		"items":    bson.A{bson.M{"sku": "a1", "qty": int32(3)}, bson.M{"sku": "b2", "qty": int32(9)}},
+		"tags":     bson.A{"red", "blue"},
+	}
+	for _, tc := range []struct {
+		filter bson.M
+		want   bool
+	}{
+		{bson.M{"metadata.version": 2}, true},
+		{bson.M{"metadata.version": 3}, false},
+		{bson.M{"metadata.owner.team": "core"}, true},
+		{bson.M{"metadata.version": bson.M{"$gt": 1}}, true},
+		{bson.M{"metadata.version": bson.M{"$gt": 2}}, false},
+		{bson.M{"items.0.sku": "a1"}, true},
+		{bson.M{"items.1.qty": bson.M{"$gte": 9}}, true},
+		{bson.M{"items.1.sku": "a1"}, false},
+		{bson.M{"tags.1": "blue"}, true},
+		// Missing intermediates and out-of-range indexes are "not found".
+		{bson.M{"metadata.missing.deep": bson.M{"$exists": true}}, false},
+		{bson.M{"metadata.missing.deep": nil}, true},
+		{bson.M{"items.5.sku": bson.M{"$exists": true}}, false},
+		{bson.M{"metadata.version.x": bson.M{"$exists": true}}, false},
+	} {
+		got, err := mongoexpr.MatchesFilter(doc, tc.filter)
+		require.NoError(t, err, "%v", tc.filter)
+		assert.Equal(t, tc.want, got, "%v", tc.filter)
+	}
+}
+
+func TestMongoexpr_TypedComparison(t *testing.T) {
+	created := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	early, late := primitive.NewObjectIDFromTimestamp(created), primitive.NewObjectIDFromTimestamp(created.Add(time.Hour))
+	// Round-trip through BSON as materialized state does: dates come back
+	// as primitive.DateTime, and filters still compare them to time.Time.
+	raw, err := bson.Marshal(bson.M{
+		"_id": early, "created": created, "count": int32(2), "ratio": 2.5, "name": "banana",
+	})
+	require.NoError(t, err)
+	var doc bson.M
+	require.NoError(t, bson.Unmarshal(raw, &doc))
+	require.IsType(t, primitive.DateTime(0), doc["created"])
+
+	for _, tc := range []struct {
+		filter bson.M
+		want   bool
+	}{
+		{bson.M{"created": bson.M{"$gt": created.Add(-time.Minute)}}, true},
+		{bson.M{"created": bson.M{"$lt": created.Add(-time.Minute)}}, false},
+		{bson.M{"created": bson.M{"$gte": created}}, true},
+		{bson.M{"created": created.In(time.FixedZone("UTC+2", 2*3600))}, true},
+		{bson.M{"created": bson.M{"$lt": primitive.NewDateTimeFromTime(created.Add(time.Second))}}, true},
+		{bson.M{"_id": early}, true},
+		{bson.M{"_id": late}, false},
+		{bson.M{"_id": bson.M{"$lt": late}}, true},
+		{bson.M{"_id": early.Hex()}, false},
+		{bson.M{"count": 2.0}, true},
+		{bson.M{"count": bson.M{"$lt": int64(3)}}, true},
+		{bson.M{"ratio": bson.M{"$gt": int32(2)}}, true},
+		{bson.M{"name": bson.M{"$gt": "apple"}}, true},
+		{bson.M{"name": bson.M{"$gt": "cherry"}}, false},
+		// Ordering never crosses type brackets.
+		{bson.M{"name": bson.M{"$gt": 5}}, false},
+		{bson.M{"created": bson.M{"$gt": "2020-01-01"}}, false},
+	} {
+		got, err := mongoexpr.MatchesFilter(doc, tc.filter)
+		require.NoError(t, err, "%v", tc.filter)
+		assert.Equal(t, tc.want, got, "%v", tc.filter)
+	}
+}
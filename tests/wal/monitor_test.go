@@ -0,0 +1,237 @@
+package wal_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/argon-lab/argon/internal/wal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestMonitor_UnreachableDatabaseTurnsUnhealthy(t *testing.T) {
+	// Nothing listens on port 1: connecting is lazy, every ping fails.
+	client, err := mongo.Connect(context.Background(),
+		options.Client().ApplyURI("mongodb://127.0.0.1:1/?serverSelectionTimeoutMS=100&connectTimeoutMS=100"))
+	require.NoError(t, err)
+	defer func() { _ = client.Disconnect(context.Background()) }()
+
+	monitor := wal.NewMonitor(wal.NewMetrics(), wal.MonitorConfig{
+		HealthCheckInterval:   20 * time.Millisecond,
+		MetricsReportInterval: time.Hour,
+		AlertThresholds:       wal.AlertThresholds{MaxConsecutiveFailures: 2},
+	})
+	monitor.SetPinger(func(ctx context.Context) error { return client.Ping(ctx, nil) })
+	monitor.Start()
+	defer monitor.Stop()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for monitor.IsHealthy() {
+		require.True(t, time.Now().Before(deadline), "monitor never noticed the database was down")
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	titles := map[string]wal.AlertLevel{}
+	for _, alert := range monitor.GetActiveAlerts() {
+		titles[alert.Title] = alert.Level
+	}
+	assert.Equal(t, wal.AlertLevelCritical, titles["system_unhealthy"])
+	assert.Equal(t, wal.AlertLevelError, titles["health_check_database_connectivity"])
+	assert.GreaterOrEqual(t, monitor.GetHealthStatus()["consecutive_fails"], 2)
+}
+
+func TestMonitor_ReachableDatabaseStaysHealthy(t *testing.T) {
+	monitor := wal.NewMonitor(wal.NewMetrics(), wal.MonitorConfig{
+		HealthCheckInterval:   20 * time.Millisecond,
+		MetricsReportInterval: time.Hour,
+	})
+	pings := make(chan struct{}, 100)
+	monitor.SetPinger(func(context.Context) error {
+		select {
+		case pings <- struct{}{}:
+		default:
+		}
+		return nil
+	})
+	monitor.Start()
+	defer monitor.Stop()
+
+	for i := 0; i < 3; i++ {
+		select {
+		case <-pings:
+		case <-time.After(5 * time.Second):
+			t.Fatal("monitor never pinged the database")
+		}
+	}
+	assert.True(t, monitor.IsHealthy())
+	assert.Empty(t, monitor.GetActiveAlerts())
+}
+
+func TestMonitor_HeapAboveCeilingRaisesWarning(t *testing.T) {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	ceiling := stats.HeapAlloc + 32<<20
+
+	monitor := wal.NewMonitor(wal.NewMetrics(), wal.MonitorConfig{
+		HealthCheckInterval:   20 * time.Millisecond,
+		MetricsReportInterval: time.Hour,
+		AlertThresholds:       wal.AlertThresholds{MaxHeapBytes: ceiling, HeapGrowthWindow: -1},
+	})
+	ballast := make([]byte, 64<<20)
+	for i := range ballast {
+		ballast[i] = byte(i)
+	}
+	monitor.Start()
+	defer monitor.Stop()
+
+	var alert *wal.Alert
+	deadline := time.Now().Add(10 * time.Second)
+	for alert == nil {
+		require.True(t, time.Now().Before(deadline), "memory alert never fired")
+		time.Sleep(20 * time.Millisecond)
+		for _, a := range monitor.GetActiveAlerts() {
+			if a.Title == "health_check_memory_usage" {
+				a := a
+				alert = &a
+			}
+		}
+	}
+	runtime.KeepAlive(ballast)
+
+	assert.Equal(t, wal.AlertLevelWarning, alert.Level)
+	assert.Greater(t, alert.Data["heap_alloc_bytes"], ceiling)
+	assert.Equal(t, ceiling, alert.Data["max_heap_bytes"])
+	assert.True(t, monitor.IsHealthy(), "the memory check is not critical")
+}
+
+func TestWebhookSink_RetriesThenDelivers(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		attempts int
+		got      wal.WebhookPayload
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+	}))
+	defer server.Close()
+
+	sink := wal.NewWebhookSink(server.URL)
+	sink.Backoff = time.Millisecond
+	sink.MaxAttempts = 3
+	err := sink.Deliver(context.Background(), wal.Alert{
+		Level: wal.AlertLevelCritical, Title: "system_unhealthy", Message: "down",
+		Timestamp: time.Now(), Data: map[string]interface{}{"consecutive_failures": 3},
+	})
+	require.NoError(t, err)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 3, attempts)
+	assert.Equal(t, wal.AlertLevelCritical, got.Level)
+	assert.Equal(t, "system_unhealthy", got.Title)
+	assert.Equal(t, "down", got.Message)
+	assert.EqualValues(t, 3, got.Data["consecutive_failures"])
+	assert.False(t, got.Resolved)
+}
+
+func TestWebhookSink_GivesUp(t *testing.T) {
+	var attempts atomic.Int32
+	status := http.StatusBadGateway
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	sink := wal.NewWebhookSink(server.URL)
+	sink.Backoff = time.Millisecond
+	sink.MaxAttempts = 3
+	assert.ErrorContains(t, sink.Deliver(context.Background(), wal.Alert{Title: "x"}), "after 3 attempt(s)")
+	assert.EqualValues(t, 3, attempts.Load())
+
+	// Client errors are final: retrying would not change the answer.
+	status = http.StatusBadRequest
+	attempts.Store(0)
+	assert.ErrorContains(t, sink.Deliver(context.Background(), wal.Alert{Title: "x"}), "after 1 attempt(s)")
+	assert.EqualValues(t, 1, attempts.Load())
+}
+
+func TestWebhookSink_StopsRetryingOnCancel(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	// A stopping monitor is not held up by a long backoff.
+	sink := wal.NewWebhookSink(server.URL)
+	sink.Backoff = time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	assert.ErrorIs(t, sink.Deliver(ctx, wal.Alert{Title: "x"}), context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 10*time.Second)
+	assert.EqualValues(t, 1, attempts.Load())
+}
+
+func TestMonitor_DeliversNewAndResolvedAlertsToSlack(t *testing.T) {
+	texts := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg struct {
+			Text string `json:"text"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&msg))
+		texts <- msg.Text
+	}))
+	defer server.Close()
+
+	monitor := wal.NewMonitor(wal.NewMetrics(), wal.MonitorConfig{
+		HealthCheckInterval:   time.Hour,
+		MetricsReportInterval: time.Hour,
+		AlertSinks:            []wal.AlertSink{wal.NewSlackSink(server.URL)},
+	})
+	monitor.Start()
+	defer monitor.Stop()
+
+	monitor.TriggerAlert(wal.AlertLevelError, "disk_full", "no space left", nil)
+	monitor.TriggerAlert(wal.AlertLevelError, "disk_full", "still no space", nil) // still active: not re-sent
+	monitor.ResolveAlert("disk_full")
+
+	next := func() string {
+		select {
+		case text := <-texts:
+			return text
+		case <-time.After(5 * time.Second):
+			t.Fatal("no delivery")
+			return ""
+		}
+	}
+	first := next()
+	assert.Contains(t, first, "[error] disk_full")
+	assert.Contains(t, first, "no space left")
+	assert.Contains(t, next(), "Resolved: disk_full")
+	select {
+	case text := <-texts:
+		t.Fatalf("unexpected extra delivery: %s", text)
+	case <-time.After(100 * time.Millisecond):
+	}
+	assert.Empty(t, monitor.GetActiveAlerts())
+}
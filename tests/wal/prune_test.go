@@ -0,0 +1,171 @@
+package wal_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/argon-lab/argon/internal/wal"
+	"github.com/argon-lab/argon/internal/walwriter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestWAL_PruneBefore(t *testing.T) {
+	db := setupTestDB(t)
+	f := newSnapshotFixture(t, db)
+	f.wal.SetBranchLister(f.branches.ListBranchesAny)
+	ctx := context.Background()
+	const project = "prune"
+
+	main, err := f.branches.CreateBranch(project, "main", "")
+	require.NoError(t, err)
+	w := walwriter.New(f.wal, f.branches, f.mat, main)
+	put := func(id string, v int) {
+		_, err := w.Put(ctx, "docs", bson.M{"_id": id, "v": int32(v)})
+		require.NoError(t, err)
+	}
+	put("a", 1)
+	put("b", 1)
+	put("a", 2)
+	put("c", 1)
+	_, _, err = w.Delete(ctx, "docs", "c")
+	require.NoError(t, err)
+	put("a", 3)
+	main, err = f.branches.GetBranchByID(main.ID)
+	require.NoError(t, err)
+	pruneAt := main.HeadLSN + 1
+	put("d", 1)
+	put("a", 4)
+
+	feature, err := f.branches.CreateBranch(project, "feature", main.ID)
+	require.NoError(t, err)
+	main, err = f.branches.GetBranchByID(main.ID)
+	require.NoError(t, err)
+
+	wantMain, err := f.matFull.MaterializeBranch(main)
+	require.NoError(t, err)
+	wantBefore, err := f.matFull.MaterializeBranchAtLSN(main, pruneAt-1)
+	require.NoError(t, err)
+	wantFeature, err := f.matFull.MaterializeBranch(feature)
+	require.NoError(t, err)
+
+	removed, err := f.wal.PruneBefore(project, pruneAt)
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, removed, "a@1, a@2 and c's put go; a@3, b@1 and c's delete carry the state")
+
+	// State at the prune point and above is unchanged.
+	got, err := f.matFull.MaterializeBranch(main)
+	require.NoError(t, err)
+	assert.Equal(t, wantMain, got)
+	got, err = f.matFull.MaterializeBranchAtLSN(main, pruneAt-1)
+	require.NoError(t, err)
+	assert.Equal(t, wantBefore, got)
+	got, err = f.matFull.MaterializeBranch(feature)
+	require.NoError(t, err)
+	assert.Equal(t, wantFeature, got)
+
+	// Pruning again finds nothing more to drop.
+	removed, err = f.wal.PruneBefore(project, pruneAt)
+	require.NoError(t, err)
+	assert.Zero(t, removed)
+
+	// Nothing is older than an hour ago.
+	removed, err = f.wal.PruneOlderThan(project, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, removed)
+}
+
+func TestWAL_PruneRefusesDependentBranches(t *testing.T) {
+	db := setupTestDB(t)
+	f := newSnapshotFixture(t, db)
+	ctx := context.Background()
+	const project = "prune-refuse"
+
+	main, err := f.branches.CreateBranch(project, "main", "")
+	require.NoError(t, err)
+	w := walwriter.New(f.wal, f.branches, f.mat, main)
+	_, err = w.Put(ctx, "docs", bson.M{"_id": "a", "v": int32(1)})
+	require.NoError(t, err)
+	main, err = f.branches.GetBranchByID(main.ID)
+	require.NoError(t, err)
+
+	// Without a branch lister there is nothing to verify against.
+	_, err = f.wal.PruneBefore(project, main.HeadLSN)
+	require.Error(t, err)
+	f.wal.SetBranchLister(f.branches.ListBranchesAny)
+
+	// A branch created at a historical LSN inside the range blocks it.
+	early, err := f.branches.CreateBranch(project, "early", main.ID)
+	require.NoError(t, err)
+	for i := 2; i <= 3; i++ {
+		_, err = w.Put(ctx, "docs", bson.M{"_id": "a", "v": int32(i)})
+		require.NoError(t, err)
+	}
+	main, err = f.branches.GetBranchByID(main.ID)
+	require.NoError(t, err)
+	before, err := f.wal.GetBranchEntries(main.ID, "", 0, main.HeadLSN)
+	require.NoError(t, err)
+
+	_, err = f.wal.PruneBefore(project, main.HeadLSN)
+	var blocked *wal.PruneBlockedError
+	require.True(t, errors.As(err, &blocked), "got %v", err)
+	assert.Equal(t, early.ID, blocked.BranchID)
+	assert.Equal(t, "early", blocked.BranchName)
+	assert.Contains(t, err.Error(), "created at LSN")
+	after, err := f.wal.GetBranchEntries(main.ID, "", 0, main.HeadLSN)
+	require.NoError(t, err)
+	assert.Len(t, after, len(before), "a refused prune deletes nothing")
+
+	// Pruning at or below the fork point is fine.
+	_, err = f.wal.PruneBefore(project, early.BaseLSN)
+	require.NoError(t, err)
+
+	// Once the branch is gone, so is the block — unless a pin reads there.
+	require.NoError(t, f.branches.DeleteBranch(project, "early"))
+	f.wal.SetPinLookup(func(branchID string) ([]int64, error) {
+		if branchID == main.ID {
+			return []int64{early.BaseLSN}, nil
+		}
+		return nil, nil
+	})
+	_, err = f.wal.PruneBefore(project, main.HeadLSN)
+	require.True(t, errors.As(err, &blocked), "got %v", err)
+	assert.Equal(t, "main", blocked.BranchName)
+	assert.Contains(t, err.Error(), "pinned")
+
+	f.wal.SetPinLookup(nil)
+	removed, err := f.wal.PruneBefore(project, main.HeadLSN)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, removed, "only a@1 is shadowed below the head")
+	state, err := f.matFull.MaterializeCollection(main, "docs")
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, state["a"]["v"])
+}
+
+func TestWAL_PruneInsideTransaction(t *testing.T) {
+	db := setupTestDB(t)
+	f := newSnapshotFixture(t, db)
+	f.wal.SetBranchLister(f.branches.ListBranchesAny)
+	main, result := transferFixture(t, f, "prune-txn")
+
+	// The prune point falls inside the transfer: its entries, and the
+	// balances before it, must survive.
+	pruneAt := result.FirstLSN + 1
+	removed, err := f.wal.PruneBefore("prune-txn", pruneAt)
+	require.NoError(t, err)
+	assert.Zero(t, removed)
+
+	before, after := [2]int32{100, 0}, [2]int32{60, 40}
+	for lsn := pruneAt; lsn <= result.LastLSN; lsn++ {
+		want := before
+		if lsn == result.LastLSN {
+			want = after
+		}
+		state, err := f.matFull.MaterializeBranchAtLSN(main, lsn)
+		require.NoError(t, err)
+		assert.Equal(t, want, balances(t, state["accounts"]), "LSN %d", lsn)
+	}
+}
@@ -0,0 +1,73 @@
+package wal_test
+
+import (
+	"context"
+	"testing"
+
+	branchwal "github.com/argon-lab/argon/internal/branch/wal"
+	"github.com/argon-lab/argon/internal/materializer"
+	projectwal "github.com/argon-lab/argon/internal/project/wal"
+	"github.com/argon-lab/argon/internal/timetravel"
+	"github.com/argon-lab/argon/internal/wal"
+	"github.com/argon-lab/argon/internal/walwriter"
+	"github.com/argon-lab/argon/pkg/walcli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+func TestReadPreference_ReadServicesUseSecondary(t *testing.T) {
+	db := setupTestDB(t)
+	walService, err := wal.NewService(db)
+	require.NoError(t, err)
+	branchService, err := branchwal.NewBranchService(db, walService)
+	require.NoError(t, err)
+	projectService, err := projectwal.NewProjectService(db, walService, branchService)
+	require.NoError(t, err)
+	mat := materializer.NewService(walService, branchService)
+	services := &walcli.Services{
+		WAL: walService, Branches: branchService, Projects: projectService,
+		Materializer: mat, TimeTravel: timetravel.NewService(walService, mat),
+	}
+
+	assert.Error(t, services.UseReadPreference("eventually"))
+	require.NoError(t, services.UseReadPreference("secondaryPreferred"))
+	assert.NotSame(t, services.Materializer, services.ReadMaterializer)
+	assert.NotSame(t, services.TimeTravel, services.ReadTimeTravel)
+	assert.Equal(t, readpref.PrimaryMode, walService.ReadPreference().Mode())
+
+	view, err := walService.WithReadPreference(readpref.SecondaryPreferred())
+	require.NoError(t, err)
+	assert.Equal(t, readpref.SecondaryPreferredMode, view.ReadPreference().Mode())
+
+	// Writes go through the primary service; the read services see them
+	// (a single-node test deployment has no secondary to prefer).
+	project, err := projectService.CreateProject("read-preference")
+	require.NoError(t, err)
+	main, err := branchService.GetBranch(project.ID, "main")
+	require.NoError(t, err)
+	writer := walwriter.New(walService, branchService, mat, main)
+	lsn, err := writer.Put(context.Background(), "items", bson.M{"_id": "i1", "v": 1})
+	require.NoError(t, err)
+	entry, err := walService.GetEntry(project.ID, lsn)
+	require.NoError(t, err)
+	assert.Equal(t, "i1", entry.DocumentID)
+
+	main, err = branchService.GetBranchByID(main.ID)
+	require.NoError(t, err)
+	state, err := services.ReadTimeTravel.MaterializeAtLSN(main, "items", lsn)
+	require.NoError(t, err)
+	assert.Contains(t, state, "i1")
+	state, err = mat.WithWAL(view).MaterializeCollection(main, "items")
+	require.NoError(t, err)
+	assert.Contains(t, state, "i1")
+
+	// Every view read runs in a session anchored at the primary.
+	collections, err := view.DistinctCollections(main.ID, 0, lsn)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"items"}, collections)
+	safe, err := view.TxnSafeLSN(main.ID, lsn)
+	require.NoError(t, err)
+	assert.Equal(t, lsn, safe)
+}
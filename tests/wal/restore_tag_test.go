@@ -0,0 +1,95 @@
+package wal_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	branchwal "github.com/argon-lab/argon/internal/branch/wal"
+	"github.com/argon-lab/argon/internal/materializer"
+	"github.com/argon-lab/argon/internal/pin"
+	projectwal "github.com/argon-lab/argon/internal/project/wal"
+	"github.com/argon-lab/argon/internal/restore"
+	"github.com/argon-lab/argon/internal/timetravel"
+	"github.com/argon-lab/argon/internal/wal"
+	"github.com/argon-lab/argon/internal/walwriter"
+	"github.com/argon-lab/argon/pkg/walcli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestRestoreToTag_PreviewAndReset(t *testing.T) {
+	db := setupTestDB(t)
+	walService, err := wal.NewService(db)
+	require.NoError(t, err)
+	branchService, err := branchwal.NewBranchService(db, walService)
+	require.NoError(t, err)
+	projectService, err := projectwal.NewProjectService(db, walService, branchService)
+	require.NoError(t, err)
+	mat := materializer.NewService(walService, branchService)
+	tt := timetravel.NewService(walService, mat)
+	pins, err := pin.NewService(db, branchService)
+	require.NoError(t, err)
+	services := &walcli.Services{
+		WAL: walService, Branches: branchService, Projects: projectService, Materializer: mat,
+		Restore: restore.NewService(walService, branchService, mat, tt), Pins: pins,
+	}
+	ctx := context.Background()
+
+	project, err := projectService.CreateProject("tag-project")
+	require.NoError(t, err)
+	main, err := branchService.GetBranch(project.ID, "main")
+	require.NoError(t, err)
+	writer := walwriter.New(walService, branchService, mat, main)
+	for i := 0; i < 3; i++ {
+		_, err := writer.Put(ctx, "docs", bson.M{"_id": fmt.Sprintf("d%d", i)})
+		require.NoError(t, err)
+	}
+	tag, err := pins.Create(project.ID, main.ID, "v1", 0, "")
+	require.NoError(t, err)
+
+	// Advance past the tag.
+	_, err = writer.Put(ctx, "docs", bson.M{"_id": "d3"})
+	require.NoError(t, err)
+	_, err = writer.Put(ctx, "events", bson.M{"_id": "e1"})
+	require.NoError(t, err)
+
+	// A dry run previews without moving the head.
+	preview, err := services.RestoreToTag("tag-project", "main", "v1", true, false)
+	require.NoError(t, err)
+	assert.Nil(t, preview.Branch)
+	assert.Equal(t, tag.LSN, preview.Preview.TargetLSN)
+	assert.Equal(t, 2, preview.Preview.OperationsToDiscard)
+	assert.Equal(t, map[string]int{"docs": 1, "events": 1}, preview.Preview.AffectedCollections)
+	unchanged, err := branchService.GetBranchByID(main.ID)
+	require.NoError(t, err)
+	assert.Equal(t, preview.Preview.CurrentLSN, unchanged.HeadLSN)
+
+	result, err := services.RestoreToTag("tag-project", "main", "v1", false, false)
+	require.NoError(t, err)
+	require.NotNil(t, result.Branch)
+	assert.Equal(t, tag.LSN, result.Branch.HeadLSN)
+	restored, err := branchService.GetBranchByID(main.ID)
+	require.NoError(t, err)
+	state, err := mat.MaterializeBranch(restored)
+	require.NoError(t, err)
+	assert.Len(t, state["docs"], 3)
+	assert.Empty(t, state["events"])
+
+	// A tag above the (now reset) head is outside the branch range.
+	_, err = writer.Put(ctx, "docs", bson.M{"_id": "d4"})
+	require.NoError(t, err)
+	_, err = pins.Create(project.ID, main.ID, "v2", 0, "")
+	require.NoError(t, err)
+	_, err = services.Restore.ResetBranchToLSN(main.ID, tag.LSN)
+	require.NoError(t, err)
+	_, err = services.RestoreToTag("tag-project", "main", "v2", false, false)
+	require.ErrorContains(t, err, "outside branch range")
+
+	// A tag on another branch is refused.
+	_, err = branchService.CreateBranch(project.ID, "feature", main.ID)
+	require.NoError(t, err)
+	_, err = services.RestoreToTag("tag-project", "feature", "v1", false, false)
+	require.ErrorContains(t, err, "not \"feature\"")
+}
@@ -2,6 +2,7 @@ package wal_test
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
@@ -104,6 +105,94 @@ func TestRestore_ResetBranchToLSN(t *testing.T) {
 	})
 }
 
+func TestRestore_ResetBranchToLSNWithBackup(t *testing.T) {
+	db := setupTestDB(t)
+	walService, err := wal.NewService(db)
+	require.NoError(t, err)
+	branchService, err := branchwal.NewBranchService(db, walService)
+	require.NoError(t, err)
+	projectService, err := projectwal.NewProjectService(db, walService, branchService)
+	require.NoError(t, err)
+	materializerService := materializer.NewService(walService, branchService)
+	timeTravelService := timetravel.NewService(walService, materializerService)
+	restoreService := restore.NewService(walService, branchService, materializerService, timeTravelService)
+	ctx := context.Background()
+
+	project, err := projectService.CreateProject("reset-backup-test")
+	require.NoError(t, err)
+	branches, _ := branchService.ListBranches(project.ID)
+	branch := branches[0]
+	interceptor := walwriter.New(walService, branchService, materializerService, branch)
+
+	_, err = interceptor.Put(ctx, "items", bson.M{"_id": "i1", "step": 1})
+	require.NoError(t, err)
+	target := walService.GetCurrentLSN(project.ID)
+	_, err = interceptor.Put(ctx, "items", bson.M{"_id": "i2", "step": 2})
+	require.NoError(t, err)
+	_, err = interceptor.Put(ctx, "items", bson.M{"_id": "i1", "step": 3})
+	require.NoError(t, err)
+	branch, _ = branchService.GetBranchByID(branch.ID)
+	head := branch.HeadLSN
+
+	reset, backup, err := restoreService.ResetBranchToLSNWithBackup(branch.ID, target, "before-reset")
+	require.NoError(t, err)
+	assert.Equal(t, target, reset.HeadLSN)
+	assert.Equal(t, "before-reset", backup.Name)
+	assert.Equal(t, head, backup.HeadLSN)
+	assert.Equal(t, branch.ID, backup.ParentID)
+
+	// The reset branch lost the later operations; the backup kept them,
+	// and keeps them after the parent's next write.
+	state, err := materializerService.MaterializeCollection(reset, "items")
+	require.NoError(t, err)
+	assert.Len(t, state, 1)
+	assert.EqualValues(t, 1, state["i1"]["step"])
+
+	reset, _ = branchService.GetBranchByID(branch.ID)
+	interceptor = walwriter.New(walService, branchService, materializerService, reset)
+	_, err = interceptor.Put(ctx, "items", bson.M{"_id": "i3", "step": 4})
+	require.NoError(t, err)
+
+	backup, err = branchService.GetBranchByID(backup.ID)
+	require.NoError(t, err)
+	state, err = materializerService.MaterializeCollection(backup, "items")
+	require.NoError(t, err)
+	assert.Len(t, state, 2)
+	assert.EqualValues(t, 3, state["i1"]["step"])
+	assert.EqualValues(t, 2, state["i2"]["step"])
+	assert.Nil(t, state["i3"])
+
+	t.Run("invalid target creates no backup", func(t *testing.T) {
+		_, _, err := restoreService.ResetBranchToLSNWithBackup(branch.ID, head+100, "never")
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, restore.ErrTargetOutOfRange))
+		_, err = branchService.GetBranch(project.ID, "never")
+		assert.Error(t, err)
+	})
+
+	t.Run("taken backup name fails before resetting", func(t *testing.T) {
+		before, _ := branchService.GetBranchByID(branch.ID)
+		_, _, err := restoreService.ResetBranchToLSNWithBackup(branch.ID, target, "before-reset")
+		require.Error(t, err)
+		after, _ := branchService.GetBranchByID(branch.ID)
+		assert.Equal(t, before.HeadLSN, after.HeadLSN)
+	})
+
+	t.Run("a removed backup is closed in the WAL", func(t *testing.T) {
+		// A failed reset purges its backup; the backup's create_branch
+		// entry must not be left standing on its own.
+		orphan, err := restoreService.CreateBranchAtLSN(project.ID, branch.ID, "orphan", target)
+		require.NoError(t, err)
+		require.NoError(t, branchService.PurgeBranch(orphan.ID))
+		closed, err := walService.GetEntries(bson.M{
+			"project_id": project.ID, "operation": wal.OpDeleteBranch, "metadata.branch_id": orphan.ID,
+		})
+		require.NoError(t, err)
+		require.Len(t, closed, 1)
+		assert.Equal(t, "orphan", closed[0].BranchID)
+		assert.Equal(t, true, closed[0].Metadata["purged"])
+	})
+}
 func TestRestore_ResetBranchToTime(t *testing.T) {
 	db := setupTestDB(t)
 	walService, _ := wal.NewService(db)
@@ -433,3 +522,248 @@ func TestRestore_ComplexScenario(t *testing.T) {
 		assert.Equal(t, "1.0", mainState2["app"]["version"])
 	})
 }
+
+func TestRestore_CreateBranchFromExternal(t *testing.T) {
+	db := setupTestDB(t)
+	walService, _ := wal.NewService(db)
+	branchService, _ := branchwal.NewBranchService(db, walService)
+	projectService, _ := projectwal.NewProjectService(db, walService, branchService)
+	materializerService := materializer.NewService(walService, branchService)
+	timeTravelService := timetravel.NewService(walService, materializerService)
+	restoreService := restore.NewService(walService, branchService, materializerService, timeTravelService)
+	restoreService.SetProjectLookup(projectService)
+	ctx := context.Background()
+
+	source, err := projectService.CreateProject("fork-source")
+	require.NoError(t, err)
+	target, err := projectService.CreateProject("fork-target")
+	require.NoError(t, err)
+	sourceBranches, _ := branchService.ListBranches(source.ID)
+	sourceMain := sourceBranches[0]
+	sourceWriter := walwriter.New(walService, branchService, materializerService, sourceMain)
+
+	_, err = sourceWriter.Put(ctx, "users", bson.M{"_id": "u1", "name": "Alice"})
+	require.NoError(t, err)
+	_, err = sourceWriter.Put(ctx, "orders", bson.M{"_id": "o1", "total": int32(10)})
+	require.NoError(t, err)
+	forkLSN := walService.GetCurrentLSN(source.ID)
+	_, err = sourceWriter.Put(ctx, "users", bson.M{"_id": "u2", "name": "Bob"})
+	require.NoError(t, err)
+
+	t.Run("Fork materializes the source state", func(t *testing.T) {
+		forked, err := restoreService.CreateBranchFromExternal(target.ID, source.ID, sourceMain.ID, forkLSN, "imported")
+		require.NoError(t, err)
+		assert.Equal(t, target.ID, forked.ProjectID)
+		assert.Empty(t, forked.ParentID)
+
+		forked, _ = branchService.GetBranchByID(forked.ID)
+		state, err := materializerService.MaterializeBranch(forked)
+		require.NoError(t, err)
+		require.Len(t, state["users"], 1, "writes after the fork LSN are not copied")
+		assert.Equal(t, "Alice", state["users"]["u1"]["name"])
+		assert.Equal(t, int32(10), state["orders"]["o1"]["total"])
+
+		// The fork and its source evolve independently.
+		_, err = walwriter.New(walService, branchService, materializerService, forked).Put(ctx, "users", bson.M{"_id": "u9", "name": "Zed"})
+		require.NoError(t, err)
+		_, _, err = sourceWriter.Delete(ctx, "orders", "o1")
+		require.NoError(t, err)
+
+		forked, _ = branchService.GetBranchByID(forked.ID)
+		forkedOrders, err := materializerService.MaterializeCollection(forked, "orders")
+		require.NoError(t, err)
+		assert.Contains(t, forkedOrders, "o1")
+
+		sourceHead, _ := branchService.GetBranchByID(sourceMain.ID)
+		sourceUsers, err := materializerService.MaterializeCollection(sourceHead, "users")
+		require.NoError(t, err)
+		assert.NotContains(t, sourceUsers, "u9")
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		_, err := restoreService.CreateBranchFromExternal("missing-project", source.ID, sourceMain.ID, 0, "nope")
+		assert.Error(t, err)
+
+		// The branch must belong to the named source project.
+		_, err = restoreService.CreateBranchFromExternal(source.ID, target.ID, sourceMain.ID, 0, "nope")
+		assert.ErrorContains(t, err, "does not belong")
+
+		_, err = restoreService.CreateBranchFromExternal(target.ID, source.ID, sourceMain.ID, forkLSN+100, "nope")
+		assert.ErrorContains(t, err, "outside source branch range")
+	})
+}
+
+func TestRestore_ReplayBranchFromExternal(t *testing.T) {
+	db := setupTestDB(t)
+	walService, _ := wal.NewService(db)
+	branchService, _ := branchwal.NewBranchService(db, walService)
+	projectService, _ := projectwal.NewProjectService(db, walService, branchService)
+	materializerService := materializer.NewService(walService, branchService)
+	timeTravelService := timetravel.NewService(walService, materializerService)
+	restoreService := restore.NewService(walService, branchService, materializerService, timeTravelService)
+	restoreService.SetProjectLookup(projectService)
+	ctx := context.Background()
+
+	source, err := projectService.CreateProject("replay-source")
+	require.NoError(t, err)
+	sourceMain, err := branchService.GetBranch(source.ID, "main")
+	require.NoError(t, err)
+	mainWriter := walwriter.New(walService, branchService, materializerService, sourceMain)
+	_, err = mainWriter.Put(ctx, "users", bson.M{"_id": "u1", "name": "Alice"})
+	require.NoError(t, err)
+	_, err = mainWriter.Put(ctx, "users", bson.M{"_id": "u2", "name": "Bob"})
+	require.NoError(t, err)
+
+	// The source is a feature branch: its history spans main up to the
+	// fork point and its own writes after it, but not main's later ones.
+	feature, err := branchService.CreateBranch(source.ID, "feature", sourceMain.ID)
+	require.NoError(t, err)
+	_, err = mainWriter.Put(ctx, "users", bson.M{"_id": "u3", "name": "main only"})
+	require.NoError(t, err)
+	featureWriter := walwriter.New(walService, branchService, materializerService, feature)
+	_, err = featureWriter.Put(ctx, "users", bson.M{"_id": "u1", "name": "Alice", "role": "admin"})
+	require.NoError(t, err)
+	_, _, err = featureWriter.Delete(ctx, "users", "u2")
+	require.NoError(t, err)
+	_, err = featureWriter.Put(ctx, "orders", bson.M{"_id": "o1", "total": int32(10)})
+	require.NoError(t, err)
+	// A control record filed under a collection is not replayed.
+	marker, err := walService.Append(&wal.Entry{
+		ProjectID: source.ID, BranchID: feature.ID, Operation: wal.OpMerge, Collection: "orders",
+	})
+	require.NoError(t, err)
+	require.NoError(t, branchService.UpdateBranchHead(feature.ID, marker, time.Now()))
+	feature, err = branchService.GetBranchByID(feature.ID)
+	require.NoError(t, err)
+
+	target, err := projectService.CreateProject("replay-target")
+	require.NoError(t, err)
+	targetMain, err := branchService.GetBranch(target.ID, "main")
+	require.NoError(t, err)
+	documents, entries, err := restoreService.ReplayBranchFromExternal(targetMain, source.ID, feature.ID, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 2, documents)
+	assert.Equal(t, 5, entries)
+
+	targetMain, err = branchService.GetBranchByID(targetMain.ID)
+	require.NoError(t, err)
+	want, err := materializerService.MaterializeBranch(feature)
+	require.NoError(t, err)
+	got, err := materializerService.MaterializeBranch(targetMain)
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+
+	// The history came along: u1 has both versions, u2 its put and delete.
+	history, err := walService.GetDocumentHistory(targetMain.ID, "users", "u1", 0, targetMain.HeadLSN)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	sourceHistory, err := walService.GetDocumentHistory(sourceMain.ID, "users", "u1", 0, feature.BaseLSN)
+	require.NoError(t, err)
+	require.Len(t, sourceHistory, 1)
+	assert.Equal(t, sourceHistory[0].Actor, history[0].Actor)
+	assert.Equal(t, sourceHistory[0].PostImage, history[0].PostImage)
+	deleted, err := walService.GetDocumentHistory(targetMain.ID, "users", "u2", 0, targetMain.HeadLSN)
+	require.NoError(t, err)
+	require.Len(t, deleted, 2)
+	assert.Equal(t, wal.OpDelete, deleted[1].Operation)
+	before, err := timeTravelService.MaterializeAtLSN(targetMain, "users", deleted[1].LSN-1)
+	require.NoError(t, err)
+	assert.Contains(t, before, "u2")
+
+	// Only an empty branch can receive a replay.
+	_, _, err = restoreService.ReplayBranchFromExternal(targetMain, source.ID, feature.ID, 0)
+	assert.ErrorContains(t, err, "already has entries")
+}
+
+func TestRestore_CherryPick(t *testing.T) {
+	db := setupTestDB(t)
+	walService, _ := wal.NewService(db)
+	branchService, _ := branchwal.NewBranchService(db, walService)
+	projectService, _ := projectwal.NewProjectService(db, walService, branchService)
+	materializerService := materializer.NewService(walService, branchService)
+	timeTravelService := timetravel.NewService(walService, materializerService)
+	restoreService := restore.NewService(walService, branchService, materializerService, timeTravelService)
+	ctx := context.Background()
+
+	project, err := projectService.CreateProject("cherry-pick-test")
+	require.NoError(t, err)
+	mainBranch, err := branchService.GetBranch(project.ID, "main")
+	require.NoError(t, err)
+	mainWriter := walwriter.New(walService, branchService, materializerService, mainBranch)
+	_, err = mainWriter.Put(ctx, "users", bson.M{"_id": "u1", "email": "broken"})
+	require.NoError(t, err)
+
+	feature, err := branchService.CreateBranch(project.ID, "feature", mainBranch.ID)
+	require.NoError(t, err)
+	featureWriter := walwriter.New(walService, branchService, materializerService, feature)
+	_, err = featureWriter.Put(ctx, "users", bson.M{"_id": "u1", "email": "fixed"})
+	require.NoError(t, err)
+	fixLSN := walService.GetCurrentLSN(project.ID)
+	_, err = featureWriter.Put(ctx, "users", bson.M{"_id": "u2", "email": "unrelated"})
+	require.NoError(t, err)
+	_, err = mainWriter.Put(ctx, "users", bson.M{"_id": "u3", "email": "main only"})
+	require.NoError(t, err)
+	feature, err = branchService.GetBranchByID(feature.ID)
+	require.NoError(t, err)
+
+	t.Run("Picked change lands, unrelated ones stay behind", func(t *testing.T) {
+		picked, err := restoreService.CherryPick(feature.ID, mainBranch.ID, fixLSN, fixLSN)
+		require.NoError(t, err)
+		assert.Equal(t, 1, picked)
+
+		mainBranch, err = branchService.GetBranchByID(mainBranch.ID)
+		require.NoError(t, err)
+		state, err := materializerService.MaterializeCollection(mainBranch, "users")
+		require.NoError(t, err)
+		assert.Equal(t, "fixed", state["u1"]["email"])
+		assert.NotContains(t, state, "u2")
+		assert.Contains(t, state, "u3")
+
+		// The pre-image is main's own prior version, not the feature's.
+		history, err := walService.GetDocumentHistory(mainBranch.ID, "users", "u1", 0, mainBranch.HeadLSN)
+		require.NoError(t, err)
+		require.Len(t, history, 2)
+		var pre bson.M
+		require.NoError(t, bson.Unmarshal(history[1].PreImage, &pre))
+		assert.Equal(t, "broken", pre["email"])
+	})
+
+	t.Run("Range must lie within the source branch", func(t *testing.T) {
+		_, err := restoreService.CherryPick(feature.ID, mainBranch.ID, feature.BaseLSN-1, fixLSN)
+		assert.ErrorContains(t, err, "outside")
+		_, err = restoreService.CherryPick(feature.ID, mainBranch.ID, fixLSN, feature.HeadLSN+1)
+		assert.ErrorContains(t, err, "outside")
+	})
+
+	t.Run("A picked transaction stays one transaction", func(t *testing.T) {
+		tx, err := featureWriter.WithTransaction(ctx, func(tx *walwriter.Tx) error {
+			if err := tx.Put(ctx, "users", bson.M{"_id": "t1"}); err != nil {
+				return err
+			}
+			return tx.Put(ctx, "users", bson.M{"_id": "t2"})
+		})
+		require.NoError(t, err)
+		feature, err = branchService.GetBranchByID(feature.ID)
+		require.NoError(t, err)
+
+		picked, err := restoreService.CherryPick(feature.ID, mainBranch.ID, tx.FirstLSN, tx.LastLSN)
+		require.NoError(t, err)
+		assert.Equal(t, 2, picked)
+
+		mainBranch, err = branchService.GetBranchByID(mainBranch.ID)
+		require.NoError(t, err)
+		copied, err := walService.GetBranchEntries(mainBranch.ID, "users", mainBranch.HeadLSN-1, mainBranch.HeadLSN)
+		require.NoError(t, err)
+		require.Len(t, copied, 2)
+		assert.NotEmpty(t, copied[0].TxnID)
+		assert.NotEqual(t, tx.TxnID, copied[0].TxnID)
+		assert.Equal(t, copied[0].TxnID, copied[1].TxnID)
+		assert.Equal(t, mainBranch.HeadLSN, copied[0].TxnLastLSN)
+
+		// Half a transaction is never picked.
+		_, err = restoreService.CherryPick(feature.ID, mainBranch.ID, tx.FirstLSN, tx.FirstLSN)
+		assert.ErrorContains(t, err, "ends inside a transaction")
+		_, err = restoreService.CherryPick(feature.ID, mainBranch.ID, tx.LastLSN, tx.LastLSN)
+		assert.ErrorContains(t, err, "starts inside a transaction")
+	})
+}
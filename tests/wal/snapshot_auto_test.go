@@ -122,3 +122,73 @@ func TestSnapshot_CleanupOnBranchDelete(t *testing.T) {
 	require.NoError(t, err)
 	assert.Len(t, state, 50)
 }
+
+func TestSnapshot_SweepOrphans(t *testing.T) {
+	db := setupTestDB(t)
+	f := newSnapshotFixture(t, db)
+	ctx := context.Background()
+
+	// No delete hook: every deletion below leaves its snapshots behind.
+	main, err := f.branches.CreateBranch("sweep-test", "main", "")
+	require.NoError(t, err)
+	mainWriter := walwriter.New(f.wal, f.branches, f.mat, main)
+	_, err = mainWriter.Put(ctx, "docs", bson.M{"_id": "d1", "on": "main"})
+	require.NoError(t, err)
+	main, _ = f.branches.GetBranchByID(main.ID)
+	_, err = f.snapshots.CreateSnapshot(ctx, main.ID, main.HeadLSN)
+	require.NoError(t, err)
+
+	snapshotted := func(name string) string {
+		b, err := f.branches.CreateBranch("sweep-test", name, main.ID)
+		require.NoError(t, err)
+		w := walwriter.New(f.wal, f.branches, f.mat, b)
+		_, err = w.Put(ctx, "docs", bson.M{"_id": "d2", "on": name})
+		require.NoError(t, err)
+		b, _ = f.branches.GetBranchByID(b.ID)
+		_, err = f.snapshots.CreateSnapshot(ctx, b.ID, b.HeadLSN)
+		require.NoError(t, err)
+		return b.ID
+	}
+	featureID := snapshotted("feature")
+	doomedID := snapshotted("doomed")
+	purgedID := snapshotted("purged")
+
+	require.NoError(t, f.branches.DeleteBranch("sweep-test", "doomed"))
+	require.NoError(t, f.branches.PurgeBranch(purgedID))
+	// Main is force-deleted (as project deletion does) but still anchors
+	// the live feature branch, so its snapshots must survive.
+	require.NoError(t, f.branches.ForceDeleteBranch("sweep-test", "main"))
+
+	count := func(branchID string) int {
+		snaps, err := f.snapshots.ListSnapshots(ctx, branchID)
+		require.NoError(t, err)
+		return len(snaps)
+	}
+
+	swept, manifests, _, err := f.snapshots.SweepOrphans(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), swept)
+	assert.Equal(t, int64(2), manifests)
+	assert.Zero(t, count(doomedID))
+	assert.Zero(t, count(purgedID))
+	assert.NotZero(t, count(main.ID), "force-deleted ancestor of a live branch is kept")
+	assert.NotZero(t, count(featureID))
+
+	feature, err := f.branches.GetBranchByID(featureID)
+	require.NoError(t, err)
+	state, err := f.mat.MaterializeCollection(feature, "docs")
+	require.NoError(t, err)
+	assert.Len(t, state, 2)
+
+	// Once the last descendant goes, the ancestor is swept too.
+	require.NoError(t, f.branches.DeleteBranch("sweep-test", "feature"))
+	swept, _, _, err = f.snapshots.SweepOrphans(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), swept)
+	assert.Zero(t, count(main.ID))
+	assert.Zero(t, count(featureID))
+
+	swept, _, _, err = f.snapshots.SweepOrphans(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, swept, "nothing left to sweep")
+}
@@ -258,7 +258,7 @@ func TestSnapshot_BoundsReplayDepth(t *testing.T) {
 		}
 		lsns, err := f.wal.AppendBatch(entries)
 		require.NoError(t, err)
-		require.NoError(t, f.branches.UpdateBranchHead(main.ID, lsns[len(lsns)-1]))
+		require.NoError(t, f.branches.UpdateBranchHead(main.ID, lsns[len(lsns)-1], time.Now()))
 	}
 	main, _ = f.branches.GetBranchByID(main.ID)
 
@@ -0,0 +1,106 @@
+package wal_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	branchwal "github.com/argon-lab/argon/internal/branch/wal"
+	"github.com/argon-lab/argon/internal/materializer"
+	"github.com/argon-lab/argon/internal/pin"
+	projectwal "github.com/argon-lab/argon/internal/project/wal"
+	"github.com/argon-lab/argon/internal/timetravel"
+	"github.com/argon-lab/argon/internal/wal"
+	"github.com/argon-lab/argon/internal/walwriter"
+	"github.com/argon-lab/argon/pkg/walcli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestTags_CreateResolveAndMaterialize(t *testing.T) {
+	db := setupTestDB(t)
+	walService, err := wal.NewService(db)
+	require.NoError(t, err)
+	branchService, err := branchwal.NewBranchService(db, walService)
+	require.NoError(t, err)
+	projectService, err := projectwal.NewProjectService(db, walService, branchService)
+	require.NoError(t, err)
+	mat := materializer.NewService(walService, branchService)
+	tt := timetravel.NewService(walService, mat)
+	require.NoError(t, tt.EnableTags(db, branchService))
+	pins, err := pin.NewService(db, branchService)
+	require.NoError(t, err)
+	services := &walcli.Services{
+		WAL: walService, Branches: branchService, Projects: projectService, Materializer: mat,
+		TimeTravel: tt, Pins: pins,
+	}
+	ctx := context.Background()
+
+	project, err := projectService.CreateProject("tags-project")
+	require.NoError(t, err)
+	main, err := branchService.GetBranch(project.ID, "main")
+	require.NoError(t, err)
+	writer := walwriter.New(walService, branchService, mat, main)
+	for i := 0; i < 3; i++ {
+		_, err := writer.Put(ctx, "docs", bson.M{"_id": fmt.Sprintf("d%d", i)})
+		require.NoError(t, err)
+	}
+
+	// LSN 0 tags the head.
+	tag, err := tt.CreateTag(main.ID, "pre-migration", 0)
+	require.NoError(t, err)
+	head, err := branchService.GetBranchByID(main.ID)
+	require.NoError(t, err)
+	assert.Equal(t, head.HeadLSN, tag.LSN)
+
+	// Names are unique per branch, not per project.
+	_, err = tt.CreateTag(main.ID, "pre-migration", tag.LSN-1)
+	require.ErrorContains(t, err, "already exists")
+	feature, err := branchService.CreateBranch(project.ID, "feature", main.ID)
+	require.NoError(t, err)
+	_, err = tt.CreateTag(feature.ID, "pre-migration", 0)
+	require.NoError(t, err)
+
+	// LSNs beyond the head are refused.
+	_, err = tt.CreateTag(main.ID, "future", head.HeadLSN+100)
+	require.ErrorContains(t, err, "outside branch range")
+
+	_, err = writer.Put(ctx, "docs", bson.M{"_id": "d3"})
+	require.NoError(t, err)
+	head, err = branchService.GetBranchByID(main.ID)
+	require.NoError(t, err)
+
+	got, err := tt.GetTag(main.ID, "pre-migration")
+	require.NoError(t, err)
+	assert.Equal(t, tag.LSN, got.LSN)
+	_, err = tt.GetTag(main.ID, "missing")
+	require.ErrorIs(t, err, timetravel.ErrTagNotFound)
+	tags, err := tt.ListTags(main.ID)
+	require.NoError(t, err)
+	require.Len(t, tags, 1)
+
+	// The tagged state materializes without the later write.
+	state, err := tt.MaterializeAtTag(head, "docs", "pre-migration")
+	require.NoError(t, err)
+	assert.Len(t, state, 3)
+	assert.NotContains(t, state, "d3")
+
+	// TagLSN resolves the branch's tag or a pin.
+	lsn, err := services.TagLSN(head, "pre-migration")
+	require.NoError(t, err)
+	assert.Equal(t, tag.LSN, lsn)
+	p, err := pins.Create(project.ID, main.ID, "v1", 0, "")
+	require.NoError(t, err)
+	lsn, err = services.TagLSN(head, "v1")
+	require.NoError(t, err)
+	assert.Equal(t, p.LSN, lsn)
+	_, err = services.TagLSN(head, "missing")
+	require.ErrorContains(t, err, "not found")
+
+	// A name that is both a tag and a pin is ambiguous.
+	_, err = pins.Create(project.ID, main.ID, "pre-migration", 0, "")
+	require.NoError(t, err)
+	_, err = services.TagLSN(head, "pre-migration")
+	require.ErrorContains(t, err, "both a tag")
+}
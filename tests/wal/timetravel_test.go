@@ -2,15 +2,18 @@ package wal_test
 
 import (
 	"context"
+	"fmt"
 	"testing"
 	"time"
 
 	branchwal "github.com/argon-lab/argon/internal/branch/wal"
 	"github.com/argon-lab/argon/internal/walwriter"
 	"github.com/argon-lab/argon/internal/materializer"
+	"github.com/argon-lab/argon/internal/mongoexpr"
 	projectwal "github.com/argon-lab/argon/internal/project/wal"
 	"github.com/argon-lab/argon/internal/timetravel"
 	"github.com/argon-lab/argon/internal/wal"
+	"github.com/argon-lab/argon/pkg/walcli"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.mongodb.org/mongo-driver/bson"
@@ -243,6 +246,61 @@ func TestTimeTravel_DocumentHistory(t *testing.T) {
 	})
 }
 
+func TestTimeTravel_DeletedDocument(t *testing.T) {
+	db := setupTestDB(t)
+	walService, _ := wal.NewService(db)
+	branchService, _ := branchwal.NewBranchService(db, walService)
+	projectService, _ := projectwal.NewProjectService(db, walService, branchService)
+	materializerService := materializer.NewService(walService, branchService)
+	timeTravelService := timetravel.NewService(walService, materializerService)
+	ctx := context.Background()
+
+	project, _ := projectService.CreateProject("deleted-doc")
+	branches, _ := branchService.ListBranches(project.ID)
+	branch := branches[0]
+	writer := walwriter.New(walService, branchService, materializerService, branch)
+
+	_, err := writer.Put(ctx, "items", bson.M{"_id": "i1", "status": "draft"})
+	require.NoError(t, err)
+	beforeDelete, err := writer.Put(ctx, "items", bson.M{"_id": "i1", "status": "published"})
+	require.NoError(t, err)
+	firstDelete, _, err := writer.Delete(ctx, "items", "i1")
+	require.NoError(t, err)
+	_, err = writer.Put(ctx, "items", bson.M{"_id": "i1", "status": "restored"})
+	require.NoError(t, err)
+	secondDelete, _, err := writer.Delete(ctx, "items", "i1")
+	require.NoError(t, err)
+	branch, _ = branchService.GetBranchByID(branch.ID)
+
+	deleted, err := timeTravelService.GetDeletedDocument(branch, "items", "i1", firstDelete)
+	require.NoError(t, err)
+	assert.Equal(t, firstDelete, deleted.LSN)
+	assert.Equal(t, "published", deleted.Document["status"])
+
+	deleted, err = timeTravelService.GetDeletedDocument(branch, "items", "i1", branch.HeadLSN)
+	require.NoError(t, err)
+	assert.Equal(t, secondDelete, deleted.LSN)
+	assert.Equal(t, "restored", deleted.Document["status"])
+
+	_, err = timeTravelService.GetDeletedDocument(branch, "items", "i1", beforeDelete)
+	assert.ErrorIs(t, err, wal.ErrNoEntriesFound)
+
+	// A delete recorded without a pre-image is recovered by replay.
+	_, err = writer.Put(ctx, "items", bson.M{"_id": "i2", "status": "captured"})
+	require.NoError(t, err)
+	bare, err := walService.Append(&wal.Entry{
+		ProjectID: project.ID, BranchID: branch.ID, Operation: wal.OpDelete,
+		Collection: "items", DocumentID: "i2",
+	})
+	require.NoError(t, err)
+	require.NoError(t, branchService.UpdateBranchHead(branch.ID, bare, time.Now()))
+	branch, _ = branchService.GetBranchByID(branch.ID)
+	deleted, err = timeTravelService.GetDeletedDocument(branch, "items", "i2", branch.HeadLSN)
+	require.NoError(t, err)
+	assert.Equal(t, bare, deleted.LSN)
+	assert.Equal(t, "captured", deleted.Document["status"])
+}
+
 func TestTimeTravel_ModifiedCollections(t *testing.T) {
 	db := setupTestDB(t)
 	walService, _ := wal.NewService(db)
@@ -294,6 +352,60 @@ func TestTimeTravel_ModifiedCollections(t *testing.T) {
 	})
 }
 
+func TestTimeTravel_ModifiedDocuments(t *testing.T) {
+	db := setupTestDB(t)
+	walService, _ := wal.NewService(db)
+	branchService, _ := branchwal.NewBranchService(db, walService)
+	projectService, _ := projectwal.NewProjectService(db, walService, branchService)
+	materializerService := materializer.NewService(walService, branchService)
+	timeTravelService := timetravel.NewService(walService, materializerService)
+	ctx := context.Background()
+
+	project, _ := projectService.CreateProject("modified-docs-test")
+	branches, _ := branchService.ListBranches(project.ID)
+	branch := branches[0]
+	writer := walwriter.New(walService, branchService, materializerService, branch)
+
+	// Outside the range.
+	_, err := writer.Put(ctx, "users", bson.M{"_id": "u0"})
+	require.NoError(t, err)
+	fromLSN := walService.GetCurrentLSN(project.ID) + 1
+
+	_, err = writer.Put(ctx, "users", bson.M{"_id": "u1"})
+	require.NoError(t, err)
+	_, err = writer.Put(ctx, "users", bson.M{"_id": "u2"})
+	require.NoError(t, err)
+	_, err = writer.Put(ctx, "orders", bson.M{"_id": "o1"})
+	require.NoError(t, err)
+	_, err = writer.Put(ctx, "users", bson.M{"_id": "u1", "v": 2})
+	require.NoError(t, err)
+	_, _, err = writer.Delete(ctx, "users", "u2")
+	require.NoError(t, err)
+	toLSN := walService.GetCurrentLSN(project.ID)
+
+	branch, _ = branchService.GetBranchByID(branch.ID)
+	modified, err := timeTravelService.GetDocumentsModifiedInRange(branch, "users", fromLSN, toLSN)
+	require.NoError(t, err)
+	require.Len(t, modified, 2)
+
+	// Most recently changed first, with every operation in LSN order.
+	assert.Equal(t, "u2", modified[0].DocumentID)
+	assert.Equal(t, []wal.OperationType{wal.OpPut, wal.OpDelete}, modified[0].Operations)
+	assert.Equal(t, toLSN, modified[0].LastLSN)
+	assert.Equal(t, "u1", modified[1].DocumentID)
+	assert.Equal(t, []wal.OperationType{wal.OpPut, wal.OpPut}, modified[1].Operations)
+
+	// The range bounds are honoured.
+	modified, err = timeTravelService.GetDocumentsModifiedInRange(branch, "users", fromLSN, fromLSN)
+	require.NoError(t, err)
+	require.Len(t, modified, 1)
+	assert.Equal(t, "u1", modified[0].DocumentID)
+	assert.Equal(t, []wal.OperationType{wal.OpPut}, modified[0].Operations)
+
+	_, err = timeTravelService.GetDocumentsModifiedInRange(branch, "", fromLSN, toLSN)
+	assert.Error(t, err)
+}
+
 func TestTimeTravel_Info(t *testing.T) {
 	db := setupTestDB(t)
 	walService, _ := wal.NewService(db)
@@ -336,6 +448,309 @@ func TestTimeTravel_Info(t *testing.T) {
 	})
 }
 
+func TestTimeTravel_InfoPerCollection(t *testing.T) {
+	db := setupTestDB(t)
+	walService, _ := wal.NewService(db)
+	branchService, _ := branchwal.NewBranchService(db, walService)
+	projectService, _ := projectwal.NewProjectService(db, walService, branchService)
+	materializerService := materializer.NewService(walService, branchService)
+	timeTravelService := timetravel.NewService(walService, materializerService)
+	ctx := context.Background()
+
+	project, err := projectService.CreateProject("info-collections-test")
+	require.NoError(t, err)
+	branch, err := branchService.GetBranch(project.ID, "main")
+	require.NoError(t, err)
+	interceptor := walwriter.New(walService, branchService, materializerService, branch)
+
+	info, err := timeTravelService.GetTimeTravelInfo(branch)
+	require.NoError(t, err)
+	assert.Empty(t, info.Collections)
+
+	usersLSN, err := interceptor.Put(ctx, "users", bson.M{"_id": "u1"})
+	require.NoError(t, err)
+	_, err = interceptor.Put(ctx, "users", bson.M{"_id": "u2"})
+	require.NoError(t, err)
+	time.Sleep(10 * time.Millisecond)
+	// orders is created later than users.
+	ordersLSN, err := interceptor.Put(ctx, "orders", bson.M{"_id": "o1"})
+	require.NoError(t, err)
+	_, err = interceptor.Put(ctx, "users", bson.M{"_id": "u3"})
+	require.NoError(t, err)
+
+	branch, err = branchService.GetBranchByID(branch.ID)
+	require.NoError(t, err)
+	info, err = timeTravelService.GetTimeTravelInfo(branch)
+	require.NoError(t, err)
+	require.Len(t, info.Collections, 2)
+	assert.Equal(t, usersLSN, info.Collections["users"].EarliestLSN)
+	assert.Equal(t, ordersLSN, info.Collections["orders"].EarliestLSN)
+	assert.Equal(t, info.EarliestLSN, info.Collections["users"].EarliestLSN)
+	assert.True(t, info.Collections["orders"].EarliestTime.After(info.Collections["users"].EarliestTime))
+}
+
+func TestTimeTravel_Projection(t *testing.T) {
+	doc := bson.M{
+		"_id":     "u1",
+		"name":    "ada",
+		"email":   "ada@example.com",
+		"address": bson.M{"city": "Oslo", "zip": "0150"},
+		"orders":  bson.A{bson.M{"sku": "s1", "qty": int32(2)}, bson.M{"sku": "s2", "qty": int32(1)}, "legacy"},
+	}
+	project := func(projection bson.M) bson.M {
+		t.Helper()
+		p, err := timetravel.CompileProjection(projection)
+		require.NoError(t, err)
+		return p.Apply(doc)
+	}
+
+	t.Run("Inclusion", func(t *testing.T) {
+		assert.Equal(t, bson.M{"_id": "u1", "name": "ada"}, project(bson.M{"name": 1}))
+		assert.Equal(t, bson.M{"name": "ada"}, project(bson.M{"name": 1, "_id": 0}))
+		assert.Equal(t, bson.M{"_id": "u1", "address": bson.M{"city": "Oslo"}}, project(bson.M{"address.city": true}))
+		assert.Equal(t, bson.M{"_id": "u1", "orders": bson.A{bson.M{"sku": "s1"}, bson.M{"sku": "s2"}}},
+			project(bson.M{"orders.sku": 1}), "scalar array elements drop out")
+		assert.Equal(t, bson.M{"_id": "u1"}, project(bson.M{"_id": 1}))
+		assert.Equal(t, bson.M{"_id": "u1"}, project(bson.M{"missing": 1}))
+	})
+
+	t.Run("Exclusion", func(t *testing.T) {
+		assert.Equal(t, bson.M{"_id": "u1", "name": "ada", "address": doc["address"], "orders": doc["orders"]},
+			project(bson.M{"email": 0}))
+		got := project(bson.M{"address.zip": 0, "orders.qty": 0, "_id": 0})
+		assert.Equal(t, bson.M{
+			"name":    "ada",
+			"email":   "ada@example.com",
+			"address": bson.M{"city": "Oslo"},
+			"orders":  bson.A{bson.M{"sku": "s1"}, bson.M{"sku": "s2"}, "legacy"},
+		}, got)
+		assert.Equal(t, "0150", doc["address"].(bson.M)["zip"], "the source document is not modified")
+		assert.Equal(t, doc, project(bson.M{}))
+	})
+
+	t.Run("Invalid projections", func(t *testing.T) {
+		for _, projection := range []bson.M{
+			{"name": 1, "email": 0},
+			{"address": 1, "address.city": 1},
+			{"name": "yes"},
+			{"$name": 1},
+			{"address..city": 1},
+		} {
+			_, err := timetravel.CompileProjection(projection)
+			assert.Error(t, err, "%v", projection)
+		}
+	})
+}
+
+func TestTimeTravel_MaterializeWithProjection(t *testing.T) {
+	db := setupTestDB(t)
+	walService, _ := wal.NewService(db)
+	branchService, _ := branchwal.NewBranchService(db, walService)
+	materializerService := materializer.NewService(walService, branchService)
+	timeTravelService := timetravel.NewService(walService, materializerService)
+	ctx := context.Background()
+
+	branch, err := branchService.CreateBranch("projection-test", "main", "")
+	require.NoError(t, err)
+	writer := walwriter.New(walService, branchService, materializerService, branch)
+	first, err := writer.Put(ctx, "users", bson.M{"_id": "u1", "name": "ada", "bio": "long text"})
+	require.NoError(t, err)
+	_, err = writer.Put(ctx, "users", bson.M{"_id": "u1", "name": "ada lovelace", "bio": "longer text"})
+	require.NoError(t, err)
+
+	state, err := timeTravelService.MaterializeAtLSNWithProjection(branch, "users", first, bson.M{"name": 1})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bson.M{"u1": {"_id": "u1", "name": "ada"}}, state)
+
+	state, err = timeTravelService.MaterializeAtLSNWithProjection(branch, "users", branch.HeadLSN, bson.M{"bio": 0})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bson.M{"u1": {"_id": "u1", "name": "ada lovelace"}}, state)
+
+	_, err = timeTravelService.MaterializeAtLSNWithProjection(branch, "users", first, bson.M{"name": 1, "bio": 0})
+	assert.Error(t, err)
+}
+
+func TestTimeTravel_QueryAtLSN(t *testing.T) {
+	db := setupTestDB(t)
+	walService, _ := wal.NewService(db)
+	branchService, _ := branchwal.NewBranchService(db, walService)
+	materializerService := materializer.NewService(walService, branchService)
+	timeTravelService := timetravel.NewService(walService, materializerService)
+	ctx := context.Background()
+
+	branch, err := branchService.CreateBranch("query-at-lsn", "main", "")
+	require.NoError(t, err)
+	writer := walwriter.New(walService, branchService, materializerService, branch)
+	for i, status := range []string{"open", "open", "closed"} {
+		_, err := writer.Put(ctx, "tickets", bson.M{"_id": fmt.Sprintf("t%d", i), "status": status, "priority": int32(i + 1)})
+		require.NoError(t, err)
+	}
+	before := walService.GetCurrentLSN(branch.ProjectID)
+	_, err = writer.Put(ctx, "tickets", bson.M{"_id": "t0", "status": "closed", "priority": int32(9)})
+	require.NoError(t, err)
+	branch, err = branchService.GetBranchByID(branch.ID)
+	require.NoError(t, err)
+
+	// Equality, then and now.
+	open, err := timeTravelService.QueryAtLSN(branch, "tickets", bson.M{"status": "open"}, before)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"t0", "t1"}, keys(open))
+	open, err = timeTravelService.QueryAtLSN(branch, "tickets", bson.M{"status": "open"}, branch.HeadLSN)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"t1"}, keys(open))
+
+	// Ranges.
+	urgent, err := timeTravelService.QueryAtLSN(branch, "tickets", bson.M{"priority": bson.M{"$gte": 2, "$lt": 10}}, branch.HeadLSN)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"t0", "t1", "t2"}, keys(urgent))
+	count, err := timeTravelService.CountAtLSN(branch, "tickets", bson.M{"priority": bson.M{"$gt": 1}}, before)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	// The empty filter matches everything; a bad one is refused.
+	count, err = timeTravelService.CountAtLSN(branch, "tickets", nil, before)
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+	_, err = timeTravelService.QueryAtLSN(branch, "tickets", bson.M{"priority": bson.M{"$near": 1}}, before)
+	assert.ErrorContains(t, err, "invalid filter")
+}
+
+func TestTimeTravel_QueryAtLSNArrays(t *testing.T) {
+	db := setupTestDB(t)
+	walService, _ := wal.NewService(db)
+	branchService, _ := branchwal.NewBranchService(db, walService)
+	materializerService := materializer.NewService(walService, branchService)
+	timeTravelService := timetravel.NewService(walService, materializerService)
+	ctx := context.Background()
+
+	branch, err := branchService.CreateBranch("query-arrays", "main", "")
+	require.NoError(t, err)
+	writer := walwriter.New(walService, branchService, materializerService, branch)
+	for _, order := range lineItemOrders() {
+		_, err := writer.Put(ctx, "orders", order)
+		require.NoError(t, err)
+	}
+	branch, err = branchService.GetBranchByID(branch.ID)
+	require.NoError(t, err)
+
+	bulk, err := timeTravelService.QueryAtLSN(branch, "orders",
+		bson.M{"items": bson.M{"$elemMatch": bson.M{"sku": "A", "qty": bson.M{"$gte": 10}}}}, branch.HeadLSN)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"o2"}, keys(bulk))
+	count, err := timeTravelService.CountAtLSN(branch, "orders", bson.M{"tags": bson.M{"$all": bson.A{"gift", "express"}}}, branch.HeadLSN)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	count, err = timeTravelService.CountAtLSN(branch, "orders", bson.M{"items": bson.M{"$size": 1}}, branch.HeadLSN)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestTimeTravel_DocumentTimeline(t *testing.T) {
+	db := setupTestDB(t)
+	walService, _ := wal.NewService(db)
+	branchService, _ := branchwal.NewBranchService(db, walService)
+	materializerService := materializer.NewService(walService, branchService)
+	timeTravelService := timetravel.NewService(walService, materializerService)
+	ctx := context.Background()
+
+	main, err := branchService.CreateBranch("doc-timeline", "main", "")
+	require.NoError(t, err)
+	writer := walwriter.New(walService, branchService, materializerService, main)
+	inserted, err := writer.Put(ctx, "users", bson.M{"_id": "u1", "name": "ada"})
+	require.NoError(t, err)
+	_, err = writer.Put(ctx, "users", bson.M{"_id": "u2", "name": "grace"})
+	require.NoError(t, err)
+	updated, err := writer.Put(ctx, "users", bson.M{"_id": "u1", "name": "ada lovelace"})
+	require.NoError(t, err)
+	deleted, _, err := writer.Delete(ctx, "users", "u1")
+	require.NoError(t, err)
+	reinserted, err := writer.Put(ctx, "users", bson.M{"_id": "u1", "name": "ada again"})
+	require.NoError(t, err)
+	main, err = branchService.GetBranchByID(main.ID)
+	require.NoError(t, err)
+
+	timeline, err := timeTravelService.GetDocumentTimeline(main, "users", "u1")
+	require.NoError(t, err)
+	require.Len(t, timeline, 4)
+	type step struct {
+		lsn       int64
+		op        wal.OperationType
+		state     bson.M
+		lifecycle int
+	}
+	var got []step
+	for _, v := range timeline {
+		assert.False(t, v.Timestamp.IsZero())
+		got = append(got, step{v.LSN, v.Operation, v.State, v.Lifecycle})
+	}
+	assert.Equal(t, []step{
+		{inserted, wal.OpPut, bson.M{"_id": "u1", "name": "ada"}, 1},
+		{updated, wal.OpPut, bson.M{"_id": "u1", "name": "ada lovelace"}, 1},
+		{deleted, wal.OpDelete, nil, 1}, // the tombstone ends the first life
+		{reinserted, wal.OpPut, bson.M{"_id": "u1", "name": "ada again"}, 2},
+	}, got)
+
+	// A child inherits the history below its fork point, then adds its own.
+	feature, err := branchService.CreateBranch("doc-timeline", "feature", main.ID)
+	require.NoError(t, err)
+	featureWriter := walwriter.New(walService, branchService, materializerService, feature)
+	forked, err := featureWriter.Put(ctx, "users", bson.M{"_id": "u1", "name": "ada on feature"})
+	require.NoError(t, err)
+	feature, err = branchService.GetBranchByID(feature.ID)
+	require.NoError(t, err)
+	timeline, err = timeTravelService.GetDocumentTimeline(feature, "users", "u1")
+	require.NoError(t, err)
+	require.Len(t, timeline, 5)
+	assert.Equal(t, forked, timeline[4].LSN)
+	assert.Equal(t, 2, timeline[4].Lifecycle)
+	timeline, err = timeTravelService.GetDocumentTimeline(main, "users", "u1")
+	require.NoError(t, err)
+	assert.Len(t, timeline, 4, "the child's write is not main's history")
+
+	// Unknown documents have an empty timeline.
+	timeline, err = timeTravelService.GetDocumentTimeline(main, "users", "nobody")
+	require.NoError(t, err)
+	assert.Empty(t, timeline)
+	_, err = timeTravelService.GetDocumentTimeline(main, "", "u1")
+	assert.Error(t, err)
+}
+
+func TestParseFilter(t *testing.T) {
+	filter, err := walcli.ParseFilter(`{"age": {"$gte": 30}, "$or": [{"a": 1}, {"b": {"$oid": "5f1d7f1b2c3a4b5c6d7e8f90"}}]}`)
+	require.NoError(t, err)
+	assert.Equal(t, bson.M{"$gte": int32(30)}, filter["age"])
+	or := filter["$or"].([]interface{})
+	require.Len(t, or, 2)
+	assert.IsType(t, primitive.ObjectID{}, or[1].(bson.M)["b"])
+
+	matched, err := mongoexpr.MatchesFilter(bson.M{"age": int64(31), "a": 1}, filter)
+	require.NoError(t, err)
+	assert.True(t, matched)
+
+	// An embedded document compared as a value keeps its field order.
+	filter, err = walcli.ParseFilter(`{"customer": {"name": "ada", "city": "Oslo"}, "tags": {"$in": [{"k": "a", "v": 1}]}}`)
+	require.NoError(t, err)
+	assert.Equal(t, bson.D{{Key: "name", Value: "ada"}, {Key: "city", Value: "Oslo"}}, filter["customer"])
+	assert.Equal(t, bson.M{"$in": []interface{}{bson.D{{Key: "k", Value: "a"}, {Key: "v", Value: int32(1)}}}}, filter["tags"])
+	matched, err = mongoexpr.MatchesFilter(bson.M{"customer": bson.M{"city": "Oslo", "name": "ada"}}, bson.M{"customer": filter["customer"]})
+	require.NoError(t, err)
+	assert.True(t, matched)
+
+	empty, err := walcli.ParseFilter("  ")
+	require.NoError(t, err)
+	assert.Empty(t, empty)
+	_, err = walcli.ParseFilter(`{"age": `)
+	assert.Error(t, err)
+}
+
+func keys(state map[string]bson.M) []string {
+	out := make([]string, 0, len(state))
+	for k := range state {
+		out = append(out, k)
+	}
+	return out
+}
+
 func TestTimeTravel_ComplexScenario(t *testing.T) {
 	db := setupTestDB(t)
 	walService, _ := wal.NewService(db)
@@ -0,0 +1,174 @@
+package wal_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/argon-lab/argon/internal/wal"
+	"github.com/argon-lab/argon/internal/walwriter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// transferFixture is a branch holding accounts a=100 and b=0, and a
+// committed transaction that moves 40 from a to b (writing a twice).
+func transferFixture(t *testing.T, f *snapshotFixture, project string) (*wal.Branch, *walwriter.TxResult) {
+	t.Helper()
+	ctx := context.Background()
+	main, err := f.branches.CreateBranch(project, "main", "")
+	require.NoError(t, err)
+	writer := walwriter.New(f.wal, f.branches, f.mat, main)
+	_, err = writer.Put(ctx, "accounts", bson.M{"_id": "a", "balance": int32(100)})
+	require.NoError(t, err)
+	_, err = writer.Put(ctx, "accounts", bson.M{"_id": "b", "balance": int32(0)})
+	require.NoError(t, err)
+
+	result, err := writer.WithTransaction(ctx, func(tx *walwriter.Tx) error {
+		if err := tx.Put(ctx, "accounts", bson.M{"_id": "a", "balance": int32(80)}); err != nil {
+			return err
+		}
+		if err := tx.Put(ctx, "accounts", bson.M{"_id": "b", "balance": int32(40)}); err != nil {
+			return err
+		}
+		return tx.Put(ctx, "accounts", bson.M{"_id": "a", "balance": int32(60)})
+	})
+	require.NoError(t, err)
+	require.NotNil(t, result)
+	require.Len(t, result.LSNs, 3)
+	main, err = f.branches.GetBranchByID(main.ID)
+	require.NoError(t, err)
+	require.Equal(t, result.LastLSN, main.HeadLSN)
+	return main, result
+}
+
+func balances(t *testing.T, state map[string]bson.M) [2]int32 {
+	t.Helper()
+	return [2]int32{state["a"]["balance"].(int32), state["b"]["balance"].(int32)}
+}
+
+func TestTransaction_NoIntermediateStateAtAnyLSN(t *testing.T) {
+	db := setupTestDB(t)
+	f := newSnapshotFixture(t, db)
+	main, result := transferFixture(t, f, "txn-atomic")
+
+	before, after := [2]int32{100, 0}, [2]int32{60, 40}
+	for lsn := result.FirstLSN - 1; lsn <= result.LastLSN; lsn++ {
+		want := before
+		if lsn == result.LastLSN {
+			want = after
+		}
+		state, err := f.timeTravel.MaterializeAtLSN(main, "accounts", lsn)
+		require.NoError(t, err)
+		assert.Equal(t, want, balances(t, state), "LSN %d", lsn)
+		branchState, err := f.matFull.MaterializeBranchAtLSN(main, lsn)
+		require.NoError(t, err)
+		assert.Equal(t, want, balances(t, branchState["accounts"]), "LSN %d, full replay", lsn)
+		doc, err := f.mat.MaterializeDocumentAtLSN(main, "accounts", "a", lsn)
+		require.NoError(t, err)
+		assert.Equal(t, want[0], doc["balance"], "LSN %d, point lookup", lsn)
+
+		// Applying incrementally from inside the block catches up fully.
+		caught, err := f.mat.ApplyEntriesFrom(state, main, "accounts", lsn, result.LastLSN)
+		require.NoError(t, err)
+		assert.Equal(t, after, balances(t, caught), "from LSN %d", lsn)
+	}
+
+	// The timeline folds the transaction's two writes of a into one step.
+	timeline, err := f.timeTravel.GetDocumentTimeline(main, "accounts", "a")
+	require.NoError(t, err)
+	require.Len(t, timeline, 2)
+	assert.Equal(t, result.LastLSN, timeline[1].LSN)
+	assert.Equal(t, int32(60), timeline[1].State["balance"])
+	assert.Equal(t, 1, timeline[1].Lifecycle)
+
+	entries, err := f.wal.GetBranchEntries(main.ID, "", result.FirstLSN, result.LastLSN)
+	require.NoError(t, err)
+	for _, entry := range entries {
+		assert.Equal(t, result.TxnID, entry.TxnID)
+		assert.Equal(t, result.LastLSN, entry.TxnLastLSN)
+	}
+}
+
+func TestTransaction_RestoreNeverLandsInside(t *testing.T) {
+	db := setupTestDB(t)
+	f := newSnapshotFixture(t, db)
+	ctx := context.Background()
+	main, result := transferFixture(t, f, "txn-restore")
+
+	for lsn := result.FirstLSN; lsn < result.LastLSN; lsn++ {
+		safe, err := f.wal.TxnSafeLSN(main.ID, lsn)
+		require.NoError(t, err)
+		assert.Equal(t, result.FirstLSN-1, safe, "LSN %d", lsn)
+	}
+	safe, err := f.wal.TxnSafeLSN(main.ID, result.LastLSN)
+	require.NoError(t, err)
+	assert.Equal(t, result.LastLSN, safe)
+
+	preview, err := f.restore.GetRestorePreview(main.ID, result.FirstLSN+1)
+	require.NoError(t, err)
+	assert.Equal(t, result.FirstLSN-1, preview.TargetLSN)
+	assert.Equal(t, 3, preview.OperationsToDiscard)
+
+	fork, err := f.restore.CreateBranchAtLSN(main.ProjectID, main.ID, "mid-txn", result.FirstLSN+1)
+	require.NoError(t, err)
+	assert.Equal(t, result.FirstLSN-1, fork.BaseLSN)
+
+	reset, err := f.restore.ResetBranchToLSN(main.ID, result.FirstLSN+1)
+	require.NoError(t, err)
+	assert.Equal(t, result.FirstLSN-1, reset.HeadLSN)
+
+	// Later writes do not resurrect part of the discarded transaction.
+	writer := walwriter.New(f.wal, f.branches, f.mat, reset)
+	_, err = writer.Put(ctx, "audit", bson.M{"_id": "reset"})
+	require.NoError(t, err)
+	reset, err = f.branches.GetBranchByID(main.ID)
+	require.NoError(t, err)
+	state, err := f.mat.MaterializeCollection(reset, "accounts")
+	require.NoError(t, err)
+	assert.Equal(t, [2]int32{100, 0}, balances(t, state))
+}
+
+func TestTransaction_WithTransactionAborts(t *testing.T) {
+	db := setupTestDB(t)
+	f := newSnapshotFixture(t, db)
+	ctx := context.Background()
+	main, err := f.branches.CreateBranch("txn-abort", "main", "")
+	require.NoError(t, err)
+	writer := walwriter.New(f.wal, f.branches, f.mat, main)
+	head := f.wal.GetCurrentLSN("txn-abort")
+
+	boom := errors.New("boom")
+	_, err = writer.WithTransaction(ctx, func(tx *walwriter.Tx) error {
+		require.NoError(t, tx.Put(ctx, "docs", bson.M{"_id": "d1"}))
+		return boom
+	})
+	assert.ErrorIs(t, err, boom)
+
+	assert.Panics(t, func() {
+		_, _ = writer.WithTransaction(ctx, func(tx *walwriter.Tx) error {
+			require.NoError(t, tx.Put(ctx, "docs", bson.M{"_id": "d2"}))
+			panic("boom")
+		})
+	})
+
+	cancelled, cancel := context.WithCancel(ctx)
+	_, err = writer.WithTransaction(cancelled, func(tx *walwriter.Tx) error {
+		cancel()
+		return tx.Put(ctx, "docs", bson.M{"_id": "d3"})
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// Nothing staged, nothing committed.
+	result, err := writer.WithTransaction(ctx, func(tx *walwriter.Tx) error { return nil })
+	require.NoError(t, err)
+	assert.Nil(t, result)
+
+	assert.Equal(t, head, f.wal.GetCurrentLSN("txn-abort"))
+	stored, err := f.branches.GetBranchByID(main.ID)
+	require.NoError(t, err)
+	state, err := f.mat.MaterializeCollection(stored, "docs")
+	require.NoError(t, err)
+	assert.Empty(t, state)
+}
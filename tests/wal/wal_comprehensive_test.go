@@ -141,7 +141,7 @@ func TestBranchService_ComplexScenarios(t *testing.T) {
 			lsn, _ := walService.Append(putEntry("proj-4", branch.ID, "data", fmt.Sprintf("doc-%d", i)))
 
 			// Update branch head
-			err = branchService.UpdateBranchHead(branch.ID, lsn)
+			err = branchService.UpdateBranchHead(branch.ID, lsn, time.Now())
 			assert.NoError(t, err)
 
 			// Verify update
@@ -267,7 +267,7 @@ func TestBranchService_LSNConsistency(t *testing.T) {
 		// Simulate some operations on main
 		for i := 0; i < 3; i++ {
 			lsn, _ := walService.Append(putEntry("lsn-test", main.ID, "data", fmt.Sprintf("doc-%d", i)))
-			_ = branchService.UpdateBranchHead(main.ID, lsn)
+			_ = branchService.UpdateBranchHead(main.ID, lsn, time.Now())
 		}
 
 		// Get updated main
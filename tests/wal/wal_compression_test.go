@@ -0,0 +1,144 @@
+package wal_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/argon-lab/argon/internal/wal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var compressionTypes = []wal.CompressionType{
+	wal.CompressionNone, wal.CompressionGzip, wal.CompressionZstd, wal.CompressionSnappy,
+}
+
+func TestParseCompressionType(t *testing.T) {
+	for name, want := range map[string]wal.CompressionType{
+		"none": wal.CompressionNone, "gzip": wal.CompressionGzip,
+		"zstd": wal.CompressionZstd, " Snappy ": wal.CompressionSnappy,
+	} {
+		got, err := wal.ParseCompressionType(name)
+		require.NoError(t, err, name)
+		assert.Equal(t, want, got, name)
+	}
+	for _, name := range []string{"", "lz4", "zstd:3"} {
+		_, err := wal.ParseCompressionType(name)
+		assert.Error(t, err, name)
+	}
+}
+
+// Whatever a value was written with, a compressor configured for any
+// other type reads it back: the type byte decides, not the config.
+func TestCompressor_ReadsEveryType(t *testing.T) {
+	data := bytes.Repeat([]byte("argon wal document "), 200)
+	for _, written := range compressionTypes {
+		config := wal.DefaultCompressionConfig()
+		config.Type = written
+		writer, err := wal.NewCompressor(config)
+		require.NoError(t, err)
+		stored, err := writer.Compress(data)
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+		assert.Equal(t, byte(written), stored[0])
+
+		for _, configured := range compressionTypes {
+			config := wal.DefaultCompressionConfig()
+			config.Type = configured
+			reader, err := wal.NewCompressor(config)
+			require.NoError(t, err)
+			got, err := reader.Decompress(stored)
+			require.NoError(t, err, "written %d, read with %d", written, configured)
+			assert.Equal(t, data, got)
+			require.NoError(t, reader.Close())
+		}
+	}
+}
+
+func TestMetrics_CompressionRatio(t *testing.T) {
+	m := wal.NewMetrics()
+	assert.Zero(t, m.GetSnapshot().CompressionRatio)
+
+	m.RecordImageBytes(3000, 1000)
+	m.RecordImageBytes(100, 105)
+	snapshot := m.GetSnapshot()
+	assert.Equal(t, int64(3100), snapshot.ImageBytesRaw)
+	assert.Equal(t, int64(1105), snapshot.ImageBytesStored)
+	assert.InDelta(t, 3100.0/1105.0, snapshot.CompressionRatio, 1e-9)
+
+	m.Reset()
+	assert.Zero(t, m.GetSnapshot().ImageBytesRaw)
+}
+
+func TestWALCompression_MixedEntriesRoundTrip(t *testing.T) {
+	db := setupTestDB(t)
+	walService, err := wal.NewService(db)
+	require.NoError(t, err)
+	before := walService.GetMetrics()
+
+	large, err := bson.Marshal(bson.M{"body": bytes.Repeat([]byte("compressible "), 400)})
+	require.NoError(t, err)
+	tiny, err := bson.Marshal(bson.M{"v": 1})
+	require.NoError(t, err)
+
+	// Write a large and a tiny document under each setting in turn.
+	var lsns []int64
+	var images []bson.Raw
+	for _, typ := range compressionTypes {
+		require.NoError(t, walService.SetCompression(typ))
+		for _, image := range []bson.Raw{large, tiny} {
+			lsn, err := walService.Append(&wal.Entry{
+				ProjectID:  "compression",
+				BranchID:   "main",
+				Operation:  wal.OpPut,
+				Collection: "docs",
+				DocumentID: fmt.Sprintf("d%d", len(lsns)),
+				PostImage:  image,
+				PreImage:   image,
+			})
+			require.NoError(t, err)
+			lsns = append(lsns, lsn)
+			images = append(images, image)
+		}
+	}
+
+	// Stored type bytes: large documents use the setting they were written
+	// under; tiny ones skip compression because it would not help.
+	for i, typ := range compressionTypes {
+		var raw struct {
+			Post []byte `bson:"post"`
+		}
+		require.NoError(t, db.Collection("wal_log").FindOne(context.Background(),
+			bson.M{"project_id": "compression", "lsn": lsns[2*i]}).Decode(&raw))
+		assert.Equal(t, byte(typ), raw.Post[0], "large document under type %d", typ)
+		require.NoError(t, db.Collection("wal_log").FindOne(context.Background(),
+			bson.M{"project_id": "compression", "lsn": lsns[2*i+1]}).Decode(&raw))
+		assert.Equal(t, byte(wal.CompressionNone), raw.Post[0], "tiny document under type %d", typ)
+	}
+
+	// Every entry reads back intact whatever the current setting.
+	require.NoError(t, walService.SetCompression(wal.CompressionSnappy))
+	for i, lsn := range lsns {
+		entry, err := walService.GetEntry("compression", lsn)
+		require.NoError(t, err)
+		assert.Equal(t, images[i], entry.PostImage)
+		assert.Equal(t, images[i], entry.PreImage)
+	}
+	entries, err := walService.GetEntries(bson.M{"project_id": "compression"})
+	require.NoError(t, err)
+	require.Len(t, entries, len(lsns))
+	for i, entry := range entries {
+		assert.Equal(t, images[i], entry.PostImage)
+	}
+
+	// The ratio reflects what was actually stored.
+	after := walService.GetMetrics()
+	rawBytes := after.ImageBytesRaw - before.ImageBytesRaw
+	stored := after.ImageBytesStored - before.ImageBytesStored
+	assert.Equal(t, int64(len(compressionTypes)*2*(len(large)+len(tiny))), rawBytes)
+	assert.Less(t, stored, rawBytes)
+	assert.Greater(t, after.CompressionRatio, 0.0)
+}
@@ -97,6 +97,132 @@ func TestWALService_Basic(t *testing.T) {
 	assert.Len(t, entries, 2)
 }
 
+func TestWALService_Subscribe(t *testing.T) {
+	db := setupTestDB(t)
+	walService, err := wal.NewService(db)
+	require.NoError(t, err)
+
+	put := func(branchID, collection, docID string) *wal.Entry {
+		return &wal.Entry{
+			ProjectID:  "test-project",
+			BranchID:   branchID,
+			Operation:  wal.OpPut,
+			Collection: collection,
+			DocumentID: docID,
+			PostImage:  mustMarshalBSON(bson.M{"_id": docID}),
+		}
+	}
+
+	sub := walService.Subscribe(wal.StreamFilter{ProjectID: "test-project", BranchID: "main"}, 0)
+	defer walService.Unsubscribe(sub)
+
+	lsn1, err := walService.Append(put("main", "users", "u1"))
+	require.NoError(t, err)
+	_, err = walService.Append(put("other", "users", "u2"))
+	require.NoError(t, err)
+	_, err = walService.AppendBatch([]*wal.Entry{put("main", "users", "u3"), put("main", "users", "u4")})
+	require.NoError(t, err)
+
+	// Published entries carry their images even though the stored copy
+	// is compressed.
+	got := <-sub.Entries
+	assert.Equal(t, lsn1, got.LSN)
+	assert.Equal(t, mustMarshalBSON(bson.M{"_id": "u1"}), got.PostImage)
+	assert.Equal(t, "u3", (<-sub.Entries).DocumentID)
+	assert.Equal(t, "u4", (<-sub.Entries).DocumentID)
+
+	// A pending entry is delivered when confirmed, not when appended.
+	pendingLSN, err := walService.AppendPending(put("main", "users", "u5"))
+	require.NoError(t, err)
+	select {
+	case entry := <-sub.Entries:
+		t.Fatalf("pending entry %d delivered before confirmation", entry.LSN)
+	default:
+	}
+	require.NoError(t, walService.ConfirmEntry("test-project", pendingLSN))
+	confirmed := <-sub.Entries
+	assert.Equal(t, pendingLSN, confirmed.LSN)
+	assert.False(t, confirmed.Pending)
+
+	// A consumer that stops reading is dropped; the append goes through.
+	slow := walService.Subscribe(wal.StreamFilter{ProjectID: "test-project"}, 1)
+	_, err = walService.AppendBatch([]*wal.Entry{put("main", "users", "u6"), put("main", "users", "u7")})
+	require.NoError(t, err)
+	assert.Equal(t, "u6", (<-slow.Entries).DocumentID)
+	_, open := <-slow.Entries
+	assert.False(t, open, "the dropped subscription is closed")
+	assert.True(t, slow.Dropped())
+	walService.Unsubscribe(slow) // a no-op once dropped
+
+	// Unsubscribing closes the channel after what is already buffered.
+	walService.Unsubscribe(sub)
+	var rest []string
+	for entry := range sub.Entries {
+		rest = append(rest, entry.DocumentID)
+	}
+	assert.Equal(t, []string{"u6", "u7"}, rest)
+	assert.False(t, sub.Dropped())
+}
+
+func TestWALService_GetEntriesByUser(t *testing.T) {
+	db := setupTestDB(t)
+	walService, err := wal.NewService(db)
+	require.NoError(t, err)
+
+	writes := []struct{ branch, actor string }{
+		{"main", "user:alice"},
+		{"main", "user:bob"},
+		{"feature", "user:alice"},
+		{"main", ""},
+		{"feature", "user:bob"},
+		{"main", "user:alice"},
+	}
+	for i, w := range writes {
+		_, err := walService.Append(&wal.Entry{
+			ProjectID:  "audit-project",
+			BranchID:   w.branch,
+			Operation:  wal.OpPut,
+			Collection: "docs",
+			DocumentID: fmt.Sprintf("doc-%d", i),
+			PostImage:  mustMarshalBSON(bson.M{"n": i}),
+			Actor:      w.actor,
+		})
+		require.NoError(t, err)
+	}
+	// Same actor in another project must not leak into the audit.
+	_, err = walService.Append(&wal.Entry{
+		ProjectID:  "other-project",
+		BranchID:   "main",
+		Operation:  wal.OpPut,
+		Collection: "docs",
+		DocumentID: "x",
+		PostImage:  mustMarshalBSON(bson.M{"n": 0}),
+		Actor:      "user:alice",
+	})
+	require.NoError(t, err)
+
+	entries, err := walService.GetEntriesByUser("audit-project", "user:alice", 0, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, entries, 3, "spans every branch of the project")
+	for i, want := range []int64{1, 3, 6} {
+		assert.Equal(t, want, entries[i].LSN)
+		assert.Equal(t, "user:alice", entries[i].Actor)
+	}
+
+	entries, err = walService.GetEntriesByUser("audit-project", "user:bob", 3, 5, 0)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, int64(5), entries[0].LSN)
+
+	entries, err = walService.GetEntriesByUser("audit-project", "user:alice", 0, 0, 2)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, int64(3), entries[1].LSN)
+
+	_, err = walService.GetEntriesByUser("audit-project", "", 0, 0, 0)
+	assert.Error(t, err)
+}
+
 func TestBranchService_CreateBranch(t *testing.T) {
 	db := setupTestDB(t)
 
@@ -176,6 +302,58 @@ func TestBranchService_DeleteBranch(t *testing.T) {
 	assert.Greater(t, currentLSN, int64(0))
 }
 
+func TestBranchService_LastActivity(t *testing.T) {
+	db := setupTestDB(t)
+
+	walService, err := wal.NewService(db)
+	require.NoError(t, err)
+	branchService, err := branchwal.NewBranchService(db, walService)
+	require.NoError(t, err)
+
+	branch, err := branchService.CreateBranch("project-1", "main", "")
+	require.NoError(t, err)
+	assert.Nil(t, branch.LastActivityAt, "no writes yet")
+
+	write := func(docID string) *wal.Entry {
+		entry := &wal.Entry{
+			ProjectID:  "project-1",
+			BranchID:   branch.ID,
+			Operation:  wal.OpPut,
+			Collection: "users",
+			DocumentID: docID,
+			PostImage:  mustMarshalBSON(bson.M{"_id": docID}),
+		}
+		lsn, err := walService.Append(entry)
+		require.NoError(t, err)
+		require.NoError(t, branchService.UpdateBranchHead(branch.ID, lsn, entry.Timestamp))
+		return entry
+	}
+
+	first := write("user-1")
+	branch, err = branchService.GetBranchByID(branch.ID)
+	require.NoError(t, err)
+	require.NotNil(t, branch.LastActivityAt)
+	assert.True(t, branch.LastActivityAt.Equal(first.Timestamp.Truncate(time.Millisecond)),
+		"the entry's own time, not the head update's")
+	firstActivity := *branch.LastActivityAt
+
+	time.Sleep(5 * time.Millisecond)
+	second := write("user-2")
+	branch, err = branchService.GetBranchByID(branch.ID)
+	require.NoError(t, err)
+	assert.True(t, branch.LastActivityAt.After(firstActivity))
+	assert.True(t, branch.LastActivityAt.Equal(second.Timestamp.Truncate(time.Millisecond)))
+
+	// A stale head update neither moves the head back nor counts as
+	// activity.
+	latest := *branch.LastActivityAt
+	require.NoError(t, branchService.UpdateBranchHead(branch.ID, first.LSN, first.Timestamp))
+	branch, err = branchService.GetBranchByID(branch.ID)
+	require.NoError(t, err)
+	assert.Equal(t, second.LSN, branch.HeadLSN)
+	assert.True(t, branch.LastActivityAt.Equal(latest))
+}
+
 func TestProjectService_CreateProject(t *testing.T) {
 	db := setupTestDB(t)
 
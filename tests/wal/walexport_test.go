@@ -0,0 +1,105 @@
+package wal_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/argon-lab/argon/internal/walexport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestWALExport_SplitsIntoSegments(t *testing.T) {
+	db := setupTestDB(t)
+	walService, branchService, _, branch, writer := newMaterializerFixture(t, db, "export-project", "main")
+	ctx := context.Background()
+
+	for i := 0; i < 25; i++ {
+		_, err := writer.Put(ctx, "items", bson.M{"_id": fmt.Sprintf("item-%02d", i), "n": int32(i), "pad": strings.Repeat("x", 40*i)})
+		require.NoError(t, err)
+	}
+	_, _, err := writer.Delete(ctx, "items", "item-03")
+	require.NoError(t, err)
+	branch, _ = branchService.GetBranchByID(branch.ID)
+
+	all, err := walService.GetBranchEntries(branch.ID, "", 0, branch.HeadLSN)
+	require.NoError(t, err)
+	require.NotEmpty(t, all)
+
+	exporter := walexport.NewService(walService)
+
+	// checkSegments asserts the segments tile the full export in order.
+	checkSegments := func(t *testing.T, res *walexport.Result) {
+		t.Helper()
+		var lsns []int64
+		for _, seg := range res.Segments {
+			assert.Equal(t, walexport.SegmentName(seg.FromLSN, seg.ToLSN), filepath.Base(seg.Path))
+			entries, err := walexport.ReadSegment(seg.Path)
+			require.NoError(t, err)
+			require.Len(t, entries, seg.Entries)
+			assert.Equal(t, seg.FromLSN, entries[0].LSN)
+			assert.Equal(t, seg.ToLSN, entries[len(entries)-1].LSN)
+			for _, e := range entries {
+				lsns = append(lsns, e.LSN)
+			}
+		}
+		require.Len(t, lsns, len(all), "segments must collectively hold every entry")
+		for i, e := range all {
+			assert.Equal(t, e.LSN, lsns[i])
+		}
+		assert.Equal(t, len(all), res.Entries)
+	}
+
+	t.Run("max entries", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "by-count")
+		res, err := exporter.Export(ctx, branch.ProjectID, walexport.Options{OutputDir: dir, MaxEntriesPerSegment: 4})
+		require.NoError(t, err)
+		assert.Len(t, res.Segments, (len(all)+3)/4)
+		for _, seg := range res.Segments {
+			assert.LessOrEqual(t, seg.Entries, 4)
+		}
+		checkSegments(t, res)
+
+		files, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Len(t, files, len(res.Segments), "no temporary files are left behind")
+	})
+
+	t.Run("max bytes", func(t *testing.T) {
+		const limit = 2048
+		dir := filepath.Join(t.TempDir(), "by-size")
+		res, err := exporter.Export(ctx, branch.ProjectID, walexport.Options{OutputDir: dir, MaxSegmentBytes: limit})
+		require.NoError(t, err)
+		assert.Greater(t, len(res.Segments), 1)
+		for _, seg := range res.Segments {
+			info, err := os.Stat(seg.Path)
+			require.NoError(t, err)
+			assert.Equal(t, seg.Bytes, info.Size())
+			if seg.Entries > 1 {
+				assert.LessOrEqual(t, info.Size(), int64(limit))
+			}
+		}
+		checkSegments(t, res)
+	})
+
+	t.Run("images survive the round trip", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "images")
+		res, err := exporter.Export(ctx, branch.ProjectID, walexport.Options{OutputDir: dir, Collection: "items"})
+		require.NoError(t, err)
+		require.Len(t, res.Segments, 1)
+		entries, err := walexport.ReadSegment(res.Segments[0].Path)
+		require.NoError(t, err)
+
+		var doc bson.M
+		require.NoError(t, bson.Unmarshal(entries[0].PostImage, &doc))
+		assert.Equal(t, "item-00", doc["_id"])
+		last := entries[len(entries)-1]
+		assert.Equal(t, "item-03", last.DocumentID)
+		assert.NotEmpty(t, last.PreImage, "deletes keep their pre-image")
+	})
+}
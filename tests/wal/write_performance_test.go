@@ -201,3 +201,56 @@ func BenchmarkWALWrites(b *testing.B) {
 		b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "ops/sec")
 	})
 }
+
+// BenchmarkWALAppend compares 10k sequential appends against the same
+// entries appended as one batch, which reserves its LSN range once and
+// inserts with a single InsertMany.
+func BenchmarkWALAppend(b *testing.B) {
+	db := setupBenchDB(b)
+	walService, _ := wal.NewService(db)
+	const count = 10000
+
+	entries := func(run int) []*wal.Entry {
+		out := make([]*wal.Entry, count)
+		for i := range out {
+			post, _ := bson.Marshal(bson.M{"_id": fmt.Sprintf("doc-%d", i), "run": run})
+			out[i] = &wal.Entry{
+				ProjectID:  "bench-append",
+				BranchID:   "main",
+				Operation:  wal.OpPut,
+				Collection: "bench_docs",
+				DocumentID: fmt.Sprintf("doc-%d", i),
+				PostImage:  post,
+			}
+		}
+		return out
+	}
+
+	b.Run("Sequential", func(b *testing.B) {
+		b.StopTimer()
+		for i := 0; i < b.N; i++ {
+			batch := entries(i)
+			b.StartTimer()
+			for _, entry := range batch {
+				if _, err := walService.Append(entry); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.StopTimer()
+		}
+		b.ReportMetric(float64(b.N*count)/b.Elapsed().Seconds(), "entries/sec")
+	})
+
+	b.Run("Batch", func(b *testing.B) {
+		b.StopTimer()
+		for i := 0; i < b.N; i++ {
+			batch := entries(i)
+			b.StartTimer()
+			if _, err := walService.AppendBatch(batch); err != nil {
+				b.Fatal(err)
+			}
+			b.StopTimer()
+		}
+		b.ReportMetric(float64(b.N*count)/b.Elapsed().Seconds(), "entries/sec")
+	})
+}
@@ -0,0 +1,191 @@
+package wal_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/argon-lab/argon/internal/walwriter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestWriter_RejectsReservedFields(t *testing.T) {
+	db := setupTestDB(t)
+	walService, _, mat, branch, writer := newMaterializerFixture(t, db, "reserved-project", "main")
+	ctx := context.Background()
+
+	_, err := writer.Put(ctx, "users", bson.M{"_id": "u1", "_argon": bson.M{"lsn": 1}})
+	var reserved *walwriter.ReservedFieldError
+	require.True(t, errors.As(err, &reserved), "got %v", err)
+	assert.Equal(t, "_argon", reserved.Field)
+	assert.Equal(t, "users", reserved.Collection)
+
+	// A rejected batch appends nothing, not even its valid documents.
+	_, err = writer.PutMany(ctx, "users", []bson.M{{"_id": "u2"}, {"_id": "u3", "$set": 1}})
+	require.True(t, errors.As(err, &reserved))
+	assert.Equal(t, "$set", reserved.Field)
+	assert.Equal(t, int64(0), walService.GetCurrentLSN(branch.ProjectID))
+
+	writer.SetReserved(walwriter.Reserved{Fields: []string{"tenant"}})
+	_, err = writer.Put(ctx, "users", bson.M{"_id": "u4", "tenant": "acme"})
+	require.True(t, errors.As(err, &reserved))
+	assert.Equal(t, "tenant", reserved.Field)
+
+	_, err = writer.Put(ctx, "users", bson.M{"_id": "u5", "_argon": "allowed now"})
+	require.NoError(t, err)
+	doc, err := mat.MaterializeDocument(branch, "users", "u5")
+	require.NoError(t, err)
+	assert.Equal(t, "allowed now", doc["_argon"])
+}
+
+func TestWriter_MergeCarriesReservedKeysUnchanged(t *testing.T) {
+	db := setupTestDB(t)
+	f := newMergeFixture(t, db, "reserved-merge")
+	ctx := context.Background()
+
+	// Import and ingest append without the writer's key policy; model
+	// such a source branch with a writer that reserves nothing.
+	f.featWriter.SetReserved(walwriter.Reserved{})
+	_, err := f.featWriter.Put(ctx, "docs", bson.M{"_id": "tagged", "_argon": "meta", "$legacy": int32(1)})
+	require.NoError(t, err)
+	f.refresh(t)
+
+	plan, err := f.merge.Preview(ctx, f.feature.ID)
+	require.NoError(t, err)
+	result, err := f.merge.Apply(ctx, plan.ID, "")
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Applied)
+
+	f.refresh(t)
+	doc, err := f.matFull.MaterializeDocument(f.main, "docs", "tagged")
+	require.NoError(t, err)
+	assert.Equal(t, "meta", doc["_argon"])
+	assert.Equal(t, int32(1), doc["$legacy"])
+}
+
+func TestWriter_Transactions(t *testing.T) {
+	db := setupTestDB(t)
+	walService, branchService, mat, branch, writer := newMaterializerFixture(t, db, "tx-project", "main")
+	ctx := context.Background()
+
+	_, err := writer.Put(ctx, "accounts", bson.M{"_id": "a", "balance": int32(100)})
+	require.NoError(t, err)
+	_, err = writer.Put(ctx, "accounts", bson.M{"_id": "b", "balance": int32(0)})
+	require.NoError(t, err)
+	before := branch.HeadLSN
+
+	t.Run("Committed transaction appears atomically", func(t *testing.T) {
+		tx, err := writer.BeginTx()
+		require.NoError(t, err)
+		require.NoError(t, tx.Put(ctx, "accounts", bson.M{"_id": "a", "balance": int32(60)}))
+		require.NoError(t, tx.Put(ctx, "accounts", bson.M{"_id": "b", "balance": int32(40)}))
+		require.NoError(t, tx.Put(ctx, "ledger", bson.M{"_id": "t1", "amount": int32(40)}))
+		existed, err := tx.Delete(ctx, "ledger", "t1")
+		require.NoError(t, err)
+		assert.True(t, existed, "a delete sees the transaction's own staged put")
+		require.NoError(t, tx.Put(ctx, "ledger", bson.M{"_id": "t1", "amount": int32(40), "ok": true}))
+
+		// Nothing is visible before commit.
+		stored, err := branchService.GetBranchByID(branch.ID)
+		require.NoError(t, err)
+		assert.Equal(t, before, stored.HeadLSN)
+		state, err := mat.MaterializeCollection(stored, "accounts")
+		require.NoError(t, err)
+		assert.EqualValues(t, 100, state["a"]["balance"])
+
+		result, err := tx.Commit(ctx)
+		require.NoError(t, err)
+		require.Len(t, result.LSNs, 5)
+		assert.Equal(t, result.FirstLSN+4, result.LastLSN, "one contiguous block")
+
+		stored, err = branchService.GetBranchByID(branch.ID)
+		require.NoError(t, err)
+		assert.Equal(t, result.LastLSN, stored.HeadLSN)
+		entries, err := walService.GetBranchEntries(branch.ID, "", result.FirstLSN, result.LastLSN)
+		require.NoError(t, err)
+		require.Len(t, entries, 5)
+		for _, entry := range entries {
+			assert.Equal(t, tx.ID(), entry.TxnID)
+		}
+
+		// Pre- and post-transaction states.
+		pre, err := mat.MaterializeBranchAtLSN(stored, result.FirstLSN-1)
+		require.NoError(t, err)
+		assert.EqualValues(t, 100, pre["accounts"]["a"]["balance"])
+		assert.Empty(t, pre["ledger"])
+		post, err := mat.MaterializeBranch(stored)
+		require.NoError(t, err)
+		assert.EqualValues(t, 60, post["accounts"]["a"]["balance"])
+		assert.EqualValues(t, 40, post["accounts"]["b"]["balance"])
+		assert.Equal(t, true, post["ledger"]["t1"]["ok"])
+
+		_, err = tx.Commit(ctx)
+		assert.Error(t, err, "a transaction commits once")
+	})
+
+	t.Run("Aborted transaction leaves no entries", func(t *testing.T) {
+		head := walService.GetCurrentLSN(branch.ProjectID)
+		tx, err := writer.BeginTx()
+		require.NoError(t, err)
+		require.NoError(t, tx.Put(ctx, "accounts", bson.M{"_id": "a", "balance": int32(0)}))
+		_, err = tx.Delete(ctx, "accounts", "b")
+		require.NoError(t, err)
+		tx.Abort()
+
+		assert.Equal(t, head, walService.GetCurrentLSN(branch.ProjectID))
+		stored, err := branchService.GetBranchByID(branch.ID)
+		require.NoError(t, err)
+		assert.Equal(t, head, stored.HeadLSN)
+		state, err := mat.MaterializeCollection(stored, "accounts")
+		require.NoError(t, err)
+		assert.EqualValues(t, 60, state["a"]["balance"])
+		assert.Contains(t, state, "b")
+		assert.Error(t, tx.Put(ctx, "accounts", bson.M{"_id": "c"}), "an aborted transaction takes no more writes")
+	})
+}
+
+// sequenceIDs hands out "doc-1", "doc-2", ... in order.
+type sequenceIDs struct{ next int }
+
+func (s *sequenceIDs) NewID() interface{} {
+	s.next++
+	return fmt.Sprintf("doc-%d", s.next)
+}
+
+func TestWriter_IDGenerator(t *testing.T) {
+	db := setupTestDB(t)
+	_, _, mat, branch, writer := newMaterializerFixture(t, db, "ids-project", "main")
+	ctx := context.Background()
+
+	writer.SetIDGenerator(&sequenceIDs{})
+	_, err := writer.Put(ctx, "users", bson.M{"name": "ada"})
+	require.NoError(t, err)
+	_, err = writer.PutMany(ctx, "users", []bson.M{{"name": "grace"}, {"_id": "explicit", "name": "linus"}, {"name": "alan"}})
+	require.NoError(t, err)
+
+	state, err := mat.MaterializeCollection(branch, "users")
+	require.NoError(t, err)
+	names := map[string]string{}
+	for id, doc := range state {
+		assert.Equal(t, id, doc["_id"])
+		names[id] = doc["name"].(string)
+	}
+	assert.Equal(t, map[string]string{"doc-1": "ada", "doc-2": "grace", "explicit": "linus", "doc-3": "alan"}, names)
+
+	// A nil generator restores the ObjectID default.
+	writer.SetIDGenerator(nil)
+	_, err = writer.Put(ctx, "users", bson.M{"name": "barbara"})
+	require.NoError(t, err)
+	state, err = mat.MaterializeCollection(branch, "users")
+	require.NoError(t, err)
+	require.Len(t, state, 5)
+	for id, doc := range state {
+		if doc["name"] == "barbara" {
+			assert.IsType(t, primitive.ObjectID{}, doc["_id"], "id %s", id)
+		}
+	}
+}